@@ -0,0 +1,28 @@
+"""
+Stable public API of Nexus, for projects that use it as a library.
+
+The helpers and services packages are internal and change with the
+strategies. The modules here re-export the reusable parts under names and
+signatures that only change with a new major API_VERSION:
+
+    statistics  Time series tests, regressions, volatility models, and outlier cleaning
+    indicators  Bollinger Bands, spreads, return series, and rolling windows
+    broker      The Broker interface, MockBroker, and the active broker
+    bus         Publishing to and consuming from the SNS/SQS message bus
+    backtest    The Reversion backtest and parameter grid runner
+
+Within a major version names are only added, never removed or renamed, and
+parameters are only added with defaults. Installing the repo puts api,
+helpers, and services at the top level, import from api:
+
+    pip install git+https://github.com/jaredgrxss/Nexus.git
+
+    from api import statistics
+    fit = statistics.garch(returns)
+"""
+from . import backtest, broker, bus, indicators, statistics
+
+# Major version changes break compatibility, minor versions only add to the API
+API_VERSION = '1.0'
+
+__all__ = ['API_VERSION', 'backtest', 'broker', 'bus', 'indicators', 'statistics']
@@ -0,0 +1,13 @@
+"""
+Backtests of the Reversion rule and a parallel parameter grid runner.
+
+Example:
+    from api import backtest
+
+    result = backtest.backtest_bollinger_reversion(closes, window=20, num_std=2)
+    grid = backtest.parameter_grid(window=[10, 20, 30], num_std=[1.5, 2, 2.5])
+    results = backtest.run_parameter_grid(backtest.backtest_bollinger_reversion, grid, closes=closes)
+"""
+from helpers.backtest import backtest_bollinger_reversion, parameter_grid, run_parameter_grid
+
+__all__ = ['backtest_bollinger_reversion', 'parameter_grid', 'run_parameter_grid']
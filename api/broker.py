@@ -0,0 +1,18 @@
+"""
+The broker interface strategies trade through.
+
+Implement Broker to add a broker, or trade against a MockBroker in tests
+and backtests. Quantities are signed by side and bars are dictionaries in
+the format published on the data topic.
+
+Example:
+    from api import broker
+
+    mock = broker.MockBroker(cash=100_000)
+    mock.set_price('AAPL', 185.0)
+    broker.set_broker(mock)
+    broker.get_broker().submit_market_order('AAPL', 10, broker.OrderSide.BUY)
+"""
+from helpers.brokers import Broker, BarHandler, MockBroker, OrderSide, get_broker, set_broker
+
+__all__ = ['Broker', 'BarHandler', 'MockBroker', 'OrderSide', 'get_broker', 'set_broker']
@@ -0,0 +1,79 @@
+"""
+The SNS/SQS message bus services publish bars and signals on.
+
+Publishers send JSON to a topic in a versioned envelope naming the
+payload's schema, e.g. 'bar.v1'. Each consumer drains its own queue
+subscribed to the topic and unwraps the SNS notification and the envelope,
+decompressing payloads published compressed.
+
+Example:
+    from api import bus
+
+    bus.publish(bus.encode('bar', bar, source='my-service'), topic_arn)
+    for message in bus.receive(queue_url, max_messages=10):
+        decoded = bus.decode(message)
+        if decoded['kind'] == 'bar':
+            bar = decoded['payload']
+        bus.delete(queue_url, message['ReceiptHandle'])
+"""
+from helpers import cloud, envelope
+from typing import Optional
+
+__all__ = ['publish', 'receive', 'delete', 'subscribe', 'unwrap', 'encode', 'decode']
+
+
+def publish(data: str, topic: str) -> dict:
+    """
+    Publish a message to an SNS topic, see cloud.publish_sns_message.
+    """
+    return cloud.publish_sns_message(data, topic)
+
+
+def receive(queue_url: str, max_messages: int = 1, wait_time_seconds: int = 10) -> list:
+    """
+    Receive up to max_messages from an SQS queue, see cloud.poll_sqs_message.
+    """
+    return cloud.poll_sqs_message(queue_url=queue_url, max_messages=max_messages, wait_time_seconds=wait_time_seconds)
+
+
+def delete(queue_url: str, receipt_handle: str) -> None:
+    """
+    Delete a handled message from an SQS queue, see cloud.delete_sqs_message.
+    """
+    cloud.delete_sqs_message(queue_url=queue_url, receipt_handle=receipt_handle)
+
+
+def subscribe(queue_arn: str, topic_arn: str) -> dict:
+    """
+    Subscribe an SQS queue to an SNS topic, see cloud.subscribe_sqs_to_sns.
+    """
+    return cloud.subscribe_sqs_to_sns(queue_arn=queue_arn, topic_arn=topic_arn)
+
+
+def unwrap(message: dict) -> dict:
+    """
+    Decode the JSON payload of a message received from a queue subscribed to a topic.
+
+    Args:
+        message (dict): A message as returned by receive().
+
+    Returns:
+        dict: The published payload, without its envelope.
+    """
+    return envelope.decode_sqs(message)['payload']
+
+
+def encode(kind: str, payload: dict, source: str, compression: Optional[str] = None) -> str:
+    """
+    Wrap a payload in the message envelope for publish(), optionally
+    compressing it with gzip or zstd, see envelope.encode.
+    """
+    return envelope.encode(kind, payload, source, compression=compression)
+
+
+def decode(message: dict) -> dict:
+    """
+    Decode a message received from a queue subscribed to a topic into its
+    schema, kind, version, producer, and payload, see envelope.decode_sqs.
+    """
+    return envelope.decode_sqs(message)
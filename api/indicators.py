@@ -0,0 +1,34 @@
+"""
+Indicators over price series: Bollinger Bands, moving averages, oscillators,
+ranges, stock/ETF spreads, return series, and rolling windows of bars.
+
+Example:
+    from api import indicators
+
+    bands = indicators.bollinger_bands(closes, window=20, num_std=2)
+    if closes[-1] <= bands['lower_band'][-1]:
+        ...
+
+    if indicators.rsi(bars)[-1] < 30 and indicators.macd(bars)['histogram'][-1] > 0:
+        ...
+
+    hedge_ratio = indicators.beta(stock_closes, etf_closes)
+    spread = indicators.spread(stock_closes, etf_closes, hedge_ratio)
+
+    returns = indicators.aligned_returns({symbol: indicators.resample(bars[symbol], '15Min') for symbol in bars})
+"""
+from helpers.statistics import bollinger_bands
+from helpers.indicators import sma, ema, macd, rsi, stochastic, true_range, atr
+from helpers.spreads import (
+    log_returns, beta, rolling_beta, hedge_ratio, rolling_hedge_ratio, hedge_ratio_drift,
+    spread, basket_spread, spread_signal, hedge_qty
+)
+from helpers.returns import simple_returns, to_returns, resample, align_bars, aligned_returns
+from helpers.windows import RollingWindow, RunningStats, RunningStatsStore
+
+__all__ = [
+    'bollinger_bands', 'sma', 'ema', 'macd', 'rsi', 'stochastic', 'true_range', 'atr',
+    'log_returns', 'beta', 'rolling_beta', 'hedge_ratio', 'rolling_hedge_ratio', 'hedge_ratio_drift',
+    'spread', 'basket_spread', 'spread_signal', 'hedge_qty',
+    'simple_returns', 'to_returns', 'resample', 'align_bars', 'aligned_returns', 'RollingWindow', 'RunningStats', 'RunningStatsStore'
+]
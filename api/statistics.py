@@ -0,0 +1,62 @@
+"""
+Statistical tests, regressions, volatility models, outlier cleaning,
+cross-sectional ranking, and OU spread simulation.
+
+Example:
+    from api import statistics
+
+    if statistics.cointegration_adf_test(stock, etf)['is_cointegrated']:
+        hedge = statistics.least_squares([[price] for price in etf], stock)['coefficients'][0]
+
+    fit = statistics.garch(returns)
+    qty = base_qty * statistics.volatility_scale(fit['forecast_volatility'], target_volatility=0.01)
+"""
+from helpers.statistics import (
+    adf_test,
+    kpss_test,
+    stationarity_test,
+    half_life,
+    hurst_exponent,
+    hurst_confidence_interval,
+    variance_ratio_test,
+    screen_series,
+    cointegration_adf_test,
+    engle_granger_test,
+    adjust_p_values,
+    johansen_test,
+    vecm,
+    covariance_matrix,
+    correlation_matrix,
+    rolling_correlation,
+    rolling_correlations,
+    correlated_pairs,
+    pca,
+    factor_residuals,
+    mean,
+    variance,
+    standard_deviation,
+    quantile,
+    percentile,
+    skewness,
+    excess_kurtosis,
+    describe,
+    garch,
+    volatility_scale,
+    linear_regression,
+    least_squares,
+    multiple_regression,
+)
+from helpers.outliers import clip, winsorize, median_filter, bad_ticks, remove_bad_ticks
+from helpers.cross_section import ranks, percentiles, zscores, demean, quantile_buckets
+from helpers.ou import fit_ou, simulate_paths, simulate_trades
+
+__all__ = [
+    'adf_test', 'kpss_test', 'stationarity_test', 'half_life', 'hurst_exponent', 'hurst_confidence_interval',
+    'variance_ratio_test', 'screen_series', 'cointegration_adf_test', 'engle_granger_test', 'adjust_p_values',
+    'johansen_test', 'vecm', 'covariance_matrix', 'correlation_matrix', 'rolling_correlation',
+    'rolling_correlations', 'correlated_pairs', 'pca', 'factor_residuals', 'mean', 'variance',
+    'standard_deviation', 'quantile', 'percentile', 'skewness', 'excess_kurtosis', 'describe', 'garch',
+    'volatility_scale', 'linear_regression', 'least_squares', 'multiple_regression', 'clip', 'winsorize',
+    'median_filter', 'bad_ticks', 'remove_bad_ticks', 'ranks', 'percentiles', 'zscores', 'demean',
+    'quantile_buckets', 'fit_ou', 'simulate_paths', 'simulate_trades'
+]
@@ -1,7 +1,7 @@
 import os
 from dotenv import load_dotenv
-from helpers import logger, cloud
-from services import reversion, data, momentum
+from helpers import logger, cloud, admin, version
+from services import reversion, data, momentum, backtest, scanner, replay
 
 if __name__ == '__main__':
     # Set up logger
@@ -26,6 +26,13 @@ if __name__ == '__main__':
         logger.error(f"Error in decrypting env file: {e}")
     # Load secrets from the env file
     load_dotenv()
+    # Record exactly which code and config produced this run
+    logger.info(f'Build info: {version.get_build_info()}')
+    # Serve health, metrics, and per-symbol stats for the running service
+    try:
+        admin.start_admin_server()
+    except Exception as e:
+        logger.error(f'Error starting admin API: {e}')
     # Run the respective service
     match os.getenv('SERVICE'):
         case 'Data':
@@ -36,3 +43,12 @@ if __name__ == '__main__':
             reversion.run()
         case 'Momentum':
             momentum.run()
+        case 'Backtest':
+            logger.info('Running Backtest service.')
+            backtest.run()
+        case 'Scanner':
+            logger.info('Running Scanner service.')
+            scanner.run()
+        case 'Replay':
+            logger.info('Running Replay service.')
+            replay.run()
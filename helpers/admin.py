@@ -0,0 +1,144 @@
+import os
+import hmac
+import json
+import threading
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+from urllib.parse import urlparse, parse_qs
+from helpers import logger, metrics, version
+from typing import Callable, Optional
+
+# Initialize logger
+logger = logger.Logger('admin.py')
+
+# Registered endpoints { (method, path): handler(query, body) -> dict }
+routes = {}
+
+
+def tokens() -> dict:
+    """
+    Read the bearer tokens allowed to call POST routes.
+
+    Environment Variables:
+        ADMIN_TOKENS (str): Comma-separated name:token pairs, e.g. 'dana:3f9c...,ops:a71b...'.
+                            POST routes are refused while it is unset.
+
+    Returns:
+        dict: { token: name }
+    """
+    pairs = [pair.split(':', 1) for pair in os.getenv('ADMIN_TOKENS', '').split(',') if ':' in pair]
+    return {token.strip(): name.strip() for name, token in pairs if name.strip() and token.strip()}
+
+
+def authenticate(authorization: Optional[str]) -> Optional[str]:
+    """
+    Resolve an Authorization header to the caller's name.
+
+    Args:
+        authorization (Optional[str]): The header value, 'Bearer <token>'.
+
+    Returns:
+        Optional[str]: The name the token was issued to, None if it is missing or unknown.
+    """
+    scheme, _, token = (authorization or '').partition(' ')
+    if scheme.lower() != 'bearer' or not token:
+        return None
+    for known, name in tokens().items():
+        if hmac.compare_digest(known.encode(), token.strip().encode()):
+            return name
+    return None
+
+
+def register_route(path: str, handler: Callable[..., dict], method: str = 'GET') -> None:
+    """
+    Register an admin API endpoint.
+
+    Args:
+        path (str): The URL path (e.g., "/stats/symbols").
+        handler (Callable): Called with `query` and `body` keyword arguments,
+                            both dictionaries, and returns a JSON serializable dict.
+                            POST handlers also get `identity`, the authenticated
+                            caller's name, see authenticate(). Raising ValueError
+                            produces a 400 response.
+        method (str, optional): The HTTP method. Defaults to 'GET'.
+    """
+    routes[(method, path)] = handler
+
+
+class AdminRequestHandler(BaseHTTPRequestHandler):
+    """Dispatches admin API requests to the registered route handlers."""
+
+    def do_GET(self):
+        self._dispatch('GET')
+
+    def do_POST(self):
+        self._dispatch('POST')
+
+    def _dispatch(self, method: str) -> None:
+        url = urlparse(self.path)
+        handler = routes.get((method, url.path))
+        if handler is None:
+            self._respond(404, {'error': f'No route for {method} {url.path}'})
+            return
+        # Routes that change state are only served to callers holding an ADMIN_TOKENS token
+        identity = None
+        if method == 'POST':
+            identity = authenticate(self.headers.get('Authorization'))
+            if identity is None:
+                metrics.increment('admin_unauthorized', path=url.path)
+                self._respond(401, {'error': 'A valid bearer token is required.'})
+                return
+        try:
+            query = {key: values[-1] for key, values in parse_qs(url.query).items()}
+            if method == 'POST':
+                length = int(self.headers.get('Content-Length', 0))
+                body = json.loads(self.rfile.read(length) or b'{}')
+                logger.info(f'{identity} called {method} {url.path}')
+                self._respond(200, handler(query=query, body=body, identity=identity))
+            else:
+                self._respond(200, handler(query=query, body={}))
+        except ValueError as e:
+            self._respond(400, {'error': str(e)})
+        except Exception as e:
+            logger.error(f'Error handling admin request {method} {url.path}: {e}')
+            self._respond(500, {'error': str(e)})
+
+    def _respond(self, status: int, payload: dict) -> None:
+        data = json.dumps(payload, default=str).encode()
+        self.send_response(status)
+        self.send_header('Content-Type', 'application/json')
+        self.send_header('Content-Length', str(len(data)))
+        self.end_headers()
+        self.wfile.write(data)
+
+    def log_message(self, format, *args):
+        logger.debug(format % args)
+
+
+def start_admin_server(port: Optional[int] = None, host: Optional[str] = None) -> ThreadingHTTPServer:
+    """
+    Start the admin API on a background daemon thread.
+
+    Args:
+        port (Optional[int], optional): The port to listen on.
+                                        Defaults to ADMIN_PORT or 8080.
+        host (Optional[str], optional): The interface to listen on. Defaults to
+                                        ADMIN_HOST or every interface, so load balancer
+                                        health checks reach /health. POST routes are
+                                        guarded by ADMIN_TOKENS wherever it listens.
+
+    Returns:
+        ThreadingHTTPServer: The running server, call shutdown() to stop it.
+    """
+    port = port if port is not None else int(os.getenv('ADMIN_PORT', 8080))
+    host = host if host is not None else os.getenv('ADMIN_HOST', '0.0.0.0')
+    server = ThreadingHTTPServer((host, port), AdminRequestHandler)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    logger.info(f'Admin API listening on {host}:{server.server_port}')
+    return server
+
+
+# Built-in endpoints available in every service
+register_route('/health', lambda query, body: {'status': 'ok'})
+register_route('/version', lambda query, body: version.get_build_info())
+register_route('/metrics', lambda query, body: metrics.snapshot())
+register_route('/stats/symbols', lambda query, body: metrics.symbol_stats(query.get('symbol')))
@@ -0,0 +1,185 @@
+import re
+from datetime import datetime, timedelta, timezone
+from threading import Lock
+from helpers import metrics
+from typing import Optional
+
+# Bar kinds: time bars close on the clock, volume and dollar bars once their size has traded
+BAR_KINDS = ('time', 'volume', 'dollar')
+
+# Seconds per time bar unit
+TIME_UNITS = {
+    's': 1,
+    'm': 60,
+}
+
+# Timeframe label per time bar unit, in the style of the broker's '1Min'
+TIME_LABELS = {
+    's': 'Sec',
+    'm': 'Min',
+}
+
+
+def parse_spec(spec: str) -> dict:
+    """
+    Parse a bar specification.
+
+    Args:
+        spec (str): A time bar as a count and unit, e.g. '5s', '15s', or '2m',
+        or a threshold bar as 'volume:<shares>' or 'dollar:<notional>'.
+
+    Returns:
+        dict: { 'kind', 'size', 'timeframe' }, size in seconds, shares, or dollars,
+        timeframe the label published with the bars, e.g. '5Sec' or 'Dollar1000000'.
+
+    Raises:
+        ValueError: If the specification is malformed.
+    """
+    spec = spec.strip().lower()
+    time_bar = re.fullmatch(r'(\d+)([sm])', spec)
+    if time_bar:
+        count, unit = int(time_bar.group(1)), time_bar.group(2)
+        seconds = count * TIME_UNITS[unit]
+        # Bars that divide a minute, or are whole minutes, line up with the streamed 1-minute bars
+        if seconds <= 0 or (60 % seconds and seconds % 60):
+            raise ValueError(f'Time bars must divide a minute or be whole minutes, got {spec}.')
+        return {'kind': 'time', 'size': seconds, 'timeframe': f'{count}{TIME_LABELS[unit]}'}
+    kind, _, size = spec.partition(':')
+    if kind not in ('volume', 'dollar') or not re.fullmatch(r'\d+(\.\d+)?', size) or float(size) <= 0:
+        raise ValueError(f"Malformed bar specification {spec}, expected e.g. '5s', 'volume:50000', or 'dollar:1000000'.")
+    return {'kind': kind, 'size': float(size), 'timeframe': f'{kind.capitalize()}{size}'}
+
+
+def parse_specs(text: str) -> list[dict]:
+    """Parses comma separated bar specifications, see parse_spec()."""
+    return [parse_spec(spec) for spec in text.split(',') if spec.strip()]
+
+
+def _start(timestamp: datetime, seconds: int) -> datetime:
+    # Time bars are aligned to the epoch, so a 15 second bar starts on :00, :15, :30, and :45
+    epoch = timestamp.astimezone(timezone.utc).timestamp()
+    return datetime.fromtimestamp(epoch - epoch % seconds, timezone.utc)
+
+
+class TradeBarAggregator:
+    """Builds bars the stream does not offer from individual trades.
+
+    Each symbol gets one bar per specification. A time bar covers an
+    aligned interval and is closed by the first trade after it, or by
+    close_due() once the interval and a grace period have passed so quiet
+    symbols are not held back. Trades older than a symbol's open time bar
+    arrive too late to include and are counted in 'late_trades'. Volume and
+    dollar bars close on the trade that takes them to their size, trades
+    are not split across bars. Intervals without trades produce no bar.
+
+    Completed bars are in the data topic's bar format with vwap, the
+    specification's 'timeframe', and 'synthetic': True added.
+
+    Attributes:
+        specs: Parsed bar specifications
+        grace: Time after a time bar's end that late trades are waited for
+        bars: { (symbol, timeframe): the bar being built }
+        lock: Thread lock around the bars
+    """
+
+    def __init__(self, specs: list[dict], grace_seconds: float = 1.0):
+        """Initializes the aggregator with no bars open.
+
+        Args:
+            specs: Bar specifications from parse_spec()
+            grace_seconds: Time after a time bar's end that late trades are waited for
+        """
+        self.specs = specs
+        self.grace = timedelta(seconds=grace_seconds)
+        self.bars = {}
+        self.lock = Lock()
+
+    def on_trade(self, trade: dict) -> list[dict]:
+        """Adds a trade to the symbol's bars.
+
+        Args:
+            trade: A trade in the format of Trade.to_dict()
+
+        Returns:
+            list[dict]: The bars the trade completed
+        """
+        timestamp = datetime.fromisoformat(trade['timestamp'])
+        completed = []
+        with self.lock:
+            for spec in self.specs:
+                key = (trade['symbol'], spec['timeframe'])
+                bar = self.bars.get(key)
+                if spec['kind'] == 'time':
+                    start = _start(timestamp, spec['size'])
+                    if bar is not None and start < bar['start']:
+                        metrics.increment('late_trades', symbol=trade['symbol'], timeframe=spec['timeframe'])
+                        continue
+                    if bar is not None and start > bar['start']:
+                        completed.append(_finish(self.bars.pop(key)))
+                        bar = None
+                    if bar is None:
+                        bar = self.bars[key] = _open(trade, spec, start)
+                    _add(bar, trade)
+                else:
+                    if bar is None:
+                        bar = self.bars[key] = _open(trade, spec, timestamp)
+                    _add(bar, trade)
+                    traded = bar['volume'] if spec['kind'] == 'volume' else bar['notional']
+                    if traded >= spec['size']:
+                        completed.append(_finish(self.bars.pop(key)))
+        return completed
+
+    def close_due(self, now: Optional[datetime] = None) -> list[dict]:
+        """Closes the time bars whose interval and grace period have passed.
+
+        Args:
+            now: Time to close bars up to, defaults to the current time
+
+        Returns:
+            list[dict]: The bars closed
+        """
+        now = datetime.now(timezone.utc) if now is None else now
+        with self.lock:
+            due = [key for key, bar in self.bars.items() if bar['end'] is not None and bar['end'] + self.grace <= now]
+            return [_finish(self.bars.pop(key)) for key in due]
+
+
+def _open(trade: dict, spec: dict, start: datetime) -> dict:
+    return {
+        'symbol': trade['symbol'],
+        'start': start,
+        'end': start + timedelta(seconds=spec['size']) if spec['kind'] == 'time' else None,
+        'timeframe': spec['timeframe'],
+        'open': trade['price'],
+        'high': trade['price'],
+        'low': trade['price'],
+        'close': trade['price'],
+        'volume': 0,
+        'notional': 0.0,
+        'trade_count': 0,
+    }
+
+
+def _add(bar: dict, trade: dict) -> None:
+    bar['high'] = max(bar['high'], trade['price'])
+    bar['low'] = min(bar['low'], trade['price'])
+    bar['close'] = trade['price']
+    bar['volume'] += trade['size']
+    bar['notional'] += trade['price'] * trade['size']
+    bar['trade_count'] += 1
+
+
+def _finish(bar: dict) -> dict:
+    return {
+        'symbol': bar['symbol'],
+        'timestamp': bar['start'].isoformat(),
+        'open': bar['open'],
+        'high': bar['high'],
+        'low': bar['low'],
+        'close': bar['close'],
+        'volume': bar['volume'],
+        'trade_count': bar['trade_count'],
+        'vwap': bar['notional'] / bar['volume'] if bar['volume'] else bar['close'],
+        'timeframe': bar['timeframe'],
+        'synthetic': True,
+    }
@@ -0,0 +1,176 @@
+import io
+import gzip
+import json
+import time
+import uuid
+from datetime import datetime, timezone
+from threading import Lock
+from helpers import cloud, logger, metrics, sinks
+from typing import Callable
+
+# Initialize logger
+logger = logger.Logger('archive.py')
+
+# Object formats the sink can write, with their extension and content type
+FORMATS = {
+    'jsonl': ('jsonl.gz', 'application/gzip'),
+    'parquet': ('parquet', 'application/vnd.apache.parquet'),
+}
+
+
+def partition_key(prefix: str, record_type: str, symbol: str, day: str) -> str:
+    """
+    The S3 prefix of a partition, laid out for Athena style partition discovery.
+
+    Args:
+        prefix (str): The archive root as s3://bucket/prefix.
+        record_type (str): One of sinks.RECORD_TYPES.
+        symbol (str): The symbol, '/' in crypto pairs is replaced with '-'.
+        day (str): The record's UTC date as YYYY-MM-DD.
+
+    Returns:
+        str: e.g. s3://bucket/prefix/type=bars/date=2024-01-02/symbol=AAPL
+    """
+    return f"{prefix.rstrip('/')}/type={record_type}/date={day}/symbol={symbol.replace('/', '-')}"
+
+
+def encode(records: list[dict], fmt: str) -> bytes:
+    """
+    Encode records as gzipped JSON lines or Parquet.
+
+    Args:
+        records (list[dict]): The records in order.
+        fmt (str): A key of FORMATS.
+
+    Returns:
+        bytes: The object body.
+
+    Raises:
+        ValueError: If the format is unknown.
+    """
+    if fmt not in FORMATS:
+        raise ValueError(f'Unknown archive format {fmt}, expected one of {", ".join(FORMATS)}.')
+    if fmt == 'jsonl':
+        return gzip.compress(''.join(json.dumps(record, default=str) + '\n' for record in records).encode())
+    import pandas
+    buffer = io.BytesIO()
+    pandas.DataFrame(records).to_parquet(buffer, index=False)
+    return buffer.getvalue()
+
+
+def decode(body: bytes, fmt: str) -> list[dict]:
+    """
+    Decode an object written by encode() back into its records.
+
+    Args:
+        body (bytes): The object body.
+        fmt (str): A key of FORMATS.
+
+    Returns:
+        list[dict]: The records in order, missing Parquet values as None.
+
+    Raises:
+        ValueError: If the format is unknown.
+    """
+    if fmt not in FORMATS:
+        raise ValueError(f'Unknown archive format {fmt}, expected one of {", ".join(FORMATS)}.')
+    if fmt == 'jsonl':
+        return [json.loads(line) for line in gzip.decompress(body).decode().splitlines() if line]
+    import pandas
+    frame = pandas.read_parquet(io.BytesIO(body))
+    return frame.astype(object).where(frame.notna(), None).to_dict('records')
+
+
+def object_format(uri: str) -> str:
+    """
+    The format of an archived object from its extension.
+
+    Raises:
+        ValueError: If the extension is not one of FORMATS.
+    """
+    for fmt, (extension, _) in FORMATS.items():
+        if uri.endswith(f'.{extension}'):
+            return fmt
+    raise ValueError(f'Unknown archive object {uri}, expected one of {", ".join(extension for extension, _ in FORMATS.values())}.')
+
+
+class ArchiveSink(sinks.MarketDataSink):
+    """Buffers streamed records and writes them to S3 partitioned by type, date, and symbol.
+
+    A partition's buffer is written as one object once it holds max_records
+    records or its oldest record is flush_seconds old, so each object holds
+    a slice of a single symbol's day. Partitions are checked as records
+    arrive, call flush() on shutdown to write what is left.
+
+    Attributes:
+        prefix: The archive root as s3://bucket/prefix
+        fmt: Object format, a key of FORMATS
+        max_records: Records per object
+        flush_seconds: Longest a record is buffered
+        buffers: { (type, date, symbol): (first buffered time, records) }
+        lock: Thread lock around the buffers
+        write: Object write function, replaceable in tests
+    """
+
+    def __init__(
+        self,
+        prefix: str,
+        fmt: str = 'jsonl',
+        max_records: int = 5_000,
+        flush_seconds: float = 15 * 60,
+        write: Callable[[str, bytes, str], dict] = cloud.write_s3_object
+    ):
+        """Initializes the sink with empty buffers.
+
+        Args:
+            prefix: The archive root as s3://bucket/prefix
+            fmt: Object format, a key of FORMATS
+            max_records: Records per object
+            flush_seconds: Longest a record is buffered
+            write: Write function with cloud.write_s3_object's signature
+        """
+        if fmt not in FORMATS:
+            raise ValueError(f'Unknown archive format {fmt}, expected one of {", ".join(FORMATS)}.')
+        self.prefix = prefix
+        self.fmt = fmt
+        self.max_records = max_records
+        self.flush_seconds = flush_seconds
+        self.buffers = {}
+        self.lock = Lock()
+        self.write = write
+
+    def record(self, record_type: str, message: dict) -> None:
+        """Buffers a streamed message, writing the partitions that are due."""
+        sinks.check_record_type(record_type)
+        day = datetime.fromisoformat(message['timestamp']).astimezone(timezone.utc).date().isoformat()
+        key = (record_type, day, message['symbol'])
+        now = time.monotonic()
+        with self.lock:
+            self.buffers.setdefault(key, (now, []))[1].append(message)
+            due = [
+                partition for partition, (started, records) in self.buffers.items()
+                if len(records) >= self.max_records or now - started >= self.flush_seconds
+            ]
+            pending = [(partition, self.buffers.pop(partition)[1]) for partition in due]
+        for partition, records in pending:
+            self._write(partition, records)
+
+    def flush(self) -> None:
+        """Writes every buffered partition."""
+        with self.lock:
+            pending, self.buffers = [(partition, records) for partition, (_, records) in self.buffers.items()], {}
+        for partition, records in pending:
+            self._write(partition, records)
+
+    def _write(self, partition: tuple, records: list[dict]) -> None:
+        """Writes one partition's records as an object, counting the records that could not be written."""
+        record_type, day, symbol = partition
+        extension, content_type = FORMATS[self.fmt]
+        stamp = datetime.now(timezone.utc).strftime('%Y%m%dT%H%M%S')
+        uri = f'{partition_key(self.prefix, record_type, symbol, day)}/{stamp}-{uuid.uuid4().hex[:8]}.{extension}'
+        try:
+            self.write(uri, encode(records, self.fmt), content_type)
+            metrics.increment('archived_records', len(records), type=record_type)
+        except Exception as e:
+            metrics.increment('archive_failures', len(records), type=record_type)
+            logger.error(f'Error archiving {len(records)} {record_type} for {symbol} to {uri}: {e}')
@@ -0,0 +1,85 @@
+from helpers import bar_cache, broker, logger, tenant
+from helpers.domain import converters
+from alpaca.data.live import CryptoDataStream, OptionDataStream
+from typing import Awaitable, Callable
+
+# Initialize logger
+logger = logger.Logger('asset_streams.py')
+
+# Handler receiving message dictionaries from a stream
+MessageHandler = Callable[[dict], Awaitable[None]]
+
+# Asset classes streamed beside equities, crypto trades around the clock
+ASSET_CLASSES = ('crypto', 'options')
+
+# Running streams { asset class: stream }
+streams = {}
+
+
+def _credentials() -> tuple:
+    api_key = tenant.getenv('BROKER_API_KEY')
+    api_secret = tenant.getenv('BROKER_SECRET_KEY')
+    if not api_key or not api_secret:
+        raise ValueError('API key and secret must be set in environment variables.')
+    return api_key, api_secret
+
+
+def stream_crypto(handler: MessageHandler, symbols: list[str]) -> None:
+    """
+    Stream crypto bars to an async handler, blocking until stop_stream('crypto') is called.
+
+    Args:
+        handler (MessageHandler): Receives each bar in the format of bar_cache.bar_to_dict().
+        symbols (list[str]): The pairs to subscribe to, e.g. 'BTC/USD'.
+
+    Raises:
+        ValueError: If the broker credentials are not set.
+    """
+    async def on_bar(bar):
+        await handler(bar_cache.bar_to_dict(bar))
+
+    stream = CryptoDataStream(*_credentials(), url_override=broker.stream_url('v1beta3/crypto/us'))
+    streams['crypto'] = stream
+    stream.subscribe_bars(on_bar, *symbols)
+    stream.run()
+
+
+def stream_options(handler: MessageHandler, symbols: list[str]) -> None:
+    """
+    Stream option quotes and trades to an async handler, blocking until
+    stop_stream('options') is called. Alpaca does not stream option bars.
+
+    Args:
+        handler (MessageHandler): Receives each quote or trade as a dictionary
+        with 'type' set to 'quote' or 'trade'.
+        symbols (list[str]): The OCC contract symbols to subscribe to.
+
+    Raises:
+        ValueError: If the broker credentials are not set.
+    """
+    async def on_quote(quote):
+        await handler({'type': 'quote', **converters.quote_from_alpaca(quote).to_dict()})
+
+    async def on_trade(trade):
+        await handler({'type': 'trade', **converters.trade_from_alpaca(trade).to_dict()})
+
+    stream = OptionDataStream(*_credentials(), url_override=broker.stream_url('v1beta1/indicative'))
+    streams['options'] = stream
+    stream.subscribe_quotes(on_quote, *symbols)
+    stream.subscribe_trades(on_trade, *symbols)
+    stream.run()
+
+
+# Stream function per asset class
+STREAMS = {
+    'crypto': stream_crypto,
+    'options': stream_options,
+}
+
+
+def stop_stream(asset_class: str) -> None:
+    """
+    Stops a running stream for the asset class.
+    """
+    if streams.get(asset_class) is not None:
+        streams[asset_class].stop()
@@ -0,0 +1,242 @@
+import os
+import json
+import time
+import itertools
+from concurrent.futures import ProcessPoolExecutor, as_completed
+from helpers import cloud, logger, performance, statistics
+from typing import Callable, Optional
+
+# Initialize logger
+logger = logger.Logger('backtest.py')
+
+
+def backtest_bollinger_reversion(
+    closes: list[float],
+    window: int = 20,
+    num_std: float = 2,
+    qty: float = 1,
+    capital: Optional[float] = None,
+    periods_per_year: int = performance.MINUTES_PER_YEAR
+) -> dict:
+    """
+    Backtest the Reversion service's Bollinger Band rule on a close series.
+    Mirrors the live rule: sell when the close touches the upper band and buy
+    when it touches the lower band, never adding to a position in the same
+    direction (the risk manager rejects those), all fills at the close.
+
+    Args:
+        closes (list[float]): Close prices ordered oldest to newest.
+        window (int, optional): The Bollinger Band window. Defaults to 20.
+        num_std (float, optional): The band width in standard deviations. Defaults to 2.
+        qty (float, optional): Shares per trade. Defaults to 1.
+        capital (Optional[float], optional): Starting capital the performance report is
+                                             computed on, None to skip the report.
+        periods_per_year (int, optional): Bars in a year, for annualizing the report.
+                                          Defaults to one minute bars.
+
+    Returns:
+        dict: A dictionary containing:
+        - 'pnl': Total profit and loss, open positions marked at the last close.
+        - 'trades': Trades as { 'index', 'qty', 'price' }.
+        - 'equity_curve': PnL marked to market at every bar.
+        - 'performance': performance.report() of capital plus the equity curve,
+                         with round trip P&Ls and exposure, only when capital is given.
+    """
+    bands = statistics.bollinger_bands(closes, window, num_std)
+    position = 0.0
+    cash = 0.0
+    trades = []
+    equity_curve = []
+    round_trip_pnls = []
+    positions = []
+    entry_price = 0.0
+    for i, close in enumerate(closes):
+        if i >= window - 1:
+            trade_qty = 0.0
+            if close >= bands['upper_band'][i] and position >= 0:
+                trade_qty = -qty
+            elif close <= bands['lower_band'][i] and position <= 0:
+                trade_qty = qty
+            if trade_qty:
+                # Positions are only ever one trade in size, a trade against one closes it
+                if position:
+                    round_trip_pnls.append(position * (close - entry_price))
+                entry_price = close
+                position += trade_qty
+                cash -= trade_qty * close
+                trades.append({'index': i, 'qty': trade_qty, 'price': close})
+        equity_curve.append(cash + position * close)
+        positions.append(position)
+    result = {
+        'pnl': equity_curve[-1] if equity_curve else 0.0,
+        'trades': trades,
+        'equity_curve': equity_curve,
+    }
+    if capital:
+        result['performance'] = performance.report(
+            [capital + pnl for pnl in equity_curve], round_trip_pnls, positions, periods_per_year
+        )
+    return result
+
+
+# Backtests that can be run by name from SQS work items
+BACKTESTS = {
+    'bollinger_reversion': backtest_bollinger_reversion,
+}
+
+
+def parameter_grid(**param_values: list) -> list[dict]:
+    """
+    Expand parameter value lists into every combination.
+
+    Example:
+        parameter_grid(window=[10, 20], num_std=[1.5, 2])
+        [{'window': 10, 'num_std': 1.5}, {'window': 10, 'num_std': 2}, ...]
+
+    Returns:
+        list[dict]: One dictionary of keyword arguments per combination.
+    """
+    names = list(param_values)
+    return [dict(zip(names, values)) for values in itertools.product(*param_values.values())]
+
+
+def _run_one(backtest_fn: Callable, params: dict, fixed_kwargs: dict) -> dict:
+    """
+    Run a single combination, capturing failures instead of raising.
+    """
+    try:
+        return {'params': params, 'result': backtest_fn(**fixed_kwargs, **params)}
+    except Exception as e:
+        return {'params': params, 'error': str(e)}
+
+
+def run_parameter_grid(
+    backtest_fn: Callable,
+    grid: list[dict],
+    max_workers: Optional[int] = None,
+    **fixed_kwargs
+) -> list[dict]:
+    """
+    Run a backtest for every parameter combination in parallel across cores.
+
+    Args:
+        backtest_fn (Callable): A module level backtest function (it is pickled
+                                to worker processes) returning a dict.
+        grid (list[dict]): Parameter combinations, e.g. from parameter_grid().
+        max_workers (Optional[int], optional): Worker processes. Defaults to the CPU count.
+        **fixed_kwargs: Arguments shared by every run (e.g., closes=...).
+
+    Returns:
+        list[dict]: { 'params', 'result' } or { 'params', 'error' } per combination,
+        in grid order.
+    """
+    results = [None] * len(grid)
+    with ProcessPoolExecutor(max_workers=max_workers) as executor:
+        futures = {
+            executor.submit(_run_one, backtest_fn, params, fixed_kwargs): index
+            for index, params in enumerate(grid)
+        }
+        for completed, future in enumerate(as_completed(futures), start=1):
+            results[futures[future]] = future.result()
+            if completed % 100 == 0:
+                logger.info(f'Completed {completed} of {len(grid)} backtests')
+    return results
+
+
+def enqueue_parameter_grid(
+    queue_url: str,
+    job_id: str,
+    backtest_name: str,
+    grid: list[dict],
+    **fixed_kwargs
+) -> int:
+    """
+    Fan a parameter grid out as SQS work items for backtest worker tasks.
+
+    Args:
+        queue_url (str): The work queue consumed by the Backtest service.
+        job_id (str): Identifier grouping the work items of this grid.
+        backtest_name (str): A key of BACKTESTS.
+        grid (list[dict]): Parameter combinations.
+        **fixed_kwargs: JSON serializable arguments shared by every run.
+
+    Returns:
+        int: The number of work items sent.
+    """
+    if backtest_name not in BACKTESTS:
+        raise ValueError(f'Unknown backtest {backtest_name}.')
+    for index, params in enumerate(grid):
+        cloud.send_sqs_message(json.dumps({
+            'job_id': job_id,
+            'index': index,
+            'backtest': backtest_name,
+            'params': params,
+            'fixed': fixed_kwargs,
+        }), queue_url)
+    logger.info(f'Enqueued {len(grid)} backtests for job {job_id}')
+    return len(grid)
+
+
+def process_work_item(body: str) -> dict:
+    """
+    Run one SQS work item and return its result message.
+    """
+    item = json.loads(body)
+    outcome = _run_one(BACKTESTS[item['backtest']], item['params'], item['fixed'])
+    return {'job_id': item['job_id'], 'index': item['index'], **outcome}
+
+
+def collect_grid_results(
+    results_queue_url: str,
+    job_id: str,
+    expected: int,
+    timeout_seconds: float = 3600
+) -> list[dict]:
+    """
+    Aggregate worker results for a job from the results queue.
+
+    Args:
+        results_queue_url (str): The queue workers send results to.
+        job_id (str): The job to collect, other jobs' results are left on the queue.
+        expected (int): The number of results to wait for.
+        timeout_seconds (float, optional): Give up after this long. Defaults to one hour.
+
+    Returns:
+        list[dict]: Results in grid order, None for combinations that never reported.
+    """
+    results = [None] * expected
+    received = 0
+    deadline = time.monotonic() + timeout_seconds
+    while received < expected and time.monotonic() < deadline:
+        for message in cloud.poll_sqs_message(results_queue_url, max_messages=10):
+            result = json.loads(message['Body'])
+            if result['job_id'] != job_id:
+                continue
+            if results[result['index']] is None:
+                received += 1
+            results[result['index']] = result
+            cloud.delete_sqs_message(results_queue_url, message['ReceiptHandle'])
+    if received < expected:
+        logger.warning(f'Collected {received} of {expected} results for job {job_id} before timeout')
+    return results
+
+
+def run_worker() -> None:
+    """
+    Consume backtest work items forever, sending each result to the results queue.
+
+    Environment Variables:
+        BACKTEST_SQS_URL (str): The work queue.
+        BACKTEST_RESULTS_SQS_URL (str): The queue results are sent to.
+    """
+    queue_url = os.getenv('BACKTEST_SQS_URL')
+    results_queue_url = os.getenv('BACKTEST_RESULTS_SQS_URL')
+    while True:
+        try:
+            for message in cloud.poll_sqs_message(queue_url, max_messages=1, wait_time_seconds=20):
+                result = process_work_item(message['Body'])
+                cloud.send_sqs_message(json.dumps(result, default=str), results_queue_url)
+                cloud.delete_sqs_message(queue_url, message['ReceiptHandle'])
+        except Exception as e:
+            logger.error(f'Error processing backtest work item: {e}')
+            time.sleep(5)
@@ -0,0 +1,140 @@
+from collections import OrderedDict, deque
+from threading import Lock
+from alpaca.data.models import Bar
+from helpers import metrics
+from helpers.domain import converters
+from typing import Optional
+
+
+def bar_to_dict(bar: Bar) -> dict:
+    """
+    Convert an Alpaca bar into the plain dictionary published on the data topic.
+
+    Args:
+        bar (Bar): The bar from the stream or the historical API.
+
+    Returns:
+        dict: The bar's symbol, ISO timestamp, OHLC prices, volume, and trade count.
+    """
+    return converters.bar_from_alpaca(bar).to_dict()
+
+
+class BarCache:
+    """Recently built bars keyed by symbol and timeframe.
+
+    Shared by every strategy in a process so each one does not keep its own
+    copy of the same bars or re-fetch history it could read from memory.
+    Each series keeps at most max_bars bars, and at most max_series series are
+    tracked, evicting the least recently updated.
+
+    Attributes:
+        max_bars: Bars retained per symbol and timeframe
+        max_series: Symbol/timeframe series retained
+    """
+
+    def __init__(self, max_bars: int = 500, max_series: int = 1000):
+        """Initializes an empty cache.
+
+        Args:
+            max_bars: Bars retained per symbol and timeframe
+            max_series: Symbol/timeframe series retained
+        """
+        self.max_bars = max_bars
+        self.max_series = max_series
+        self._series = OrderedDict()  # { (symbol, timeframe): deque of bar dicts }
+        self._lock = Lock()
+
+    def add(self, bar: dict, timeframe: str = '1Min') -> None:
+        """Adds a bar, replacing a bar with the same timestamp and ignoring older ones.
+
+        Args:
+            bar: Bar dictionary with at least 'symbol', 'timestamp', and 'close'
+            timeframe: Timeframe of the bar (e.g., '1Min', '5Min')
+        """
+        key = (bar['symbol'], timeframe)
+        with self._lock:
+            series = self._series.get(key)
+            if series is None:
+                series = deque(maxlen=self.max_bars)
+                self._series[key] = series
+            if series and bar['timestamp'] < series[-1]['timestamp']:
+                metrics.increment('bar_cache_out_of_order', symbol=bar['symbol'])
+                return
+            if series and bar['timestamp'] == series[-1]['timestamp']:
+                series[-1] = bar
+            else:
+                series.append(bar)
+            self._series.move_to_end(key)
+            while len(self._series) > self.max_series:
+                self._series.popitem(last=False)
+
+    def extend(self, bars: list[dict], timeframe: str = '1Min') -> None:
+        """Adds bars in timestamp order, e.g. to seed the cache from history."""
+        for bar in sorted(bars, key=lambda bar: bar['timestamp']):
+            self.add(bar, timeframe)
+
+    def merge(self, bars: list[dict], timeframe: str = '1Min') -> None:
+        """Merges bars of any age into their series by timestamp.
+
+        Unlike add(), bars older than the newest cached bar are inserted rather
+        than ignored, so history fetched after live bars arrived fills in behind
+        them. Incoming bars replace cached bars with the same timestamp.
+
+        Args:
+            bars: Bar dictionaries of one or more symbols
+            timeframe: Timeframe of the bars
+        """
+        by_symbol = {}
+        for bar in bars:
+            by_symbol.setdefault(bar['symbol'], []).append(bar)
+        with self._lock:
+            for symbol, symbol_bars in by_symbol.items():
+                key = (symbol, timeframe)
+                merged = {bar['timestamp']: bar for bar in self._series.get(key, ())}
+                merged.update((bar['timestamp'], bar) for bar in symbol_bars)
+                self._series[key] = deque(
+                    (merged[timestamp] for timestamp in sorted(merged)),
+                    maxlen=self.max_bars
+                )
+                self._series.move_to_end(key)
+            while len(self._series) > self.max_series:
+                self._series.popitem(last=False)
+
+    def get(self, symbol: str, timeframe: str = '1Min', limit: Optional[int] = None) -> list[dict]:
+        """Returns cached bars ordered oldest to newest.
+
+        Args:
+            symbol: Trading symbol
+            timeframe: Timeframe of the bars
+            limit: Return only the most recent limit bars
+
+        Returns:
+            list[dict]: The cached bars, empty if none are cached
+        """
+        with self._lock:
+            bars = list(self._series.get((symbol, timeframe), ()))
+        return bars[-limit:] if limit else bars
+
+    def closes(self, symbol: str, timeframe: str = '1Min', limit: Optional[int] = None) -> list[float]:
+        """Returns cached close prices ordered oldest to newest."""
+        return [bar['close'] for bar in self.get(symbol, timeframe, limit)]
+
+    def count(self, symbol: str, timeframe: str = '1Min') -> int:
+        """Returns the number of bars cached for a symbol and timeframe."""
+        with self._lock:
+            return len(self._series.get((symbol, timeframe), ()))
+
+
+# Cache shared by every strategy running in this process
+shared_cache = None
+
+
+def get_bar_cache() -> BarCache:
+    """
+    Returns the process-wide bar cache.
+    Initializes the cache if it hasn't been initialized yet.
+    """
+    global shared_cache
+    if shared_cache is None:
+        shared_cache = BarCache()
+    return shared_cache
@@ -1,17 +1,33 @@
 import os
-from helpers import logger
+import time
+import hashlib
+import requests
+from helpers import logger, chaos, market_clock, observer, order_errors, risk, tenant
+from helpers.domain import Bar as DomainBar, Order, Quote, Trade, Snapshot, converters
+from alpaca.common.exceptions import APIError
 from alpaca.trading.client import TradingClient
-from alpaca.trading.requests import MarketOrderRequest, LimitOrderRequest
-from alpaca.trading.enums import OrderSide, TimeInForce
-from alpaca.data import StockHistoricalDataClient
+from alpaca.trading.requests import (
+                                     MarketOrderRequest,
+                                     LimitOrderRequest,
+                                     TakeProfitRequest,
+                                     StopLossRequest,
+                                     ReplaceOrderRequest,
+                                     GetOrdersRequest
+                                     )
+from alpaca.trading.enums import OrderSide, TimeInForce, OrderClass, QueryOrderStatus
+from alpaca.data import StockHistoricalDataClient, OptionHistoricalDataClient, NewsClient
 from alpaca.data.models import Bar
 from alpaca.data.requests import (
                                   StockBarsRequest,
                                   StockQuotesRequest,
-                                  StockTradesRequest
+                                  StockTradesRequest,
+                                  StockLatestTradeRequest,
+                                  StockLatestQuoteRequest,
+                                  StockSnapshotRequest
                                   )
-from alpaca.data.timeframe import TimeFrame
-from datetime import datetime
+from alpaca.data.enums import Adjustment, DataFeed
+from alpaca.data.timeframe import TimeFrame, TimeFrameUnit
+from datetime import datetime, timedelta
 from typing import Optional, List
 
 # Initialize logger
@@ -20,6 +36,58 @@ logger = logger.Logger('broker.py')
 # Initialize a placeholder for Alpaca clients
 alpaca_clients = None
 
+# Market data feeds selectable with DATA_FEED
+DATA_FEEDS = {
+    'iex': DataFeed.IEX,                    # Free, IEX prints only
+    'sip': DataFeed.SIP,                    # Paid, every US exchange
+    'delayed_sip': DataFeed.DELAYED_SIP,    # Free, every US exchange 15 minutes late
+}
+
+# Market data endpoints used when DATA_SANDBOX is set
+SANDBOX_DATA_URL = 'https://data.sandbox.alpaca.markets'
+SANDBOX_STREAM_URL = 'wss://stream.data.sandbox.alpaca.markets'
+
+# Cached asset flags { symbol: (fetched_at, flags) }
+asset_cache = {}
+ASSET_CACHE_TTL_SECONDS = 60 * 60
+
+
+def data_feed() -> Optional[DataFeed]:
+    """
+    Returns the configured market data feed, None to let Alpaca choose from the subscription.
+
+    Environment Variables:
+        DATA_FEED (str): Optional key of DATA_FEEDS, e.g. 'sip' in production and 'iex' in development.
+
+    Raises:
+        ValueError: If DATA_FEED is not a key of DATA_FEEDS.
+    """
+    name = tenant.getenv('DATA_FEED')
+    if not name:
+        return None
+    if name.lower() not in DATA_FEEDS:
+        raise ValueError(f'Unknown data feed {name}, expected one of {", ".join(DATA_FEEDS)}.')
+    return DATA_FEEDS[name.lower()]
+
+
+def data_sandbox() -> bool:
+    """
+    Whether market data comes from the sandbox endpoints, set with DATA_SANDBOX=True.
+    """
+    return tenant.getenv('DATA_SANDBOX') == 'True'
+
+
+def stream_url(path: str) -> Optional[str]:
+    """
+    Returns a stream URL override for the sandbox, None for the default endpoint.
+
+    Args:
+        path (str): The stream's path, e.g. 'v2/iex' or 'v1beta1/news'.
+    """
+    if not data_sandbox():
+        return None
+    return f'{SANDBOX_STREAM_URL}/{path}'
+
 
 def get_alpaca_clients():
     """
@@ -27,17 +95,31 @@ def get_alpaca_clients():
     Ensures environment variables are loaded before creating clients.
     """
     trading_client = TradingClient(
-        os.getenv('BROKER_API_KEY'),
-        os.getenv('BROKER_SECRET_KEY'),
+        tenant.getenv('BROKER_API_KEY'),
+        tenant.getenv('BROKER_SECRET_KEY'),
         paper=False if os.environ.get('ENV') == 'production' else True
     )
     stock_client = StockHistoricalDataClient(
-        os.getenv('BROKER_API_KEY'),
-        os.getenv('BROKER_SECRET_KEY')
+        tenant.getenv('BROKER_API_KEY'),
+        tenant.getenv('BROKER_SECRET_KEY'),
+        url_override=SANDBOX_DATA_URL if data_sandbox() else None
+    )
+    option_client = OptionHistoricalDataClient(
+        tenant.getenv('BROKER_API_KEY'),
+        tenant.getenv('BROKER_SECRET_KEY')
     )
+    news_client = NewsClient(
+        tenant.getenv('BROKER_API_KEY'),
+        tenant.getenv('BROKER_SECRET_KEY'),
+        url_override=SANDBOX_DATA_URL if data_sandbox() else None
+    )
+    # Fault hooks are no-ops unless chaos testing is enabled outside production,
+    # order methods are disabled in observer mode
     return {
-        'trading': trading_client,
-        'stock': stock_client,
+        'trading': observer.wrap_trading_client(chaos.wrap(trading_client, {'*': chaos.error_hook})),
+        'stock': chaos.wrap(stock_client, {'*': chaos.error_hook}),
+        'option': chaos.wrap(option_client, {'*': chaos.error_hook}),
+        'news': chaos.wrap(news_client, {'*': chaos.error_hook}),
     }
 
 
@@ -52,6 +134,44 @@ def get_broker_client(service):
     return alpaca_clients[service]
 
 
+# Initialize a placeholder for the cached market clock
+market_clock_cache = None
+
+
+def fetch_market_clock() -> dict:
+    """
+    Retrieve the market clock from the API.
+
+    Returns:
+        dict: { 'is_open', 'timestamp', 'next_open', 'next_close' }
+    """
+    trading_client = get_broker_client('trading')
+    try:
+        clock = trading_client.get_clock()
+        return {
+            'is_open': clock.is_open,
+            'timestamp': clock.timestamp,
+            'next_open': clock.next_open,
+            'next_close': clock.next_close,
+        }
+    except Exception as e:
+        raise Exception(f"Failed to get market clock: {e}") from e
+
+
+def get_market_clock() -> dict:
+    """
+    Returns the market clock, fetched from the API at most once per
+    market_clock.DEFAULT_TTL_SECONDS and around session boundaries.
+
+    Returns:
+        dict: { 'is_open', 'timestamp', 'next_open', 'next_close' }
+    """
+    global market_clock_cache
+    if market_clock_cache is None:
+        market_clock_cache = market_clock.CachedClock(fetch_market_clock)
+    return market_clock_cache.get()
+
+
 def is_market_open() -> bool:
     """
     Check if the stock market is currently open.
@@ -59,11 +179,8 @@ def is_market_open() -> bool:
     Returns:
         bool: True if the market is open, False otherwise.
     """
-    trading_client = get_broker_client('trading')
     try:
-        # Get the market clock
-        clock = trading_client.get_clock()
-        return clock.is_open
+        return get_market_clock()['is_open']
     except Exception as e:
         raise Exception(f"Failed to check market status: {e}") from e
 
@@ -76,20 +193,13 @@ def minutes_till_market_close() -> int:
         int: The number of minutes until the market closes.
         Returns 0 if the market is closed.
     """
-    trading_client = get_broker_client('trading')
     try:
-        # Get the market clock
-        clock = trading_client.get_clock()
+        clock = get_market_clock()
         # Check if the market is open
-        if not clock.is_open:
+        if not clock['is_open']:
             return 0
-        # Caclulate the time difference between now and the next market close
-        now = clock.timestamp
-        next_close = clock.next_close
-        time_difference = next_close - now
-        # Convert the time difference to minutes
-        minutes_remaining = int(time_difference.total_seconds() / 60)
-        return minutes_remaining
+        # Convert the time until the next market close to minutes
+        return int((clock['next_close'] - clock['timestamp']).total_seconds() / 60)
     except Exception as e:
         raise Exception(
                 f"Failed to calculate minutes until market close: {e}"
@@ -100,31 +210,240 @@ def minutes_till_market_open() -> int:
     """
     Calculates and returns the time until the market reopens.
     """
-    trading_client = get_broker_client('trading')
     try:
-        # Get the market clock
-        clock = trading_client.get_clock()
-        if clock.is_open:
-            return 0
-        else:
-            # Calculate the time until next market open
-            next_open = clock.next_open
-            now = datetime.now(next_open.tzinfo)  # Ensure timezone awareness
-            time_until_open = next_open - now
-            # Convert the time difference to total minutes
-            total_minutes = int(time_until_open.total_seconds() // 60)
-            return total_minutes
+        seconds = market_clock.seconds_till_open(get_market_clock())
+        return int(seconds // 60)
     except Exception as e:
         raise Exception(
             f"Failed to calculate minutes until market open: {e}"
         ) from e
 
 
+def get_positions() -> dict:
+    """
+    Retrieve all open positions on the account.
+
+    Returns:
+        dict: { symbol: { 'qty': float (negative for shorts),
+        'market_value': float, 'current_price': float } }
+
+    Raises:
+        Exception: If the positions cannot be retrieved.
+    """
+    trading_client = get_broker_client('trading')
+    try:
+        return {
+            position.symbol: {
+                'qty': float(position.qty),
+                'market_value': float(position.market_value or 0),
+                'current_price': float(position.current_price or 0),
+            }
+            for position in trading_client.get_all_positions()
+        }
+    except Exception as e:
+        raise Exception(f"Failed to retrieve positions: {e}") from e
+
+
+def get_account_equity() -> float:
+    """
+    Retrieve the current total equity of the account.
+
+    Returns:
+        float: Cash plus the market value of all positions.
+
+    Raises:
+        Exception: If the account cannot be retrieved.
+    """
+    trading_client = get_broker_client('trading')
+    try:
+        return float(trading_client.get_account().equity)
+    except Exception as e:
+        raise Exception(f"Failed to retrieve account equity: {e}") from e
+
+
+def get_asset_flags(symbol: str, max_age_seconds: float = ASSET_CACHE_TTL_SECONDS) -> dict:
+    """
+    Retrieve the trading eligibility flags of an asset.
+    Results are cached per symbol since the flags only change a few times a day
+    (e.g., when a stock goes hard-to-borrow), which keeps the check cheap enough
+    to run before every order.
+
+    Args:
+        symbol (str): The stock symbol (e.g., "AAPL").
+        max_age_seconds (float, optional): The maximum age of a cached entry.
+                                           Defaults to one hour.
+
+    Returns:
+        dict: A dictionary containing the boolean flags 'tradable', 'shortable',
+        'easy_to_borrow', 'marginable', and 'fractionable'.
+
+    Raises:
+        Exception: If the asset lookup fails.
+    """
+    cached = asset_cache.get(symbol)
+    if cached and time.time() - cached[0] < max_age_seconds:
+        return cached[1]
+    trading_client = get_broker_client('trading')
+    try:
+        asset = trading_client.get_asset(symbol)
+        flags = {
+            'tradable': bool(asset.tradable),
+            'shortable': bool(asset.shortable),
+            'easy_to_borrow': bool(asset.easy_to_borrow),
+            'marginable': bool(asset.marginable),
+            'fractionable': bool(asset.fractionable),
+        }
+        asset_cache[symbol] = (time.time(), flags)
+        return flags
+    except Exception as e:
+        raise Exception(f"Failed to retrieve asset flags for {symbol}: {e}") from e
+
+
+def is_shortable(symbol: str) -> bool:
+    """
+    Check if an asset can be sold short right now.
+    Alpaca only allows opening shorts in assets that are both
+    shortable and easy-to-borrow.
+
+    Args:
+        symbol (str): The stock symbol (e.g., "AAPL").
+
+    Returns:
+        bool: True if the asset is tradable, shortable, and easy to borrow.
+    """
+    flags = get_asset_flags(symbol)
+    return flags['tradable'] and flags['shortable'] and flags['easy_to_borrow']
+
+
+def generate_client_order_id(strategy: str, symbol: str, signal_timestamp: str) -> str:
+    """
+    Build a deterministic client order ID for a signal.
+    The same strategy, symbol, and signal timestamp always produce the same ID,
+    so a restarted service re-processing a signal maps to the original order.
+    Tenants other than the default are part of the ID, so books sharing an
+    account never collide.
+
+    Args:
+        strategy (str): The strategy name (e.g., "reversion").
+        symbol (str): The stock symbol of the signal.
+        signal_timestamp (str): The ISO timestamp of the bar that produced the signal.
+
+    Returns:
+        str: A client order ID within Alpaca's 128 character limit.
+    """
+    prefix = tenant.resource_name(strategy)
+    digest = hashlib.sha256(f'{prefix}|{symbol}|{signal_timestamp}'.encode()).hexdigest()[:24]
+    return f'{prefix}-{symbol}-{digest}'[:128]
+
+
+def get_order_by_client_id(client_order_id: str):
+    """
+    Look up an order by its client order ID.
+
+    Args:
+        client_order_id (str): The client order ID assigned at submission.
+
+    Returns:
+        Order: The matching order, or None if no order exists with that ID.
+    """
+    trading_client = get_broker_client('trading')
+    try:
+        return trading_client.get_order_by_client_id(client_order_id)
+    except APIError as e:
+        if e.status_code == 404:
+            return None
+        raise Exception(f"Failed to look up order {client_order_id}: {e}") from e
+
+
+def _is_transient_error(error: Exception) -> bool:
+    """
+    Determine if an order submission error is worth retrying.
+    """
+    if isinstance(error, (requests.ConnectionError, requests.Timeout)):
+        return True
+    if isinstance(error, (APIError, chaos.InjectedFault)):
+        return error.status_code in (429, 500, 502, 503, 504)
+    return False
+
+
+def submit_order_idempotent(
+    order_request,
+    max_retries: int = 3,
+    backoff_seconds: float = 1.0
+):
+    """
+    Submit an order at most once for its client order ID.
+    Before every attempt the broker is checked for an existing order with the
+    same client order ID, so a request that timed out after reaching Alpaca,
+    or a signal replayed after a crash, returns the original order instead of
+    submitting a duplicate. Transient failures (rate limits, 5xx responses,
+    connection errors) are retried with exponential backoff.
+
+    Args:
+        order_request (OrderRequest): The order request, with client_order_id set.
+        max_retries (int, optional): Retries after the first attempt. Defaults to 3.
+        backoff_seconds (float, optional): The initial backoff, doubled on every
+                                           retry. Defaults to 1.0.
+
+    Returns:
+        Order: The submitted order, or the previously submitted duplicate.
+
+    Raises:
+        ValueError: If the order request has no client order ID.
+        order_errors.OrderError: If the broker rejects the order, typed by cause.
+        Exception: If the order fails with a non-transient error or retries run out.
+    """
+    client_order_id = order_request.client_order_id
+    if not client_order_id:
+        raise ValueError('Idempotent submission requires a client_order_id.')
+    trading_client = get_broker_client('trading')
+    for attempt in range(max_retries + 1):
+        existing_order = get_order_by_client_id(client_order_id)
+        if existing_order is not None:
+            logger.warning(f'Order {client_order_id} was already submitted, skipping duplicate')
+            return existing_order
+        try:
+            return trading_client.submit_order(order_request)
+        except Exception as e:
+            if not _is_transient_error(e) or attempt == max_retries:
+                raise order_errors.wrap(e, f"Failed to submit order {client_order_id}") from e
+            delay = backoff_seconds * (2 ** attempt)
+            logger.warning(
+                f'Transient error submitting order {client_order_id}, retrying in {delay}s: {e}'
+            )
+            time.sleep(delay)
+
+
+def _pre_trade_check(
+    symbol: str,
+    side: OrderSide,
+    qty: Optional[float] = None,
+    notional: Optional[float] = None,
+    price: Optional[float] = None
+) -> None:
+    """
+    Run the pre-trade self-cross and risk checks for an order about to be
+    submitted against the Alpaca account, see risk.pre_trade_check.
+
+    Raises:
+        wash.WashTradeRejection: If the order could cross an opposing order.
+        risk.RiskRejection: If the order breaches a limit or cannot be priced.
+    """
+    risk.pre_trade_check(
+        symbol, side.value, qty, notional, price,
+        get_positions=get_positions,
+        get_orders=get_orders,
+        get_latest_price=lambda symbol: get_latest_trade(symbol).price,
+        get_equity=get_account_equity
+    )
+
+
 def place_market_order(
     symbol: str,
     qty: float,
     side: OrderSide,
-    time_in_force: TimeInForce = TimeInForce.DAY
+    time_in_force: TimeInForce = TimeInForce.DAY,
+    client_order_id: Optional[str] = None
 ) -> Optional[float]:
     """
     Place a market order.
@@ -137,10 +456,16 @@ def place_market_order(
         time_in_force (TimeInForce, optional):
                     The time-in-force for the order (e.g., TimeInForce.DAY).
                     Defaults to TimeInForce.DAY.
+        client_order_id (Optional[str], optional): When provided the order is
+                    submitted idempotently under this ID. Defaults to None.
 
     Raises:
+        wash.WashTradeRejection: If the order could cross an opposing order.
+        risk.RiskRejection: If the order fails pre-trade risk checks.
+        order_errors.OrderError: If the broker rejects the order, typed by cause.
         Exception: If the order placement fails.
     """
+    _pre_trade_check(symbol, side, qty=qty)
     trading_client = get_broker_client('trading')
     try:
         # Create a market order request
@@ -148,10 +473,14 @@ def place_market_order(
             symbol=symbol,
             qty=qty,
             side=side,
-            time_in_force=time_in_force
+            time_in_force=time_in_force,
+            client_order_id=client_order_id
         )
         # Place the market order
-        submitted_order = trading_client.submit_order(market_order)
+        if client_order_id:
+            submitted_order = submit_order_idempotent(market_order)
+        else:
+            submitted_order = trading_client.submit_order(market_order)
         logger.info(
             f"Market order placed for {qty} shares of {symbol} ({side.value})"
         )
@@ -162,7 +491,71 @@ def place_market_order(
             logger.warning('Order was placed but filled price is not yet available')
             return None
     except Exception as e:
-        raise Exception(f"Failed to place market order: {e}") from e
+        raise order_errors.wrap(e, "Failed to place market order") from e
+
+
+def place_notional_order(
+    symbol: str,
+    notional: float,
+    side: OrderSide,
+    time_in_force: TimeInForce = TimeInForce.DAY,
+    client_order_id: Optional[str] = None
+) -> Optional[float]:
+    """
+    Place a market order sized by dollar amount instead of share quantity.
+    Alpaca fills notional orders with fractional shares, so this only
+    works for fractionable assets and only with a DAY time-in-force.
+    Fractional shares cannot be sold short, so a notional SELL can only
+    reduce an existing long position.
+
+    Args:
+        symbol (str): The stock symbol to trade (e.g., "AAPL").
+        notional (float): The dollar amount to trade, rounded to cents.
+        side (OrderSide): The side of the order
+                    (e.g., OrderSide.BUY or OrderSide.SELL).
+        time_in_force (TimeInForce, optional):
+                    The time-in-force for the order. Defaults to TimeInForce.DAY.
+        client_order_id (Optional[str], optional): When provided the order is
+                    submitted idempotently under this ID. Defaults to None.
+
+    Returns:
+        Optional[float]: The filled average price if available, else None.
+
+    Raises:
+        ValueError: If the notional amount is not positive.
+        wash.WashTradeRejection: If the order could cross an opposing order.
+        risk.RiskRejection: If the order fails pre-trade risk checks.
+        order_errors.OrderError: If the broker rejects the order, typed by cause.
+        Exception: If the order placement fails.
+    """
+    if notional <= 0:
+        raise ValueError('Notional amount must be positive.')
+    _pre_trade_check(symbol, side, notional=notional)
+    trading_client = get_broker_client('trading')
+    try:
+        # Create a market order request for a dollar amount
+        notional_order = MarketOrderRequest(
+            symbol=symbol,
+            notional=round(notional, 2),
+            side=side,
+            time_in_force=time_in_force,
+            client_order_id=client_order_id
+        )
+        # Place the notional order
+        if client_order_id:
+            submitted_order = submit_order_idempotent(notional_order)
+        else:
+            submitted_order = trading_client.submit_order(notional_order)
+        logger.info(
+            f"Notional order placed for ${notional:.2f} of {symbol} ({side.value})"
+        )
+        if submitted_order.filled_avg_price is not None:
+            return float(submitted_order.filled_avg_price)
+        else:
+            logger.warning('Order was placed but filled price is not yet available')
+            return None
+    except Exception as e:
+        raise order_errors.wrap(e, "Failed to place notional order") from e
 
 
 def place_limit_order(
@@ -170,10 +563,14 @@ def place_limit_order(
     qty: float,
     side: OrderSide,
     limit_price: float,
-    time_in_force: TimeInForce = TimeInForce.DAY
-) -> None:
+    time_in_force: TimeInForce = TimeInForce.DAY,
+    extended_hours: bool = False,
+    client_order_id: Optional[str] = None
+) -> str:
     """
     Place a limit order.
+    Only DAY limit orders may be eligible for the pre-market and
+    post-market sessions.
 
     Args:
         symbol (str): The stock symbol to trade (e.g., "AAPL").
@@ -184,10 +581,24 @@ def place_limit_order(
         time_in_force (TimeInForce, optional):
                     The time-in-force for the order (e.g., TimeInForce.DAY).
                     Defaults to TimeInForce.DAY.
+        extended_hours (bool, optional): Allow the order to fill during the
+                    pre-market and post-market sessions. Defaults to False.
+        client_order_id (Optional[str], optional): When provided the order is
+                    submitted idempotently under this ID. Defaults to None.
+
+    Returns:
+        str: The ID of the resting order, used to cancel or replace it.
 
     Raises:
+        ValueError: If an extended hours order is not a DAY order.
+        wash.WashTradeRejection: If the order could cross an opposing order.
+        risk.RiskRejection: If the order fails pre-trade risk checks.
+        order_errors.OrderError: If the broker rejects the order, typed by cause.
         Exception: If the order placement fails.
     """
+    if extended_hours and time_in_force != TimeInForce.DAY:
+        raise ValueError('Extended hours orders must use a DAY time-in-force.')
+    _pre_trade_check(symbol, side, qty=qty, price=limit_price)
     trading_client = get_broker_client('trading')
     try:
         # Create a limit order request
@@ -196,52 +607,363 @@ def place_limit_order(
             qty=qty,
             side=side,
             limit_price=limit_price,
-            time_in_force=time_in_force
+            time_in_force=time_in_force,
+            extended_hours=extended_hours,
+            client_order_id=client_order_id
         )
         # Place the order
-        trading_client.submit_order(limit_order)
+        if client_order_id:
+            submitted_order = submit_order_idempotent(limit_order)
+        else:
+            submitted_order = trading_client.submit_order(limit_order)
         logger.info(
             f"""Limit order placed for {qty} shares of
             {symbol} ({side.value}) at ${limit_price}
             """
         )
+        return str(submitted_order.id)
     except Exception as e:
-        raise Exception(f"Failed to place limit order: {e}") from e
+        raise order_errors.wrap(e, "Failed to place limit order") from e
+
+
+def get_orders(symbol: str, since: Optional[datetime] = None) -> list[Order]:
+    """
+    Retrieve the open orders of a symbol, plus its closed orders submitted since a time.
+
+    Args:
+        symbol (str): The stock symbol.
+        since (Optional[datetime], optional): Include closed orders submitted after this
+                                              time. Defaults to None (open orders only).
+
+    Returns:
+        list[Order]: The orders.
+
+    Raises:
+        Exception: If the orders cannot be retrieved.
+    """
+    trading_client = get_broker_client('trading')
+    try:
+        orders = trading_client.get_orders(GetOrdersRequest(status=QueryOrderStatus.OPEN, symbols=[symbol]))
+        if since is not None:
+            orders += trading_client.get_orders(GetOrdersRequest(
+                status=QueryOrderStatus.CLOSED,
+                symbols=[symbol],
+                after=since,
+                limit=500
+            ))
+        return [converters.order_from_alpaca(order) for order in orders]
+    except Exception as e:
+        raise Exception(f"Failed to get orders for {symbol}: {e}") from e
+
+
+def cancel_order(order_id: str) -> None:
+    """
+    Cancel a single open order.
+
+    Args:
+        order_id (str): The ID of the order to cancel.
+
+    Raises:
+        Exception: If the order cannot be cancelled (e.g., it already filled).
+    """
+    trading_client = get_broker_client('trading')
+    try:
+        trading_client.cancel_order_by_id(order_id)
+        logger.info(f'Cancelled order {order_id}')
+    except Exception as e:
+        raise Exception(f"Failed to cancel order {order_id}: {e}") from e
+
+
+def cancel_all_orders() -> int:
+    """
+    Cancel every open order on the account.
+
+    Returns:
+        int: The number of orders the broker accepted cancellation for.
+
+    Raises:
+        Exception: If the cancel request fails.
+    """
+    trading_client = get_broker_client('trading')
+    try:
+        responses = trading_client.cancel_orders()
+        cancelled = [response for response in responses if response.status == 200]
+        logger.info(f'Cancelled {len(cancelled)} of {len(responses)} open orders')
+        return len(cancelled)
+    except Exception as e:
+        raise Exception(f"Failed to cancel all orders: {e}") from e
+
+
+def replace_order(
+    order_id: str,
+    limit_price: Optional[float] = None,
+    qty: Optional[float] = None
+) -> str:
+    """
+    Replace a resting order with a new price and/or quantity in one request,
+    e.g. to re-peg a limit as the spread z-score moves. Alpaca cancels the
+    original and creates a new order, so the returned ID supersedes order_id.
+
+    Args:
+        order_id (str): The ID of the order to replace.
+        limit_price (Optional[float], optional): The new limit price. Defaults to None.
+        qty (Optional[float], optional): The new quantity, fractional for fractional orders. Defaults to None.
+
+    Returns:
+        str: The ID of the replacement order.
+
+    Raises:
+        ValueError: If neither a new price nor a new quantity is given.
+        order_errors.OrderError: If the broker rejects the replacement, typed by cause.
+        Exception: If the replace request fails.
+    """
+    if limit_price is None and qty is None:
+        raise ValueError('Replacing an order requires a new limit price or quantity.')
+    trading_client = get_broker_client('trading')
+    try:
+        replacement = trading_client.replace_order_by_id(
+            order_id,
+            # Passed as is, fractional quantities would otherwise be truncated
+            ReplaceOrderRequest(qty=qty, limit_price=limit_price)
+        )
+        logger.info(f'Replaced order {order_id} with {replacement.id} (qty={qty}, limit=${limit_price})')
+        return str(replacement.id)
+    except Exception as e:
+        raise order_errors.wrap(e, f"Failed to replace order {order_id}") from e
+
+
+def place_oco_order(
+    symbol: str,
+    qty: float,
+    side: OrderSide,
+    take_profit_price: float,
+    stop_price: float,
+    stop_limit_price: Optional[float] = None,
+    time_in_force: TimeInForce = TimeInForce.GTC,
+    client_order_id: Optional[str] = None
+) -> str:
+    """
+    Place a one-cancels-other exit for an open position.
+    Both a profit-target limit and a stop are working at once, when one
+    fills Alpaca cancels the other, so the position never has two exits fill.
+
+    Args:
+        symbol (str): The stock symbol of the open position (e.g., "AAPL").
+        qty (float): The quantity of shares to exit.
+        side (OrderSide): The exit side, OrderSide.SELL to close a long
+                    or OrderSide.BUY to cover a short.
+        take_profit_price (float): The limit price of the profit target.
+        stop_price (float): The trigger price of the stop.
+        stop_limit_price (Optional[float], optional): Makes the stop a stop-limit
+                    at this price. Defaults to None (stop-market).
+        time_in_force (TimeInForce, optional): The time-in-force of both legs.
+                    Defaults to TimeInForce.GTC.
+        client_order_id (Optional[str], optional): When provided the order is
+                    submitted idempotently under this ID. Defaults to None.
+
+    Returns:
+        str: The ID of the OCO order.
+
+    Raises:
+        ValueError: If the target and stop are on the wrong sides of each other.
+        wash.WashTradeRejection: If the order could cross an opposing order.
+        risk.RiskRejection: If the order fails pre-trade risk checks.
+        order_errors.OrderError: If the broker rejects the order, typed by cause.
+        Exception: If the order placement fails.
+    """
+    # A sell exit takes profit above the stop, a buy-to-cover below it
+    if side == OrderSide.SELL and take_profit_price <= stop_price:
+        raise ValueError('Take profit price must be above the stop price for a sell exit.')
+    if side == OrderSide.BUY and take_profit_price >= stop_price:
+        raise ValueError('Take profit price must be below the stop price for a buy exit.')
+    _pre_trade_check(symbol, side, qty=qty, price=take_profit_price)
+    trading_client = get_broker_client('trading')
+    try:
+        oco_order = LimitOrderRequest(
+            symbol=symbol,
+            qty=qty,
+            side=side,
+            time_in_force=time_in_force,
+            order_class=OrderClass.OCO,
+            take_profit=TakeProfitRequest(limit_price=take_profit_price),
+            stop_loss=StopLossRequest(stop_price=stop_price, limit_price=stop_limit_price),
+            client_order_id=client_order_id
+        )
+        if client_order_id:
+            submitted_order = submit_order_idempotent(oco_order)
+        else:
+            submitted_order = trading_client.submit_order(oco_order)
+        logger.info(
+            f"OCO order placed for {qty} shares of {symbol} ({side.value}) "
+            f"target ${take_profit_price} stop ${stop_price}"
+        )
+        return str(submitted_order.id)
+    except Exception as e:
+        raise order_errors.wrap(e, "Failed to place OCO order") from e
+
+
+# Timeframe strings accepted by get_historical_bar_data()
+BAR_TIMEFRAMES = {
+    '1Min': TimeFrame.Minute,
+    '5Min': TimeFrame(5, TimeFrameUnit.Minute),
+    '15Min': TimeFrame(15, TimeFrameUnit.Minute),
+    '1Hour': TimeFrame.Hour,
+    '1Day': TimeFrame.Day,
+}
+# Minutes per timeframe unit, months are rounded up so windows stay under the page size
+TIMEFRAME_UNIT_MINUTES = {
+    TimeFrameUnit.Minute: 1,
+    TimeFrameUnit.Hour: 60,
+    TimeFrameUnit.Day: 1440,
+    TimeFrameUnit.Week: 10080,
+    TimeFrameUnit.Month: 44640,
+}
+# Bars per symbol requested at once when paging through a long range
+MAX_BARS_PER_REQUEST = 10_000
+ADJUSTMENTS = ('raw', 'split', 'dividend', 'all')
+
+
+def split_date_range(start_date: datetime, end_date: datetime, step: timedelta) -> list:
+    """
+    Split a date range into consecutive windows no longer than step.
+
+    Args:
+        start_date (datetime): The start of the range.
+        end_date (datetime): The end of the range.
+        step (timedelta): The maximum length of a window.
+
+    Returns:
+        list: (start, end) tuples covering the range in order.
+    """
+    windows = []
+    window_start = start_date
+    while window_start < end_date:
+        window_end = min(window_start + step, end_date)
+        windows.append((window_start, window_end))
+        window_start = window_end
+    return windows
 
 
 def get_historical_bar_data(
     symbols: List[str],
     start_date: datetime,
     end_date: datetime,
-    timeframe: TimeFrame = TimeFrame.Hour,
-    limit: Optional[int] = None
+    timeframe: TimeFrame | str = TimeFrame.Hour,
+    limit: Optional[int] = None,
+    adjustment: str = 'raw'
 ) -> dict:
     """
     Retrieve historical bar data for a list of stock symbols.
+    Long ranges are requested in windows of at most MAX_BARS_PER_REQUEST
+    bars per symbol and stitched back together in order. Alpaca applies a
+    request's limit to every symbol's bars combined, so with several symbols
+    the limit is applied to each symbol here, and symbols that reached it
+    are left out of later windows.
 
     Args:
         symbols (List[str]): A list of stock symbols (e.g., ["AAPL", "MSFT"]).
         start_date (datetime): The start date for the historical data.
         end_date (datetime): The end date for the historical data.
-        timeframe (TimeFrame, optional): The granularity of the data
-            (e.g., TimeFrame.Day, TimeFrame.Minute). Defaults to TimeFrame.Day.
+        timeframe (TimeFrame | str, optional): The granularity of the data, a TimeFrame
+            or one of '1Min', '5Min', '15Min', '1Hour', '1Day'. Defaults to TimeFrame.Hour.
         limit (Optional[int], optional): The maximum number of data points to
-                                        retrieve. Defaults to None.
+                                        retrieve per symbol. Defaults to None.
+        adjustment (str, optional): Corporate action adjustment, one of 'raw',
+                                    'split', 'dividend', or 'all'. Defaults to 'raw'.
 
     Returns:
         dict: A dictionary containing historical bar data
         for the specified symbols.
+
+    Raises:
+        ValueError: If the timeframe or adjustment is not supported.
+        Exception: If a page of bars fails to download.
     """
+    if isinstance(timeframe, str):
+        if timeframe not in BAR_TIMEFRAMES:
+            raise ValueError(f'Unsupported timeframe {timeframe}.')
+        timeframe = BAR_TIMEFRAMES[timeframe]
+    if adjustment not in ADJUSTMENTS:
+        raise ValueError(f'Unsupported adjustment {adjustment}.')
+
+    bar_minutes = timeframe.amount_value * TIMEFRAME_UNIT_MINUTES[timeframe.unit_value]
     stock_client = get_broker_client('stock')
-    request = StockBarsRequest(
-        symbol_or_symbols=symbols,
-        timeframe=timeframe,
-        start=start_date,
-        end=end_date,
-        limit=limit
-    )
-    bars = stock_client.get_stock_bars(request)
-    return bars.data  # Returns a pandas dataframe
+    bars = {}
+    for window_start, window_end in split_date_range(
+        start_date, end_date, timedelta(minutes=bar_minutes * MAX_BARS_PER_REQUEST)
+    ):
+        pending = [symbol for symbol in symbols if not limit or len(bars.get(symbol, [])) < limit]
+        if not pending:
+            break
+        request = StockBarsRequest(
+            symbol_or_symbols=pending,
+            timeframe=timeframe,
+            start=window_start,
+            end=window_end,
+            # A limit shared by several symbols could be used up by one of them
+            limit=limit - len(bars.get(pending[0], [])) if limit and len(pending) == 1 else None,
+            adjustment=Adjustment(adjustment),
+            feed=data_feed()
+        )
+        try:
+            page = stock_client.get_stock_bars(request).data
+        except Exception as e:
+            raise Exception(f"Failed to get bars from {window_start} to {window_end}: {e}") from e
+        for symbol, symbol_bars in page.items():
+            bars.setdefault(symbol, []).extend(symbol_bars)
+    if limit:
+        bars = {symbol: symbol_bars[:limit] for symbol, symbol_bars in bars.items()}
+    return bars
+
+
+# Symbols per multi-symbol bars request, keeps request URLs well under the API's limit
+MAX_SYMBOLS_PER_REQUEST = 100
+
+
+def get_historical_bars_multi(
+    symbols: List[str],
+    start_date: datetime,
+    end_date: datetime,
+    timeframe: TimeFrame | str = '1Day',
+    adjustment: str = 'all',
+    batch_size: int = MAX_SYMBOLS_PER_REQUEST
+) -> dict[str, list[DomainBar]]:
+    """
+    Retrieve bars for a universe of symbols through the multi-symbol bars
+    endpoint, one request per batch of symbols instead of one per symbol.
+
+    Args:
+        symbols (List[str]): The universe of stock symbols, duplicates are ignored.
+        start_date (datetime): The start date for the historical data.
+        end_date (datetime): The end date for the historical data.
+        timeframe (TimeFrame | str, optional): The granularity of the data. Defaults to '1Day'.
+        adjustment (str, optional): Corporate action adjustment, one of 'raw',
+                                    'split', 'dividend', or 'all'. Defaults to 'all'.
+        batch_size (int, optional): Symbols per request. Defaults to MAX_SYMBOLS_PER_REQUEST.
+
+    Returns:
+        dict[str, list[DomainBar]]: Bars in time order for every requested symbol,
+        an empty list for symbols without data.
+
+    Raises:
+        ValueError: If the timeframe or adjustment is not supported.
+        Exception: If a batch of bars fails to download.
+    """
+    universe = list(dict.fromkeys(symbols))
+    bars = {symbol: [] for symbol in universe}
+    for i in range(0, len(universe), batch_size):
+        batch = universe[i:i + batch_size]
+        data = get_historical_bar_data(
+            symbols=batch,
+            start_date=start_date,
+            end_date=end_date,
+            timeframe=timeframe,
+            adjustment=adjustment
+        )
+        for symbol, symbol_bars in data.items():
+            bars[symbol] = [converters.bar_from_alpaca(bar) for bar in symbol_bars]
+        logger.debug(f'Fetched bars for {len(batch)} symbols in one request')
+    return bars
 
 
 def get_historical_quote_data(
@@ -269,7 +991,8 @@ def get_historical_quote_data(
         symbol_or_symbols=symbols,
         start=start_date,
         end=end_date,
-        limit=limit
+        limit=limit,
+        feed=data_feed()
     )
     quotes = stock_client.get_quotes(request)
     return quotes.data  # Returns a pandas dataframe
@@ -300,12 +1023,76 @@ def get_historical_trade_data(
         symbol_or_symbols=symbols,
         start=start_date,
         end=end_date,
-        limit=limit
+        limit=limit,
+        feed=data_feed()
     )
     trades = stock_client.get_trades(request)
     return trades.data  # Returns a pandas dataframe
 
 
+def get_latest_trade(symbol: str) -> Trade:
+    """
+    Retrieve the most recent trade of a stock.
+
+    Args:
+        symbol (str): The stock symbol.
+
+    Returns:
+        Trade: The latest trade.
+
+    Raises:
+        Exception: If the trade cannot be retrieved.
+    """
+    stock_client = get_broker_client('stock')
+    try:
+        latest = stock_client.get_stock_latest_trade(StockLatestTradeRequest(symbol_or_symbols=symbol, feed=data_feed()))
+        return converters.trade_from_alpaca(latest[symbol])
+    except Exception as e:
+        raise Exception(f"Failed to get latest trade for {symbol}: {e}") from e
+
+
+def get_latest_quote(symbol: str) -> Quote:
+    """
+    Retrieve the current best bid and offer of a stock.
+
+    Args:
+        symbol (str): The stock symbol.
+
+    Returns:
+        Quote: The latest quote.
+
+    Raises:
+        Exception: If the quote cannot be retrieved.
+    """
+    stock_client = get_broker_client('stock')
+    try:
+        latest = stock_client.get_stock_latest_quote(StockLatestQuoteRequest(symbol_or_symbols=symbol, feed=data_feed()))
+        return converters.quote_from_alpaca(latest[symbol])
+    except Exception as e:
+        raise Exception(f"Failed to get latest quote for {symbol}: {e}") from e
+
+
+def get_snapshot(symbol: str) -> Snapshot:
+    """
+    Retrieve the latest trade, quote, minute bar, and daily bars of a stock in one call.
+
+    Args:
+        symbol (str): The stock symbol.
+
+    Returns:
+        Snapshot: The symbol's snapshot.
+
+    Raises:
+        Exception: If the snapshot cannot be retrieved.
+    """
+    stock_client = get_broker_client('stock')
+    try:
+        snapshots = stock_client.get_stock_snapshot(StockSnapshotRequest(symbol_or_symbols=symbol, feed=data_feed()))
+        return converters.snapshot_from_alpaca(snapshots[symbol])
+    except Exception as e:
+        raise Exception(f"Failed to get snapshot for {symbol}: {e}") from e
+
+
 def extract_close_data(bars: List[Bar]) -> List[float]:
     """
     Extract the 'close' prices for all bar data points from a list of Bar objects.
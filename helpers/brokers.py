@@ -0,0 +1,1102 @@
+import os
+import asyncio
+import inspect
+from abc import ABC, abstractmethod
+import math
+from datetime import datetime, timedelta, timezone
+from zoneinfo import ZoneInfo
+from itertools import count
+from collections import deque
+from threading import Event
+from helpers import broker, bar_cache, futures, logger, market_clock, observer, risk, sessions, tenant, trading_calendar
+from helpers.domain import FuturesContract, Order, Side, converters
+from alpaca.data.enums import DataFeed
+from alpaca.data.live import StockDataStream
+from alpaca.trading.enums import OrderSide, TimeInForce
+from typing import Awaitable, Callable, Optional
+
+# Initialize logger
+logger = logger.Logger('brokers.py')
+
+# Handler receiving bar dictionaries (see bar_cache.bar_to_dict) from a stream
+BarHandler = Callable[[dict], Awaitable[None]]
+
+# Handler receiving trade dictionaries (see Trade.to_dict) from a stream
+TradeHandler = Callable[[dict], Awaitable[None]]
+
+# Handler receiving quote dictionaries (see Quote.to_dict) from a stream
+QuoteHandler = Callable[[dict], Awaitable[None]]
+
+
+class Broker(ABC):
+    """Interface every broker implementation provides.
+
+    Strategies and services talk to a Broker instead of a vendor SDK, so other
+    brokers can be added and a MockBroker can be injected for tests and
+    backtests. Quantities are signed by side, prices are floats, and bars are
+    plain dictionaries in the format published on the data topic.
+    """
+
+    @abstractmethod
+    def submit_market_order(
+        self,
+        symbol: str,
+        qty: float,
+        side: OrderSide,
+        time_in_force: TimeInForce = TimeInForce.DAY,
+        client_order_id: Optional[str] = None
+    ) -> Optional[float]:
+        """Submits a market order, returning the fill price if already filled."""
+
+    @abstractmethod
+    def submit_notional_order(
+        self,
+        symbol: str,
+        notional: float,
+        side: OrderSide,
+        time_in_force: TimeInForce = TimeInForce.DAY,
+        client_order_id: Optional[str] = None
+    ) -> Optional[float]:
+        """Submits a dollar-sized market order, returning the fill price if already filled."""
+
+    @abstractmethod
+    def submit_limit_order(
+        self,
+        symbol: str,
+        qty: float,
+        side: OrderSide,
+        limit_price: float,
+        time_in_force: TimeInForce = TimeInForce.DAY,
+        extended_hours: bool = False,
+        client_order_id: Optional[str] = None
+    ) -> str:
+        """Submits a limit order, returning its order ID. Extended hours orders may fill outside RTH."""
+
+    @abstractmethod
+    def cancel_order(self, order_id: str) -> None:
+        """Cancels a working order."""
+
+    @abstractmethod
+    def get_orders(self, symbol: str, since: Optional[datetime] = None) -> list[Order]:
+        """Returns the open orders of a symbol, plus its closed orders submitted since a time."""
+
+    @abstractmethod
+    def get_positions(self) -> dict:
+        """Returns open positions as { symbol: { 'qty', 'market_value', 'current_price' } }."""
+
+    @abstractmethod
+    def get_account_equity(self) -> float:
+        """Returns the account equity."""
+
+    @abstractmethod
+    def get_clock(self) -> dict:
+        """Returns the market clock as { 'is_open', 'timestamp', 'next_open', 'next_close' }."""
+
+    @abstractmethod
+    def get_bars(
+        self,
+        symbols: list[str],
+        start: datetime,
+        end: datetime,
+        timeframe: str = '1Min',
+        limit: Optional[int] = None
+    ) -> dict[str, list[dict]]:
+        """Returns historical bars as { symbol: [bar dict, ...] } ordered oldest to newest."""
+
+    @abstractmethod
+    def get_latest_price(self, symbol: str) -> Optional[float]:
+        """Returns the most recent trade price of a symbol, None if unavailable."""
+
+    @abstractmethod
+    def stream_bars(self, handler: BarHandler, symbols: list[str]) -> None:
+        """Streams live bars to an async handler, blocking until stop_stream() is called."""
+
+    @abstractmethod
+    def stop_stream(self) -> None:
+        """Stops a running stream_bars() call."""
+
+    def subscribe_bars(self, symbols: list[str]) -> None:
+        """Adds symbols to a running stream_bars() call, a no-op when not streaming."""
+        raise NotImplementedError(f'{type(self).__name__} cannot change subscriptions while streaming.')
+
+    def unsubscribe_bars(self, symbols: list[str]) -> None:
+        """Removes symbols from a running stream_bars() call, a no-op when not streaming."""
+        raise NotImplementedError(f'{type(self).__name__} cannot change subscriptions while streaming.')
+
+    def stream_trades(self, handler: TradeHandler, symbols: list[str]) -> None:
+        """Delivers the symbols' trades to an async handler over stream_bars()'s connection, from now and every reconnect."""
+        raise NotImplementedError(f'{type(self).__name__} cannot stream trades.')
+
+    def subscribe_trades(self, symbols: list[str]) -> None:
+        """Adds symbols to the trades delivered since stream_trades()."""
+        raise NotImplementedError(f'{type(self).__name__} cannot stream trades.')
+
+    def unsubscribe_trades(self, symbols: list[str]) -> None:
+        """Removes symbols from the trades delivered since stream_trades()."""
+        raise NotImplementedError(f'{type(self).__name__} cannot stream trades.')
+
+    def stream_quotes(self, handler: QuoteHandler, symbols: list[str]) -> None:
+        """Delivers the symbols' quotes to an async handler over stream_bars()'s connection, from now and every reconnect."""
+        raise NotImplementedError(f'{type(self).__name__} cannot stream quotes.')
+
+    def subscribe_quotes(self, symbols: list[str]) -> None:
+        """Adds symbols to the quotes delivered since stream_quotes()."""
+        raise NotImplementedError(f'{type(self).__name__} cannot stream quotes.')
+
+    def unsubscribe_quotes(self, symbols: list[str]) -> None:
+        """Removes symbols from the quotes delivered since stream_quotes()."""
+        raise NotImplementedError(f'{type(self).__name__} cannot stream quotes.')
+
+    def is_shortable(self, symbol: str) -> bool:
+        """Checks if a symbol can be sold short, True unless the broker says otherwise."""
+        return True
+
+    def is_market_open(self) -> bool:
+        """Checks if the market is currently open."""
+        return self.get_clock()['is_open']
+
+    def minutes_till_market_close(self) -> int:
+        """Returns minutes until the market closes, 0 if it is closed."""
+        clock = self.get_clock()
+        if not clock['is_open']:
+            return 0
+        return int((clock['next_close'] - clock['timestamp']).total_seconds() / 60)
+
+    def minutes_till_market_open(self) -> int:
+        """Returns minutes until the market opens, 0 if it is open."""
+        clock = self.get_clock()
+        if clock['is_open']:
+            return 0
+        return int((clock['next_open'] - clock['timestamp']).total_seconds() // 60)
+
+    def next_open(self) -> Optional[datetime]:
+        """Returns the start of the next regular session, None if unknown."""
+        return market_clock.next_open(self.get_clock())
+
+    def is_within_last_minutes_of_session(self, minutes: float) -> bool:
+        """Checks if the market is open and closes within the given number of minutes."""
+        return market_clock.is_within_last_minutes_of_session(self.get_clock(), minutes)
+
+    def sleep_until_open(self, stop: Optional[Event] = None) -> bool:
+        """Blocks until the market is open, returning False if stop was set first."""
+        return market_clock.sleep_until_open(self.get_clock, stop)
+
+    def get_session(self) -> sessions.MarketSession:
+        """Returns the market session in progress."""
+        clock = self.get_clock()
+        return sessions.get_session(clock['timestamp'], clock)
+
+    def check_risk(
+        self,
+        symbol: str,
+        side: OrderSide,
+        qty: Optional[float] = None,
+        notional: Optional[float] = None,
+        price: Optional[float] = None
+    ) -> None:
+        """
+        Run the pre-trade self-cross and risk checks for an order about to be
+        submitted against this broker's positions and orders, see risk.pre_trade_check.
+
+        Raises:
+            wash.WashTradeRejection: If the order could cross an opposing order.
+            risk.RiskRejection: If the order breaches a limit or cannot be priced.
+        """
+        risk.pre_trade_check(
+            symbol, side.value, qty, notional, price,
+            get_positions=self.get_positions,
+            get_orders=self.get_orders,
+            get_latest_price=self.get_latest_price,
+            get_equity=self.get_account_equity
+        )
+
+
+class AlpacaBroker(Broker):
+    """Broker backed by the Alpaca trading, market data, and stream APIs.
+
+    Delegates to the helpers in broker.py, which keep their lazily created
+    clients, idempotent submission, and retries.
+    """
+
+    def __init__(self):
+        """Initializes the broker, the stream client is created on first use."""
+        self._stream = None
+        self._on_bar = None
+        self._on_trade = None
+        self._trade_symbols = set()
+        self._on_quote = None
+        self._quote_symbols = set()
+
+    def submit_market_order(self, symbol, qty, side, time_in_force=TimeInForce.DAY, client_order_id=None):
+        return broker.place_market_order(
+            symbol=symbol,
+            qty=qty,
+            side=side,
+            time_in_force=time_in_force,
+            client_order_id=client_order_id
+        )
+
+    def submit_notional_order(self, symbol, notional, side, time_in_force=TimeInForce.DAY, client_order_id=None):
+        return broker.place_notional_order(
+            symbol=symbol,
+            notional=notional,
+            side=side,
+            time_in_force=time_in_force,
+            client_order_id=client_order_id
+        )
+
+    def submit_limit_order(self, symbol, qty, side, limit_price, time_in_force=TimeInForce.DAY,
+                           extended_hours=False, client_order_id=None):
+        return broker.place_limit_order(
+            symbol=symbol,
+            qty=qty,
+            side=side,
+            limit_price=limit_price,
+            time_in_force=time_in_force,
+            extended_hours=extended_hours,
+            client_order_id=client_order_id
+        )
+
+    def cancel_order(self, order_id):
+        broker.cancel_order(order_id)
+
+    def get_orders(self, symbol, since=None):
+        return broker.get_orders(symbol, since)
+
+    def get_positions(self):
+        return broker.get_positions()
+
+    def get_account_equity(self):
+        return broker.get_account_equity()
+
+    def get_clock(self):
+        return broker.get_market_clock()
+
+    def get_session(self):
+        clock = self.get_clock()
+        now = clock['timestamp'].astimezone(sessions.EASTERN)
+        try:
+            session_times = trading_calendar.get_calendar().session_times(now.date())
+            # Full-day holidays have no session times and no extended hours either
+            trading_day = session_times is not None
+        except Exception as e:
+            # The fixed session hours are only wrong on early close days and holidays
+            logger.error(f'Error reading trading calendar: {e}')
+            session_times, trading_day = None, True
+        return sessions.get_session(now, clock, session_times, trading_day)
+
+    def get_bars(self, symbols, start, end, timeframe='1Min', limit=None):
+        # Split and dividend adjusted so statistics over history are continuous
+        data = broker.get_historical_bar_data(
+            symbols=symbols,
+            start_date=start,
+            end_date=end,
+            timeframe=timeframe,
+            limit=limit,
+            adjustment='all'
+        )
+        return {symbol: [bar_cache.bar_to_dict(bar) for bar in bars] for symbol, bars in data.items()}
+
+    def get_latest_price(self, symbol):
+        return broker.get_latest_trade(symbol).price
+
+    def is_shortable(self, symbol):
+        return broker.is_shortable(symbol)
+
+    def stream_bars(self, handler, symbols):
+        async def on_bar(bar):
+            await handler(bar_cache.bar_to_dict(bar))
+
+        api_key = tenant.getenv('BROKER_API_KEY')
+        api_secret = tenant.getenv('BROKER_SECRET_KEY')
+        if not api_key or not api_secret:
+            raise ValueError("Broker API credentials are missing in environment variables.")
+        # IEX unless DATA_FEED selects another feed, the sandbox endpoint when DATA_SANDBOX is set
+        feed = broker.data_feed() or DataFeed.IEX
+        self._stream = StockDataStream(
+            api_key, api_secret, feed=feed, url_override=broker.stream_url(f'v2/{feed.value}')
+        )
+        self._on_bar = on_bar
+        self._stream.subscribe_bars(on_bar, *symbols)
+        # Alpaca allows one connection per feed, so trades and quotes share the bar stream's
+        if self._on_trade is not None and self._trade_symbols:
+            self._stream.subscribe_trades(self._on_trade, *self._trade_symbols)
+        if self._on_quote is not None and self._quote_symbols:
+            self._stream.subscribe_quotes(self._on_quote, *self._quote_symbols)
+        self._stream.run()
+
+    def stop_stream(self):
+        if self._stream is not None:
+            self._stream.stop()
+
+    def subscribe_bars(self, symbols):
+        if self._stream is not None and symbols:
+            self._stream.subscribe_bars(self._on_bar, *symbols)
+
+    def unsubscribe_bars(self, symbols):
+        if self._stream is not None and symbols:
+            self._stream.unsubscribe_bars(*symbols)
+
+    def stream_trades(self, handler, symbols):
+        async def on_trade(trade):
+            await handler(converters.trade_from_alpaca(trade).to_dict())
+
+        self._on_trade = on_trade
+        self.subscribe_trades(symbols)
+
+    def subscribe_trades(self, symbols):
+        self._trade_symbols.update(symbols)
+        if self._stream is not None and self._on_trade is not None and symbols:
+            self._stream.subscribe_trades(self._on_trade, *symbols)
+
+    def unsubscribe_trades(self, symbols):
+        self._trade_symbols.difference_update(symbols)
+        if self._stream is not None and symbols:
+            self._stream.unsubscribe_trades(*symbols)
+
+    def stream_quotes(self, handler, symbols):
+        async def on_quote(quote):
+            await handler(converters.quote_from_alpaca(quote).to_dict())
+
+        self._on_quote = on_quote
+        self.subscribe_quotes(symbols)
+
+    def subscribe_quotes(self, symbols):
+        self._quote_symbols.update(symbols)
+        if self._stream is not None and self._on_quote is not None and symbols:
+            self._stream.subscribe_quotes(self._on_quote, *symbols)
+
+    def unsubscribe_quotes(self, symbols):
+        self._quote_symbols.difference_update(symbols)
+        if self._stream is not None and symbols:
+            self._stream.unsubscribe_quotes(*symbols)
+
+
+class MockBroker(Broker):
+    """In-memory broker for tests and backtests.
+
+    Market orders fill immediately at the price set with set_price(), limit
+    orders rest until cancelled, and the clock is whatever the test sets.
+
+    Attributes:
+        prices: Latest price per symbol { symbol: float }
+        bars: Bars returned by get_bars() and replayed by stream_bars() { symbol: [bar dict] }
+        orders: Every order submitted, in submission order
+        positions: Signed quantity per symbol { symbol: float }
+        cash: Cash balance, moved by fills
+        clock: The market clock returned by get_clock()
+        shortable: Symbols that may be sold short, None allows every symbol
+        trade_handler: Handler registered with stream_trades(), called by tests with trade dicts
+        traded_symbols: Symbols whose trades are subscribed
+        quote_handler: Handler registered with stream_quotes(), called by tests with quote dicts
+        quoted_symbols: Symbols whose quotes are subscribed
+    """
+
+    def __init__(self, cash: float = 100_000.0, is_open: bool = True, shortable: Optional[set] = None):
+        """Initializes an empty account.
+
+        Args:
+            cash: Starting cash balance
+            is_open: Whether the market clock reports open
+            shortable: Symbols that may be sold short, None allows every symbol
+        """
+        self.prices = {}
+        self.bars = {}
+        self.orders = []
+        self.positions = {}
+        self.cash = cash
+        self.shortable = shortable
+        now = datetime.now(timezone.utc)
+        self.clock = {
+            'is_open': is_open,
+            'timestamp': now,
+            'next_open': now if is_open else now + timedelta(hours=1),
+            'next_close': now + timedelta(hours=6.5) if is_open else now + timedelta(hours=7.5),
+        }
+        self._ids = count(1)
+        self._streaming = False
+        self.streamed_symbols = set()
+        self.trade_handler = None
+        self.traded_symbols = set()
+        self.quote_handler = None
+        self.quoted_symbols = set()
+
+    def set_price(self, symbol: str, price: float) -> None:
+        """Sets the price market orders in a symbol fill at."""
+        self.prices[symbol] = price
+
+    def _record(self, **order) -> dict:
+        order = {'id': str(next(self._ids)), 'status': 'new', 'submitted_at': datetime.now(timezone.utc), **order}
+        # Replays of a client order ID return the original order, like a real broker
+        if order.get('client_order_id'):
+            for existing in self.orders:
+                if existing.get('client_order_id') == order['client_order_id']:
+                    return existing
+        self.orders.append(order)
+        return order
+
+    def _fill(self, order: dict, qty: float) -> float:
+        price = self.prices.get(order['symbol'])
+        if price is None:
+            raise Exception(f"No price set for {order['symbol']}")
+        signed_qty = qty if order['side'] == OrderSide.BUY else -qty
+        self.positions[order['symbol']] = self.positions.get(order['symbol'], 0.0) + signed_qty
+        if abs(self.positions[order['symbol']]) < 1e-9:
+            del self.positions[order['symbol']]
+        self.cash -= signed_qty * price
+        order.update(status='filled', filled_qty=qty, filled_avg_price=price)
+        return price
+
+    def submit_market_order(self, symbol, qty, side, time_in_force=TimeInForce.DAY, client_order_id=None):
+        self.check_risk(symbol, side, qty=qty)
+        order = self._record(symbol=symbol, qty=qty, side=side, type='market', client_order_id=client_order_id)
+        if order['status'] == 'filled':
+            return order['filled_avg_price']
+        return self._fill(order, qty)
+
+    def submit_notional_order(self, symbol, notional, side, time_in_force=TimeInForce.DAY, client_order_id=None):
+        self.check_risk(symbol, side, notional=notional)
+        order = self._record(symbol=symbol, notional=notional, side=side, type='market', client_order_id=client_order_id)
+        if order['status'] == 'filled':
+            return order['filled_avg_price']
+        if not self.prices.get(symbol):
+            raise Exception(f'No price set for {symbol}')
+        return self._fill(order, notional / self.prices[symbol])
+
+    def submit_limit_order(self, symbol, qty, side, limit_price, time_in_force=TimeInForce.DAY,
+                           extended_hours=False, client_order_id=None):
+        self.check_risk(symbol, side, qty=qty, price=limit_price)
+        return self._record(
+            symbol=symbol, qty=qty, side=side, type='limit', limit_price=limit_price,
+            extended_hours=extended_hours, client_order_id=client_order_id
+        )['id']
+
+    def cancel_order(self, order_id):
+        for order in self.orders:
+            if order['id'] == order_id and order['status'] == 'new':
+                order['status'] = 'canceled'
+                return
+        raise Exception(f'Failed to cancel order {order_id}: not open')
+
+    def get_orders(self, symbol, since=None):
+        return [
+            Order(
+                symbol=order['symbol'],
+                qty=order.get('qty') or 0.0,
+                side=Side(order['side'].value),
+                order_type=order['type'],
+                limit_price=order.get('limit_price'),
+                client_order_id=order.get('client_order_id'),
+                id=order['id'],
+                status=order['status'],
+                filled_qty=order.get('filled_qty', 0.0),
+                filled_avg_price=order.get('filled_avg_price'),
+                submitted_at=order['submitted_at']
+            )
+            for order in self.orders
+            if order['symbol'] == symbol and (
+                order['status'] == 'new' or (since is not None and order['submitted_at'] > since)
+            )
+        ]
+
+    def get_positions(self):
+        return {
+            symbol: {
+                'qty': qty,
+                'market_value': qty * self.prices.get(symbol, 0.0),
+                'current_price': self.prices.get(symbol, 0.0),
+            }
+            for symbol, qty in self.positions.items()
+        }
+
+    def get_account_equity(self):
+        return self.cash + sum(position['market_value'] for position in self.get_positions().values())
+
+    def get_clock(self):
+        return dict(self.clock)
+
+    def get_bars(self, symbols, start, end, timeframe='1Min', limit=None):
+        result = {}
+        for symbol in symbols:
+            bars = [
+                bar for bar in self.bars.get(symbol, [])
+                if start.isoformat() <= bar['timestamp'] <= end.isoformat()
+            ]
+            result[symbol] = bars[:limit] if limit else bars
+        return result
+
+    def get_latest_price(self, symbol):
+        return self.prices.get(symbol)
+
+    def is_shortable(self, symbol):
+        return self.shortable is None or symbol in self.shortable
+
+    def stream_bars(self, handler, symbols):
+        # Replays stored bars in timestamp order, updating prices as a live feed would
+        self._streaming = True
+        self.streamed_symbols = set(symbols)
+        bars = sorted(
+            (bar for symbol_bars in self.bars.values() for bar in symbol_bars),
+            key=lambda bar: bar['timestamp']
+        )
+        for bar in bars:
+            if not self._streaming:
+                break
+            if bar['symbol'] not in self.streamed_symbols:
+                continue
+            self.prices[bar['symbol']] = bar['close']
+            result = handler(bar)
+            if inspect.isawaitable(result):
+                asyncio.run(result)
+        self._streaming = False
+
+    def stop_stream(self):
+        self._streaming = False
+
+    def subscribe_bars(self, symbols):
+        self.streamed_symbols.update(symbols)
+
+    def unsubscribe_bars(self, symbols):
+        self.streamed_symbols.difference_update(symbols)
+
+    def stream_trades(self, handler, symbols):
+        self.trade_handler = handler
+        self.traded_symbols = set(symbols)
+
+    def subscribe_trades(self, symbols):
+        self.traded_symbols.update(symbols)
+
+    def unsubscribe_trades(self, symbols):
+        self.traded_symbols.difference_update(symbols)
+
+    def stream_quotes(self, handler, symbols):
+        self.quote_handler = handler
+        self.quoted_symbols = set(symbols)
+
+    def subscribe_quotes(self, symbols):
+        self.quoted_symbols.update(symbols)
+
+    def unsubscribe_quotes(self, symbols):
+        self.quoted_symbols.difference_update(symbols)
+
+
+# Timeframe strings accepted by get_bars(), as IB bar size settings
+IBKR_BAR_SIZES = {
+    '1Min': '1 min',
+    '5Min': '5 mins',
+    '15Min': '15 mins',
+    '1Hour': '1 hour',
+    '1Day': '1 day',
+}
+
+
+def parse_trading_hours(hours: str, time_zone: str) -> list[tuple[datetime, datetime]]:
+    """
+    Parse IB contract trading hours into sessions.
+
+    Example:
+        parse_trading_hours('20250203:0930-20250203:1600;20250208:CLOSED', 'US/Eastern')
+        [(datetime(2025, 2, 3, 9, 30, tzinfo=...), datetime(2025, 2, 3, 16, 0, tzinfo=...))]
+
+    Args:
+        hours (str): The liquidHours or tradingHours field of a contract's details.
+        time_zone (str): The contract's timeZoneId.
+
+    Returns:
+        list[tuple[datetime, datetime]]: Timezone aware (open, close) pairs
+        ordered by open, closed days omitted.
+    """
+    tz = ZoneInfo(time_zone)
+    sessions = []
+    for session in filter(None, hours.split(';')):
+        if session.endswith(':CLOSED'):
+            continue
+        start, end = session.split('-')
+        sessions.append((
+            datetime.strptime(start, '%Y%m%d:%H%M').replace(tzinfo=tz),
+            datetime.strptime(end, '%Y%m%d:%H%M').replace(tzinfo=tz),
+        ))
+    return sorted(sessions)
+
+
+def clock_from_sessions(sessions: list[tuple[datetime, datetime]], now: datetime) -> dict:
+    """
+    Build a market clock from trading sessions.
+
+    Args:
+        sessions (list[tuple[datetime, datetime]]): Sessions from parse_trading_hours().
+        now (datetime): The current timezone aware time.
+
+    Returns:
+        dict: { 'is_open', 'timestamp', 'next_open', 'next_close' }, next_open and
+        next_close are None when the sessions do not reach far enough ahead.
+    """
+    for open_at, close_at in sessions:
+        if open_at <= now < close_at:
+            later = [session for session in sessions if session[0] > now]
+            return {
+                'is_open': True,
+                'timestamp': now,
+                'next_open': later[0][0] if later else None,
+                'next_close': close_at,
+            }
+        if open_at > now:
+            return {'is_open': False, 'timestamp': now, 'next_open': open_at, 'next_close': close_at}
+    return {'is_open': False, 'timestamp': now, 'next_open': None, 'next_close': None}
+
+
+class IBKRBroker(Broker):
+    """Broker backed by an Interactive Brokers TWS or Gateway session.
+
+    ib_insync is imported on first connection, so it is only required when
+    BROKER=ibkr. IB has no market clock endpoint, the clock is derived from
+    the liquid hours of a reference contract. Futures contract symbols such as 'ESH25'
+    resolve to futures, so bars and orders for them use the same interface.
+
+    Environment Variables:
+        IBKR_HOST (str): TWS/Gateway host. Defaults to 127.0.0.1.
+        IBKR_PORT (int): TWS/Gateway API port. Defaults to 7496 in production, 7497 otherwise.
+        IBKR_CLIENT_ID (int): API client ID, unique per connected service. Defaults to 1.
+        IBKR_ACCOUNT (str): Account to trade and report on. Defaults to the session's account.
+    """
+
+    # Contract whose trading hours define the market clock
+    CLOCK_SYMBOL = 'SPY'
+
+    # Seconds to wait for an order fill or snapshot before giving up
+    WAIT_SECONDS = 5
+
+    def __init__(self):
+        """Initializes the broker, the connection is opened on first use."""
+        self._ib = None
+        self._insync = None
+        self._contracts = {}
+        self._streaming = False
+        self._pending = deque()
+        # Contract details are a slow request, the derived clock is reused
+        self._clock = market_clock.CachedClock(self._fetch_clock)
+
+    def _client(self):
+        """
+        Returns the connected IB session, connecting (or reconnecting) if needed.
+        """
+        if self._ib is None:
+            import ib_insync
+            self._insync = ib_insync
+            self._ib = ib_insync.IB()
+        if not self._ib.isConnected():
+            default_port = 7496 if os.getenv('ENV') == 'production' else 7497
+            try:
+                self._ib.connect(
+                    tenant.getenv('IBKR_HOST', '127.0.0.1'),
+                    int(tenant.getenv('IBKR_PORT', default_port)),
+                    clientId=int(tenant.getenv('IBKR_CLIENT_ID', 1)),
+                    account=tenant.getenv('IBKR_ACCOUNT', '')
+                )
+            except Exception as e:
+                raise Exception(f"Failed to connect to IB gateway: {e}") from e
+        return self._ib
+
+    def _insync_module(self):
+        """
+        Returns the lazily imported ib_insync module.
+        """
+        self._client()
+        return self._insync
+
+    def _contract(self, symbol: str):
+        """
+        Returns the qualified contract for a symbol, cached per symbol.
+        Futures contract symbols (see futures.parse_contract_symbol) resolve to
+        the dated future on the product's exchange, anything else to a US stock.
+        """
+        if symbol not in self._contracts:
+            ib = self._client()
+            if futures.is_contract_symbol(symbol):
+                root, year, month = futures.parse_contract_symbol(symbol)
+                contract = self._insync.Future(
+                    root, f'{year}{month:02d}', futures.CONTRACT_SPECS[root][1], currency='USD'
+                )
+            else:
+                contract = self._insync.Stock(symbol, 'SMART', 'USD')
+            ib.qualifyContracts(contract)
+            self._contracts[symbol] = contract
+        return self._contracts[symbol]
+
+    def get_futures_contract(self, symbol: str) -> FuturesContract:
+        """
+        Returns the listed details of a futures contract, with its exact expiration.
+
+        Raises:
+            Exception: If the contract details cannot be retrieved.
+        """
+        try:
+            details = self._client().reqContractDetails(self._contract(symbol))[0]
+            return converters.futures_contract_from_ib(details)
+        except Exception as e:
+            raise Exception(f"Failed to get futures contract {symbol}: {e}") from e
+
+    def _find_trade(self, client_order_id: Optional[str]):
+        """
+        Returns this session's trade submitted under a client order ID, if any.
+        """
+        if not client_order_id:
+            return None
+        for trade in self._client().trades():
+            if trade.order.orderRef == client_order_id:
+                return trade
+        return None
+
+    def _place(self, symbol: str, order, client_order_id: Optional[str] = None):
+        """
+        Places an order, returning the existing trade for a resubmitted client order ID.
+        """
+        existing = self._find_trade(client_order_id)
+        if existing is not None:
+            logger.info(f'Order {client_order_id} already submitted, skipping resubmission')
+            return existing
+        order.orderRef = client_order_id or ''
+        order.account = tenant.getenv('IBKR_ACCOUNT', '')
+        return self._client().placeOrder(self._contract(symbol), order)
+
+    def _fill_price(self, trade) -> Optional[float]:
+        """
+        Waits briefly for a trade to fill, returning its average price or None.
+        """
+        ib = self._client()
+        deadline = datetime.now(timezone.utc) + timedelta(seconds=self.WAIT_SECONDS)
+        while not trade.isDone() and datetime.now(timezone.utc) < deadline:
+            ib.sleep(0.1)
+        if trade.orderStatus.status == 'Filled':
+            return float(trade.orderStatus.avgFillPrice)
+        logger.warning('Order was placed but filled price is not yet available')
+        return None
+
+    def submit_market_order(self, symbol, qty, side, time_in_force=TimeInForce.DAY, client_order_id=None):
+        self.check_risk(symbol, side, qty=qty)
+        try:
+            order = self._insync_module().MarketOrder(side.value.upper(), qty, tif=time_in_force.value.upper())
+            trade = self._place(symbol, order, client_order_id)
+            logger.info(f"Market order placed for {qty} shares of {symbol} ({side.value})")
+            return self._fill_price(trade)
+        except Exception as e:
+            raise Exception(f"Failed to place market order: {e}") from e
+
+    def submit_notional_order(self, symbol, notional, side, time_in_force=TimeInForce.DAY, client_order_id=None):
+        self.check_risk(symbol, side, notional=notional)
+        try:
+            # IB sizes cash quantity orders itself, the share quantity is left at zero
+            order = self._insync_module().MarketOrder(side.value.upper(), 0, tif=time_in_force.value.upper())
+            order.cashQty = round(notional, 2)
+            trade = self._place(symbol, order, client_order_id)
+            logger.info(f"Notional order placed for ${notional:.2f} of {symbol} ({side.value})")
+            return self._fill_price(trade)
+        except Exception as e:
+            raise Exception(f"Failed to place notional order: {e}") from e
+
+    def submit_limit_order(self, symbol, qty, side, limit_price, time_in_force=TimeInForce.DAY,
+                           extended_hours=False, client_order_id=None):
+        self.check_risk(symbol, side, qty=qty, price=limit_price)
+        try:
+            order = self._insync_module().LimitOrder(
+                side.value.upper(), qty, limit_price, tif=time_in_force.value.upper(), outsideRth=extended_hours
+            )
+            trade = self._place(symbol, order, client_order_id)
+            logger.info(f"Limit order placed for {qty} shares of {symbol} ({side.value}) at ${limit_price}")
+            return str(trade.order.orderId)
+        except Exception as e:
+            raise Exception(f"Failed to place limit order: {e}") from e
+
+    def cancel_order(self, order_id):
+        ib = self._client()
+        for trade in ib.openTrades():
+            if str(trade.order.orderId) == str(order_id):
+                ib.cancelOrder(trade.order)
+                logger.info(f'Cancelled order {order_id}')
+                return
+        raise Exception(f"Failed to cancel order {order_id}: not open")
+
+    def get_orders(self, symbol, since=None):
+        # IB only reports the orders of the connected session and open orders of the account
+        try:
+            orders = []
+            for trade in self._client().trades():
+                if trade.contract.symbol != symbol:
+                    continue
+                submitted_at = converters.as_utc(trade.log[0].time) if trade.log else None
+                is_open = not trade.isDone()
+                if not is_open and (since is None or submitted_at is None or submitted_at <= since):
+                    continue
+                orders.append(Order(
+                    symbol=symbol,
+                    qty=float(trade.order.totalQuantity),
+                    side=Side.BUY if trade.order.action == 'BUY' else Side.SELL,
+                    order_type='limit' if trade.order.orderType == 'LMT' else 'market',
+                    client_order_id=trade.order.orderRef or None,
+                    id=str(trade.order.orderId),
+                    status=trade.orderStatus.status,
+                    filled_qty=float(trade.orderStatus.filled),
+                    submitted_at=submitted_at
+                ))
+            return orders
+        except Exception as e:
+            raise Exception(f"Failed to get orders for {symbol}: {e}") from e
+
+    def get_positions(self):
+        try:
+            account = tenant.getenv('IBKR_ACCOUNT', '')
+            return {
+                item.contract.symbol: {
+                    'qty': float(item.position),
+                    'market_value': float(item.marketValue),
+                    'current_price': float(item.marketPrice),
+                }
+                for item in self._client().portfolio(account)
+                if item.position
+            }
+        except Exception as e:
+            raise Exception(f"Failed to get positions: {e}") from e
+
+    def get_account_equity(self):
+        try:
+            for value in self._client().accountSummary(tenant.getenv('IBKR_ACCOUNT', '')):
+                if value.tag == 'NetLiquidation':
+                    return float(value.value)
+            raise ValueError('NetLiquidation missing from account summary')
+        except Exception as e:
+            raise Exception(f"Failed to get account equity: {e}") from e
+
+    def get_clock(self):
+        return self._clock.get()
+
+    def _fetch_clock(self):
+        try:
+            details = self._client().reqContractDetails(self._contract(self.CLOCK_SYMBOL))[0]
+            sessions = parse_trading_hours(details.liquidHours, details.timeZoneId)
+            return clock_from_sessions(sessions, datetime.now(ZoneInfo(details.timeZoneId)))
+        except Exception as e:
+            raise Exception(f"Failed to get market clock: {e}") from e
+
+    def get_bars(self, symbols, start, end, timeframe='1Min', limit=None):
+        if timeframe not in IBKR_BAR_SIZES:
+            raise ValueError(f'Unsupported timeframe {timeframe}.')
+        seconds = max(int((end - start).total_seconds()), 60)
+        # IB durations above one day must be given in days
+        duration = f'{seconds} S' if seconds <= 86400 else f'{math.ceil(seconds / 86400)} D'
+        result = {}
+        for symbol in symbols:
+            try:
+                bars = self._client().reqHistoricalData(
+                    self._contract(symbol),
+                    endDateTime=end,
+                    durationStr=duration,
+                    barSizeSetting=IBKR_BAR_SIZES[timeframe],
+                    whatToShow='TRADES',
+                    useRTH=False,
+                    formatDate=2
+                )
+            except Exception as e:
+                raise Exception(f"Failed to get historical bars for {symbol}: {e}") from e
+            result[symbol] = [
+                self._bar_to_dict(symbol, bar) for bar in bars
+                if converters.as_utc(bar.date) >= converters.as_utc(start)
+            ]
+            if limit:
+                result[symbol] = result[symbol][:limit]
+        return result
+
+    def get_latest_price(self, symbol):
+        try:
+            ticker = self._client().reqMktData(self._contract(symbol), '', True, False)
+            self._client().sleep(2)
+            price = ticker.last
+            if price is None or math.isnan(price):
+                price = ticker.marketPrice()
+            return None if price is None or math.isnan(price) else float(price)
+        except Exception as e:
+            raise Exception(f"Failed to get latest price of {symbol}: {e}") from e
+
+    def is_shortable(self, symbol):
+        # Generic tick 236 reports shortable shares, above 2.5 means easy to borrow
+        contract = self._contract(symbol)
+        ib = self._client()
+        ticker = ib.reqMktData(contract, '236', False, False)
+        try:
+            ib.sleep(2)
+            return (ticker.shortableShares or 0) > 2.5
+        finally:
+            ib.cancelMktData(contract)
+
+    def stream_bars(self, handler, symbols):
+        ib = self._client()
+        subscriptions = {}
+
+        def on_update(bars, has_new_bar):
+            # The previous bar is complete once a new one starts
+            if has_new_bar and len(bars) >= 2:
+                asyncio.ensure_future(handler(self._bar_to_dict(bars.contract.symbol, bars[-2])))
+
+        def subscribe(symbol):
+            bars = ib.reqHistoricalData(
+                self._contract(symbol),
+                endDateTime='',
+                durationStr='120 S',
+                barSizeSetting='1 min',
+                whatToShow='TRADES',
+                useRTH=False,
+                formatDate=2,
+                keepUpToDate=True
+            )
+            bars.updateEvent += on_update
+            subscriptions[symbol] = bars
+
+        for symbol in symbols:
+            subscribe(symbol)
+        self._streaming = True
+        try:
+            while self._streaming and ib.isConnected():
+                # ib_insync is not thread safe, changes are applied on the streaming thread
+                while self._pending:
+                    action, symbol = self._pending.popleft()
+                    if action == 'subscribe' and symbol not in subscriptions:
+                        subscribe(symbol)
+                    elif action == 'unsubscribe' and symbol in subscriptions:
+                        ib.cancelHistoricalData(subscriptions.pop(symbol))
+                ib.sleep(1)
+        finally:
+            for bars in subscriptions.values():
+                ib.cancelHistoricalData(bars)
+        if self._streaming:
+            self._streaming = False
+            raise Exception('IB gateway disconnected during stream')
+
+    def stop_stream(self):
+        self._streaming = False
+
+    def subscribe_bars(self, symbols):
+        self._pending.extend(('subscribe', symbol) for symbol in symbols)
+
+    def unsubscribe_bars(self, symbols):
+        self._pending.extend(('unsubscribe', symbol) for symbol in symbols)
+
+    def _bar_to_dict(self, symbol: str, bar) -> dict:
+        """
+        Converts an IB bar into the dictionary published on the data topic.
+        """
+        return converters.bar_from_ib(symbol, bar).to_dict()
+
+
+class ObserverBroker(Broker):
+    """Read-only view of another broker used in observer mode.
+
+    Market data, clock, and account reads pass through, every order and
+    cancel raises observer.ExecutionDisabled before reaching the broker.
+
+    Attributes:
+        broker: The wrapped broker
+    """
+
+    def __init__(self, broker: Broker):
+        self.broker = broker
+
+    def submit_market_order(self, symbol, qty, side, time_in_force=TimeInForce.DAY, client_order_id=None):
+        observer.blocked(f'market order for {qty} {symbol}')
+
+    def submit_notional_order(self, symbol, notional, side, time_in_force=TimeInForce.DAY, client_order_id=None):
+        observer.blocked(f'notional order for ${notional} of {symbol}')
+
+    def submit_limit_order(self, symbol, qty, side, limit_price, time_in_force=TimeInForce.DAY,
+                           extended_hours=False, client_order_id=None):
+        observer.blocked(f'limit order for {qty} {symbol}')
+
+    def cancel_order(self, order_id):
+        observer.blocked(f'cancel of order {order_id}')
+
+    def get_orders(self, symbol, since=None):
+        return self.broker.get_orders(symbol, since)
+
+    def get_positions(self):
+        return self.broker.get_positions()
+
+    def get_account_equity(self):
+        return self.broker.get_account_equity()
+
+    def get_clock(self):
+        return self.broker.get_clock()
+
+    def get_bars(self, symbols, start, end, timeframe='1Min', limit=None):
+        return self.broker.get_bars(symbols, start, end, timeframe, limit)
+
+    def get_latest_price(self, symbol):
+        return self.broker.get_latest_price(symbol)
+
+    def is_shortable(self, symbol):
+        return self.broker.is_shortable(symbol)
+
+    def stream_bars(self, handler, symbols):
+        self.broker.stream_bars(handler, symbols)
+
+    def stop_stream(self):
+        self.broker.stop_stream()
+
+    def subscribe_bars(self, symbols):
+        self.broker.subscribe_bars(symbols)
+
+    def unsubscribe_bars(self, symbols):
+        self.broker.unsubscribe_bars(symbols)
+
+    def stream_trades(self, handler, symbols):
+        self.broker.stream_trades(handler, symbols)
+
+    def subscribe_trades(self, symbols):
+        self.broker.subscribe_trades(symbols)
+
+    def unsubscribe_trades(self, symbols):
+        self.broker.unsubscribe_trades(symbols)
+
+    def stream_quotes(self, handler, symbols):
+        self.broker.stream_quotes(handler, symbols)
+
+    def subscribe_quotes(self, symbols):
+        self.broker.subscribe_quotes(symbols)
+
+    def unsubscribe_quotes(self, symbols):
+        self.broker.unsubscribe_quotes(symbols)
+
+
+# Broker implementations selectable with the BROKER environment variable
+BROKERS = {
+    'alpaca': AlpacaBroker,
+    'ibkr': IBKRBroker,
+    'mock': MockBroker,
+}
+
+# Initialize a placeholder for the active broker
+active_broker = None
+
+
+def get_broker() -> Broker:
+    """
+    Returns the active broker.
+    Initializes it from the BROKER environment variable (default 'alpaca')
+    if it hasn't been initialized or set yet, read-only in observer mode.
+    """
+    global active_broker
+    if active_broker is None:
+        name = tenant.getenv('BROKER', 'alpaca').lower()
+        if name not in BROKERS:
+            raise ValueError(f'Unknown broker {name}.')
+        active_broker = BROKERS[name]()
+        if observer.enabled():
+            active_broker = ObserverBroker(active_broker)
+        logger.info(f"Using {name} broker{' in observer mode' if observer.enabled() else ''}")
+    return active_broker
+
+
+def set_broker(new_broker: Optional[Broker]) -> None:
+    """
+    Replaces the active broker, e.g. with a MockBroker in tests and backtests.
+    Passing None resets to the BROKER environment variable on next use.
+    """
+    global active_broker
+    active_broker = new_broker
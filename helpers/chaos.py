@@ -0,0 +1,110 @@
+import os
+import time
+import random
+from helpers import logger
+from typing import Callable
+
+# Initialize logger
+logger = logger.Logger('chaos.py')
+
+
+class InjectedFault(Exception):
+    """A simulated dependency failure, shaped like an HTTP 500 from the broker."""
+
+    def __init__(self, operation: str, status_code: int = 500):
+        super().__init__(f'Injected fault in {operation} (HTTP {status_code})')
+        self.status_code = status_code
+
+
+def enabled() -> bool:
+    """
+    Check if fault injection is active. Faults are never injected in production,
+    whatever CHAOS_ENABLED is set to.
+
+    Returns:
+        bool: True if CHAOS_ENABLED is 'True' and ENV is not production.
+    """
+    return os.getenv('CHAOS_ENABLED') == 'True' and os.getenv('ENV') != 'production'
+
+
+def _percent(name: str) -> float:
+    """
+    Read a percentage setting as a probability between 0 and 1.
+    """
+    return min(max(float(os.getenv(name, 0)), 0), 100) / 100
+
+
+def drop_hook(operation: str, call: Callable):
+    """
+    Silently drop CHAOS_SNS_DROP_PCT percent of calls, as if the message was lost.
+    """
+    if random.random() < _percent('CHAOS_SNS_DROP_PCT'):
+        logger.warning(f'Chaos: dropped {operation}')
+        return {'MessageId': 'chaos-dropped'}
+    return call()
+
+
+def delay_hook(operation: str, call: Callable):
+    """
+    Delay every call by CHAOS_SQS_DELAY_SECONDS before running it.
+    """
+    delay = float(os.getenv('CHAOS_SQS_DELAY_SECONDS', 0))
+    if delay > 0:
+        logger.warning(f'Chaos: delaying {operation} by {delay}s')
+        time.sleep(delay)
+    return call()
+
+
+def error_hook(operation: str, call: Callable):
+    """
+    Fail CHAOS_BROKER_ERROR_PCT percent of calls with an InjectedFault.
+    """
+    if random.random() < _percent('CHAOS_BROKER_ERROR_PCT'):
+        logger.warning(f'Chaos: failing {operation}')
+        raise InjectedFault(operation)
+    return call()
+
+
+class FaultInjectingClient:
+    """Proxy around an SDK client that routes selected methods through fault hooks.
+
+    Attributes not listed in the hooks pass straight through to the wrapped client,
+    a hook registered under '*' applies to every method.
+    """
+
+    def __init__(self, client, hooks: dict):
+        """Wraps a client.
+
+        Args:
+            client: The boto3 or Alpaca client to wrap
+            hooks: { method name: hook(operation, call) }
+        """
+        self._client = client
+        self._hooks = hooks
+
+    def __getattr__(self, name):
+        attribute = getattr(self._client, name)
+        hook = self._hooks.get(name, self._hooks.get('*'))
+        if hook is None or not callable(attribute):
+            return attribute
+
+        def wrapped(*args, **kwargs):
+            return hook(name, lambda: attribute(*args, **kwargs))
+        return wrapped
+
+
+def wrap(client, hooks: dict):
+    """
+    Wrap a client with fault hooks when chaos is enabled.
+
+    Args:
+        client: The client to wrap.
+        hooks (dict): { method name: hook(operation, call) }
+
+    Returns:
+        The client itself when chaos is disabled, otherwise a FaultInjectingClient.
+    """
+    if not enabled():
+        return client
+    logger.warning(f'Chaos enabled for {type(client).__name__} methods {list(hooks)}')
+    return FaultInjectingClient(client, hooks)
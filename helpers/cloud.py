@@ -1,16 +1,24 @@
 import boto3
 import json
 import os
+import time
 import gnupg
+from decimal import Decimal
+from helpers import chaos, envelope, tenant
+from boto3.dynamodb.types import TypeDeserializer, TypeSerializer
 from botocore.exceptions import (
                                  ClientError,
                                  NoCredentialsError,
                                  PartialCredentialsError
                                  )
+from typing import Optional
 
 # Initialize a placeholder for AWS clients
 aws_clients = None
 
+# SNS limit on the combinations of values a subscription filter policy can match
+MAX_FILTER_POLICY_COMBINATIONS = 150
+
 
 def get_aws_clients():
     """
@@ -22,10 +30,14 @@ def get_aws_clients():
         aws_secret_access_key=os.environ.get('AWS_SECRET_ACCESS_KEY'),
         region_name=os.environ.get('REGION')
     )
+    # Fault hooks are no-ops unless chaos testing is enabled outside production
     return {
-        'sns': session.client('sns'),
-        'sqs': session.client('sqs'),
+        'sns': chaos.wrap(session.client('sns'), {'publish': chaos.drop_hook, 'publish_batch': chaos.drop_hook}),
+        'sqs': chaos.wrap(session.client('sqs'), {'receive_message': chaos.delay_hook}),
         'secretsmanager': session.client('secretsmanager'),
+        's3': session.client('s3'),
+        'dynamodb': session.client('dynamodb'),
+        'kinesis': session.client('kinesis'),
     }
 
 
@@ -40,9 +52,15 @@ def get_client(service):
     return aws_clients[service]
 
 
+def _message_attributes(data: str) -> dict:
+    # Subscription filter policies match on these, see sns_filter_policy()
+    return {name: {'DataType': 'String', 'StringValue': value} for name, value in envelope.attributes(data).items()}
+
+
 def publish_sns_message(data: str, topic: str) -> dict:
     """
-    Publish a message to an SNS topic.
+    Publish a message to an SNS topic, with its kind, symbol, and asset class
+    as message attributes.
 
     Args:
         data (str): The message data to publish.
@@ -61,6 +79,7 @@ def publish_sns_message(data: str, topic: str) -> dict:
         response = sns_client.publish(
             TopicArn=topic,
             Message=data,
+            MessageAttributes=_message_attributes(data),
         )
         return response
     except (NoCredentialsError, PartialCredentialsError) as e:
@@ -69,6 +88,66 @@ def publish_sns_message(data: str, topic: str) -> dict:
         raise Exception(f"Failed to publish message to SNS topic: {e}") from e
 
 
+def publish_sns_batch(messages: list[str], topic: str) -> dict:
+    """
+    Publish up to ten messages to an SNS topic in one PublishBatch request,
+    each with its kind, symbol, and asset class as message attributes.
+
+    Args:
+        messages (list[str]): The message bodies, at most ten and 256 KB in total.
+        topic (str): The ARN of the SNS topic.
+
+    Returns:
+        dict: The response from the SNS service, entries that were not published are under 'Failed'.
+
+    Raises:
+        NoCredentialsError: If AWS credentials are not found.
+        PartialCredentialsError: If AWS credentials are incomplete.
+        ClientError: If there is an error publishing the batch.
+    """
+    sns_client = get_client('sns')
+    try:
+        return sns_client.publish_batch(
+            TopicArn=topic,
+            PublishBatchRequestEntries=[
+                {'Id': str(i), 'Message': data, 'MessageAttributes': _message_attributes(data)} for i, data in enumerate(messages)
+            ],
+        )
+    except (NoCredentialsError, PartialCredentialsError) as e:
+        raise Exception('AWS credentials are missing or incomplete.') from e
+    except ClientError as e:
+        raise Exception(f"Failed to publish batch to SNS topic: {e}") from e
+
+
+def publish_kinesis_records(records: list[tuple[str, str]], stream: str) -> dict:
+    """
+    Put up to 500 records on a Kinesis data stream in one PutRecords request.
+
+    Args:
+        records (list[tuple[str, str]]): (data, partition key) pairs, at most 500 and 5 MB in total.
+        stream (str): The ARN or name of the stream.
+
+    Returns:
+        dict: The response from the Kinesis service, records that were not put have an 'ErrorCode'.
+
+    Raises:
+        NoCredentialsError: If AWS credentials are not found.
+        PartialCredentialsError: If AWS credentials are incomplete.
+        ClientError: If there is an error putting the records.
+    """
+    kinesis_client = get_client('kinesis')
+    target = {'StreamARN': stream} if stream.startswith('arn:') else {'StreamName': stream}
+    try:
+        return kinesis_client.put_records(
+            Records=[{'Data': data.encode(), 'PartitionKey': key} for data, key in records],
+            **target,
+        )
+    except (NoCredentialsError, PartialCredentialsError) as e:
+        raise Exception('AWS credentials are missing or incomplete.') from e
+    except ClientError as e:
+        raise Exception(f"Failed to put records on Kinesis stream: {e}") from e
+
+
 def poll_sqs_message(
     queue_url: str,
     max_messages: int = 1,
@@ -98,6 +177,7 @@ def poll_sqs_message(
             QueueUrl=queue_url,
             MaxNumberOfMessages=max_messages,
             WaitTimeSeconds=wait_time_seconds,
+            MessageSystemAttributeNames=['SentTimestamp'],
         )
         return response.get('Messages', [])
     except (NoCredentialsError, PartialCredentialsError) as e:
@@ -106,6 +186,34 @@ def poll_sqs_message(
         raise Exception(f"Failed to poll messages from SQS queue: {e}") from e
 
 
+def send_sqs_message(data: str, queue_url: str) -> dict:
+    """
+    Send a message directly to an SQS queue.
+
+    Args:
+        data (str): The message body.
+        queue_url (str): The URL of the SQS queue.
+
+    Returns:
+        dict: The response from the SQS service.
+
+    Raises:
+        NoCredentialsError: If AWS credentials are not found.
+        PartialCredentialsError: If AWS credentials are incomplete.
+        ClientError: If there is an error sending the message.
+    """
+    sqs_client = get_client('sqs')
+    try:
+        return sqs_client.send_message(
+            QueueUrl=queue_url,
+            MessageBody=data,
+        )
+    except (NoCredentialsError, PartialCredentialsError) as e:
+        raise Exception('AWS credentials are missing or incomplete.') from e
+    except ClientError as e:
+        raise Exception(f"Failed to send message to SQS queue: {e}") from e
+
+
 def delete_sqs_message(queue_url: str, receipt_handle: str) -> None:
     """
     Delete a message from an SQS queue.
@@ -131,13 +239,108 @@ def delete_sqs_message(queue_url: str, receipt_handle: str) -> None:
         raise Exception(f"Failed to delete message from SQS queue: {e}") from e
 
 
-def subscribe_sqs_to_sns(queue_arn: str, topic_arn: str) -> dict:
+def get_queue_attributes(queue_url: str) -> dict:
+    """
+    Retrieve the approximate backlog counters of an SQS queue.
+
+    Args:
+        queue_url (str): The URL of the SQS queue.
+
+    Returns:
+        dict: A dictionary containing:
+        - 'visible': Messages available for retrieval.
+        - 'in_flight': Messages received but not yet deleted.
+        - 'delayed': Messages delayed and not yet available.
+
+    Raises:
+        NoCredentialsError: If AWS credentials are not found.
+        PartialCredentialsError: If AWS credentials are incomplete.
+        ClientError: If there is an error reading the queue attributes.
+    """
+    sqs_client = get_client('sqs')
+    try:
+        response = sqs_client.get_queue_attributes(
+            QueueUrl=queue_url,
+            AttributeNames=[
+                'ApproximateNumberOfMessages',
+                'ApproximateNumberOfMessagesNotVisible',
+                'ApproximateNumberOfMessagesDelayed',
+            ],
+        )
+        attributes = response.get('Attributes', {})
+        return {
+            'visible': int(attributes.get('ApproximateNumberOfMessages', 0)),
+            'in_flight': int(attributes.get('ApproximateNumberOfMessagesNotVisible', 0)),
+            'delayed': int(attributes.get('ApproximateNumberOfMessagesDelayed', 0)),
+        }
+    except (NoCredentialsError, PartialCredentialsError) as e:
+        raise Exception('AWS credentials are missing or incomplete.') from e
+    except ClientError as e:
+        raise Exception(f"Failed to get SQS queue attributes: {e}") from e
+
+
+def publish_alert(subject: str, details: dict) -> None:
+    """
+    Publish an operational alert to the alert SNS topic.
+    Alerts are best effort, if no ALERT_SNS topic is configured
+    the alert is dropped silently so local runs do not need one.
+    Alerts are tagged with the tenant that raised them.
+
+    Args:
+        subject (str): A short description of the alert.
+        details (dict): Structured context for the alert.
+    """
+    topic = tenant.getenv('ALERT_SNS')
+    if not topic:
+        return
+    publish_sns_message(json.dumps({'subject': subject, 'tenant': tenant.get_tenant(), **details}, default=str), topic)
+
+
+def sns_filter_policy(
+    kinds: Optional[list[str]] = None,
+    symbols: Optional[list[str]] = None,
+    asset_classes: Optional[list[str]] = None
+) -> dict:
+    """
+    Build a subscription filter policy on the message attributes published
+    with every message. Messages without a symbol, such as heartbeats, pass
+    the symbol and asset class filters.
+
+    Args:
+        kinds (Optional[list[str]], optional): Envelope kinds to receive, e.g. ['bar', 'heartbeat'].
+        symbols (Optional[list[str]], optional): Symbols to receive.
+        asset_classes (Optional[list[str]], optional): Asset classes to receive, see envelope.asset_class.
+
+    Returns:
+        dict: The filter policy, empty to receive everything.
+
+    Raises:
+        ValueError: If the policy matches more combinations than SNS allows.
+    """
+    policy = {}
+    if kinds:
+        policy['kind'] = sorted(set(kinds))
+    if symbols:
+        policy['symbol'] = sorted(set(symbols)) + [{'exists': False}]
+    if asset_classes:
+        policy['asset_class'] = sorted(set(asset_classes)) + [{'exists': False}]
+    combinations = 1
+    for values in policy.values():
+        combinations *= len(values)
+    if combinations > MAX_FILTER_POLICY_COMBINATIONS:
+        raise ValueError(f'Filter policy matches {combinations} combinations, SNS allows {MAX_FILTER_POLICY_COMBINATIONS}.')
+    return policy
+
+
+def subscribe_sqs_to_sns(queue_arn: str, topic_arn: str, filter_policy: Optional[dict] = None) -> dict:
     """
     Subscribe an SQS queue to an SNS topic.
 
     Args:
         queue_arn (str): The ARN of the SQS queue.
         topic_arn (str): The ARN of the SNS topic.
+        filter_policy (Optional[dict], optional): Filter policy from sns_filter_policy() applied to the
+            subscription, replacing any it had. Defaults to None, leaving the subscription's policy as is.
 
     Returns:
         dict: The response from the SNS service.
@@ -153,7 +356,15 @@ def subscribe_sqs_to_sns(queue_arn: str, topic_arn: str) -> dict:
             Protocol='sqs',
             TopicArn=topic_arn,
             Endpoint=queue_arn,
+            ReturnSubscriptionArn=True,
         )
+        # Set separately, subscribing again with different attributes fails
+        if filter_policy is not None:
+            sns_client.set_subscription_attributes(
+                SubscriptionArn=response['SubscriptionArn'],
+                AttributeName='FilterPolicy',
+                AttributeValue=json.dumps(filter_policy),
+            )
         return response
     except (NoCredentialsError, PartialCredentialsError) as e:
         raise Exception('AWS credentials are missing or incomplete.') from e
@@ -163,6 +374,216 @@ def subscribe_sqs_to_sns(queue_arn: str, topic_arn: str) -> dict:
         ) from e
 
 
+def read_s3_object(uri: str) -> str:
+    """
+    Read a text object from S3.
+
+    Args:
+        uri (str): The object as s3://bucket/key.
+
+    Returns:
+        str: The object's contents decoded as UTF-8.
+
+    Raises:
+        ValueError: If the URI is not an s3:// URI with a bucket and key.
+        NoCredentialsError: If AWS credentials are not found.
+        PartialCredentialsError: If AWS credentials are incomplete.
+        ClientError: If there is an error reading the object.
+    """
+    return read_s3_bytes(uri).decode('utf-8')
+
+
+def read_s3_bytes(uri: str) -> bytes:
+    """
+    Read an object from S3.
+
+    Args:
+        uri (str): The object as s3://bucket/key.
+
+    Returns:
+        bytes: The object's contents.
+
+    Raises:
+        ValueError: If the URI is not an s3:// URI with a bucket and key.
+        NoCredentialsError: If AWS credentials are not found.
+        PartialCredentialsError: If AWS credentials are incomplete.
+        ClientError: If there is an error reading the object.
+    """
+    bucket, _, key = uri.removeprefix('s3://').partition('/')
+    if not uri.startswith('s3://') or not bucket or not key:
+        raise ValueError(f'Expected an s3://bucket/key URI, got {uri}.')
+    s3_client = get_client('s3')
+    try:
+        response = s3_client.get_object(Bucket=bucket, Key=key)
+        return response['Body'].read()
+    except (NoCredentialsError, PartialCredentialsError) as e:
+        raise Exception('AWS credentials are missing or incomplete.') from e
+    except ClientError as e:
+        raise Exception(f"Failed to read S3 object {uri}: {e}") from e
+
+
+def list_s3_objects(prefix: str) -> list[str]:
+    """
+    List the objects under an S3 prefix.
+
+    Args:
+        prefix (str): The prefix as s3://bucket/prefix.
+
+    Returns:
+        list[str]: The objects as s3://bucket/key URIs in key order.
+
+    Raises:
+        ValueError: If the URI is not an s3:// URI with a bucket.
+        NoCredentialsError: If AWS credentials are not found.
+        PartialCredentialsError: If AWS credentials are incomplete.
+        ClientError: If there is an error listing the objects.
+    """
+    bucket, _, key = prefix.removeprefix('s3://').partition('/')
+    if not prefix.startswith('s3://') or not bucket:
+        raise ValueError(f'Expected an s3://bucket/prefix URI, got {prefix}.')
+    s3_client = get_client('s3')
+    try:
+        uris = []
+        for page in s3_client.get_paginator('list_objects_v2').paginate(Bucket=bucket, Prefix=key):
+            uris += [f"s3://{bucket}/{item['Key']}" for item in page.get('Contents', [])]
+        return uris
+    except (NoCredentialsError, PartialCredentialsError) as e:
+        raise Exception('AWS credentials are missing or incomplete.') from e
+    except ClientError as e:
+        raise Exception(f"Failed to list S3 objects under {prefix}: {e}") from e
+
+
+def write_s3_object(uri: str, body: bytes, content_type: str = 'application/octet-stream') -> dict:
+    """
+    Write an object to S3.
+
+    Args:
+        uri (str): The object as s3://bucket/key.
+        body (bytes): The object's contents.
+        content_type (str, optional): The object's content type. Defaults to application/octet-stream.
+
+    Returns:
+        dict: The response from the S3 service.
+
+    Raises:
+        ValueError: If the URI is not an s3:// URI with a bucket and key.
+        NoCredentialsError: If AWS credentials are not found.
+        PartialCredentialsError: If AWS credentials are incomplete.
+        ClientError: If there is an error writing the object.
+    """
+    bucket, _, key = uri.removeprefix('s3://').partition('/')
+    if not uri.startswith('s3://') or not bucket or not key:
+        raise ValueError(f'Expected an s3://bucket/key URI, got {uri}.')
+    s3_client = get_client('s3')
+    try:
+        return s3_client.put_object(Bucket=bucket, Key=key, Body=body, ContentType=content_type)
+    except (NoCredentialsError, PartialCredentialsError) as e:
+        raise Exception('AWS credentials are missing or incomplete.') from e
+    except ClientError as e:
+        raise Exception(f"Failed to write S3 object {uri}: {e}") from e
+
+
+def _to_dynamodb(value) -> dict:
+    # DynamoDB numbers are Decimals, floats are converted through their JSON form
+    return TypeSerializer().serialize(json.loads(json.dumps(value), parse_float=Decimal))
+
+
+def _from_dynamodb(value: dict):
+    value = TypeDeserializer().deserialize(value)
+    return json.loads(json.dumps(value, default=float))
+
+
+def update_dynamodb_item(
+    table: str,
+    key: dict,
+    update_expression: str,
+    values: dict,
+    names: Optional[dict] = None,
+    condition: Optional[str] = None
+) -> bool:
+    """
+    Update an item in a DynamoDB table, creating it if it does not exist.
+
+    Args:
+        table (str): The table name.
+        key (dict): The item's key, e.g. {'symbol': 'AAPL'}.
+        update_expression (str): e.g. 'SET #bar = :bar'.
+        values (dict): Expression values as plain Python values, e.g. {':bar': {...}}.
+        names (Optional[dict], optional): Expression attribute names, e.g. {'#bar': 'bar'}.
+        condition (Optional[str], optional): Condition the existing item must meet.
+
+    Returns:
+        bool: True if the item was updated, False if the condition failed.
+
+    Raises:
+        NoCredentialsError: If AWS credentials are not found.
+        PartialCredentialsError: If AWS credentials are incomplete.
+        ClientError: If there is an error updating the item.
+    """
+    dynamodb_client = get_client('dynamodb')
+    request = {
+        'TableName': table,
+        'Key': {name: _to_dynamodb(value) for name, value in key.items()},
+        'UpdateExpression': update_expression,
+        'ExpressionAttributeValues': {name: _to_dynamodb(value) for name, value in values.items()},
+    }
+    if names:
+        request['ExpressionAttributeNames'] = names
+    if condition:
+        request['ConditionExpression'] = condition
+    try:
+        dynamodb_client.update_item(**request)
+        return True
+    except (NoCredentialsError, PartialCredentialsError) as e:
+        raise Exception('AWS credentials are missing or incomplete.') from e
+    except ClientError as e:
+        if e.response.get('Error', {}).get('Code') == 'ConditionalCheckFailedException':
+            return False
+        raise Exception(f"Failed to update DynamoDB item in {table}: {e}") from e
+
+
+def get_dynamodb_items(table: str, keys: list[dict], max_retries: int = 8, backoff_seconds: float = 0.05) -> list[dict]:
+    """
+    Read up to 100 items from a DynamoDB table by key. Keys DynamoDB leaves
+    unprocessed, when the table is throttled or the response is over its size
+    limit, are requested again with exponential backoff.
+
+    Args:
+        table (str): The table name.
+        keys (list[dict]): The items' keys, e.g. [{'symbol': 'AAPL'}].
+        max_retries (int, optional): Retries of unprocessed keys after the first request. Defaults to 8.
+        backoff_seconds (float, optional): The initial backoff, doubled on every retry. Defaults to 0.05.
+
+    Returns:
+        list[dict]: The items found as plain Python values, numbers as floats, in no particular order.
+
+    Raises:
+        NoCredentialsError: If AWS credentials are not found.
+        PartialCredentialsError: If AWS credentials are incomplete.
+        ClientError: If there is an error reading the items.
+        Exception: If keys are still unprocessed when the retries run out.
+    """
+    if not keys:
+        return []
+    dynamodb_client = get_client('dynamodb')
+    request = {table: {'Keys': [{name: _to_dynamodb(value) for name, value in key.items()} for key in keys]}}
+    items = []
+    try:
+        for attempt in range(max_retries + 1):
+            if attempt:
+                time.sleep(backoff_seconds * (2 ** (attempt - 1)))
+            response = dynamodb_client.batch_get_item(RequestItems=request)
+            items.extend(response.get('Responses', {}).get(table, []))
+            request = response.get('UnprocessedKeys') or {}
+            if not request:
+                return [{name: _from_dynamodb(value) for name, value in item.items()} for item in items]
+    except (NoCredentialsError, PartialCredentialsError) as e:
+        raise Exception('AWS credentials are missing or incomplete.') from e
+    except ClientError as e:
+        raise Exception(f"Failed to read DynamoDB items from {table}: {e}") from e
+    raise Exception(f"Failed to read DynamoDB items from {table}: {len(request[table]['Keys'])} keys unprocessed after {max_retries} retries")
+
+
 def retrieve_secret(secret_name: str) -> dict:
     """
     Retrieve a secret from AWS Secrets Manager.
@@ -0,0 +1,63 @@
+import time
+from threading import Lock
+from helpers import metrics
+from typing import Any, Hashable, Optional
+
+
+class Conflator:
+    """Limits messages to one per key per interval, keeping the latest.
+
+    A key's first message after a quiet interval is released immediately,
+    so a slow symbol's quotes are not delayed. Messages arriving within the
+    interval after one was released are held, each replacing the one held
+    before it, and the latest is released by due() once the interval has
+    passed. A busy symbol is thus published at most once per interval with
+    its most recent state, and the replaced messages are counted in
+    'conflated_messages'.
+
+    Attributes:
+        interval_seconds: Shortest time between two releases of a key
+        released: Monotonic seconds each key was last released
+        held: The latest message waiting per key
+        lock: Thread lock around released and held
+    """
+
+    def __init__(self, interval_seconds: float):
+        """Initializes the conflator with nothing released or held.
+
+        Args:
+            interval_seconds: Shortest time between two releases of a key
+        """
+        if interval_seconds <= 0:
+            raise ValueError('interval_seconds must be positive.')
+        self.interval_seconds = interval_seconds
+        self.released = {}
+        self.held = {}
+        self.lock = Lock()
+
+    def offer(self, key: Hashable, message: Any, now: Optional[float] = None) -> bool:
+        """Offers a message, returning True if it should be published now, False if it is held."""
+        now = time.monotonic() if now is None else now
+        with self.lock:
+            if key not in self.held and now - self.released.get(key, float('-inf')) >= self.interval_seconds:
+                self.released[key] = now
+                return True
+            if key in self.held:
+                metrics.increment('conflated_messages')
+            self.held[key] = message
+            return False
+
+    def due(self, now: Optional[float] = None) -> list:
+        """Releases the held messages whose key's interval has passed."""
+        now = time.monotonic() if now is None else now
+        with self.lock:
+            keys = [key for key in self.held if now - self.released[key] >= self.interval_seconds]
+            for key in keys:
+                self.released[key] = now
+            return [self.held.pop(key) for key in keys]
+
+    def flush(self) -> list:
+        """Releases every held message, at shutdown."""
+        with self.lock:
+            messages, self.held = list(self.held.values()), {}
+            return messages
@@ -0,0 +1,149 @@
+from helpers import broker, logger
+from alpaca.trading.requests import GetCorporateAnnouncementsRequest
+from alpaca.trading.enums import CorporateActionType, CorporateActionDateType
+from datetime import date, datetime, timedelta
+from typing import Optional
+
+# Initialize logger
+logger = logger.Logger('corporate_actions.py')
+
+# The announcements endpoint accepts at most 90 days per request
+MAX_ANNOUNCEMENT_DAYS = 90
+
+
+def _announcement_to_dict(announcement) -> dict:
+    """
+    Flatten an Alpaca corporate action announcement.
+    Splits carry the share ratio (new shares per old share), cash dividends
+    the cash paid per share, other actions carry neither and are only flagged.
+    """
+    ca_type = announcement.ca_type.value if hasattr(announcement.ca_type, 'value') else str(announcement.ca_type)
+    sub_type = announcement.ca_sub_type.value if hasattr(announcement.ca_sub_type, 'value') else announcement.ca_sub_type
+    ratio = None
+    if ca_type == 'split' and announcement.old_rate and announcement.new_rate:
+        ratio = float(announcement.new_rate) / float(announcement.old_rate)
+    cash = float(announcement.cash) if ca_type == 'dividend' and sub_type == 'cash' and announcement.cash else None
+    return {
+        'symbol': announcement.initiating_symbol or announcement.target_symbol,
+        'type': ca_type,
+        'sub_type': sub_type,
+        'ex_date': announcement.ex_date,
+        'ratio': ratio,
+        'cash': cash,
+    }
+
+
+def get_corporate_actions(
+    symbol: str,
+    start: date,
+    end: date,
+    ca_types: tuple = (CorporateActionType.SPLIT, CorporateActionType.DIVIDEND)
+) -> list[dict]:
+    """
+    Retrieve corporate action announcements of a symbol with an ex-date in a range,
+    requesting in windows of at most MAX_ANNOUNCEMENT_DAYS.
+
+    Args:
+        symbol (str): The stock symbol.
+        start (date): The earliest ex-date.
+        end (date): The latest ex-date.
+        ca_types (tuple, optional): CorporateActionTypes to include. Defaults to splits and dividends.
+
+    Returns:
+        list[dict]: Actions as { 'symbol', 'type', 'sub_type', 'ex_date', 'ratio', 'cash' }
+        ordered by ex-date.
+
+    Raises:
+        Exception: If the announcements cannot be retrieved.
+    """
+    trading_client = broker.get_broker_client('trading')
+    actions = []
+    try:
+        for window_start, window_end in broker.split_date_range(start, end, timedelta(days=MAX_ANNOUNCEMENT_DAYS - 1)):
+            announcements = trading_client.get_corporate_announcements(GetCorporateAnnouncementsRequest(
+                ca_types=list(ca_types),
+                since=window_start,
+                until=window_end,
+                symbol=symbol,
+                date_type=CorporateActionDateType.EX_DATE
+            ))
+            actions.extend(_announcement_to_dict(announcement) for announcement in announcements)
+    except Exception as e:
+        raise Exception(f"Failed to get corporate actions for {symbol}: {e}") from e
+    # Windows share their boundary day, so an action on it can be returned twice
+    unique = {(action['type'], action['sub_type'], action['ex_date']): action for action in actions}
+    return sorted(unique.values(), key=lambda action: action['ex_date'])
+
+
+def _as_date(value) -> date:
+    return value.date() if isinstance(value, datetime) else value
+
+
+def actions_in_series(dates: list, actions: list[dict]) -> list[dict]:
+    """
+    Flag the corporate actions whose ex-date falls inside a price series,
+    where an unadjusted series has a jump that is not a price move.
+
+    Args:
+        dates (list): Dates or datetimes of the series, in order.
+        actions (list[dict]): Actions from get_corporate_actions().
+
+    Returns:
+        list[dict]: The actions after the first and up to the last date of the series.
+    """
+    if not dates:
+        return []
+    first, last = _as_date(dates[0]), _as_date(dates[-1])
+    return [action for action in actions if first < action['ex_date'] <= last]
+
+
+def adjustment_factor(dates: list, prices: list[float], action: dict) -> Optional[float]:
+    """
+    The factor prices before an action's ex-date are multiplied by.
+    Splits divide by the share ratio, cash dividends scale by one minus the
+    dividend over the last close before the ex-date.
+
+    Returns:
+        Optional[float]: The factor, None for actions that cannot be adjusted for.
+    """
+    if action['ratio']:
+        return 1 / action['ratio']
+    if action['cash']:
+        before = [price for day, price in zip(dates, prices) if _as_date(day) < action['ex_date']]
+        if before and before[-1] > action['cash']:
+            return 1 - action['cash'] / before[-1]
+    return None
+
+
+def adjust_prices(dates: list, prices: list[float], actions: list[dict]) -> list[float]:
+    """
+    Back-adjust a raw price series for splits and cash dividends, so the
+    series is continuous across ex-dates and the latest prices are unchanged.
+
+    Args:
+        dates (list): Dates or datetimes of the series, in order.
+        prices (list[float]): Raw prices aligned with dates.
+        actions (list[dict]): Actions from get_corporate_actions().
+
+    Returns:
+        list[float]: The adjusted prices.
+
+    Raises:
+        ValueError: If dates and prices differ in length.
+    """
+    if len(dates) != len(prices):
+        raise ValueError('Dates and prices must be the same length.')
+    factors = []
+    for action in actions_in_series(dates, actions):
+        factor = adjustment_factor(dates, prices, action)
+        if factor is None:
+            logger.warning(f"Cannot adjust {action['symbol']} for {action['type']} on {action['ex_date']}")
+            continue
+        factors.append((action['ex_date'], factor))
+    adjusted = []
+    for day, price in zip(dates, prices):
+        for ex_date, factor in factors:
+            if _as_date(day) < ex_date:
+                price *= factor
+        adjusted.append(price)
+    return adjusted
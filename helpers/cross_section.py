@@ -0,0 +1,89 @@
+import math
+from typing import Optional
+
+
+def ranks(values: dict[str, float], ascending: bool = True) -> dict[str, float]:
+    """
+    Rank symbols on a value at one point in time, ties sharing their average rank.
+
+    Args:
+        values (dict[str, float]): { symbol: value }, e.g. the day's return of each symbol.
+        ascending (bool, optional): Rank 1 is the smallest value. Defaults to True.
+
+    Returns:
+        dict[str, float]: { symbol: rank } from 1 to the number of symbols.
+    """
+    ordered = sorted(values, key=values.get, reverse=not ascending)
+    result = {}
+    start = 0
+    while start < len(ordered):
+        end = start
+        while end + 1 < len(ordered) and values[ordered[end + 1]] == values[ordered[start]]:
+            end += 1
+        for symbol in ordered[start:end + 1]:
+            result[symbol] = (start + end) / 2 + 1
+        start = end + 1
+    return result
+
+
+def percentiles(values: dict[str, float]) -> dict[str, float]:
+    """
+    Cross-sectional percentile of each symbol, 0 for the smallest value and 1 for the largest.
+
+    Returns:
+        dict[str, float]: { symbol: percentile }, 0.5 for a single symbol.
+    """
+    if len(values) == 1:
+        return {symbol: 0.5 for symbol in values}
+    return {symbol: (rank - 1) / (len(values) - 1) for symbol, rank in ranks(values).items()}
+
+
+def zscores(values: dict[str, float], clip: Optional[float] = None) -> dict[str, float]:
+    """
+    Cross-sectional z-score of each symbol against the universe mean and standard deviation.
+
+    Args:
+        values (dict[str, float]): { symbol: value }.
+        clip (Optional[float], optional): Clip scores to +/- this many deviations, None for no clipping.
+
+    Returns:
+        dict[str, float]: { symbol: z-score }, 0 for every symbol when the values do not vary.
+    """
+    if len(values) < 2:
+        return {symbol: 0.0 for symbol in values}
+    mean = sum(values.values()) / len(values)
+    deviation = math.sqrt(sum((value - mean) ** 2 for value in values.values()) / (len(values) - 1))
+    if not deviation:
+        return {symbol: 0.0 for symbol in values}
+    scores = {symbol: (value - mean) / deviation for symbol, value in values.items()}
+    if clip is not None:
+        scores = {symbol: max(-clip, min(clip, score)) for symbol, score in scores.items()}
+    return scores
+
+
+def demean(values: dict[str, float]) -> dict[str, float]:
+    """
+    Subtract the universe mean from each value, e.g. returns relative to the equal weighted market.
+    """
+    mean = sum(values.values()) / len(values) if values else 0.0
+    return {symbol: value - mean for symbol, value in values.items()}
+
+
+def quantile_buckets(values: dict[str, float], buckets: int = 5) -> dict[str, int]:
+    """
+    Assign each symbol to an equal sized bucket by value, e.g. quintiles to go
+    long the losers and short the winners.
+
+    Args:
+        values (dict[str, float]): { symbol: value }.
+        buckets (int, optional): Number of buckets. Defaults to 5.
+
+    Returns:
+        dict[str, int]: { symbol: bucket } from 0 for the smallest values to buckets - 1.
+
+    Raises:
+        ValueError: If buckets is not positive.
+    """
+    if buckets < 1:
+        raise ValueError('buckets must be positive.')
+    return {symbol: min(int(percentile * buckets), buckets - 1) for symbol, percentile in percentiles(values).items()}
@@ -0,0 +1,165 @@
+import time
+from datetime import datetime, timezone
+from threading import Lock
+from helpers import cloud, envelope, logger, metrics
+from typing import Callable, Optional
+
+# Initialize logger
+logger = logger.Logger('data_quality.py')
+
+# Problems the monitor reports, 'recovered' follows a 'stale' once the symbol streams again
+ALERT_KINDS = ('gap', 'stale', 'recovered', 'crossed_quote', 'zero_volume')
+
+
+class DataQualityMonitor:
+    """Watches the market data streams for data consumers should not trust.
+
+    Records are observed as they are streamed: quotes whose bid is above the
+    ask and bars without volume are reported straight away, and gaps found
+    by a gaps.GapDetector are passed in through report_gap(). check() is
+    called periodically while the market is open and reports symbols that
+    have not streamed for stale_seconds, once when they go stale and once
+    when they recover. Record problems repeat at most once per symbol and
+    kind per cooldown_seconds so a bad feed cannot flood the topic, every
+    occurrence is still counted in the 'data_quality_issues' metric.
+
+    Methods return the alerts raised, publish() sends them, so callers on
+    an event loop can publish off it.
+
+    Attributes:
+        topic: ARN of the monitoring topic alerts are published to
+        stale_seconds: Seconds without a message before a symbol is stale
+        cooldown_seconds: Shortest interval between repeated alerts of a symbol and kind
+        last_message: { symbol: epoch seconds the latest message was received }
+        stale: Symbols currently reported as stale
+        lock: Thread lock around the tracking state
+        send: Message publish function, replaceable in tests
+    """
+
+    def __init__(
+        self,
+        topic: str,
+        stale_seconds: float = 300,
+        cooldown_seconds: float = 300,
+        send: Callable[[str, str], dict] = cloud.publish_sns_message
+    ):
+        """Initializes the monitor with no symbols seen.
+
+        Args:
+            topic: ARN of the monitoring topic
+            stale_seconds: Seconds without a message before a symbol is stale
+            cooldown_seconds: Shortest interval between repeated alerts of a symbol and kind
+            send: Publish function with cloud.publish_sns_message's signature
+        """
+        self.topic = topic
+        self.stale_seconds = stale_seconds
+        self.cooldown_seconds = cooldown_seconds
+        self.last_message = {}
+        self.stale = set()
+        self.lock = Lock()
+        self.send = send
+        self._since = time.time()
+        self._last_alert = {}  # { (symbol, kind): epoch seconds of the latest alert }
+
+    def reset(self, now: Optional[float] = None) -> None:
+        """Forgets when symbols last streamed, e.g. at the open, so the overnight close is not reported as stale."""
+        with self.lock:
+            self.last_message = {}
+            self.stale = set()
+            self._since = time.time() if now is None else now
+
+    def observe(self, record_type: str, message: dict, now: Optional[float] = None) -> list[dict]:
+        """Records a streamed bar, trade, or quote and checks its values.
+
+        Args:
+            record_type: One of sinks.RECORD_TYPES
+            message: The streamed record
+            now: Epoch seconds the record was received, defaults to the current time
+
+        Returns:
+            list[dict]: The alerts raised, a recovery if the symbol was stale
+        """
+        now = time.time() if now is None else now
+        symbol = message['symbol']
+        alerts = []
+        with self.lock:
+            self.last_message[symbol] = now
+            if symbol in self.stale:
+                self.stale.discard(symbol)
+                alerts.append(_alert('recovered', symbol, now))
+        if record_type == 'quotes' and 0 < message.get('ask_price', 0) < message.get('bid_price', 0):
+            alerts += self._throttled(_alert(
+                'crossed_quote', symbol, now,
+                timestamp=message['timestamp'], bid_price=message['bid_price'], ask_price=message['ask_price']
+            ), now)
+        if record_type == 'bars' and not message.get('volume'):
+            alerts += self._throttled(_alert('zero_volume', symbol, now, timestamp=message['timestamp']), now)
+        return alerts
+
+    def report_gap(self, issue: dict, now: Optional[float] = None) -> list[dict]:
+        """Raises a gap alert from a gaps.GapDetector gap.
+
+        Args:
+            issue: A gap as returned by GapDetector.observe()
+            now: Epoch seconds the gap was found, defaults to the current time
+        """
+        now = time.time() if now is None else now
+        return self._throttled(_alert(
+            'gap', issue['symbol'], now,
+            start=issue['start'].isoformat(), end=issue['end'].isoformat(), missing=issue['missing']
+        ), now)
+
+    def check(self, symbols: list[str], now: Optional[float] = None) -> list[dict]:
+        """Finds symbols that have stopped streaming.
+
+        Symbols never seen are measured from construction or the last reset().
+
+        Args:
+            symbols: The symbols that should be streaming
+            now: Epoch seconds to measure to, defaults to the current time
+
+        Returns:
+            list[dict]: A stale alert per symbol newly gone stale
+        """
+        now = time.time() if now is None else now
+        alerts = []
+        with self.lock:
+            for symbol in symbols:
+                silent = now - self.last_message.get(symbol, self._since)
+                if silent >= self.stale_seconds and symbol not in self.stale:
+                    self.stale.add(symbol)
+                    alerts.append(_alert('stale', symbol, now, silent_seconds=round(silent, 1)))
+        for alert in alerts:
+            metrics.increment('data_quality_issues', kind='stale', symbol=alert['symbol'])
+        metrics.set_gauge('stale_symbols', len(self.stale))
+        return alerts
+
+    def publish(self, alerts: list[dict]) -> None:
+        """Publishes alerts to the monitoring topic in data_quality envelopes, logging rather than raising on failure."""
+        for alert in alerts:
+            logger.warning(f"Data quality {alert['alert']} for {alert['symbol']}: {alert}")
+            try:
+                self.send(envelope.encode('data_quality', alert, source='data'), self.topic)
+            except Exception as e:
+                metrics.increment('data_quality_publish_failures')
+                logger.error(f"Error publishing data quality alert for {alert['symbol']}: {e}")
+
+    def _throttled(self, alert: dict, now: float) -> list[dict]:
+        """Counts an alert, returning it unless the same symbol and kind was alerted within the cooldown."""
+        key = (alert['symbol'], alert['alert'])
+        metrics.increment('data_quality_issues', kind=alert['alert'], symbol=alert['symbol'])
+        with self.lock:
+            last = self._last_alert.get(key)
+            if last is not None and now - last < self.cooldown_seconds:
+                return []
+            self._last_alert[key] = now
+        return [alert]
+
+
+def _alert(kind: str, symbol: str, now: float, **details) -> dict:
+    return {
+        'alert': kind,
+        'symbol': symbol,
+        'detected_at': datetime.fromtimestamp(now, timezone.utc).isoformat(),
+        **details,
+    }
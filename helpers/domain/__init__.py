@@ -0,0 +1,23 @@
+"""
+Core domain types shared by strategies, services, and broker adapters.
+
+Strategies depend only on these types, vendor structs (alpaca, ib_insync)
+are converted at the adapter boundary in converters.py.
+"""
+from .models import (
+    Price,
+    Size,
+    Side,
+    Instrument,
+    FuturesContract,
+    RollSchedule,
+    Bar,
+    Trade,
+    Quote,
+    Snapshot,
+    Signal,
+    Order,
+    Fill,
+)
+
+__all__ = ['Price', 'Size', 'Side', 'Instrument', 'FuturesContract', 'RollSchedule', 'Bar', 'Trade', 'Quote', 'Snapshot', 'Signal', 'Order', 'Fill']
@@ -0,0 +1,161 @@
+"""
+Converters from vendor structs to domain types, used only by broker and feed adapters.
+"""
+from datetime import datetime, timezone
+from .models import Bar, Trade, Quote, Snapshot, Order, Fill, Side, FuturesContract
+
+
+def as_utc(value) -> datetime:
+    """
+    Normalizes vendor timestamps (naive datetimes are UTC, daily IB bars are dates) to aware datetimes.
+    """
+    if not isinstance(value, datetime):
+        value = datetime(value.year, value.month, value.day)
+    return value if value.tzinfo else value.replace(tzinfo=timezone.utc)
+
+
+def _optional_float(value):
+    return float(value) if value is not None else None
+
+
+def bar_from_alpaca(bar) -> Bar:
+    """
+    Convert an alpaca.data.models.Bar.
+    """
+    return Bar(
+        symbol=bar.symbol,
+        timestamp=as_utc(bar.timestamp),
+        open=float(bar.open),
+        high=float(bar.high),
+        low=float(bar.low),
+        close=float(bar.close),
+        volume=float(bar.volume),
+        trade_count=int(bar.trade_count) if bar.trade_count is not None else None,
+        vwap=_optional_float(bar.vwap)
+    )
+
+
+def trade_from_alpaca(trade) -> Trade:
+    """
+    Convert an alpaca.data.models.Trade.
+    """
+    return Trade(
+        symbol=trade.symbol,
+        timestamp=as_utc(trade.timestamp),
+        price=float(trade.price),
+        size=float(trade.size),
+        exchange=str(trade.exchange) if trade.exchange else None,
+        trade_id=getattr(trade, 'id', None)
+    )
+
+
+def quote_from_alpaca(quote) -> Quote:
+    """
+    Convert an alpaca.data.models.Quote.
+    """
+    return Quote(
+        symbol=quote.symbol,
+        timestamp=as_utc(quote.timestamp),
+        bid_price=float(quote.bid_price),
+        bid_size=float(quote.bid_size),
+        ask_price=float(quote.ask_price),
+        ask_size=float(quote.ask_size)
+    )
+
+
+def snapshot_from_alpaca(snapshot) -> Snapshot:
+    """
+    Convert an alpaca.data.models.Snapshot, missing parts stay None.
+    """
+    def convert(value, converter):
+        return converter(value) if value is not None else None
+
+    return Snapshot(
+        symbol=snapshot.symbol,
+        latest_trade=convert(snapshot.latest_trade, trade_from_alpaca),
+        latest_quote=convert(snapshot.latest_quote, quote_from_alpaca),
+        minute_bar=convert(snapshot.minute_bar, bar_from_alpaca),
+        daily_bar=convert(snapshot.daily_bar, bar_from_alpaca),
+        previous_daily_bar=convert(snapshot.previous_daily_bar, bar_from_alpaca)
+    )
+
+
+def order_from_alpaca(order) -> Order:
+    """
+    Convert an alpaca.trading.models.Order, enums become their string values.
+    """
+    return Order(
+        symbol=order.symbol,
+        qty=float(order.qty) if order.qty is not None else 0.0,
+        side=Side(order.side.value),
+        order_type=order.order_type.value if order.order_type else 'market',
+        limit_price=_optional_float(order.limit_price),
+        time_in_force=order.time_in_force.value,
+        extended_hours=bool(order.extended_hours),
+        client_order_id=order.client_order_id,
+        id=str(order.id),
+        status=order.status.value,
+        filled_qty=float(order.filled_qty or 0),
+        filled_avg_price=_optional_float(order.filled_avg_price),
+        submitted_at=as_utc(order.submitted_at) if order.submitted_at else None
+    )
+
+
+def fill_from_alpaca(order) -> Fill:
+    """
+    Convert a filled alpaca.trading.models.Order into its aggregate fill.
+    """
+    return Fill(
+        order_id=str(order.id),
+        symbol=order.symbol,
+        side=Side(order.side.value),
+        qty=float(order.filled_qty),
+        price=float(order.filled_avg_price),
+        timestamp=as_utc(order.filled_at)
+    )
+
+
+def bar_from_ib(symbol: str, bar) -> Bar:
+    """
+    Convert an ib_insync BarData, which does not carry its symbol.
+    """
+    return Bar(
+        symbol=symbol,
+        timestamp=as_utc(bar.date),
+        open=float(bar.open),
+        high=float(bar.high),
+        low=float(bar.low),
+        close=float(bar.close),
+        volume=float(bar.volume),
+        trade_count=int(bar.barCount) if bar.barCount is not None else None,
+        vwap=_optional_float(getattr(bar, 'average', None))
+    )
+
+
+def fill_from_ib(fill) -> Fill:
+    """
+    Convert an ib_insync Fill.
+    """
+    return Fill(
+        order_id=str(fill.execution.orderId),
+        symbol=fill.contract.symbol,
+        side=Side.BUY if fill.execution.side == 'BOT' else Side.SELL,
+        qty=float(fill.execution.shares),
+        price=float(fill.execution.price),
+        timestamp=as_utc(fill.execution.time)
+    )
+
+
+def futures_contract_from_ib(details) -> FuturesContract:
+    """
+    Convert ib_insync ContractDetails of a future, whose last trade date is YYYYMMDD.
+    """
+    contract = details.contract
+    return FuturesContract(
+        symbol=contract.localSymbol,
+        root=contract.symbol,
+        expiration=datetime.strptime(contract.lastTradeDateOrContractMonth[:8], '%Y%m%d').date(),
+        multiplier=float(contract.multiplier or 1),
+        exchange=contract.exchange or None,
+        currency=contract.currency or 'USD'
+    )
@@ -0,0 +1,306 @@
+from dataclasses import dataclass, asdict
+from datetime import date, datetime, timedelta
+from enum import Enum
+from typing import Optional
+
+# Prices and sizes are floats everywhere, vendor decimals and strings are
+# converted at the adapter boundary
+Price = float
+Size = float
+
+
+class Side(str, Enum):
+    """Direction of an order, fill, or signal."""
+    BUY = 'buy'
+    SELL = 'sell'
+
+    @property
+    def sign(self) -> int:
+        """Returns 1 for buys and -1 for sells."""
+        return 1 if self == Side.BUY else -1
+
+
+@dataclass(frozen=True)
+class Instrument:
+    """A tradable instrument.
+
+    Attributes:
+        symbol: Ticker or OCC symbol
+        asset_class: 'us_equity', 'us_option', 'crypto', 'future', or 'fx'
+        exchange: Primary listing exchange, None when unknown
+        currency: Quote currency
+    """
+    symbol: str
+    asset_class: str = 'us_equity'
+    exchange: Optional[str] = None
+    currency: str = 'USD'
+
+
+@dataclass(frozen=True)
+class FuturesContract:
+    """A dated futures contract.
+
+    Attributes:
+        symbol: Contract symbol, root plus month code and two digit year, e.g. 'ESH25'
+        root: Product root, e.g. 'ES'
+        expiration: Last trading day
+        multiplier: Dollars per point of price
+        exchange: Listing exchange, None when unknown
+        currency: Quote currency
+    """
+    symbol: str
+    root: str
+    expiration: date
+    multiplier: float = 1.0
+    exchange: Optional[str] = None
+    currency: str = 'USD'
+
+    @property
+    def instrument(self) -> Instrument:
+        """Returns the contract as a generic instrument."""
+        return Instrument(self.symbol, 'future', self.exchange, self.currency)
+
+
+@dataclass(frozen=True)
+class RollSchedule:
+    """When a continuous futures series moves from one contract to the next.
+
+    Attributes:
+        root: Product root the schedule applies to
+        months: Listed contract months traded by the series, e.g. (3, 6, 9, 12)
+        roll_days_before_expiry: Calendar days before expiration the series rolls
+    """
+    root: str
+    months: tuple = (3, 6, 9, 12)
+    roll_days_before_expiry: int = 8
+
+    def roll_date(self, contract: FuturesContract) -> date:
+        """Returns the first day the series uses the contract after this one."""
+        return contract.expiration - timedelta(days=self.roll_days_before_expiry)
+
+
+@dataclass(frozen=True)
+class Bar:
+    """An OHLCV bar.
+
+    Attributes:
+        symbol: Trading symbol
+        timestamp: Start of the bar, timezone aware
+        open, high, low, close: Prices over the bar
+        volume: Shares traded
+        trade_count: Number of trades, None when the feed does not report it
+        vwap: Volume weighted average price, None when the feed does not report it
+    """
+    symbol: str
+    timestamp: datetime
+    open: Price
+    high: Price
+    low: Price
+    close: Price
+    volume: Size
+    trade_count: Optional[int] = None
+    vwap: Optional[Price] = None
+
+    def to_dict(self) -> dict:
+        """Returns the bar in the dictionary format published on the data topic."""
+        return {
+            'symbol': self.symbol,
+            'timestamp': self.timestamp.isoformat(),
+            'open': self.open,
+            'high': self.high,
+            'low': self.low,
+            'close': self.close,
+            'volume': self.volume,
+            'trade_count': self.trade_count
+        }
+
+    @classmethod
+    def from_dict(cls, data: dict) -> 'Bar':
+        """Builds a bar from the data topic dictionary format, ignoring extra keys."""
+        return cls(
+            symbol=data['symbol'],
+            timestamp=datetime.fromisoformat(data['timestamp']),
+            open=float(data['open']),
+            high=float(data['high']),
+            low=float(data['low']),
+            close=float(data['close']),
+            volume=float(data['volume']),
+            trade_count=data.get('trade_count'),
+            vwap=data.get('vwap')
+        )
+
+
+@dataclass(frozen=True)
+class Trade:
+    """A print on the tape.
+
+    Attributes:
+        symbol: Trading symbol
+        timestamp: Time of the trade, timezone aware
+        price: Trade price
+        size: Shares traded
+        exchange: Reporting exchange, None when unknown
+        trade_id: The feed's id of the trade, unique per symbol and exchange, None when unknown
+    """
+    symbol: str
+    timestamp: datetime
+    price: Price
+    size: Size
+    exchange: Optional[str] = None
+    trade_id: Optional[int] = None
+
+    def to_dict(self) -> dict:
+        """Returns the trade as a JSON serializable dictionary."""
+        return {**asdict(self), 'timestamp': self.timestamp.isoformat()}
+
+
+@dataclass(frozen=True)
+class Quote:
+    """A top of book quote.
+
+    Attributes:
+        symbol: Trading symbol
+        timestamp: Time of the quote, timezone aware
+        bid_price, bid_size: Best bid
+        ask_price, ask_size: Best offer
+    """
+    symbol: str
+    timestamp: datetime
+    bid_price: Price
+    bid_size: Size
+    ask_price: Price
+    ask_size: Size
+
+    def to_dict(self) -> dict:
+        """Returns the quote as a JSON serializable dictionary."""
+        return {**asdict(self), 'timestamp': self.timestamp.isoformat()}
+
+    @property
+    def mid(self) -> Price:
+        """Returns the midpoint of the bid and ask."""
+        return (self.bid_price + self.ask_price) / 2
+
+    @property
+    def spread(self) -> Price:
+        """Returns the ask minus the bid."""
+        return self.ask_price - self.bid_price
+
+
+@dataclass(frozen=True)
+class Snapshot:
+    """The latest market state of a symbol.
+
+    Attributes:
+        symbol: Trading symbol
+        latest_trade: Most recent trade, None when unavailable
+        latest_quote: Most recent quote, None when unavailable
+        minute_bar: Most recent minute bar, None when unavailable
+        daily_bar: Current day's bar, None when unavailable
+        previous_daily_bar: Previous day's bar, None when unavailable
+    """
+    symbol: str
+    latest_trade: Optional[Trade] = None
+    latest_quote: Optional[Quote] = None
+    minute_bar: Optional[Bar] = None
+    daily_bar: Optional[Bar] = None
+    previous_daily_bar: Optional[Bar] = None
+
+
+@dataclass(frozen=True)
+class Signal:
+    """A strategy's decision to trade.
+
+    Attributes:
+        strategy: Name of the strategy that generated the signal
+        symbol: Trading symbol
+        side: Direction of the trade
+        timestamp: Time of the data the signal was generated from
+        qty: Share quantity, None when sized by notional
+        notional: Dollar amount, None when sized by quantity
+        reason: Human readable explanation
+        valid_from: Earliest time the signal may be executed, None for immediately
+        ttl: How long after timestamp the signal may be executed, None for no expiry
+    """
+    strategy: str
+    symbol: str
+    side: Side
+    timestamp: datetime
+    qty: Optional[Size] = None
+    notional: Optional[Price] = None
+    reason: str = ''
+    valid_from: Optional[datetime] = None
+    ttl: Optional[timedelta] = None
+
+    @property
+    def expires_at(self) -> Optional[datetime]:
+        """Returns the time the signal expires, None if it never does."""
+        return self.timestamp + self.ttl if self.ttl is not None else None
+
+    def is_valid(self, now: datetime) -> bool:
+        """Checks if the signal may be executed at a time, inside its validity window."""
+        if self.valid_from is not None and now < self.valid_from:
+            return False
+        return self.expires_at is None or now < self.expires_at
+
+
+@dataclass
+class Order:
+    """An order as submitted to a broker.
+
+    Attributes:
+        symbol: Trading symbol
+        qty: Unsigned quantity
+        side: Direction of the order
+        order_type: 'market', 'limit', 'stop', or 'stop_limit'
+        limit_price: Limit price, None for market orders
+        time_in_force: 'day', 'gtc', 'ioc', ...
+        extended_hours: Whether the order may trade outside RTH
+        client_order_id: Caller assigned idempotency key
+        id: Broker assigned ID, None until submitted
+        status: Broker order status
+        filled_qty: Quantity filled so far
+        filled_avg_price: Average fill price, None until filled
+        submitted_at: When the broker accepted the order, None until submitted
+    """
+    symbol: str
+    qty: Size
+    side: Side
+    order_type: str = 'market'
+    limit_price: Optional[Price] = None
+    time_in_force: str = 'day'
+    extended_hours: bool = False
+    client_order_id: Optional[str] = None
+    id: Optional[str] = None
+    status: str = 'new'
+    filled_qty: Size = 0.0
+    filled_avg_price: Optional[Price] = None
+    submitted_at: Optional[datetime] = None
+
+    @property
+    def signed_qty(self) -> Size:
+        """Returns the quantity, negative for sells."""
+        return self.qty * self.side.sign
+
+
+@dataclass(frozen=True)
+class Fill:
+    """An execution of all or part of an order.
+
+    Attributes:
+        order_id: Broker ID of the filled order
+        symbol: Trading symbol
+        side: Direction of the fill
+        qty: Unsigned quantity filled
+        price: Execution price
+        timestamp: Time of the execution, timezone aware
+    """
+    order_id: str
+    symbol: str
+    side: Side
+    qty: Size
+    price: Price
+    timestamp: datetime
+
+    def to_dict(self) -> dict:
+        """Returns the fill as a JSON serializable dictionary."""
+        return {**asdict(self), 'side': self.side.value, 'timestamp': self.timestamp.isoformat()}
@@ -0,0 +1,78 @@
+import gzip
+import json
+import uuid
+from datetime import datetime, timezone
+from threading import Condition
+from helpers import cloud, metrics
+from typing import Awaitable, Callable, Optional
+
+# Handler receiving message dictionaries from a stream
+MessageHandler = Callable[[dict], Awaitable[None]]
+
+
+class InFlight:
+    """Tracks the stream messages being handled so shutdown can wait for them.
+
+    Stream handlers are wrapped with track(). Once close() is called new
+    messages are refused and counted in 'messages_after_shutdown', while
+    those already being handled run to completion, wait() blocks until they
+    have. Stopping a stream cancels the handlers still running on its event
+    loop, so streams are stopped only after wait() returns.
+
+    Attributes:
+        count: Messages currently being handled
+        closed: Whether new messages are refused
+        condition: Condition variable guarding count and closed
+    """
+
+    def __init__(self):
+        """Initializes the tracker open, with nothing in flight."""
+        self.count = 0
+        self.closed = False
+        self.condition = Condition()
+
+    def track(self, handler: MessageHandler) -> MessageHandler:
+        """Wraps a stream handler so its messages are counted while handled and refused once closed."""
+        async def tracked(message: dict) -> None:
+            with self.condition:
+                if self.closed:
+                    metrics.increment('messages_after_shutdown')
+                    return
+                self.count += 1
+            try:
+                await handler(message)
+            finally:
+                with self.condition:
+                    self.count -= 1
+                    self.condition.notify_all()
+        return tracked
+
+    def close(self) -> None:
+        """Refuses messages from now on."""
+        with self.condition:
+            self.closed = True
+
+    def wait(self, timeout: Optional[float] = None) -> bool:
+        """Blocks until no message is being handled, returning False if the timeout passed first."""
+        with self.condition:
+            return self.condition.wait_for(lambda: self.count == 0, timeout)
+
+
+def spill(prefix: str, messages: list[tuple[str, str]], write: Callable[[str, bytes, str], dict] = cloud.write_s3_object) -> str:
+    """
+    Persist messages that could not be published before shutdown, so they
+    can be republished rather than lost.
+
+    Args:
+        prefix (str): Where to write them as s3://bucket/prefix.
+        messages (list[tuple[str, str]]): (message, topic) pairs.
+        write (Callable[[str, bytes, str], dict], optional): Object write function, replaceable in tests.
+
+    Returns:
+        str: The object written, gzipped JSON lines of { 'topic', 'message' }.
+    """
+    stamp = datetime.now(timezone.utc).strftime('%Y%m%dT%H%M%S')
+    uri = f"{prefix.rstrip('/')}/{stamp}-{uuid.uuid4().hex[:8]}.jsonl.gz"
+    body = ''.join(json.dumps({'topic': topic, 'message': data}) + '\n' for data, topic in messages)
+    write(uri, gzip.compress(body.encode()), 'application/gzip')
+    return uri
@@ -0,0 +1,211 @@
+import os
+import json
+from enum import Enum
+from threading import Lock
+from helpers import admin, cloud, logger, metrics
+from typing import Optional
+
+# Initialize logger
+logger = logger.Logger('drawdown.py')
+
+# Fraction of normal size traded while in the reduced state
+REDUCED_SIZE = 0.5
+
+
+class DrawdownState(str, Enum):
+    """Trading state of a strategy under its drawdown limits."""
+    ACTIVE = 'active'
+    REDUCED = 'reduced'
+    HALTED = 'halted'
+
+
+class DrawdownGuard:
+    """Locks a strategy out of new risk as its equity falls from its high-water mark.
+
+    Equity is the capital allocated to the strategy plus its P&L. After a
+    drawdown of reduce_at_pct new positions are opened at half size until
+    equity makes a new high, after halt_at_pct no new positions are opened
+    until an operator resumes the strategy through the admin API. Orders that
+    only reduce a position are never blocked.
+
+    Attributes:
+        strategy: Name of the guarded strategy
+        capital: Dollar capital allocated to the strategy
+        reduce_at_pct: Drawdown percent that halves position sizes
+        halt_at_pct: Drawdown percent that halts new positions
+        state_file: Optional JSON file the halt is persisted to, so a restart does not resume trading
+        persist_high_water_mark: Whether the high-water mark is persisted with the state and restored on restart
+        equity: Last observed strategy equity
+        high_water_mark: Highest observed strategy equity
+        state: Current DrawdownState
+        lock: Thread lock for updates from the strategy and admin threads
+    """
+
+    def __init__(
+        self,
+        strategy: str,
+        capital: float,
+        reduce_at_pct: float = 5.0,
+        halt_at_pct: float = 10.0,
+        state_file: Optional[str] = None,
+        persist_high_water_mark: bool = False
+    ):
+        """Initializes the guard at its high-water mark, or halted if a persisted halt exists.
+
+        Args:
+            strategy: Name of the guarded strategy
+            capital: Dollar capital allocated to the strategy
+            reduce_at_pct: Drawdown percent that halves position sizes
+            halt_at_pct: Drawdown percent that halts new positions
+            state_file: Optional JSON file the halt is persisted to
+            persist_high_water_mark: Also persist the high-water mark, for guards marked with account equity
+                rather than P&L since they were created, which a restart does not reset
+        """
+        if not 0 < reduce_at_pct < halt_at_pct:
+            raise ValueError('Drawdown thresholds must satisfy 0 < reduce_at_pct < halt_at_pct.')
+        self.strategy = strategy
+        self.capital = capital
+        self.reduce_at_pct = reduce_at_pct
+        self.halt_at_pct = halt_at_pct
+        self.state_file = state_file
+        self.persist_high_water_mark = persist_high_water_mark
+        self.equity = capital
+        self.high_water_mark = capital
+        self.state = DrawdownState.ACTIVE
+        self.lock = Lock()
+        if state_file and os.path.exists(state_file):
+            with open(state_file) as file:
+                persisted = json.load(file)
+            self.state = DrawdownState(persisted.get('state', 'active'))
+            if persist_high_water_mark:
+                self.high_water_mark = max(capital, persisted.get('high_water_mark', capital))
+            if self.state == DrawdownState.HALTED:
+                logger.warning(f'{strategy} is halted from a previous run, resume it through the admin API')
+
+    @property
+    def drawdown_pct(self) -> float:
+        """Returns the percent equity is below the high-water mark."""
+        if self.high_water_mark <= 0:
+            return 0.0
+        return max(0.0, (self.high_water_mark - self.equity) / self.high_water_mark * 100)
+
+    def update(self, pnl: float) -> DrawdownState:
+        """Marks the strategy's equity and moves between states as the drawdown changes.
+
+        Args:
+            pnl: Strategy P&L, realized plus unrealized, since the guard was created
+
+        Returns:
+            DrawdownState: The state after the update
+        """
+        with self.lock:
+            self.equity = self.capital + pnl
+            if self.equity > self.high_water_mark:
+                self.high_water_mark = self.equity
+                if self.persist_high_water_mark:
+                    self._persist()
+            metrics.set_gauge('strategy_drawdown_pct', self.drawdown_pct, strategy=self.strategy)
+
+            # Only an operator can lift a halt
+            if self.state == DrawdownState.HALTED:
+                return self.state
+            if self.drawdown_pct >= self.halt_at_pct:
+                self._transition(DrawdownState.HALTED)
+            elif self.drawdown_pct >= self.reduce_at_pct:
+                if self.state == DrawdownState.ACTIVE:
+                    self._transition(DrawdownState.REDUCED)
+            elif self.state == DrawdownState.REDUCED and self.equity >= self.high_water_mark:
+                self._transition(DrawdownState.ACTIVE)
+            return self.state
+
+    def size_multiplier(self) -> float:
+        """Returns the fraction of normal size new positions may be opened at."""
+        return {
+            DrawdownState.ACTIVE: 1.0,
+            DrawdownState.REDUCED: REDUCED_SIZE,
+            DrawdownState.HALTED: 0.0,
+        }[self.state]
+
+    def resume(self, operator: Optional[str] = None) -> dict:
+        """Lifts a halt or size reduction, resetting the high-water mark to current equity.
+
+        Args:
+            operator: Authenticated name of the operator resuming the strategy, logged with the transition
+
+        Returns:
+            dict: The guard status after resuming, with the operator as 'resumed_by'
+        """
+        with self.lock:
+            logger.warning(f'{self.strategy} resumed by {operator or "an unnamed caller"} from {self.state.value}')
+            self.high_water_mark = self.equity
+            if self.state != DrawdownState.ACTIVE:
+                self._transition(DrawdownState.ACTIVE)
+            elif self.persist_high_water_mark:
+                self._persist()
+        return {**self.status(), 'resumed_by': operator}
+
+    def status(self) -> dict:
+        """Returns the guard's state and drawdown as a JSON serializable dict."""
+        return {
+            'strategy': self.strategy,
+            'state': self.state.value,
+            'equity': round(self.equity, 2),
+            'high_water_mark': round(self.high_water_mark, 2),
+            'drawdown_pct': round(self.drawdown_pct, 2),
+            'reduce_at_pct': self.reduce_at_pct,
+            'halt_at_pct': self.halt_at_pct,
+        }
+
+    def _transition(self, state: DrawdownState) -> None:
+        """Moves to a new state, persisting it and alerting without interrupting the strategy."""
+        previous = self.state
+        self.state = state
+        stats = {**self.status(), 'previous_state': previous.value}
+        logger.warning(f'{self.strategy} drawdown state {previous.value} -> {state.value}: {stats}')
+        metrics.increment('drawdown_transitions', strategy=self.strategy, state=state.value)
+        self._persist()
+        try:
+            cloud.publish_alert(f'Strategy {self.strategy} drawdown {state.value}', stats)
+        except Exception as e:
+            logger.error(f'Error publishing drawdown alert: {e}')
+
+    def _persist(self) -> None:
+        """Writes the state, and the high-water mark if it is persisted, to the state file if there is one."""
+        if not self.state_file:
+            return
+        persisted = {'state': self.state.value}
+        if self.persist_high_water_mark:
+            persisted['high_water_mark'] = self.high_water_mark
+        try:
+            with open(self.state_file, 'w') as file:
+                json.dump(persisted, file)
+        except Exception as e:
+            logger.error(f'Error persisting drawdown state to {self.state_file}: {e}')
+
+
+# Guards of the strategies running in this process { strategy: DrawdownGuard }
+guards = {}
+
+
+def register_guard(guard: DrawdownGuard) -> DrawdownGuard:
+    """
+    Make a guard visible to the admin API.
+    """
+    guards[guard.strategy] = guard
+    return guard
+
+
+def _get_registered_guard(strategy: Optional[str]) -> DrawdownGuard:
+    if strategy not in guards:
+        raise ValueError(f'No drawdown guard for strategy {strategy}.')
+    return guards[strategy]
+
+
+admin.register_route('/drawdown', lambda query, body: {
+    name: guard.status() for name, guard in guards.items()
+})
+admin.register_route(
+    '/drawdown/resume',
+    lambda query, body, identity: _get_registered_guard(body.get('strategy')).resume(identity),
+    method='POST'
+)
@@ -0,0 +1,282 @@
+import gzip
+import json
+import base64
+from datetime import datetime, timezone
+from helpers import proto
+from typing import Optional, Union
+
+# Message kinds published on the bus, with their current schema version
+SCHEMA_VERSIONS = {
+    'bar': 1,
+    'trade': 1,
+    'quote': 1,
+    'news': 1,
+    'scan': 1,
+    'data_quality': 1,
+    'heartbeat': 1,
+}
+
+# Message serializations, proto only covers the kinds in proto.PAYLOAD_FIELDS
+SERIALIZERS = ('json', 'proto')
+
+# Payload compressions, marked in the envelope's contentEncoding
+ENCODINGS = ('gzip', 'zstd')
+
+# Smallest JSON payload worth compressing, smaller ones gain little over the base64 overhead
+MIN_COMPRESS_BYTES = 1024
+
+
+class Encoded(str):
+    """A message as encode() returns it, carrying what transports route and
+    filter it by so they need not decode it again for every publish.
+
+    Attributes:
+        kind: The envelope kind
+        symbol: The payload's symbol, None for messages without one
+        attributes: The message's attributes, see attributes()
+    """
+
+    def __new__(cls, data: str, kind: str, symbol: Optional[str] = None):
+        """Wraps an encoded message with its kind and symbol."""
+        encoded = super().__new__(cls, data)
+        encoded.kind = kind
+        encoded.symbol = symbol
+        encoded.attributes = {'kind': kind}
+        if isinstance(symbol, str):
+            encoded.attributes.update(symbol=symbol, asset_class=asset_class(symbol))
+        return encoded
+
+
+def compress(data: bytes, encoding: str) -> bytes:
+    """
+    Compress bytes with one of ENCODINGS.
+
+    Raises:
+        ValueError: If the encoding is unknown.
+    """
+    if encoding == 'gzip':
+        return gzip.compress(data)
+    if encoding == 'zstd':
+        import zstandard
+        return zstandard.ZstdCompressor().compress(data)
+    raise ValueError(f'Unknown content encoding {encoding}, expected one of {", ".join(ENCODINGS)}.')
+
+
+def decompress(data: bytes, encoding: str) -> bytes:
+    """
+    Decompress bytes from compress().
+
+    Raises:
+        ValueError: If the encoding is unknown.
+    """
+    if encoding == 'gzip':
+        return gzip.decompress(data)
+    if encoding == 'zstd':
+        import zstandard
+        return zstandard.ZstdDecompressor().decompress(data)
+    raise ValueError(f'Unknown content encoding {encoding}, expected one of {", ".join(ENCODINGS)}.')
+
+
+def wrap(kind: str, payload: dict, source: str, build: Optional[str] = None) -> dict:
+    """
+    Wrap a payload in the envelope every bus message is published in.
+
+    Args:
+        kind (str): A key of SCHEMA_VERSIONS.
+        payload (dict): The message, e.g. a bar in the format of bar_cache.bar_to_dict().
+        source (str): The producing service, e.g. 'data'.
+        build (Optional[str], optional): Commit of the producing code, so every trade can be traced back to it.
+
+    Returns:
+        dict: { 'schema', 'producedAt', 'source', 'build', 'payload' }, schema e.g. 'bar.v1'.
+
+    Raises:
+        ValueError: If the kind is unknown.
+    """
+    if kind not in SCHEMA_VERSIONS:
+        raise ValueError(f'Unknown message kind {kind}, expected one of {", ".join(SCHEMA_VERSIONS)}.')
+    return {
+        'schema': f'{kind}.v{SCHEMA_VERSIONS[kind]}',
+        'producedAt': datetime.now(timezone.utc).isoformat(),
+        'source': source,
+        'build': build,
+        'payload': payload,
+    }
+
+
+def encode(
+    kind: str,
+    payload: dict,
+    source: str,
+    build: Optional[str] = None,
+    serializer: str = 'json',
+    compression: Optional[str] = None,
+    min_compress_bytes: int = MIN_COMPRESS_BYTES
+) -> str:
+    """
+    Wrap a payload and serialize it for publishing, see wrap(). Kinds without
+    a protobuf message are published as JSON whatever the serializer.
+
+    A compressed JSON payload is replaced with the base64 of its compressed
+    bytes and the envelope's contentEncoding set, so the schema stays
+    readable without decompressing. Protobuf messages are not compressed.
+    The result is an Encoded string, so transports route it without decoding.
+
+    Args:
+        serializer (str, optional): One of SERIALIZERS. Defaults to json.
+        compression (Optional[str], optional): One of ENCODINGS, None to never compress.
+        min_compress_bytes (int, optional): Only compress payloads at least this large. Defaults to MIN_COMPRESS_BYTES.
+
+    Raises:
+        ValueError: If the kind, serializer, or compression is unknown.
+    """
+    if serializer not in SERIALIZERS:
+        raise ValueError(f'Unknown serializer {serializer}, expected one of {", ".join(SERIALIZERS)}.')
+    if compression is not None and compression not in ENCODINGS:
+        raise ValueError(f'Unknown content encoding {compression}, expected one of {", ".join(ENCODINGS)}.')
+    message = wrap(kind, payload, source, build)
+    symbol = payload.get('symbol')
+    if serializer == 'proto' and kind in proto.PAYLOAD_FIELDS:
+        return Encoded(proto.encode(message), kind, symbol)
+    if compression:
+        body = json.dumps(payload, default=str).encode()
+        if len(body) >= min_compress_bytes:
+            message['contentEncoding'] = compression
+            message['payload'] = base64.b64encode(compress(body, compression)).decode()
+    return Encoded(json.dumps(message, default=str), kind, symbol)
+
+
+def _legacy_kind(payload: dict) -> str:
+    # Messages published before the envelope carried no schema, the fields tell them apart
+    if payload.get('type') in ('news', 'trade', 'quote'):
+        return payload['type']
+    if 'bid_price' in payload:
+        return 'quote'
+    if 'price' in payload and 'close' not in payload:
+        return 'trade'
+    return 'bar'
+
+
+def decode(data: Union[str, dict]) -> dict:
+    """
+    Decode a published message, JSON or base64 protobuf, decompressing a
+    compressed payload and accepting the bare payloads published before the
+    envelope so consumers keep working through a rolling deploy.
+
+    Args:
+        data (Union[str, dict]): The message as published, or already parsed.
+
+    Returns:
+        dict: { 'schema', 'kind', 'version', 'produced_at', 'source', 'build', 'payload' },
+        produced_at a datetime, None with source for legacy messages.
+
+    Raises:
+        ValueError: If the message is not a JSON object or its schema is malformed.
+    """
+    if isinstance(data, str):
+        # JSON starts with a brace or bracket, neither is in the base64 alphabet
+        message = json.loads(data) if data.lstrip()[:1] in ('{', '[') else proto.decode(data)
+    else:
+        message = data
+    if not isinstance(message, dict):
+        raise ValueError('Message is not a JSON object.')
+    if 'schema' not in message:
+        kind = _legacy_kind(message)
+        return {
+            'schema': f'{kind}.v{SCHEMA_VERSIONS[kind]}', 'kind': kind, 'version': SCHEMA_VERSIONS[kind],
+            'produced_at': None, 'source': None, 'build': message.get('build'), 'payload': message,
+        }
+    kind, _, version = message['schema'].partition('.v')
+    if not kind or not version.isdigit():
+        raise ValueError(f"Malformed schema {message['schema']}, expected e.g. 'bar.v1'.")
+    payload = message['payload']
+    if message.get('contentEncoding'):
+        payload = json.loads(decompress(base64.b64decode(payload), message['contentEncoding']))
+    return {
+        'schema': message['schema'],
+        'kind': kind,
+        'version': int(version),
+        'produced_at': datetime.fromisoformat(message['producedAt']) if message.get('producedAt') else None,
+        'source': message.get('source'),
+        'build': message.get('build'),
+        'payload': payload,
+    }
+
+
+def decode_sqs(message: dict) -> dict:
+    """
+    Decode a message received from a queue subscribed to a topic, unwrapping
+    the SNS notification first, see decode().
+
+    Args:
+        message (dict): A message as returned by cloud.poll_sqs_message.
+    """
+    return decode(json.loads(message['Body'])['Message'])
+
+
+def route(data: str) -> tuple[str, Optional[str]]:
+    """
+    Find what a partitioned transport routes a message by, so each symbol's
+    messages stay in order on one partition. Messages straight from encode()
+    carry both, others, such as those read back from the spool, are decoded.
+
+    Args:
+        data (str): A message encoded by encode().
+
+    Returns:
+        tuple[str, Optional[str]]: The kind, 'unknown' when the message cannot be decoded, and the payload's
+        symbol, None for messages without one.
+    """
+    if isinstance(data, Encoded):
+        return data.kind, data.symbol
+    try:
+        message = decode(data)
+    except Exception:
+        return 'unknown', None
+    return message['kind'], message['payload'].get('symbol')
+
+
+def asset_class(symbol: str) -> str:
+    """
+    Classify a streamed symbol in domain.Instrument's asset class vocabulary.
+
+    Args:
+        symbol (str): A ticker, crypto pair, or OCC option symbol.
+
+    Returns:
+        str: 'crypto' for pairs such as 'BTC/USD', 'us_option' for OCC symbols, see
+        options.parse_option_symbol, and 'us_equity' otherwise.
+    """
+    if '/' in symbol:
+        return 'crypto'
+    if len(symbol) >= 16 and symbol[-9] in 'CP' and symbol[-8:].isdigit() and symbol[-15:-9].isdigit():
+        return 'us_option'
+    return 'us_equity'
+
+
+def attributes(data: str) -> dict:
+    """
+    Describe a message for transports that filter on attributes, such as SNS
+    subscription filter policies. Messages straight from encode() carry them,
+    others are decoded.
+
+    Args:
+        data (str): A message encoded by encode().
+
+    Returns:
+        dict: { 'kind', 'symbol', 'asset_class' }, symbol and asset class only for messages about one
+        symbol, empty for messages that are not enveloped, such as alerts.
+    """
+    if isinstance(data, Encoded):
+        return dict(data.attributes)
+    try:
+        message = decode(data)
+    except Exception:
+        return {}
+    if message['source'] is None:
+        return {}
+    described = {'kind': message['kind']}
+    if isinstance(message['payload'].get('symbol'), str):
+        described['symbol'] = message['payload']['symbol']
+        described['asset_class'] = asset_class(described['symbol'])
+    return described
@@ -0,0 +1,195 @@
+import time
+import math
+from datetime import datetime, timedelta
+from helpers import brokers, logger
+from alpaca.data.models import Bar
+from alpaca.trading.enums import OrderSide
+from typing import Callable, Optional
+
+# Initialize logger
+logger = logger.Logger('execution.py')
+
+
+def allocate(total_qty: float, weights: list[float], allow_fractional: bool = False) -> list[float]:
+    """
+    Split a quantity across slices in proportion to weights.
+    Whole-share allocation uses the largest remainder method, so the slices
+    always sum exactly to total_qty.
+
+    Args:
+        total_qty (float): The parent order quantity (positive).
+        weights (list[float]): Relative weight of each slice.
+        allow_fractional (bool, optional): Allow fractional slice quantities.
+                                           Defaults to False.
+
+    Returns:
+        list[float]: The quantity of each slice.
+    """
+    if total_qty <= 0:
+        raise ValueError('Total quantity must be positive.')
+    if not weights or any(weight < 0 for weight in weights):
+        raise ValueError('Weights must be a non-empty list of non-negative values.')
+    weight_sum = sum(weights)
+    if weight_sum == 0:
+        weights = [1.0] * len(weights)
+        weight_sum = float(len(weights))
+    exact = [total_qty * weight / weight_sum for weight in weights]
+    if allow_fractional:
+        return exact
+    quantities = [math.floor(qty) for qty in exact]
+    remainder = int(round(total_qty - sum(quantities)))
+    by_remainder = sorted(range(len(exact)), key=lambda i: exact[i] - quantities[i], reverse=True)
+    for i in by_remainder[:remainder]:
+        quantities[i] += 1
+    return [float(qty) for qty in quantities]
+
+
+def twap_schedule(
+    total_qty: float,
+    horizon_minutes: float,
+    slices: int,
+    start: Optional[datetime] = None,
+    allow_fractional: bool = False
+) -> list[dict]:
+    """
+    Build a time-weighted schedule, equal slices evenly spaced over the horizon.
+
+    Args:
+        total_qty (float): The parent order quantity (positive).
+        horizon_minutes (float): Minutes over which to execute.
+        slices (int): The number of child orders.
+        start (Optional[datetime], optional): When the first slice is sent.
+                                              Defaults to now.
+        allow_fractional (bool, optional): Allow fractional slice quantities.
+                                           Defaults to False.
+
+    Returns:
+        list[dict]: Child orders as { 'at': datetime, 'qty': float }, zero
+        quantity slices omitted.
+    """
+    return _schedule(total_qty, [1.0] * slices, horizon_minutes, start, allow_fractional)
+
+
+def volume_profile(
+    bars: list[Bar],
+    slices: int,
+    horizon_minutes: float,
+    start: datetime
+) -> list[float]:
+    """
+    Estimate the share of volume traded in each slice of the horizon from
+    recent historical bars (e.g. the last 20 days of minute bars), by summing
+    historical volume at the same time of day as each slice.
+
+    Args:
+        bars (list[Bar]): Historical bars with timestamps and volume.
+        slices (int): The number of slices.
+        horizon_minutes (float): Minutes covered by the slices.
+        start (datetime): Start of the first slice, in the same timezone as the bars.
+
+    Returns:
+        list[float]: Volume weights per slice summing to 1, uniform when no
+        historical volume falls inside the horizon.
+    """
+    slice_minutes = horizon_minutes / slices
+    start_minute = start.hour * 60 + start.minute + start.second / 60
+    volumes = [0.0] * slices
+    for bar in bars:
+        offset = (bar.timestamp.hour * 60 + bar.timestamp.minute) - start_minute
+        if 0 <= offset < horizon_minutes:
+            volumes[min(int(offset // slice_minutes), slices - 1)] += bar.volume
+    total = sum(volumes)
+    if total == 0:
+        return [1.0 / slices] * slices
+    return [volume / total for volume in volumes]
+
+
+def vwap_schedule(
+    total_qty: float,
+    horizon_minutes: float,
+    weights: list[float],
+    start: Optional[datetime] = None,
+    allow_fractional: bool = False
+) -> list[dict]:
+    """
+    Build a volume-weighted schedule, slices sized by a volume curve.
+
+    Args:
+        total_qty (float): The parent order quantity (positive).
+        horizon_minutes (float): Minutes over which to execute.
+        weights (list[float]): Volume weights per slice, e.g. from volume_profile().
+        start (Optional[datetime], optional): When the first slice is sent.
+                                              Defaults to now.
+        allow_fractional (bool, optional): Allow fractional slice quantities.
+                                           Defaults to False.
+
+    Returns:
+        list[dict]: Child orders as { 'at': datetime, 'qty': float }, zero
+        quantity slices omitted.
+    """
+    return _schedule(total_qty, weights, horizon_minutes, start, allow_fractional)
+
+
+def _schedule(
+    total_qty: float,
+    weights: list[float],
+    horizon_minutes: float,
+    start: Optional[datetime],
+    allow_fractional: bool
+) -> list[dict]:
+    """
+    Spread allocated slices evenly across the horizon.
+    """
+    if horizon_minutes <= 0:
+        raise ValueError('Horizon must be positive.')
+    start = start or datetime.now()
+    interval = timedelta(minutes=horizon_minutes / len(weights))
+    quantities = allocate(total_qty, weights, allow_fractional)
+    return [
+        {'at': start + interval * i, 'qty': qty}
+        for i, qty in enumerate(quantities)
+        if qty > 0
+    ]
+
+
+def execute_schedule(
+    symbol: str,
+    side: OrderSide,
+    schedule: list[dict],
+    parent_id: Optional[str] = None,
+    sleep: Callable[[float], None] = time.sleep
+) -> list[dict]:
+    """
+    Send each child order of a schedule as a market order at its time.
+    Child orders get client order IDs derived from parent_id, so restarting
+    a partially executed parent does not resend slices that already went out.
+
+    Args:
+        symbol (str): The stock symbol to trade.
+        side (OrderSide): The side of every child order.
+        schedule (list[dict]): Child orders from twap_schedule() or vwap_schedule().
+        parent_id (Optional[str], optional): Identifier of the parent order. Defaults to None.
+        sleep (Callable, optional): Sleep function, replaceable in tests. Defaults to time.sleep.
+
+    Returns:
+        list[dict]: The schedule entries with 'filled_price' added,
+        None where the child order failed or has not filled yet.
+    """
+    results = []
+    for i, child in enumerate(schedule):
+        wait = (child['at'] - datetime.now(child['at'].tzinfo)).total_seconds()
+        if wait > 0:
+            sleep(wait)
+        filled_price = None
+        try:
+            filled_price = brokers.get_broker().submit_market_order(
+                symbol=symbol,
+                qty=child['qty'],
+                side=side,
+                client_order_id=f'{parent_id}-{i}' if parent_id else None
+            )
+        except Exception as e:
+            logger.error(f'Error sending slice {i + 1}/{len(schedule)} of {symbol}: {e}')
+        results.append({**child, 'filled_price': filled_price})
+    logger.info(f'Executed {len(schedule)} slices of {symbol} ({side.value})')
+    return results
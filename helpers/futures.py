@@ -0,0 +1,193 @@
+import calendar
+import re
+from datetime import date, datetime
+from helpers.domain import FuturesContract, RollSchedule
+from typing import Optional
+
+# Contract month codes, January to December
+MONTH_CODES = 'FGHJKMNQUVXZ'
+
+# Known products as { root: (multiplier, exchange) }
+CONTRACT_SPECS = {
+    'ES': (50.0, 'CME'),
+    'NQ': (20.0, 'CME'),
+    'RTY': (50.0, 'CME'),
+    'YM': (5.0, 'CBOT'),
+    'ZN': (1000.0, 'CBOT'),
+    'CL': (1000.0, 'NYMEX'),
+    'GC': (100.0, 'COMEX'),
+}
+
+# Root, month code, two digit year
+CONTRACT_PATTERN = re.compile(r'^([A-Z0-9]{1,3}?)([FGHJKMNQUVXZ])(\d{2})$')
+
+# Back-adjustment methods of continuous series
+ADJUSTMENTS = ('ratio', 'difference', 'none')
+
+
+def contract_symbol(root: str, year: int, month: int) -> str:
+    """
+    Build a contract symbol, e.g. ('ES', 2025, 3) -> 'ESH25'.
+    """
+    return f'{root}{MONTH_CODES[month - 1]}{year % 100:02d}'
+
+
+def parse_contract_symbol(symbol: str) -> tuple[str, int, int]:
+    """
+    Split a contract symbol into its root, year, and month.
+
+    Raises:
+        ValueError: If the symbol is not a futures contract symbol.
+    """
+    match = CONTRACT_PATTERN.match(symbol)
+    if not match:
+        raise ValueError(f'Invalid futures contract symbol {symbol}.')
+    root, code, year = match.groups()
+    return root, 2000 + int(year), MONTH_CODES.index(code) + 1
+
+
+def is_contract_symbol(symbol: str) -> bool:
+    """
+    Checks if a symbol is a contract of a product in CONTRACT_SPECS.
+    """
+    try:
+        return parse_contract_symbol(symbol)[0] in CONTRACT_SPECS
+    except ValueError:
+        return False
+
+
+def third_friday(year: int, month: int) -> date:
+    """
+    The third Friday of a month, the last trading day of equity index futures.
+    """
+    fridays = [week[calendar.FRIDAY] for week in calendar.monthcalendar(year, month) if week[calendar.FRIDAY]]
+    return date(year, month, fridays[2])
+
+
+def contract_chain(schedule: RollSchedule, start: date, end: date) -> list[FuturesContract]:
+    """
+    Contracts of a product expiring from start through one cycle past end,
+    with expirations estimated as the third Friday. Adapters with contract
+    details should prefer the listed expirations.
+
+    Args:
+        schedule (RollSchedule): The product's roll schedule.
+        start (date): The first day the chain must cover.
+        end (date): The last day the chain must cover.
+
+    Returns:
+        list[FuturesContract]: The contracts ordered by expiration.
+    """
+    multiplier, exchange = CONTRACT_SPECS.get(schedule.root, (1.0, None))
+    contracts = []
+    year = start.year
+    while True:
+        for month in sorted(schedule.months):
+            expiration = third_friday(year, month)
+            if expiration < start:
+                continue
+            contracts.append(FuturesContract(
+                symbol=contract_symbol(schedule.root, year, month),
+                root=schedule.root,
+                expiration=expiration,
+                multiplier=multiplier,
+                exchange=exchange
+            ))
+            if expiration > end and len(contracts) > 1:
+                return contracts
+        year += 1
+
+
+def active_contract(contracts: list[FuturesContract], day: date, schedule: RollSchedule) -> Optional[FuturesContract]:
+    """
+    The contract a continuous series uses on a day, the earliest in the
+    schedule's months that has not reached its roll date.
+
+    Returns:
+        Optional[FuturesContract]: The contract, None when every contract has rolled.
+    """
+    for contract in sorted(contracts, key=lambda contract: contract.expiration):
+        if contract.expiration.month in schedule.months and day < schedule.roll_date(contract):
+            return contract
+    return None
+
+
+def _day(bar: dict) -> date:
+    return datetime.fromisoformat(bar['timestamp']).date()
+
+
+def continuous_series(
+    bars_by_contract: dict[str, list[dict]],
+    contracts: list[FuturesContract],
+    schedule: RollSchedule,
+    adjustment: str = 'ratio'
+) -> list[dict]:
+    """
+    Stitch the bars of consecutive contracts into one continuous series,
+    rolling on the schedule's roll dates and back-adjusting earlier prices
+    so the series has no jump at a roll.
+
+    Args:
+        bars_by_contract (dict[str, list[dict]]): Bars per contract symbol, ordered oldest to newest.
+        contracts (list[FuturesContract]): The contracts of the product.
+        schedule (RollSchedule): The product's roll schedule.
+        adjustment (str, optional): 'ratio' scales, 'difference' shifts, and 'none' leaves
+                                    earlier prices unadjusted. Defaults to 'ratio'.
+
+    Returns:
+        list[dict]: Bars with the root as symbol and the source under 'contract'.
+
+    Raises:
+        ValueError: If adjustment is not one of ADJUSTMENTS.
+    """
+    if adjustment not in ADJUSTMENTS:
+        raise ValueError(f'Unsupported adjustment {adjustment}.')
+    series = []
+    for contract in sorted(contracts, key=lambda contract: contract.expiration):
+        bars = [
+            bar for bar in bars_by_contract.get(contract.symbol, [])
+            if active_contract(contracts, _day(bar), schedule) == contract
+        ]
+        if not bars:
+            continue
+        if series and adjustment != 'none':
+            _back_adjust(series, bars_by_contract.get(contract.symbol, []), adjustment)
+        series.extend({**bar, 'symbol': schedule.root, 'contract': contract.symbol} for bar in bars)
+    return series
+
+
+def _back_adjust(series: list[dict], incoming: list[dict], adjustment: str) -> None:
+    """
+    Adjust the series built so far to the price level of the incoming contract,
+    comparing both contracts at the last bar of the outgoing one.
+    """
+    last = series[-1]
+    overlap = [bar for bar in incoming if bar['timestamp'] <= last['timestamp']]
+    if not overlap:
+        return
+    new_close, old_close = overlap[-1]['close'], last['close']
+    for bar in series:
+        for field in ('open', 'high', 'low', 'close'):
+            if field not in bar:
+                continue
+            bar[field] = bar[field] * new_close / old_close if adjustment == 'ratio' else bar[field] + new_close - old_close
+
+
+def calendar_spread(near_bars: list[dict], far_bars: list[dict]) -> list[dict]:
+    """
+    Calendar spread of two contracts, the far close less the near close at
+    each timestamp both traded.
+
+    Returns:
+        list[dict]: { 'timestamp', 'near', 'far', 'spread' } ordered as near_bars.
+    """
+    far_closes = {bar['timestamp']: bar['close'] for bar in far_bars}
+    return [
+        {
+            'timestamp': bar['timestamp'],
+            'near': bar['close'],
+            'far': far_closes[bar['timestamp']],
+            'spread': far_closes[bar['timestamp']] - bar['close'],
+        }
+        for bar in near_bars if bar['timestamp'] in far_closes
+    ]
@@ -0,0 +1,184 @@
+import time
+from threading import Lock
+from helpers import logger, tenant
+from typing import Callable, Optional
+
+# Initialize logger
+logger = logger.Logger('fx.py')
+
+# Currency P&L and limits are reported in unless BASE_CURRENCY is set
+DEFAULT_BASE_CURRENCY = 'USD'
+
+# Currency cross rates are routed through when no direct rate is known
+PIVOT_CURRENCY = 'USD'
+
+# Seconds a fetched rate is reused
+DEFAULT_TTL_SECONDS = 60
+
+
+def get_base_currency() -> str:
+    """
+    Returns the currency P&L, exposure, and risk limits are expressed in,
+    BASE_CURRENCY for the current tenant (default USD).
+    """
+    return tenant.getenv('BASE_CURRENCY', DEFAULT_BASE_CURRENCY).upper()
+
+
+def parse_pairs(value: Optional[str], separator: str = '=') -> dict[str, str]:
+    """
+    Parse comma-separated KEY<separator>VALUE configuration, e.g. 'EURUSD=1.08,GBPUSD=1.27'.
+
+    Raises:
+        ValueError: If an entry has no separator.
+    """
+    pairs = {}
+    for entry in filter(None, (value or '').split(',')):
+        key, found, item = entry.strip().partition(separator)
+        if not found or not key or not item:
+            raise ValueError(f'Invalid entry {entry}, expected KEY{separator}VALUE.')
+        pairs[key.strip().upper()] = item.strip()
+    return pairs
+
+
+def currency_of(symbol: str) -> str:
+    """
+    The quote currency of an instrument.
+    Crypto pairs quote in the currency after the slash ('BTC/EUR' is EUR),
+    other instruments default to USD unless listed in INSTRUMENT_CURRENCIES,
+    e.g. 'SHOP.TO:CAD,VOD.L:GBP'.
+
+    Args:
+        symbol (str): The instrument symbol.
+
+    Returns:
+        str: The ISO currency code.
+    """
+    configured = parse_pairs(tenant.getenv('INSTRUMENT_CURRENCIES'), separator=':')
+    if symbol.upper() in configured:
+        return configured[symbol.upper()].upper()
+    if '/' in symbol:
+        return symbol.rsplit('/', 1)[1].upper()
+    return 'USD'
+
+
+def configured_rates(base: str, quote: str) -> Optional[float]:
+    """
+    Rate source reading FX_RATES, e.g. 'EURUSD=1.08,CADUSD=0.73', where
+    EURUSD=1.08 means one EUR buys 1.08 USD.
+
+    Returns:
+        Optional[float]: Units of quote per unit of base, None if not configured.
+    """
+    rates = parse_pairs(tenant.getenv('FX_RATES'))
+    rate = rates.get(f'{base}{quote}')
+    return float(rate) if rate is not None else None
+
+
+class FXRates:
+    """Exchange rates with inversion, crosses through the pivot currency, and caching.
+
+    Attributes:
+        source: Returns units of quote per unit of base for (base, quote), None if unknown
+        ttl_seconds: Seconds a fetched rate is reused
+        monotonic: Source of elapsed time, replaceable in tests
+        cache: { (base, quote): (fetched_at, rate) }
+        lock: Thread lock around the cache
+    """
+
+    def __init__(
+        self,
+        source: Callable[[str, str], Optional[float]] = configured_rates,
+        ttl_seconds: float = DEFAULT_TTL_SECONDS,
+        monotonic: Callable[[], float] = time.monotonic
+    ):
+        """Initializes an empty cache.
+
+        Args:
+            source: Returns units of quote per unit of base for (base, quote), None if unknown
+            ttl_seconds: Seconds a fetched rate is reused
+            monotonic: Source of elapsed time, replaceable in tests
+        """
+        self.source = source
+        self.ttl_seconds = ttl_seconds
+        self.monotonic = monotonic
+        self.cache = {}
+        self.lock = Lock()
+
+    def _direct(self, base: str, quote: str) -> Optional[float]:
+        """Returns the source's rate or the inverse of its opposite rate, cached."""
+        with self.lock:
+            cached = self.cache.get((base, quote))
+            if cached and self.monotonic() - cached[0] < self.ttl_seconds:
+                return cached[1]
+        rate = self.source(base, quote)
+        if rate is None:
+            inverse = self.source(quote, base)
+            rate = 1 / inverse if inverse else None
+        if rate is not None:
+            with self.lock:
+                self.cache[(base, quote)] = (self.monotonic(), rate)
+        return rate
+
+    def rate(self, base: str, quote: str) -> float:
+        """Returns units of quote per unit of base.
+
+        Raises:
+            ValueError: If neither a direct rate nor a cross through PIVOT_CURRENCY is available.
+        """
+        base, quote = base.upper(), quote.upper()
+        if base == quote:
+            return 1.0
+        rate = self._direct(base, quote)
+        if rate is not None:
+            return rate
+        if PIVOT_CURRENCY not in (base, quote):
+            to_pivot = self._direct(base, PIVOT_CURRENCY)
+            from_pivot = self._direct(PIVOT_CURRENCY, quote)
+            if to_pivot is not None and from_pivot is not None:
+                return to_pivot * from_pivot
+        raise ValueError(f'No FX rate for {base}/{quote}.')
+
+    def convert(self, amount: float, currency: str, to_currency: Optional[str] = None) -> float:
+        """Converts an amount to another currency, the base currency by default."""
+        return amount * self.rate(currency, to_currency or get_base_currency())
+
+
+# Initialize a placeholder for the shared rates
+fx_rates = None
+
+
+def get_rates() -> FXRates:
+    """
+    Returns the shared exchange rates.
+    Initializes them from FX_RATES if they haven't been initialized or set yet.
+    """
+    global fx_rates
+    if fx_rates is None:
+        fx_rates = FXRates()
+    return fx_rates
+
+
+def set_rates(rates: Optional[FXRates]) -> None:
+    """
+    Replaces the shared exchange rates, e.g. with a live source or in tests.
+    Passing None resets to FX_RATES on next use.
+    """
+    global fx_rates
+    fx_rates = rates
+
+
+def to_base(amount: float, symbol: str) -> float:
+    """
+    Converts an amount in an instrument's quote currency to the base currency.
+
+    Args:
+        amount (float): The amount in the quote currency of symbol.
+        symbol (str): The instrument.
+
+    Returns:
+        float: The amount in the base currency.
+
+    Raises:
+        ValueError: If no rate is available.
+    """
+    return get_rates().convert(amount, currency_of(symbol))
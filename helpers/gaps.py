@@ -0,0 +1,83 @@
+from datetime import datetime, timedelta
+from threading import Lock
+from helpers import metrics
+from typing import Optional
+
+
+class GapDetector:
+    """Per-symbol continuity tracking for a market data stream.
+
+    Counts messages per symbol and checks that timestamps only move forward.
+    When the step between consecutive messages is larger than expected (e.g.
+    after a reconnect) the missing interval is reported so it can be
+    backfilled from the historical API. Steps longer than max_gap_seconds are
+    treated as market closures rather than gaps.
+
+    Attributes:
+        interval: Expected spacing between messages of a symbol
+        min_missing: Missing messages before a step is reported as a gap,
+                     illiquid symbols legitimately skip the odd bar
+        max_gap: Steps longer than this are not gaps
+    """
+
+    def __init__(self, interval_seconds: float = 60, min_missing: int = 2, max_gap_seconds: float = 6 * 60 * 60):
+        """Initializes the detector with no symbols seen.
+
+        Args:
+            interval_seconds: Expected spacing between messages of a symbol
+            min_missing: Missing messages before a step is reported as a gap
+            max_gap_seconds: Steps longer than this are not gaps
+        """
+        self.interval = timedelta(seconds=interval_seconds)
+        self.min_missing = min_missing
+        self.max_gap = timedelta(seconds=max_gap_seconds)
+        self._last = {}  # { symbol: datetime of the latest message }
+        self._counts = {}  # { symbol: messages seen }
+        self._lock = Lock()
+
+    def observe(self, symbol: str, timestamp: datetime) -> Optional[dict]:
+        """Records a message and checks it against the symbol's previous one.
+
+        Args:
+            symbol: Trading symbol of the message
+            timestamp: Timestamp of the message
+
+        Returns:
+            Optional[dict]: None when the message is in sequence, otherwise
+            { 'kind': 'gap' or 'out_of_order', 'symbol', 'start', 'end', 'missing' }
+            where start and end are the timestamps either side of the problem.
+        """
+        with self._lock:
+            self._counts[symbol] = self._counts.get(symbol, 0) + 1
+            last = self._last.get(symbol)
+            if last is None:
+                self._last[symbol] = timestamp
+                return None
+            if timestamp <= last:
+                metrics.increment('stream_out_of_order', symbol=symbol)
+                return {'kind': 'out_of_order', 'symbol': symbol, 'start': last, 'end': timestamp, 'missing': 0}
+            self._last[symbol] = timestamp
+        step = timestamp - last
+        missing = int(step / self.interval) - 1
+        if missing < self.min_missing or step > self.max_gap:
+            return None
+        metrics.increment('stream_gaps', symbol=symbol)
+        metrics.increment('stream_gap_missing', missing, symbol=symbol)
+        return {'kind': 'gap', 'symbol': symbol, 'start': last, 'end': timestamp, 'missing': missing}
+
+    def advance(self, symbol: str, timestamp: datetime) -> None:
+        """Moves a symbol's latest timestamp forward, e.g. past backfilled messages."""
+        with self._lock:
+            last = self._last.get(symbol)
+            if last is None or timestamp > last:
+                self._last[symbol] = timestamp
+
+    def last_seen(self, symbol: str) -> Optional[datetime]:
+        """Returns the timestamp of a symbol's latest message, None if never seen."""
+        with self._lock:
+            return self._last.get(symbol)
+
+    def count(self, symbol: str) -> int:
+        """Returns the number of messages seen for a symbol."""
+        with self._lock:
+            return self._counts.get(symbol, 0)
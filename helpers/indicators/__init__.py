@@ -0,0 +1,13 @@
+"""
+Technical indicators over close prices or bars.
+
+Prices are lists of floats, bars are dictionaries in the data topic format
+or domain.Bar objects, oldest first. Every indicator returns values aligned
+with its input, None until enough history has accumulated.
+"""
+from .inputs import closes, highs, lows
+from .trend import sma, ema, macd
+from .oscillators import rsi, stochastic
+from .ranges import true_range, atr
+
+__all__ = ['closes', 'highs', 'lows', 'sma', 'ema', 'macd', 'rsi', 'stochastic', 'true_range', 'atr']
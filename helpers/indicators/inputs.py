@@ -0,0 +1,28 @@
+from typing import Union
+from helpers.domain import Bar
+
+# Prices or bars an indicator is computed over
+Series = list[Union[float, dict, Bar]]
+
+
+def _field(item: Union[float, dict, Bar], name: str) -> float:
+    if isinstance(item, dict):
+        return float(item[name])
+    if isinstance(item, Bar):
+        return float(getattr(item, name))
+    return float(item)
+
+
+def closes(series: Series) -> list[float]:
+    """Returns the close of each bar, or the prices as floats."""
+    return [_field(item, 'close') for item in series]
+
+
+def highs(series: Series) -> list[float]:
+    """Returns the high of each bar, or the prices as floats."""
+    return [_field(item, 'high') for item in series]
+
+
+def lows(series: Series) -> list[float]:
+    """Returns the low of each bar, or the prices as floats."""
+    return [_field(item, 'low') for item in series]
@@ -0,0 +1,62 @@
+from typing import Optional
+from .inputs import Series, closes, highs, lows
+from .trend import sma
+
+
+def rsi(series: Series, period: int = 14) -> list[Optional[float]]:
+    """
+    Relative strength index with Wilder's smoothing of average gains and losses.
+
+    Args:
+        series (Series): Prices or bars, oldest first.
+        period (int, optional): The smoothing period. Defaults to 14.
+
+    Returns:
+        list[Optional[float]]: RSI from 0 to 100 at each point, None for the first
+        period values. 100 when there were no losses, 50 when the price did not move.
+    """
+    values = closes(series)
+    result = [None] * min(period, len(values))
+    if len(values) <= period:
+        return result
+    changes = [current - previous for previous, current in zip(values, values[1:])]
+    average_gain = sum(max(change, 0.0) for change in changes[:period]) / period
+    average_loss = sum(max(-change, 0.0) for change in changes[:period]) / period
+    for i in range(period, len(values)):
+        if i > period:
+            change = changes[i - 1]
+            average_gain = (average_gain * (period - 1) + max(change, 0.0)) / period
+            average_loss = (average_loss * (period - 1) + max(-change, 0.0)) / period
+        if average_loss == 0:
+            result.append(100.0 if average_gain > 0 else 50.0)
+        else:
+            result.append(100 - 100 / (1 + average_gain / average_loss))
+    return result
+
+
+def stochastic(series: Series, k_period: int = 14, d_period: int = 3) -> dict:
+    """
+    Stochastic oscillator: where the close sits in the high-low range of the
+    last k_period bars (%K), and its simple average (%D).
+
+    Args:
+        series (Series): Bars, oldest first. Prices are used as high, low, and close.
+        k_period (int, optional): Bars in the range. Defaults to 14.
+        d_period (int, optional): %K values averaged for %D. Defaults to 3.
+
+    Returns:
+        dict: { 'k', 'd' } from 0 to 100 aligned with the series, None until warm
+        and 50 where the range is flat.
+    """
+    close_values, high_values, low_values = closes(series), highs(series), lows(series)
+    k = []
+    for i, close in enumerate(close_values):
+        if i < k_period - 1:
+            k.append(None)
+            continue
+        highest = max(high_values[i - k_period + 1:i + 1])
+        lowest = min(low_values[i - k_period + 1:i + 1])
+        k.append(100 * (close - lowest) / (highest - lowest) if highest > lowest else 50.0)
+    warm = [value for value in k if value is not None]
+    d = [None] * (len(k) - len(warm)) + sma(warm, d_period)
+    return {'k': k, 'd': d}
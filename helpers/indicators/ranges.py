@@ -0,0 +1,50 @@
+from typing import Optional
+from .inputs import Series, closes, highs, lows
+
+
+def true_range(series: Series) -> list[float]:
+    """
+    True range of each bar: its high-low range extended to the previous close
+    when the bar gapped, the first bar's high less its low.
+
+    Args:
+        series (Series): Bars, oldest first.
+
+    Returns:
+        list[float]: The true range of each bar.
+    """
+    close_values, high_values, low_values = closes(series), highs(series), lows(series)
+    ranges = []
+    for i, (high, low) in enumerate(zip(high_values, low_values)):
+        if i == 0:
+            ranges.append(high - low)
+        else:
+            previous = close_values[i - 1]
+            ranges.append(max(high - low, abs(high - previous), abs(low - previous)))
+    return ranges
+
+
+def atr(series: Series, period: int = 14) -> list[Optional[float]]:
+    """
+    Average true range with Wilder's smoothing, seeded with the mean of the first period true ranges.
+
+    Args:
+        series (Series): Bars, oldest first.
+        period (int, optional): The smoothing period. Defaults to 14.
+
+    Returns:
+        list[Optional[float]]: The ATR at each bar, None for the first period - 1.
+    """
+    ranges = true_range(series)
+    result = []
+    average = None
+    for i, value in enumerate(ranges):
+        if i < period - 1:
+            result.append(None)
+            continue
+        if average is None:
+            average = sum(ranges[:period]) / period
+        else:
+            average = (average * (period - 1) + value) / period
+        result.append(average)
+    return result
@@ -0,0 +1,92 @@
+from typing import Optional
+from .inputs import Series, closes
+
+
+def sma(series: Series, period: int) -> list[Optional[float]]:
+    """
+    Simple moving average of the closes.
+
+    Args:
+        series (Series): Prices or bars, oldest first.
+        period (int): Closes averaged.
+
+    Returns:
+        list[Optional[float]]: The average at each point, None for the first period - 1.
+    """
+    values = closes(series)
+    averages = []
+    total = 0.0
+    for i, value in enumerate(values):
+        total += value
+        if i >= period:
+            total -= values[i - period]
+        averages.append(total / period if i >= period - 1 else None)
+    return averages
+
+
+def _ema(values: list[Optional[float]], period: int) -> list[Optional[float]]:
+    """
+    EMA of a series that may start with None, seeded with the SMA of its first period values.
+    """
+    alpha = 2 / (period + 1)
+    averages = []
+    seed = []
+    average = None
+    for value in values:
+        if value is None:
+            averages.append(None)
+            continue
+        if average is None:
+            seed.append(value)
+            if len(seed) == period:
+                average = sum(seed) / period
+            averages.append(average)
+            continue
+        average = alpha * value + (1 - alpha) * average
+        averages.append(average)
+    return averages
+
+
+def ema(series: Series, period: int) -> list[Optional[float]]:
+    """
+    Exponential moving average of the closes with smoothing 2 / (period + 1),
+    seeded with the simple average of the first period closes.
+
+    Args:
+        series (Series): Prices or bars, oldest first.
+        period (int): The EMA period.
+
+    Returns:
+        list[Optional[float]]: The average at each point, None for the first period - 1.
+    """
+    return _ema(closes(series), period)
+
+
+def macd(series: Series, fast: int = 12, slow: int = 26, signal: int = 9) -> dict:
+    """
+    Moving average convergence divergence: the fast EMA less the slow EMA,
+    its EMA as the signal line, and their difference as the histogram.
+
+    Args:
+        series (Series): Prices or bars, oldest first.
+        fast (int, optional): The fast EMA period. Defaults to 12.
+        slow (int, optional): The slow EMA period. Defaults to 26.
+        signal (int, optional): The signal line EMA period. Defaults to 9.
+
+    Returns:
+        dict: { 'macd', 'signal', 'histogram' }, each aligned with the series.
+    """
+    values = closes(series)
+    line = [
+        fast_value - slow_value if slow_value is not None else None
+        for fast_value, slow_value in zip(_ema(values, fast), _ema(values, slow))
+    ]
+    signal_line = _ema(line, signal)
+    return {
+        'macd': line,
+        'signal': signal_line,
+        'histogram': [
+            value - signal_value if signal_value is not None else None
+            for value, signal_value in zip(line, signal_line)
+        ],
+    }
@@ -0,0 +1,259 @@
+import os
+import json
+import time
+from threading import Lock
+from helpers import envelope, logger, metrics
+from typing import Optional
+
+# Initialize logger
+logger = logger.Logger('kafka.py')
+
+# Producer shared by the publish functions, created on first use
+producer = None
+
+# Seconds a batch waits for its deliveries to be acknowledged
+DELIVERY_TIMEOUT_SECONDS = 10
+
+
+def config() -> dict:
+    """
+    Build the client configuration from the environment.
+
+    Environment Variables:
+        KAFKA_BOOTSTRAP_SERVERS (str): Comma-separated host:port brokers, e.g. an MSK cluster's bootstrap brokers.
+        KAFKA_SECURITY_PROTOCOL (str): Optional 'SSL' or 'SASL_SSL', plaintext otherwise.
+        KAFKA_SASL_MECHANISM (str): Optional SASL mechanism, e.g. 'SCRAM-SHA-512' for MSK.
+        KAFKA_SASL_USERNAME (str): SASL username.
+        KAFKA_SASL_PASSWORD (str): SASL password.
+
+    Returns:
+        dict: librdkafka configuration.
+
+    Raises:
+        ValueError: If KAFKA_BOOTSTRAP_SERVERS is not set.
+    """
+    if not os.getenv('KAFKA_BOOTSTRAP_SERVERS'):
+        raise ValueError('KAFKA_BOOTSTRAP_SERVERS must be set to use Kafka.')
+    settings = {'bootstrap.servers': os.getenv('KAFKA_BOOTSTRAP_SERVERS')}
+    if os.getenv('KAFKA_SECURITY_PROTOCOL'):
+        settings['security.protocol'] = os.getenv('KAFKA_SECURITY_PROTOCOL')
+    if os.getenv('KAFKA_SASL_MECHANISM'):
+        settings['sasl.mechanism'] = os.getenv('KAFKA_SASL_MECHANISM')
+        settings['sasl.username'] = os.getenv('KAFKA_SASL_USERNAME')
+        settings['sasl.password'] = os.getenv('KAFKA_SASL_PASSWORD')
+    return settings
+
+
+def get_producer():
+    """
+    Returns the shared producer, creating it if it hasn't been created yet.
+    """
+    global producer
+    if producer is None:
+        from confluent_kafka import Producer
+        producer = Producer({**config(), 'enable.idempotence': True, 'linger.ms': 5})
+    return producer
+
+
+def topic_name(topic: str, kind: str) -> str:
+    """
+    Name the Kafka topic a kind of message published to a destination goes to, one per message type.
+
+    Args:
+        topic (str): The destination, e.g. DATA_SNS's value such as 'nexus.data'.
+        kind (str): The envelope kind, e.g. 'bar'.
+
+    Returns:
+        str: The Kafka topic, e.g. 'nexus.data.bar'.
+    """
+    return f'{topic}.{kind}'
+
+
+def publish_batch(messages: list[str], topic: str) -> dict:
+    """
+    Publish messages to the Kafka topics of their kinds, keyed by symbol,
+    with cloud.publish_sns_batch's signature so it can replace it.
+
+    Args:
+        messages (list[str]): The message bodies.
+        topic (str): The destination the Kafka topics are named after, see topic_name().
+
+    Returns:
+        dict: The response in SNS PublishBatch's shape, { 'Successful', 'Failed' } entries with the
+        message's index as 'Id', so failures are counted and spooled the same way.
+    """
+    client = get_producer()
+    results = {}
+
+    def delivered(i: int):
+        def callback(error, message):
+            results[i] = {'Id': str(i), 'Code': str(error.code()), 'Message': error.str()} if error else None
+        return callback
+
+    for i, data in enumerate(messages):
+        kind, key = envelope.route(data)
+        try:
+            client.produce(topic_name(topic, kind), value=data.encode(), key=key.encode() if key else None, on_delivery=delivered(i))
+        except BufferError:
+            results[i] = {'Id': str(i), 'Code': 'QueueFull', 'Message': 'Local producer queue is full'}
+    client.flush(DELIVERY_TIMEOUT_SECONDS)
+    successful, failed = [], []
+    for i in range(len(messages)):
+        if i not in results:
+            failed.append({'Id': str(i), 'Code': 'Timeout', 'Message': 'Delivery was not acknowledged in time'})
+        elif results[i]:
+            failed.append(results[i])
+        else:
+            successful.append({'Id': str(i)})
+    return {'Successful': successful, 'Failed': failed}
+
+
+def publish(data: str, topic: str) -> dict:
+    """
+    Publish one message to the Kafka topic of its kind, with
+    cloud.publish_sns_message's signature so it can replace it.
+
+    Args:
+        data (str): The message body.
+        topic (str): The destination the Kafka topic is named after, see topic_name().
+
+    Returns:
+        dict: { 'Id' } of the delivered message.
+
+    Raises:
+        Exception: If Kafka did not acknowledge the message.
+    """
+    response = publish_batch([data], topic)
+    if response['Failed']:
+        raise Exception(f"Failed to publish message to Kafka topic: {response['Failed'][0]['Message']}")
+    return response['Successful'][0]
+
+
+class KafkaPoller:
+    """Receives from Kafka topics as a member of a consumer group.
+
+    Stands in for polling.AdaptivePoller and cloud.delete_sqs_message:
+    poll() returns messages in the shape cloud.poll_sqs_message does, so
+    envelope.decode_sqs and monitoring.QueueLagMonitor read them unchanged,
+    and delete() marks a message handled. A partition's offset is committed
+    up to its oldest message not yet handled, so messages received but not
+    handled before a crash or a rebalance are delivered again, never
+    skipped. Like an SQS visibility timeout, a message left unhandled for
+    visibility_seconds is delivered again by seeking its partition back to
+    it, later messages of the partition with it.
+
+    Attributes:
+        topics: Kafka topics consumed
+        group_id: Consumer group shared by the service's instances
+        max_batch: Most messages per poll
+        wait_seconds: Longest a poll waits for messages
+        visibility_seconds: Seconds a received message waits to be handled before it is delivered again
+        consumer: confluent_kafka.Consumer, replaceable in tests
+    """
+
+    def __init__(
+        self,
+        topics: list[str],
+        group_id: str,
+        max_batch: int = 100,
+        wait_seconds: float = 1,
+        visibility_seconds: float = 30,
+        consumer=None
+    ):
+        """Initializes the poller and subscribes the consumer to the topics.
+
+        Args:
+            topics: Kafka topics to consume, see topic_name()
+            group_id: Consumer group shared by the service's instances
+            max_batch: Most messages per poll
+            wait_seconds: Longest a poll waits for messages
+            visibility_seconds: Seconds a received message waits to be handled before it is delivered again
+            consumer: Consumer with confluent_kafka.Consumer's interface, one is created from config() if None
+        """
+        if consumer is None:
+            from confluent_kafka import Consumer
+            consumer = Consumer({**config(), 'group.id': group_id, 'enable.auto.commit': False, 'auto.offset.reset': 'latest'})
+        self.topics = topics
+        self.group_id = group_id
+        self.max_batch = max_batch
+        self.wait_seconds = wait_seconds
+        self.visibility_seconds = visibility_seconds
+        self.consumer = consumer
+        self.consumer.subscribe(topics, on_revoke=self._revoked)
+        # Messages polled but not yet committed { (topic, partition): { offset: [message, received, handled] } }
+        self._pending = {}
+        self._lock = Lock()
+
+    def poll(self, backlog: Optional[int] = None) -> list:
+        """
+        Receive the next messages, the backlog argument is accepted for AdaptivePoller's interface.
+
+        Returns:
+            list: { 'MessageId', 'ReceiptHandle', 'Body', 'Attributes' } messages, possibly empty.
+        """
+        self._redeliver_expired()
+        messages = []
+        for message in self.consumer.consume(num_messages=self.max_batch, timeout=self.wait_seconds):
+            if message.error():
+                logger.error(f'Error consuming from Kafka: {message.error()}')
+                continue
+            handle = f'{message.topic()}:{message.partition()}:{message.offset()}'
+            with self._lock:
+                self._pending.setdefault((message.topic(), message.partition()), {})[message.offset()] = [message, time.monotonic(), False]
+            messages.append({
+                'MessageId': handle,
+                'ReceiptHandle': handle,
+                'Body': json.dumps({'Message': message.value().decode()}),
+                'Attributes': {'SentTimestamp': str(message.timestamp()[1])},
+            })
+        metrics.increment('kafka_messages', len(messages), group=self.group_id)
+        return messages
+
+    def delete(self, receipt_handle: str) -> None:
+        """
+        Marks a message handled, committing its partition's offset past every
+        message handled without a gap. Handles of messages since delivered
+        again or of partitions revoked in a rebalance are ignored.
+        """
+        topic, partition, offset = receipt_handle.rsplit(':', 2)
+        last = None
+        with self._lock:
+            pending = self._pending.get((topic, int(partition)), {})
+            if int(offset) not in pending:
+                return
+            pending[int(offset)][2] = True
+            while pending and next(iter(pending.values()))[2]:
+                last = pending.pop(next(iter(pending)))[0]
+        if last is not None:
+            self.consumer.commit(message=last, asynchronous=True)
+
+    def _redeliver_expired(self) -> None:
+        """Seeks each partition back to its oldest message left unhandled for visibility_seconds."""
+        now = time.monotonic()
+        with self._lock:
+            for (topic, partition), pending in self._pending.items():
+                expired = next(
+                    (offset for offset, (_, received, handled) in pending.items() if not handled and now - received >= self.visibility_seconds),
+                    None
+                )
+                if expired is None:
+                    continue
+                from confluent_kafka import TopicPartition
+                self.consumer.seek(TopicPartition(topic, partition, expired))
+                self._pending[(topic, partition)] = {offset: entry for offset, entry in pending.items() if offset < expired}
+                metrics.increment('kafka_redelivered', group=self.group_id, topic=topic)
+                logger.warning(f'{topic} partition {partition} offset {expired} was not handled in {self.visibility_seconds}s, delivering it again')
+
+    def _revoked(self, consumer, partitions) -> None:
+        """Forgets the messages of partitions assigned away in a rebalance, their new owner receives them."""
+        with self._lock:
+            for partition in partitions:
+                self._pending.pop((partition.topic, partition.partition), None)
+
+    def lag(self) -> int:
+        """Returns the messages published to the assigned partitions that the group has not received yet."""
+        total = 0
+        for position in self.consumer.position(self.consumer.assignment()):
+            low, high = self.consumer.get_watermark_offsets(position, cached=False)
+            total += high - (position.offset if position.offset >= 0 else low)
+        return total
@@ -0,0 +1,68 @@
+import hashlib
+from helpers import cloud, envelope
+
+# Kinesis limits on a single PutRecords call
+MAX_KINESIS_BATCH = 500
+MAX_KINESIS_BATCH_BYTES = 5 * 1024 * 1024
+
+
+def partition_key(data: str) -> str:
+    """
+    Choose the shard a message goes to, keeping each symbol's messages in
+    order on one shard.
+
+    Args:
+        data (str): A message encoded by envelope.encode.
+
+    Returns:
+        str: The payload's symbol, the message kind for messages without one,
+        or a hash of the message when it cannot be decoded.
+    """
+    kind, symbol = envelope.route(data)
+    if kind == 'unknown':
+        return hashlib.md5(data.encode()).hexdigest()
+    return str(symbol or kind)
+
+
+def publish_batch(messages: list[str], stream: str) -> dict:
+    """
+    Publish messages to a Kinesis data stream, partitioned by symbol, with
+    cloud.publish_sns_batch's signature so it can replace it.
+
+    Args:
+        messages (list[str]): The message bodies, at most MAX_KINESIS_BATCH and MAX_KINESIS_BATCH_BYTES in total.
+        stream (str): The ARN or name of the stream.
+
+    Returns:
+        dict: The response in SNS PublishBatch's shape, { 'Successful', 'Failed' } entries with the
+        message's index as 'Id', so failures are counted and spooled the same way.
+    """
+    response = cloud.publish_kinesis_records([(data, partition_key(data)) for data in messages], stream)
+    successful, failed = [], []
+    for i, record in enumerate(response['Records']):
+        if record.get('ErrorCode'):
+            failed.append({'Id': str(i), 'Code': record['ErrorCode'], 'Message': record.get('ErrorMessage')})
+        else:
+            successful.append({'Id': str(i), 'MessageId': record['SequenceNumber'], 'ShardId': record['ShardId']})
+    return {'Successful': successful, 'Failed': failed}
+
+
+def publish(data: str, stream: str) -> dict:
+    """
+    Publish one message to a Kinesis data stream, with
+    cloud.publish_sns_message's signature so it can replace it.
+
+    Args:
+        data (str): The message body.
+        stream (str): The ARN or name of the stream.
+
+    Returns:
+        dict: { 'MessageId', 'ShardId' }, the message's sequence number and shard.
+
+    Raises:
+        Exception: If Kinesis did not accept the message.
+    """
+    response = publish_batch([data], stream)
+    if response['Failed']:
+        raise Exception(f"Failed to put record on Kinesis stream: {response['Failed'][0]['Message']}")
+    return response['Successful'][0]
@@ -0,0 +1,135 @@
+import time
+import threading
+from datetime import datetime, timezone
+from threading import Lock
+from helpers import cloud, logger, metrics, sinks
+from typing import Callable, Optional
+
+# Initialize logger
+logger = logger.Logger('latest_prices.py')
+
+# Item attribute holding the latest record of each type
+ATTRIBUTES = {
+    'bars': 'bar',
+    'trades': 'trade',
+    'quotes': 'quote',
+}
+
+# Keys per DynamoDB BatchGetItem request
+MAX_BATCH_GET = 100
+
+
+class LatestPriceSink(sinks.MarketDataSink):
+    """Keeps the latest bar, trade, and quote per symbol in a DynamoDB table.
+
+    Each symbol is one item keyed on 'symbol' with 'bar', 'trade', and
+    'quote' attributes. Records are coalesced in memory and written every
+    flush_seconds by a timer thread, so a busy quote stream costs one write
+    per symbol per interval rather than one per quote and record() never
+    waits for DynamoDB. Writes are conditional on the record's timestamp, a
+    late or replayed record never overwrites a newer one.
+
+    Attributes:
+        table: The DynamoDB table name
+        flush_seconds: Seconds between writes of a symbol's latest records
+        pending: { (symbol, type): latest record not yet written }
+        lock: Thread lock around the pending records
+        update: Item update function, replaceable in tests
+    """
+
+    def __init__(
+        self,
+        table: str,
+        flush_seconds: float = 1.0,
+        update: Callable[..., bool] = cloud.update_dynamodb_item
+    ):
+        """Initializes the sink with nothing pending, the timer starts with the first record.
+
+        Args:
+            table: The DynamoDB table name
+            flush_seconds: Seconds between writes of a symbol's latest records
+            update: Update function with cloud.update_dynamodb_item's signature
+        """
+        self.table = table
+        self.flush_seconds = flush_seconds
+        self.pending = {}
+        self.lock = Lock()
+        self.update = update
+        self._timer = None
+
+    def record(self, record_type: str, message: dict) -> None:
+        """Keeps the message if it is the newest of its type for the symbol, for the timer to write."""
+        sinks.check_record_type(record_type)
+        key = (message['symbol'], record_type)
+        with self.lock:
+            current = self.pending.get(key)
+            if current is None or current['timestamp'] <= message['timestamp']:
+                self.pending[key] = message
+            if self._timer is None:
+                self._timer = threading.Thread(target=self._run, daemon=True)
+                self._timer.start()
+
+    def _run(self) -> None:
+        while True:
+            time.sleep(self.flush_seconds)
+            self.flush()
+
+    def flush(self) -> None:
+        """Writes every pending record."""
+        with self.lock:
+            pending, self.pending = self.pending, {}
+        updated_at = datetime.now(timezone.utc).isoformat()
+        for (symbol, record_type), message in pending.items():
+            try:
+                self.update(
+                    self.table,
+                    {'symbol': symbol},
+                    'SET #record = :record, updated_at = :updated_at',
+                    {':record': message, ':updated_at': updated_at, ':timestamp': message['timestamp']},
+                    names={'#record': ATTRIBUTES[record_type], '#timestamp': 'timestamp'},
+                    condition='attribute_not_exists(#record) OR #record.#timestamp <= :timestamp'
+                )
+            except Exception as e:
+                metrics.increment('latest_price_failures', symbol=symbol)
+                logger.error(f'Error writing latest {record_type} for {symbol}: {e}')
+
+
+def get_latest(table: str, symbols: list[str]) -> dict:
+    """
+    Read the latest records for symbols from a LatestPriceSink table.
+
+    Args:
+        table (str): The DynamoDB table name.
+        symbols (list[str]): The symbols to read.
+
+    Returns:
+        dict: { symbol: { 'bar', 'trade', 'quote', 'updated_at' } }, symbols never written are missing.
+    """
+    latest = {}
+    for i in range(0, len(symbols), MAX_BATCH_GET):
+        keys = [{'symbol': symbol} for symbol in symbols[i:i + MAX_BATCH_GET]]
+        for item in cloud.get_dynamodb_items(table, keys):
+            latest[item.pop('symbol')] = item
+    return latest
+
+
+def mark(item: dict) -> Optional[float]:
+    """
+    The current price of a symbol from its latest records: the newest of the
+    last trade, the quote midpoint, and the last bar close.
+
+    Args:
+        item (dict): A symbol's entry from get_latest().
+
+    Returns:
+        Optional[float]: The price, None when nothing usable has been written.
+    """
+    candidates = []
+    if item.get('trade'):
+        candidates.append((item['trade']['timestamp'], item['trade']['price']))
+    quote = item.get('quote')
+    if quote and quote.get('bid_price') and quote.get('ask_price'):
+        candidates.append((quote['timestamp'], (quote['bid_price'] + quote['ask_price']) / 2))
+    if item.get('bar'):
+        candidates.append((item['bar']['timestamp'], item['bar']['close']))
+    return max(candidates)[1] if candidates else None
@@ -0,0 +1,159 @@
+import time
+from datetime import datetime, timezone
+from threading import Lock
+from helpers import cloud, logger, metrics
+from typing import Optional
+
+# Stream states reported in heartbeats, only 'streaming' is connected
+STATUSES = ('waiting', 'streaming', 'reconnecting')
+
+
+class StreamHeartbeat:
+    """Counts what a market data stream delivers, summarized in periodic heartbeats.
+
+    The data service sets the status as its stream connects and drops, and
+    records every message it receives. Each beat() reports the status, the
+    message rate since the previous beat, and how long ago the last message
+    arrived, so a consumer can tell a quiet market from a dead feed.
+
+    Attributes:
+        status: One of STATUSES
+        messages: Messages recorded since the last beat
+        last_message: Epoch seconds the latest message was recorded, None if none yet
+        lock: Thread lock around the counters
+    """
+
+    def __init__(self):
+        """Initializes the heartbeat waiting for a stream, with nothing recorded."""
+        self.status = 'waiting'
+        self.messages = 0
+        self.last_message = None
+        self.lock = Lock()
+        self._last_beat = time.time()
+
+    def set_status(self, status: str) -> None:
+        """Records a change in the stream's state.
+
+        Raises:
+            ValueError: If the status is not one of STATUSES.
+        """
+        if status not in STATUSES:
+            raise ValueError(f'Unknown stream status {status}, expected one of {", ".join(STATUSES)}.')
+        with self.lock:
+            self.status = status
+
+    def record_message(self, now: Optional[float] = None) -> None:
+        """Counts a message received from the stream."""
+        with self.lock:
+            self.messages += 1
+            self.last_message = time.time() if now is None else now
+
+    def beat(self, subscribed_symbols: int, now: Optional[float] = None) -> dict:
+        """Summarizes the stream since the last beat and starts a new interval.
+
+        Args:
+            subscribed_symbols: Symbols the stream is subscribed to
+            now: Epoch seconds of the beat, defaults to the current time
+
+        Returns:
+            dict: { 'status', 'connected', 'subscribed_symbols', 'messages', 'messages_per_second',
+            'last_message_at', 'last_message_age_seconds' }, the last message None before the first
+        """
+        now = time.time() if now is None else now
+        with self.lock:
+            elapsed = max(now - self._last_beat, 1e-9)
+            messages, self.messages, self._last_beat = self.messages, 0, now
+            status, last_message = self.status, self.last_message
+        return {
+            'status': status,
+            'connected': status == 'streaming',
+            'subscribed_symbols': subscribed_symbols,
+            'messages': messages,
+            'messages_per_second': round(messages / elapsed, 3),
+            'last_message_at': datetime.fromtimestamp(last_message, timezone.utc).isoformat() if last_message else None,
+            'last_message_age_seconds': round(now - last_message, 1) if last_message else None,
+        }
+
+
+class FeedLiveness:
+    """Tracks a data service's heartbeats from the consumer side.
+
+    The feed is dead when no heartbeat has arrived for max_silence_seconds,
+    when the last heartbeat says the stream is reconnecting, or when it says
+    the stream is connected with symbols subscribed but nothing has arrived
+    for max_message_age_seconds. A heartbeat saying the stream is waiting
+    for the open is healthy. An alert is published once when the feed dies
+    and once when it recovers, rather than on every check.
+
+    Attributes:
+        max_silence_seconds: Seconds without a heartbeat before the feed is dead
+        max_message_age_seconds: Seconds a connected stream can go without a message
+        last_heartbeat: Monotonic seconds the latest heartbeat was observed
+        last_payload: The latest heartbeat
+        alerting: Whether the feed is currently reported dead
+    """
+
+    def __init__(
+        self,
+        logger: logger.Logger,
+        max_silence_seconds: float = 60,
+        max_message_age_seconds: float = 180
+    ):
+        """Initializes the tracker, silence is measured from construction until the first heartbeat.
+
+        Args:
+            logger: Service logger used for liveness warnings
+            max_silence_seconds: Seconds without a heartbeat before the feed is dead
+            max_message_age_seconds: Seconds a connected stream can go without a message
+        """
+        self.logger = logger
+        self.max_silence_seconds = max_silence_seconds
+        self.max_message_age_seconds = max_message_age_seconds
+        self.last_heartbeat = time.monotonic()
+        self.last_payload = None
+        self.alerting = False
+
+    def observe(self, payload: dict, now: Optional[float] = None) -> None:
+        """Records a heartbeat from StreamHeartbeat.beat()."""
+        self.last_heartbeat = time.monotonic() if now is None else now
+        self.last_payload = payload
+
+    def problem(self, now: Optional[float] = None) -> Optional[str]:
+        """Returns why the feed is dead, None while it is alive."""
+        now = time.monotonic() if now is None else now
+        silence = now - self.last_heartbeat
+        if silence > self.max_silence_seconds:
+            return f'no heartbeat for {silence:.0f} seconds'
+        payload = self.last_payload
+        if payload is None or payload['status'] == 'waiting':
+            return None
+        if payload['status'] == 'reconnecting':
+            return 'stream reconnecting'
+        age = payload.get('last_message_age_seconds')
+        if payload['subscribed_symbols'] and (age is None or age > self.max_message_age_seconds):
+            return f"no messages for {age:.0f} seconds" if age is not None else 'no messages since connecting'
+        return None
+
+    def check(self, now: Optional[float] = None) -> bool:
+        """Checks the feed, alerting when it dies or recovers.
+
+        Returns:
+            bool: True while the feed is alive
+        """
+        problem = self.problem(now)
+        metrics.set_gauge('feed_alive', 0 if problem else 1)
+        if problem and not self.alerting:
+            self.logger.warning(f'Market data feed is dead: {problem}')
+            self._alert('Market data feed dead', {'problem': problem, 'heartbeat': self.last_payload})
+        elif not problem and self.alerting:
+            self.logger.info('Market data feed recovered')
+            self._alert('Market data feed recovered', {'heartbeat': self.last_payload})
+        self.alerting = problem is not None
+        return problem is None
+
+    def _alert(self, subject: str, details: dict) -> None:
+        """Publishes a liveness alert without interrupting the consumer."""
+        try:
+            cloud.publish_alert(subject, details)
+        except Exception as e:
+            self.logger.error(f'Error publishing liveness alert: {e}')
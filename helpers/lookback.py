@@ -0,0 +1,97 @@
+from datetime import datetime, timedelta
+from threading import Lock
+from typing import Optional
+from helpers import logger, metrics, statistics
+
+# Initialize logger
+logger = logger.Logger('lookback.py')
+
+
+def window_from_half_life(
+    half_life: Optional[float],
+    multiplier: float = 2.0,
+    min_window: int = 10,
+    max_window: int = 120,
+    default: int = 20
+) -> int:
+    """
+    Lookback window for a Bollinger Band or z-score as a multiple of the
+    mean-reversion half-life, so the bands span the time a deviation takes
+    to decay rather than a fixed number of bars.
+
+    Args:
+        half_life (Optional[float]): Half-life in bars, None when the series is not mean reverting.
+        multiplier (float, optional): Half-lives per window. Defaults to 2.
+        min_window (int, optional): Shortest window. Defaults to 10.
+        max_window (int, optional): Longest window. Defaults to 120.
+        default (int, optional): Window without a usable half-life. Defaults to 20.
+
+    Returns:
+        int: The window in bars.
+    """
+    if half_life is None or half_life <= 0 or half_life != half_life:
+        return default
+    return max(min_window, min(max_window, round(multiplier * half_life)))
+
+
+class AdaptiveLookback:
+    """Per-symbol lookback windows re-estimated from the half-life on a schedule.
+
+    A symbol's window is estimated from its closes the first time it is
+    asked for and again once reestimate_seconds of bar time have passed, so
+    half-life regressions are not run on every bar. Times come from the
+    caller, bar time in the strategies, so backtests and live trading agree.
+
+    Attributes:
+        multiplier: Half-lives per window
+        min_window: Shortest window
+        max_window: Longest window
+        default: Window without a usable half-life
+        reestimate_seconds: Bar time between estimates of a symbol's window
+        windows: { symbol: (window, time estimated) }
+        lock: Thread lock around the windows
+    """
+
+    def __init__(
+        self,
+        multiplier: float = 2.0,
+        min_window: int = 10,
+        max_window: int = 120,
+        default: int = 20,
+        reestimate_seconds: float = 30 * 60
+    ):
+        """Initializes the lookback with no windows estimated.
+
+        Args:
+            multiplier: Half-lives per window
+            min_window: Shortest window
+            max_window: Longest window
+            default: Window without a usable half-life
+            reestimate_seconds: Bar time between estimates of a symbol's window
+        """
+        self.multiplier = multiplier
+        self.min_window = min_window
+        self.max_window = max_window
+        self.default = default
+        self.reestimate_seconds = reestimate_seconds
+        self.windows = {}
+        self.lock = Lock()
+
+    def window(self, symbol: str, closes: list[float], at: datetime) -> int:
+        """Returns the symbol's window, re-estimating it from the closes when it is due."""
+        with self.lock:
+            current = self.windows.get(symbol)
+            if current is not None and at < current[1] + timedelta(seconds=self.reestimate_seconds):
+                return current[0]
+        try:
+            half_life = statistics.half_life(closes) if len(closes) > 2 else None
+        except Exception as e:
+            logger.warning(f'Half-life estimate failed for {symbol}: {e}')
+            half_life = None
+        window = window_from_half_life(half_life, self.multiplier, self.min_window, self.max_window, self.default)
+        with self.lock:
+            if current is None or current[0] != window:
+                logger.info(f'{symbol} lookback window set to {window} bars (half-life {half_life})')
+            self.windows[symbol] = (window, at)
+        metrics.set_gauge('lookback_window', window, symbol=symbol)
+        return window
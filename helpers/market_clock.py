@@ -0,0 +1,136 @@
+import time
+from datetime import datetime, timedelta
+from threading import Event, Lock
+from helpers import logger
+from typing import Callable, Optional
+
+# Initialize logger
+logger = logger.Logger('market_clock.py')
+
+# Seconds a fetched clock is reused before the broker is asked again
+DEFAULT_TTL_SECONDS = 30
+# Longest single sleep while waiting for the open, so holiday schedule changes are picked up
+MAX_SLEEP_SECONDS = 15 * 60
+
+
+class CachedClock:
+    """Market clock that hits the broker at most once per TTL.
+
+    Between fetches the clock's timestamp is advanced by the time elapsed, and
+    the clock is refetched early once that passes the next open or close, so
+    is_open never lags a session boundary.
+
+    Attributes:
+        fetch: Returns a fresh clock as { 'is_open', 'timestamp', 'next_open', 'next_close' }
+        ttl_seconds: Seconds a fetched clock is reused
+        monotonic: Source of elapsed time, replaceable in tests
+        clock: The last fetched clock
+        fetched_at: monotonic() at the last fetch
+        lock: Thread lock around fetches
+    """
+
+    def __init__(
+        self,
+        fetch: Callable[[], dict],
+        ttl_seconds: float = DEFAULT_TTL_SECONDS,
+        monotonic: Callable[[], float] = time.monotonic
+    ):
+        """Initializes the cache, the clock is fetched on first use.
+
+        Args:
+            fetch: Returns a fresh clock as { 'is_open', 'timestamp', 'next_open', 'next_close' }
+            ttl_seconds: Seconds a fetched clock is reused
+            monotonic: Source of elapsed time, replaceable in tests
+        """
+        self.fetch = fetch
+        self.ttl_seconds = ttl_seconds
+        self.monotonic = monotonic
+        self.clock = None
+        self.fetched_at = None
+        self.lock = Lock()
+
+    def get(self) -> dict:
+        """Returns the clock, fetching it if the cached one expired or crossed a session boundary."""
+        with self.lock:
+            if self.clock is not None:
+                elapsed = self.monotonic() - self.fetched_at
+                projected = {**self.clock, 'timestamp': self.clock['timestamp'] + timedelta(seconds=elapsed)}
+                if elapsed < self.ttl_seconds and not _crossed_boundary(projected):
+                    return projected
+            self.clock = self.fetch()
+            self.fetched_at = self.monotonic()
+            return dict(self.clock)
+
+    def invalidate(self) -> None:
+        """Forces the next get() to fetch."""
+        with self.lock:
+            self.clock = None
+
+
+def _crossed_boundary(clock: dict) -> bool:
+    boundary = clock['next_close'] if clock['is_open'] else clock['next_open']
+    return boundary is not None and clock['timestamp'] >= boundary
+
+
+def next_open(clock: dict) -> Optional[datetime]:
+    """
+    The start of the next regular session, None if unknown.
+    While the market is open this is the open after the current session.
+    """
+    return clock['next_open']
+
+
+def seconds_till_open(clock: dict) -> Optional[float]:
+    """
+    Seconds until the market opens, 0 if it is open and None if unknown.
+    """
+    if clock['is_open']:
+        return 0.0
+    if clock['next_open'] is None:
+        return None
+    return max((clock['next_open'] - clock['timestamp']).total_seconds(), 0.0)
+
+
+def is_within_last_minutes_of_session(clock: dict, minutes: float) -> bool:
+    """
+    Checks if the market is open and closes within the given number of minutes.
+
+    Args:
+        clock (dict): A market clock from Broker.get_clock().
+        minutes (float): Size of the window before the close.
+
+    Returns:
+        bool: True inside the window, False while closed or earlier in the session.
+    """
+    if not clock['is_open'] or clock['next_close'] is None:
+        return False
+    return clock['next_close'] - clock['timestamp'] <= timedelta(minutes=minutes)
+
+
+def sleep_until_open(
+    get_clock: Callable[[], dict],
+    stop: Optional[Event] = None,
+    max_sleep_seconds: float = MAX_SLEEP_SECONDS
+) -> bool:
+    """
+    Block until the market is open, checking the clock at the expected open
+    and at least every max_sleep_seconds.
+
+    Args:
+        get_clock (Callable[[], dict]): Returns the market clock, e.g. Broker.get_clock.
+        stop (Optional[Event], optional): Set to abandon the wait, e.g. on shutdown. Defaults to None.
+        max_sleep_seconds (float, optional): Longest single sleep. Defaults to MAX_SLEEP_SECONDS.
+
+    Returns:
+        bool: True once the market is open, False if stop was set first.
+    """
+    stop = stop or Event()
+    while not stop.is_set():
+        clock = get_clock()
+        if clock['is_open']:
+            return True
+        wait = seconds_till_open(clock)
+        wait = max_sleep_seconds if wait is None else min(max(wait, 1.0), max_sleep_seconds)
+        logger.info(f"Market closed until {clock['next_open']}. Sleeping {wait / 60:.1f} minutes")
+        stop.wait(wait)
+    return False
@@ -0,0 +1,152 @@
+from datetime import datetime, timedelta, timezone
+from threading import Lock
+from helpers import bar_cache, brokers, logger
+from typing import Optional
+
+# Initialize logger
+logger = logger.Logger('market_data.py')
+
+# Minutes per bar of each supported timeframe
+TIMEFRAME_MINUTES = {
+    '1Min': 1,
+    '5Min': 5,
+    '15Min': 15,
+    '1Hour': 60,
+    '1Day': 60 * 24,
+}
+
+# Calendar time per bar of trading time, regular sessions are 6.5 of 24 hours
+CALENDAR_FACTOR = 24 / 6.5
+
+
+def aggregate_bars(bars: list[dict], minutes: int) -> list[dict]:
+    """
+    Aggregate bars into buckets of a longer timeframe aligned to the hour.
+
+    Args:
+        bars (list[dict]): Bars of one symbol ordered oldest to newest.
+        minutes (int): The bucket length in minutes (e.g., 5 for 5Min bars).
+
+    Returns:
+        list[dict]: One bar per bucket, timestamped at the start of the bucket.
+    """
+    buckets = {}
+    for bar in bars:
+        timestamp = datetime.fromisoformat(bar['timestamp'])
+        minute_of_day = timestamp.hour * 60 + timestamp.minute
+        start = timestamp.replace(hour=0, minute=0, second=0, microsecond=0) + timedelta(
+            minutes=minute_of_day - minute_of_day % minutes
+        )
+        key = start.isoformat()
+        bucket = buckets.get(key)
+        if bucket is None:
+            buckets[key] = {**bar, 'timestamp': key}
+            continue
+        bucket['high'] = max(bucket['high'], bar['high'])
+        bucket['low'] = min(bucket['low'], bar['low'])
+        bucket['close'] = bar['close']
+        bucket['volume'] += bar['volume']
+        bucket['trade_count'] = (bucket.get('trade_count') or 0) + (bar.get('trade_count') or 0)
+    return [buckets[key] for key in sorted(buckets)]
+
+
+class MarketData:
+    """Single access point for bar series, whatever their source.
+
+    Live and backfilled bars land in the shared bar cache as they arrive, and
+    history is fetched from the broker the first time a series is requested,
+    so a strategy asks for the last N bars and gets one continuous series.
+    Intraday timeframes longer than a minute are kept current by aggregating
+    live minute bars on top of the fetched history.
+
+    Attributes:
+        cache: The bar cache series are read from and history is merged into
+    """
+
+    def __init__(self, cache: Optional[bar_cache.BarCache] = None):
+        """Initializes the data access object.
+
+        Args:
+            cache: Bar cache to use, defaults to the process-wide cache
+        """
+        self.cache = cache or bar_cache.get_bar_cache()
+        self._seeded = set()  # { (symbol, timeframe) } with history already fetched
+        self._lock = Lock()
+
+    def get_series(self, symbol: str, timeframe: str = '1Min', lookback: int = 120) -> list[dict]:
+        """Returns the most recent bars of a symbol.
+
+        Args:
+            symbol: Trading symbol
+            timeframe: One of TIMEFRAME_MINUTES (e.g., '1Min', '5Min')
+            lookback: Number of bars wanted
+
+        Returns:
+            list[dict]: Up to lookback bars ordered oldest to newest, fewer when
+            history does not reach back far enough
+        """
+        if timeframe not in TIMEFRAME_MINUTES:
+            raise ValueError(f'Unsupported timeframe {timeframe}.')
+        minutes = TIMEFRAME_MINUTES[timeframe]
+        self._seed(symbol, timeframe, lookback, minutes)
+        if 1 < minutes < TIMEFRAME_MINUTES['1Day']:
+            self._roll_up(symbol, timeframe, minutes)
+        return self.cache.get(symbol, timeframe, lookback)
+
+    def closes(self, symbol: str, timeframe: str = '1Min', lookback: int = 120) -> list[float]:
+        """Returns the close prices of get_series()."""
+        return [bar['close'] for bar in self.get_series(symbol, timeframe, lookback)]
+
+    def _seed(self, symbol: str, timeframe: str, lookback: int, minutes: int) -> None:
+        """
+        Fetches history once per series when the cache cannot fill the lookback.
+        """
+        key = (symbol, timeframe)
+        with self._lock:
+            if key in self._seeded or self.cache.count(symbol, timeframe) >= lookback:
+                return
+            self._seeded.add(key)
+        end = datetime.now(timezone.utc)
+        # Reach back over nights and a weekend for enough trading-time bars
+        span = timedelta(minutes=lookback * minutes * CALENDAR_FACTOR) + timedelta(days=3)
+        try:
+            history = brokers.get_broker().get_bars([symbol], end - span, end, timeframe)
+        except Exception as e:
+            with self._lock:
+                self._seeded.discard(key)
+            raise Exception(f"Failed to fetch history for {symbol} {timeframe}: {e}") from e
+        bars = history.get(symbol, [])
+        self.cache.merge(bars, timeframe)
+        logger.debug(f'Seeded {len(bars)} {timeframe} bars of {symbol}')
+
+    def _roll_up(self, symbol: str, timeframe: str, minutes: int) -> None:
+        """
+        Updates a longer series with buckets built from live minute bars.
+        """
+        minute_bars = self.cache.get(symbol, '1Min')
+        if not minute_bars:
+            return
+        existing = self.cache.get(symbol, timeframe, 1)
+        latest = existing[-1]['timestamp'] if existing else ''
+        # Skip the first bucket unless minute bars cover it from its start
+        live = [
+            bar for bar in aggregate_bars(minute_bars, minutes)
+            if bar['timestamp'] >= latest and bar['timestamp'] >= minute_bars[0]['timestamp']
+        ]
+        if live:
+            self.cache.merge(live, timeframe)
+
+
+# Data access object shared by every strategy running in this process
+shared_market_data = None
+
+
+def get_market_data() -> MarketData:
+    """
+    Returns the process-wide data access object.
+    Initializes it if it hasn't been initialized yet.
+    """
+    global shared_market_data
+    if shared_market_data is None:
+        shared_market_data = MarketData()
+    return shared_market_data
@@ -0,0 +1,171 @@
+import time
+import bisect
+from threading import Lock
+from helpers import tenant
+
+# In-process metrics registry shared by every module of a service
+lock = Lock()
+counters = {}  # { (name, labels): value }
+gauges = {}  # { (name, labels): value }
+histograms = {}  # { (name, labels): { 'buckets': [count per bound], 'count', 'sum' } }
+symbol_events = {}  # { symbol: { event: count, 'last_seen': epoch seconds } }
+
+# Upper bounds, in seconds, of the buckets latencies are counted in, the last bucket has no bound
+LATENCY_BUCKETS = (0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10)
+
+
+def _key(name: str, labels: dict) -> tuple:
+    """
+    Build a hashable registry key from a metric name and its labels.
+    Series of tenants other than the default carry a tenant label.
+    """
+    if not tenant.is_default():
+        labels = {**labels, 'tenant': tenant.get_tenant()}
+    return name, tuple(sorted(labels.items()))
+
+
+def increment(name: str, value: float = 1, **labels) -> None:
+    """
+    Increment a counter.
+
+    Args:
+        name (str): The counter name (e.g., "orders_placed").
+        value (float, optional): The amount to add. Defaults to 1.
+        **labels: Label values identifying the series (e.g., symbol="AAPL").
+    """
+    key = _key(name, labels)
+    with lock:
+        counters[key] = counters.get(key, 0) + value
+
+
+def set_gauge(name: str, value: float, **labels) -> None:
+    """
+    Set a gauge to its current value.
+
+    Args:
+        name (str): The gauge name (e.g., "window_memory_bytes").
+        value (float): The current value.
+        **labels: Label values identifying the series.
+    """
+    with lock:
+        gauges[_key(name, labels)] = value
+
+
+def observe(name: str, value: float, **labels) -> None:
+    """
+    Record an observation in a histogram with LATENCY_BUCKETS bounds.
+
+    Args:
+        name (str): The histogram name (e.g., "handler_publish_seconds").
+        value (float): The observed value, seconds for latencies.
+        **labels: Label values identifying the series.
+    """
+    key = _key(name, labels)
+    with lock:
+        histogram = histograms.setdefault(key, {'buckets': [0] * (len(LATENCY_BUCKETS) + 1), 'count': 0, 'sum': 0})
+        histogram['buckets'][bisect.bisect_left(LATENCY_BUCKETS, value)] += 1
+        histogram['count'] += 1
+        histogram['sum'] += value
+
+
+def get_histogram(name: str, **labels) -> dict:
+    """
+    Read a histogram, with the cumulative count of observations at or below
+    each bound under 'buckets', empty if nothing was observed.
+
+    Returns:
+        dict: { 'buckets': { bound: count, '+Inf': count }, 'count', 'sum' }
+    """
+    with lock:
+        histogram = histograms.get(_key(name, labels))
+        return _cumulative(histogram) if histogram else {'buckets': {}, 'count': 0, 'sum': 0}
+
+
+def _cumulative(histogram: dict) -> dict:
+    """
+    Convert a histogram's per bucket counts to cumulative counts keyed by bound.
+    """
+    buckets, total = {}, 0
+    for bound, count in zip([*map(str, LATENCY_BUCKETS), '+Inf'], histogram['buckets']):
+        total += count
+        buckets[bound] = total
+    return {'buckets': buckets, 'count': histogram['count'], 'sum': histogram['sum']}
+
+
+def get_counter(name: str, **labels) -> float:
+    """
+    Read the current value of a counter, 0 if it was never incremented.
+    """
+    with lock:
+        return counters.get(_key(name, labels), 0)
+
+
+def record_symbol_event(symbol: str, event: str) -> None:
+    """
+    Count a processing event for a symbol and stamp when it was last seen.
+    Events are things like "bars", "messages", "signals", and "orders",
+    a symbol whose counts stop moving while others keep growing usually
+    means its subscription silently dropped.
+
+    Args:
+        symbol (str): The trading symbol.
+        event (str): The processing stage that handled the symbol.
+    """
+    increment(f'symbol_{event}', symbol=symbol)
+    with lock:
+        stats = symbol_events.setdefault(symbol, {})
+        stats[event] = stats.get(event, 0) + 1
+        stats['last_seen'] = time.time()
+
+
+def symbol_stats(symbol: str = None) -> dict:
+    """
+    Return per-symbol processing counters.
+
+    Args:
+        symbol (str, optional): Limit the result to one symbol. Defaults to None.
+
+    Returns:
+        dict: { symbol: { event: count, 'last_seen': epoch seconds } }
+    """
+    with lock:
+        if symbol is not None:
+            return {symbol: dict(symbol_events.get(symbol, {}))}
+        return {name: dict(stats) for name, stats in symbol_events.items()}
+
+
+def snapshot() -> dict:
+    """
+    Return every counter, gauge, and histogram in a JSON serializable form.
+
+    Returns:
+        dict: A dictionary with 'counters', 'gauges', and 'histograms' lists,
+        each entry containing the metric 'name', its 'labels', and its 'value',
+        a histogram's value being get_histogram()'s dictionary.
+    """
+    with lock:
+        return {
+            'counters': [
+                {'name': name, 'labels': dict(labels), 'value': value}
+                for (name, labels), value in counters.items()
+            ],
+            'gauges': [
+                {'name': name, 'labels': dict(labels), 'value': value}
+                for (name, labels), value in gauges.items()
+            ],
+            'histograms': [
+                {'name': name, 'labels': dict(labels), 'value': _cumulative(histogram)}
+                for (name, labels), histogram in histograms.items()
+            ],
+        }
+
+
+def reset() -> None:
+    """
+    Clear all metrics, used between tests.
+    """
+    with lock:
+        counters.clear()
+        gauges.clear()
+        histograms.clear()
+        symbol_events.clear()
@@ -0,0 +1,191 @@
+import time
+from datetime import datetime, timedelta
+from helpers import cloud, logger, metrics
+from typing import Callable, Optional
+
+
+class QueueLagMonitor:
+    """Tracks how far an SQS consumer is behind its queue.
+
+    Backlog size comes from the queue attributes, which are refreshed at most
+    once per check interval. Message age comes from the SentTimestamp of the
+    messages the consumer actually receives, so it reflects how stale the
+    data being traded on is. Empty receives leave it as is, a drained queue
+    is seen through the backlog, which resets it.
+
+    Attributes:
+        queue_url: URL of the monitored SQS queue
+        max_backlog: Visible message count that triggers an alert
+        max_age_seconds: Age of the oldest received message that triggers an alert
+        check_interval_seconds: Minimum seconds between queue attribute polls
+        backlog: Last observed visible message count
+        oldest_message_age: Age in seconds of the oldest message in the last batch with timestamps
+        alerting: Whether the consumer is currently flagged as lagging
+    """
+
+    def __init__(
+        self,
+        queue_url: str,
+        logger: logger.Logger,
+        max_backlog: int = 100,
+        max_age_seconds: float = 120,
+        check_interval_seconds: float = 60,
+        read_backlog: Optional[Callable[[], int]] = None
+    ):
+        """Initializes the monitor for a single consumer queue.
+
+        Args:
+            queue_url: URL of the SQS queue to monitor
+            logger: Service logger used for lag warnings
+            max_backlog: Visible message count that triggers an alert
+            max_age_seconds: Oldest message age that triggers an alert
+            check_interval_seconds: Minimum seconds between queue attribute polls
+            read_backlog: Returns the backlog of a consumer not reading SQS, e.g. KafkaPoller.lag
+        """
+        self.queue_url = queue_url
+        self.logger = logger
+        self.max_backlog = max_backlog
+        self.max_age_seconds = max_age_seconds
+        self.check_interval_seconds = check_interval_seconds
+        self.read_backlog = read_backlog
+        self.backlog = 0
+        self.oldest_message_age = 0.0
+        self.alerting = False
+        self._last_check = 0.0
+
+    def record_messages(self, messages: list) -> None:
+        """Updates the oldest message age from a batch of received messages, if any carry a SentTimestamp.
+
+        Args:
+            messages: Messages returned by cloud.poll_sqs_message
+        """
+        now_ms = time.time() * 1000
+        ages = [
+            (now_ms - int(message['Attributes']['SentTimestamp'])) / 1000
+            for message in messages
+            if 'SentTimestamp' in message.get('Attributes', {})
+        ]
+        if ages:
+            self.oldest_message_age = max(ages)
+
+    def check(self) -> Optional[dict]:
+        """Polls the queue backlog if the check interval elapsed and alerts on lag.
+
+        An alert is published once when the consumer starts lagging and a
+        recovery notice once it catches up, rather than on every check.
+
+        Returns:
+            Optional[dict]: The lag stats if a check ran, None otherwise
+        """
+        if time.monotonic() - self._last_check < self.check_interval_seconds:
+            return None
+        self._last_check = time.monotonic()
+        try:
+            self.backlog = self.read_backlog() if self.read_backlog else cloud.get_queue_attributes(self.queue_url)['visible']
+        except Exception as e:
+            self.logger.error(f'Error reading queue backlog: {e}')
+            return None
+        # Nothing is waiting, so no message is aging
+        if not self.backlog:
+            self.oldest_message_age = 0.0
+
+        stats = {
+            'queue_url': self.queue_url,
+            'backlog': self.backlog,
+            'oldest_message_age': round(self.oldest_message_age, 1),
+        }
+        lagging = self.backlog > self.max_backlog or self.oldest_message_age > self.max_age_seconds
+        if lagging and not self.alerting:
+            self.logger.warning(f'Consumer is lagging: {stats}')
+            self._alert('SQS consumer lagging', stats)
+        elif not lagging and self.alerting:
+            self.logger.info(f'Consumer caught up: {stats}')
+            self._alert('SQS consumer recovered', stats)
+        self.alerting = lagging
+        return stats
+
+    def _alert(self, subject: str, stats: dict) -> None:
+        """Publishes a lag alert without interrupting the consumer."""
+        try:
+            cloud.publish_alert(subject, stats)
+        except Exception as e:
+            self.logger.error(f'Error publishing lag alert: {e}')
+
+
+class LatencyBudget:
+    """Enforces a maximum delay between a bar closing and an order on it being submitted.
+
+    Latency is measured from the bar's close, its start timestamp plus the
+    bar length, to the moment the signal is about to be submitted, so it
+    covers the feed, the data topic, the queue, and signal generation.
+
+    Attributes:
+        budget_ms: Latency in milliseconds above which a signal is late
+        bar_seconds: Length of the bars signals are generated from
+        skip_late: Whether late signals are skipped instead of traded
+        breaches: Number of late signals seen
+    """
+
+    def __init__(
+        self,
+        logger: logger.Logger,
+        budget_ms: float = 500,
+        bar_seconds: float = 60,
+        skip_late: bool = False
+    ):
+        """Initializes the budget for one strategy.
+
+        Args:
+            logger: Service logger used for budget warnings
+            budget_ms: Latency in milliseconds above which a signal is late
+            bar_seconds: Length of the bars signals are generated from
+            skip_late: Whether late signals are skipped instead of traded
+        """
+        self.logger = logger
+        self.budget_ms = budget_ms
+        self.bar_seconds = bar_seconds
+        self.skip_late = skip_late
+        self.breaches = 0
+
+    def elapsed_ms(self, bar_timestamp: str, now: Optional[float] = None) -> float:
+        """Returns milliseconds since the bar closed.
+
+        Args:
+            bar_timestamp: ISO start timestamp of the bar, timezone aware
+            now: Epoch seconds to measure to, defaults to the current time
+        """
+        bar_close = datetime.fromisoformat(bar_timestamp) + timedelta(seconds=self.bar_seconds)
+        now = time.time() if now is None else now
+        return (now - bar_close.timestamp()) * 1000
+
+    def check(self, symbol: str, bar_timestamp: str, now: Optional[float] = None) -> bool:
+        """Measures a signal's latency and alerts if it is over budget.
+
+        Args:
+            symbol: Symbol the signal is for
+            bar_timestamp: ISO start timestamp of the bar the signal came from
+            now: Epoch seconds to measure to, defaults to the current time
+
+        Returns:
+            bool: True if the order should be submitted, False if it is late and late signals are skipped
+        """
+        latency_ms = self.elapsed_ms(bar_timestamp, now)
+        metrics.set_gauge('signal_latency_ms', latency_ms, symbol=symbol)
+        if latency_ms <= self.budget_ms:
+            return True
+
+        self.breaches += 1
+        metrics.increment('latency_budget_breaches', symbol=symbol)
+        stats = {
+            'symbol': symbol,
+            'bar_timestamp': bar_timestamp,
+            'latency_ms': round(latency_ms, 1),
+            'budget_ms': self.budget_ms,
+            'skipped': self.skip_late,
+        }
+        self.logger.warning(f'Signal exceeded latency budget: {stats}')
+        try:
+            cloud.publish_alert('Signal latency budget exceeded', stats)
+        except Exception as e:
+            self.logger.error(f'Error publishing latency alert: {e}')
+        return not self.skip_late
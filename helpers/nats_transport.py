@@ -0,0 +1,279 @@
+import os
+import re
+import json
+import time
+import queue
+import asyncio
+import threading
+from helpers import envelope, logger, metrics
+from typing import Optional
+
+# Initialize logger
+logger = logger.Logger('nats_transport.py')
+
+# Connection shared by the publish functions, created on first use
+connection = None
+
+# Seconds a call waits for the server, including JetStream acknowledgements
+TIMEOUT_SECONDS = 5
+
+
+class Connection:
+    """A NATS connection driven by its own event loop thread.
+
+    The NATS client is asyncio only, while the transport interface is called
+    from publisher worker threads and the synchronous strategy loop, so each
+    call is submitted to the connection's loop and waited on.
+
+    Attributes:
+        jetstream: Whether messages are published to and consumed from JetStream streams
+        loop: Event loop the client runs on
+        client: nats.aio.client.Client, replaceable in tests
+        js: JetStream context, None for core NATS
+        streams: Destinations whose JetStream stream has been ensured
+    """
+
+    def __init__(self, servers: Optional[str] = None, jetstream: Optional[bool] = None, client=None):
+        """Starts the event loop thread and connects.
+
+        Args:
+            servers: Comma-separated server URLs, NATS_URL or nats://localhost:4222 if None
+            jetstream: Whether to use JetStream, NATS_JETSTREAM == 'True' if None
+            client: Client with nats.aio.client.Client's interface, one is connected if None
+        """
+        self.jetstream = os.getenv('NATS_JETSTREAM') == 'True' if jetstream is None else jetstream
+        self.loop = asyncio.new_event_loop()
+        threading.Thread(target=self.loop.run_forever, daemon=True).start()
+        if client is None:
+            import nats
+            servers = (servers or os.getenv('NATS_URL', 'nats://localhost:4222')).split(',')
+            client = self.call(nats.connect(servers, max_reconnect_attempts=-1))
+        self.client = client
+        self.js = client.jetstream() if self.jetstream else None
+        self.streams = set()
+
+    def call(self, coroutine, timeout: float = TIMEOUT_SECONDS):
+        """Runs a coroutine on the connection's loop, returning its result."""
+        return asyncio.run_coroutine_threadsafe(coroutine, self.loop).result(timeout)
+
+    def ensure_stream(self, topic: str) -> None:
+        """Creates the JetStream stream capturing every subject of a destination unless it exists, a no-op for core NATS."""
+        if not self.js or topic in self.streams:
+            return
+        name = stream_name(topic)
+        try:
+            self.call(self.js.add_stream(name=name, subjects=[f'{topic}.>']))
+            logger.info(f'Created JetStream stream {name} for {topic}.>')
+        except Exception as e:
+            # Adding a stream that exists with another configuration fails, the existing one is used as is
+            try:
+                self.call(self.js.stream_info(name))
+            except Exception:
+                raise Exception(f'Failed to create JetStream stream {name}: {e}') from e
+        self.streams.add(topic)
+
+
+def get_connection() -> Connection:
+    """
+    Returns the shared connection, creating it if it hasn't been created yet.
+    """
+    global connection
+    if connection is None:
+        connection = Connection()
+    return connection
+
+
+def subject(topic: str, kind: str, symbol: Optional[str] = None) -> str:
+    """
+    Name the subject a message published to a destination goes to.
+
+    Args:
+        topic (str): The destination, e.g. DATA_SNS's value such as 'nexus.data'.
+        kind (str): The envelope kind, e.g. 'bar'.
+        symbol (Optional[str], optional): The payload's symbol, '_' stands in for messages without one.
+
+    Returns:
+        str: The subject, e.g. 'nexus.data.bar.AAPL', subscribe to 'nexus.data.bar.>' for every symbol.
+    """
+    return f"{topic}.{kind}.{symbol or '_'}"
+
+
+def wildcard(topic: str, kind: str) -> str:
+    """Returns the subject matching every symbol's messages of a kind published to a destination."""
+    return f'{topic}.{kind}.>'
+
+
+def stream_name(topic: str) -> str:
+    """Returns the JetStream stream holding a destination's messages, e.g. 'nexus_data' for 'nexus.data'."""
+    return re.sub(r'[^A-Za-z0-9_-]', '_', topic)
+
+
+def publish_batch(messages: list[str], topic: str) -> dict:
+    """
+    Publish messages to the subjects of their kinds and symbols, with
+    cloud.publish_sns_batch's signature so it can replace it. Core NATS
+    publishes are confirmed by a flush, JetStream ones by the stream's
+    acknowledgements, the destination's stream is created on first publish.
+
+    Args:
+        messages (list[str]): The message bodies.
+        topic (str): The destination the subjects are named after, see subject().
+
+    Returns:
+        dict: The response in SNS PublishBatch's shape, { 'Successful', 'Failed' } entries with the
+        message's index as 'Id', so failures are counted and spooled the same way.
+    """
+    shared = get_connection()
+    shared.ensure_stream(topic)
+
+    async def send():
+        publish = shared.js.publish if shared.js else shared.client.publish
+        results = await asyncio.gather(
+            *[publish(subject(topic, *envelope.route(data)), data.encode()) for data in messages],
+            return_exceptions=True
+        )
+        if not shared.js:
+            await shared.client.flush(TIMEOUT_SECONDS)
+        return results
+
+    successful, failed = [], []
+    for i, result in enumerate(shared.call(send())):
+        if isinstance(result, Exception):
+            failed.append({'Id': str(i), 'Code': type(result).__name__, 'Message': str(result)})
+        else:
+            successful.append({'Id': str(i), 'MessageId': f'{result.stream}:{result.seq}' if result else None})
+    return {'Successful': successful, 'Failed': failed}
+
+
+def publish(data: str, topic: str) -> dict:
+    """
+    Publish one message to the subject of its kind and symbol, with
+    cloud.publish_sns_message's signature so it can replace it.
+
+    Args:
+        data (str): The message body.
+        topic (str): The destination the subject is named after, see subject().
+
+    Returns:
+        dict: { 'Id', 'MessageId' }, the message's stream and sequence under JetStream.
+
+    Raises:
+        Exception: If NATS did not accept the message.
+    """
+    response = publish_batch([data], topic)
+    if response['Failed']:
+        raise Exception(f"Failed to publish message to NATS: {response['Failed'][0]['Message']}")
+    return response['Successful'][0]
+
+
+class NatsPoller:
+    """Receives from NATS subjects for a group of consumers.
+
+    Stands in for polling.AdaptivePoller and cloud.delete_sqs_message like
+    kafka.KafkaPoller: poll() returns messages in the shape
+    cloud.poll_sqs_message does and delete() acknowledges one. Under
+    JetStream each subject is read through a durable pull consumer named
+    after the group, so unacknowledged messages are redelivered, and the
+    destination's stream is created if no publisher has yet. Core NATS
+    delivers to a queue group with nothing to acknowledge, messages
+    published while no member is subscribed are lost.
+
+    Attributes:
+        subjects: Subjects consumed, wildcards allowed
+        group: Durable consumer or queue group name shared by the service's instances
+        max_batch: Most messages per poll
+        wait_seconds: Longest a poll waits for messages
+        connection: The Connection read from
+    """
+
+    def __init__(
+        self,
+        subjects: list[str],
+        group: str,
+        max_batch: int = 100,
+        wait_seconds: float = 1,
+        connection: Optional[Connection] = None
+    ):
+        """Initializes the poller and subscribes to the subjects.
+
+        Args:
+            subjects: Subjects to consume, see wildcard()
+            group: Durable consumer or queue group name shared by the service's instances
+            max_batch: Most messages per poll
+            wait_seconds: Longest a poll waits for messages
+            connection: Connection to read from, the shared one if None
+        """
+        self.subjects = subjects
+        self.group = group
+        self.max_batch = max_batch
+        self.wait_seconds = wait_seconds
+        self.connection = connection or get_connection()
+        self._received = {}  # JetStream messages polled but not yet acknowledged, by receipt handle
+        self._delivered = queue.Queue()  # Core NATS messages waiting to be polled
+        self._count = 0
+        if self.connection.js:
+            # Subjects are named by wildcard(), the destination is what precedes the kind
+            for name in subjects:
+                self.connection.ensure_stream(name.rsplit('.', 2)[0])
+            self._subscriptions = [
+                self.connection.call(self.connection.js.pull_subscribe(name, durable=f"{group}-{re.sub(r'[^A-Za-z0-9_-]', '_', name)}"))
+                for name in subjects
+            ]
+        else:
+            async def deliver(message):
+                self._delivered.put((message, time.time()))
+            self._subscriptions = [
+                self.connection.call(self.connection.client.subscribe(name, queue=group, cb=deliver)) for name in subjects
+            ]
+
+    def poll(self, backlog: Optional[int] = None) -> list:
+        """
+        Receive the next messages, the backlog argument is accepted for AdaptivePoller's interface.
+
+        Returns:
+            list: { 'MessageId', 'ReceiptHandle', 'Body', 'Attributes' } messages, possibly empty.
+        """
+        received = []
+        if self.connection.js:
+            for subscription in self._subscriptions:
+                try:
+                    fetched = self.connection.call(
+                        subscription.fetch(self.max_batch, timeout=self.wait_seconds / len(self._subscriptions)),
+                        timeout=self.wait_seconds + TIMEOUT_SECONDS
+                    )
+                except TimeoutError:
+                    continue  # Nothing arrived in time
+                received += [(message, message.metadata.timestamp.timestamp()) for message in fetched]
+        else:
+            try:
+                received.append(self._delivered.get(timeout=self.wait_seconds))
+                while len(received) < self.max_batch:
+                    received.append(self._delivered.get_nowait())
+            except queue.Empty:
+                pass
+        messages = []
+        for message, sent in received:
+            self._count += 1
+            handle = f'{message.subject}:{self._count}'
+            if self.connection.js:
+                self._received[handle] = message
+            messages.append({
+                'MessageId': handle,
+                'ReceiptHandle': handle,
+                'Body': json.dumps({'Message': message.data.decode()}),
+                'Attributes': {'SentTimestamp': str(int(sent * 1000))},
+            })
+        metrics.increment('nats_messages', len(messages), group=self.group)
+        return messages
+
+    def delete(self, receipt_handle: str) -> None:
+        """Acknowledges a handled JetStream message so it is not redelivered, core NATS has nothing to acknowledge."""
+        message = self._received.pop(receipt_handle, None)
+        if message is not None:
+            self.connection.call(message.ack())
+
+    def lag(self) -> int:
+        """Returns the messages waiting for the group, pending in the durable consumers or delivered but not polled."""
+        if not self.connection.js:
+            return self._delivered.qsize()
+        return sum(self.connection.call(subscription.consumer_info()).num_pending for subscription in self._subscriptions)
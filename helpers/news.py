@@ -0,0 +1,154 @@
+from helpers import broker, logger, tenant
+from helpers.domain import converters
+from alpaca.data.live import NewsDataStream
+from alpaca.data.requests import NewsRequest
+from datetime import datetime, timedelta, timezone
+from threading import Lock
+from typing import Awaitable, Callable, Optional
+
+# Initialize logger
+logger = logger.Logger('news.py')
+
+# Handler receiving news dictionaries (see news_to_dict) from a stream
+NewsHandler = Callable[[dict], Awaitable[None]]
+
+
+def news_to_dict(article) -> dict:
+    """
+    Convert an Alpaca News article to the dictionary format published on the news topic.
+    """
+    return {
+        'type': 'news',
+        'id': article.id,
+        'headline': article.headline,
+        'summary': article.summary,
+        'source': article.source,
+        'url': article.url,
+        'symbols': list(article.symbols or []),
+        'created_at': converters.as_utc(article.created_at).isoformat(),
+    }
+
+
+def get_news(
+    symbols: list[str],
+    start: datetime,
+    end: datetime,
+    limit: Optional[int] = None
+) -> list[dict]:
+    """
+    Retrieve news articles mentioning any of the symbols.
+
+    Args:
+        symbols (list[str]): The stock symbols.
+        start (datetime): The earliest publish time.
+        end (datetime): The latest publish time.
+        limit (Optional[int], optional): The maximum number of articles. Defaults to None.
+
+    Returns:
+        list[dict]: Articles in the format of news_to_dict(), oldest first.
+
+    Raises:
+        Exception: If the news cannot be retrieved.
+    """
+    news_client = broker.get_broker_client('news')
+    try:
+        news = news_client.get_news(NewsRequest(
+            symbols=','.join(symbols),
+            start=start,
+            end=end,
+            limit=limit
+        ))
+        articles = [news_to_dict(article) for article in news.data.get('news', [])]
+        return sorted(articles, key=lambda article: article['created_at'])
+    except Exception as e:
+        raise Exception(f"Failed to get news for {symbols}: {e}") from e
+
+
+# Initialize a placeholder for the running news stream and its callback
+news_stream = None
+news_callback = None
+
+
+def stream_news(handler: NewsHandler, symbols: list[str]) -> None:
+    """
+    Stream news for the symbols to an async handler, blocking until stop_news_stream() is called.
+
+    Args:
+        handler (NewsHandler): Receives each article in the format of news_to_dict().
+        symbols (list[str]): The stock symbols to subscribe to.
+
+    Raises:
+        ValueError: If the broker credentials are not set.
+    """
+    global news_stream, news_callback
+    api_key = tenant.getenv('BROKER_API_KEY')
+    api_secret = tenant.getenv('BROKER_SECRET_KEY')
+    if not api_key or not api_secret:
+        raise ValueError('API key and secret must be set in environment variables.')
+
+    async def on_news(article):
+        await handler(news_to_dict(article))
+
+    news_stream = NewsDataStream(api_key, api_secret, url_override=broker.stream_url('v1beta1/news'))
+    news_callback = on_news
+    news_stream.subscribe_news(on_news, *symbols)
+    news_stream.run()
+
+
+def stop_news_stream() -> None:
+    """
+    Stops a running stream_news() call.
+    """
+    if news_stream is not None:
+        news_stream.stop()
+
+
+def subscribe_news(symbols: list[str]) -> None:
+    """
+    Adds symbols to a running stream_news() call, a no-op when not streaming.
+    """
+    if news_stream is not None and symbols:
+        news_stream.subscribe_news(news_callback, *symbols)
+
+
+def unsubscribe_news(symbols: list[str]) -> None:
+    """
+    Removes symbols from a running stream_news() call, a no-op when not streaming.
+    """
+    if news_stream is not None and symbols:
+        news_stream.unsubscribe_news(*symbols)
+
+
+class HeadlineGuard:
+    """Pauses trading in a symbol for a window after a headline mentions it.
+
+    Attributes:
+        pause_seconds: Seconds after a headline during which the symbol is paused
+        last_headline: { symbol: publish time of the latest headline }
+        lock: Thread lock for updates from the consumer and strategy
+    """
+
+    def __init__(self, pause_seconds: float = 300):
+        """Initializes the guard.
+
+        Args:
+            pause_seconds: Seconds after a headline during which the symbol is paused
+        """
+        self.pause_seconds = pause_seconds
+        self.last_headline = {}
+        self.lock = Lock()
+
+    def record(self, article: dict) -> None:
+        """Records an article from the news topic against every symbol it mentions."""
+        published = datetime.fromisoformat(article['created_at'])
+        with self.lock:
+            for symbol in article['symbols']:
+                if symbol not in self.last_headline or published > self.last_headline[symbol]:
+                    self.last_headline[symbol] = published
+
+    def is_paused(self, symbol: str, now: Optional[datetime] = None) -> bool:
+        """Checks if a symbol had a headline within the pause window."""
+        now = now or datetime.now(timezone.utc)
+        with self.lock:
+            published = self.last_headline.get(symbol)
+        return published is not None and now - published < timedelta(seconds=self.pause_seconds)
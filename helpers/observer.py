@@ -0,0 +1,72 @@
+import os
+from helpers import chaos, logger, metrics
+
+# Initialize logger
+logger = logger.Logger('observer.py')
+
+# Trading client methods that send, change, or cancel orders or positions
+TRADING_METHODS = (
+    'submit_order',
+    'replace_order_by_id',
+    'cancel_order_by_id',
+    'cancel_orders',
+    'close_position',
+    'close_all_positions',
+    'exercise_options_position',
+)
+
+
+class ExecutionDisabled(Exception):
+    """Raised when an order is attempted in observer mode."""
+
+    def __init__(self, operation: str):
+        super().__init__(f'Observer mode, {operation} is disabled')
+        self.operation = operation
+
+
+def enabled() -> bool:
+    """
+    Check if the system runs in observer mode, where services compute and
+    publish signals and metrics but the execution layer never sends orders.
+
+    Returns:
+        bool: True if OBSERVER_MODE is 'True'.
+    """
+    return os.getenv('OBSERVER_MODE') == 'True'
+
+
+def blocked(operation: str) -> None:
+    """
+    Refuse an execution operation.
+
+    Raises:
+        ExecutionDisabled: Always.
+    """
+    logger.info(f'Observer mode, blocked {operation}')
+    metrics.increment('observer_blocked', operation=operation)
+    raise ExecutionDisabled(operation)
+
+
+def block_hook(operation: str, call):
+    """
+    Client hook that never runs the call.
+    """
+    blocked(operation)
+
+
+def wrap_trading_client(client):
+    """
+    Disable the order methods of a trading client in observer mode.
+    Clients are created once per process, so observer mode cannot be
+    switched off without a restart.
+
+    Args:
+        client: The Alpaca TradingClient, optionally chaos wrapped.
+
+    Returns:
+        The client itself outside observer mode, otherwise a proxy whose order methods raise ExecutionDisabled.
+    """
+    if not enabled():
+        return client
+    logger.warning('Observer mode enabled, the trading client will not send orders')
+    return chaos.FaultInjectingClient(client, {method: block_hook for method in TRADING_METHODS})
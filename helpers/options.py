@@ -0,0 +1,290 @@
+from helpers import broker, logger
+from alpaca.data.requests import OptionChainRequest, OptionSnapshotRequest
+from alpaca.trading.requests import (
+                                     GetOptionContractsRequest,
+                                     MarketOrderRequest,
+                                     LimitOrderRequest,
+                                     OptionLegRequest
+                                     )
+from alpaca.trading.enums import OrderSide, TimeInForce, OrderClass, ContractType
+from datetime import date, datetime
+from typing import Optional
+
+# Initialize logger
+logger = logger.Logger('options.py')
+
+# Alpaca accepts between two and four legs in a multi-leg order
+MIN_LEGS = 2
+MAX_LEGS = 4
+
+
+def parse_option_symbol(symbol: str) -> dict:
+    """
+    Parse an OCC option symbol.
+
+    Example:
+        parse_option_symbol('AAPL250321C00150000')
+        {'underlying': 'AAPL', 'expiration': date(2025, 3, 21), 'type': 'call', 'strike': 150.0}
+
+    Args:
+        symbol (str): The OCC symbol, root followed by YYMMDD, C or P,
+                      and the strike times 1000 in eight digits.
+
+    Returns:
+        dict: The underlying, expiration date, type ('call' or 'put'), and strike.
+
+    Raises:
+        ValueError: If the symbol is not a valid OCC symbol.
+    """
+    symbol = symbol.replace(' ', '')
+    if len(symbol) < 16 or symbol[-9] not in 'CP' or not symbol[-8:].isdigit():
+        raise ValueError(f'Invalid option symbol {symbol}.')
+    return {
+        'underlying': symbol[:-15],
+        'expiration': datetime.strptime(symbol[-15:-9], '%y%m%d').date(),
+        'type': 'call' if symbol[-9] == 'C' else 'put',
+        'strike': int(symbol[-8:]) / 1000,
+    }
+
+
+def get_option_contracts(
+    underlying: str,
+    expiration_date_gte: Optional[date] = None,
+    expiration_date_lte: Optional[date] = None,
+    contract_type: Optional[ContractType] = None,
+    strike_price_gte: Optional[float] = None,
+    strike_price_lte: Optional[float] = None
+) -> list[dict]:
+    """
+    Retrieve the tradable option contracts of an underlying, following pagination.
+
+    Args:
+        underlying (str): The underlying symbol (e.g., "AAPL").
+        expiration_date_gte (Optional[date], optional): Earliest expiration. Defaults to None.
+        expiration_date_lte (Optional[date], optional): Latest expiration. Defaults to None.
+        contract_type (Optional[ContractType], optional): Calls or puts only. Defaults to both.
+        strike_price_gte (Optional[float], optional): Lowest strike. Defaults to None.
+        strike_price_lte (Optional[float], optional): Highest strike. Defaults to None.
+
+    Returns:
+        list[dict]: Contracts as { 'symbol', 'underlying', 'type', 'strike',
+        'expiration', 'open_interest' } ordered by expiration then strike.
+    """
+    trading_client = broker.get_broker_client('trading')
+    try:
+        contracts = []
+        page_token = None
+        while True:
+            response = trading_client.get_option_contracts(GetOptionContractsRequest(
+                underlying_symbols=[underlying],
+                expiration_date_gte=expiration_date_gte,
+                expiration_date_lte=expiration_date_lte,
+                type=contract_type,
+                strike_price_gte=str(strike_price_gte) if strike_price_gte is not None else None,
+                strike_price_lte=str(strike_price_lte) if strike_price_lte is not None else None,
+                page_token=page_token
+            ))
+            for contract in response.option_contracts or []:
+                contracts.append({
+                    'symbol': contract.symbol,
+                    'underlying': contract.underlying_symbol,
+                    'type': contract.type.value,
+                    'strike': float(contract.strike_price),
+                    'expiration': contract.expiration_date,
+                    'open_interest': float(contract.open_interest) if contract.open_interest else 0.0,
+                })
+            page_token = response.next_page_token
+            if not page_token:
+                break
+        return sorted(contracts, key=lambda contract: (contract['expiration'], contract['strike']))
+    except Exception as e:
+        raise Exception(f"Failed to get option contracts for {underlying}: {e}") from e
+
+
+def _snapshot_to_dict(snapshot) -> dict:
+    """
+    Flatten an Alpaca option snapshot, missing fields are None.
+    """
+    quote = snapshot.latest_quote
+    trade = snapshot.latest_trade
+    greeks = snapshot.greeks
+    return {
+        'bid': quote.bid_price if quote else None,
+        'ask': quote.ask_price if quote else None,
+        'last': trade.price if trade else None,
+        'implied_volatility': snapshot.implied_volatility,
+        'delta': greeks.delta if greeks else None,
+        'gamma': greeks.gamma if greeks else None,
+        'theta': greeks.theta if greeks else None,
+        'vega': greeks.vega if greeks else None,
+    }
+
+
+def get_option_chain(
+    underlying: str,
+    expiration_date: Optional[date] = None,
+    contract_type: Optional[ContractType] = None,
+    strike_price_gte: Optional[float] = None,
+    strike_price_lte: Optional[float] = None,
+    expiration_date_gte: Optional[date] = None,
+    expiration_date_lte: Optional[date] = None
+) -> dict:
+    """
+    Retrieve the option chain of an underlying with quotes, implied volatility, and greeks.
+
+    Args:
+        underlying (str): The underlying symbol (e.g., "AAPL").
+        expiration_date (Optional[date], optional): Only this expiration. Defaults to all.
+        contract_type (Optional[ContractType], optional): Calls or puts only. Defaults to both.
+        strike_price_gte (Optional[float], optional): Lowest strike. Defaults to None.
+        strike_price_lte (Optional[float], optional): Highest strike. Defaults to None.
+        expiration_date_gte (Optional[date], optional): Earliest expiration. Defaults to None.
+        expiration_date_lte (Optional[date], optional): Latest expiration. Defaults to None.
+
+    Returns:
+        dict: { option symbol: { 'bid', 'ask', 'last', 'implied_volatility',
+        'delta', 'gamma', 'theta', 'vega' } }.
+    """
+    option_client = broker.get_broker_client('option')
+    try:
+        chain = option_client.get_option_chain(OptionChainRequest(
+            underlying_symbol=underlying,
+            expiration_date=expiration_date,
+            type=contract_type,
+            strike_price_gte=strike_price_gte,
+            strike_price_lte=strike_price_lte,
+            expiration_date_gte=expiration_date_gte,
+            expiration_date_lte=expiration_date_lte
+        ))
+        return {symbol: _snapshot_to_dict(snapshot) for symbol, snapshot in chain.items()}
+    except Exception as e:
+        raise Exception(f"Failed to get option chain for {underlying}: {e}") from e
+
+
+def get_option_snapshots(symbols: list[str]) -> dict:
+    """
+    Retrieve snapshots of specific option contracts.
+
+    Args:
+        symbols (list[str]): OCC option symbols.
+
+    Returns:
+        dict: { option symbol: snapshot dict } in the format of get_option_chain().
+    """
+    option_client = broker.get_broker_client('option')
+    try:
+        snapshots = option_client.get_option_snapshot(OptionSnapshotRequest(symbol_or_symbols=symbols))
+        return {symbol: _snapshot_to_dict(snapshot) for symbol, snapshot in snapshots.items()}
+    except Exception as e:
+        raise Exception(f"Failed to get option snapshots: {e}") from e
+
+
+def place_option_order(
+    symbol: str,
+    qty: int,
+    side: OrderSide,
+    limit_price: Optional[float] = None,
+    time_in_force: TimeInForce = TimeInForce.DAY,
+    client_order_id: Optional[str] = None
+) -> str:
+    """
+    Place a single-leg option order, a limit order when limit_price is given.
+
+    Args:
+        symbol (str): The OCC option symbol.
+        qty (int): The number of contracts.
+        side (OrderSide): The side of the order.
+        limit_price (Optional[float], optional): The limit price per share of
+                    the contract. Defaults to None (market order).
+        time_in_force (TimeInForce, optional): Options only support DAY.
+                    Defaults to TimeInForce.DAY.
+        client_order_id (Optional[str], optional): When provided the order is
+                    submitted idempotently under this ID. Defaults to None.
+
+    Returns:
+        str: The ID of the order.
+
+    Raises:
+        ValueError: If the quantity is not a positive whole number of contracts.
+        Exception: If the order placement fails.
+    """
+    if qty <= 0 or int(qty) != qty:
+        raise ValueError('Option quantity must be a positive whole number of contracts.')
+    parse_option_symbol(symbol)
+    try:
+        fields = dict(symbol=symbol, qty=qty, side=side, time_in_force=time_in_force, client_order_id=client_order_id)
+        order = LimitOrderRequest(limit_price=limit_price, **fields) if limit_price else MarketOrderRequest(**fields)
+        submitted_order = _submit(order, client_order_id)
+        logger.info(f"Option order placed for {qty} contracts of {symbol} ({side.value})")
+        return str(submitted_order.id)
+    except Exception as e:
+        raise Exception(f"Failed to place option order: {e}") from e
+
+
+def place_multileg_order(
+    legs: list[dict],
+    qty: int,
+    limit_price: Optional[float] = None,
+    time_in_force: TimeInForce = TimeInForce.DAY,
+    client_order_id: Optional[str] = None
+) -> str:
+    """
+    Place a multi-leg option order (e.g., a vertical spread or iron condor)
+    that fills all legs together or not at all.
+
+    Example:
+        place_multileg_order([
+            {'symbol': 'AAPL250321C00150000', 'side': OrderSide.BUY},
+            {'symbol': 'AAPL250321C00160000', 'side': OrderSide.SELL},
+        ], qty=1, limit_price=3.10)
+
+    Args:
+        legs (list[dict]): Two to four legs as { 'symbol', 'side', 'ratio_qty' (default 1) }.
+        qty (int): The number of times the strategy is bought.
+        limit_price (Optional[float], optional): Net price of the strategy, positive
+                    for a debit and negative for a credit. Defaults to None (market order).
+        time_in_force (TimeInForce, optional): Defaults to TimeInForce.DAY.
+        client_order_id (Optional[str], optional): When provided the order is
+                    submitted idempotently under this ID. Defaults to None.
+
+    Returns:
+        str: The ID of the multi-leg order.
+
+    Raises:
+        ValueError: If the legs or quantity are invalid.
+        Exception: If the order placement fails.
+    """
+    if not MIN_LEGS <= len(legs) <= MAX_LEGS:
+        raise ValueError(f'Multi-leg orders need {MIN_LEGS} to {MAX_LEGS} legs.')
+    if len({leg['symbol'] for leg in legs}) != len(legs):
+        raise ValueError('Multi-leg order legs must be different contracts.')
+    if qty <= 0 or int(qty) != qty:
+        raise ValueError('Option quantity must be a positive whole number of contracts.')
+    for leg in legs:
+        parse_option_symbol(leg['symbol'])
+    try:
+        fields = dict(
+            qty=qty,
+            time_in_force=time_in_force,
+            order_class=OrderClass.MLEG,
+            legs=[
+                OptionLegRequest(symbol=leg['symbol'], side=leg['side'], ratio_qty=leg.get('ratio_qty', 1))
+                for leg in legs
+            ],
+            client_order_id=client_order_id
+        )
+        order = LimitOrderRequest(limit_price=limit_price, **fields) if limit_price is not None else MarketOrderRequest(**fields)
+        submitted_order = _submit(order, client_order_id)
+        logger.info(f"Multi-leg option order placed for {qty}x {[leg['symbol'] for leg in legs]}")
+        return str(submitted_order.id)
+    except Exception as e:
+        raise Exception(f"Failed to place multi-leg option order: {e}") from e
+
+
+def _submit(order, client_order_id: Optional[str]):
+    """
+    Submit an order, idempotently when it has a client order ID.
+    """
+    if client_order_id:
+        return broker.submit_order_idempotent(order)
+    return broker.get_broker_client('trading').submit_order(order)
@@ -0,0 +1,108 @@
+from typing import Optional
+
+# Phrases in broker rejection messages, lowercase, per error class name
+REJECTION_PHRASES = {
+    'InsufficientBuyingPower': ('insufficient buying power', 'insufficient day trading buying power'),
+    'AssetNotTradable': ('not tradable', 'not active', 'asset not found', 'not fractionable', 'cannot be sold short'),
+    'MarketClosed': ('market is closed', 'market hours', 'outside of trading hours'),
+    'RateLimited': ('rate limit', 'too many requests'),
+}
+
+
+class OrderError(Exception):
+    """An order the broker refused or failed to accept, by cause.
+    Strategies can catch the subclasses instead of matching messages.
+
+    Attributes:
+        status_code: HTTP status of the broker response, if any
+        code: Broker error code, if any
+    """
+
+    def __init__(self, message: str, status_code: Optional[int] = None, code: Optional[int] = None):
+        super().__init__(message)
+        self.status_code = status_code
+        self.code = code
+
+
+class InsufficientBuyingPower(OrderError):
+    """The account cannot fund the order."""
+
+
+class AssetNotTradable(OrderError):
+    """The asset is inactive, unknown, or does not support the order (fractional, short)."""
+
+
+class MarketClosed(OrderError):
+    """The order is not accepted outside market hours."""
+
+
+class RateLimited(OrderError):
+    """The broker throttled the request, it can be retried later."""
+
+
+class OrderRejected(OrderError):
+    """The broker rejected the order for any other reason."""
+
+
+def _causes(error: BaseException):
+    """
+    The error followed by the errors it was raised from.
+    """
+    while error is not None:
+        yield error
+        error = error.__cause__ or error.__context__
+
+
+def classify(error: BaseException) -> Optional[type]:
+    """
+    Determine the cause of an order failure from the broker error or
+    any error it was raised from.
+
+    Args:
+        error (BaseException): The failure.
+
+    Returns:
+        Optional[type]: The OrderError subclass, None when the failure is not
+        a broker response (e.g. a connection error or a bug).
+    """
+    for cause in _causes(error):
+        if isinstance(cause, OrderError):
+            return type(cause)
+        status_code = getattr(cause, 'status_code', None)
+        if not isinstance(status_code, int):
+            continue
+        if status_code == 429:
+            return RateLimited
+        message = str(cause).lower()
+        for name, phrases in REJECTION_PHRASES.items():
+            if any(phrase in message for phrase in phrases):
+                return globals()[name]
+        if status_code in (403, 422):
+            return OrderRejected
+    return None
+
+
+def wrap(error: Exception, context: str) -> Exception:
+    """
+    Build the exception to raise for an order failure, typed by its cause.
+
+    Args:
+        error (Exception): The failure.
+        context (str): What was being attempted, e.g. 'Failed to place market order'.
+
+    Returns:
+        Exception: An OrderError subclass when the cause is known, a plain Exception otherwise,
+        either way with the message '<context>: <error>'.
+    """
+    error_class = classify(error)
+    message = f'{context}: {error}'
+    if error_class is None:
+        return Exception(message)
+    cause = next((cause for cause in _causes(error) if getattr(cause, 'status_code', None) is not None), error)
+    status_code = getattr(cause, 'status_code', None)
+    code = getattr(cause, 'code', None)
+    return error_class(
+        message,
+        status_code=status_code if isinstance(status_code, int) else None,
+        code=code if isinstance(code, int) else None
+    )
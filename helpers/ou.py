@@ -0,0 +1,151 @@
+import math
+import random
+from typing import Optional
+from helpers import statistics
+
+
+def fit_ou(data: list[float], dt: float = 1.0) -> dict:
+    """
+    Fit an Ornstein-Uhlenbeck process dx = theta * (mu - x) dt + sigma dW to a
+    spread from its exact AR(1) discretization x_t = a + b * x_{t-1} + e_t.
+
+    Args:
+        data (list[float]): The spread, oldest first.
+        dt (float, optional): Time between observations, in the unit the
+        parameters are wanted in. Defaults to 1, one bar.
+
+    Returns:
+        dict: { 'theta', 'mu', 'sigma', 'half_life', 'stationary_std' }, the
+        half-life in the unit of dt and stationary_std the spread's long run deviation.
+
+    Raises:
+        ValueError: If the series is too short or not mean reverting.
+    """
+    if len(data) < 3:
+        raise ValueError('At least three observations are required to fit an OU process.')
+    previous, current = data[:-1], data[1:]
+    n = len(previous)
+    previous_mean, current_mean = sum(previous) / n, sum(current) / n
+    variance = sum((x - previous_mean) ** 2 for x in previous)
+    if not variance:
+        raise ValueError('Series has no variation.')
+    b = sum((x - previous_mean) * (y - current_mean) for x, y in zip(previous, current)) / variance
+    if not 0 < b < 1:
+        raise ValueError(f'Series is not mean reverting, AR(1) coefficient {b:.4f}.')
+    a = current_mean - b * previous_mean
+    residual_variance = sum((y - a - b * x) ** 2 for x, y in zip(previous, current)) / (n - 2) if n > 2 else 0.0
+    theta = -math.log(b) / dt
+    return {
+        'theta': theta,
+        'mu': a / (1 - b),
+        'sigma': math.sqrt(residual_variance * 2 * theta / (1 - b ** 2)),
+        'half_life': math.log(2) / theta,
+        'stationary_std': math.sqrt(residual_variance / (1 - b ** 2)),
+    }
+
+
+def simulate_paths(
+    theta: float,
+    mu: float,
+    sigma: float,
+    x0: float,
+    steps: int,
+    paths: int = 1000,
+    dt: float = 1.0,
+    seed: Optional[int] = None
+) -> list[list[float]]:
+    """
+    Simulate OU paths with the exact transition, so the paths are unbiased
+    however coarse dt is.
+
+    Args:
+        theta (float): Speed of mean reversion.
+        mu (float): Long run mean.
+        sigma (float): Volatility.
+        x0 (float): Starting value.
+        steps (int): Steps per path.
+        paths (int, optional): Number of paths. Defaults to 1000.
+        dt (float, optional): Time per step. Defaults to 1.
+        seed (Optional[int], optional): Seed for reproducible paths.
+
+    Returns:
+        list[list[float]]: Each path of steps + 1 values starting at x0.
+    """
+    rng = random.Random(seed)
+    decay = math.exp(-theta * dt)
+    deviation = sigma * math.sqrt((1 - decay ** 2) / (2 * theta))
+    simulated = []
+    for _ in range(paths):
+        path = [x0]
+        for _ in range(steps):
+            path.append(mu + (path[-1] - mu) * decay + deviation * rng.gauss(0, 1))
+        simulated.append(path)
+    return simulated
+
+
+def simulate_trades(
+    params: dict,
+    entry_z: float = 2.0,
+    exit_z: float = 0.0,
+    stop_z: Optional[float] = 4.0,
+    max_holding: int = 500,
+    paths: int = 1000,
+    seed: Optional[int] = None
+) -> dict:
+    """
+    Monte Carlo a spread trade before committing capital: enter short the
+    spread entry_z stationary deviations above its mean, exit when it reverts
+    to exit_z, stop out at stop_z, or time out after max_holding steps. The
+    trade is symmetric, so the long side has the same distribution.
+
+    Args:
+        params (dict): OU parameters, e.g. from fit_ou().
+        entry_z (float, optional): Entry in stationary deviations from the mean. Defaults to 2.
+        exit_z (float, optional): Exit in stationary deviations from the mean. Defaults to 0.
+        stop_z (Optional[float], optional): Stop in stationary deviations, None for no stop. Defaults to 4.
+        max_holding (int, optional): Steps before the trade is closed. Defaults to 500.
+        paths (int, optional): Number of simulated trades. Defaults to 1000.
+        seed (Optional[int], optional): Seed for reproducible results.
+
+    Returns:
+        dict: A dictionary containing:
+        - 'expected_holding': Mean steps held.
+        - 'holding_percentiles': { 0.5, 0.9, 0.99: steps }.
+        - 'expected_pnl': Mean P&L per unit of spread.
+        - 'pnl_percentiles': { 0.01, 0.05, 0.5, 0.95: P&L per unit of spread }.
+        - 'win_rate', 'stop_out_probability', 'timeout_probability': Fractions of trades.
+
+    Raises:
+        ValueError: If the levels are not ordered exit_z < entry_z < stop_z.
+    """
+    if not exit_z < entry_z or (stop_z is not None and not entry_z < stop_z):
+        raise ValueError('Levels must be ordered exit_z < entry_z < stop_z.')
+    theta, mu, sigma = params['theta'], params['mu'], params['sigma']
+    stationary_std = sigma / math.sqrt(2 * theta)
+    entry = mu + entry_z * stationary_std
+    exit_level = mu + exit_z * stationary_std
+    stop = mu + stop_z * stationary_std if stop_z is not None else None
+
+    holdings, pnls = [], []
+    stops = timeouts = 0
+    for path in simulate_paths(theta, mu, sigma, entry, max_holding, paths, seed=seed):
+        for step, value in enumerate(path[1:], start=1):
+            if value <= exit_level:
+                break
+            if stop is not None and value >= stop:
+                stops += 1
+                break
+        else:
+            timeouts += 1
+        holdings.append(step)
+        pnls.append(entry - value)
+
+    return {
+        'expected_holding': sum(holdings) / paths,
+        'holding_percentiles': {q: statistics.quantile(holdings, q) for q in (0.5, 0.9, 0.99)},
+        'expected_pnl': sum(pnls) / paths,
+        'pnl_percentiles': {q: statistics.quantile(pnls, q) for q in (0.01, 0.05, 0.5, 0.95)},
+        'win_rate': sum(1 for pnl in pnls if pnl > 0) / paths,
+        'stop_out_probability': stops / paths,
+        'timeout_probability': timeouts / paths,
+    }
@@ -0,0 +1,117 @@
+import math
+from typing import Optional
+from helpers import statistics
+
+# Scales a median absolute deviation to a standard deviation of normal data
+MAD_TO_SIGMA = 1.4826
+
+
+def _median(values: list[float]) -> float:
+    return statistics.quantile(values, 0.5)
+
+
+def clip(values: list[float], lower: Optional[float] = None, upper: Optional[float] = None) -> list[float]:
+    """
+    Clip values to fixed bounds.
+
+    Args:
+        values (list[float]): The values.
+        lower (Optional[float], optional): Lowest value kept, None for no bound.
+        upper (Optional[float], optional): Highest value kept, None for no bound.
+
+    Returns:
+        list[float]: The clipped values.
+    """
+    return [
+        min(max(value, lower if lower is not None else value), upper if upper is not None else value)
+        for value in values
+    ]
+
+
+def winsorize(values: list[float], lower: float = 0.01, upper: float = 0.99) -> list[float]:
+    """
+    Clip values to their own quantiles, so the tails are pulled in rather than dropped.
+
+    Args:
+        values (list[float]): The values, e.g. returns.
+        lower (float, optional): Quantile values below are raised to. Defaults to 0.01.
+        upper (float, optional): Quantile values above are lowered to. Defaults to 0.99.
+
+    Returns:
+        list[float]: The winsorized values.
+
+    Raises:
+        ValueError: If the quantiles are not 0 <= lower <= upper <= 1.
+    """
+    if not 0 <= lower <= upper <= 1:
+        raise ValueError('Quantiles must satisfy 0 <= lower <= upper <= 1.')
+    if not values:
+        return []
+    return clip(values, statistics.quantile(values, lower), statistics.quantile(values, upper))
+
+
+def median_filter(values: list[float], window: int = 5) -> list[float]:
+    """
+    Replace each value with the median of the centered window around it,
+    which removes isolated spikes while keeping level shifts. The window
+    shrinks at the ends of the series.
+
+    Args:
+        values (list[float]): The values.
+        window (int, optional): Odd window length. Defaults to 5.
+
+    Returns:
+        list[float]: The filtered values.
+
+    Raises:
+        ValueError: If the window is not a positive odd number.
+    """
+    if window < 1 or window % 2 == 0:
+        raise ValueError('window must be a positive odd number.')
+    half = window // 2
+    return [_median(values[max(0, i - half):i + half + 1]) for i in range(len(values))]
+
+
+def bad_ticks(prices: list[float], sigma: float = 5.0, reversion: float = 0.5) -> list[int]:
+    """
+    Find prints that jump away from the series and revert on the next tick,
+    the signature of a bad IEX print rather than a real move. Jumps are
+    measured in log returns against a median absolute deviation, so the bad
+    prints do not inflate the scale they are judged by.
+
+    Args:
+        prices (list[float]): Prices ordered oldest to newest.
+        sigma (float, optional): Jump size in robust standard deviations. Defaults to 5.
+        reversion (float, optional): Fraction of the jump the next tick must undo. Defaults to 0.5.
+
+    Returns:
+        list[int]: Indices of the bad ticks.
+    """
+    if len(prices) < 3 or any(price <= 0 for price in prices):
+        return []
+    returns = [math.log(current / previous) for previous, current in zip(prices, prices[1:])]
+    center = _median(returns)
+    scale = _median([abs(r - center) for r in returns]) * MAD_TO_SIGMA
+    if not scale:
+        return []
+    indices = []
+    # returns[i - 1] moves into prices[i], returns[i] moves out of it
+    for i in range(1, len(prices) - 1):
+        jump, back = returns[i - 1] - center, returns[i] - center
+        if abs(jump) > sigma * scale and jump * back < 0 and -back / jump >= reversion:
+            indices.append(i)
+    return indices
+
+
+def remove_bad_ticks(prices: list[float], sigma: float = 5.0, reversion: float = 0.5) -> list[float]:
+    """
+    Replace bad ticks with the previous price, keeping the series aligned.
+    See bad_ticks() for the arguments.
+
+    Returns:
+        list[float]: The cleaned prices.
+    """
+    cleaned = list(prices)
+    for i in bad_ticks(prices, sigma, reversion):
+        cleaned[i] = cleaned[i - 1]
+    return cleaned
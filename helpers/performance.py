@@ -0,0 +1,286 @@
+import math
+import time
+from collections import deque
+from threading import Lock
+from typing import Callable, Optional
+from helpers import admin, statistics
+
+# Trading days in a year, the periods of daily returns
+TRADING_DAYS_PER_YEAR = 252
+
+# Periods in a year of one minute bars over regular sessions
+MINUTES_PER_YEAR = TRADING_DAYS_PER_YEAR * 390
+
+# Live trackers served by the /performance admin route { strategy: LivePerformance }
+trackers = {}
+
+
+def returns_from_equity(equity_curve: list[float]) -> list[float]:
+    """
+    Simple returns between consecutive values of an equity curve.
+
+    Args:
+        equity_curve (list[float]): Account or strategy equity, oldest first.
+
+    Returns:
+        list[float]: One return per step, 0 where the previous equity is not positive.
+    """
+    return [
+        current / previous - 1 if previous > 0 else 0.0
+        for previous, current in zip(equity_curve, equity_curve[1:])
+    ]
+
+
+def _mean(values: list[float]) -> float:
+    return sum(values) / len(values) if values else 0.0
+
+
+def sharpe_ratio(returns: list[float], periods_per_year: int = TRADING_DAYS_PER_YEAR, risk_free_rate: float = 0.0) -> float:
+    """
+    Annualized Sharpe ratio: mean excess return over its standard deviation.
+
+    Args:
+        returns (list[float]): Periodic returns.
+        periods_per_year (int, optional): Return periods in a year. Defaults to TRADING_DAYS_PER_YEAR.
+        risk_free_rate (float, optional): Annual risk free rate. Defaults to 0.
+
+    Returns:
+        float: The ratio, 0 with fewer than two returns or no variation.
+    """
+    if len(returns) < 2:
+        return 0.0
+    excess = [r - risk_free_rate / periods_per_year for r in returns]
+    mean = _mean(excess)
+    deviation = math.sqrt(sum((r - mean) ** 2 for r in excess) / (len(excess) - 1))
+    return mean / deviation * math.sqrt(periods_per_year) if deviation else 0.0
+
+
+def sortino_ratio(returns: list[float], periods_per_year: int = TRADING_DAYS_PER_YEAR, risk_free_rate: float = 0.0) -> float:
+    """
+    Annualized Sortino ratio: mean excess return over the downside deviation,
+    so upside volatility is not penalized.
+
+    Args:
+        returns (list[float]): Periodic returns.
+        periods_per_year (int, optional): Return periods in a year. Defaults to TRADING_DAYS_PER_YEAR.
+        risk_free_rate (float, optional): Annual risk free rate. Defaults to 0.
+
+    Returns:
+        float: The ratio, 0 without returns or losing periods.
+    """
+    if not returns:
+        return 0.0
+    excess = [r - risk_free_rate / periods_per_year for r in returns]
+    downside = math.sqrt(_mean([min(r, 0.0) ** 2 for r in excess]))
+    return _mean(excess) / downside * math.sqrt(periods_per_year) if downside else 0.0
+
+
+def underwater_curve(equity_curve: list[float]) -> list[float]:
+    """
+    Drawdown from the running peak at every point of an equity curve.
+
+    Args:
+        equity_curve (list[float]): Account or strategy equity, oldest first.
+
+    Returns:
+        list[float]: equity / peak - 1 at each point, 0 at new highs and
+        negative while underwater, e.g. -0.12 when 12% below the peak.
+    """
+    curve = []
+    peak = None
+    for equity in equity_curve:
+        peak = equity if peak is None else max(peak, equity)
+        curve.append(equity / peak - 1 if peak > 0 else 0.0)
+    return curve
+
+
+def max_drawdown(equity_curve: list[float]) -> float:
+    """
+    Largest fall of an equity curve from a running peak.
+
+    Args:
+        equity_curve (list[float]): Account or strategy equity, oldest first.
+
+    Returns:
+        float: The drawdown as a fraction of the peak, e.g. 0.12 for 12%.
+    """
+    return max(0.0, -min(underwater_curve(equity_curve), default=0.0))
+
+
+def annualized_return(equity_curve: list[float], periods_per_year: int = TRADING_DAYS_PER_YEAR) -> float:
+    """
+    Compound annual growth rate of an equity curve sampled once per period.
+
+    Returns:
+        float: The annualized return, 0 with fewer than two values or a non-positive start or end,
+        inf when a gain over a few periods compounds past a float, as live reports can early on.
+    """
+    if len(equity_curve) < 2 or equity_curve[0] <= 0 or equity_curve[-1] <= 0:
+        return 0.0
+    years = (len(equity_curve) - 1) / periods_per_year
+    try:
+        return (equity_curve[-1] / equity_curve[0]) ** (1 / years) - 1
+    except OverflowError:
+        return math.inf
+
+
+def calmar_ratio(equity_curve: list[float], periods_per_year: int = TRADING_DAYS_PER_YEAR) -> float:
+    """
+    Annualized return over the maximum drawdown.
+
+    Returns:
+        float: The ratio, 0 when the curve never draws down.
+    """
+    drawdown = max_drawdown(equity_curve)
+    return annualized_return(equity_curve, periods_per_year) / drawdown if drawdown else 0.0
+
+
+def trade_summary(trade_pnls: list[float]) -> dict:
+    """
+    Summarize the P&L of closed trades.
+
+    Args:
+        trade_pnls (list[float]): P&L of each closed trade.
+
+    Returns:
+        dict: { 'trades', 'hit_rate', 'average_win', 'average_loss', 'profit_factor', 'pnl_skewness',
+        'pnl_excess_kurtosis' }, average_loss negative, profit_factor None without losing trades.
+    """
+    wins = [pnl for pnl in trade_pnls if pnl > 0]
+    losses = [pnl for pnl in trade_pnls if pnl < 0]
+    return {
+        'trades': len(trade_pnls),
+        'hit_rate': len(wins) / len(trade_pnls) if trade_pnls else 0.0,
+        'average_win': _mean(wins),
+        'average_loss': _mean(losses),
+        'profit_factor': sum(wins) / -sum(losses) if losses else None,
+        'pnl_skewness': statistics.skewness(trade_pnls) if trade_pnls else 0.0,
+        'pnl_excess_kurtosis': statistics.excess_kurtosis(trade_pnls) if trade_pnls else 0.0,
+    }
+
+
+def exposure(positions: list[float]) -> float:
+    """
+    Fraction of periods a position was held.
+
+    Args:
+        positions (list[float]): Position size at each period.
+
+    Returns:
+        float: The fraction, 0 without periods.
+    """
+    return sum(1 for qty in positions if qty) / len(positions) if positions else 0.0
+
+
+def report(
+    equity_curve: list[float],
+    trade_pnls: Optional[list[float]] = None,
+    positions: Optional[list[float]] = None,
+    periods_per_year: int = TRADING_DAYS_PER_YEAR
+) -> dict:
+    """
+    Performance report for live P&L or a backtest.
+
+    Args:
+        equity_curve (list[float]): Equity sampled once per period, capital plus P&L.
+        trade_pnls (Optional[list[float]], optional): P&L of each closed trade.
+        positions (Optional[list[float]], optional): Position size at each period, for exposure.
+        periods_per_year (int, optional): Periods of the equity curve in a year. Defaults to TRADING_DAYS_PER_YEAR.
+
+    Returns:
+        dict: { 'total_return', 'annualized_return', 'sharpe', 'sortino', 'calmar', 'max_drawdown' },
+        plus the trade_summary() fields with trades and 'exposure' with positions.
+    """
+    returns = returns_from_equity(equity_curve)
+    result = {
+        'total_return': equity_curve[-1] / equity_curve[0] - 1 if equity_curve and equity_curve[0] > 0 else 0.0,
+        'annualized_return': annualized_return(equity_curve, periods_per_year),
+        'sharpe': sharpe_ratio(returns, periods_per_year),
+        'sortino': sortino_ratio(returns, periods_per_year),
+        'calmar': calmar_ratio(equity_curve, periods_per_year),
+        'max_drawdown': max_drawdown(equity_curve),
+    }
+    if trade_pnls is not None:
+        result.update(trade_summary(trade_pnls))
+    if positions is not None:
+        result['exposure'] = exposure(positions)
+    return result
+
+
+class LivePerformance:
+    """Samples a running strategy's equity and closed trades for report().
+
+    Equity is sampled at most once per period_seconds as it is marked, so
+    the curve's periods are comparable to a backtest's bars. Samples and
+    trades beyond the window are forgotten.
+
+    Attributes:
+        strategy: Name the report is served under
+        capital: Capital the P&L is added to for the equity curve
+        period_seconds: Shortest time between equity samples
+        monotonic: Source of elapsed time, replaceable in tests
+        equity_curve: Equity samples, oldest first
+        trade_pnls: P&L of each closed trade, oldest first
+        lock: Thread lock around the samples and trades
+    """
+
+    def __init__(
+        self,
+        strategy: str,
+        capital: float,
+        period_seconds: float = 60.0,
+        window: int = 100_000,
+        monotonic: Callable[[], float] = time.monotonic
+    ):
+        """Initializes the tracker with the capital as the first equity sample.
+
+        Args:
+            strategy: Name the report is served under
+            capital: Capital the P&L is added to for the equity curve
+            period_seconds: Shortest time between equity samples
+            window: Most samples and trades kept
+            monotonic: Source of elapsed time, replaceable in tests
+        """
+        self.strategy = strategy
+        self.capital = capital
+        self.period_seconds = period_seconds
+        self.monotonic = monotonic
+        self.equity_curve = deque([capital], maxlen=window)
+        self.trade_pnls = deque(maxlen=window)
+        self.lock = Lock()
+        self._sampled_at = monotonic()
+
+    def record_equity(self, pnl: float) -> None:
+        """Samples capital plus the strategy's total P&L once its period has passed."""
+        with self.lock:
+            now = self.monotonic()
+            if now - self._sampled_at < self.period_seconds:
+                return
+            self._sampled_at = now
+            self.equity_curve.append(self.capital + pnl)
+
+    def record_trade(self, pnl: float) -> None:
+        """Records a closed trade's realized P&L."""
+        with self.lock:
+            self.trade_pnls.append(pnl)
+
+    def report(self) -> dict:
+        """Returns report() of the samples and trades so far, annualized by the sampling period."""
+        with self.lock:
+            equity_curve, trade_pnls = list(self.equity_curve), list(self.trade_pnls)
+        periods_per_year = max(int(MINUTES_PER_YEAR * 60 / self.period_seconds), 1)
+        return {'samples': len(equity_curve), **report(equity_curve, trade_pnls, periods_per_year=periods_per_year)}
+
+
+def register_tracker(tracker: LivePerformance) -> LivePerformance:
+    """
+    Make a tracker's report visible to the admin API.
+    """
+    trackers[tracker.strategy] = tracker
+    return tracker
+
+
+admin.register_route('/performance', lambda query, body: {
+    name: tracker.report() for name, tracker in trackers.items()
+    if query.get('strategy') in (None, name)
+})
@@ -0,0 +1,124 @@
+from concurrent.futures import ThreadPoolExecutor
+from helpers import cloud, logger, metrics
+from typing import Callable, Optional
+
+# Initialize logger
+logger = logger.Logger('polling.py')
+
+# SQS limits on a single ReceiveMessage call
+MAX_SQS_BATCH = 10
+MAX_SQS_WAIT_SECONDS = 20
+
+
+class AdaptivePoller:
+    """Receives from an SQS queue at a rate matched to its backlog.
+
+    A drained queue is long polled with a single small request, which keeps
+    the number of billed requests low while idle. Full batches, or a known
+    backlog, double the batch size up to the SQS limit and then add
+    concurrent receive requests, so a consumer catching up after a burst
+    drains the queue quickly. Messages are still returned to a single
+    caller, only the receiving is concurrent.
+
+    Attributes:
+        queue_url: URL of the consumed SQS queue
+        max_batch: Largest messages per receive request
+        max_workers: Largest number of concurrent receive requests
+        idle_wait_seconds: Long poll wait while the queue is drained
+        busy_wait_seconds: Wait per request while there is a backlog
+        batch_size: Current messages per receive request
+        workers: Current number of concurrent receive requests
+        receive: Receive function, replaceable in tests
+    """
+
+    def __init__(
+        self,
+        queue_url: str,
+        max_batch: int = MAX_SQS_BATCH,
+        max_workers: int = 4,
+        idle_wait_seconds: int = MAX_SQS_WAIT_SECONDS,
+        busy_wait_seconds: int = 1,
+        receive: Callable[..., list] = cloud.poll_sqs_message
+    ):
+        """Initializes the poller in idle mode.
+
+        Args:
+            queue_url: URL of the SQS queue to consume
+            max_batch: Largest messages per receive request, at most MAX_SQS_BATCH
+            max_workers: Largest number of concurrent receive requests
+            idle_wait_seconds: Long poll wait while the queue is drained, at most MAX_SQS_WAIT_SECONDS
+            busy_wait_seconds: Wait per request while there is a backlog
+            receive: Receive function with cloud.poll_sqs_message's signature
+        """
+        if not 1 <= max_batch <= MAX_SQS_BATCH or max_workers < 1:
+            raise ValueError(f'max_batch must be 1 to {MAX_SQS_BATCH} and max_workers at least 1.')
+        self.queue_url = queue_url
+        self.max_batch = max_batch
+        self.max_workers = max_workers
+        self.idle_wait_seconds = min(idle_wait_seconds, MAX_SQS_WAIT_SECONDS)
+        self.busy_wait_seconds = busy_wait_seconds
+        self.receive = receive
+        self.batch_size = 1
+        self.workers = 1
+        self._executor = ThreadPoolExecutor(max_workers=max_workers) if max_workers > 1 else None
+
+    @property
+    def idle(self) -> bool:
+        """Whether the poller is in its drained, long polling mode."""
+        return self.batch_size == 1 and self.workers == 1
+
+    def poll(self, backlog: Optional[int] = None) -> list:
+        """
+        Receive the next messages and adapt to how many there were.
+
+        Args:
+            backlog (Optional[int], optional): Visible messages last reported for the queue,
+                                               e.g. QueueLagMonitor.backlog. Defaults to None.
+
+        Returns:
+            list: The messages received, possibly empty.
+
+        Raises:
+            Exception: If every receive request fails.
+        """
+        wait = self.idle_wait_seconds if self.idle else self.busy_wait_seconds
+        if self.workers == 1:
+            messages = self.receive(self.queue_url, max_messages=self.batch_size, wait_time_seconds=wait)
+        else:
+            futures = [
+                self._executor.submit(self.receive, self.queue_url, max_messages=self.batch_size, wait_time_seconds=wait)
+                for _ in range(self.workers)
+            ]
+            messages, errors = [], []
+            for future in futures:
+                try:
+                    messages.extend(future.result())
+                except Exception as e:
+                    errors.append(e)
+            if len(errors) == len(futures):
+                raise errors[0]
+            for error in errors:
+                logger.error(f'Error in concurrent receive from {self.queue_url}: {error}')
+        self.adapt(len(messages), backlog)
+        return messages
+
+    def adapt(self, received: int, backlog: Optional[int] = None) -> None:
+        """
+        Scale up after full batches or with a backlog, back to idle once drained.
+        """
+        capacity = self.batch_size * self.workers
+        if received == 0 and not backlog:
+            self.batch_size, self.workers = 1, 1
+        elif received >= capacity or (backlog or 0) > capacity:
+            if self.batch_size < self.max_batch:
+                self.batch_size = min(self.batch_size * 2, self.max_batch)
+            elif self.workers < self.max_workers:
+                self.workers += 1
+        elif received < capacity // 2:
+            # Shrink one step at a time so a lull mid-burst does not drop straight to idle
+            if self.workers > 1:
+                self.workers -= 1
+            else:
+                self.batch_size = max(self.batch_size // 2, 1)
+        metrics.set_gauge('poll_batch_size', self.batch_size, queue=self.queue_url)
+        metrics.set_gauge('poll_workers', self.workers, queue=self.queue_url)
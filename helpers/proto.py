@@ -0,0 +1,188 @@
+import base64
+from datetime import datetime, timedelta, timezone
+from functools import lru_cache
+
+# Fields of each message in proto/market_data.proto as (name, number, type)
+MESSAGES = {
+    'BarData': [
+        ('symbol', 1, 'string'),
+        ('timestamp_us', 2, 'int64'),
+        ('open', 3, 'double'),
+        ('high', 4, 'double'),
+        ('low', 5, 'double'),
+        ('close', 6, 'double'),
+        ('volume', 7, 'double'),
+        ('trade_count', 8, 'int64'),
+        ('backfill', 9, 'bool'),
+        ('timeframe', 10, 'string'),
+        ('synthetic', 11, 'bool'),
+    ],
+    'TradeData': [
+        ('symbol', 1, 'string'),
+        ('timestamp_us', 2, 'int64'),
+        ('price', 3, 'double'),
+        ('size', 4, 'double'),
+        ('exchange', 5, 'string'),
+        ('trade_id', 6, 'int64'),
+    ],
+    'QuoteData': [
+        ('symbol', 1, 'string'),
+        ('timestamp_us', 2, 'int64'),
+        ('bid_price', 3, 'double'),
+        ('bid_size', 4, 'double'),
+        ('ask_price', 5, 'double'),
+        ('ask_size', 6, 'double'),
+    ],
+    'Envelope': [
+        ('schema', 1, 'string'),
+        ('produced_at_us', 2, 'int64'),
+        ('source', 3, 'string'),
+        ('build', 4, 'string'),
+        ('bar', 10, 'BarData'),
+        ('trade', 11, 'TradeData'),
+        ('quote', 12, 'QuoteData'),
+    ],
+}
+
+# Envelope payload field per message kind, other kinds are only published as JSON
+PAYLOAD_FIELDS = {
+    'bar': 'bar',
+    'trade': 'trade',
+    'quote': 'quote',
+}
+
+# Origin of the microsecond timestamps
+EPOCH = datetime(1970, 1, 1, tzinfo=timezone.utc)
+
+
+def to_micros(timestamp: str) -> int:
+    """Returns an ISO timestamp as microseconds since the epoch."""
+    return (datetime.fromisoformat(timestamp) - EPOCH) // timedelta(microseconds=1)
+
+
+def from_micros(micros: int) -> str:
+    """Returns microseconds since the epoch as a UTC ISO timestamp."""
+    return (EPOCH + timedelta(microseconds=micros)).isoformat()
+
+
+@lru_cache(maxsize=None)
+def message_classes() -> dict:
+    """
+    Builds the message classes from MESSAGES, so no generated code is needed.
+
+    Returns:
+        dict: { message name: message class }.
+    """
+    from google.protobuf import descriptor_pb2, descriptor_pool, message_factory
+
+    field_types = {
+        'string': descriptor_pb2.FieldDescriptorProto.TYPE_STRING,
+        'int64': descriptor_pb2.FieldDescriptorProto.TYPE_INT64,
+        'double': descriptor_pb2.FieldDescriptorProto.TYPE_DOUBLE,
+        'bool': descriptor_pb2.FieldDescriptorProto.TYPE_BOOL,
+    }
+    file = descriptor_pb2.FileDescriptorProto(name='nexus/market_data.proto', package='nexus', syntax='proto3')
+    for name, fields in MESSAGES.items():
+        message = file.message_type.add(name=name)
+        if name == 'Envelope':
+            message.oneof_decl.add(name='payload')
+        for field_name, number, field_type in fields:
+            field = message.field.add(
+                name=field_name, number=number, label=descriptor_pb2.FieldDescriptorProto.LABEL_OPTIONAL
+            )
+            if field_type in field_types:
+                field.type = field_types[field_type]
+            else:
+                field.type = descriptor_pb2.FieldDescriptorProto.TYPE_MESSAGE
+                field.type_name = f'.nexus.{field_type}'
+                field.oneof_index = 0
+    pool = descriptor_pool.DescriptorPool()
+    pool.AddSerializedFile(file.SerializeToString())
+    return {name: message_factory.GetMessageClass(pool.FindMessageTypeByName(f'nexus.{name}')) for name in MESSAGES}
+
+
+def _payload_to_proto(payload: dict, target) -> None:
+    for name, _, _ in MESSAGES[target.DESCRIPTOR.name]:
+        if name == 'timestamp_us':
+            target.timestamp_us = to_micros(payload['timestamp'])
+        elif name == 'trade_count':
+            target.trade_count = payload['trade_count'] if payload.get('trade_count') is not None else -1
+        elif name == 'exchange':
+            target.exchange = payload.get('exchange') or ''
+        elif name == 'trade_id':
+            target.trade_id = payload['trade_id'] if payload.get('trade_id') is not None else -1
+        elif name in ('backfill', 'synthetic'):
+            setattr(target, name, bool(payload.get(name)))
+        elif name == 'timeframe':
+            target.timeframe = payload.get('timeframe') or ''
+        else:
+            setattr(target, name, payload[name])
+
+
+def _payload_from_proto(message) -> dict:
+    payload = {}
+    for name, _, _ in MESSAGES[message.DESCRIPTOR.name]:
+        value = getattr(message, name)
+        if name == 'timestamp_us':
+            payload['timestamp'] = from_micros(value)
+        elif name == 'trade_count':
+            payload['trade_count'] = value if value >= 0 else None
+        elif name == 'exchange':
+            payload['exchange'] = value or None
+        elif name == 'trade_id':
+            payload['trade_id'] = value if value >= 0 else None
+        elif name in ('backfill', 'synthetic', 'timeframe'):
+            # Only flagged bars carry these keys, as in the JSON messages
+            if value:
+                payload[name] = value
+        else:
+            payload[name] = value
+    return payload
+
+
+def encode(message: dict) -> str:
+    """
+    Serialize an envelope from envelope.wrap() as a base64 protobuf Envelope,
+    base64 since SNS messages are text.
+
+    Args:
+        message (dict): The envelope, its payload a bar, trade, or quote.
+
+    Returns:
+        str: The encoded message.
+
+    Raises:
+        ValueError: If the payload's kind has no protobuf message.
+    """
+    kind = message['schema'].partition('.v')[0]
+    if kind not in PAYLOAD_FIELDS:
+        raise ValueError(f'No protobuf message for {kind}, expected one of {", ".join(PAYLOAD_FIELDS)}.')
+    wire = message_classes()['Envelope'](
+        schema=message['schema'],
+        produced_at_us=to_micros(message['producedAt']),
+        source=message.get('source') or '',
+        build=message.get('build') or ''
+    )
+    _payload_to_proto(message['payload'], getattr(wire, PAYLOAD_FIELDS[kind]))
+    return base64.b64encode(wire.SerializeToString()).decode()
+
+
+def decode(data: str) -> dict:
+    """
+    Parse a message from encode() back into the envelope's dictionary form.
+
+    Args:
+        data (str): The encoded message.
+
+    Returns:
+        dict: { 'schema', 'producedAt', 'source', 'build', 'payload' } as envelope.wrap() returns.
+    """
+    wire = message_classes()['Envelope']()
+    wire.ParseFromString(base64.b64decode(data))
+    return {
+        'schema': wire.schema,
+        'producedAt': from_micros(wire.produced_at_us),
+        'source': wire.source or None,
+        'build': wire.build or None,
+        'payload': _payload_from_proto(getattr(wire, wire.WhichOneof('payload'))),
+    }
@@ -0,0 +1,227 @@
+import time
+import zlib
+import queue
+import threading
+from helpers import cloud, envelope, kafka, kinesis, logger, metrics, nats_transport
+from typing import Callable, Optional
+
+# Initialize logger
+logger = logger.Logger('publisher.py')
+
+# SNS limits on a single PublishBatch call
+MAX_SNS_BATCH = 10
+MAX_SNS_BATCH_BYTES = 256 * 1024
+
+# Ways messages can be published, see publishers()
+TRANSPORTS = ('sns', 'kinesis', 'kafka', 'nats')
+
+# Transports keeping each symbol's messages in order, published by one worker per symbol, see ordering_key()
+ORDERED_TRANSPORTS = ('kinesis', 'kafka', 'nats')
+
+
+class BatchPublisher:
+    """Publishes SNS messages in batches from worker threads.
+
+    Stream handlers hand messages to publish() and return immediately, the
+    workers group them by topic and send a PublishBatch once batch_size
+    messages are waiting or flush_seconds have passed since the first one.
+    The queue is bounded so a stalled SNS cannot exhaust memory, messages
+    offered while it is full are dropped and counted. With a key, each
+    worker has its own queue and every message of a key goes to the same
+    one, so an ordered transport receives a key's messages in order. Each
+    message is stamped when queued, and the time until its batch was sent
+    is observed as publish_queue_seconds.
+
+    Attributes:
+        batch_size: Most messages per PublishBatch request
+        flush_seconds: Longest a message waits for its batch to fill
+        workers: Number of publishing threads
+        key: Ordering key of a message, None lets any worker publish any message
+        queues: Bounded queues of (message, topic, queued_at) waiting to be published, one shared or one per worker with a key
+        publish_batch: Batch publish function, replaceable in tests
+    """
+
+    def __init__(
+        self,
+        batch_size: int = MAX_SNS_BATCH,
+        flush_seconds: float = 0.5,
+        max_queue: int = 10_000,
+        workers: int = 2,
+        publish_batch: Callable[[list[str], str], dict] = cloud.publish_sns_batch,
+        key: Optional[Callable[[str], str]] = None
+    ):
+        """Initializes the publisher, call start() to run the workers.
+
+        Args:
+            batch_size: Most messages per PublishBatch request, at most MAX_SNS_BATCH
+            flush_seconds: Longest a message waits for its batch to fill
+            max_queue: Messages held before new ones are dropped, split between the workers with a key
+            workers: Number of publishing threads
+            publish_batch: Batch publish function with cloud.publish_sns_batch's signature
+            key: Ordering key of a message, see ordering_key(), None when the transport is unordered
+        """
+        if not 1 <= batch_size <= MAX_SNS_BATCH or workers < 1:
+            raise ValueError(f'batch_size must be 1 to {MAX_SNS_BATCH} and workers at least 1.')
+        self.batch_size = batch_size
+        self.flush_seconds = flush_seconds
+        self.workers = workers
+        self.key = key
+        if key is None:
+            self.queues = [queue.Queue(maxsize=max_queue)]
+        else:
+            self.queues = [queue.Queue(maxsize=max(max_queue // workers, 1)) for _ in range(workers)]
+        self.publish_batch = publish_batch
+        self._stopped = threading.Event()
+        self._threads = []
+
+    def start(self) -> 'BatchPublisher':
+        """Starts the worker threads, returning the publisher."""
+        for i in range(self.workers):
+            thread = threading.Thread(target=self._work, args=(self.queues[i % len(self.queues)],), daemon=True)
+            thread.start()
+            self._threads.append(thread)
+        return self
+
+    def publish(self, data: str, topic: str) -> bool:
+        """Queues a message, returning False when the queue is full and it was dropped."""
+        shard = zlib.crc32(self.key(data).encode()) % len(self.queues) if self.key else 0
+        try:
+            self.queues[shard].put_nowait((data, topic, time.monotonic()))
+            return True
+        except queue.Full:
+            metrics.increment('publish_dropped', topic=topic)
+            logger.error(f'Publish queue full, dropped a message for {topic}')
+            return False
+
+    def flush(self, timeout: Optional[float] = None) -> bool:
+        """Blocks until every queued message has been published or has failed, returning False if the timeout passed first."""
+        deadline = None if timeout is None else time.monotonic() + timeout
+        for shard in self.queues:
+            with shard.all_tasks_done:
+                remaining = None if deadline is None else max(deadline - time.monotonic(), 0)
+                if not shard.all_tasks_done.wait_for(lambda: not shard.unfinished_tasks, remaining):
+                    return False
+        return True
+
+    def stop(self, timeout: Optional[float] = None) -> list[tuple[str, str]]:
+        """Publishes what is queued within the timeout, then stops the workers.
+
+        Returns:
+            list[tuple[str, str]]: The (message, topic) pairs still queued when the timeout passed
+        """
+        self.flush(timeout)
+        self._stopped.set()
+        for thread in self._threads:
+            thread.join(self.flush_seconds + 1)
+        self._threads = []
+        remaining = []
+        for shard in self.queues:
+            while True:
+                try:
+                    data, topic, _ = shard.get_nowait()
+                    remaining.append((data, topic))
+                except queue.Empty:
+                    break
+        return remaining
+
+    def _take(self, shard: queue.Queue) -> list:
+        """Waits for a message, then collects more until the batch is full or the flush interval passes."""
+        try:
+            taken = [shard.get(timeout=self.flush_seconds)]
+        except queue.Empty:
+            return []
+        deadline = time.monotonic() + self.flush_seconds
+        while len(taken) < self.batch_size:
+            remaining = deadline - time.monotonic()
+            if remaining <= 0:
+                break
+            try:
+                taken.append(shard.get(timeout=remaining))
+            except queue.Empty:
+                break
+        return taken
+
+    def _work(self, shard: queue.Queue) -> None:
+        while not self._stopped.is_set():
+            taken = self._take(shard)
+            by_topic = {}
+            for data, topic, queued_at in taken:
+                by_topic.setdefault(topic, []).append((data, queued_at))
+            for topic, entries in by_topic.items():
+                sent = 0
+                for batch in batches([data for data, _ in entries], self.batch_size):
+                    self._send(batch, topic, [queued_at for _, queued_at in entries[sent:sent + len(batch)]])
+                    sent += len(batch)
+            for _ in taken:
+                shard.task_done()
+
+    def _send(self, batch: list[str], topic: str, queued_at: list[float]) -> None:
+        """Publishes one batch, counting the messages that were not published and timing the request and each message."""
+        started = time.monotonic()
+        try:
+            failed = len(self.publish_batch(batch, topic).get('Failed', []))
+        except Exception as e:
+            failed = len(batch)
+            logger.error(f'Error publishing batch of {len(batch)} messages to {topic}: {e}')
+        finished = time.monotonic()
+        metrics.observe('publish_batch_seconds', finished - started, topic=topic)
+        for stamp in queued_at:
+            metrics.observe('publish_queue_seconds', finished - stamp, topic=topic)
+        metrics.set_gauge('publish_queue_depth', sum(shard.qsize() for shard in self.queues))
+        metrics.increment('published_messages', len(batch) - failed, topic=topic)
+        if failed:
+            metrics.increment('publish_failures', failed, topic=topic)
+
+
+def batches(messages: list[str], size: int = MAX_SNS_BATCH) -> list[list[str]]:
+    """
+    Split messages into PublishBatch requests of at most size messages and MAX_SNS_BATCH_BYTES.
+
+    Args:
+        messages (list[str]): The message bodies in order.
+        size (int, optional): Most messages per batch. Defaults to MAX_SNS_BATCH.
+
+    Returns:
+        list[list[str]]: The batches in order.
+    """
+    grouped, batch, batch_bytes = [], [], 0
+    for data in messages:
+        length = len(data.encode())
+        if batch and (len(batch) == size or batch_bytes + length > MAX_SNS_BATCH_BYTES):
+            grouped.append(batch)
+            batch, batch_bytes = [], 0
+        batch.append(data)
+        batch_bytes += length
+    if batch:
+        grouped.append(batch)
+    return grouped
+
+
+def ordering_key(data: str) -> str:
+    """Returns what an ordered transport keeps a message in order by, its symbol, or its kind without one."""
+    kind, symbol = envelope.route(data)
+    return str(symbol or kind)
+
+
+def publishers(transport: str) -> tuple:
+    """
+    Look up the publish functions of a transport.
+
+    Args:
+        transport (str): One of TRANSPORTS.
+
+    Returns:
+        tuple: (publish, publish_batch) with cloud.publish_sns_message's and cloud.publish_sns_batch's signatures.
+
+    Raises:
+        ValueError: If the transport is unknown.
+    """
+    if transport == 'sns':
+        return cloud.publish_sns_message, cloud.publish_sns_batch
+    if transport == 'kinesis':
+        return kinesis.publish, kinesis.publish_batch
+    if transport == 'kafka':
+        return kafka.publish, kafka.publish_batch
+    if transport == 'nats':
+        return nats_transport.publish, nats_transport.publish_batch
+    raise ValueError(f'Unknown message transport {transport}, expected one of {", ".join(TRANSPORTS)}.')
@@ -0,0 +1,132 @@
+import math
+from helpers import brokers, logger
+from alpaca.trading.enums import OrderSide
+from typing import Optional
+
+# Initialize logger
+logger = logger.Logger('rebalance.py')
+
+
+def compute_rebalance_orders(
+    target_weights: dict[str, float],
+    positions: dict[str, float],
+    prices: dict[str, float],
+    equity: float,
+    drift_threshold: float = 0.01,
+    allow_fractional: bool = False,
+    max_gross_weight: float = 1.0
+) -> list[dict]:
+    """
+    Compute the minimal set of orders that moves current positions to target weights.
+    Symbols held but missing from the targets are closed. A symbol is only traded
+    when its weight has drifted from target by at least drift_threshold, so small
+    price moves do not cause churn.
+
+    Args:
+        target_weights (dict[str, float]): { symbol: fraction of equity },
+                                           negative weights are shorts.
+        positions (dict[str, float]): { symbol: current signed share quantity }.
+        prices (dict[str, float]): { symbol: current price } for every symbol involved.
+        equity (float): The account equity the weights apply to.
+        drift_threshold (float, optional): Minimum absolute weight drift to trade.
+                                           Defaults to 0.01 (1% of equity).
+        allow_fractional (bool, optional): Allow fractional share quantities,
+                                           otherwise quantities round toward zero.
+                                           Defaults to False.
+        max_gross_weight (float, optional): Maximum sum of absolute target weights.
+                                            Defaults to 1.0 (no leverage).
+
+    Returns:
+        list[dict]: Orders as { 'symbol', 'qty' (signed), 'side' }, sells first so
+        they free up buying power for the buys.
+
+    Raises:
+        ValueError: If equity is not positive, the targets are too leveraged,
+                    or a price is missing.
+    """
+    if equity <= 0:
+        raise ValueError('Equity must be positive to rebalance.')
+    gross_weight = sum(abs(weight) for weight in target_weights.values())
+    if gross_weight > max_gross_weight + 1e-9:
+        raise ValueError(f'Gross target weight {gross_weight:.2f} exceeds {max_gross_weight:.2f}.')
+
+    orders = []
+    for symbol in sorted(set(target_weights) | set(positions)):
+        price = prices.get(symbol)
+        if not price or price <= 0:
+            raise ValueError(f'Missing price for {symbol}.')
+        current_qty = positions.get(symbol, 0.0)
+        current_weight = current_qty * price / equity
+        target_weight = target_weights.get(symbol, 0.0)
+        # Always fully close positions dropped from the targets
+        if target_weight == 0 and current_qty != 0:
+            qty = -current_qty
+        else:
+            if abs(target_weight - current_weight) < drift_threshold:
+                continue
+            qty = (target_weight - current_weight) * equity / price
+            if not allow_fractional:
+                qty = float(math.trunc(qty))
+        if qty == 0:
+            continue
+        orders.append({
+            'symbol': symbol,
+            'qty': qty,
+            'side': OrderSide.BUY if qty > 0 else OrderSide.SELL,
+        })
+    return sorted(orders, key=lambda order: order['qty'] > 0)
+
+
+def rebalance(
+    target_weights: dict[str, float],
+    prices: Optional[dict[str, float]] = None,
+    drift_threshold: float = 0.01,
+    allow_fractional: bool = False,
+    dry_run: bool = False
+) -> list[dict]:
+    """
+    Rebalance the account to target weights using live positions and equity.
+
+    Args:
+        target_weights (dict[str, float]): { symbol: fraction of equity }.
+        prices (Optional[dict[str, float]], optional): Current prices, required for
+            target symbols not currently held. Held symbols default to the
+            position's current price. Defaults to None.
+        drift_threshold (float, optional): Minimum absolute weight drift to trade.
+                                           Defaults to 0.01.
+        allow_fractional (bool, optional): Allow fractional share quantities.
+                                           Defaults to False.
+        dry_run (bool, optional): Compute and log the orders without submitting.
+                                  Defaults to False.
+
+    Returns:
+        list[dict]: The orders computed, each with a 'submitted' flag.
+    """
+    positions = brokers.get_broker().get_positions()
+    equity = brokers.get_broker().get_account_equity()
+    all_prices = {symbol: position['current_price'] for symbol, position in positions.items()}
+    all_prices.update(prices or {})
+    orders = compute_rebalance_orders(
+        target_weights,
+        {symbol: position['qty'] for symbol, position in positions.items()},
+        all_prices,
+        equity,
+        drift_threshold=drift_threshold,
+        allow_fractional=allow_fractional
+    )
+    logger.info(f'Rebalance of ${equity:.2f} equity requires {len(orders)} orders')
+    for order in orders:
+        order['submitted'] = False
+        if dry_run:
+            logger.info(f"Dry run rebalance order: {order['side'].value} {abs(order['qty'])} {order['symbol']}")
+            continue
+        try:
+            brokers.get_broker().submit_market_order(
+                symbol=order['symbol'],
+                qty=abs(order['qty']),
+                side=order['side']
+            )
+            order['submitted'] = True
+        except Exception as e:
+            logger.error(f"Error submitting rebalance order for {order['symbol']}: {e}")
+    return orders
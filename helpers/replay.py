@@ -0,0 +1,161 @@
+import re
+import heapq
+import asyncio
+import time
+from datetime import date, datetime, timedelta
+from helpers import archive, broker, brokers, cloud, logger
+from helpers.domain import converters
+from typing import Awaitable, Callable, Iterable, Iterator, Optional
+
+# Initialize logger
+logger = logger.Logger('replay.py')
+
+# Record types that can be replayed
+RECORD_TYPES = ('bars', 'trades')
+
+# Named replay speeds, None publishes as fast as possible
+SPEEDS = {
+    'realtime': 1.0,
+    'max': None,
+}
+
+# Streamed bars are published when they close, a minute after their timestamp
+BAR_SECONDS = 60
+
+
+def parse_speed(text: str) -> Optional[float]:
+    """
+    Parse a replay speed.
+
+    Args:
+        text (str): A key of SPEEDS or a multiple of real time, e.g. '10x' or '0.5x'.
+
+    Returns:
+        Optional[float]: The multiple of real time, None for as fast as possible.
+
+    Raises:
+        ValueError: If the speed is malformed or not positive.
+    """
+    text = text.strip().lower()
+    if text in SPEEDS:
+        return SPEEDS[text]
+    match = re.fullmatch(r'(\d+(\.\d+)?)x', text)
+    if not match or float(match.group(1)) <= 0:
+        raise ValueError(f"Malformed replay speed {text}, expected {', '.join(SPEEDS)}, or a multiple such as '10x'.")
+    return float(match.group(1))
+
+
+def release_time(record_type: str, message: dict) -> datetime:
+    """Returns when the live stream would have delivered a record, bars at their close and trades as printed."""
+    timestamp = datetime.fromisoformat(message['timestamp'])
+    return timestamp + timedelta(seconds=BAR_SECONDS) if record_type == 'bars' else timestamp
+
+
+def load_alpaca(record_type: str, symbols: list[str], start: datetime, end: datetime) -> list[dict]:
+    """
+    Read historical records from the broker's data API.
+
+    Args:
+        record_type (str): One of RECORD_TYPES.
+        symbols (list[str]): The symbols to read.
+        start (datetime): Start of the range, timezone aware.
+        end (datetime): End of the range, timezone aware.
+
+    Returns:
+        list[dict]: The records in the formats the data service publishes, in release order.
+    """
+    if record_type == 'bars':
+        history = brokers.get_broker().get_bars(symbols, start, end, '1Min')
+        records = [bar for bars in history.values() for bar in bars]
+    else:
+        history = broker.get_historical_trade_data(symbols, start, end)
+        records = [converters.trade_from_alpaca(trade).to_dict() for trades in history.values() for trade in trades]
+    return sorted(records, key=lambda record: release_time(record_type, record))
+
+
+def load_archive(
+    prefix: str,
+    record_type: str,
+    symbols: list[str],
+    start: date,
+    end: date,
+    list_objects: Callable[[str], list[str]] = cloud.list_s3_objects,
+    read: Callable[[str], bytes] = cloud.read_s3_bytes
+) -> list[dict]:
+    """
+    Read records written by archive.ArchiveSink.
+
+    Args:
+        prefix (str): The archive root as s3://bucket/prefix.
+        record_type (str): One of RECORD_TYPES.
+        symbols (list[str]): The symbols to read.
+        start (date): First UTC day to read.
+        end (date): Last UTC day to read, inclusive.
+        list_objects (Callable[[str], list[str]], optional): Object listing function, replaceable in tests.
+        read (Callable[[str], bytes], optional): Object read function, replaceable in tests.
+
+    Returns:
+        list[dict]: The records in release order.
+    """
+    records = []
+    for day in range((end - start).days + 1):
+        for symbol in symbols:
+            partition = archive.partition_key(prefix, record_type, symbol, (start + timedelta(days=day)).isoformat())
+            for uri in list_objects(f'{partition}/'):
+                records += archive.decode(read(uri), archive.object_format(uri))
+    return sorted(records, key=lambda record: release_time(record_type, record))
+
+
+def merge(streams: dict[str, list[dict]]) -> Iterator[tuple[str, dict]]:
+    """
+    Interleave each record type's records in release order.
+
+    Args:
+        streams (dict[str, list[dict]]): { record type: records in release order }.
+
+    Returns:
+        Iterator[tuple[str, dict]]: (record type, record) pairs.
+    """
+    return heapq.merge(
+        *[[(record_type, record) for record in records] for record_type, records in streams.items()],
+        key=lambda item: release_time(*item)
+    )
+
+
+async def replay(
+    records: Iterable[tuple[str, dict]],
+    publish: Callable[[str, dict], Awaitable[None]],
+    speed: Optional[float],
+    is_shutdown: Callable[[], bool] = lambda: False,
+    sleep: Callable[[float], Awaitable[None]] = asyncio.sleep,
+    clock: Callable[[], float] = time.monotonic
+) -> int:
+    """
+    Publish records spaced as they were originally released, scaled by speed.
+
+    Args:
+        records (Iterable[tuple[str, dict]]): (record type, record) pairs in release order, see merge().
+        publish (Callable[[str, dict], Awaitable[None]]): Publishes one record.
+        speed (Optional[float]): Multiple of real time, None to publish as fast as possible.
+        is_shutdown (Callable[[], bool], optional): Returns True to stop early.
+        sleep (Callable[[float], Awaitable[None]], optional): Async sleep, replaceable in tests.
+        clock (Callable[[], float], optional): Monotonic clock in seconds, replaceable in tests.
+
+    Returns:
+        int: The number of records published.
+    """
+    published = 0
+    started, first = clock(), None
+    for record_type, record in records:
+        if is_shutdown():
+            break
+        if speed is not None:
+            released = release_time(record_type, record).timestamp()
+            first = released if first is None else first
+            wait = started + (released - first) / speed - clock()
+            if wait > 0:
+                await sleep(wait)
+        await publish(record_type, record)
+        published += 1
+    logger.info(f'Replayed {published} records')
+    return published
@@ -0,0 +1,116 @@
+from typing import Optional
+from helpers import market_data
+from helpers.spreads import log_returns
+
+# Kinds of returns a price series converts to
+RETURN_KINDS = ('log', 'simple')
+
+
+def simple_returns(prices: list[float]) -> list[float]:
+    """
+    Simple returns between consecutive prices.
+    """
+    return [current / previous - 1 for previous, current in zip(prices, prices[1:])]
+
+
+def to_returns(prices: list[float], kind: str = 'log') -> list[float]:
+    """
+    Convert a price series to returns.
+
+    Args:
+        prices (list[float]): Prices ordered oldest to newest.
+        kind (str, optional): One of RETURN_KINDS. Defaults to 'log'.
+
+    Returns:
+        list[float]: One return per consecutive pair of prices.
+
+    Raises:
+        ValueError: If the kind is unknown.
+    """
+    if kind not in RETURN_KINDS:
+        raise ValueError(f'Unknown return kind {kind}, expected one of {", ".join(RETURN_KINDS)}.')
+    return log_returns(prices) if kind == 'log' else simple_returns(prices)
+
+
+def resample(bars: list[dict], timeframe: str) -> list[dict]:
+    """
+    Resample bars of one symbol to a coarser timeframe.
+
+    Args:
+        bars (list[dict]): Bars ordered oldest to newest.
+        timeframe (str): One of market_data.TIMEFRAME_MINUTES, e.g. '15Min'.
+
+    Returns:
+        list[dict]: One bar per bucket, timestamped at the start of the bucket.
+
+    Raises:
+        ValueError: If the timeframe is unknown.
+    """
+    if timeframe not in market_data.TIMEFRAME_MINUTES:
+        raise ValueError(f'Unknown timeframe {timeframe}, expected one of {", ".join(market_data.TIMEFRAME_MINUTES)}.')
+    return market_data.aggregate_bars(bars, market_data.TIMEFRAME_MINUTES[timeframe])
+
+
+def align_bars(
+    bars: dict[str, list[dict]],
+    forward_fill: bool = True,
+    max_fill: Optional[int] = None,
+    field: str = 'close'
+) -> tuple[list[str], dict[str, list[float]]]:
+    """
+    Align several bar series on the union of their timestamps.
+    A symbol without a bar at a timestamp carries its last value forward, so
+    one thinly traded symbol does not drop every bar it missed from the rest
+    of the universe. Timestamps before every symbol has traded, or where a
+    gap is longer than max_fill, are dropped. Without forward fill only the
+    timestamps every symbol traded are kept.
+
+    Args:
+        bars (dict[str, list[dict]]): { symbol: bars ordered oldest to newest }.
+        forward_fill (bool, optional): Carry values over missing bars. Defaults to True.
+        max_fill (Optional[int], optional): Most consecutive bars carried forward, None for no limit.
+        field (str, optional): The bar field to align. Defaults to 'close'.
+
+    Returns:
+        tuple[list[str], dict[str, list[float]]]: The kept timestamps and { symbol: values } at them.
+    """
+    series = {symbol: {bar['timestamp']: bar[field] for bar in symbol_bars} for symbol, symbol_bars in bars.items()}
+    timestamps = sorted(set().union(*series.values())) if series else []
+    last = {symbol: None for symbol in series}
+    filled = {symbol: 0 for symbol in series}
+    kept = []
+    values = {symbol: [] for symbol in series}
+    for timestamp in timestamps:
+        complete = True
+        for symbol, points in series.items():
+            if timestamp in points:
+                last[symbol], filled[symbol] = points[timestamp], 0
+            else:
+                filled[symbol] += 1
+                if not forward_fill or (max_fill is not None and filled[symbol] > max_fill):
+                    complete = False
+            if last[symbol] is None:
+                complete = False
+        if not complete:
+            continue
+        kept.append(timestamp)
+        for symbol in series:
+            values[symbol].append(last[symbol])
+    return kept, values
+
+
+def aligned_returns(
+    bars: dict[str, list[dict]],
+    kind: str = 'log',
+    forward_fill: bool = True,
+    max_fill: Optional[int] = None
+) -> dict[str, list[float]]:
+    """
+    Align several bar series and convert their closes to returns, the input
+    the correlation and PCA helpers in statistics expect.
+
+    Returns:
+        dict[str, list[float]]: { symbol: returns } of equal length.
+    """
+    _, closes = align_bars(bars, forward_fill, max_fill)
+    return {symbol: to_returns(values, kind) for symbol, values in closes.items()}
@@ -0,0 +1,319 @@
+import json
+import time
+from datetime import datetime, timedelta, timezone
+from dataclasses import dataclass, field, fields
+from threading import Lock
+from helpers import cloud, fx, logger, metrics, tenant, wash
+from typing import Callable, Optional
+
+# Initialize logger
+logger = logger.Logger('risk.py')
+
+
+class RiskRejection(Exception):
+    """Raised when an order fails a pre-trade risk check.
+
+    Attributes:
+        symbol: Symbol of the rejected order
+        reason: Which limit the order breached
+    """
+
+    def __init__(self, symbol: str, reason: str):
+        super().__init__(f'Order for {symbol} rejected: {reason}')
+        self.symbol = symbol
+        self.reason = reason
+
+
+@dataclass
+class RiskLimits:
+    """Pre-trade limits applied to every order, None disables a limit.
+    Notional limits are in the base currency (see fx.get_base_currency()).
+
+    Attributes:
+        max_order_notional: Maximum dollar value of a single order
+        max_position_notional: Maximum absolute dollar value of a position in one symbol
+        max_gross_exposure: Maximum sum of absolute position values across symbols
+        restricted_symbols: Symbols that may only be traded to reduce a position
+        max_drawdown_pct: Percent the account equity may fall from its high-water mark
+                          before orders that add risk are rejected
+    """
+    max_order_notional: Optional[float] = None
+    max_position_notional: Optional[float] = None
+    max_gross_exposure: Optional[float] = None
+    restricted_symbols: set = field(default_factory=set)
+    max_drawdown_pct: Optional[float] = None
+
+    def enabled(self) -> bool:
+        """Checks if any limit is configured."""
+        return any(
+            value is not None for value in
+            (self.max_order_notional, self.max_position_notional, self.max_gross_exposure, self.max_drawdown_pct)
+        ) or bool(self.restricted_symbols)
+
+
+def load_limits() -> RiskLimits:
+    """
+    Load risk limits from the optional RISK_CONFIG_FILE, then environment
+    variables, which take precedence. Each is read for the current tenant.
+
+    Environment Variables:
+        RISK_CONFIG_FILE (str): Path to a JSON file with any of the RiskLimits fields.
+        RISK_MAX_ORDER_NOTIONAL (float): Maximum dollar value of a single order.
+        RISK_MAX_POSITION_NOTIONAL (float): Maximum dollar value of a position.
+        RISK_MAX_GROSS_EXPOSURE (float): Maximum gross dollar exposure.
+        RISK_RESTRICTED_SYMBOLS (str): Comma-separated restricted symbols.
+        RISK_MAX_DRAWDOWN_PCT (float): Account drawdown percent that stops new risk.
+        RISK_DRAWDOWN_STATE_FILE (str): Optional JSON file the account's high-water mark and halt are persisted to.
+
+    Returns:
+        RiskLimits: The configured limits.
+    """
+    config = {}
+    path = tenant.getenv('RISK_CONFIG_FILE')
+    if path:
+        try:
+            with open(path) as file:
+                config = json.load(file)
+        except Exception as e:
+            raise Exception(f"Failed to load risk config {path}: {e}") from e
+    for limit in fields(RiskLimits):
+        value = tenant.getenv(f'RISK_{limit.name.upper()}')
+        if value:
+            config[limit.name] = value
+    restricted = config.get('restricted_symbols', [])
+    if isinstance(restricted, str):
+        restricted = restricted.split(',')
+    return RiskLimits(
+        max_order_notional=_optional_float(config.get('max_order_notional')),
+        max_position_notional=_optional_float(config.get('max_position_notional')),
+        max_gross_exposure=_optional_float(config.get('max_gross_exposure')),
+        restricted_symbols={symbol.strip().upper() for symbol in restricted if symbol.strip()},
+        max_drawdown_pct=_optional_float(config.get('max_drawdown_pct'))
+    )
+
+
+def _optional_float(value) -> Optional[float]:
+    return float(value) if value is not None else None
+
+
+# Initialize a placeholder for the loaded limits
+limits = None
+
+
+def get_limits() -> RiskLimits:
+    """
+    Returns the risk limits.
+    Loads them if they haven't been loaded yet.
+    """
+    global limits
+    if limits is None:
+        limits = load_limits()
+    return limits
+
+
+# Seconds an account equity reading is reused by the drawdown limit, so orders do not each cost a REST call
+EQUITY_TTL_SECONDS = 30.0
+
+# Guards the account against max_drawdown_pct, created from the first equity reading, see account_drawdown()
+account_guard = None
+
+# time.monotonic() of the last equity reading, and the lock around taking one
+_equity_read_at = 0.0
+_equity_lock = Lock()
+
+
+def account_drawdown(limits: RiskLimits, get_equity: Callable[[], float]) -> float:
+    """
+    Mark the account guard with equity read at most every EQUITY_TTL_SECONDS.
+    The guard is a drawdown.DrawdownGuard on account equity that halts at
+    max_drawdown_pct, its high-water mark and halt persisted to
+    RISK_DRAWDOWN_STATE_FILE. A halt holds until an operator resumes the
+    account through the admin API's /drawdown/resume.
+
+    Args:
+        limits (RiskLimits): The limits, max_drawdown_pct set.
+        get_equity (Callable[[], float]): Reads the current account equity.
+
+    Returns:
+        float: The account's drawdown as a fraction, at least max_drawdown_pct while halted.
+    """
+    from helpers import drawdown  # Deferred so importing risk does not register the /drawdown routes
+
+    global account_guard, _equity_read_at
+    with _equity_lock:
+        now = time.monotonic()
+        if account_guard is None or now - _equity_read_at >= EQUITY_TTL_SECONDS:
+            equity = get_equity()
+            _equity_read_at = now
+            if account_guard is None:
+                account_guard = drawdown.register_guard(drawdown.DrawdownGuard(
+                    strategy=tenant.resource_name('account'),
+                    capital=equity,
+                    reduce_at_pct=limits.max_drawdown_pct / 2,
+                    halt_at_pct=limits.max_drawdown_pct,
+                    state_file=tenant.scoped_path(tenant.getenv('RISK_DRAWDOWN_STATE_FILE')),
+                    persist_high_water_mark=True
+                ))
+            account_guard.update(equity - account_guard.capital)
+        if account_guard.state == drawdown.DrawdownState.HALTED:
+            return max(account_guard.drawdown_pct, account_guard.halt_at_pct) / 100
+        return account_guard.drawdown_pct / 100
+
+
+def evaluate(
+    limits: RiskLimits,
+    symbol: str,
+    qty: float,
+    price: float,
+    positions: dict,
+    drawdown: float = 0.0
+) -> Optional[str]:
+    """
+    Check an order against risk limits. Orders that only reduce a position
+    are never blocked by position, exposure, restricted-symbol, or drawdown limits.
+    Prices and market values are in each instrument's quote currency and are
+    converted to the base currency before comparing with the limits.
+
+    Args:
+        limits (RiskLimits): The limits to apply.
+        symbol (str): The symbol of the order.
+        qty (float): Signed order quantity, negative for sells.
+        price (float): The price the order is expected to fill at.
+        positions (dict): Current positions as returned by get_positions().
+        drawdown (float, optional): Account equity below its high-water mark, as a fraction. Defaults to 0.
+
+    Returns:
+        Optional[str]: The reason the order is rejected, None if it passes.
+
+    Raises:
+        ValueError: If an instrument's quote currency has no FX rate.
+    """
+    order_notional = fx.to_base(abs(qty) * price, symbol)
+    if limits.max_order_notional is not None and order_notional > limits.max_order_notional:
+        return f'order notional ${order_notional:,.2f} exceeds ${limits.max_order_notional:,.2f}'
+
+    current_qty = positions.get(symbol, {}).get('qty', 0.0)
+    new_qty = current_qty + qty
+    if abs(new_qty) <= abs(current_qty) and current_qty * new_qty >= 0:
+        return None
+
+    if symbol.upper() in limits.restricted_symbols:
+        return 'symbol is restricted'
+
+    if limits.max_drawdown_pct is not None and drawdown * 100 >= limits.max_drawdown_pct:
+        return f'account drawdown {drawdown:.2%} breaches the {limits.max_drawdown_pct}% circuit breaker'
+
+    position_notional = fx.to_base(abs(new_qty) * price, symbol)
+    if limits.max_position_notional is not None and position_notional > limits.max_position_notional:
+        return f'position notional ${position_notional:,.2f} exceeds ${limits.max_position_notional:,.2f}'
+
+    if limits.max_gross_exposure is not None:
+        others = sum(
+            fx.to_base(abs(position['market_value']), other) for other, position in positions.items() if other != symbol
+        )
+        gross = others + position_notional
+        if gross > limits.max_gross_exposure:
+            return f'gross exposure ${gross:,.2f} exceeds ${limits.max_gross_exposure:,.2f}'
+    return None
+
+
+def check_order(
+    symbol: str,
+    qty: float,
+    price: float,
+    positions: dict,
+    get_equity: Optional[Callable[[], float]] = None
+) -> None:
+    """
+    Run the pre-trade checks every order helper calls before submission.
+    Rejections are logged, counted, and published to the alert topic.
+
+    Args:
+        symbol (str): The symbol of the order.
+        qty (float): Signed order quantity, negative for sells.
+        price (float): The price the order is expected to fill at.
+        positions (dict): Current positions as returned by get_positions().
+        get_equity (Optional[Callable[[], float]], optional): Reads the account equity for the drawdown
+                                                             limit, see account_drawdown().
+
+    Raises:
+        RiskRejection: If the order breaches a limit, or cannot be valued in the base currency.
+    """
+    limits = get_limits()
+    try:
+        underwater = account_drawdown(limits, get_equity) if get_equity and limits.max_drawdown_pct is not None else 0.0
+        reason = evaluate(limits, symbol, qty, price, positions, underwater)
+    except ValueError as e:
+        reason = str(e)
+    if reason is None:
+        return
+    logger.warning(f'Pre-trade risk rejected {qty} {symbol} at ${price}: {reason}')
+    metrics.increment('risk_rejections', symbol=symbol)
+    try:
+        cloud.publish_alert('Pre-trade risk rejection', {
+            'symbol': symbol,
+            'qty': qty,
+            'price': price,
+            'reason': reason,
+        })
+    except Exception as e:
+        logger.error(f'Error publishing risk rejection alert: {e}')
+    raise RiskRejection(symbol, reason)
+
+
+def pre_trade_check(
+    symbol: str,
+    side: str,
+    qty: Optional[float] = None,
+    notional: Optional[float] = None,
+    price: Optional[float] = None,
+    *,
+    get_positions: Callable[[], dict],
+    get_orders: Callable[[str, Optional[datetime]], list],
+    get_latest_price: Callable[[str], Optional[float]],
+    get_equity: Callable[[], float]
+) -> None:
+    """
+    Run the pre-trade self-cross and risk checks for an order about to be
+    submitted, pricing it at the latest trade unless a price is given. Each is
+    skipped without any calls when it is not configured. Orders that only
+    reduce or close out a position skip the self-cross check. Every broker
+    runs its orders through this one check.
+
+    Args:
+        symbol (str): The symbol of the order.
+        side (str): 'buy' or 'sell'.
+        qty (Optional[float], optional): Unsigned order quantity, None for a notional order.
+        notional (Optional[float], optional): Dollar amount of a notional order.
+        price (Optional[float], optional): The price the order is expected to fill at, None for the latest trade.
+        get_positions (Callable[[], dict]): Reads positions as { symbol: { 'qty', ... } }.
+        get_orders (Callable[[str, Optional[datetime]], list]): Reads a symbol's open orders and those since a time.
+        get_latest_price (Callable[[str], Optional[float]]): Reads a symbol's latest trade price, None if unavailable.
+        get_equity (Callable[[], float]): Reads the account equity, see account_drawdown().
+
+    Raises:
+        wash.WashTradeRejection: If the order could cross an opposing order.
+        RiskRejection: If the order breaches a limit or cannot be priced.
+    """
+    wash_config = wash.get_config()
+    positions = None
+    if wash_config.enabled:
+        positions = get_positions()
+        order_qty = qty
+        if order_qty is None:
+            price = price if price is not None else get_latest_price(symbol)
+            # An order that cannot be sized is not known to reduce the position
+            order_qty = notional / price if price else None
+        if not wash.reduces_position(side, order_qty, positions.get(symbol, {}).get('qty', 0)):
+            since = datetime.now(timezone.utc) - timedelta(seconds=wash_config.window_seconds)
+            wash.check_order(symbol, side, get_orders(symbol, since if wash_config.window_seconds else None))
+    if not get_limits().enabled():
+        return
+    if price is None:
+        price = get_latest_price(symbol)
+    if not price:
+        raise RiskRejection(symbol, 'no price available for risk checks')
+    if qty is None:
+        qty = notional / price
+    positions = positions if positions is not None else get_positions()
+    check_order(symbol, qty if side == 'buy' else -qty, price, positions, get_equity)
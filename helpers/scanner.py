@@ -0,0 +1,228 @@
+import json
+import itertools
+from datetime import datetime, timedelta, timezone
+from typing import Optional
+from helpers import brokers, cloud, envelope, logger, outliers, spreads, statistics, subscription
+
+# Initialize logger
+logger = logger.Logger('scanner.py')
+
+# Fewest shared closes a pair or basket is tested on
+MIN_OBSERVATIONS = 100
+
+
+def align_closes(bars: dict[str, list[dict]]) -> dict[str, list[float]]:
+    """
+    Align the closes of a universe on the timestamps every symbol traded.
+
+    Args:
+        bars (dict[str, list[dict]]): { symbol: bars ordered oldest to newest }.
+
+    Returns:
+        dict[str, list[float]]: { symbol: closes } at the shared timestamps, symbols without bars dropped.
+    """
+    series = {symbol: {bar['timestamp']: bar['close'] for bar in symbol_bars} for symbol, symbol_bars in bars.items() if symbol_bars}
+    if not series:
+        return {}
+    shared = sorted(set.intersection(*(set(closes) for closes in series.values())))
+    return {symbol: [closes[timestamp] for timestamp in shared] for symbol, closes in series.items()}
+
+
+def _screen(values: list[float], significance: float, max_half_life: Optional[float], max_hurst: Optional[float]) -> Optional[dict]:
+    """
+    Half-life, Hurst exponent, and variance ratio of a spread, None when statistics.screen_series() rejects it.
+    """
+    screen = statistics.screen_series(values, significance, max_half_life, max_hurst)
+    if not screen['is_mean_reverting']:
+        return None
+    return {key: screen[key] for key in ('half_life', 'hurst', 'variance_ratio')}
+
+
+def scan_pairs(
+    closes: dict[str, list[float]],
+    max_p_value: float = 0.05,
+    max_half_life: Optional[float] = None,
+    max_hurst: Optional[float] = 0.5,
+    min_correlation: Optional[float] = None,
+    regression: str = 'ols',
+    correction: Optional[str] = 'fdr_bh'
+) -> list[dict]:
+    """
+    Engle-Granger test every pair in a universe and keep those whose spread
+    passes statistics.screen_series(). Each pair is tested both ways round, the direction with the lower p-value is kept.
+    P-values are adjusted for the number of pairs tested before max_p_value is applied, with hundreds of
+    pairs some would pass at 5% by chance alone.
+
+    Args:
+        closes (dict[str, list[float]]): { symbol: closes } aligned, e.g. from align_closes().
+        max_p_value (float, optional): Highest adjusted Engle-Granger p-value of the pair. Defaults to 0.05.
+        max_half_life (Optional[float], optional): Longest spread half-life in bars, None for no limit.
+        max_hurst (Optional[float], optional): Highest Hurst exponent of the spread, None for no limit.
+            Defaults to 0.5, spreads that are not mean reverting.
+        min_correlation (Optional[float], optional): Only test pairs whose log returns are at least
+            this correlated, None to test every pair.
+        regression (str, optional): How the hedge ratio is fit, one of statistics.REGRESSION_METHODS.
+            Defaults to 'ols'.
+        correction (Optional[str], optional): Multiple testing correction, one of statistics.P_VALUE_CORRECTIONS,
+            None to compare raw p-values. Defaults to 'fdr_bh', Benjamini-Hochberg.
+
+    Returns:
+        list[dict]: { 'symbols', 'hedge_ratio', 'p_value', 'raw_p_value', 'half_life', 'hurst', 'variance_ratio' }
+        per pair, ranked by p-value then half-life. p_value is the adjusted one. The spread is
+        symbols[0] - hedge_ratio * symbols[1].
+    """
+    if len(closes) < 2 or len(next(iter(closes.values()))) < MIN_OBSERVATIONS:
+        return []
+    if min_correlation is not None:
+        returns = {symbol: spreads.log_returns(values) for symbol, values in closes.items()}
+        candidates = [(a, b) for a, b, _ in statistics.correlated_pairs(returns, min_correlation)]
+    else:
+        candidates = list(itertools.combinations(closes, 2))
+
+    tests = []
+    for a, b in candidates:
+        best = None
+        for y, x in ((a, b), (b, a)):
+            test = statistics.engle_granger_test(closes[x], closes[y])
+            if best is None or test['p_value'] < best[2]['p_value']:
+                best = (y, x, test)
+        tests.append(best)
+    raw_p_values = [test['p_value'] for _, _, test in tests]
+    p_values = statistics.adjust_p_values(raw_p_values, correction) if correction else raw_p_values
+
+    results = []
+    for (y, x, test), p_value in zip(tests, p_values):
+        if p_value > max_p_value:
+            continue
+        hedge_ratio, _ = statistics.linear_regression(closes[x], closes[y], method=regression)
+        spread = spreads.spread(closes[y], closes[x], hedge_ratio, log_prices=False)
+        filters = _screen(spread, max_p_value, max_half_life, max_hurst)
+        if filters is None:
+            continue
+        results.append({
+            'symbols': [y, x],
+            'hedge_ratio': float(hedge_ratio),
+            'p_value': float(p_value),
+            'raw_p_value': float(test['p_value']),
+            **filters,
+        })
+    return sorted(results, key=lambda result: (result['p_value'], result['half_life']))
+
+
+def scan_triples(
+    closes: dict[str, list[float]],
+    significance: str = '95%',
+    max_half_life: Optional[float] = None,
+    max_hurst: Optional[float] = 0.5
+) -> list[dict]:
+    """
+    Johansen test every triple in a universe and keep the baskets that pass statistics.screen_series().
+
+    Args:
+        closes (dict[str, list[float]]): { symbol: closes } aligned, e.g. from align_closes().
+        significance (str, optional): Critical value column of the Johansen test. Defaults to '95%'.
+        max_half_life (Optional[float], optional): Longest basket half-life in bars, None for no limit.
+        max_hurst (Optional[float], optional): Highest Hurst exponent of the basket, None for no limit.
+
+    Returns:
+        list[dict]: { 'symbols', 'weights', 'rank', 'trace_statistic', 'half_life', 'hurst', 'variance_ratio' } per
+        basket, ranked by half-life. Weights are the leading cointegrating vector, see spreads.basket_spread.
+    """
+    if len(closes) < 3 or len(next(iter(closes.values()))) < MIN_OBSERVATIONS:
+        return []
+    results = []
+    for symbols in itertools.combinations(closes, 3):
+        series = [closes[symbol] for symbol in symbols]
+        try:
+            test = statistics.johansen_test(series, det_order=0, significance=significance)
+        except Exception as e:
+            logger.warning(f'Johansen test failed for {symbols}: {e}')
+            continue
+        if not test['cointegration_rank']:
+            continue
+        weights = [float(weight) for weight in test['cointegrating_vectors'][0]]
+        filters = _screen(spreads.basket_spread(series, weights), 0.05, max_half_life, max_hurst)
+        if filters is None:
+            continue
+        results.append({
+            'symbols': list(symbols),
+            'weights': weights,
+            'rank': test['cointegration_rank'],
+            'trace_statistic': float(test['trace_statistics'][0]),
+            **filters,
+        })
+    return sorted(results, key=lambda result: result['half_life'])
+
+
+def scan(
+    universe: list[str],
+    lookback_days: int = 30,
+    timeframe: str = '1Hour',
+    triples: bool = False,
+    bad_tick_sigma: Optional[float] = None,
+    **filters
+) -> dict:
+    """
+    Pull aligned history for a universe from the active broker and scan it for pairs and, optionally, triples.
+
+    Args:
+        universe (list[str]): The symbols to scan.
+        lookback_days (int, optional): Days of history. Defaults to 30.
+        timeframe (str, optional): Bar timeframe. Defaults to '1Hour'.
+        triples (bool, optional): Also scan triples with the Johansen test. Defaults to False.
+        bad_tick_sigma (Optional[float], optional): Replace prints that jump this many robust
+            standard deviations and revert before testing, None to test the raw closes.
+        **filters: max_p_value, max_half_life, max_hurst, min_correlation, regression, and correction for scan_pairs(),
+            the half-life and Hurst limits also apply to triples.
+
+    Returns:
+        dict: { 'scanned_at', 'universe', 'observations', 'pairs', 'triples' }.
+    """
+    end = datetime.now(timezone.utc)
+    closes = align_closes(brokers.get_broker().get_bars(universe, end - timedelta(days=lookback_days), end, timeframe))
+    observations = len(next(iter(closes.values()))) if closes else 0
+    if bad_tick_sigma is not None:
+        closes = {symbol: outliers.remove_bad_ticks(values, bad_tick_sigma) for symbol, values in closes.items()}
+    logger.info(f'Scanning {len(closes)} symbols over {observations} shared {timeframe} bars')
+    if observations < MIN_OBSERVATIONS:
+        logger.warning(f'Only {observations} shared bars, at least {MIN_OBSERVATIONS} are needed to scan')
+    basket_filters = {key: value for key, value in filters.items() if key in ('max_half_life', 'max_hurst')}
+    return {
+        'scanned_at': end.isoformat(),
+        'universe': universe,
+        'observations': observations,
+        'pairs': scan_pairs(closes, **filters),
+        'triples': scan_triples(closes, **basket_filters) if triples else [],
+    }
+
+
+def publish(
+    results: dict,
+    path: Optional[str] = None,
+    topic: Optional[str] = None,
+    control_queue: Optional[str] = None,
+    compression: Optional[str] = None
+) -> None:
+    """
+    Write scan results to a JSON file and/or publish them to an SNS topic in a scan envelope,
+    and optionally subscribe a data service to every symbol found through
+    its control queue. Large results can be compressed on the topic, see
+    envelope.encode.
+
+    Raises:
+        Exception: If the file cannot be written or a message cannot be sent.
+    """
+    body = json.dumps(results, default=str)
+    if path:
+        try:
+            with open(path, 'w') as file:
+                file.write(body)
+        except Exception as e:
+            raise Exception(f"Failed to write scan results to {path}: {e}") from e
+    if topic:
+        cloud.publish_sns_message(envelope.encode('scan', results, source='scanner', compression=compression), topic)
+    symbols = list(dict.fromkeys(
+        symbol for group in results.get('pairs', []) + results.get('triples', []) for symbol in group['symbols']
+    ))
+    if control_queue and symbols:
+        cloud.send_sqs_message(subscription.control_command('subscribe', symbols), control_queue)
@@ -0,0 +1,78 @@
+from datetime import datetime, time, timedelta
+from enum import Enum
+from zoneinfo import ZoneInfo
+from typing import Optional
+
+# US equity sessions, in exchange time
+EASTERN = ZoneInfo('America/New_York')
+PRE_MARKET_OPEN = time(4, 0)
+REGULAR_OPEN = time(9, 30)
+REGULAR_CLOSE = time(16, 0)
+POST_MARKET_CLOSE = time(20, 0)
+# The post-market session lasts this long after an early close
+POST_MARKET_HOURS = 4
+
+
+class MarketSession(str, Enum):
+    """Trading session of the US equity market."""
+    PRE_MARKET = 'pre_market'
+    REGULAR = 'regular'
+    POST_MARKET = 'post_market'
+    CLOSED = 'closed'
+
+
+def get_session(
+    now: Optional[datetime] = None,
+    clock: Optional[dict] = None,
+    session_times: Optional[tuple[datetime, datetime]] = None,
+    trading_day: bool = True
+) -> MarketSession:
+    """
+    Detect the current market session.
+    Sessions are derived from the time of day in New York. When the broker's
+    market clock is given it decides whether the regular session is open, so
+    holidays and early closes are respected.
+
+    Args:
+        now (Optional[datetime], optional): The time to classify, timezone aware.
+                                            Defaults to the current time.
+        clock (Optional[dict], optional): A market clock from Broker.get_clock().
+                                          Defaults to None.
+        session_times (Optional[tuple[datetime, datetime]], optional): The day's regular
+            session from trading_calendar, so early closes move the post-market session.
+            Defaults to None.
+        trading_day (bool, optional): False on a weekday holiday from trading_calendar, which
+            has no pre-market or post-market session either. Defaults to True.
+
+    Returns:
+        MarketSession: The session in progress.
+    """
+    if clock is not None and clock['is_open']:
+        return MarketSession.REGULAR
+    now = (now or datetime.now(EASTERN)).astimezone(EASTERN)
+    if now.weekday() >= 5 or not trading_day:
+        return MarketSession.CLOSED
+    if session_times is not None:
+        open_at, close_at = session_times
+        if open_at <= now < close_at:
+            return MarketSession.CLOSED if clock is not None else MarketSession.REGULAR
+        if close_at <= now < close_at + timedelta(hours=POST_MARKET_HOURS):
+            return MarketSession.POST_MARKET
+        if now >= close_at:
+            return MarketSession.CLOSED
+    current = now.time()
+    if PRE_MARKET_OPEN <= current < REGULAR_OPEN:
+        return MarketSession.PRE_MARKET
+    if REGULAR_OPEN <= current < REGULAR_CLOSE:
+        # The clock says closed during regular hours on holidays
+        return MarketSession.CLOSED if clock is not None else MarketSession.REGULAR
+    if REGULAR_CLOSE <= current < POST_MARKET_CLOSE:
+        return MarketSession.POST_MARKET
+    return MarketSession.CLOSED
+
+
+def is_extended_hours(session: MarketSession) -> bool:
+    """
+    Checks if a session is the pre-market or post-market session.
+    """
+    return session in (MarketSession.PRE_MARKET, MarketSession.POST_MARKET)
@@ -0,0 +1,109 @@
+import queue
+import threading
+from abc import ABC, abstractmethod
+from helpers import logger, metrics
+from typing import Optional
+
+# Initialize logger
+logger = logger.Logger('sinks.py')
+
+# Record types streamed to sinks
+RECORD_TYPES = ('bars', 'trades', 'quotes')
+
+
+class MarketDataSink(ABC):
+    """Somewhere the data service stores every streamed record besides SNS.
+
+    Sinks buffer records and write them in batches, record() may write the
+    batches that are due and flush() writes the rest on shutdown.
+    """
+
+    @abstractmethod
+    def record(self, record_type: str, message: dict) -> None:
+        """Buffers a streamed bar, trade, or quote, record_type one of RECORD_TYPES."""
+
+    @abstractmethod
+    def flush(self) -> None:
+        """Writes every buffered record."""
+
+
+def check_record_type(record_type: str) -> None:
+    """Raises ValueError if record_type is not one of RECORD_TYPES."""
+    if record_type not in RECORD_TYPES:
+        raise ValueError(f'Unknown record type {record_type}, expected one of {", ".join(RECORD_TYPES)}.')
+
+
+class SinkQueue:
+    """Feeds streamed records to the sinks from a worker thread.
+
+    Stream handlers hand records to offer() and return immediately, so a
+    sink writing a due batch to S3 or a database never delays publishing.
+    The queue is bounded so a stalled sink cannot exhaust memory, records
+    offered while it is full are dropped and counted.
+
+    Attributes:
+        sinks: The sinks every record is handed to, in order
+        queue: Bounded queue of (record_type, message) waiting for the sinks
+    """
+
+    def __init__(self, sinks: list[MarketDataSink], max_queue: int = 10_000):
+        """Initializes the queue, call start() to run the worker.
+
+        Args:
+            sinks: The sinks every record is handed to
+            max_queue: Records held before new ones are dropped
+        """
+        self.sinks = sinks
+        self.queue = queue.Queue(maxsize=max_queue)
+        self._stopped = threading.Event()
+        self._thread = None
+
+    def start(self) -> 'SinkQueue':
+        """Starts the worker thread, returning the queue."""
+        self._thread = threading.Thread(target=self._work, daemon=True)
+        self._thread.start()
+        return self
+
+    def offer(self, record_type: str, message: dict) -> bool:
+        """Queues a record, returning False when the queue is full and it was dropped."""
+        try:
+            self.queue.put_nowait((record_type, message))
+            return True
+        except queue.Full:
+            metrics.increment('sink_dropped', type=record_type)
+            logger.error(f'Sink queue full, dropped {record_type} for {message.get("symbol")}')
+            return False
+
+    def stop(self, timeout: Optional[float] = None) -> int:
+        """Hands what is queued to the sinks within the timeout, stops the worker, and flushes every sink.
+
+        Returns:
+            int: The number of records still queued when the timeout passed, which no sink received
+        """
+        with self.queue.all_tasks_done:
+            self.queue.all_tasks_done.wait_for(lambda: not self.queue.unfinished_tasks, timeout)
+        self._stopped.set()
+        if self._thread is not None:
+            self._thread.join(1)
+            self._thread = None
+        remaining = self.queue.qsize()
+        for sink in self.sinks:
+            try:
+                sink.flush()
+            except Exception as e:
+                logger.error(f'Error flushing {type(sink).__name__}: {e}')
+        return remaining
+
+    def _work(self) -> None:
+        while not self._stopped.is_set():
+            try:
+                record_type, message = self.queue.get(timeout=0.5)
+            except queue.Empty:
+                continue
+            for sink in self.sinks:
+                try:
+                    sink.record(record_type, message)
+                except Exception as e:
+                    metrics.increment('sink_failures', type=record_type, sink=type(sink).__name__)
+                    logger.error(f'Error storing {record_type} for {message.get("symbol")} in {type(sink).__name__}: {e}')
+            self.queue.task_done()
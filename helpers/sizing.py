@@ -0,0 +1,198 @@
+import math
+import time
+from collections import deque
+from threading import Lock
+from typing import Callable, Optional
+from helpers import logger, metrics
+
+# Initialize logger
+logger = logger.Logger('sizing.py')
+
+# Sizing methods a PositionSizer supports
+SIZING_METHODS = ('fixed', 'kelly', 'half_kelly')
+
+# How the Kelly fraction is estimated from closed trades
+KELLY_ESTIMATES = ('payoff', 'moments')
+
+# Seconds an account equity reading sizes entries before it is read again
+EQUITY_TTL_SECONDS = 30.0
+
+
+def kelly_fraction(win_rate: float, average_win: float, average_loss: float = 1.0) -> float:
+    """
+    Kelly fraction of equity to commit per trade from win rate and payoff.
+    Maximizes W * log(1 + f * average_win) + (1 - W) * log(1 - f * average_loss),
+    with average_loss 1 (the whole stake is lost) it is the classic W - (1 - W) / R.
+
+    Args:
+        win_rate (float): Fraction of trades that win, 0 to 1.
+        average_win (float): Average winning return per unit committed, e.g. 0.02 for 2%.
+        average_loss (float, optional): Average losing return per unit committed, positive. Defaults to 1.0.
+
+    Returns:
+        float: The fraction of equity, negative when the trades have no edge.
+
+    Raises:
+        ValueError: If the win rate is outside 0 to 1 or a payoff is not positive.
+    """
+    if not 0 <= win_rate <= 1:
+        raise ValueError('win_rate must be between 0 and 1.')
+    if average_win <= 0 or average_loss <= 0:
+        raise ValueError('average_win and average_loss must be positive.')
+    return win_rate / average_loss - (1 - win_rate) / average_win
+
+
+def continuous_kelly_fraction(mean_return: float, variance: float) -> float:
+    """
+    Kelly fraction of equity for continuously distributed returns, mean over variance.
+
+    Args:
+        mean_return (float): Mean return per trade or period.
+        variance (float): Variance of the returns.
+
+    Returns:
+        float: The fraction of equity, negative when the mean return is.
+
+    Raises:
+        ValueError: If the variance is not positive.
+    """
+    if variance <= 0:
+        raise ValueError('variance must be positive.')
+    return mean_return / variance
+
+
+def trade_statistics(returns: list[float]) -> dict:
+    """
+    Summarize closed trade returns for the Kelly estimates.
+
+    Args:
+        returns (list[float]): Return of each closed trade on its entry notional.
+
+    Returns:
+        dict: { 'trades', 'win_rate', 'average_win', 'average_loss', 'mean', 'variance' },
+        average_loss positive, payoffs 0 when there are no wins or losses.
+    """
+    wins = [r for r in returns if r > 0]
+    losses = [-r for r in returns if r < 0]
+    mean = sum(returns) / len(returns) if returns else 0.0
+    variance = sum((r - mean) ** 2 for r in returns) / (len(returns) - 1) if len(returns) > 1 else 0.0
+    return {
+        'trades': len(returns),
+        'win_rate': len(wins) / len(returns) if returns else 0.0,
+        'average_win': sum(wins) / len(wins) if wins else 0.0,
+        'average_loss': sum(losses) / len(losses) if losses else 0.0,
+        'mean': mean,
+        'variance': variance,
+    }
+
+
+class PositionSizer:
+    """Converts signals to share quantities sized by Kelly or a fixed fraction.
+
+    Closed trade returns are recorded as round trips complete. Until
+    min_trades are recorded, or when a Kelly estimate cannot be made (no
+    losses yet), the fixed fraction is used. A Kelly fraction with no edge
+    sizes entries at 0. Account equity is reused for equity_ttl_seconds, so
+    a burst of entries does not each cost a REST call.
+
+    Attributes:
+        method: One of SIZING_METHODS
+        estimate: One of KELLY_ESTIMATES
+        fixed_fraction: Fraction of equity per entry for 'fixed' and the fallback
+        max_fraction: Cap on the fraction of equity per entry
+        min_trades: Closed trades required before Kelly is used
+        equity_ttl_seconds: Seconds an account equity reading is reused
+        monotonic: Source of elapsed time, replaceable in tests
+        returns: The most recent closed trade returns
+        lock: Thread lock around the returns and the equity reading
+    """
+
+    def __init__(
+        self,
+        method: str = 'half_kelly',
+        estimate: str = 'payoff',
+        fixed_fraction: float = 0.01,
+        max_fraction: float = 0.2,
+        min_trades: int = 30,
+        window: int = 200,
+        equity_ttl_seconds: float = EQUITY_TTL_SECONDS,
+        monotonic: Callable[[], float] = time.monotonic
+    ):
+        """Initializes the sizer with no trades recorded.
+
+        Args:
+            method: One of SIZING_METHODS
+            estimate: 'payoff' for win rate and payoff, 'moments' for mean over variance
+            fixed_fraction: Fraction of equity per entry for 'fixed' and the fallback
+            max_fraction: Cap on the fraction of equity per entry
+            min_trades: Closed trades required before Kelly is used
+            window: Closed trades the estimate is made over
+            equity_ttl_seconds: Seconds an account equity reading is reused
+            monotonic: Source of elapsed time, replaceable in tests
+
+        Raises:
+            ValueError: If the method or estimate is unknown.
+        """
+        if method not in SIZING_METHODS:
+            raise ValueError(f'Unknown sizing method {method}, expected one of {", ".join(SIZING_METHODS)}.')
+        if estimate not in KELLY_ESTIMATES:
+            raise ValueError(f'Unknown Kelly estimate {estimate}, expected one of {", ".join(KELLY_ESTIMATES)}.')
+        self.method = method
+        self.estimate = estimate
+        self.fixed_fraction = fixed_fraction
+        self.max_fraction = max_fraction
+        self.min_trades = min_trades
+        self.equity_ttl_seconds = equity_ttl_seconds
+        self.monotonic = monotonic
+        self.returns = deque(maxlen=window)
+        self.lock = Lock()
+        self._equity = None
+
+    def record_trade(self, pnl: float, entry_notional: float) -> None:
+        """Records a closed trade's return on its entry notional."""
+        if not entry_notional:
+            return
+        with self.lock:
+            self.returns.append(pnl / abs(entry_notional))
+
+    def account_equity(self, get_equity: Callable[[], float]) -> float:
+        """Returns the account equity, read through get_equity once the last reading is older than equity_ttl_seconds."""
+        with self.lock:
+            if self._equity is not None and self.monotonic() - self._equity[0] < self.equity_ttl_seconds:
+                return self._equity[1]
+        equity = get_equity()
+        with self.lock:
+            self._equity = (self.monotonic(), equity)
+        return equity
+
+    def _kelly(self) -> Optional[float]:
+        """The full Kelly fraction of the recorded trades, None when it cannot be estimated."""
+        with self.lock:
+            stats = trade_statistics(list(self.returns))
+        if stats['trades'] < self.min_trades:
+            return None
+        if self.estimate == 'moments':
+            return continuous_kelly_fraction(stats['mean'], stats['variance']) if stats['variance'] > 0 else None
+        if not stats['average_win'] or not stats['average_loss']:
+            return None
+        return kelly_fraction(stats['win_rate'], stats['average_win'], stats['average_loss'])
+
+    def fraction(self) -> float:
+        """The fraction of equity to commit to the next entry."""
+        kelly = None if self.method == 'fixed' else self._kelly()
+        if kelly is None:
+            fraction = self.fixed_fraction
+        else:
+            fraction = max(kelly, 0.0) * (0.5 if self.method == 'half_kelly' else 1.0)
+        fraction = min(fraction, self.max_fraction)
+        metrics.set_gauge('sizing_fraction', fraction)
+        return fraction
+
+    def qty(self, equity: float, price: float) -> int:
+        """Whole shares of an entry at a price, 0 when the fraction buys less than one share."""
+        if price <= 0:
+            return 0
+        qty = math.floor(equity * self.fraction() / price)
+        if not qty:
+            logger.info(f'Sizing {self.method} buys less than one share at {price}, skipping entry')
+        return qty
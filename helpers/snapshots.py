@@ -0,0 +1,100 @@
+from datetime import datetime, timezone
+from threading import Lock
+from helpers import admin, sinks
+from typing import Optional
+
+# Record type to the snapshot field holding its latest record
+FIELDS = {
+    'bars': 'bar',
+    'trades': 'trade',
+    'quotes': 'quote',
+}
+
+# The data service's cache, served by the /snapshots route, None unless SNAPSHOTS is set
+cache = None
+
+
+class SnapshotCache(sinks.MarketDataSink):
+    """Keeps what the feed currently sees for each symbol in memory.
+
+    Each symbol's snapshot holds its last bar, last trade, and best quote,
+    plus the current minute's bar under construction from the trades seen
+    so far, replaced by a new one when a trade of a later minute arrives.
+    Late records never overwrite newer ones. Nothing is written anywhere,
+    the snapshots are read through query() and the /snapshots admin route.
+
+    Attributes:
+        snapshots: { symbol: { 'bar', 'trade', 'quote', 'minute_bar', 'updated_at' } }
+        lock: Thread lock around the snapshots
+    """
+
+    def __init__(self):
+        """Initializes the cache with no snapshots."""
+        self.snapshots = {}
+        self.lock = Lock()
+
+    def record(self, record_type: str, message: dict) -> None:
+        """Updates the symbol's snapshot with the message if it is the newest of its type."""
+        sinks.check_record_type(record_type)
+        field = FIELDS[record_type]
+        with self.lock:
+            snapshot = self.snapshots.setdefault(message['symbol'], {})
+            current = snapshot.get(field)
+            if current is None or current['timestamp'] <= message['timestamp']:
+                snapshot[field] = message
+            if record_type == 'trades':
+                _add_trade(snapshot, message)
+            snapshot['updated_at'] = datetime.now(timezone.utc).isoformat()
+
+    def flush(self) -> None:
+        """Nothing is buffered for writing, the snapshots stay until the service stops."""
+
+    def query(self, symbols: Optional[list[str]] = None) -> dict:
+        """Returns copies of the snapshots of the symbols, every symbol's if None, symbols never seen are missing."""
+        with self.lock:
+            symbols = list(self.snapshots) if symbols is None else symbols
+            return {symbol: dict(self.snapshots[symbol]) for symbol in symbols if symbol in self.snapshots}
+
+
+def _add_trade(snapshot: dict, trade: dict) -> None:
+    """Adds a trade to the snapshot's bar of the current minute, starting a new bar for a later minute."""
+    start = datetime.fromisoformat(trade['timestamp']).astimezone(timezone.utc).replace(second=0, microsecond=0).isoformat()
+    bar = snapshot.get('minute_bar')
+    if bar is not None and start < bar['timestamp']:
+        return  # Late trade of a minute already replaced
+    price, size = trade['price'], trade.get('size') or 0
+    if bar is None or start > bar['timestamp']:
+        snapshot['minute_bar'] = {
+            'symbol': trade['symbol'],
+            'timestamp': start,
+            'open': price,
+            'high': price,
+            'low': price,
+            'close': price,
+            'volume': size,
+            'trade_count': 1,
+            'vwap': price,
+        }
+        return
+    volume = bar['volume'] + size
+    # Replaced rather than updated, so snapshots already returned by query() do not change
+    snapshot['minute_bar'] = {
+        **bar,
+        'high': max(bar['high'], price),
+        'low': min(bar['low'], price),
+        'close': price,
+        'volume': volume,
+        'trade_count': bar['trade_count'] + 1,
+        'vwap': (bar['vwap'] * bar['volume'] + price * size) / volume if volume else price,
+    }
+
+
+def _query(query: dict) -> dict:
+    """Handles /snapshots, an optional 'symbol' query parameter takes comma-separated symbols."""
+    if cache is None:
+        raise ValueError('Snapshots are not kept by this service, set SNAPSHOTS=True on the data service.')
+    symbols = query.get('symbol')
+    return cache.query(symbols.split(',') if symbols else None)
+
+
+admin.register_route('/snapshots', lambda query, body: _query(query))
@@ -0,0 +1,214 @@
+import os
+import json
+import threading
+from threading import Lock
+from helpers import cloud, logger, metrics, publisher
+from typing import Callable
+
+# Initialize logger
+logger = logger.Logger('spool.py')
+
+
+class DiskSpool:
+    """Bounded on-disk buffer for messages SNS would not take, replayed once it does.
+
+    publish() and publish_batch() wrap the SNS calls. A message SNS rejects
+    is appended to the spool rather than dropped, and after max_failures
+    consecutive failures SNS is treated as down: every message goes
+    straight to the spool, rather than waiting on a failing call each,
+    until run() has replayed it all. Replayed messages arrive after those
+    published meanwhile, consumers order by timestamp.
+
+    The spool is JSON lines segment files in directory, oldest first, so it
+    also survives a restart when the directory is on a persistent volume.
+    Once it holds max_bytes the oldest segments are evicted, the latest
+    data being the most useful on recovery, and the evicted messages are
+    counted in 'spool_dropped'.
+
+    Attributes:
+        directory: Where segment files are written
+        max_bytes: Largest size of the spool on disk
+        segment_bytes: Size at which a new segment file is started
+        max_failures: Consecutive publish failures before SNS is treated as down
+        retry_seconds: Seconds between replay attempts
+        segments: Segment file paths, oldest first
+        size: Bytes spooled
+        failures: Consecutive publish failures
+        spooling: Whether messages go straight to the spool
+        lock: Thread lock around the segments and counters
+    """
+
+    def __init__(
+        self,
+        directory: str,
+        max_bytes: int = 256 * 1024 * 1024,
+        segment_bytes: int = 1024 * 1024,
+        max_failures: int = 3,
+        retry_seconds: float = 5,
+        publish: Callable[[str, str], dict] = cloud.publish_sns_message,
+        publish_batch: Callable[[list[str], str], dict] = cloud.publish_sns_batch
+    ):
+        """Initializes the spool, picking up segments left by a previous run.
+
+        Args:
+            directory: Where segment files are written, created if missing
+            max_bytes: Largest size of the spool on disk
+            segment_bytes: Size at which a new segment file is started
+            max_failures: Consecutive publish failures before SNS is treated as down
+            retry_seconds: Seconds between replay attempts
+            publish: Publish function with cloud.publish_sns_message's signature
+            publish_batch: Batch publish function with cloud.publish_sns_batch's signature
+        """
+        os.makedirs(directory, exist_ok=True)
+        self.directory = directory
+        self.max_bytes = max_bytes
+        self.segment_bytes = segment_bytes
+        self.max_failures = max_failures
+        self.retry_seconds = retry_seconds
+        self.segments = sorted(os.path.join(directory, name) for name in os.listdir(directory) if name.endswith('.jsonl'))
+        self.size = sum(os.path.getsize(path) for path in self.segments)
+        self.failures = 0
+        self.spooling = bool(self.segments)
+        self.lock = Lock()
+        self._publish = publish
+        self._publish_batch = publish_batch
+        self._next = int(os.path.basename(self.segments[-1]).split('.')[0]) + 1 if self.segments else 0
+        self._writing = None  # Segment new messages are appended to, None to start a new one
+        self._replaying = None  # Segment being replayed, never evicted
+        if self.segments:
+            logger.warning(f'Found {self.size} spooled bytes in {len(self.segments)} segments, replaying them first')
+
+    def publish(self, data: str, topic: str) -> bool:
+        """Publishes a message, spooling it if SNS is down or rejects it.
+
+        Returns:
+            bool: True if it was published, False if it was spooled
+        """
+        if not self.spooling:
+            try:
+                self._publish(data, topic)
+                self.failures = 0
+                return True
+            except Exception as e:
+                self._failed(e)
+        self.append([(data, topic)])
+        return False
+
+    def publish_batch(self, messages: list[str], topic: str) -> dict:
+        """Publishes a batch, spooling the messages SNS is down for or rejects.
+
+        Returns:
+            dict: The SNS response with 'Failed' emptied and the number spooled under 'Spooled'
+        """
+        response, failed = {}, messages
+        if not self.spooling:
+            try:
+                response = self._publish_batch(messages, topic)
+                failed = [messages[int(entry['Id'])] for entry in response.get('Failed', [])]
+                if failed:
+                    self._failed(f"{len(failed)} of {len(messages)} messages failed")
+                else:
+                    self.failures = 0
+            except Exception as e:
+                self._failed(e)
+        if failed:
+            self.append([(data, topic) for data in failed])
+        return {**response, 'Failed': [], 'Spooled': len(failed)}
+
+    def _failed(self, error) -> None:
+        """Counts a publish failure, spooling everything once they repeat."""
+        with self.lock:
+            self.failures += 1
+            if self.failures >= self.max_failures and not self.spooling:
+                self.spooling = True
+                logger.error(f'SNS unavailable after {self.failures} failures, spooling messages to {self.directory}: {error}')
+
+    def append(self, messages: list[tuple[str, str]]) -> None:
+        """Appends (message, topic) pairs to the newest segment, evicting the oldest segments when full."""
+        lines = ''.join(json.dumps({'topic': topic, 'message': data}) + '\n' for data, topic in messages)
+        length = len(lines.encode())
+        with self.lock:
+            while self.size + length > self.max_bytes and self._evict():
+                pass
+            if self.size + length > self.max_bytes:
+                metrics.increment('spool_dropped', len(messages))
+                logger.error(f'Spool full, dropped {len(messages)} messages')
+                return
+            if self._writing is None or os.path.getsize(self._writing) >= self.segment_bytes:
+                self._writing = os.path.join(self.directory, f'{self._next:012d}.jsonl')
+                self._next += 1
+                self.segments.append(self._writing)
+            with open(self._writing, 'a') as file:
+                file.write(lines)
+            self.size += length
+            metrics.increment('spooled_messages', len(messages))
+            metrics.set_gauge('spool_bytes', self.size)
+
+    def _evict(self) -> bool:
+        """Removes the oldest segment not being replayed, returning False when there is none. Called with the lock held."""
+        for path in self.segments:
+            if path not in (self._replaying, self._writing):
+                with open(path) as file:
+                    metrics.increment('spool_dropped', sum(1 for _ in file))
+                self.size -= os.path.getsize(path)
+                self.segments.remove(path)
+                os.remove(path)
+                logger.error(f'Spool full, evicted {path}')
+                return True
+        return False
+
+    def replay(self) -> int:
+        """Publishes the oldest segment, stopping at the first failure.
+
+        Returns:
+            int: The number of messages published
+        """
+        with self.lock:
+            if not self.segments:
+                self.spooling = False
+                return 0
+            path = self._replaying = self.segments[0]
+            if path == self._writing:
+                self._writing = None
+        with open(path) as file:
+            entries = [json.loads(line) for line in file if line.strip()]
+        done = set()
+        try:
+            by_topic = {}
+            for i, entry in enumerate(entries):
+                by_topic.setdefault(entry['topic'], []).append(i)
+            for topic, indices in by_topic.items():
+                for batch in publisher.batches([entries[i]['message'] for i in indices]):
+                    response = self._publish_batch(batch, topic)
+                    if response.get('Failed'):
+                        raise Exception(f"{len(response['Failed'])} of {len(batch)} messages failed")
+                    done.update(indices[:len(batch)])
+                    indices = indices[len(batch):]
+        except Exception as e:
+            logger.warning(f'Spool replay stopped after {len(done)} messages: {e}')
+        published = len(done)
+        remaining = [entry for i, entry in enumerate(entries) if i not in done]
+        with self.lock:
+            self._replaying = None
+            self.size -= os.path.getsize(path)
+            if remaining:
+                with open(path, 'w') as file:
+                    file.write(''.join(json.dumps(entry) + '\n' for entry in remaining))
+                self.size += os.path.getsize(path)
+            else:
+                self.segments.remove(path)
+                os.remove(path)
+            if not self.segments:
+                self.spooling = False
+                self.failures = 0
+                logger.info('Spool replayed, publishing to SNS directly again')
+            metrics.increment('spool_replayed', published)
+            metrics.set_gauge('spool_bytes', self.size)
+        return published
+
+    def run(self, shutdown: threading.Event) -> None:
+        """Replays the spool every retry_seconds until shutdown, what is left stays on disk for the next run."""
+        while not shutdown.wait(self.retry_seconds):
+            while self.segments and not shutdown.is_set():
+                if not self.replay():
+                    break
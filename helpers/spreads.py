@@ -0,0 +1,290 @@
+import math
+from typing import Optional
+
+
+def parse_pairs(value: Optional[str]) -> dict[str, str]:
+    """
+    Parse stock to sector ETF pairs from configuration.
+
+    Args:
+        value (Optional[str]): Comma-separated STOCK:ETF pairs, e.g. 'AAPL:XLK,JPM:XLF'.
+
+    Returns:
+        dict[str, str]: { stock: sector ETF }, empty when value is empty.
+
+    Raises:
+        ValueError: If a pair is malformed or pairs a symbol with itself.
+    """
+    pairs = {}
+    for pair in filter(None, (value or '').split(',')):
+        stock, _, etf = pair.strip().partition(':')
+        if not stock or not etf or stock == etf:
+            raise ValueError(f'Invalid spread pair {pair}, expected STOCK:ETF.')
+        pairs[stock] = etf
+    return pairs
+
+
+def align(stock_bars: list[dict], etf_bars: list[dict]) -> tuple[list[float], list[float]]:
+    """
+    Align the closes of a stock and its ETF on the timestamps both traded.
+
+    Args:
+        stock_bars (list[dict]): Bars of the stock ordered oldest to newest.
+        etf_bars (list[dict]): Bars of the ETF ordered oldest to newest.
+
+    Returns:
+        tuple[list[float], list[float]]: Stock and ETF closes at the shared timestamps.
+    """
+    etf_closes = {bar['timestamp']: bar['close'] for bar in etf_bars}
+    shared = [bar for bar in stock_bars if bar['timestamp'] in etf_closes]
+    return [bar['close'] for bar in shared], [etf_closes[bar['timestamp']] for bar in shared]
+
+
+def log_returns(prices: list[float]) -> list[float]:
+    """
+    Log returns between consecutive prices.
+    """
+    return [math.log(current / previous) for previous, current in zip(prices, prices[1:])]
+
+
+def beta(stock_closes: list[float], etf_closes: list[float]) -> float:
+    """
+    Beta of the stock to its ETF, the least squares slope of the stock's log
+    returns on the ETF's log returns.
+
+    Args:
+        stock_closes (list[float]): Stock closes aligned with etf_closes.
+        etf_closes (list[float]): ETF closes.
+
+    Returns:
+        float: The beta.
+
+    Raises:
+        ValueError: If the series differ in length, are too short, or the ETF never moved.
+    """
+    if len(stock_closes) != len(etf_closes):
+        raise ValueError('Stock and ETF closes must be the same length.')
+    stock_returns, etf_returns = log_returns(stock_closes), log_returns(etf_closes)
+    if len(etf_returns) < 2:
+        raise ValueError('At least three closes are required to estimate beta.')
+    stock_mean = sum(stock_returns) / len(stock_returns)
+    etf_mean = sum(etf_returns) / len(etf_returns)
+    covariance = sum((s - stock_mean) * (e - etf_mean) for s, e in zip(stock_returns, etf_returns))
+    variance = sum((e - etf_mean) ** 2 for e in etf_returns)
+    if variance == 0:
+        raise ValueError('ETF closes have no variance.')
+    return covariance / variance
+
+
+def rolling_beta(stock_closes: list[float], etf_closes: list[float], window: int) -> list[Optional[float]]:
+    """
+    Beta over the trailing window of closes at each point of the series.
+
+    Args:
+        stock_closes (list[float]): Stock closes aligned with etf_closes.
+        etf_closes (list[float]): ETF closes.
+        window (int): Closes per estimate.
+
+    Returns:
+        list[Optional[float]]: Beta aligned with the closes, None until the window
+        is full or where the ETF did not move.
+    """
+    betas = []
+    for end in range(1, len(stock_closes) + 1):
+        if end < window:
+            betas.append(None)
+            continue
+        try:
+            betas.append(beta(stock_closes[end - window:end], etf_closes[end - window:end]))
+        except ValueError:
+            betas.append(None)
+    return betas
+
+
+def hedge_ratio(stock_closes: list[float], etf_closes: list[float], log_prices: bool = True) -> float:
+    """
+    Hedge ratio of the stock to its ETF, the least squares slope of stock
+    prices on ETF prices, the ratio spread() expects. Unlike beta() it is fit
+    on levels, so it is the ratio the spread is stationary under.
+
+    Args:
+        stock_closes (list[float]): Stock closes aligned with etf_closes.
+        etf_closes (list[float]): ETF closes.
+        log_prices (bool, optional): Fit log prices rather than prices. Defaults to True.
+
+    Returns:
+        float: The hedge ratio.
+
+    Raises:
+        ValueError: If the series differ in length, are too short, or the ETF never moved.
+    """
+    if len(stock_closes) != len(etf_closes):
+        raise ValueError('Stock and ETF closes must be the same length.')
+    if len(etf_closes) < 2:
+        raise ValueError('At least two closes are required to estimate a hedge ratio.')
+    stock = [math.log(price) for price in stock_closes] if log_prices else stock_closes
+    etf = [math.log(price) for price in etf_closes] if log_prices else etf_closes
+    stock_mean = sum(stock) / len(stock)
+    etf_mean = sum(etf) / len(etf)
+    covariance = sum((s - stock_mean) * (e - etf_mean) for s, e in zip(stock, etf))
+    variance = sum((e - etf_mean) ** 2 for e in etf)
+    if variance == 0:
+        raise ValueError('ETF closes have no variance.')
+    return covariance / variance
+
+
+def rolling_hedge_ratio(
+    stock_closes: list[float],
+    etf_closes: list[float],
+    window: int,
+    log_prices: bool = True
+) -> list[Optional[float]]:
+    """
+    Hedge ratio over the trailing window of closes at each point of the series.
+
+    Args:
+        stock_closes (list[float]): Stock closes aligned with etf_closes.
+        etf_closes (list[float]): ETF closes.
+        window (int): Closes per estimate.
+        log_prices (bool, optional): Fit log prices rather than prices. Defaults to True.
+
+    Returns:
+        list[Optional[float]]: Hedge ratio aligned with the closes, None until the
+        window is full or where the ETF did not move.
+    """
+    ratios = []
+    for end in range(1, len(stock_closes) + 1):
+        if end < window:
+            ratios.append(None)
+            continue
+        try:
+            ratios.append(hedge_ratio(stock_closes[end - window:end], etf_closes[end - window:end], log_prices))
+        except ValueError:
+            ratios.append(None)
+    return ratios
+
+
+def hedge_ratio_drift(ratios: list[Optional[float]], reference: float, tolerance: float = 0.2) -> dict:
+    """
+    How far the latest rolling hedge ratio or beta has moved from the one a
+    pair is traded at, so a strategy can re-estimate or retire the pair.
+
+    Args:
+        ratios (list[Optional[float]]): Rolling estimates, e.g. from rolling_hedge_ratio().
+        reference (float): The hedge ratio the pair is traded at.
+        tolerance (float, optional): Relative drift at which the pair has drifted. Defaults to 0.2.
+
+    Returns:
+        dict: { 'current', 'drift', 'drifted' }, drift relative to the reference,
+        None and False without an estimate.
+    """
+    current = next((ratio for ratio in reversed(ratios) if ratio is not None), None)
+    if current is None or not reference:
+        return {'current': current, 'drift': None, 'drifted': False}
+    drift = (current - reference) / abs(reference)
+    return {'current': current, 'drift': drift, 'drifted': abs(drift) > tolerance}
+
+
+def spread(
+    stock_closes: list[float],
+    etf_closes: list[float],
+    hedge_ratio: float,
+    log_prices: bool = True
+) -> list[float]:
+    """
+    Spread of the stock over its beta-weighted ETF, log(stock) - hedge_ratio * log(ETF)
+    on log prices or stock - hedge_ratio * ETF on prices. Stationarity tests,
+    z-scores, and live signals all build the spread here so they agree.
+
+    Args:
+        stock_closes (list[float]): Stock closes aligned with etf_closes.
+        etf_closes (list[float]): ETF closes.
+        hedge_ratio (float): ETF units per stock unit.
+        log_prices (bool, optional): Spread log prices rather than prices. Defaults to True.
+
+    Returns:
+        list[float]: The spread at each close.
+    """
+    return basket_spread([stock_closes, etf_closes], [1.0, -hedge_ratio], log_prices)
+
+
+def basket_spread(series: list[list[float]], weights: list[float], log_prices: bool = False) -> list[float]:
+    """
+    Weighted sum of aligned price series, e.g. a Johansen cointegrating vector
+    applied to its legs.
+
+    Args:
+        series (list[list[float]]): One aligned close series per leg.
+        weights (list[float]): Weight of each leg, negative for short legs.
+        log_prices (bool, optional): Weight log prices rather than prices. Defaults to False.
+
+    Returns:
+        list[float]: The basket value at each close.
+
+    Raises:
+        ValueError: If the weights do not match the legs or the legs differ in length.
+    """
+    if len(series) != len(weights):
+        raise ValueError('One weight is required per series.')
+    if len({len(closes) for closes in series}) > 1:
+        raise ValueError('Series must have the same length.')
+    transform = math.log if log_prices else float
+    return [
+        sum(weight * transform(price) for weight, price in zip(weights, prices))
+        for prices in zip(*series)
+    ]
+
+
+def spread_signal(
+    stock_closes: list[float],
+    etf_closes: list[float],
+    beta_window: int = 60,
+    band_window: int = 20,
+    num_std: float = 2
+) -> Optional[dict]:
+    """
+    Bollinger band signal on the stock versus sector ETF spread, hedged with
+    the latest rolling beta so only the stock specific move is traded.
+
+    Args:
+        stock_closes (list[float]): Stock closes aligned with etf_closes.
+        etf_closes (list[float]): ETF closes.
+        beta_window (int, optional): Closes per beta estimate. Defaults to 60.
+        band_window (int, optional): Spread values per band. Defaults to 20.
+        num_std (float, optional): Band width in sample standard deviations. Defaults to 2.
+
+    Returns:
+        Optional[dict]: { 'beta', 'spread', 'upper_band', 'lower_band', 'direction' } where
+        direction is -1 to short the spread (sell the stock, buy the ETF) above the upper
+        band, 1 to buy it below the lower band, and 0 inside the bands. None until
+        enough closes are available.
+    """
+    if len(stock_closes) < max(beta_window, band_window):
+        return None
+    try:
+        hedge_ratio = beta(stock_closes[-beta_window:], etf_closes[-beta_window:])
+    except ValueError:
+        return None
+    values = spread(stock_closes[-band_window:], etf_closes[-band_window:], hedge_ratio)
+    middle = sum(values) / len(values)
+    deviation = (sum((value - middle) ** 2 for value in values) / (len(values) - 1)) ** 0.5
+    upper, lower = middle + num_std * deviation, middle - num_std * deviation
+    direction = 0
+    if deviation > 0 and values[-1] >= upper:
+        direction = -1
+    elif deviation > 0 and values[-1] <= lower:
+        direction = 1
+    return {
+        'beta': hedge_ratio,
+        'spread': values[-1],
+        'upper_band': upper,
+        'lower_band': lower,
+        'direction': direction,
+    }
+
+
+def hedge_qty(stock_qty: float, stock_price: float, etf_price: float, hedge_ratio: float) -> float:
+    """
+    ETF quantity offsetting the beta-weighted dollar exposure of a stock position.
+    """
+    return -hedge_ratio * stock_qty * stock_price / etf_price
@@ -1,9 +1,15 @@
-from statsmodels.tsa.stattools import adfuller
-from statsmodels.tsa.vector_ar.vecm import coint_johansen
-from sklearn.linear_model import LinearRegression
+from statsmodels.tsa.stattools import adfuller, coint, kpss
+from statsmodels.stats.multitest import multipletests
+from statsmodels.tsa.vector_ar.vecm import VECM, coint_johansen
+from sklearn.covariance import ledoit_wolf
+from sklearn.linear_model import HuberRegressor, LinearRegression, TheilSenRegressor
+from scipy.optimize import minimize
+from scipy.stats import norm, t as student_t
 import numpy as np
 import statsmodels.api as sm
+import warnings
 import nolds
+from typing import Optional
 
 
 def adf_test(data: list[float], lag: int = 1) -> tuple:
@@ -22,6 +28,55 @@ def adf_test(data: list[float], lag: int = 1) -> tuple:
     return adf_result
 
 
+def kpss_test(data: list[float], regression: str = 'c', lags: str = 'auto') -> tuple:
+    """
+    Execute the Kwiatkowski-Phillips-Schmidt-Shin test on the given data.
+    The null hypothesis is the reverse of ADF's: the series is stationary,
+    so a small p-value is evidence of a unit root.
+
+    Args:
+        data (list[float]): The data to test.
+        regression (str, optional): 'c' for stationarity around a constant,
+                                    'ct' around a trend. Defaults to 'c'.
+        lags (str, optional): The number of lags, or 'auto'. Defaults to 'auto'.
+
+    Returns:
+        a tuple containing the test statistic, p-value, number of lags used,
+        and the critical values. The p-value is interpolated from a table
+        and clipped to 0.01 - 0.1.
+    """
+    with warnings.catch_warnings():
+        # Warns when the p-value falls outside the table and is clipped
+        warnings.simplefilter('ignore')
+        return kpss(data, regression=regression, nlags=lags)
+
+
+def stationarity_test(data: list[float], significance: float = 0.05, lag: int = 1) -> dict:
+    """
+    Run ADF and KPSS together, calling the series stationary only when they
+    agree: ADF rejects a unit root and KPSS does not reject stationarity.
+    Requiring both cuts the false positives either test makes alone.
+
+    Args:
+        data (list[float]): The data to test.
+        significance (float, optional): The significance level of both tests. Defaults to 0.05.
+        lag (int, optional): The number of lags of the ADF test. Defaults to 1.
+
+    Returns:
+        dict: A dictionary containing:
+        - 'adf_p_value': The p-value of the ADF test.
+        - 'kpss_p_value': The p-value of the KPSS test.
+        - 'is_stationary': True if both tests agree the series is stationary.
+    """
+    adf_p_value = adf_test(data, lag)[1]
+    kpss_p_value = kpss_test(data)[1]
+    return {
+        'adf_p_value': adf_p_value,
+        'kpss_p_value': kpss_p_value,
+        'is_stationary': bool(adf_p_value < significance and kpss_p_value >= significance)
+    }
+
+
 def half_life(data: list[float]) -> float:
     """
     Calculate the half-life of a mean-reverting time series
@@ -65,7 +120,8 @@ def hurst_exponent(data: list[float]) -> float:
         data (list[float]): A list of float values representing the time series.
 
     Returns:
-        float: The Hurst Exponent of the time series
+        float: The Hurst Exponent of the time series,
+        see hurst_confidence_interval for its uncertainty.
     """
     if len(data) < 10:
         return .5
@@ -73,10 +129,219 @@ def hurst_exponent(data: list[float]) -> float:
     return nolds.hurst_rs(X)
 
 
+# Methods hurst_confidence_interval estimates the Hurst exponent with
+HURST_METHODS = ('dfa', 'rs')
+
+# Fewest window sizes the log-log scaling fit is run on
+HURST_MIN_SCALES = 4
+
+
+def _hurst_scales(length: int, min_window: int) -> np.ndarray:
+    """
+    Log spaced window sizes from min_window to a quarter of the series.
+    """
+    max_window = length // 4
+    if max_window <= min_window:
+        return np.array([], dtype=int)
+    return np.unique(np.logspace(np.log10(min_window), np.log10(max_window), 12).astype(int))
+
+
+def _dfa_fluctuation(increments: np.ndarray, window: int) -> float:
+    """
+    Root mean square of the linearly detrended profile over non-overlapping windows.
+    """
+    profile = np.cumsum(increments - increments.mean())
+    segments = profile[:len(profile) // window * window].reshape(-1, window)
+    t = np.arange(window)
+    slope, intercept = np.polyfit(t, segments.T, 1)
+    detrended = segments - (np.outer(slope, t) + intercept[:, None])
+    return float(np.sqrt(np.mean(detrended ** 2)))
+
+
+def _rescaled_range(increments: np.ndarray, window: int) -> float:
+    """
+    Average range of cumulative deviations over standard deviation across non-overlapping windows.
+    """
+    segments = increments[:len(increments) // window * window].reshape(-1, window)
+    deviations = np.cumsum(segments - segments.mean(axis=1, keepdims=True), axis=1)
+    ranges = deviations.max(axis=1) - deviations.min(axis=1)
+    deviation = segments.std(axis=1)
+    valid = deviation > 0
+    return float(np.mean(ranges[valid] / deviation[valid])) if valid.any() else float('nan')
+
+
+def hurst_confidence_interval(
+    data: list[float],
+    method: str = 'dfa',
+    confidence: float = 0.95,
+    min_window: int = 8
+) -> dict:
+    """
+    Estimate the Hurst exponent of a price series with a standard error,
+    by detrended fluctuation analysis (DFA) or rescaled range (R/S) of its
+    increments. The exponent is the slope of the log-log fit of fluctuation
+    against window size, its standard error and interval come from that fit.
+    DFA removes local linear trends in each window, so it is less biased and
+    noisy than R/S on short intraday windows. Read H < 0.5 as mean
+    reverting only when the whole interval is below 0.5.
+
+    Args:
+        data (list[float]): Prices or levels, oldest first.
+        method (str, optional): 'dfa' or 'rs'. Defaults to 'dfa'.
+        confidence (float, optional): Coverage of the interval. Defaults to 0.95.
+        min_window (int, optional): The smallest window size. Defaults to 8.
+
+    Returns:
+        dict: A dictionary containing:
+        - 'hurst': The estimated Hurst exponent.
+        - 'standard_error': The standard error of the estimate.
+        - 'confidence_interval': (low, high) at the requested confidence.
+        - 'windows': The number of window sizes fitted.
+
+    Raises:
+        ValueError: If the method is unknown or the series is too short
+                    for HURST_MIN_SCALES window sizes.
+    """
+    if method not in HURST_METHODS:
+        raise ValueError(f'method must be one of {", ".join(HURST_METHODS)}.')
+    increments = np.diff(np.array(data, dtype=float))
+    scales = _hurst_scales(len(increments), min_window)
+    if len(scales) < HURST_MIN_SCALES:
+        raise ValueError(f'Series too short for {HURST_MIN_SCALES} window sizes of at least {min_window}.')
+
+    fluctuation = _dfa_fluctuation if method == 'dfa' else _rescaled_range
+    values = np.array([fluctuation(increments, window) for window in scales])
+    valid = np.isfinite(values) & (values > 0)
+    if valid.sum() < HURST_MIN_SCALES:
+        raise ValueError('Series has too little variation to estimate the Hurst exponent.')
+    x, y = np.log(scales[valid]), np.log(values[valid])
+    slope, intercept = np.polyfit(x, y, 1)
+    residuals = y - (slope * x + intercept)
+    degrees_of_freedom = len(x) - 2
+    standard_error = float(np.sqrt(np.sum(residuals ** 2) / degrees_of_freedom / np.sum((x - x.mean()) ** 2)))
+    margin = student_t.ppf((1 + confidence) / 2, degrees_of_freedom) * standard_error
+    return {
+        'hurst': float(slope),
+        'standard_error': standard_error,
+        'confidence_interval': (float(slope - margin), float(slope + margin)),
+        'windows': len(x)
+    }
+
+
+def variance_ratio_test(data: list[float], period: int = 2) -> dict:
+    """
+    Lo-MacKinlay variance ratio test of a level series: the variance of
+    period-step changes over period times the variance of one-step changes.
+    A random walk has a ratio of 1, mean reversion pulls it below 1 and
+    trending pushes it above.
+
+    Args:
+        data (list[float]): Prices or levels, oldest first.
+        period (int, optional): The multi-step horizon in bars. Defaults to 2.
+
+    Returns:
+        dict: A dictionary containing:
+        - 'variance_ratio': The ratio.
+        - 'z_statistic': The homoskedastic test statistic of a ratio of 1.
+        - 'p_value': The two sided p-value.
+
+    Raises:
+        ValueError: If the period is below 2 or the series is too short for it.
+    """
+    if period < 2:
+        raise ValueError('period must be at least 2.')
+    levels = np.array(data, dtype=float)
+    increments = np.diff(levels)
+    n = len(increments)
+    if n <= period:
+        raise ValueError(f'The variance ratio test requires more than {period} increments.')
+    drift = increments.mean()
+    one_step = np.sum((increments - drift) ** 2) / (n - 1)
+    if not one_step:
+        raise ValueError('Series has no variation.')
+    multi_step = levels[period:] - levels[:-period]
+    scale = period * (n - period + 1) * (1 - period / n)
+    ratio = float(np.sum((multi_step - period * drift) ** 2) / scale / one_step)
+    z_statistic = (ratio - 1) / np.sqrt(2 * (2 * period - 1) * (period - 1) / (3 * period * n))
+    return {
+        'variance_ratio': ratio,
+        'z_statistic': float(z_statistic),
+        'p_value': float(2 * norm.sf(abs(z_statistic)))
+    }
+
+
+def screen_series(
+    data: list[float],
+    significance: float = 0.05,
+    max_half_life: Optional[float] = None,
+    max_hurst: Optional[float] = 0.5,
+    lag: int = 1,
+    period: int = 2
+) -> dict:
+    """
+    Screen a series, e.g. a spread, for tradable mean reversion with one
+    decision rule: ADF rejects a unit root, the half-life is positive and
+    within max_half_life, the Hurst exponent is below max_hurst, and the
+    variance ratio is below 1. Hurst and the variance ratio are skipped
+    when the series is too short or flat to estimate them.
+
+    Args:
+        data (list[float]): The series, oldest first.
+        significance (float, optional): The ADF significance level. Defaults to 0.05.
+        max_half_life (Optional[float], optional): Longest half-life in bars, None for no limit.
+        max_hurst (Optional[float], optional): Highest Hurst exponent, None for no limit. Defaults to 0.5.
+        lag (int, optional): The number of lags of the ADF test. Defaults to 1.
+        period (int, optional): The variance ratio horizon in bars. Defaults to 2.
+
+    Returns:
+        dict: A dictionary containing:
+        - 'adf_statistic', 'adf_p_value': The ADF test of the series.
+        - 'half_life': The half-life in bars, None when not mean reverting.
+        - 'hurst', 'hurst_confidence_interval': The DFA Hurst estimate, None when skipped.
+        - 'variance_ratio', 'variance_ratio_p_value': The variance ratio test, None when skipped.
+        - 'checks': { 'adf', 'half_life', 'hurst', 'variance_ratio': bool }, skipped checks pass.
+        - 'is_mean_reverting': True if every check passes.
+    """
+    adf_result = adf_test(data, lag)
+    result = {
+        'adf_statistic': float(adf_result[0]),
+        'adf_p_value': float(adf_result[1]),
+        'half_life': None,
+        'hurst': None,
+        'hurst_confidence_interval': None,
+        'variance_ratio': None,
+        'variance_ratio_p_value': None,
+    }
+    half_life_bars = half_life(data)
+    if half_life_bars is not None:
+        result['half_life'] = float(half_life_bars)
+    try:
+        hurst = hurst_confidence_interval(data)
+        result['hurst'], result['hurst_confidence_interval'] = hurst['hurst'], hurst['confidence_interval']
+    except ValueError:
+        pass
+    try:
+        ratio = variance_ratio_test(data, period)
+        result['variance_ratio'], result['variance_ratio_p_value'] = ratio['variance_ratio'], ratio['p_value']
+    except ValueError:
+        pass
+
+    checks = {
+        'adf': result['adf_p_value'] < significance,
+        'half_life': result['half_life'] is not None and (max_half_life is None or result['half_life'] <= max_half_life),
+        'hurst': result['hurst'] is None or max_hurst is None or result['hurst'] < max_hurst,
+        'variance_ratio': result['variance_ratio'] is None or result['variance_ratio'] < 1,
+    }
+    result['checks'] = checks
+    result['is_mean_reverting'] = all(checks.values())
+    return result
+
+
 def cointegration_adf_test(
     X: list[float],
     Y: list[float],
-    lag: int = 1
+    lag: int = 1,
+    confirm_with_kpss: bool = False
 ) -> dict:
     """
     Perform the Cointegration Augmented Dickey-Fuller (CADF)
@@ -91,6 +356,8 @@ def cointegration_adf_test(
         Y (list[float]): The second time series.
         lag (int, optional): The number of lags to include
         in the ADF test. Defaults to 1.
+        confirm_with_kpss (bool, optional): Also require a KPSS test of the
+        residuals not to reject stationarity at 5%. Defaults to False.
 
     Returns:
         dict: A dictionary containing the ADF test results, including:
@@ -100,7 +367,9 @@ def cointegration_adf_test(
                             the ADF test at 1%, 5%, and 10%.
         - 'is_cointegrated': A boolean indicating whether
                             the series are cointegrated
-                            (True if p-value < 0.05, False otherwise).
+                            (True if the ADF statistic is below the 5%
+                            critical value, and KPSS agrees when confirming).
+        - 'kpss_p_value': The p-value of the KPSS test, only when confirming.
     """
     X = np.array(X)
     Y = np.array(Y)
@@ -110,25 +379,106 @@ def cointegration_adf_test(
     model = sm.OLS(Y, X).fit()
     residual = model.resid
     adf_result = adfuller(residual, maxlag=lag, regression='c')
-    return {
+    result = {
         'adf_statistic': adf_result[0],
         'p_value': adf_result[1],
         'critical_values': adf_result[4],
         'is_cointegrated': adf_result[0] < adf_result[4]['5%']
     }
+    if confirm_with_kpss:
+        result['kpss_p_value'] = kpss_test(residual)[1]
+        result['is_cointegrated'] = result['is_cointegrated'] and result['kpss_p_value'] >= 0.05
+    return result
+
+
+def engle_granger_test(X: list[float], Y: list[float], lag: int = 1) -> dict:
+    """
+    Engle-Granger test of Y on X. Unlike cointegration_adf_test, the p-value
+    comes from MacKinnon's critical values for regression residuals, which
+    account for the hedge ratio being estimated from the same data.
+
+    Args:
+        X (list[float]): The first time series.
+        Y (list[float]): The second time series.
+        lag (int, optional): The number of lags in the ADF test of the residuals. Defaults to 1.
+
+    Returns:
+        dict: { 'statistic', 'p_value', 'critical_values' }, critical values keyed '1%', '5%', and '10%'.
+
+    Raises:
+        ValueError: If X and Y have different lengths.
+    """
+    if len(X) != len(Y):
+        raise ValueError('X and Y must have the same length.')
+    statistic, p_value, critical_values = coint(np.array(Y), np.array(X), trend='c', maxlag=lag, autolag=None)
+    return {
+        'statistic': float(statistic),
+        'p_value': float(p_value),
+        'critical_values': dict(zip(('1%', '5%', '10%'), (float(value) for value in critical_values))),
+    }
+
+
+# Multiple testing corrections adjust_p_values accepts, see statsmodels' multipletests
+P_VALUE_CORRECTIONS = ('bonferroni', 'fdr_bh')
+
+
+def adjust_p_values(p_values: list[float], method: str = 'fdr_bh') -> list[float]:
+    """
+    Adjust p-values for the number of hypotheses tested together, so scanning
+    a large universe does not turn up pairs by chance.
+
+    Args:
+        p_values (list[float]): The raw p-values, one per hypothesis.
+        method (str, optional): 'bonferroni' to control the family-wise error rate or
+                                'fdr_bh' for the Benjamini-Hochberg false discovery rate. Defaults to 'fdr_bh'.
+
+    Returns:
+        list[float]: The adjusted p-values in the same order, compared against the same significance level.
+
+    Raises:
+        ValueError: If the method is not one of P_VALUE_CORRECTIONS.
+    """
+    if method not in P_VALUE_CORRECTIONS:
+        raise ValueError(f'Unknown p-value correction {method}, expected one of {P_VALUE_CORRECTIONS}.')
+    if not p_values:
+        return []
+    return [float(p_value) for p_value in multipletests(p_values, method=method)[1]]
+
+
+# Columns of the Johansen critical value tables
+JOHANSEN_SIGNIFICANCE_LEVELS = ('90%', '95%', '99%')
+
+# Series the Johansen critical value tables cover
+JOHANSEN_MAX_SERIES = 12
+
+
+def _johansen_rank(test_statistics: list[float], critical_values: list[float]) -> int:
+    """
+    Count the rejected hypotheses of at most r cointegrating relationships,
+    r = 0, 1, ..., stopping at the first one that is not rejected.
+    """
+    rank = 0
+    for statistic, critical_value in zip(test_statistics, critical_values):
+        if statistic <= critical_value:
+            break
+        rank += 1
+    return rank
 
 
 def johansen_test(
     data: list[list[float]],
     det_order: int = - 1,
-    k_ar_diff: int = 1
+    k_ar_diff: int = 1,
+    significance: str = '95%'
 ) -> dict:
     """
     Perform the Johansen Test for cointegration on multiple time series.
     The Johansen Test determines the number of
     cointegrating relationships among a set of time series.
     It is based on the maximum likelihood estimation of a Vector Error
-    Correction Model (VECM).
+    Correction Model (VECM). Both the trace and maximum eigenvalue statistics
+    are compared against the critical value tables (MacKinnon-Haug-Michelis)
+    for the deterministic specification and number of series.
 
     Args:
         data (list[list[float]]): A list of time series,
@@ -141,36 +491,301 @@ def johansen_test(
             Defaults to -1.
         k_ar_diff (int, optional): The number of lags in the VAR model.
                                     Defaults to 1.
+        significance (str, optional): The critical value column ranks are
+                                      selected at, one of JOHANSEN_SIGNIFICANCE_LEVELS.
+                                      Defaults to '95%'.
 
     Returns:
         dict: A dictionary containing the Johansen Test results, including:
-        - 'eigenvalues': The eigenvalues used in the test.
-        - 'trace_statistics': The trace statistics for each hypothesis.
+        - 'eigenvalues': The eigenvalues, largest first.
+        - 'eigenvectors': The eigenvector of each eigenvalue, in the same order.
+        - 'cointegrating_vectors': The eigenvectors of the first
+                                   'cointegration_rank' eigenvalues scaled so
+                                   the first series has weight 1, usable as basket weights.
+        - 'trace_statistics': The trace statistics for each hypothesis
+                              of at most r = 0, 1, ... relationships.
         - 'critical_values': The critical values for the trace statistics at
                             90%, 95%, and 99%.
+        - 'max_eigen_statistics': The maximum eigenvalue statistics for each hypothesis.
+        - 'max_eigen_critical_values': Their critical values at 90%, 95%, and 99%.
+        - 'trace_rank': The rank selected by the trace statistics.
+        - 'max_eigen_rank': The rank selected by the maximum eigenvalue statistics.
         - 'cointegration_rank': The estimated number of
-                                cointegrating relationships.
+                                cointegrating relationships, the trace rank.
+
+    Raises:
+        ValueError: If the arguments are outside the critical value tables.
     """
+    if det_order not in (-1, 0, 1):
+        raise ValueError('det_order must be -1, 0, or 1.')
+    if significance not in JOHANSEN_SIGNIFICANCE_LEVELS:
+        raise ValueError(f'significance must be one of {", ".join(JOHANSEN_SIGNIFICANCE_LEVELS)}.')
+    if not 2 <= len(data) <= JOHANSEN_MAX_SERIES:
+        raise ValueError(f'The Johansen test requires 2 to {JOHANSEN_MAX_SERIES} series.')
+
     # Convert the input data to a numpy array and transpose
     data = np.array(data).T
 
     result = coint_johansen(data, det_order, k_ar_diff)
 
-    trace_statistics = result.lr1
-    critical_values = result.cvt
-    coint_rank = 0
-    for i in range(len(trace_statistics)):
-        if trace_statistics[i] > critical_values[i, 1]:  # Compare to 95% critical value
-            coint_rank += 1
-        else:
-            break
+    column = JOHANSEN_SIGNIFICANCE_LEVELS.index(significance)
+    trace_rank = _johansen_rank(result.lr1, result.cvt[:, column])
+    max_eigen_rank = _johansen_rank(result.lr2, result.cvm[:, column])
+    eigenvectors = [result.evec[:, i].tolist() for i in range(result.evec.shape[1])]
+    return {
+        'eigenvalues': result.eig.tolist(),
+        'eigenvectors': eigenvectors,
+        'cointegrating_vectors': [
+            [weight / (vector[0] or 1) for weight in vector] for vector in eigenvectors[:trace_rank]
+        ],
+        'trace_statistics': result.lr1,
+        'critical_values': result.cvt,
+        'max_eigen_statistics': result.lr2,
+        'max_eigen_critical_values': result.cvm,
+        'trace_rank': trace_rank,
+        'max_eigen_rank': max_eigen_rank,
+        'cointegration_rank': trace_rank
+    }
+
+
+def vecm(
+    data: list[list[float]],
+    coint_rank: Optional[int] = None,
+    k_ar_diff: int = 1,
+    steps: int = 1
+) -> dict:
+    """
+    Fit a vector error correction model to cointegrated series, with a
+    constant inside the cointegrating relation. Beyond whether the series are
+    cointegrated, the fit says how fast each series corrects deviations from
+    equilibrium and forecasts the levels and the spreads.
+
+    Args:
+        data (list[list[float]]): A list of time series, each a list of floats.
+        coint_rank (Optional[int], optional): Number of cointegrating relations,
+        None to use the rank selected by johansen_test.
+        k_ar_diff (int, optional): The number of lagged differences. Defaults to 1.
+        steps (int, optional): Bars to forecast. Defaults to 1.
+
+    Returns:
+        dict: A dictionary containing:
+        - 'rank': The number of cointegrating relations fitted.
+        - 'alpha': Adjustment speeds, one row per series and one column per relation.
+          Negative values pull a series back toward equilibrium.
+        - 'beta': Cointegrating vectors, one column per relation, normalized so the first rank rows are the identity.
+        - 'gamma': Short-run coefficients on the lagged differences, one row per series.
+        - 'forecast': Forecast levels, one row per step and one column per series.
+        - 'spread_forecast': The forecast of each cointegrating spread before its constant, one row per step.
+
+    Raises:
+        ValueError: If the series are not cointegrated or the rank is out of range.
+    """
+    if coint_rank is None:
+        coint_rank = johansen_test(data, det_order=0, k_ar_diff=k_ar_diff)['cointegration_rank']
+    if not 1 <= coint_rank < len(data):
+        raise ValueError(f'coint_rank must be between 1 and {len(data) - 1}, the series may not be cointegrated.')
+
+    levels = np.array(data, dtype=float).T
+    result = VECM(levels, k_ar_diff=k_ar_diff, coint_rank=coint_rank, deterministic='ci').fit()
+    forecast = result.predict(steps=steps)
+    beta = result.beta
+    return {
+        'rank': coint_rank,
+        'alpha': result.alpha.tolist(),
+        'beta': beta.tolist(),
+        'gamma': result.gamma.tolist(),
+        'forecast': forecast.tolist(),
+        'spread_forecast': (forecast @ beta).tolist(),
+    }
+
+
+def _return_matrix(returns: dict[str, list[float]]) -> tuple[list[str], np.ndarray]:
+    """
+    Stack a universe's return series into one row per symbol.
+
+    Raises:
+        ValueError: If there are fewer than two symbols or the series differ in length.
+    """
+    symbols = list(returns)
+    if len(symbols) < 2:
+        raise ValueError('At least two symbols are required.')
+    if len({len(series) for series in returns.values()}) != 1:
+        raise ValueError('Return series must have the same length.')
+    return symbols, np.array([returns[symbol] for symbol in symbols], dtype=float)
+
+
+# Estimators covariance_matrix supports
+COVARIANCE_ESTIMATORS = ('sample', 'ledoit_wolf')
+
+
+def covariance_matrix(returns: dict[str, list[float]], estimator: str = 'sample') -> dict:
+    """
+    Covariance matrix of the returns of a symbol universe. The sample
+    covariance is ill-conditioned when the universe is large relative to the
+    window, Ledoit-Wolf shrinks it toward a scaled identity by the amount
+    that minimizes expected error, which keeps it invertible for basket
+    weights and risk.
+
+    Args:
+        returns (dict[str, list[float]]): { symbol: returns }, aligned and of equal length.
+        estimator (str, optional): One of COVARIANCE_ESTIMATORS. Defaults to 'sample'.
+
+    Returns:
+        dict: { 'symbols': list[str], 'matrix': list[list[float]], 'shrinkage': float },
+        rows and columns in the order of 'symbols', shrinkage 0 for the sample estimator.
+
+    Raises:
+        ValueError: If the estimator is unknown.
+    """
+    if estimator not in COVARIANCE_ESTIMATORS:
+        raise ValueError(f'Unknown covariance estimator {estimator}, expected one of {", ".join(COVARIANCE_ESTIMATORS)}.')
+    symbols, matrix = _return_matrix(returns)
+    if estimator == 'ledoit_wolf':
+        covariance, shrinkage = ledoit_wolf(matrix.T)
+        return {'symbols': symbols, 'matrix': covariance.tolist(), 'shrinkage': float(shrinkage)}
+    return {'symbols': symbols, 'matrix': np.cov(matrix, ddof=1).tolist(), 'shrinkage': 0.0}
+
+
+def correlation_matrix(returns: dict[str, list[float]]) -> dict:
+    """
+    Correlation matrix of the returns of a symbol universe.
+
+    Args:
+        returns (dict[str, list[float]]): { symbol: returns }, aligned and of equal length.
+
+    Returns:
+        dict: { 'symbols': list[str], 'matrix': list[list[float]] }, NaN
+        for symbols whose returns have no variance.
+    """
+    symbols, matrix = _return_matrix(returns)
+    with np.errstate(divide='ignore', invalid='ignore'):
+        return {'symbols': symbols, 'matrix': np.corrcoef(matrix).tolist()}
+
+
+def rolling_correlation(X: list[float], Y: list[float], window: int) -> np.ndarray:
+    """
+    Correlation of two series over a trailing window at each point.
+
+    Args:
+        X (list[float]): The first series.
+        Y (list[float]): The second series, aligned with X.
+        window (int): Observations per estimate.
+
+    Returns:
+        np.ndarray: The correlations, padded with NaN for the first window - 1
+        values and NaN where either series is flat over the window.
+    """
+    X = np.array(X, dtype=float)
+    Y = np.array(Y, dtype=float)
+    if len(X) != len(Y):
+        raise ValueError('X and Y must have the same length.')
+    if len(X) < window:
+        raise ValueError('Window size larger than data length')
+    correlations = np.full(len(X), np.nan)
+    for end in range(window, len(X) + 1):
+        x, y = X[end - window:end], Y[end - window:end]
+        deviation = x.std() * y.std()
+        if deviation > 0:
+            correlations[end - 1] = np.mean((x - x.mean()) * (y - y.mean())) / deviation
+    return correlations
+
+
+def rolling_correlations(returns: dict[str, list[float]], window: int) -> dict[tuple[str, str], np.ndarray]:
+    """
+    Rolling correlation of every pair in a symbol universe.
+
+    Args:
+        returns (dict[str, list[float]]): { symbol: returns }, aligned and of equal length.
+        window (int): Observations per estimate.
+
+    Returns:
+        dict[tuple[str, str], np.ndarray]: { (symbol, other): rolling_correlation() } per pair,
+        each pair once in the order of the universe.
+    """
+    symbols, _ = _return_matrix(returns)
     return {
-        'trace_statistics': trace_statistics,
-        'critical_values': critical_values,
-        'cointegration_rank': coint_rank
+        (symbol, other): rolling_correlation(returns[symbol], returns[other], window)
+        for i, symbol in enumerate(symbols) for other in symbols[i + 1:]
     }
 
 
+def correlated_pairs(returns: dict[str, list[float]], min_correlation: float = 0.7) -> list[tuple[str, str, float]]:
+    """
+    Pairs whose returns are at least min_correlation correlated, a cheap
+    pre-filter before running cointegration tests on every pair.
+
+    Args:
+        returns (dict[str, list[float]]): { symbol: returns }, aligned and of equal length.
+        min_correlation (float, optional): The lowest correlation kept. Defaults to 0.7.
+
+    Returns:
+        list[tuple[str, str, float]]: (symbol, other, correlation), most correlated first.
+    """
+    correlations = correlation_matrix(returns)
+    symbols, matrix = correlations['symbols'], correlations['matrix']
+    pairs = [
+        (symbol, other, matrix[i][j])
+        for i, symbol in enumerate(symbols) for j, other in enumerate(symbols)
+        if j > i and matrix[i][j] >= min_correlation
+    ]
+    return sorted(pairs, key=lambda pair: pair[2], reverse=True)
+
+
+def pca(returns: dict[str, list[float]], n_components: Optional[int] = None) -> dict:
+    """
+    Principal component analysis of a universe's returns by eigen-decomposition
+    of their covariance matrix. The first component of an equity universe is
+    usually the market, later ones sectors or styles.
+
+    Args:
+        returns (dict[str, list[float]]): { symbol: returns }, aligned and of equal length.
+        n_components (Optional[int], optional): Components kept, None for all.
+
+    Returns:
+        dict: A dictionary containing:
+        - 'symbols': The symbols, in the order of each loading vector.
+        - 'eigenvalues': The variance of each component, largest first.
+        - 'loadings': One unit length vector of symbol weights per component, signed
+                      so the weights sum to a positive number.
+        - 'explained_variance_ratio': The fraction of total variance each component explains.
+        - 'factor_returns': The return series of each component.
+    """
+    symbols, matrix = _return_matrix(returns)
+    eigenvalues, eigenvectors = np.linalg.eigh(np.cov(matrix, ddof=1))
+    order = np.argsort(eigenvalues)[::-1][:n_components]
+    eigenvalues, eigenvectors = eigenvalues[order], eigenvectors[:, order]
+    # Eigenvectors are unique up to sign, fix it so results are reproducible
+    eigenvectors = eigenvectors * np.where(eigenvectors.sum(axis=0) < 0, -1, 1)
+    demeaned = matrix - matrix.mean(axis=1, keepdims=True)
+    total = np.trace(np.cov(matrix, ddof=1))
+    return {
+        'symbols': symbols,
+        'eigenvalues': eigenvalues.tolist(),
+        'loadings': eigenvectors.T.tolist(),
+        'explained_variance_ratio': (eigenvalues / total).tolist() if total else [0.0] * len(eigenvalues),
+        'factor_returns': (eigenvectors.T @ demeaned).tolist(),
+    }
+
+
+def factor_residuals(returns: dict[str, list[float]], n_factors: int) -> dict[str, list[float]]:
+    """
+    Returns left after removing the first n_factors principal components,
+    the idiosyncratic moves statistical arbitrage baskets trade on.
+
+    Args:
+        returns (dict[str, list[float]]): { symbol: returns }, aligned and of equal length.
+        n_factors (int): Leading components removed.
+
+    Returns:
+        dict[str, list[float]]: { symbol: residual returns }, demeaned.
+    """
+    symbols, matrix = _return_matrix(returns)
+    loadings = np.array(pca(returns, n_factors)['loadings'], dtype=float).reshape(-1, len(symbols))
+    demeaned = matrix - matrix.mean(axis=1, keepdims=True)
+    residuals = demeaned - loadings.T @ (loadings @ demeaned)
+    return {symbol: residuals[i].tolist() for i, symbol in enumerate(symbols)}
+
+
 def bollinger_bands(
     data: list[float],
     window: int,
@@ -197,6 +812,11 @@ def bollinger_bands(
               - 'middle_band': The middle band (SMA).
               - 'upper_band': The upper band.
               - 'lower_band': The lower band.
+              - 'percent_b': Where the value sits in the bands,
+                0 at the lower band and 1 at the upper band.
+              - 'bandwidth': The band width relative to the middle band.
+              Each series is padded with NaN for the first window - 1
+              values, %B is also NaN where the bands have no width.
     """
     data = np.array(data)
     if len(data) < window:
@@ -208,12 +828,18 @@ def bollinger_bands(
 
     upper_band = middle_band + (num_std * std_dev)
     lower_band = middle_band - (num_std * std_dev)
+    width = upper_band - lower_band
+    with np.errstate(divide='ignore', invalid='ignore'):
+        percent_b = np.where(width > 0, (data[window-1:] - lower_band) / width, np.nan)
+        bandwidth = width / middle_band
 
     pad = window - 1
     return {
         'middle_band': np.pad(middle_band, (pad, 0), constant_values=np.nan),
         'upper_band': np.pad(upper_band, (pad, 0), constant_values=np.nan),
-        'lower_band': np.pad(lower_band, (pad, 0), constant_values=np.nan)
+        'lower_band': np.pad(lower_band, (pad, 0), constant_values=np.nan),
+        'percent_b': np.pad(percent_b, (pad, 0), constant_values=np.nan),
+        'bandwidth': np.pad(bandwidth, (pad, 0), constant_values=np.nan)
     }
 
 
@@ -234,6 +860,8 @@ def variance(data: list[float]) -> float:
     """
     Calculate the variance of a list of numbers.
     Variance measures the spread of the data points around the mean.
+    For a stream of values use windows.RunningStats, which keeps the
+    count, mean, and variance without storing the values.
 
     Args:
         data (list[float]): A list of numerical values.
@@ -260,36 +888,300 @@ def standard_deviation(data: list[float]) -> float:
     return variance(data) ** 0.5
 
 
-def linear_regression(X: list[float], Y: list[float]) -> tuple[float, float]:
+def quantile(data: list[float], q: float) -> float:
+    """
+    Calculate a quantile of a list of numbers,
+    interpolating linearly between the closest ranks.
+
+    Args:
+        data (list[float]): A list of numerical values.
+        q (float): The quantile, 0 to 1 (e.g., 0.05 for the 5th percentile).
+
+    Returns:
+        float: The quantile of the input data.
+
+    Raises:
+        ValueError: If the data is empty or q is outside 0 to 1.
+    """
+    if not data:
+        raise ValueError('data must not be empty.')
+    if not 0 <= q <= 1:
+        raise ValueError('q must be between 0 and 1.')
+    ordered = sorted(data)
+    position = (len(ordered) - 1) * q
+    lower = int(position)
+    upper = min(lower + 1, len(ordered) - 1)
+    return ordered[lower] + (ordered[upper] - ordered[lower]) * (position - lower)
+
+
+def percentile(data: list[float], p: float) -> float:
+    """
+    Calculate a percentile of a list of numbers, see quantile().
+
+    Args:
+        data (list[float]): A list of numerical values.
+        p (float): The percentile, 0 to 100.
+
+    Returns:
+        float: The percentile of the input data.
+    """
+    return quantile(data, p / 100)
+
+
+def skewness(data: list[float]) -> float:
+    """
+    Calculate the skewness of a list of numbers, the third standardized moment.
+    Negative skew means a longer left tail, e.g. P&L with rare large losses.
+
+    Args:
+        data (list[float]): A list of numerical values.
+
+    Returns:
+        float: The skewness of the input data, 0 when it does not vary.
+    """
+    mean_value = mean(data)
+    deviation = standard_deviation(data)
+    if not deviation:
+        return 0.0
+    return sum((x - mean_value) ** 3 for x in data) / len(data) / deviation ** 3
+
+
+def excess_kurtosis(data: list[float]) -> float:
+    """
+    Calculate the excess kurtosis of a list of numbers, the fourth
+    standardized moment less the normal distribution's 3. Positive values
+    mean fatter tails than a normal distribution with the same variance.
+
+    Args:
+        data (list[float]): A list of numerical values.
+
+    Returns:
+        float: The excess kurtosis of the input data, 0 when it does not vary.
+    """
+    mean_value = mean(data)
+    data_variance = variance(data)
+    if not data_variance:
+        return 0.0
+    return sum((x - mean_value) ** 4 for x in data) / len(data) / data_variance ** 2 - 3
+
+
+def describe(data: list[float]) -> dict:
+    """
+    Summarize the distribution of a list of numbers for risk reporting,
+    e.g. a spread or per-trade P&L.
+
+    Args:
+        data (list[float]): A list of numerical values.
+
+    Returns:
+        dict: { 'count', 'mean', 'standard_deviation', 'skewness', 'excess_kurtosis',
+        'min', 'max', 'quantiles': { 0.01, 0.05, 0.25, 0.5, 0.75, 0.95, 0.99: value } }.
+    """
+    return {
+        'count': len(data),
+        'mean': mean(data),
+        'standard_deviation': standard_deviation(data),
+        'skewness': skewness(data),
+        'excess_kurtosis': excess_kurtosis(data),
+        'min': min(data),
+        'max': max(data),
+        'quantiles': {q: quantile(data, q) for q in (0.01, 0.05, 0.25, 0.5, 0.75, 0.95, 0.99)},
+    }
+
+
+# Fewest returns a GARCH(1,1) fit is attempted on
+GARCH_MIN_OBSERVATIONS = 50
+
+
+def _garch_variances(params: np.ndarray, residuals: np.ndarray) -> np.ndarray:
+    """
+    Conditional variances of a GARCH(1,1) process, started at the sample variance.
+    """
+    omega, alpha, beta = params
+    variances = np.empty(len(residuals))
+    variances[0] = residuals.var()
+    for t in range(1, len(residuals)):
+        variances[t] = omega + alpha * residuals[t-1] ** 2 + beta * variances[t-1]
+    return variances
+
+
+def _garch_negative_log_likelihood(params: np.ndarray, residuals: np.ndarray) -> float:
+    variances = _garch_variances(params, residuals)
+    return 0.5 * float(np.sum(np.log(2 * np.pi) + np.log(variances) + residuals ** 2 / variances))
+
+
+def garch(returns: list[float]) -> dict:
+    """
+    Fit a GARCH(1,1) model to a return series by maximum likelihood and
+    forecast the next period's volatility. The conditional variance follows
+    sigma²_t = omega + alpha * e²_(t-1) + beta * sigma²_(t-1), where e are
+    the demeaned returns, so recent shocks raise the forecast and it decays
+    back to the long run variance at rate alpha + beta.
+
+    Args:
+        returns (list[float]): Periodic returns, oldest first.
+
+    Returns:
+        dict: A dictionary containing the fit:
+        - 'omega', 'alpha', 'beta': The estimated parameters.
+        - 'persistence': alpha + beta, below 1 for a stationary fit.
+        - 'log_likelihood': The maximized Gaussian log likelihood.
+        - 'conditional_volatility': The fitted volatility of each period.
+        - 'forecast_volatility': The one-step-ahead volatility forecast.
+        - 'long_run_volatility': The unconditional volatility of the fit.
+        Volatilities are per period, in the units of the returns.
+
+    Raises:
+        ValueError: If there are fewer than GARCH_MIN_OBSERVATIONS returns,
+                    the returns have no variance, or the optimizer did not converge.
+    """
+    returns = np.array(returns, dtype=float)
+    if len(returns) < GARCH_MIN_OBSERVATIONS:
+        raise ValueError(f"GARCH requires at least {GARCH_MIN_OBSERVATIONS} returns.")
+    residuals = returns - returns.mean()
+    scale = residuals.std()
+    if scale == 0:
+        raise ValueError("Returns have no variance.")
+
+    # Fit on unit variance residuals so omega is not vanishingly small
+    standardized = residuals / scale
+    result = minimize(
+        _garch_negative_log_likelihood,
+        x0=np.array([0.1, 0.05, 0.85]),
+        args=(standardized,),
+        method='SLSQP',
+        bounds=[(1e-8, None), (0.0, 1.0), (0.0, 1.0)],
+        constraints=[{'type': 'ineq', 'fun': lambda params: 1 - 1e-6 - params[1] - params[2]}]
+    )
+    # The last iterate of a failed fit is not an estimate, sizing on it would be arbitrary
+    if not result.success:
+        raise ValueError(f"GARCH fit did not converge: {result.message}")
+    omega, alpha, beta = result.x
+    variances = _garch_variances(result.x, standardized)
+    forecast = omega + alpha * standardized[-1] ** 2 + beta * variances[-1]
+    return {
+        'omega': float(omega * scale ** 2),
+        'alpha': float(alpha),
+        'beta': float(beta),
+        'persistence': float(alpha + beta),
+        'log_likelihood': float(-result.fun - len(residuals) * np.log(scale)),
+        'conditional_volatility': (np.sqrt(variances) * scale).tolist(),
+        'forecast_volatility': float(np.sqrt(forecast) * scale),
+        'long_run_volatility': float(np.sqrt(omega / (1 - alpha - beta)) * scale)
+    }
+
+
+def volatility_scale(forecast_volatility: float, target_volatility: float, max_scale: float = 2.0) -> float:
+    """
+    Scale a position inversely to its forecast volatility, e.g. from garch(),
+    so each position carries about the target volatility.
+
+    Args:
+        forecast_volatility (float): The forecast volatility of the instrument.
+        target_volatility (float): The volatility a full size position should carry,
+                                   in the same units as the forecast.
+        max_scale (float, optional): Cap on the scale when the forecast is very low.
+                                     Defaults to 2.0.
+
+    Returns:
+        float: The multiplier to apply to the position size, max_scale if the forecast is 0.
+    """
+    if forecast_volatility <= 0:
+        return max_scale
+    return min(target_volatility / forecast_volatility, max_scale)
+
+
+# Estimators linear_regression supports, 'ols' is ordinary least squares
+REGRESSION_METHODS = ('ols', 'theil_sen', 'huber')
+
+# Residuals beyond this many scale estimates are down-weighted by Huber regression
+HUBER_EPSILON = 1.35
+
+
+def linear_regression(X: list[float], Y: list[float], method: str = 'ols') -> tuple[float, float]:
     """
     Perform simple linear regression to
     fit a line to the data using scikit-learn.
     The line is of the form: Y = a * X + b, where:
     - a is the slope.
     - b is the intercept.
+    The robust methods keep a hedge ratio from being dragged by a handful of
+    bad prints or flash crash bars: Theil-Sen takes the median slope over
+    pairs of points, Huber down-weights observations with large residuals.
 
     Args:
         X (list[float]): The independent variable (predictor).
         Y (list[float]): The dependent variable (response).
+        method (str, optional): One of REGRESSION_METHODS. Defaults to 'ols'.
 
     Returns:
         Tuple[float, float]: A tuple containing
         the slope (a) and intercept (b) of the fitted line.
+
+    Raises:
+        ValueError: If X and Y differ in length or the method is unknown.
     """
     if len(X) != len(Y):
         raise ValueError("X and Y must have the same length.")
+    if method not in REGRESSION_METHODS:
+        raise ValueError(f'Unknown regression method {method}, expected one of {", ".join(REGRESSION_METHODS)}.')
     X_reshaped = [[x] for x in X]
-    model = LinearRegression()
+    if method == 'theil_sen':
+        model = TheilSenRegressor(random_state=0)
+    elif method == 'huber':
+        model = HuberRegressor(epsilon=HUBER_EPSILON, alpha=0.0, max_iter=1000)
+    else:
+        model = LinearRegression()
     model.fit(X_reshaped, Y)
     a = model.coef_[0]
     b = model.intercept_
     return a, b
 
 
+def least_squares(X: list[list[float]], Y: list[float]) -> dict:
+    """
+    Fit Y = b0 + b1*X1 + ... + bn*Xn by ordinary least squares, solved
+    with SVD so nearly collinear predictors (e.g. the legs of a multi-leg
+    hedge) stay numerically stable.
+
+    Args:
+        X (list[list[float]]): One row per observation, one column per independent variable.
+        Y (list[float]): The dependent variable (response).
+
+    Returns:
+        dict: { 'intercept': float, 'coefficients': list[float], 'residuals': list[float],
+        'r_squared': float }. R² is 1.0 when Y has no variance and is fitted exactly.
+
+    Raises:
+        ValueError: If X and Y differ in length or there are not more
+                    observations than parameters.
+    """
+    if len(X) != len(Y):
+        raise ValueError("X and Y must have the same length.")
+    X_array = np.array(X, dtype=float)
+    if X_array.ndim == 1:
+        X_array = X_array.reshape(-1, 1)
+    Y_array = np.array(Y, dtype=float)
+    design = np.column_stack((np.ones(len(Y_array)), X_array))
+    if design.shape[0] <= design.shape[1]:
+        raise ValueError("More observations than parameters are required.")
+    beta, _, _, _ = np.linalg.lstsq(design, Y_array, rcond=None)
+    residuals = Y_array - design @ beta
+    total = float(np.sum((Y_array - Y_array.mean()) ** 2))
+    residual_sum = float(np.sum(residuals ** 2))
+    return {
+        'intercept': float(beta[0]),
+        'coefficients': beta[1:].tolist(),
+        'residuals': residuals.tolist(),
+        'r_squared': 1 - residual_sum / total if total else float(residual_sum == 0),
+    }
+
+
 def multiple_regression(X: list[list[float]], Y: list[float]) -> list[float]:
     """
     Perform multiple linear regression to fit a hyperplane
-    to the data using scikit-learn. The model is of the form:
+    to the data, see least_squares for the residuals and R².
+    The model is of the form:
     Y = b0 + b1*X1 + b2*X2 + ... + bn*Xn, where:
     - b0 is the intercept.
     - b1, b2, ..., bn are the coefficients for the independent variables.
@@ -303,26 +1195,5 @@ def multiple_regression(X: list[list[float]], Y: list[float]) -> list[float]:
         list[float]: A list containing the intercept
         (b0) and coefficients (b1, b2, ..., bn).
     """
-    if len(X) != len(Y):
-        raise ValueError("X and Y must have the same length.")
-    X_array = np.array(X)
-    if X_array.ndim == 1:
-        X_array = X_array.reshape(-1, 1)
-    model = LinearRegression().fit(X_array, Y)
-    return [model.intercept_] + model.coef_.tolist()
-
-
-def gather_close_data() -> None:
-    pass
-
-
-def gather_open_data() -> None:
-    pass
-
-
-def gather_high_data() -> None:
-    pass
-
-
-def gather_low_data() -> None:
-    pass
+    fit = least_squares(X, Y)
+    return [fit['intercept']] + fit['coefficients']
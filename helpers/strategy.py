@@ -1,7 +1,7 @@
 import pytz
-from datetime import datetime, timedelta
+from datetime import datetime
 from alpaca.trading.enums import OrderSide, TimeInForce
-from . import broker, logger
+from . import brokers, drawdown, fx, logger, observer, performance, sessions
 from threading import Lock
 from typing import Optional
 
@@ -10,25 +10,36 @@ class TradingStateManager:
     """Manages trading positions and P&L state for a strategy.
 
     Attributes:
-        positions: Dictionary tracking current positions {symbol: {qty, entry_price}}
+        positions: Dictionary tracking current positions {symbol: {qty, entry_price, currency}},
+                   entry prices in the instrument's quote currency
         lock: Thread lock for concurrent access to positions
         logger: Strategy-specific logger instance
         open_orders: Dictionary tracking working orders
-        daily_pnl: Realized profit/loss for the current trading day
+        daily_pnl: Realized profit/loss for the current trading day, in the base currency
+        realized_pnl: Realized profit/loss since the strategy started, in the base currency
+        marks: Latest price seen per symbol, used to value open positions
+        hedges: ETF legs held against stock positions, unwound when the stock position closes
+        performance: Optional LivePerformance closed trades and equity are reported to
         market_close_buffer: Minutes before market close to initiate liquidation
     """
-    def __init__(self, logger: logger.Logger):
+    def __init__(self, logger: logger.Logger, performance_tracker: Optional[performance.LivePerformance] = None):
         """Initializes trading state manager for a specific strategy.
 
         Args:
             strategy_name: Identifier for strategy-specific logging
+            performance_tracker: Optional LivePerformance for live P&L reporting
         """
-        self.positions = {}  # { symbol: { 'qty': int, 'entry_price': float} }
+        self.positions = {}  # { symbol: { 'qty': float, 'entry_price': float, 'currency': str } }
         self.lock = Lock()
         self.logger = logger
+        self.open_orders = {}  # { order_id: { 'symbol': str, 'qty': float, 'limit_price': float } }
         self.daily_pnl = 0.0
+        self.realized_pnl = 0.0
+        self.marks = {}  # { symbol: float }
+        self.hedges = {}  # { stock: { 'symbol': ETF, 'qty': float } }
+        self.performance = performance_tracker
 
-    def update_position(self, symbol: str, qty: int, price: float) -> None:
+    def update_position(self, symbol: str, qty: float, price: float) -> None:
         """Updates position for a symbol with thread-safe locking.
 
         Calculates new average price for additive positions and updates P&L
@@ -36,15 +47,17 @@ class TradingStateManager:
 
         Args:
             symbol: Trading symbol to update
-            qty: Quantity to add/remove from position (positive for long, negative for short)
+            qty: Quantity to add/remove from position (positive for long, negative for short),
+                 fractional for notional orders
             price: Execution price for this transaction
         """
         with self.lock:
             current = self.positions.get(symbol, {'qty': 0, 'entry_price': 0.0})
             new_qty = current['qty'] + qty
 
-            if new_qty == 0:
-                self._update_pnl(symbol, current['entry_price'], price)
+            # Fractional fills leave float dust, so treat near-zero as flat
+            if abs(new_qty) < 1e-9:
+                self._update_pnl(current['qty'], current['entry_price'], price, symbol)
                 del self.positions[symbol]
             else:
                 total_value = (current['qty'] * current['entry_price']) + (qty * price)
@@ -52,6 +65,7 @@ class TradingStateManager:
                 self.positions[symbol] = {
                     'qty': new_qty,
                     'entry_price': new_price,
+                    'currency': fx.currency_of(symbol),
                     'timestamp': datetime.now(pytz.utc)
                 }
 
@@ -61,37 +75,76 @@ class TradingStateManager:
         Iterates through all positions, submits market orders to close them,
         and handles any execution errors.
         """
+        # Snapshot under the lock, update_position takes it again per symbol
         with self.lock:
-            for symbol, position in self.positions.items():
-                try:
-                    filled_price = broker.place_market_order(
-                            symbol=symbol,
-                            qty=abs(position['qty']),
-                            side=OrderSide.SELL if position['qty'] > 0 else OrderSide.BUY,
-                            time_in_force=TimeInForce.DAY
-                        )
-                    self.update_position(symbol, -position['qty'], 0)
-                    self._update_pnl(position['qty'], position['entry_price'], filled_price)
-                except Exception as e:
-                    self.logger.error(f'Failed to liquidate {symbol}: {e}')
-
-    def _update_pnl(self, qty: int, entry_price: float, exit_price: float):
+            positions = list(self.positions.items())
+        for symbol, position in positions:
+            try:
+                filled_price = brokers.get_broker().submit_market_order(
+                        symbol=symbol,
+                        qty=abs(position['qty']),
+                        side=OrderSide.SELL if position['qty'] > 0 else OrderSide.BUY,
+                        time_in_force=TimeInForce.DAY
+                    )
+                # Closing the position realizes its P&L at the fill price
+                self.update_position(symbol, -position['qty'], filled_price or self.marks.get(symbol, position['entry_price']))
+            except Exception as e:
+                self.logger.error(f'Failed to liquidate {symbol}: {e}')
+        # Hedge legs were liquidated with everything else, those that failed are still in positions
+        with self.lock:
+            self.hedges = {stock: leg for stock, leg in self.hedges.items() if leg['symbol'] in self.positions}
+
+    def _update_pnl(self, qty: int, entry_price: float, exit_price: float, symbol: Optional[str] = None):
         """Updates daily realized P&L with closed position.
 
         Args:
             qty: Position quantity closed
             entry_price: Average entry price of position
             exit_price: Execution price for closing trade
+            symbol: Symbol of the position, its P&L is converted to the base currency
+        """
+        pnl = (exit_price - entry_price) * qty
+        if symbol:
+            pnl = self._to_base(pnl, symbol)
+        self.daily_pnl += pnl
+        self.realized_pnl += pnl
+        if self.performance:
+            self.performance.record_trade(pnl)
+
+    def _to_base(self, amount: float, symbol: str) -> float:
+        """Converts an amount in a symbol's quote currency to the base currency.
+
+        A missing rate is logged and the amount kept unconverted, so P&L is never dropped.
+        """
+        try:
+            return fx.to_base(amount, symbol)
+        except ValueError as e:
+            self.logger.error(f'Error converting {symbol} P&L to {fx.get_base_currency()}: {e}')
+            return amount
+
+    def mark_price(self, symbol: str, price: float) -> None:
+        """Records the latest price of a symbol for valuing open positions."""
+        self.marks[symbol] = price
+
+    def total_pnl(self) -> float:
+        """Returns realized P&L plus open positions marked at their latest price, in the base currency.
+
+        Positions without a mark are valued at their entry price.
         """
-        self.daily_pnl += (exit_price - entry_price) * qty
+        with self.lock:
+            unrealized = sum(
+                self._to_base((self.marks.get(symbol, position['entry_price']) - position['entry_price']) * position['qty'], symbol)
+                for symbol, position in self.positions.items()
+            )
+            return self.realized_pnl + unrealized
 
 
 class RiskManager:
     """Validates orders against risk parameters and current positions.
 
     Attributes:
-        max_position_size: Maximum USD value per symbol position
-        daily_loss_limit: Maximum allowed daily loss in USD
+        max_position_size: Maximum value per symbol position, in the base currency
+        daily_loss_limit: Maximum allowed daily loss, in the base currency
         state: Reference to associated TradingStateManager
     """
 
@@ -105,29 +158,39 @@ class RiskManager:
         self.daily_loss_limit = -5000
         self.state = state_manager
 
-    def validate_order(self, symbol: str, qty: int, price: float) -> bool:
+    def validate_order(self, symbol: str, qty: int, price: float, extended_hours: bool = False) -> bool:
         """Validates order against all risk checks.
 
         Args:
             symbol: Trading symbol for order
             qty: Proposed order quantity (positive for long, negative for short)
             price: Current market price for calculations
+            extended_hours: Whether the order may trade in the pre-market and post-market sessions
 
         Returns:
             bool: True if order passes all risk checks, False otherwise
         """
-        if not broker.is_market_open():
-            self.state.logger.warning('Market closed - rejecting order')
+        session = brokers.get_broker().get_session()
+        if session != sessions.MarketSession.REGULAR and not (extended_hours and sessions.is_extended_hours(session)):
+            self.state.logger.warning(f'Market {session.value} - rejecting order')
             return False
 
         # prevents same direction trading
         if self._same_direction_trade(symbol, qty):
             return False
 
-        if self._exceeds_position_size(symbol, qty, price):
+        if self._not_shortable(symbol, qty):
             return False
 
-        if self._exceeds_daily_loss_limit(qty, price):
+        # Limits are in the base currency, an order that cannot be converted is rejected by name
+        try:
+            if self._exceeds_position_size(symbol, qty, price):
+                return False
+
+            if self._exceeds_daily_loss_limit(symbol, qty, price):
+                return False
+        except ValueError as e:
+            self.state.logger.error(f'Missing FX rate for {fx.currency_of(symbol)}, rejecting {qty} {symbol} order: {e}')
             return False
 
         return True
@@ -142,21 +205,51 @@ class RiskManager:
             return True
         return False
 
-    def _exceeds_daily_loss_limit(self, qty: int, price: float) -> bool:
+    def _not_shortable(self, symbol: str, qty: int) -> bool:
+        """
+        Blocks orders that would open or grow a short in an asset that cannot be borrowed
+        """
+        current_qty = self.state.positions.get(symbol, {}).get('qty', 0)
+        if current_qty + qty >= 0 or qty > 0:
+            return False
+        try:
+            if brokers.get_broker().is_shortable(symbol):
+                return False
+            self.state.logger.warning(f'{symbol} is not shortable or easy to borrow, rejecting {qty} order')
+        except Exception as e:
+            self.state.logger.error(f'Error checking short eligibility for {symbol}: {e}')
+        return True
+
+    def _exceeds_daily_loss_limit(self, symbol: str, qty: int, price: float) -> bool:
         """
         Projects if order would exceed daily loss limit.
         Uses conservative 2% adverse move assumption for open positions.
+
+        Raises:
+            ValueError: If the symbol's quote currency has no FX rate.
         """
-        projected_pnl = self._calculate_projected_pnl(qty, price)
+        projected_pnl = self._calculate_projected_pnl(symbol, qty, price)
         if (self.state.daily_pnl + projected_pnl) < self.daily_loss_limit:
             self.state.logger.warning(f"Daily loss limit exceeded: {self.state.daily_pnl + projected_pnl:.2f}")
             return True
         return False
 
+    def _calculate_projected_pnl(self, symbol: str, qty: int, price: float) -> float:
+        """Projects the loss of the order in the base currency if price moves 2% against it.
+
+        Raises:
+            ValueError: If the symbol's quote currency has no FX rate.
+        """
+        return -fx.to_base(abs(qty * price), symbol) * 0.02
+
     def _exceeds_position_size(self, symbol: str, qty: int, price: float) -> bool:
-        """Checks if order exceeds maximum position size."""
+        """Checks if order exceeds maximum position size.
+
+        Raises:
+            ValueError: If the symbol's quote currency has no FX rate.
+        """
         position = self.state.positions.get(symbol, {'qty': 0})
-        new_notional = abs((position['qty'] + qty) * price)
+        new_notional = fx.to_base(abs((position['qty'] + qty) * price), symbol)
         if new_notional > self.max_position_size:
             self.state.logger.warning(f'Position limit exceeded: {new_notional: .2f} / {self.max_position_size}')
             return True
@@ -169,39 +262,98 @@ class OrderExecutor:
     Attributes:
         state: TradingStateManager for position updates
         risk: RiskManager for order validation
+        drawdown: Optional DrawdownGuard scaling or blocking new positions
     """
 
-    def __init__(self, state_manager: TradingStateManager, risk_manager: RiskManager):
+    def __init__(
+        self,
+        state_manager: TradingStateManager,
+        risk_manager: RiskManager,
+        drawdown_guard: Optional[drawdown.DrawdownGuard] = None
+    ):
         """Initializes executor with state and risk components.
 
         Args:
             state_manager: TradingStateManager instance
             risk_manager: RiskManager instance
-            strategy_universe: list of strategies known universe
+            drawdown_guard: Optional DrawdownGuard for the strategy
         """
         self.state = state_manager
         self.risk = risk_manager
+        self.drawdown = drawdown_guard
+
+    def mark_to_market(self, symbol: str, price: float) -> None:
+        """Values the symbol's position at a new price and updates the drawdown guard and live P&L report.
+
+        Args:
+            symbol: Trading symbol the price is for
+            price: Latest price of the symbol
+        """
+        self.state.mark_price(symbol, price)
+        if self.drawdown or self.state.performance:
+            pnl = self.state.total_pnl()
+            if self.drawdown:
+                self.drawdown.update(pnl)
+            if self.state.performance:
+                self.state.performance.record_equity(pnl)
+
+    def _size_for_drawdown(self, symbol: str, qty: float, fractional: bool = False) -> float:
+        """Scales an order by the drawdown guard, orders that only reduce a position are left as is.
 
-    def execute_market_order(self, symbol: str, qty: int) -> bool:
+        A scaled share order is rounded toward zero to whole shares, since
+        market, limit, and short orders cannot be fractional, and comes
+        back as 0 when less than a share is left, so it is skipped.
+
+        Args:
+            symbol: Trading symbol for order
+            qty: Order quantity (positive for long, negative for short)
+            fractional: Whether the order may be fractional, as dollar-sized orders are
+
+        Returns:
+            float: The quantity to order, 0 to skip the order
+        """
+        if self.drawdown is None:
+            return qty
+        current_qty = self.state.positions.get(symbol, {}).get('qty', 0)
+        if current_qty * qty < 0 and abs(qty) <= abs(current_qty):
+            return qty
+        multiplier = self.drawdown.size_multiplier()
+        if multiplier == 1:
+            return qty
+        sized = qty * multiplier if fractional else int(qty * multiplier)
+        self.state.logger.warning(
+            f'{self.drawdown.strategy} is {self.drawdown.state.value}, sizing {symbol} order at {multiplier:.0%}'
+            + ('' if sized else ', skipping it')
+        )
+        return sized
+
+    def execute_market_order(self, symbol: str, qty: int, client_order_id: Optional[str] = None) -> bool:
         """Executes market order with full risk validation lifecycle.
 
         Args:
             symbol: Trading symbol for order
             qty: Order quantity (positive for long, negative for short)
+            client_order_id: Optional deterministic ID so the order is submitted at most once
 
         Returns:
             bool: True if order executed successfully, False otherwise
         """
         try:
+            qty = self._size_for_drawdown(symbol, qty)
+            if not qty:
+                return False
             current_price = self._get_current_price(symbol)
             if not current_price or not self.risk.validate_order(symbol, qty, current_price):
                 return False
+            if self._observing(f'market order for {qty} {symbol}'):
+                return False
 
-            filled_price = broker.place_market_order(
+            filled_price = brokers.get_broker().submit_market_order(
                 symbol=symbol,
                 qty=abs(qty),
                 side=OrderSide.BUY if qty > 0 else OrderSide.SELL,
-                time_in_force=TimeInForce.DAY
+                time_in_force=TimeInForce.DAY,
+                client_order_id=client_order_id
             )
 
             self.state.update_position(
@@ -214,18 +366,113 @@ class OrderExecutor:
             self.state.logger.error(f'Execution market order failed {e}')
             return False
 
+    def execute_notional_order(self, symbol: str, notional: float, client_order_id: Optional[str] = None) -> bool:
+        """Executes a dollar-sized market order using fractional shares.
+
+        The share quantity is estimated from the current price so the same
+        risk checks and position tracking apply as for share-sized orders.
+
+        Args:
+            symbol: Trading symbol for order
+            notional: Dollar amount (positive for buy, negative for sell)
+            client_order_id: Optional deterministic ID so the order is submitted at most once
+
+        Returns:
+            bool: True if order executed successfully, False otherwise
+        """
+        try:
+            current_price = self._get_current_price(symbol)
+            if not current_price:
+                return False
+            qty = self._size_for_drawdown(symbol, notional / current_price, fractional=True)
+            if not qty:
+                return False
+            notional = qty * current_price
+            if not self.risk.validate_order(symbol, qty, current_price):
+                return False
+            if self._observing(f'notional order for ${notional:.2f} of {symbol}'):
+                return False
+
+            filled_price = brokers.get_broker().submit_notional_order(
+                symbol=symbol,
+                notional=abs(notional),
+                side=OrderSide.BUY if notional > 0 else OrderSide.SELL,
+                time_in_force=TimeInForce.DAY,
+                client_order_id=client_order_id
+            )
+
+            self.state.update_position(
+                symbol=symbol,
+                qty=qty,
+                price=filled_price or current_price
+            )
+            return True
+        except Exception as e:
+            self.state.logger.error(f'Execution notional order failed {e}')
+            return False
+
+    def execute_limit_order(
+        self,
+        symbol: str,
+        qty: float,
+        limit_price: float,
+        extended_hours: bool = False,
+        client_order_id: Optional[str] = None
+    ) -> Optional[str]:
+        """Submits a limit order after risk validation, optionally outside RTH.
+
+        The position is not updated until the order fills, the working order
+        is tracked in the state manager's open orders instead.
+
+        Args:
+            symbol: Trading symbol for order
+            qty: Order quantity (positive for long, negative for short)
+            limit_price: Limit price of the order
+            extended_hours: Allow the order to trade in the pre-market and post-market sessions
+            client_order_id: Optional deterministic ID so the order is submitted at most once
+
+        Returns:
+            Optional[str]: The order ID if submitted, None otherwise
+        """
+        try:
+            qty = self._size_for_drawdown(symbol, qty)
+            if not qty:
+                return None
+            if not self.risk.validate_order(symbol, qty, limit_price, extended_hours=extended_hours):
+                return None
+            if self._observing(f'limit order for {qty} {symbol} at ${limit_price}'):
+                return None
+
+            order_id = brokers.get_broker().submit_limit_order(
+                symbol=symbol,
+                qty=abs(qty),
+                side=OrderSide.BUY if qty > 0 else OrderSide.SELL,
+                limit_price=limit_price,
+                time_in_force=TimeInForce.DAY,
+                extended_hours=extended_hours,
+                client_order_id=client_order_id
+            )
+
+            with self.state.lock:
+                self.state.open_orders[order_id] = {'symbol': symbol, 'qty': qty, 'limit_price': limit_price}
+            return order_id
+        except Exception as e:
+            self.state.logger.error(f'Execution limit order failed {e}')
+            return None
+
+    def _observing(self, order: str) -> bool:
+        """Checks for observer mode, logging the order that passed risk checks but is not sent."""
+        if not observer.enabled():
+            return False
+        self.state.logger.info(f'Observer mode, not submitting {order}')
+        return True
+
     def _get_current_price(self, symbol: str) -> Optional[float]:
         """
             Retreives the latest price of an asset
         """
         try:
-            current_price = broker.get_historical_trade_data(
-                symbols=[symbol],
-                start_date=pytz.utc() - timedelta(seconds=30),
-                end_date=pytz.utc(),
-                limit=1
-            )[symbol].iloc[-1]['price']
-            return current_price
+            return brokers.get_broker().get_latest_price(symbol)
         except Exception as e:
             self.state.logger.error(f'Error in retreiving current price of {symbol}: {e}')
             return None
@@ -0,0 +1,276 @@
+import json
+from threading import Lock
+from helpers import cloud, logger, metrics, tenant
+from typing import Optional
+
+# Initialize logger
+logger = logger.Logger('subscription.py')
+
+# Symbols a market data stream may subscribe to per plan, None for unlimited
+PLAN_SYMBOL_LIMITS = {
+    'basic': 30,      # Alpaca Basic, IEX feed
+    'plus': None,     # Alpaca Algo Trader Plus, SIP feed
+    'ibkr': 100,      # IBKR default market data lines
+}
+
+# One minute bars per symbol in a regular session
+BARS_PER_SESSION = 390
+
+# Trading days used to project a monthly message count
+SESSIONS_PER_MONTH = 21
+
+# Estimated headlines per symbol per session when the news channel is streamed
+NEWS_PER_SESSION = 5
+
+# Projected SNS publishes per symbol per session, per channel
+MESSAGES_PER_SESSION = {
+    'bars': BARS_PER_SESSION,
+    'news': NEWS_PER_SESSION,
+}
+
+
+# Actions accepted on the control queue
+CONTROL_ACTIONS = ('subscribe', 'unsubscribe')
+
+
+class SubscriptionLimitExceeded(ValueError):
+    """Raised when the configured universe does not fit the data plan or message budget."""
+
+
+def projected_monthly_messages(symbols: int, channels: tuple = ('bars',)) -> int:
+    """
+    Project the SNS messages a month of streaming publishes.
+
+    Args:
+        symbols (int): Symbols in the universe.
+        channels (tuple, optional): Streamed channels, keys of MESSAGES_PER_SESSION. Defaults to bars only.
+
+    Returns:
+        int: The projected number of published messages.
+    """
+    return symbols * SESSIONS_PER_MONTH * sum(MESSAGES_PER_SESSION[channel] for channel in channels)
+
+
+def max_symbols(plan: Optional[str], channels: tuple = ('bars',), monthly_budget: Optional[int] = None) -> Optional[int]:
+    """
+    The largest universe allowed by the plan's symbol limit and the message budget.
+
+    Args:
+        plan (Optional[str]): Key of PLAN_SYMBOL_LIMITS, None when the plan is unknown.
+        channels (tuple, optional): Streamed channels. Defaults to bars only.
+        monthly_budget (Optional[int], optional): SNS messages allowed per month, None for no budget.
+
+    Returns:
+        Optional[int]: The limit, None when nothing limits the universe.
+
+    Raises:
+        ValueError: If the plan is not in PLAN_SYMBOL_LIMITS.
+    """
+    if plan is not None and plan not in PLAN_SYMBOL_LIMITS:
+        raise ValueError(f'Unknown data plan {plan}, expected one of {", ".join(PLAN_SYMBOL_LIMITS)}.')
+    limits = []
+    if plan is not None and PLAN_SYMBOL_LIMITS[plan] is not None:
+        limits.append(PLAN_SYMBOL_LIMITS[plan])
+    if monthly_budget is not None:
+        limits.append(monthly_budget // projected_monthly_messages(1, channels))
+    return min(limits) if limits else None
+
+
+def check_universe(
+    universe: list[str],
+    plan: Optional[str] = None,
+    channels: tuple = ('bars',),
+    monthly_budget: Optional[int] = None,
+    trim: bool = False
+) -> list[str]:
+    """
+    Validate the universe against the data plan and message budget before subscribing.
+
+    Args:
+        universe (list[str]): The configured symbols, in priority order.
+        plan (Optional[str], optional): Key of PLAN_SYMBOL_LIMITS, None when the plan is unknown.
+        channels (tuple, optional): Streamed channels. Defaults to bars only.
+        monthly_budget (Optional[int], optional): SNS messages allowed per month, None for no budget.
+        trim (bool, optional): Keep the first symbols that fit instead of refusing. Defaults to False.
+
+    Returns:
+        list[str]: The universe to subscribe, trimmed when over the limit and trim is set.
+
+    Raises:
+        SubscriptionLimitExceeded: If the universe is over the limit and trim is not set.
+    """
+    limit = max_symbols(plan, channels, monthly_budget)
+    metrics.set_gauge('projected_monthly_messages', projected_monthly_messages(len(universe), channels))
+    if limit is None or len(universe) <= limit:
+        return universe
+    reason = (
+        f'{len(universe)} symbols exceed the limit of {limit} for plan {plan} '
+        f'and budget {monthly_budget} messages per month'
+    )
+    if not trim:
+        raise SubscriptionLimitExceeded(f'{reason}, refusing to start.')
+    logger.warning(f'{reason}, dropping {", ".join(universe[limit:])}')
+    return universe[:limit]
+
+
+def check_configured_universe(universe: list[str], channels: tuple = ('bars',)) -> list[str]:
+    """
+    Run check_universe with the deployment's settings.
+
+    Environment Variables:
+        DATA_PLAN (str): Optional data plan, a key of PLAN_SYMBOL_LIMITS. Unset skips the symbol limit.
+        SNS_MONTHLY_MESSAGE_BUDGET (int): Optional SNS messages allowed per month.
+        UNIVERSE_OVERFLOW (str): 'refuse' (default) to refuse to start or 'trim' to drop
+            the symbols past the limit.
+
+    Raises:
+        SubscriptionLimitExceeded: If the universe is over the limit and UNIVERSE_OVERFLOW is not 'trim'.
+        ValueError: If DATA_PLAN is unknown.
+    """
+    budget = tenant.getenv('SNS_MONTHLY_MESSAGE_BUDGET')
+    return check_universe(
+        universe,
+        plan=tenant.getenv('DATA_PLAN') or None,
+        channels=channels,
+        monthly_budget=int(budget) if budget else None,
+        trim=tenant.getenv('UNIVERSE_OVERFLOW', 'refuse').lower() == 'trim'
+    )
+
+
+def parse_universe(text: str) -> list[str]:
+    """
+    Parse a universe from a JSON list or comma or newline separated symbols,
+    keeping the first occurrence of each symbol in order.
+
+    Args:
+        text (str): The universe, e.g. 'AAPL,MSFT' or '["AAPL", "MSFT"]'.
+
+    Returns:
+        list[str]: The symbols in priority order.
+    """
+    text = text.strip()
+    if text.startswith('['):
+        symbols = json.loads(text)
+    else:
+        symbols = text.replace('\n', ',').split(',')
+    return list(dict.fromkeys(symbol.strip() for symbol in symbols if symbol.strip()))
+
+
+def load_universe() -> list[str]:
+    """
+    Load the universe from the first configured source.
+
+    Environment Variables:
+        Each is read for the current TENANT, see tenant.getenv().
+        UNIVERSE_S3 (str): Optional s3://bucket/key of an object listing the symbols.
+        UNIVERSE_FILE (str): Optional path of a file listing the symbols.
+        UNIVERSE (str): Comma-separated symbols, used when neither is set.
+
+    Returns:
+        list[str]: The symbols in priority order.
+
+    Raises:
+        ValueError: If no source is configured or it lists no symbols.
+    """
+    if tenant.getenv('UNIVERSE_S3'):
+        source, text = tenant.getenv('UNIVERSE_S3'), cloud.read_s3_object(tenant.getenv('UNIVERSE_S3'))
+    elif tenant.getenv('UNIVERSE_FILE'):
+        source = tenant.getenv('UNIVERSE_FILE')
+        with open(source) as file:
+            text = file.read()
+    else:
+        source, text = 'UNIVERSE', tenant.getenv('UNIVERSE') or ''
+    universe = parse_universe(text)
+    if not universe:
+        raise ValueError(f'No symbols in the universe from {source}.')
+    logger.info(f'Loaded {len(universe)} symbols from {source}')
+    return universe
+
+
+def control_command(action: str, symbols: list[str], types: tuple = ('bars',)) -> str:
+    """
+    Build a control queue message that changes a running data service's subscriptions.
+
+    Args:
+        action (str): One of CONTROL_ACTIONS.
+        symbols (list[str]): The symbols to change.
+        types (tuple, optional): Streams to change, keys of MESSAGES_PER_SESSION. Defaults to bars only.
+
+    Returns:
+        str: The message body, e.g. '{"action": "subscribe", "symbols": ["MSFT"], "types": ["bars"]}'.
+    """
+    return json.dumps({'action': action, 'symbols': list(symbols), 'types': list(types)})
+
+
+def parse_control_command(body: str) -> dict:
+    """
+    Parse and validate a control queue message.
+
+    Args:
+        body (str): The message body in the format of control_command().
+
+    Returns:
+        dict: { 'action', 'symbols', 'types' }, types defaulting to bars only.
+
+    Raises:
+        ValueError: If the message is not valid JSON, the action or a type is unknown, or no symbols are given.
+    """
+    try:
+        command = json.loads(body)
+    except json.JSONDecodeError as e:
+        raise ValueError(f'Control command is not valid JSON: {e}') from e
+    if not isinstance(command, dict) or command.get('action') not in CONTROL_ACTIONS:
+        raise ValueError(f'Control command action must be one of {", ".join(CONTROL_ACTIONS)}.')
+    symbols = command.get('symbols')
+    if not isinstance(symbols, list) or not symbols or not all(isinstance(symbol, str) for symbol in symbols):
+        raise ValueError('Control command must list at least one symbol.')
+    types = command.get('types') or ['bars']
+    unknown = [stream for stream in types if stream not in MESSAGES_PER_SESSION]
+    if unknown:
+        raise ValueError(f'Unknown stream types {", ".join(map(str, unknown))}.')
+    return {'action': command['action'], 'symbols': symbols, 'types': tuple(types)}
+
+
+class Watchlist:
+    """The symbols a data service streams, changeable while it runs.
+
+    Attributes:
+        lock: Thread lock around the symbols
+    """
+
+    def __init__(self, symbols: list[str]):
+        """Initializes the watchlist.
+
+        Args:
+            symbols: The initial symbols in priority order
+        """
+        self._symbols = list(dict.fromkeys(symbols))
+        self.lock = Lock()
+
+    def add(self, symbols: list[str]) -> list[str]:
+        """Adds symbols to the end of the watchlist, returning those not already on it."""
+        with self.lock:
+            added = [symbol for symbol in dict.fromkeys(symbols) if symbol not in self._symbols]
+            self._symbols.extend(added)
+        metrics.set_gauge('watchlist_symbols', len(self._symbols))
+        return added
+
+    def remove(self, symbols: list[str]) -> list[str]:
+        """Removes symbols from the watchlist, returning those that were on it."""
+        with self.lock:
+            removed = [symbol for symbol in dict.fromkeys(symbols) if symbol in self._symbols]
+            self._symbols = [symbol for symbol in self._symbols if symbol not in removed]
+        metrics.set_gauge('watchlist_symbols', len(self._symbols))
+        return removed
+
+    def symbols(self) -> list[str]:
+        """Returns the symbols in priority order."""
+        with self.lock:
+            return list(self._symbols)
+
+    def __contains__(self, symbol: str) -> bool:
+        with self.lock:
+            return symbol in self._symbols
+
+    def __len__(self) -> int:
+        return len(self._symbols)
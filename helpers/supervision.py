@@ -0,0 +1,232 @@
+import os
+import json
+import itertools
+from collections import deque
+from dataclasses import dataclass, field
+from datetime import datetime, timedelta, timezone
+from threading import Lock
+from helpers import admin, logger, metrics
+from typing import Optional
+
+# Initialize logger
+logger = logger.Logger('supervision.py')
+
+# Journal entries kept in memory for the admin API
+JOURNAL_MEMORY = 1000
+
+
+def enabled() -> bool:
+    """
+    Check if the system runs in supervised mode, where strategies queue their
+    signals for an operator to approve or veto instead of trading them directly.
+
+    Returns:
+        bool: True if SUPERVISED_MODE is 'True'.
+    """
+    return os.getenv('SUPERVISED_MODE') == 'True'
+
+
+class Journal:
+    """Append-only record of automated and operator decisions.
+
+    Attributes:
+        path: Optional JSON lines file entries are appended to
+        entries: The most recent JOURNAL_MEMORY entries
+        lock: Thread lock around writes
+    """
+
+    def __init__(self, path: Optional[str] = None):
+        """Initializes an empty journal.
+
+        Args:
+            path: Optional JSON lines file entries are appended to
+        """
+        self.path = path
+        self.entries = deque(maxlen=JOURNAL_MEMORY)
+        self.lock = Lock()
+
+    def record(self, event: str, actor: str, **fields) -> dict:
+        """Journals a decision, actor is 'strategy', 'auto', or the operator's name."""
+        entry = {'time': datetime.now(timezone.utc).isoformat(), 'event': event, 'actor': actor, **fields}
+        with self.lock:
+            self.entries.append(entry)
+            if self.path:
+                try:
+                    with open(self.path, 'a') as file:
+                        file.write(json.dumps(entry, default=str) + '\n')
+                except Exception as e:
+                    logger.error(f'Error writing journal entry to {self.path}: {e}')
+        return entry
+
+
+@dataclass
+class TradeIdea:
+    """A signal waiting for an operator decision.
+
+    Attributes:
+        id: Queue assigned ID
+        order: The order the strategy wants to place
+        created_at: When the signal was queued
+        context: Strategy data needed to execute the order later
+        status: 'pending', 'approved', 'auto_approved', or 'vetoed'
+        notes: Operator annotations as { 'operator', 'note' }
+    """
+    id: str
+    order: dict
+    created_at: datetime
+    context: dict = field(default_factory=dict)
+    status: str = 'pending'
+    notes: list = field(default_factory=list)
+
+    def to_dict(self) -> dict:
+        """Returns the idea as JSON serializable data."""
+        return {
+            'id': self.id,
+            'order': self.order,
+            'created_at': self.created_at.isoformat(),
+            'status': self.status,
+            'notes': self.notes,
+        }
+
+
+class SignalQueue:
+    """Holds a strategy's signals until an operator approves them or the
+    auto-approve timeout passes. Operators act through the admin API while the
+    strategy thread releases approved ideas and executes them itself.
+
+    Attributes:
+        strategy: Name of the supervised strategy
+        auto_approve_seconds: Seconds after which a pending idea is approved, 0 to wait for an operator
+        journal: Journal of every decision
+        ideas: { id: TradeIdea } of ideas not yet released or vetoed
+        lock: Thread lock for updates from the strategy and admin threads
+    """
+
+    def __init__(self, strategy: str, auto_approve_seconds: float = 60.0, journal: Optional[Journal] = None):
+        """Initializes an empty queue.
+
+        Args:
+            strategy: Name of the supervised strategy
+            auto_approve_seconds: Seconds after which a pending idea is approved, 0 to wait for an operator
+            journal: Journal of every decision, kept in memory only by default
+        """
+        self.strategy = strategy
+        self.auto_approve_seconds = auto_approve_seconds
+        self.journal = journal or Journal()
+        self.ideas = {}
+        self.lock = Lock()
+        self._ids = itertools.count(1)
+
+    def submit(self, order: dict, context: Optional[dict] = None, now: Optional[datetime] = None) -> str:
+        """Queues a signal for review, returning its ID."""
+        with self.lock:
+            idea = TradeIdea(str(next(self._ids)), order, now or datetime.now(timezone.utc), context or {})
+            self.ideas[idea.id] = idea
+        self.journal.record('signal', 'strategy', strategy=self.strategy, idea=idea.id, order=order)
+        metrics.increment('supervised_signals', strategy=self.strategy)
+        logger.info(f'{self.strategy} queued idea {idea.id} for review: {order}')
+        return idea.id
+
+    def _pending(self, idea_id: str) -> TradeIdea:
+        idea = self.ideas.get(str(idea_id))
+        if idea is None or idea.status != 'pending':
+            raise ValueError(f'No pending idea {idea_id} for strategy {self.strategy}.')
+        return idea
+
+    def annotate(self, idea_id: str, operator: str, note: str) -> dict:
+        """Attaches an operator note to a pending idea."""
+        with self.lock:
+            idea = self._pending(idea_id)
+            idea.notes.append({'operator': operator, 'note': note})
+        self.journal.record('annotated', operator, strategy=self.strategy, idea=idea.id, note=note)
+        return idea.to_dict()
+
+    def approve(self, idea_id: str, operator: str) -> dict:
+        """Approves a pending idea for the strategy to execute."""
+        with self.lock:
+            idea = self._pending(idea_id)
+            idea.status = 'approved'
+        self.journal.record('approved', operator, strategy=self.strategy, idea=idea.id)
+        return idea.to_dict()
+
+    def veto(self, idea_id: str, operator: str, reason: str = '') -> dict:
+        """Drops a pending idea without trading it."""
+        with self.lock:
+            idea = self._pending(idea_id)
+            idea.status = 'vetoed'
+            del self.ideas[idea.id]
+        self.journal.record('vetoed', operator, strategy=self.strategy, idea=idea.id, reason=reason)
+        metrics.increment('supervised_vetoes', strategy=self.strategy)
+        return idea.to_dict()
+
+    def release(self, now: Optional[datetime] = None) -> list[TradeIdea]:
+        """
+        Removes and returns the ideas ready to execute, approving pending ideas
+        past the auto-approve timeout first. Called from the strategy thread.
+        """
+        now = now or datetime.now(timezone.utc)
+        timed_out = []
+        with self.lock:
+            for idea in self.ideas.values():
+                if (
+                    idea.status == 'pending' and self.auto_approve_seconds
+                    and now - idea.created_at >= timedelta(seconds=self.auto_approve_seconds)
+                ):
+                    idea.status = 'auto_approved'
+                    timed_out.append(idea)
+            released = [idea for idea in self.ideas.values() if idea.status != 'pending']
+            for idea in released:
+                del self.ideas[idea.id]
+        for idea in timed_out:
+            self.journal.record('auto_approved', 'auto', strategy=self.strategy, idea=idea.id)
+        return released
+
+    def record_execution(self, idea: TradeIdea, placed: bool) -> None:
+        """Journals the outcome of executing a released idea."""
+        self.journal.record('executed', 'strategy', strategy=self.strategy, idea=idea.id, placed=placed)
+
+    def pending(self) -> list[dict]:
+        """Returns the ideas awaiting a decision."""
+        with self.lock:
+            return [idea.to_dict() for idea in self.ideas.values() if idea.status == 'pending']
+
+
+# Queues of the strategies running in this process { strategy: SignalQueue }
+queues = {}
+
+
+def register_queue(queue: SignalQueue) -> SignalQueue:
+    """
+    Make a queue visible to the admin API.
+    """
+    queues[queue.strategy] = queue
+    return queue
+
+
+def _get_registered_queue(strategy: Optional[str]) -> SignalQueue:
+    if strategy not in queues:
+        raise ValueError(f'No signal queue for strategy {strategy}.')
+    return queues[strategy]
+
+
+admin.register_route('/ideas', lambda query, body: {
+    name: queue.pending() for name, queue in queues.items()
+})
+admin.register_route('/ideas/journal', lambda query, body: {
+    'entries': list(_get_registered_queue(query.get('strategy')).journal.entries)
+})
+admin.register_route(
+    '/ideas/annotate',
+    lambda query, body, identity: _get_registered_queue(body.get('strategy')).annotate(body.get('id'), identity, body.get('note', '')),
+    method='POST'
+)
+admin.register_route(
+    '/ideas/approve',
+    lambda query, body, identity: _get_registered_queue(body.get('strategy')).approve(body.get('id'), identity),
+    method='POST'
+)
+admin.register_route(
+    '/ideas/veto',
+    lambda query, body, identity: _get_registered_queue(body.get('strategy')).veto(body.get('id'), identity, body.get('reason', '')),
+    method='POST'
+)
@@ -0,0 +1,73 @@
+import os
+import re
+from typing import Optional
+
+# Tenant used when TENANT is not set, its names and IDs are left unprefixed
+# so single book deployments keep their existing resources and state
+DEFAULT_TENANT = 'default'
+TENANT_PATTERN = re.compile(r'^[a-z][a-z0-9-]{0,31}$')
+
+
+def get_tenant() -> str:
+    """
+    Returns the tenant, an independent book (user or capital pool) sharing
+    infrastructure with others, from the TENANT environment variable.
+
+    Raises:
+        ValueError: If TENANT is not lowercase alphanumeric with dashes.
+    """
+    name = os.getenv('TENANT', DEFAULT_TENANT)
+    if not TENANT_PATTERN.match(name):
+        raise ValueError(f'Invalid tenant {name}, use lowercase letters, digits, and dashes.')
+    return name
+
+
+def is_default() -> bool:
+    """
+    Checks if the process runs as the default tenant.
+    """
+    return get_tenant() == DEFAULT_TENANT
+
+
+def getenv(name: str, default: Optional[str] = None) -> Optional[str]:
+    """
+    Read a configuration value for the current tenant.
+    A tenant scoped variable, e.g. POOL_B__BROKER_API_KEY for tenant pool-b,
+    takes precedence over the shared NAME variable.
+
+    Args:
+        name (str): The shared variable name.
+        default (Optional[str], optional): Value when neither is set. Defaults to None.
+
+    Returns:
+        Optional[str]: The configured value.
+    """
+    if not is_default():
+        scoped = os.getenv(f"{get_tenant().upper().replace('-', '_')}__{name}")
+        if scoped is not None:
+            return scoped
+    return os.getenv(name, default)
+
+
+def resource_name(base: str, separator: str = '-') -> str:
+    """
+    Namespace a shared resource name (queue, topic, service, key prefix) by tenant.
+
+    Args:
+        base (str): The resource name used by the default tenant.
+        separator (str, optional): Joins the name and tenant. Defaults to '-'.
+
+    Returns:
+        str: The base name for the default tenant, otherwise the base name suffixed with the tenant.
+    """
+    return base if is_default() else f'{base}{separator}{get_tenant()}'
+
+
+def scoped_path(path: Optional[str]) -> Optional[str]:
+    """
+    Namespace a state file path by tenant, e.g. state.json becomes state-pool-b.json.
+    """
+    if not path or is_default():
+        return path
+    root, extension = os.path.splitext(path)
+    return f'{resource_name(root)}{extension}'
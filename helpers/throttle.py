@@ -0,0 +1,66 @@
+from datetime import date, datetime, timedelta
+from threading import Lock
+from helpers import logger, metrics, sessions
+
+# Initialize logger
+logger = logger.Logger('throttle.py')
+
+
+class TradeThrottle:
+    """Limits how often a strategy re-enters the same symbol or pair.
+
+    After max_round_trips round trips in a session day, or for
+    cooldown_seconds after a stop-out, new entries in the symbol are blocked.
+    Exits are never blocked. Times come from the caller, bar time in the
+    strategies, so backtests and live trading agree.
+
+    Attributes:
+        max_round_trips: Round trips per symbol per session day, 0 for no limit
+        stop_out_pct: Loss in percent of the entry notional at which a round trip is a stop-out
+        cooldown_seconds: Seconds entries are blocked after a stop-out, 0 for no cooldown
+        round_trips: { (symbol, session day): round trips }
+        cooldowns: { symbol: time entries resume }
+        lock: Thread lock around updates
+    """
+
+    def __init__(self, max_round_trips: int = 0, stop_out_pct: float = 1.0, cooldown_seconds: float = 0):
+        """Initializes the throttle with no round trips recorded.
+
+        Args:
+            max_round_trips: Round trips per symbol per session day, 0 for no limit
+            stop_out_pct: Loss in percent of the entry notional at which a round trip is a stop-out
+            cooldown_seconds: Seconds entries are blocked after a stop-out, 0 for no cooldown
+        """
+        self.max_round_trips = max_round_trips
+        self.stop_out_pct = stop_out_pct
+        self.cooldown_seconds = cooldown_seconds
+        self.round_trips = {}
+        self.cooldowns = {}
+        self.lock = Lock()
+
+    def _session_day(self, at: datetime) -> date:
+        return at.astimezone(sessions.EASTERN).date()
+
+    def record_round_trip(self, symbol: str, pnl: float, entry_notional: float, at: datetime) -> None:
+        """Records a closed position, starting a cooldown if it was a stop-out."""
+        with self.lock:
+            key = (symbol, self._session_day(at))
+            self.round_trips[key] = self.round_trips.get(key, 0) + 1
+            stopped_out = pnl < 0 and entry_notional and -pnl / abs(entry_notional) * 100 >= self.stop_out_pct
+            if stopped_out and self.cooldown_seconds:
+                self.cooldowns[symbol] = at + timedelta(seconds=self.cooldown_seconds)
+                logger.warning(f'{symbol} stopped out ({pnl:.2f}), entries paused until {self.cooldowns[symbol]}')
+                metrics.increment('stop_out_cooldowns', symbol=symbol)
+
+    def allows(self, symbol: str, at: datetime) -> bool:
+        """Checks if a new entry in the symbol is allowed at a time."""
+        with self.lock:
+            resume_at = self.cooldowns.get(symbol)
+            if resume_at is not None and at < resume_at:
+                logger.info(f'{symbol} cooling down after a stop-out until {resume_at}, skipping entry')
+                return False
+            round_trips = self.round_trips.get((symbol, self._session_day(at)), 0)
+            if self.max_round_trips and round_trips >= self.max_round_trips:
+                logger.info(f'{symbol} reached {round_trips} round trips today, skipping entry')
+                return False
+            return True
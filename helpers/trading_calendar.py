@@ -0,0 +1,156 @@
+import os
+import json
+from datetime import date, datetime, timedelta
+from threading import Lock
+from helpers import broker, logger, sessions
+from alpaca.trading.requests import GetCalendarRequest
+from typing import Callable, Optional
+
+# Initialize logger
+logger = logger.Logger('trading_calendar.py')
+
+# Days to look ahead for the next trading day, covers the longest exchange closure
+MAX_CLOSED_DAYS = 10
+
+
+def _as_eastern(value: datetime) -> datetime:
+    """
+    Alpaca reports session times as naive exchange times.
+    """
+    return value.replace(tzinfo=sessions.EASTERN) if value.tzinfo is None else value.astimezone(sessions.EASTERN)
+
+
+def fetch_sessions(start: date, end: date) -> dict[date, tuple[datetime, datetime]]:
+    """
+    Retrieve the regular session times of every trading day in a range.
+
+    Args:
+        start (date): The first day.
+        end (date): The last day.
+
+    Returns:
+        dict[date, tuple[datetime, datetime]]: { trading day: (open, close) } in exchange time,
+        days without a session are absent.
+
+    Raises:
+        Exception: If the calendar cannot be retrieved.
+    """
+    trading_client = broker.get_broker_client('trading')
+    try:
+        days = trading_client.get_calendar(GetCalendarRequest(start=start, end=end))
+        return {day.date: (_as_eastern(day.open), _as_eastern(day.close)) for day in days}
+    except Exception as e:
+        raise Exception(f"Failed to get trading calendar from {start} to {end}: {e}") from e
+
+
+class TradingCalendar:
+    """Exchange trading days and session times, including holidays and early closes.
+
+    Sessions are fetched a calendar year at a time and kept for the life of
+    the process, optionally persisted so restarts do not refetch.
+
+    Attributes:
+        fetch: Returns { trading day: (open, close) } for a date range
+        cache_file: Optional JSON file the sessions are persisted to
+        years: Years fetched so far
+        days: { trading day: (open, close) } for the fetched years
+        lock: Thread lock around fetches
+    """
+
+    def __init__(
+        self,
+        fetch: Callable[[date, date], dict] = fetch_sessions,
+        cache_file: Optional[str] = None
+    ):
+        """Initializes the calendar, loading cache_file if it exists.
+
+        Args:
+            fetch: Returns { trading day: (open, close) } for a date range
+            cache_file: Optional JSON file the sessions are persisted to
+        """
+        self.fetch = fetch
+        self.cache_file = cache_file
+        self.years = set()
+        self.days = {}
+        self.lock = Lock()
+        if cache_file and os.path.exists(cache_file):
+            try:
+                with open(cache_file) as file:
+                    cached = json.load(file)
+                self.years = set(cached['years'])
+                self.days = {
+                    date.fromisoformat(day): (datetime.fromisoformat(times[0]), datetime.fromisoformat(times[1]))
+                    for day, times in cached['days'].items()
+                }
+            except Exception as e:
+                logger.error(f'Error loading trading calendar from {cache_file}: {e}')
+
+    def _ensure_year(self, year: int) -> None:
+        """Fetches the sessions of a year unless they are already known."""
+        with self.lock:
+            if year in self.years:
+                return
+            self.days.update(self.fetch(date(year, 1, 1), date(year, 12, 31)))
+            self.years.add(year)
+            logger.info(f'Loaded trading calendar for {year}')
+        self._save()
+
+    def _save(self) -> None:
+        """Persists the sessions to cache_file if one is configured."""
+        if not self.cache_file:
+            return
+        try:
+            with self.lock, open(self.cache_file, 'w') as file:
+                json.dump({
+                    'years': sorted(self.years),
+                    'days': {day.isoformat(): [times[0].isoformat(), times[1].isoformat()] for day, times in self.days.items()},
+                }, file)
+        except Exception as e:
+            logger.error(f'Error persisting trading calendar to {self.cache_file}: {e}')
+
+    def session_times(self, day: date) -> Optional[tuple[datetime, datetime]]:
+        """Returns the regular session's (open, close) in exchange time, None on non-trading days."""
+        self._ensure_year(day.year)
+        return self.days.get(day)
+
+    def is_trading_day(self, day: date) -> bool:
+        """Checks if the exchange holds a regular session on a day."""
+        return self.session_times(day) is not None
+
+    def is_early_close(self, day: date) -> bool:
+        """Checks if a trading day's regular session closes before the usual close."""
+        times = self.session_times(day)
+        return times is not None and times[1].time() < sessions.REGULAR_CLOSE
+
+    def next_trading_day(self, day: date) -> date:
+        """Returns the first trading day after a day.
+
+        Raises:
+            ValueError: If no trading day follows within MAX_CLOSED_DAYS.
+        """
+        for offset in range(1, MAX_CLOSED_DAYS + 1):
+            candidate = day + timedelta(days=offset)
+            if self.is_trading_day(candidate):
+                return candidate
+        raise ValueError(f'No trading day within {MAX_CLOSED_DAYS} days after {day}.')
+
+    def flatten_time(self, day: date, minutes_before_close: float = 15) -> Optional[datetime]:
+        """Returns when end of day flattening should start, honoring early closes, None on non-trading days."""
+        times = self.session_times(day)
+        return times[1] - timedelta(minutes=minutes_before_close) if times else None
+
+
+# Initialize a placeholder for the shared calendar
+trading_calendar = None
+
+
+def get_calendar() -> TradingCalendar:
+    """
+    Returns the shared trading calendar.
+    Initializes it if it hasn't been initialized yet, persisted to
+    TRADING_CALENDAR_CACHE_FILE when set.
+    """
+    global trading_calendar
+    if trading_calendar is None:
+        trading_calendar = TradingCalendar(cache_file=os.getenv('TRADING_CALENDAR_CACHE_FILE'))
+    return trading_calendar
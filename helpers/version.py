@@ -0,0 +1,83 @@
+import os
+import hashlib
+import subprocess
+from dotenv import dotenv_values
+from importlib import metadata
+from helpers import observer, tenant
+
+# Build info is resolved once per process
+build_info = None
+
+
+def _git_commit() -> str:
+    """
+    Resolve the git commit, preferring the value baked in at image build time.
+    """
+    commit = os.getenv('GIT_COMMIT')
+    if commit:
+        return commit
+    try:
+        return subprocess.check_output(
+            ['git', 'rev-parse', 'HEAD'],
+            cwd=os.path.dirname(os.path.abspath(__file__)),
+            stderr=subprocess.DEVNULL,
+            text=True
+        ).strip()
+    except Exception:
+        return 'unknown'
+
+
+def _package_version() -> str:
+    """
+    Resolve the installed package version from setup.py.
+    """
+    try:
+        return metadata.version('Nexus')
+    except metadata.PackageNotFoundError:
+        return 'unknown'
+
+
+def config_hash(env_file: str = '.env') -> str:
+    """
+    Hash the service configuration so two runs can be compared without
+    exposing any of the values (the env file holds secrets).
+
+    Args:
+        env_file (str, optional): The decrypted env file. Defaults to ".env".
+
+    Returns:
+        str: The first 12 hex characters of a SHA-256 over the sorted config.
+    """
+    config = dict(dotenv_values(env_file)) if os.path.exists(env_file) else {}
+    for name in ('SERVICE', 'ENV', 'REGION', 'ENV_FILE', 'TENANT'):
+        config[name] = os.getenv(name)
+    serialized = '\n'.join(f'{key}={config[key]}' for key in sorted(config))
+    return hashlib.sha256(serialized.encode()).hexdigest()[:12]
+
+
+def get_build_info() -> dict:
+    """
+    Return which code and configuration the running process was built from.
+
+    Returns:
+        dict: A dictionary containing:
+        - 'version': The package version.
+        - 'commit': The git commit SHA.
+        - 'build_time': The image build time (BUILD_TIME), 'unknown' if not set.
+        - 'config_hash': A hash of the loaded configuration.
+        - 'service': The service being run.
+        - 'tenant': The book the service runs for.
+        - 'observer_mode': Whether the execution layer is disabled.
+    """
+    global build_info
+    if build_info is None:
+        build_info = {
+            'version': _package_version(),
+            'commit': _git_commit(),
+            'build_time': os.getenv('BUILD_TIME', 'unknown'),
+            'config_hash': config_hash(),
+            'service': os.getenv('SERVICE'),
+            'tenant': tenant.get_tenant(),
+            'observer_mode': observer.enabled(),
+        }
+    return build_info
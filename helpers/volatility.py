@@ -0,0 +1,215 @@
+import os
+import json
+from datetime import date, datetime, timedelta, timezone
+from threading import Lock
+from helpers import broker, logger, metrics, options
+from typing import Optional
+
+# Initialize logger
+logger = logger.Logger('volatility.py')
+
+# At-the-money IV is sampled from the expiration nearest a month out
+TARGET_DAYS = 30
+MIN_DAYS = 20
+MAX_DAYS = 45
+# Strikes requested around the spot price
+STRIKE_BAND = 0.1
+# One year of daily samples
+LOOKBACK_DAYS = 252
+
+
+def atm_implied_volatility(chain: dict, spot: float, today: date) -> Optional[float]:
+    """
+    At-the-money implied volatility of an option chain: the average IV of the
+    call and put at the strike nearest spot, in the expiration nearest
+    TARGET_DAYS out.
+
+    Args:
+        chain (dict): Chain as returned by options.get_option_chain().
+        spot (float): Price of the underlying.
+        today (date): Date the chain was observed.
+
+    Returns:
+        Optional[float]: The annualized implied volatility, None if no contract has an IV.
+    """
+    contracts = []
+    for symbol, snapshot in chain.items():
+        if snapshot.get('implied_volatility') is None:
+            continue
+        contracts.append({**options.parse_option_symbol(symbol), 'iv': snapshot['implied_volatility']})
+    if not contracts:
+        return None
+    expiration = min(
+        {contract['expiration'] for contract in contracts},
+        key=lambda expiration: abs((expiration - today).days - TARGET_DAYS)
+    )
+    expiring = [contract for contract in contracts if contract['expiration'] == expiration]
+    strike = min({contract['strike'] for contract in expiring}, key=lambda strike: abs(strike - spot))
+    ivs = [contract['iv'] for contract in expiring if contract['strike'] == strike]
+    return sum(ivs) / len(ivs)
+
+
+def get_atm_implied_volatility(underlying: str, today: Optional[date] = None) -> Optional[float]:
+    """
+    Sample the current at-the-money implied volatility of an underlying.
+
+    Args:
+        underlying (str): The underlying symbol.
+        today (Optional[date], optional): The trading date. Defaults to today in UTC.
+
+    Returns:
+        Optional[float]: The annualized implied volatility, None if the chain has no IVs.
+    """
+    today = today or datetime.now(timezone.utc).date()
+    spot = broker.get_latest_trade(underlying).price
+    chain = options.get_option_chain(
+        underlying,
+        strike_price_gte=round(spot * (1 - STRIKE_BAND), 2),
+        strike_price_lte=round(spot * (1 + STRIKE_BAND), 2),
+        expiration_date_gte=today + timedelta(days=MIN_DAYS),
+        expiration_date_lte=today + timedelta(days=MAX_DAYS)
+    )
+    return atm_implied_volatility(chain, spot, today)
+
+
+def iv_rank(history: list[float], current: float) -> Optional[float]:
+    """
+    Where current IV sits between the low and high of its history, 0 to 100.
+    """
+    if not history:
+        return None
+    low, high = min(history), max(history)
+    if high == low:
+        return 50.0
+    return min(max((current - low) / (high - low) * 100, 0.0), 100.0)
+
+
+def iv_percentile(history: list[float], current: float) -> Optional[float]:
+    """
+    Percent of days in the history with IV below current, 0 to 100.
+    """
+    if not history:
+        return None
+    return sum(1 for iv in history if iv < current) / len(history) * 100
+
+
+class IVFeatures:
+    """Daily at-the-money IV history per underlying, with IV rank and percentile.
+
+    Options data only gives the current IV, so the history is built by
+    sampling once per trading day and persisted so it survives restarts.
+
+    Attributes:
+        lookback_days: Number of daily samples kept per underlying
+        state_file: Optional JSON file the history is persisted to
+        history: { underlying: { ISO date: IV } }
+        lock: Thread lock for concurrent refreshes
+    """
+
+    def __init__(self, lookback_days: int = LOOKBACK_DAYS, state_file: Optional[str] = None):
+        """Initializes the history, loading it from state_file if it exists.
+
+        Args:
+            lookback_days: Number of daily samples kept per underlying
+            state_file: Optional JSON file the history is persisted to
+        """
+        self.lookback_days = lookback_days
+        self.state_file = state_file
+        self.history = {}
+        self.lock = Lock()
+        if state_file and os.path.exists(state_file):
+            with open(state_file) as file:
+                self.history = json.load(file)
+
+    def record(self, underlying: str, day: date, iv: float) -> None:
+        """Stores the IV sample of a day, keeping the most recent lookback_days samples."""
+        with self.lock:
+            samples = self.history.setdefault(underlying, {})
+            samples[day.isoformat()] = iv
+            for stale in sorted(samples)[:-self.lookback_days]:
+                del samples[stale]
+
+    def refresh(self, underlying: str, today: Optional[date] = None) -> None:
+        """Samples today's IV of an underlying unless it was already sampled.
+
+        Raises:
+            Exception: If the IV cannot be retrieved.
+        """
+        today = today or datetime.now(timezone.utc).date()
+        if today.isoformat() in self.history.get(underlying, {}):
+            return
+        iv = get_atm_implied_volatility(underlying, today)
+        if iv is None:
+            logger.warning(f'No implied volatility available for {underlying}')
+            return
+        self.record(underlying, today, iv)
+        self.save()
+
+    def features(self, underlying: str) -> dict:
+        """Returns the latest IV with its rank and percentile over the history.
+
+        Returns:
+            dict: { 'iv', 'iv_rank', 'iv_percentile', 'observations' }, values
+            are None when the underlying has no samples.
+        """
+        with self.lock:
+            samples = [self.history[underlying][day] for day in sorted(self.history.get(underlying, {}))]
+        if not samples:
+            return {'iv': None, 'iv_rank': None, 'iv_percentile': None, 'observations': 0}
+        current = samples[-1]
+        return {
+            'iv': current,
+            'iv_rank': iv_rank(samples, current),
+            'iv_percentile': iv_percentile(samples, current),
+            'observations': len(samples),
+        }
+
+    def save(self) -> None:
+        """Persists the history to state_file if one is configured."""
+        if not self.state_file:
+            return
+        try:
+            with self.lock, open(self.state_file, 'w') as file:
+                json.dump(self.history, file)
+        except Exception as e:
+            logger.error(f'Error persisting IV history to {self.state_file}: {e}')
+
+
+class IVFilter:
+    """Strategy filter that blocks new entries while implied volatility is exploding.
+
+    Attributes:
+        features: IVFeatures the rank is read from
+        max_iv_rank: IV rank above which entries are blocked
+        min_observations: Samples required before the filter blocks anything
+    """
+
+    def __init__(self, features: IVFeatures, max_iv_rank: float = 80.0, min_observations: int = 20):
+        """Initializes the filter.
+
+        Args:
+            features: IVFeatures the rank is read from
+            max_iv_rank: IV rank above which entries are blocked
+            min_observations: Samples required before the filter blocks anything
+        """
+        self.features = features
+        self.max_iv_rank = max_iv_rank
+        self.min_observations = min_observations
+
+    def allows(self, symbol: str) -> bool:
+        """Checks if a new position may be opened in a symbol.
+
+        Missing or failed IV data never blocks trading, it is logged instead.
+        """
+        try:
+            self.features.refresh(symbol)
+        except Exception as e:
+            logger.error(f'Error refreshing implied volatility of {symbol}: {e}')
+        features = self.features.features(symbol)
+        if features['observations'] < self.min_observations:
+            return True
+        metrics.set_gauge('iv_rank', features['iv_rank'], symbol=symbol)
+        if features['iv_rank'] > self.max_iv_rank:
+            logger.info(f"{symbol} IV rank {features['iv_rank']:.0f} above {self.max_iv_rank:.0f}, blocking entry")
+            return False
+        return True
@@ -0,0 +1,151 @@
+from dataclasses import dataclass
+from datetime import datetime, timedelta, timezone
+from helpers import logger, metrics, tenant
+from helpers.domain import Order
+from typing import Optional
+
+# Initialize logger
+logger = logger.Logger('wash.py')
+
+# Broker statuses of orders that can still fill
+OPEN_STATUSES = {
+    'new', 'accepted', 'pending_new', 'partially_filled', 'held',
+    'accepted_for_bidding', 'pending_replace',
+    # ib_insync order statuses
+    'PendingSubmit', 'ApiPending', 'PreSubmitted', 'Submitted',
+}
+
+
+class WashTradeRejection(Exception):
+    """Raised when an order could cross with an opposing order in the same symbol.
+
+    Attributes:
+        symbol: Symbol of the rejected order
+        reason: The opposing order the new one conflicts with
+    """
+
+    def __init__(self, symbol: str, reason: str):
+        super().__init__(f'Order for {symbol} blocked: {reason}')
+        self.symbol = symbol
+        self.reason = reason
+
+
+@dataclass
+class WashTradeConfig:
+    """Self-cross prevention settings, shared by every strategy on the account.
+
+    Attributes:
+        enabled: Whether orders are checked at all
+        window_seconds: Opposite side orders that filled within this many seconds also block
+    """
+    enabled: bool = False
+    window_seconds: float = 0.0
+
+
+def load_config() -> WashTradeConfig:
+    """
+    Load the self-cross guard settings for the current tenant.
+
+    Environment Variables:
+        WASH_TRADE_GUARD (str): 'true' to block orders crossing an opposing order. Defaults to false.
+        WASH_TRADE_WINDOW_SECONDS (float): Seconds after an opposite side fill during which
+            new orders in the symbol are blocked. Defaults to 0.
+
+    Returns:
+        WashTradeConfig: The settings.
+    """
+    return WashTradeConfig(
+        enabled=tenant.getenv('WASH_TRADE_GUARD', 'false').lower() == 'true',
+        window_seconds=float(tenant.getenv('WASH_TRADE_WINDOW_SECONDS', 0))
+    )
+
+
+# Initialize a placeholder for the loaded settings
+config = None
+
+
+def get_config() -> WashTradeConfig:
+    """
+    Returns the self-cross guard settings.
+    Loads them if they haven't been loaded yet.
+    """
+    global config
+    if config is None:
+        config = load_config()
+    return config
+
+
+def find_conflict(
+    symbol: str,
+    side: str,
+    orders: list[Order],
+    window_seconds: float = 0.0,
+    now: Optional[datetime] = None
+) -> Optional[str]:
+    """
+    Find an opposite side order in the same symbol a new order could cross with.
+
+    Args:
+        symbol (str): The symbol of the new order.
+        side (str): The side of the new order, 'buy' or 'sell'.
+        orders (list[Order]): Recent and open orders on the account.
+        window_seconds (float, optional): Opposite side orders with fills submitted within
+                                          this many seconds conflict too. Defaults to 0.
+        now (Optional[datetime], optional): The current time. Defaults to now in UTC.
+
+    Returns:
+        Optional[str]: The conflict, None if the order may be submitted.
+    """
+    now = now or datetime.now(timezone.utc)
+    for order in orders:
+        if order.symbol != symbol or order.side.value == side:
+            continue
+        if order.status in OPEN_STATUSES:
+            return f'opposing {order.side.value} order {order.id} is open'
+        if (
+            window_seconds and order.filled_qty and order.submitted_at is not None
+            and now - order.submitted_at < timedelta(seconds=window_seconds)
+        ):
+            return f'opposing {order.side.value} order {order.id} filled within {window_seconds:g}s'
+    return None
+
+
+def reduces_position(side: str, qty: Optional[float], position_qty: float) -> bool:
+    """
+    Check if an order only reduces or closes out a position, never opening or
+    flipping one. Such orders are exempt from the self-cross check, so exits
+    and liquidations always go through and a strategy is never stuck in a position.
+
+    Args:
+        side (str): The side of the new order, 'buy' or 'sell'.
+        qty (Optional[float]): The unsigned order quantity, None when it is not known.
+        position_qty (float): The current position in the symbol, negative for shorts.
+
+    Returns:
+        bool: True for a sell of at most a long position or a buy of at most a short one.
+    """
+    if qty is None:
+        return False
+    signed_qty = qty if side == 'buy' else -qty
+    return position_qty * signed_qty < 0 and abs(qty) <= abs(position_qty) + 1e-9
+
+
+def check_order(symbol: str, side: str, orders: list[Order], now: Optional[datetime] = None) -> None:
+    """
+    Run the self-cross check the order layer calls before submission.
+
+    Args:
+        symbol (str): The symbol of the new order.
+        side (str): The side of the new order, 'buy' or 'sell'.
+        orders (list[Order]): Recent and open orders on the account.
+        now (Optional[datetime], optional): The current time. Defaults to now in UTC.
+
+    Raises:
+        WashTradeRejection: If the order could cross an opposing order.
+    """
+    reason = find_conflict(symbol, side, orders, get_config().window_seconds, now)
+    if reason is None:
+        return
+    logger.warning(f'Blocked {side} {symbol}: {reason}')
+    metrics.increment('wash_trade_blocks', symbol=symbol)
+    raise WashTradeRejection(symbol, reason)
@@ -0,0 +1,276 @@
+import sys
+import time
+from array import array
+from collections import OrderedDict
+from threading import Lock
+from helpers import metrics
+from typing import Optional
+
+
+class RollingWindow:
+    """Fixed capacity ring buffer of floats.
+
+    Storage is allocated once at construction, so the memory held by a window
+    never grows no matter how many values are appended.
+
+    Attributes:
+        capacity: Maximum number of values retained
+        last_update: Epoch seconds of the most recent append
+    """
+
+    def __init__(self, capacity: int):
+        """Allocates the ring buffer.
+
+        Args:
+            capacity: Maximum number of values retained
+        """
+        if capacity <= 0:
+            raise ValueError('Window capacity must be positive.')
+        self.capacity = capacity
+        self.last_update = time.time()
+        self._buffer = array('d', bytes(8 * capacity))
+        self._start = 0
+        self._size = 0
+
+    def append(self, value: float) -> None:
+        """Adds a value, overwriting the oldest one once the window is full."""
+        end = (self._start + self._size) % self.capacity
+        self._buffer[end] = value
+        if self._size < self.capacity:
+            self._size += 1
+        else:
+            self._start = (self._start + 1) % self.capacity
+        self.last_update = time.time()
+
+    def values(self) -> list[float]:
+        """Returns the retained values ordered oldest to newest."""
+        return [self._buffer[(self._start + i) % self.capacity] for i in range(self._size)]
+
+    def is_full(self) -> bool:
+        """Checks if the window holds capacity values."""
+        return self._size == self.capacity
+
+    def nbytes(self) -> int:
+        """Returns the memory held by the window including its buffer."""
+        return sys.getsizeof(self) + sys.getsizeof(self._buffer)
+
+    def __len__(self) -> int:
+        return self._size
+
+
+class WindowStore:
+    """Per-symbol rolling windows with a memory bound and eviction policy.
+
+    Symbols are kept in least-recently-updated order. Symbols that have not
+    been updated for idle_seconds are evicted by evict_idle(), and when more
+    than max_symbols are tracked the least recently updated one is evicted
+    immediately. Total memory and symbol count are published as gauges.
+
+    Attributes:
+        capacity: Values retained per symbol
+        max_symbols: Maximum number of symbols tracked at once
+        idle_seconds: Age after which an untouched symbol is evicted
+        name: Label distinguishing this store in metrics
+    """
+
+    def __init__(
+        self,
+        capacity: int,
+        max_symbols: int = 500,
+        idle_seconds: float = 60 * 60,
+        name: str = 'default'
+    ):
+        """Initializes an empty store.
+
+        Args:
+            capacity: Values retained per symbol
+            max_symbols: Maximum number of symbols tracked at once
+            idle_seconds: Age after which an untouched symbol is evicted
+            name: Label distinguishing this store in metrics
+        """
+        self.capacity = capacity
+        self.max_symbols = max_symbols
+        self.idle_seconds = idle_seconds
+        self.name = name
+        self._windows = OrderedDict()  # { symbol: RollingWindow } least recent first
+        self._lock = Lock()
+
+    def append(self, symbol: str, value: float) -> RollingWindow:
+        """Adds a value to a symbol's window, creating the window if needed.
+
+        Args:
+            symbol: Trading symbol
+            value: Value to append (e.g., a close price)
+
+        Returns:
+            RollingWindow: The updated window
+        """
+        with self._lock:
+            window = self._windows.get(symbol)
+            if window is None:
+                window = RollingWindow(self.capacity)
+                self._windows[symbol] = window
+            window.append(value)
+            self._windows.move_to_end(symbol)
+            while len(self._windows) > self.max_symbols:
+                self._windows.popitem(last=False)
+                metrics.increment('window_evictions', store=self.name, reason='capacity')
+        self._publish_gauges()
+        return window
+
+    def get(self, symbol: str) -> Optional[RollingWindow]:
+        """Returns a symbol's window, or None if it is not tracked."""
+        with self._lock:
+            return self._windows.get(symbol)
+
+    def evict_idle(self, now: Optional[float] = None) -> list[str]:
+        """Evicts every symbol not updated within idle_seconds.
+
+        Args:
+            now: Epoch seconds to measure idleness against, defaults to the current time
+
+        Returns:
+            list[str]: The evicted symbols
+        """
+        now = now if now is not None else time.time()
+        evicted = []
+        with self._lock:
+            # Ordered least recently updated first, so stop at the first active symbol
+            for symbol, window in list(self._windows.items()):
+                if now - window.last_update < self.idle_seconds:
+                    break
+                del self._windows[symbol]
+                evicted.append(symbol)
+        if evicted:
+            metrics.increment('window_evictions', len(evicted), store=self.name, reason='idle')
+        self._publish_gauges()
+        return evicted
+
+    def memory_bytes(self) -> int:
+        """Returns the total memory held by all windows in the store."""
+        with self._lock:
+            return sum(window.nbytes() for window in self._windows.values())
+
+    def symbols(self) -> list[str]:
+        """Returns tracked symbols ordered least to most recently updated."""
+        with self._lock:
+            return list(self._windows)
+
+    def _publish_gauges(self) -> None:
+        # Every window has the same fixed size, so avoid summing on each append
+        with self._lock:
+            count = len(self._windows)
+            per_window = next(iter(self._windows.values())).nbytes() if count else 0
+        metrics.set_gauge('window_memory_bytes', count * per_window, store=self.name)
+        metrics.set_gauge('window_symbols', count, store=self.name)
+
+    def __len__(self) -> int:
+        return len(self._windows)
+
+
+class RunningStats:
+    """Count, mean, variance, min, and max of a stream updated in O(1).
+
+    Uses Welford's algorithm, so no values are stored and the variance stays
+    accurate over long streams where summing squares would lose precision.
+
+    Attributes:
+        count: Number of values seen
+        mean: Mean of the values
+        min: Smallest value, None before the first
+        max: Largest value, None before the first
+        last_update: Epoch seconds of the most recent update
+    """
+
+    def __init__(self):
+        """Initializes empty statistics."""
+        self.count = 0
+        self.mean = 0.0
+        self.min = None
+        self.max = None
+        self.last_update = time.time()
+        self._m2 = 0.0
+
+    def update(self, value: float) -> None:
+        """Adds a value to the statistics."""
+        self.count += 1
+        delta = value - self.mean
+        self.mean += delta / self.count
+        self._m2 += delta * (value - self.mean)
+        self.min = value if self.min is None else min(self.min, value)
+        self.max = value if self.max is None else max(self.max, value)
+        self.last_update = time.time()
+
+    def variance(self) -> float:
+        """Returns the sample variance, 0 with fewer than two values."""
+        return self._m2 / (self.count - 1) if self.count > 1 else 0.0
+
+    def standard_deviation(self) -> float:
+        """Returns the sample standard deviation."""
+        return self.variance() ** 0.5
+
+    def snapshot(self) -> dict:
+        """Returns the statistics as a dict."""
+        return {
+            'count': self.count,
+            'mean': self.mean,
+            'variance': self.variance(),
+            'min': self.min,
+            'max': self.max,
+        }
+
+
+class RunningStatsStore:
+    """Per-symbol RunningStats for consumers of the data stream.
+
+    Attributes:
+        name: Label distinguishing this store in metrics
+    """
+
+    def __init__(self, name: str = 'default'):
+        """Initializes an empty store.
+
+        Args:
+            name: Label distinguishing this store in metrics
+        """
+        self.name = name
+        self._stats = {}  # { symbol: RunningStats }
+        self._lock = Lock()
+
+    def update(self, symbol: str, value: float) -> RunningStats:
+        """Adds a value to a symbol's statistics, creating them if needed.
+
+        Args:
+            symbol: Trading symbol
+            value: Value to add (e.g., a close price or return)
+
+        Returns:
+            RunningStats: The updated statistics
+        """
+        with self._lock:
+            stats = self._stats.get(symbol)
+            if stats is None:
+                stats = RunningStats()
+                self._stats[symbol] = stats
+            stats.update(value)
+            count = len(self._stats)
+        metrics.set_gauge('running_stats_symbols', count, store=self.name)
+        return stats
+
+    def get(self, symbol: str) -> Optional[RunningStats]:
+        """Returns a symbol's statistics, or None if it is not tracked."""
+        with self._lock:
+            return self._stats.get(symbol)
+
+    def reset(self, symbol: str) -> None:
+        """Forgets a symbol's statistics, e.g. at the start of a session."""
+        with self._lock:
+            self._stats.pop(symbol, None)
+
+    def symbols(self) -> list[str]:
+        """Returns the tracked symbols."""
+        with self._lock:
+            return list(self._stats)
+
+    def __len__(self) -> int:
+        return len(self._stats)
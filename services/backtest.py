@@ -0,0 +1,19 @@
+from helpers import backtest
+from helpers import logger
+
+logger = logger.Logger('backtest.py')
+
+
+def run() -> None:
+    """
+    Runs a backtest worker that consumes parameter grid work items from SQS.
+    Scaling the number of Backtest tasks fans a large grid out across machines,
+    results are sent to the results queue and aggregated by the submitter with
+    helpers.backtest.collect_grid_results.
+
+    Environment Variables:
+        BACKTEST_SQS_URL: The URL of the SQS queue holding work items.
+        BACKTEST_RESULTS_SQS_URL: The URL of the SQS queue results are sent to.
+    """
+    logger.info('Starting backtest worker.')
+    backtest.run_worker()
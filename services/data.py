@@ -1,135 +1,908 @@
-import os
 import time
 import signal
-import json
 import asyncio
-from alpaca.data.live import StockDataStream
-from alpaca.data.models import Bar
-from helpers import logger, broker, cloud
+import threading
+from datetime import datetime, timezone
+from helpers import logger, aggregation, archive, asset_streams, brokers, cloud, envelope, metrics, tenant, version, gaps
+from helpers import conflation, data_quality, draining, latest_prices, liveness, news, publisher, sinks, snapshots, spool, subscription, timescale
 
 # Configure logger
 logger = logger.Logger('data.py')
 
-# Initialize placeholders for the stream client and universe
-broker_stream_client = None
-broker_universe = None
+# Per-symbol sequence tracking of the bar stream
+gap_detector = gaps.GapDetector()
 
+# State and message rate of the bar stream, published as heartbeats when HEARTBEAT_SECONDS is set
+stream_heartbeat = liveness.StreamHeartbeat()
 
-def get_broker_stream_client():
-    """
-    Lazily initializes and returns the StockDataStream client.
-    Ensures environment variables are loaded before creating the client.
-    """
-    api_key = os.getenv('BROKER_API_KEY')
-    api_secret = os.getenv('BROKER_SECRET_KEY')
-    universe = os.getenv('UNIVERSE').split(',')
-    if not api_key or not api_secret:
-        raise ValueError("Broker API credentials are missing in environment variables.")
+# Stream messages being handled, shutdown waits for them before stopping the streams
+in_flight = draining.InFlight()
 
-    stream_client = StockDataStream(api_key, api_secret)
-    return stream_client, universe
+# How messages are published, 'sns' unless MESSAGE_TRANSPORT is set, see publisher.publishers
+message_transport = 'sns'
 
+# Batches SNS publishes when SNS_BATCH_SIZE is set, None publishes each message directly
+batch_publisher = None
 
-def get_broker_stream():
-    """
-    Returns the StockDataStream client and universe.
-    Initializes the client and universe if they haven't been initialized yet.
-    """
-    global broker_stream_client, broker_universe
-    if broker_stream_client is None or broker_universe is None:
-        broker_stream_client, broker_universe = get_broker_stream_client()
-    return broker_stream_client, broker_universe
+# Spools messages to disk while SNS is unavailable when SPOOL_DIR is set, None drops them
+message_spool = None
+
+# Stores every streamed record besides SNS, configured by ARCHIVE_S3, DATABASE_URL, LATEST_PRICES_TABLE, and SNAPSHOTS
+record_sinks = []
+
+# Hands records to the record sinks from a worker thread, None while no sink is configured
+sink_queue = None
+
+# Reports gaps, stale symbols, and bad records to DATA_QUALITY_SNS when set
+quality_monitor = None
+
+# Publishes at most one quote per symbol per QUOTE_CONFLATION_MS when set, None publishes every quote
+quote_conflator = None
+
+# Symbols being streamed per stream type, loaded when the service starts
+watchlist = subscription.Watchlist([])
+news_watchlist = subscription.Watchlist([])
 
 
 def run() -> None:
     """
     Main function to run the data service.
 
-    This function continuously checks if the market is open.
-    If the market is open, it establishes a WebSocket connection to stream
-    real-time bar data for the specified universe of stocks.
+    This function waits for the market to open, then streams real-time bar data for the specified
+    universe of stocks from the configured broker.
     The service handles graceful shutdown on receiving termination
     signals (e.g., SIGINT or SIGTERM) and retries in case of errors.
 
     The service performs the following steps:
-    1. Checks if the market is open.
-    2. If the market is open, connects to the Alpaca WebSocket stream.
+    1. Sleeps until the market is open, using the broker's cached market clock.
+    2. Connects to the broker's bar stream.
     3. Subscribes to bar data for the specified universe of stocks.
     4. Handles incoming bar data using the `bar_handler` function.
     5. Monitors for termination signals to shut down gracefully.
-    6. Retries the connection in case of errors.
+    6. Retries the connection in case of errors, backfilling the bars missed
+       while disconnected before resuming the stream.
 
     Environment Variables:
+        Each is read for the current TENANT, see tenant.getenv().
+        BROKER (str): The broker to stream from, 'alpaca' (default) or 'ibkr'.
         BROKER_API_KEY (str): Alpaca API key.
         BROKER_SECRET_KEY (str): Alpaca API secret key.
+        DATA_FEED (str): Optional Alpaca feed, 'iex' (default for the stream), 'sip', or 'delayed_sip'.
+        DATA_SANDBOX (str): 'True' to stream from Alpaca's sandbox market data endpoints.
         UNIVERSE (str): Comma-separated list of stock symbols to subscribe to.
+        UNIVERSE_FILE (str): Optional path of a file listing the symbols, used instead of UNIVERSE.
+        UNIVERSE_S3 (str): Optional s3://bucket/key of an object listing the symbols, used before either.
+        NEWS_SNS (str): Optional ARN of the topic news for the universe is published to.
+        DATA_PLAN (str): Optional data plan whose symbol limit the universe must fit, see subscription.PLAN_SYMBOL_LIMITS.
+        SNS_MONTHLY_MESSAGE_BUDGET (int): Optional SNS messages the streams may publish per month.
+        UNIVERSE_OVERFLOW (str): 'refuse' (default) or 'trim' when the universe is over a limit.
+        CONTROL_SQS_URL (str): Optional queue polled for subscribe and unsubscribe commands,
+            see subscription.control_command().
+        CRYPTO_UNIVERSE (str): Optional comma-separated crypto pairs, e.g. 'BTC/USD', streamed around the clock.
+        CRYPTO_SNS (str): ARN of the topic crypto bars are published to.
+        OPTIONS_UNIVERSE (str): Optional comma-separated OCC option symbols streamed while the market is open.
+        OPTIONS_SNS (str): ARN of the topic option quotes and trades are published to.
+        SNS_BATCH_SIZE (int): Optional messages per SNS PublishBatch, publishing from worker threads.
+        SNS_BATCH_FLUSH_SECONDS (float): Longest a message waits for its batch to fill. Defaults to 0.5.
+        SNS_PUBLISH_QUEUE (int): Messages waiting to be published before new ones are dropped. Defaults to 10000.
+        SNS_PUBLISH_WORKERS (int): Publishing threads. Defaults to 2. Under Kinesis, Kafka, and NATS each symbol is published by
+            one of them, so its messages stay in order.
+        ARCHIVE_S3 (str): Optional s3://bucket/prefix every bar, trade, and quote is archived under.
+        ARCHIVE_FORMAT (str): 'jsonl' (default, gzipped) or 'parquet'.
+        ARCHIVE_MAX_RECORDS (int): Records per archived object. Defaults to 5000.
+        ARCHIVE_FLUSH_SECONDS (float): Longest a record is buffered before it is written. Defaults to 900.
+        DATABASE_URL (str): Optional TimescaleDB connection string every bar, trade, and quote is inserted into.
+        DATABASE_BATCH_SIZE (int): Rows per insert. Defaults to 1000.
+        DATABASE_FLUSH_SECONDS (float): Longest a row is buffered before it is inserted. Defaults to 5.
+        DATABASE_CONNECT_TIMEOUT (int): Seconds a connection attempt waits for the database. Defaults to 10.
+        LATEST_PRICES_TABLE (str): Optional DynamoDB table the latest bar, trade, and quote per symbol are kept in.
+        LATEST_PRICES_FLUSH_SECONDS (float): Seconds between writes of a symbol's latest records. Defaults to 1.
+        SINK_QUEUE (int): Records waiting for the sinks above before new ones are dropped. Defaults to 10000.
+        MESSAGE_SERIALIZER (str): 'json' (default) or 'proto' to publish bars, trades, and quotes as
+            base64 protobuf, see proto/market_data.proto.
+        MESSAGE_COMPRESSION (str): Optional 'gzip' or 'zstd' to compress JSON payloads of any kind by their size,
+            see envelope.encode. A single bar, trade, or quote is a few hundred bytes and stays uncompressed, so in
+            practice news articles and other large payloads are.
+        MESSAGE_COMPRESSION_MIN_BYTES (int): Smallest payload compressed. Defaults to 1024.
+        DATA_QUALITY_SNS (str): Optional monitoring topic gap, stale symbol, crossed quote, and zero volume alerts are published to.
+        DATA_QUALITY_STALE_SECONDS (float): Seconds a symbol can go without a bar while the market is open. Defaults to 300.
+        DATA_QUALITY_COOLDOWN_SECONDS (float): Shortest interval between repeated alerts of a symbol and kind. Defaults to 300.
+        DATA_QUALITY_CHECK_SECONDS (float): Seconds between stale symbol checks. Defaults to 30.
+        TRADE_BARS (str): Optional comma-separated bars built from the watchlist's trades, e.g. '5s,15s,dollar:1000000',
+            see aggregation.parse_spec.
+        TRADE_BARS_SNS (str): ARN of the topic bars built from trades are published to.
+        TRADE_BARS_GRACE_SECONDS (float): Time after a time bar's end that late trades are waited for. Defaults to 1.
+        HEARTBEAT_SECONDS (float): Optional seconds between heartbeats published to DATA_SNS with the bar stream's
+            status, subscribed symbol count, and message rate, see liveness.StreamHeartbeat.
+        SHUTDOWN_DRAIN_SECONDS (float): Seconds after SIGTERM to finish in-flight messages and publish what is
+            queued. Defaults to 30, keep it inside the task's stop timeout.
+        SHUTDOWN_SPILL_S3 (str): Optional s3://bucket/prefix messages still unpublished at the drain deadline
+            are written to, otherwise they are counted and dropped.
+        SPOOL_DIR (str): Optional directory messages SNS rejects are spooled to and replayed from once it recovers,
+            on a persistent volume to survive restarts. Suffixed with the tenant, see tenant.scoped_path().
+        SPOOL_MAX_MB (float): Largest size of the spool, the oldest messages are evicted beyond it. Defaults to 256.
+        SPOOL_MAX_FAILURES (int): Consecutive publish failures before every message is spooled. Defaults to 3.
+        SPOOL_RETRY_SECONDS (float): Seconds between attempts to replay the spool. Defaults to 5.
+        MESSAGE_TRANSPORT (str): 'sns' (default), 'kinesis' to put messages on Kinesis data streams partitioned
+            by symbol, DATA_SNS and the other topics then being stream ARNs or names, see kinesis.publish_batch,
+            'kafka' to publish to a Kafka topic per destination and message type keyed by symbol, see
+            kafka.publish_batch and kafka.config for the KAFKA_ connection settings, or 'nats' to publish to
+            NATS subjects per destination, message type, and symbol, see nats_transport.publish_batch.
+        NATS_URL (str): NATS servers for the 'nats' transport. Defaults to nats://localhost:4222.
+        NATS_JETSTREAM (str): 'True' to publish through JetStream streams covering the destinations' subjects.
+        QUOTE_CONFLATION_MS (float): Optional milliseconds, e.g. 250, each symbol's quotes are conflated over,
+            publishing the latest quote per interval while every quote is still archived, see conflation.Conflator.
+        SNAPSHOTS (str): 'True' to keep each symbol's last bar, trade, quote, and the minute bar its trades are
+            building in memory, served by the admin API's /snapshots?symbol=AAPL,MSFT, see snapshots.SnapshotCache.
+            The watchlist's stock trades and quotes are streamed into it, neither is published.
     """
-    stream_client, universe = get_broker_stream_client()
-    shutdown = False
+    global watchlist, news_watchlist, batch_publisher, record_sinks, quality_monitor, in_flight, message_spool
+    global message_transport, quote_conflator, sink_queue
+    broker = brokers.get_broker()
+    shutdown = threading.Event()
+    in_flight = draining.InFlight()
+
+    # Refuse a universe the data plan or message budget cannot carry before subscribing
+    try:
+        universe = subscription.check_configured_universe(subscription.load_universe(), channels=_channels())
+    except Exception as e:
+        logger.error(f'Error validating universe: {e}')
+        return
+    watchlist = subscription.Watchlist(universe)
+    try:
+        message_transport = tenant.getenv('MESSAGE_TRANSPORT', 'sns')
+        transport_publish, transport_publish_batch = publisher.publishers(message_transport)
+    except Exception as e:
+        logger.error(f'Error configuring message transport: {e}')
+        return
+    if tenant.getenv('SPOOL_DIR'):
+        message_spool = spool.DiskSpool(
+            tenant.scoped_path(tenant.getenv('SPOOL_DIR')),
+            max_bytes=int(float(tenant.getenv('SPOOL_MAX_MB', 256)) * 1024 * 1024),
+            max_failures=int(tenant.getenv('SPOOL_MAX_FAILURES', 3)),
+            retry_seconds=float(tenant.getenv('SPOOL_RETRY_SECONDS', 5)),
+            publish=transport_publish,
+            publish_batch=transport_publish_batch
+        )
+        threading.Thread(target=message_spool.run, args=(shutdown,), daemon=True).start()
+    if tenant.getenv('SNS_BATCH_SIZE'):
+        batch_publisher = publisher.BatchPublisher(
+            batch_size=int(tenant.getenv('SNS_BATCH_SIZE')),
+            flush_seconds=float(tenant.getenv('SNS_BATCH_FLUSH_SECONDS', 0.5)),
+            max_queue=int(tenant.getenv('SNS_PUBLISH_QUEUE', 10_000)),
+            workers=int(tenant.getenv('SNS_PUBLISH_WORKERS', 2)),
+            publish_batch=message_spool.publish_batch if message_spool else transport_publish_batch,
+            key=publisher.ordering_key if message_transport in publisher.ORDERED_TRANSPORTS else None
+        ).start()
+    record_sinks = []
+    if tenant.getenv('ARCHIVE_S3'):
+        record_sinks.append(archive.ArchiveSink(
+            tenant.getenv('ARCHIVE_S3'),
+            fmt=tenant.getenv('ARCHIVE_FORMAT', 'jsonl'),
+            max_records=int(tenant.getenv('ARCHIVE_MAX_RECORDS', 5_000)),
+            flush_seconds=float(tenant.getenv('ARCHIVE_FLUSH_SECONDS', 15 * 60))
+        ))
+    if tenant.getenv('DATABASE_URL'):
+        record_sinks.append(timescale.TimescaleSink(
+            tenant.getenv('DATABASE_URL'),
+            batch_size=int(tenant.getenv('DATABASE_BATCH_SIZE', 1_000)),
+            flush_seconds=float(tenant.getenv('DATABASE_FLUSH_SECONDS', 5)),
+            connect_timeout=int(tenant.getenv('DATABASE_CONNECT_TIMEOUT', 10))
+        ))
+    if tenant.getenv('LATEST_PRICES_TABLE'):
+        record_sinks.append(latest_prices.LatestPriceSink(
+            tenant.getenv('LATEST_PRICES_TABLE'),
+            flush_seconds=float(tenant.getenv('LATEST_PRICES_FLUSH_SECONDS', 1))
+        ))
+    if tenant.getenv('SNAPSHOTS') == 'True':
+        snapshots.cache = snapshots.SnapshotCache()
+        record_sinks.append(snapshots.cache)
+    if record_sinks:
+        sink_queue = sinks.SinkQueue(record_sinks, max_queue=int(tenant.getenv('SINK_QUEUE', 10_000))).start()
+    news_watchlist = subscription.Watchlist(universe if tenant.getenv('NEWS_SNS') else [])
+
+    drain_seconds = float(tenant.getenv('SHUTDOWN_DRAIN_SECONDS', 30))
+    drain_deadline = None
+
+    def stop_streams():
+        # Stopping a stream cancels the handlers running on it, so let them finish first
+        if not in_flight.wait(max(drain_deadline - time.monotonic(), 0)):
+            logger.warning(f'{in_flight.count} stream messages still in flight at the drain deadline')
+        broker.stop_stream()
+        news.stop_news_stream()
+        for asset_class in asset_streams.ASSET_CLASSES:
+            asset_streams.stop_stream(asset_class)
 
     def handle_single(signum, frame):
-        nonlocal shutdown
+        nonlocal drain_deadline
         logger.info(f'Received shutdown signal {signum}')
-        shutdown = True
-        stream_client.stop()
+        if shutdown.is_set():
+            return
+        # Refuse new stream messages, the signal arrives on the bar stream's thread so it must not block
+        drain_deadline = time.monotonic() + drain_seconds
+        shutdown.set()
+        in_flight.close()
+        threading.Thread(target=stop_streams, daemon=True).start()
 
     signal.signal(signal.SIGINT, handle_single)
     signal.signal(signal.SIGTERM, handle_single)
 
-    while not shutdown:
+    # Headlines stream alongside bars so strategies can pause around them
+    if tenant.getenv('NEWS_SNS'):
+        threading.Thread(target=run_news, args=(shutdown.is_set,), daemon=True).start()
+
+    # Busy option chains quote far more often than consumers need
+    if tenant.getenv('QUOTE_CONFLATION_MS'):
+        quote_conflator = conflation.Conflator(float(tenant.getenv('QUOTE_CONFLATION_MS')) / 1000)
+        threading.Thread(target=run_quote_conflation, args=(quote_conflator, shutdown), daemon=True).start()
+
+    # Crypto and options stream beside equities, each to its own topic
+    for asset_class in asset_streams.ASSET_CLASSES:
+        symbols = subscription.parse_universe(tenant.getenv(f'{asset_class.upper()}_UNIVERSE') or '')
+        if symbols:
+            threading.Thread(
+                target=run_asset_stream,
+                args=(asset_class, symbols, tenant.getenv(f'{asset_class.upper()}_SNS'), broker, shutdown),
+                daemon=True
+            ).start()
+
+    # Alpaca only streams 1-minute bars, shorter and activity based bars are built from trades
+    aggregator = None
+    if tenant.getenv('TRADE_BARS'):
+        aggregator = aggregation.TradeBarAggregator(
+            aggregation.parse_specs(tenant.getenv('TRADE_BARS')),
+            grace_seconds=float(tenant.getenv('TRADE_BARS_GRACE_SECONDS', 1))
+        )
         try:
-            # Check if the market is open
-            if not broker.is_market_open():
-                retry_minutes = broker.minutes_till_market_open() or 60
-                logger.info(f'Market closed. Sleeping {retry_minutes} minutes')
-                time.sleep(retry_minutes * 60)
-                continue
+            run_trade_bars(aggregator, tenant.getenv('TRADE_BARS_SNS'), broker)
+        except NotImplementedError as e:
+            logger.error(f'Trade bars are not built: {e}')
+        threading.Thread(target=run_trade_bar_clock, args=(aggregator, tenant.getenv('TRADE_BARS_SNS'), shutdown), daemon=True).start()
+
+    # The snapshots' best quote, and last trade without trade bars, need the stock quote and trade streams
+    if snapshots.cache:
+        try:
+            run_snapshot_feeds(broker, trades=aggregator is None)
+        except NotImplementedError as e:
+            logger.error(f'Snapshots are built from bars only: {e}')
+
+    # The watchdog reports symbols that stop streaming while the market is open
+    if tenant.getenv('DATA_QUALITY_SNS'):
+        quality_monitor = data_quality.DataQualityMonitor(
+            tenant.getenv('DATA_QUALITY_SNS'),
+            stale_seconds=float(tenant.getenv('DATA_QUALITY_STALE_SECONDS', 300)),
+            cooldown_seconds=float(tenant.getenv('DATA_QUALITY_COOLDOWN_SECONDS', 300))
+        )
+        threading.Thread(
+            target=run_data_quality,
+            args=(broker, float(tenant.getenv('DATA_QUALITY_CHECK_SECONDS', 30)), shutdown),
+            daemon=True
+        ).start()
+
+    # Heartbeats let consumers tell a quiet market from a dead feed
+    if tenant.getenv('HEARTBEAT_SECONDS'):
+        threading.Thread(
+            target=run_heartbeat, args=(float(tenant.getenv('HEARTBEAT_SECONDS')), tenant.getenv('DATA_SNS'), shutdown), daemon=True
+        ).start()
+
+    # Commands from the scanner or an operator change the streams without a redeploy
+    if tenant.getenv('CONTROL_SQS_URL'):
+        threading.Thread(target=run_control, args=(tenant.getenv('CONTROL_SQS_URL'), shutdown.is_set), daemon=True).start()
 
-            logger.info("Adding universe to stream.")
+    while not shutdown.is_set():
+        try:
+            # Wait for the open, returns early on shutdown
+            stream_heartbeat.set_status('waiting')
+            if not broker.sleep_until_open(shutdown):
+                break
 
-            # Unpack and subscribe universe
-            stream_client.subscribe_bars(bar_handler, *universe)
+            # Publish bars missed while disconnected so rolling windows stay continuous
+            asyncio.run(backfill_since_last_seen(watchlist.symbols()))
 
-            # Start the websocket connection
+            # Subscribe the watchlist and stream until stopped
             logger.info("Starting market data stream.")
-            stream_client.run()
+            stream_heartbeat.set_status('streaming')
+            broker.stream_bars(in_flight.track(bar_handler), watchlist.symbols())
         except Exception as e:
+            stream_heartbeat.set_status('reconnecting')
             logger.error(f"Error in data service: {e}")
-            if not shutdown:
+            if not shutdown.is_set():
                 logger.info("Retrying in 1 minutes...")
-                time.sleep(60)
+                shutdown.wait(60)
+
+    # Publish and store what the streams handed over before exiting
+    if drain_deadline is None:
+        drain_deadline = time.monotonic() + drain_seconds
+    in_flight.close()
+    in_flight.wait(max(drain_deadline - time.monotonic(), 0))
+    if aggregator is not None:
+        asyncio.run(publish_trade_bars(aggregator.close_due(datetime.max.replace(tzinfo=timezone.utc)), tenant.getenv('TRADE_BARS_SNS')))
+    if quote_conflator is not None:
+        asyncio.run(publish_conflated(quote_conflator.flush()))
+    if batch_publisher is not None:
+        unpublished = batch_publisher.stop(timeout=max(drain_deadline - time.monotonic(), 0))
+        if unpublished:
+            spill_unpublished(unpublished)
+    if sink_queue is not None:
+        unstored = sink_queue.stop(timeout=max(drain_deadline - time.monotonic(), 0))
+        if unstored:
+            logger.warning(f'{unstored} records were not stored in the sinks before the drain deadline')
+    logger.info('Data service drained and stopped.')
+
+
+def spill_unpublished(messages: list[tuple[str, str]]) -> None:
+    """
+    Persists messages the batch publisher could not send before the drain
+    deadline to the disk spool, replayed on the next start, or to
+    SHUTDOWN_SPILL_S3, counting them as dropped otherwise.
+
+    Args:
+        messages (list[tuple[str, str]]): (message, topic) pairs.
+    """
+    if message_spool is not None:
+        message_spool.append(messages)
+        logger.warning(f'Spooled {len(messages)} unpublished messages to {message_spool.directory}')
+        return
+    if tenant.getenv('SHUTDOWN_SPILL_S3'):
+        try:
+            uri = draining.spill(tenant.getenv('SHUTDOWN_SPILL_S3'), messages)
+            metrics.increment('spilled_messages', len(messages))
+            logger.warning(f'Spilled {len(messages)} unpublished messages to {uri}')
+            return
+        except Exception as e:
+            logger.error(f'Error spilling {len(messages)} unpublished messages: {e}')
+    metrics.increment('publish_dropped', len(messages))
+    logger.error(f'Dropped {len(messages)} unpublished messages at shutdown')
+
+
+def _channels() -> tuple:
+    return ('bars', 'news') if tenant.getenv('NEWS_SNS') else ('bars',)
+
+
+def subscribe_symbols(symbols: list[str], types: tuple = ('bars',)) -> dict:
+    """
+    Adds symbols to the running streams without a redeploy, checked against
+    the data plan and message budget like the startup universe.
+
+    Args:
+        symbols (list[str]): The symbols to stream.
+        types (tuple, optional): Streams to add them to, 'bars' and/or 'news'. Defaults to bars only.
+
+    Returns:
+        dict: { type: the symbols newly subscribed }.
+
+    Raises:
+        SubscriptionLimitExceeded: If the grown universe is over the limit and UNIVERSE_OVERFLOW is not 'trim'.
+        ValueError: If news is requested without NEWS_SNS set.
+    """
+    if 'news' in types and not tenant.getenv('NEWS_SNS'):
+        raise ValueError('News cannot be subscribed without NEWS_SNS set.')
+    streamed = list(dict.fromkeys(watchlist.symbols() + news_watchlist.symbols()))
+    allowed = subscription.check_configured_universe(
+        streamed + [symbol for symbol in symbols if symbol not in streamed], channels=_channels()
+    )
+    symbols = [symbol for symbol in symbols if symbol in allowed]
+    added = {}
+    if 'bars' in types:
+        added['bars'] = watchlist.add(symbols)
+        if added['bars']:
+            brokers.get_broker().subscribe_bars(added['bars'])
+            if tenant.getenv('TRADE_BARS') or snapshots.cache:
+                brokers.get_broker().subscribe_trades(added['bars'])
+            if snapshots.cache:
+                brokers.get_broker().subscribe_quotes(added['bars'])
+    if 'news' in types:
+        added['news'] = news_watchlist.add(symbols)
+        if added['news']:
+            news.subscribe_news(added['news'])
+    for stream, stream_symbols in added.items():
+        if stream_symbols:
+            logger.info(f'Subscribed {stream} for {", ".join(stream_symbols)}')
+    return added
+
+
+def unsubscribe_symbols(symbols: list[str], types: tuple = ('bars',)) -> dict:
+    """
+    Removes symbols from the running streams without a redeploy.
+
+    Args:
+        symbols (list[str]): The symbols to stop streaming.
+        types (tuple, optional): Streams to remove them from, 'bars' and/or 'news'. Defaults to bars only.
+
+    Returns:
+        dict: { type: the symbols unsubscribed }.
+    """
+    removed = {}
+    if 'bars' in types:
+        removed['bars'] = watchlist.remove(symbols)
+        if removed['bars']:
+            brokers.get_broker().unsubscribe_bars(removed['bars'])
+            if tenant.getenv('TRADE_BARS') or snapshots.cache:
+                brokers.get_broker().unsubscribe_trades(removed['bars'])
+            if snapshots.cache:
+                brokers.get_broker().unsubscribe_quotes(removed['bars'])
+    if 'news' in types:
+        removed['news'] = news_watchlist.remove(symbols)
+        if removed['news']:
+            news.unsubscribe_news(removed['news'])
+    for stream, stream_symbols in removed.items():
+        if stream_symbols:
+            logger.info(f'Unsubscribed {stream} for {", ".join(stream_symbols)}')
+    return removed
 
 
-async def bar_handler(bar: Bar):
+def handle_command(body: str) -> dict:
+    """
+    Applies a control queue command.
+
+    Args:
+        body (str): The message body in the format of subscription.control_command().
+
+    Returns:
+        dict: { type: the symbols changed }.
+
+    Raises:
+        ValueError: If the command is invalid or cannot be applied.
+    """
+    command = subscription.parse_control_command(body)
+    change = subscribe_symbols if command['action'] == 'subscribe' else unsubscribe_symbols
+    return change(command['symbols'], command['types'])
+
+
+def run_control(queue_url: str, is_shutdown) -> None:
+    """
+    Polls the control queue for commands until shutdown. Commands that are
+    rejected or fail to apply are logged and deleted, so one bad message is
+    not redelivered forever and does not block the queue.
+
+    Args:
+        queue_url (str): The control queue.
+        is_shutdown (Callable[[], bool]): Returns True once the service is shutting down.
+    """
+    while not is_shutdown():
+        try:
+            for message in cloud.poll_sqs_message(queue_url, max_messages=10, wait_time_seconds=20):
+                try:
+                    handle_command(message['Body'])
+                except ValueError as e:
+                    metrics.increment('rejected_control_commands')
+                    logger.error(f'Rejected control command {message["Body"]}: {e}')
+                except Exception as e:
+                    metrics.increment('failed_control_commands')
+                    logger.error(f'Failed to apply control command {message["Body"]}: {e}')
+                cloud.delete_sqs_message(queue_url, message['ReceiptHandle'])
+        except Exception as e:
+            logger.error(f'Error polling control queue: {e}')
+            time.sleep(5)
+
+
+def run_trade_bars(aggregator: aggregation.TradeBarAggregator, topic: str, broker) -> None:
+    """
+    Streams the watchlist's trades into the aggregator, publishing the bars
+    they complete. Trades ride the broker's bar stream, so they connect and
+    stop with it, and follow the watchlist as symbols are subscribed.
+
+    Args:
+        aggregator (aggregation.TradeBarAggregator): Builds the bars.
+        topic (str): ARN of the topic bars are published to.
+        broker (brokers.Broker): The broker streaming the trades.
+
+    Raises:
+        NotImplementedError: If the broker cannot stream trades.
+    """
+    async def handler(trade: dict) -> None:
+        received = time.monotonic()
+        metrics.record_symbol_event(trade['symbol'], 'trades')
+        metrics.increment('stream_messages', type='trades', stream='stock_trades')
+        # Stock trades are not published, so they reach the snapshots here
+        if snapshots.cache:
+            snapshots.cache.record('trades', trade)
+        await publish_trade_bars(aggregator.on_trade(trade), topic)
+        observe_handled('trades', 'stock_trades', received)
+
+    logger.info('Subscribing trades for bar aggregation.')
+    broker.stream_trades(in_flight.track(handler), watchlist.symbols())
+
+
+def run_snapshot_feeds(broker, trades: bool = True) -> None:
+    """
+    Streams the watchlist's stock quotes, and trades unless run_trade_bars
+    already does, into the snapshot cache. They ride the broker's bar stream
+    and follow the watchlist like the trades of run_trade_bars.
+
+    Args:
+        broker (brokers.Broker): The broker streaming the quotes and trades.
+        trades (bool, optional): Whether to stream trades too. Defaults to True.
+
+    Raises:
+        NotImplementedError: If the broker cannot stream quotes or trades.
+    """
+    async def on_quote(quote: dict) -> None:
+        snapshots.cache.record('quotes', quote)
+
+    async def on_trade(trade: dict) -> None:
+        snapshots.cache.record('trades', trade)
+
+    logger.info('Subscribing stock quotes for snapshots.')
+    broker.stream_quotes(on_quote, watchlist.symbols())
+    if trades:
+        broker.stream_trades(on_trade, watchlist.symbols())
+
+
+def run_trade_bar_clock(aggregator: aggregation.TradeBarAggregator, topic: str, shutdown: threading.Event) -> None:
+    """
+    Publishes time bars every second once their interval has passed, so a
+    quiet symbol's bar does not wait for its next trade.
+
+    Args:
+        aggregator (aggregation.TradeBarAggregator): Builds the bars.
+        topic (str): ARN of the topic bars are published to.
+        shutdown (threading.Event): Set once the service is shutting down.
+    """
+    while not shutdown.wait(1):
+        try:
+            bars = aggregator.close_due()
+            if bars:
+                asyncio.run(publish_trade_bars(bars, topic))
+        except Exception as e:
+            logger.error(f'Error closing trade bars: {e}')
+
+
+def run_quote_conflation(conflator: conflation.Conflator, shutdown: threading.Event) -> None:
+    """
+    Publishes the quotes held by the conflator as their intervals pass.
+
+    Args:
+        conflator (conflation.Conflator): Holds the latest quote per symbol.
+        shutdown (threading.Event): Set once the service is shutting down.
+    """
+    # Checked a few times per interval so a held quote is not delayed much past it
+    while not shutdown.wait(max(conflator.interval_seconds / 5, 0.01)):
+        try:
+            held = conflator.due()
+            if held:
+                asyncio.run(publish_conflated(held))
+        except Exception as e:
+            logger.error(f'Error publishing conflated quotes: {e}')
+
+
+async def publish_conflated(held: list[tuple]) -> None:
+    """
+    Publishes quotes released by the conflator.
+
+    Args:
+        held (list[tuple]): (quote, topic, asset class) of each released quote.
+    """
+    for message, topic, channel in held:
+        await publish_message(message, topic, channel)
+
+
+async def publish_trade_bars(bars: list[dict], topic: str) -> None:
+    """
+    Publishes bars built from trades to their topic in bar envelopes.
+
+    Args:
+        bars (list[dict]): Bars from aggregation.TradeBarAggregator.
+        topic (str): ARN of the topic.
+    """
+    for bar in bars:
+        metrics.increment('trade_bars', symbol=bar['symbol'], timeframe=bar['timeframe'])
+        await publish_message(bar, topic, 'trade_bars')
+
+
+def run_heartbeat(interval_seconds: float, topic: str, shutdown: threading.Event) -> None:
+    """
+    Publishes a heartbeat every interval until shutdown. Heartbeats are sent
+    directly rather than through the batch publisher, so a backed up
+    publisher cannot delay them.
+
+    Args:
+        interval_seconds (float): Seconds between heartbeats.
+        topic (str): ARN of the topic heartbeats are published to.
+        shutdown (threading.Event): Set once the service is shutting down.
+    """
+    publish = publisher.publishers(message_transport)[0]
+    while not shutdown.wait(interval_seconds):
+        try:
+            publish(_encode('heartbeat', stream_heartbeat.beat(len(watchlist))), topic)
+        except Exception as e:
+            metrics.increment('heartbeat_failures')
+            logger.error(f'Error publishing heartbeat: {e}')
+
+
+def run_data_quality(broker, interval_seconds: float, shutdown: threading.Event) -> None:
+    """
+    Checks the watchlist for stale symbols every interval while the market is
+    open until shutdown. The monitor is reset at each open so the overnight
+    close is not reported.
+
+    Args:
+        broker (brokers.Broker): The broker whose market clock gates the checks.
+        interval_seconds (float): Seconds between checks.
+        shutdown (threading.Event): Set once the service is shutting down.
+    """
+    was_open = False
+    while not shutdown.wait(interval_seconds):
+        try:
+            is_open = broker.is_market_open()
+            if is_open and not was_open:
+                quality_monitor.reset()
+            was_open = is_open
+            if is_open:
+                quality_monitor.publish(quality_monitor.check(watchlist.symbols()))
+        except Exception as e:
+            logger.error(f'Error checking data quality: {e}')
+
+
+async def check_quality(record_type: str, message: dict, issue: dict = None) -> None:
+    """
+    Hands a streamed record, and the gap found before it if any, to the data
+    quality monitor, publishing its alerts off the event loop.
+
+    Args:
+        record_type (str): One of sinks.RECORD_TYPES.
+        message (dict): The bar, trade, or quote.
+        issue (dict, optional): A gap from gap_detector.observe().
+    """
+    if quality_monitor is None:
+        return
+    alerts = quality_monitor.observe(record_type, message)
+    if issue and issue['kind'] == 'gap':
+        alerts += quality_monitor.report_gap(issue)
+    if alerts:
+        loop = asyncio.get_event_loop()
+        await loop.run_in_executor(None, quality_monitor.publish, alerts)
+
+
+def run_news(is_shutdown) -> None:
+    """
+    Streams news for the news watchlist to the news topic until shutdown, reconnecting on errors.
+
+    Args:
+        is_shutdown (Callable[[], bool]): Returns True once the service is shutting down.
+    """
+    while not is_shutdown():
+        try:
+            logger.info('Starting news stream.')
+            news.stream_news(in_flight.track(news_handler), news_watchlist.symbols())
+        except Exception as e:
+            logger.error(f'Error in news stream: {e}')
+        if not is_shutdown():
+            time.sleep(60)
+
+
+def run_asset_stream(asset_class: str, symbols: list[str], topic: str, broker, shutdown: threading.Event) -> None:
+    """
+    Streams an asset class to its topic until shutdown, reconnecting on errors.
+    Crypto streams around the clock, other asset classes wait for the equity open.
+
+    Args:
+        asset_class (str): One of asset_streams.ASSET_CLASSES.
+        symbols (list[str]): The symbols to subscribe to.
+        topic (str): ARN of the topic messages are published to.
+        broker (brokers.Broker): The broker whose market clock gates the stream.
+        shutdown (threading.Event): Set once the service is shutting down.
+    """
+    async def handler(message: dict) -> None:
+        received = time.monotonic()
+        metrics.record_symbol_event(message['symbol'], asset_class)
+        record_type = f"{message['type']}s" if asset_class == 'options' else 'bars'
+        metrics.increment('stream_messages', type=record_type, stream=asset_class)
+        await check_quality(record_type, message)
+        archive_record(record_type, message)
+        # Quotes within their symbol's conflation interval are held for run_quote_conflation
+        if record_type == 'quotes' and quote_conflator:
+            if not quote_conflator.offer((topic, message['symbol']), (message, topic, asset_class)):
+                return
+        await publish_message(message, topic, asset_class)
+        observe_handled(record_type, asset_class, received)
+
+    while not shutdown.is_set():
+        try:
+            if asset_class != 'crypto' and not broker.sleep_until_open(shutdown):
+                break
+            logger.info(f'Starting {asset_class} stream for {len(symbols)} symbols.')
+            asset_streams.STREAMS[asset_class](in_flight.track(handler), symbols)
+        except Exception as e:
+            logger.error(f'Error in {asset_class} stream: {e}')
+        if not shutdown.is_set():
+            shutdown.wait(60)
+
+
+def _encode(kind: str, payload: dict) -> str:
+    # Commit of the producing code so every trade can be traced back to it
+    return envelope.encode(
+        kind, payload, source='data', build=version.get_build_info()['commit'],
+        serializer=tenant.getenv('MESSAGE_SERIALIZER', 'json'),
+        compression=tenant.getenv('MESSAGE_COMPRESSION') or None,
+        min_compress_bytes=int(tenant.getenv('MESSAGE_COMPRESSION_MIN_BYTES', envelope.MIN_COMPRESS_BYTES))
+    )
+
+
+async def send(data: str, topic: str) -> None:
+    """
+    Publishes a message through the batch publisher when one is running,
+    otherwise directly on the default executor, through the spool when one
+    is configured.
+
+    Args:
+        data (str): The message body.
+        topic (str): ARN of the topic.
+    """
+    if batch_publisher is not None:
+        batch_publisher.publish(data, topic)
+        return
+    loop = asyncio.get_event_loop()
+    publish = message_spool.publish if message_spool else publisher.publishers(message_transport)[0]
+    await loop.run_in_executor(None, publish, data, topic)
+
+
+def observe_handled(record_type: str, stream: str, received: float) -> None:
+    """
+    Records how long a stream message took from its handler starting to its
+    publish, with the stream messages still being handled. Latency growing
+    with in-flight messages means the handlers are falling behind the feed.
+    With a batch publisher the handler only queues the message, the wait for
+    its batch to be sent is observed by the publisher as publish_queue_seconds.
+
+    Args:
+        record_type (str): One of sinks.RECORD_TYPES or 'news'.
+        stream (str): The stream the message arrived on, e.g. 'bars' or 'crypto'.
+        received (float): time.monotonic() when the handler started.
+    """
+    metrics.observe('handler_publish_seconds', time.monotonic() - received, type=record_type, stream=stream)
+    metrics.set_gauge('in_flight_messages', in_flight.count)
+
+
+def archive_record(record_type: str, message: dict) -> None:
+    """
+    Queues a streamed record for every configured sink without waiting for
+    them, since a sink writes its due batches as records arrive.
+
+    Args:
+        record_type (str): One of sinks.RECORD_TYPES.
+        message (dict): The bar, trade, or quote.
+    """
+    if sink_queue is not None:
+        sink_queue.offer(record_type, message)
+
+
+async def publish_message(message: dict, topic: str, channel: str) -> None:
+    """
+    Publishes a crypto or options message to its topic in a bar, trade, or quote envelope.
+
+    Args:
+        message (dict): The message to publish, options messages carry their kind in 'type'.
+        topic (str): ARN of the topic.
+        channel (str): The asset class, labels the failure metric.
+    """
+    try:
+        await send(_encode(message.get('type', 'bar'), message), topic)
+    except Exception as e:
+        metrics.increment('publish_failures', symbol=message['symbol'], channel=channel, type=message.get('type', 'bar'))
+        logger.error(f'Error in publishing {channel} data to {topic} {e}')
+
+
+async def news_handler(article: dict) -> None:
+    """
+    Publishes a news article to the news topic in a news envelope.
+
+    Args:
+        article (dict): The article in the format of news.news_to_dict().
+    """
+    received = time.monotonic()
+    for symbol in article['symbols']:
+        metrics.record_symbol_event(symbol, 'news')
+    metrics.increment('stream_messages', type='news', stream='news')
+    try:
+        await send(_encode('news', article), tenant.getenv('NEWS_SNS'))
+    except Exception as e:
+        metrics.increment('news_publish_failures')
+        logger.error(f'Error in publishing news to news topic {e}')
+    observe_handled('news', 'news', received)
+
+
+async def bar_handler(bar: dict):
     """
     Handles incoming bar data for subscribed symbols.
+    When the bar follows a gap in the symbol's stream, the missing bars are
+    backfilled from the historical API and published first, so consumers
+    see a continuous series.
 
     Args:
-        bar (Bar): The bar data object containing information
-                   like symbol, timestamp,
-                   open, high, low, close, and volume.
+        bar (dict): The bar from the broker stream, containing
+                    symbol, timestamp (ISO format), open, high,
+                    low, close, volume, and trade_count.
     """
+    received = time.monotonic()
+    metrics.record_symbol_event(bar['symbol'], 'bars')
+    metrics.increment('stream_messages', type='bars', stream='bars')
+    stream_heartbeat.record_message()
+    issue = gap_detector.observe(bar['symbol'], datetime.fromisoformat(bar['timestamp']))
+    await check_quality('bars', bar, issue)
+    if issue and issue['kind'] == 'gap':
+        logger.warning(
+            f"Suspected gap of {issue['missing']} bars in {bar['symbol']} between {issue['start']} and {issue['end']}"
+        )
+        await backfill(bar['symbol'], issue['start'], issue['end'])
+    elif issue:
+        logger.warning(f"Out of order bar for {bar['symbol']}: {issue['end']} after {issue['start']}")
+    await publish_bar(bar)
+    observe_handled('bars', 'bars', received)
+
+
+async def backfill(symbol: str, start: datetime, end: datetime) -> int:
+    """
+    Publishes the bars strictly between two timestamps from the historical API,
+    flagged with 'backfill': True, in timestamp order.
+
+    Args:
+        symbol (str): The symbol to backfill.
+        start (datetime): Timestamp of the last bar received before the gap.
+        end (datetime): Timestamp of the first bar received after the gap.
+
+    Returns:
+        int: The number of bars published.
+    """
+    loop = asyncio.get_event_loop()
     try:
-        # Convert bar object to SNS format
-        message = {
-            'symbol': bar.symbol,
-            'timestamp': bar.timestamp.isoformat(),
-            'open': bar.open,
-            'high': bar.high,
-            'low': bar.low,
-            'close': bar.close,
-            'volume': bar.volume,
-            'trade_count': bar.trade_count
-        }
-        loop = asyncio.get_event_loop()
-        await loop.run_in_executor(
+        history = await loop.run_in_executor(
             None,
-            cloud.publish_sns_message,
-            json.dumps(message),
-            os.getenv('DATA_SNS')
+            lambda: brokers.get_broker().get_bars([symbol], start, end, '1Min')
         )
     except Exception as e:
+        metrics.increment('backfill_failures', symbol=symbol)
+        logger.error(f'Error backfilling {symbol} from {start} to {end}: {e}')
+        return 0
+    missed = [
+        bar for bar in history.get(symbol, [])
+        if start < datetime.fromisoformat(bar['timestamp']) < end
+    ]
+    for bar in sorted(missed, key=lambda bar: bar['timestamp']):
+        await publish_bar({**bar, 'backfill': True})
+        gap_detector.advance(symbol, datetime.fromisoformat(bar['timestamp']))
+    metrics.increment('backfilled_bars', len(missed), symbol=symbol)
+    logger.info(f'Backfilled {len(missed)} bars for {symbol} from {start} to {end}')
+    return len(missed)
+
+
+async def backfill_since_last_seen(universe: list[str]) -> int:
+    """
+    Backfills every symbol from its last streamed bar up to now, used when
+    the stream reconnects. Symbols never seen, or last seen before a market
+    closure, are skipped.
+
+    Args:
+        universe (list[str]): The symbols being streamed.
+
+    Returns:
+        int: The number of bars published.
+    """
+    now = datetime.now(timezone.utc)
+    published = 0
+    for symbol in universe:
+        last = gap_detector.last_seen(symbol)
+        if last is None or now - last > gap_detector.max_gap:
+            continue
+        published += await backfill(symbol, last, now)
+    if published:
+        logger.info(f'Backfilled {published} bars missed while reconnecting')
+    return published
+
+
+async def publish_bar(bar: dict) -> None:
+    """
+    Publishes a bar to the data topic in a bar envelope, then queues it for the record sinks.
+
+    Args:
+        bar (dict): The bar to publish.
+    """
+    try:
+        await send(_encode('bar', bar), tenant.getenv('DATA_SNS'))
+    except Exception as e:
+        metrics.increment('publish_failures', symbol=bar['symbol'], type='bar')
         logger.error(f'Error in publishing bar data to data topic {e}')
+    archive_record('bars', bar)
 
 
 def dummy_test() -> int:
@@ -0,0 +1,90 @@
+import os
+import signal
+import asyncio
+import threading
+from datetime import datetime, timedelta, timezone
+from helpers import aggregation, logger, replay, subscription
+from services import data
+
+logger = logger.Logger('replay.py')
+
+
+def _time(name: str, end_of_day: bool = False) -> datetime:
+    # A bare date is the whole UTC day, the end date included
+    value = os.getenv(name)
+    timestamp = datetime.fromisoformat(value)
+    if timestamp.tzinfo is None:
+        timestamp = timestamp.replace(tzinfo=timezone.utc)
+    if end_of_day and len(value) == 10:
+        timestamp += timedelta(days=1)
+    return timestamp
+
+
+def run() -> None:
+    """
+    Replays historical bars and trades through the data service's topics, so
+    strategies can be tested end to end against the real pipeline. Bars are
+    published to DATA_SNS as the data service publishes them, trades are
+    aggregated into TRADE_BARS and published to TRADE_BARS_SNS. Records keep
+    their historical timestamps, expect latency budgets to report them late.
+
+    Environment Variables:
+        REPLAY_START: First day or time to replay, ISO format, UTC unless an offset is given.
+        REPLAY_END: Last day or time to replay, a bare date is replayed in full.
+        REPLAY_SOURCE: 'alpaca' (default) for the historical API, or 's3' for the ARCHIVE_S3 archive.
+        REPLAY_TYPES: Comma-separated record types, 'bars' and/or 'trades'. Defaults to bars.
+        REPLAY_SPEED: 'realtime', a multiple such as '10x', or 'max' for as fast as possible. Defaults to realtime.
+        UNIVERSE, UNIVERSE_FILE, UNIVERSE_S3: The symbols to replay, as for the data service.
+        DATA_SNS, TRADE_BARS, TRADE_BARS_SNS, MESSAGE_SERIALIZER, MESSAGE_COMPRESSION: As for the data service.
+    """
+    shutdown = threading.Event()
+
+    def handle_signal(signum, frame):
+        logger.info(f'Received shutdown signal {signum}')
+        shutdown.set()
+
+    signal.signal(signal.SIGINT, handle_signal)
+    signal.signal(signal.SIGTERM, handle_signal)
+
+    try:
+        symbols = subscription.load_universe()
+        start, end = _time('REPLAY_START'), _time('REPLAY_END', end_of_day=True)
+        record_types = [record_type.strip() for record_type in os.getenv('REPLAY_TYPES', 'bars').split(',')]
+        speed = replay.parse_speed(os.getenv('REPLAY_SPEED', 'realtime'))
+        for record_type in record_types:
+            if record_type not in replay.RECORD_TYPES:
+                raise ValueError(f'Unknown record type {record_type}, expected one of {", ".join(replay.RECORD_TYPES)}.')
+        if 'trades' in record_types and not os.getenv('TRADE_BARS'):
+            raise ValueError('Trades are replayed as bars built from them, set TRADE_BARS and TRADE_BARS_SNS.')
+    except Exception as e:
+        logger.error(f'Error configuring replay: {e}')
+        return
+
+    aggregator = None
+    if 'trades' in record_types:
+        aggregator = aggregation.TradeBarAggregator(aggregation.parse_specs(os.getenv('TRADE_BARS')))
+
+    async def publish(record_type: str, record: dict) -> None:
+        # Time bars close on the replayed clock, as the live clock closes them
+        if aggregator is not None:
+            await data.publish_trade_bars(aggregator.close_due(replay.release_time(record_type, record)), os.getenv('TRADE_BARS_SNS'))
+        if record_type == 'bars':
+            await data.publish_bar(record)
+        else:
+            await data.publish_trade_bars(aggregator.on_trade(record), os.getenv('TRADE_BARS_SNS'))
+
+    try:
+        streams = {}
+        for record_type in record_types:
+            if os.getenv('REPLAY_SOURCE', 'alpaca') == 's3':
+                records = replay.load_archive(os.getenv('ARCHIVE_S3'), record_type, symbols, start.date(), end.date())
+                streams[record_type] = [record for record in records if start <= datetime.fromisoformat(record['timestamp']) < end]
+            else:
+                streams[record_type] = replay.load_alpaca(record_type, symbols, start, end)
+            logger.info(f'Loaded {len(streams[record_type])} {record_type} for {len(symbols)} symbols from {start} to {end}.')
+        asyncio.run(replay.replay(replay.merge(streams), publish, speed, is_shutdown=shutdown.is_set))
+        # Close the bars still open when the records ran out
+        if aggregator is not None:
+            asyncio.run(data.publish_trade_bars(aggregator.close_due(datetime.max.replace(tzinfo=timezone.utc)), os.getenv('TRADE_BARS_SNS')))
+    except Exception as e:
+        logger.error(f'Error in replay: {e}')
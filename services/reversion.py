@@ -1,17 +1,42 @@
-import os
-import time
-import json
-from datetime import datetime, timedelta
+import math
+from datetime import datetime, timedelta, timezone
+from typing import Callable, Optional
 from helpers import cloud
+from helpers import envelope
 from helpers import broker
+from helpers import brokers
 from helpers import logger
 from helpers import strategy
 from helpers import statistics
-from alpaca.trading.enums import OrderSide
-from alpaca.data.timeframe import TimeFrame
+from helpers import monitoring
+from helpers import performance
+from helpers import polling
+from helpers import metrics
+from helpers import bar_cache
+from helpers import market_data
+from helpers import drawdown
+from helpers import tenant
+from helpers import volatility
+from helpers import news
+from helpers import spreads
+from helpers import supervision
+from helpers import throttle
+from helpers import sizing
+from helpers import fx
+from helpers import lookback
+from helpers import liveness
+from helpers import kafka
+from helpers import nats_transport
+from helpers.domain import Side, Signal
 
 logger = logger.Logger('reversion.py')
 
+# Bollinger Band lookback in bars
+BOLLINGER_WINDOW = 20
+
+# Rolling beta lookback in bars for stock versus sector ETF spreads
+SPREAD_BETA_WINDOW = 60
+
 
 def run() -> None:
     """
@@ -21,20 +46,86 @@ def run() -> None:
 
     This function performs the following steps:
     1. Subscribes the reversion SQS queue to the data SNS topic to receive trading signals.
-    2. Continuously polls the SQS queue for new messages (trading signals).
+    2. Continuously polls the SQS queue for new messages (trading signals), adapting
+       the batch size and concurrency to the backlog.
     3. Processes each message (e.g., executes trades or updates strategy state).
-    4. Deletes processed messages from the SQS queue to avoid reprocessing.
+    4. Deletes processed messages from the SQS queue to avoid reprocessing, leaving messages
+       that failed to be delivered again.
 
     The service runs indefinitely, making it suitable for deployment as a long-running
     background process in an algorithmic trading platform.
 
     Environment Variables:
+        Each is read for the current TENANT, see tenant.getenv().
         - REVERSION_SQS_ARN: The ARN of the SQS queue used for the reversion strategy.
         - REVERSION_SQS_URL: The URL of the SQS queue used for the reversion strategy.
         - DATA_SNS: The ARN of the SNS topic that publishes trading data.
         - AWS_REGION: The AWS region where the SQS and SNS resources are located.
         - AWS_ACCESS_KEY_ID: The AWS access key for authentication.
         - AWS_SECRET_ACCESS_KEY: The AWS secret key for authentication.
+        - REVERSION_NOTIONAL: Optional dollar amount per trade. When set, long entries are
+          sized by notional using fractional shares instead of share quantity. Short entries
+          are floored to whole shares, which Alpaca requires, and exits close the position.
+        - REVERSION_POLL_MAX_BATCH: Largest messages per receive request under load. Defaults to 10.
+        - REVERSION_POLL_MAX_WORKERS: Largest number of concurrent receive requests under load. Defaults to 4.
+        - REVERSION_MAX_BACKLOG: Queue backlog that triggers a lag alert. Defaults to 100.
+        - REVERSION_MAX_MESSAGE_AGE: Message age in seconds that triggers a lag alert. Defaults to 120.
+        - REVERSION_LATENCY_BUDGET_MS: Bar close to order submission latency that triggers an
+          alert. Defaults to 500.
+        - REVERSION_SIGNAL_TTL_SECONDS: Seconds after a bar's timestamp an entry signal expires and is
+          dropped instead of traded, including signals awaiting approval. Exits never expire. 0 never
+          expires signals. Defaults to 120, the bar itself plus one more, or 0 in supervised mode
+          where signals wait on an operator.
+        - REVERSION_SKIP_LATE_SIGNALS: 'true' to skip signals over the latency budget. Defaults to false.
+        - REVERSION_CAPITAL: Dollar capital allocated to the strategy for drawdown limits and the
+          live performance report. Defaults to the account equity at startup.
+        - REVERSION_DRAWDOWN_REDUCE_PCT: Drawdown percent that halves position sizes. Defaults to 5.
+        - REVERSION_DRAWDOWN_HALT_PCT: Drawdown percent that halts new positions until resumed
+          through the admin API. Defaults to 10.
+        - REVERSION_DRAWDOWN_STATE_FILE: Optional file a halt is persisted to across restarts.
+        - REVERSION_MAX_IV_RANK: Optional options IV rank above which new entries are skipped.
+        - REVERSION_IV_STATE_FILE: Optional file the daily IV history is persisted to.
+        - NEWS_SNS: Optional ARN of the news topic, headlines pause entries in their symbols.
+        - REVERSION_NEWS_PAUSE_SECONDS: Seconds entries are paused after a headline (default 300).
+        - REVERSION_SECTOR_PAIRS: Optional STOCK:ETF pairs, e.g. 'AAPL:XLK,JPM:XLF'. Paired stocks
+          must be in the universe and their ETFs streamed by the data service, the spread of
+          each stock over its beta-hedged ETF is traded when the stock has no outright signal.
+        - REVERSION_MAX_ROUND_TRIPS: Optional round trips per symbol or pair per day after which
+          new entries are skipped for the rest of the day.
+        - REVERSION_STOP_OUT_PCT: Loss in percent of the entry notional that makes a round trip
+          a stop-out. Defaults to 1.
+        - REVERSION_STOP_OUT_COOLDOWN_SECONDS: Optional seconds entries in a symbol or pair are
+          skipped after a stop-out.
+        - REVERSION_SIZING: Optional 'fixed', 'kelly', or 'half_kelly' to size entries as a fraction
+          of account equity instead of one share, exits flatten the position. Ignored with REVERSION_NOTIONAL.
+        - REVERSION_KELLY_ESTIMATE: 'payoff' (default) for win rate and payoff, 'moments' for mean
+          over variance of closed trade returns.
+        - REVERSION_SIZING_FRACTION: Fraction of equity per entry for 'fixed', and for Kelly until
+          REVERSION_SIZING_MIN_TRADES trades have closed. Defaults to 0.01.
+        - REVERSION_SIZING_MAX_FRACTION: Cap on the fraction of equity per entry. Defaults to 0.2.
+        - REVERSION_SIZING_MIN_TRADES: Closed trades before Kelly sizing is used. Defaults to 30.
+        - REVERSION_WINDOW_HALF_LIVES: Optional half-lives per Bollinger window, sizing each symbol's
+          window from its estimated mean-reversion half-life instead of 20 bars.
+        - REVERSION_WINDOW_MIN: Shortest adaptive window in bars. Defaults to 10.
+        - REVERSION_WINDOW_MAX: Longest adaptive window in bars. Defaults to 120, the closes kept per symbol.
+        - REVERSION_WINDOW_REESTIMATE_MINUTES: Minutes of bar time between half-life estimates. Defaults to 30.
+        - SUPERVISED_MODE: 'True' to queue signals for operator approval through the admin API.
+        - REVERSION_AUTO_APPROVE_SECONDS: Seconds after which a queued signal is approved, 0 to
+          wait for an operator. Defaults to 60.
+        - REVERSION_JOURNAL_FILE: Optional JSON lines file signals and decisions are journaled to.
+        - REVERSION_HEARTBEAT_TIMEOUT_SECONDS: Optional seconds without a data service heartbeat after
+          which the feed is reported dead. Requires HEARTBEAT_SECONDS on the data service.
+        - REVERSION_FEED_MAX_MESSAGE_AGE: Seconds a connected feed can go without a bar before it is
+          reported dead. Defaults to 180.
+        - REVERSION_SNS_FILTER: 'True' to filter the data topic subscription to bars for the universe and
+          sector pair ETFs, plus heartbeats, instead of receiving every message, see data_filter_policy.
+          Unsetting it leaves a policy already applied in place.
+        - MESSAGE_TRANSPORT: 'kafka' to consume the data service's Kafka topics in a consumer group
+          instead of polling REVERSION_SQS_URL, DATA_SNS and NEWS_SNS then naming the topics as the
+          data service publishes them, see kafka.topic_name and kafka.config. 'nats' to consume its
+          NATS subjects likewise, with NATS_URL and NATS_JETSTREAM as for the data service. Neither
+          needs the SQS queue or its subscriptions, optional AWS features such as ALERT_SNS still do. Defaults to 'sns'.
+        - ALERT_SNS: Optional ARN of the SNS topic receiving operational alerts.
 
     Raises:
         Logs errors if any of the following occur:
@@ -48,91 +139,699 @@ def run() -> None:
         - The service assumes that the SQS queue is configured to receive messages from
           the SNS topic and that the trading strategy logic is implemented elsewhere.
     """
-    # Ensure the reversion SQS is subscribed to the data SNS
+    # Kafka consumer groups or NATS subscriptions replace the SQS queue and its topic subscriptions
+    transport = tenant.getenv('MESSAGE_TRANSPORT', 'sns')
+    use_queue = transport not in ('kafka', 'nats')
+
+    # Get strategy universe
+    reversion_universe = [symbol.strip() for symbol in tenant.getenv('REVERSION_UNIVERSE').split(',') if symbol.strip()]
+
+    # Ensure the reversion SQS is subscribed to the data SNS, filtered to the bars traded when configured
+    if use_queue:
+        try:
+            filter_policy = None
+            if tenant.getenv('REVERSION_SNS_FILTER') == 'True':
+                symbols = reversion_universe + list(spreads.parse_pairs(tenant.getenv('REVERSION_SECTOR_PAIRS')).values())
+                filter_policy = data_filter_policy(symbols)
+            cloud.subscribe_sqs_to_sns(
+                queue_arn=tenant.getenv('REVERSION_SQS_ARN'),
+                topic_arn=tenant.getenv('DATA_SNS'),
+                filter_policy=filter_policy
+            )
+            logger.info('Successfully subscribed SQS to SNS.')
+        except Exception as e:
+            logger.error(f'Error subscribing to SNS data topic: {e}')
+            return
+
+    # Headlines arrive on the same queue as bars when the news topic is configured
+    headline_guard = None
+    if tenant.getenv('NEWS_SNS'):
+        try:
+            if use_queue:
+                cloud.subscribe_sqs_to_sns(
+                    queue_arn=tenant.getenv('REVERSION_SQS_ARN'),
+                    topic_arn=tenant.getenv('NEWS_SNS')
+                )
+            headline_guard = news.HeadlineGuard(float(tenant.getenv('REVERSION_NEWS_PAUSE_SECONDS', 300)))
+        except Exception as e:
+            logger.error(f'Error subscribing to SNS news topic: {e}')
+            return
+
+    # Lock the strategy out of new risk as it draws down from its high-water mark
     try:
-        cloud.subscribe_sqs_to_sns(
-            queue_arn=os.getenv('REVERSION_SQS_ARN'),
-            topic_arn=os.getenv('DATA_SNS')
-        )
-        logger.info('Successfully subscribed SQS to SNS.')
+        capital = float(tenant.getenv('REVERSION_CAPITAL') or brokers.get_broker().get_account_equity())
+        drawdown_guard = drawdown.register_guard(drawdown.DrawdownGuard(
+            strategy=tenant.resource_name('reversion'),
+            capital=capital,
+            reduce_at_pct=float(tenant.getenv('REVERSION_DRAWDOWN_REDUCE_PCT', 5)),
+            halt_at_pct=float(tenant.getenv('REVERSION_DRAWDOWN_HALT_PCT', 10)),
+            state_file=tenant.scoped_path(tenant.getenv('REVERSION_DRAWDOWN_STATE_FILE'))
+        ))
     except Exception as e:
-        logger.error(f'Error subscribing to SNS data topic: {e}')
+        logger.error(f'Error creating drawdown guard: {e}')
         return
 
+    # Live P&L reported through the admin API's /performance, sampled once per bar
+    performance_tracker = performance.register_tracker(performance.LivePerformance(
+        strategy=tenant.resource_name('reversion'),
+        capital=capital
+    ))
+
     # Construct import strategy containers
-    trading_state_manager = strategy.TradingStateManager(logger=logger)
+    trading_state_manager = strategy.TradingStateManager(logger=logger, performance_tracker=performance_tracker)
     risk_manager = strategy.RiskManager(trading_state_manager)
     order_executor = strategy.OrderExecutor(
         state_manager=trading_state_manager,
-        risk_manager=risk_manager
+        risk_manager=risk_manager,
+        drawdown_guard=drawdown_guard
         )
 
-    # Get strategy universe
-    reversion_universe = os.getenv('REVERSION_UNIVERSE').split(',')
+    # Stock versus sector ETF spreads widen the opportunity set beyond single names
+    try:
+        sector_pairs = spreads.parse_pairs(tenant.getenv('REVERSION_SECTOR_PAIRS'))
+    except ValueError as e:
+        logger.error(f'Error parsing sector pairs: {e}')
+        return
+    for stock in [stock for stock in sector_pairs if stock not in reversion_universe]:
+        logger.warning(f'Sector pair stock {stock} is not in the universe, ignoring it')
+        del sector_pairs[stock]
+
+    # Optional dollar sizing per trade for small accounts, uses fractional shares
+    reversion_notional = float(tenant.getenv('REVERSION_NOTIONAL', 0))
+
+    # Kafka and NATS are read in a consumer group, SQS batch size and concurrent receives follow the backlog
+    if not use_queue:
+        sources = [(tenant.getenv('DATA_SNS'), kind) for kind in ('bar', 'heartbeat')]
+        if tenant.getenv('NEWS_SNS'):
+            sources.append((tenant.getenv('NEWS_SNS'), 'news'))
+        try:
+            if transport == 'kafka':
+                poller = kafka.KafkaPoller([kafka.topic_name(*source) for source in sources], group_id=tenant.resource_name('reversion'))
+            else:
+                poller = nats_transport.NatsPoller([nats_transport.wildcard(*source) for source in sources], group=tenant.resource_name('reversion'))
+        except Exception as e:
+            logger.error(f'Error subscribing to {transport} messages: {e}')
+            return
+    else:
+        poller = polling.AdaptivePoller(
+            queue_url=tenant.getenv('REVERSION_SQS_URL'),
+            max_batch=int(tenant.getenv('REVERSION_POLL_MAX_BATCH', polling.MAX_SQS_BATCH)),
+            max_workers=int(tenant.getenv('REVERSION_POLL_MAX_WORKERS', 4))
+        )
+
+    def delete_message(message: dict) -> None:
+        # Committing the offset or acknowledging is the equivalent of deleting from the queue
+        if not use_queue:
+            poller.delete(message['ReceiptHandle'])
+        else:
+            cloud.delete_sqs_message(queue_url=tenant.getenv('REVERSION_SQS_URL'), receipt_handle=message['ReceiptHandle'])
+
+    # Track consumer lag so trading on stale prices is visible
+    lag_monitor = monitoring.QueueLagMonitor(
+        queue_url=tenant.getenv('REVERSION_SQS_URL'),
+        logger=logger,
+        max_backlog=int(tenant.getenv('REVERSION_MAX_BACKLOG', 100)),
+        max_age_seconds=float(tenant.getenv('REVERSION_MAX_MESSAGE_AGE', 120)),
+        read_backlog=None if use_queue else poller.lag
+    )
+
+    # A silently dead feed looks like a quiet market unless the data service's heartbeats are watched
+    feed_liveness = None
+    if tenant.getenv('REVERSION_HEARTBEAT_TIMEOUT_SECONDS'):
+        feed_liveness = liveness.FeedLiveness(
+            logger,
+            max_silence_seconds=float(tenant.getenv('REVERSION_HEARTBEAT_TIMEOUT_SECONDS')),
+            max_message_age_seconds=float(tenant.getenv('REVERSION_FEED_MAX_MESSAGE_AGE', 180))
+        )
+
+    # Late reversion entries have materially worse expectancy
+    latency_budget = monitoring.LatencyBudget(
+        logger=logger,
+        budget_ms=float(tenant.getenv('REVERSION_LATENCY_BUDGET_MS', 500)),
+        skip_late=tenant.getenv('REVERSION_SKIP_LATE_SIGNALS', 'false').lower() == 'true'
+    )
+
+    # Reversion performance degrades when implied volatility is exploding
+    iv_filter = None
+    if tenant.getenv('REVERSION_MAX_IV_RANK'):
+        iv_filter = volatility.IVFilter(
+            volatility.IVFeatures(state_file=tenant.scoped_path(tenant.getenv('REVERSION_IV_STATE_FILE'))),
+            max_iv_rank=float(tenant.getenv('REVERSION_MAX_IV_RANK'))
+        )
+
+    # A broken symbol or pair should not churn commissions all afternoon
+    trade_throttle = None
+    if tenant.getenv('REVERSION_MAX_ROUND_TRIPS') or tenant.getenv('REVERSION_STOP_OUT_COOLDOWN_SECONDS'):
+        trade_throttle = throttle.TradeThrottle(
+            max_round_trips=int(tenant.getenv('REVERSION_MAX_ROUND_TRIPS', 0)),
+            stop_out_pct=float(tenant.getenv('REVERSION_STOP_OUT_PCT', 1)),
+            cooldown_seconds=float(tenant.getenv('REVERSION_STOP_OUT_COOLDOWN_SECONDS', 0))
+        )
+
+    # Entries sized from the strategy's own closed trades rather than one share
+    position_sizer = None
+    if tenant.getenv('REVERSION_SIZING') and not reversion_notional:
+        try:
+            position_sizer = sizing.PositionSizer(
+                method=tenant.getenv('REVERSION_SIZING').lower(),
+                estimate=tenant.getenv('REVERSION_KELLY_ESTIMATE', 'payoff').lower(),
+                fixed_fraction=float(tenant.getenv('REVERSION_SIZING_FRACTION', 0.01)),
+                max_fraction=float(tenant.getenv('REVERSION_SIZING_MAX_FRACTION', 0.2)),
+                min_trades=int(tenant.getenv('REVERSION_SIZING_MIN_TRADES', 30))
+            )
+        except ValueError as e:
+            logger.error(f'Error creating position sizer: {e}')
+            return
+
+    # Band windows that follow each symbol's half-life instead of a fixed 20 bars
+    adaptive_lookback = None
+    if tenant.getenv('REVERSION_WINDOW_HALF_LIVES'):
+        adaptive_lookback = lookback.AdaptiveLookback(
+            multiplier=float(tenant.getenv('REVERSION_WINDOW_HALF_LIVES')),
+            min_window=int(tenant.getenv('REVERSION_WINDOW_MIN', 10)),
+            max_window=int(tenant.getenv('REVERSION_WINDOW_MAX', 120)),
+            default=BOLLINGER_WINDOW,
+            reestimate_seconds=float(tenant.getenv('REVERSION_WINDOW_REESTIMATE_MINUTES', 30)) * 60
+        )
+
+    # Supervised deployments hold signals for an operator to approve or veto
+    supervisor = None
+    if supervision.enabled():
+        supervisor = supervision.register_queue(supervision.SignalQueue(
+            strategy=tenant.resource_name('reversion'),
+            auto_approve_seconds=float(tenant.getenv('REVERSION_AUTO_APPROVE_SECONDS', 60)),
+            journal=supervision.Journal(tenant.scoped_path(tenant.getenv('REVERSION_JOURNAL_FILE')))
+        ))
+
+    # An entry is only valid through the bar after the one it came from, unless an operator holds it
+    signal_ttl = float(tenant.getenv('REVERSION_SIGNAL_TTL_SECONDS', 0 if supervisor else 120)) or None
 
     # Poll SQS for messages forever
     while True:
         try:
-            # Poll messages from the SQS queue
-            messages = cloud.poll_sqs_message(
-                queue_url=os.getenv('REVERSION_SQS_URL')
-            )
+            if supervisor:
+                release_ideas(supervisor, order_executor, trade_throttle, position_sizer)
+            # Poll messages from the SQS queue, long polling while it is drained
+            messages = poller.poll(lag_monitor.backlog)
+            lag_monitor.record_messages(messages)
+            lag_monitor.check()
+            if feed_liveness:
+                feed_liveness.check()
             if not messages:
-                logger.info('No reversion queue messages available. Sleeping for 10 seconds...')
-                time.sleep(10)
+                logger.info('No reversion queue messages available')
                 continue
             # Process each message
             for message in messages:
-                # Transform message for later use
-                outer_message = json.loads(message['Body'])
-                bar_data = json.loads(outer_message['Message'])
+                # Unwrap the SNS notification and the message envelope
+                decoded = envelope.decode_sqs(message)
+                bar_data = decoded['payload']
+                if decoded['kind'] != 'bar':
+                    if decoded['kind'] == 'news':
+                        logger.info(f"Received headline: ID={message['MessageId']}, SYMBOLS={bar_data['symbols']}")
+                        if headline_guard:
+                            headline_guard.record(bar_data)
+                    elif decoded['kind'] == 'heartbeat':
+                        if feed_liveness:
+                            feed_liveness.observe(bar_data)
+                    else:
+                        logger.warning(f"Skipping unsupported {decoded['schema']} message {message['MessageId']}")
+                    try:
+                        delete_message(message)
+                    except Exception as e:
+                        logger.error(f'Error deleting SQS message: {e}')
+                    continue
                 logger.info(
                     f"Received SNS message: ID={message['MessageId']}, SYMBOL={bar_data['symbol']}"
                 )
-                # Delete the message from the queue after processing
-                try:
-                    cloud.delete_sqs_message(
-                        queue_url=os.getenv('REVERSION_SQS_URL'),
-                        receipt_handle=message['ReceiptHandle']
-                    )
-                except Exception as e:
-                    logger.error(f'Error deleting SQS message: {e}')
-
-                try:
-                    # Don't generate signals if market is not open
-                    if not broker.is_market_open() or broker.minutes_till_market_close() <= 15:
-                        retry_minutes = broker.minutes_till_market_open() or 60
-                        logger.info(
-                            f'Market not open or <= 15 minutes left in trading day, skipping signal generation for {retry_minutes} minutes'
-                        )
-                        continue
-
-                    # signal generation
-                    do, side, qty, symbol = generate_signal(bar_data, reversion_universe)
-
-                    # make sure signal said to move and that market is not about to close
-                    if do and broker.minutes_till_market_close() > 15:
-                        order_executor.execute_market_order(
-                            symbol=symbol,
-                            qty=qty if side == OrderSide.BUY else -qty
-                        )
-
-                    # Make sure to liquidate all positions 15 minutes prior to market close
-                    if broker.minutes_till_market_close() <= 15:
-                        order_executor.liquidate_all_positions()
-                except Exception as e:
-                    logger.error(f'Error in reversion strategy: {e}')
+                metrics.record_symbol_event(bar_data['symbol'], 'messages')
+                handle_bar_message(
+                    message, bar_data, delete_message, reversion_universe, order_executor,
+                    reversion_notional=reversion_notional,
+                    latency_budget=latency_budget,
+                    iv_filter=iv_filter,
+                    headline_guard=headline_guard,
+                    sector_pairs=sector_pairs,
+                    supervisor=supervisor,
+                    trade_throttle=trade_throttle,
+                    signal_ttl=signal_ttl,
+                    position_sizer=position_sizer,
+                    adaptive_lookback=adaptive_lookback
+                )
         except Exception as e:
             logger.error(f'Error receiving SQS message: {e}')
 
 
-def generate_signal(message: dict, reversion_universe: list[str]):
+def data_filter_policy(symbols: list[str]) -> dict:
+    """
+    Build the data topic subscription filter for the bars traded, plus
+    heartbeats. A universe too large for SNS to list is filtered on kind and
+    asset class only, which still leaves out quotes, trades, and other assets.
+
+    Args:
+        symbols (list[str]): The universe and sector pair ETFs.
+
+    Returns:
+        dict: The filter policy, see cloud.sns_filter_policy.
+    """
+    try:
+        return cloud.sns_filter_policy(kinds=['bar', 'heartbeat'], symbols=symbols)
+    except ValueError as e:
+        logger.warning(f'Filtering the data topic on kind and asset class only: {e}')
+        return cloud.sns_filter_policy(kinds=['bar', 'heartbeat'], asset_classes=['us_equity'])
+
+
+def handle_bar_message(
+    message: dict,
+    bar_data: dict,
+    delete_message: Callable[[dict], None],
+    reversion_universe: list[str],
+    order_executor: strategy.OrderExecutor,
+    **options
+) -> bool:
+    """
+    Runs handle_bar on a bar message, then deletes it from the queue. A bar
+    whose handling fails is left on the queue, or its offset uncommitted, so
+    it is delivered again rather than lost. Its deterministic client order ID
+    keeps a redelivered bar from submitting an order twice.
+
+    Args:
+        message (dict): The SQS shaped message the bar came in.
+        bar_data (dict): The bar message's payload.
+        delete_message (Callable[[dict], None]): Deletes, acknowledges, or commits the message.
+        reversion_universe (list[str]): Symbols the strategy trades.
+        order_executor (strategy.OrderExecutor): Executor orders are submitted through.
+        **options: handle_bar's keyword-only collaborators.
+
+    Returns:
+        bool: Whether the bar was handled and its message deleted.
+    """
+    try:
+        handle_bar(bar_data, reversion_universe, order_executor, **options)
+    except Exception as e:
+        logger.error(f"Error in reversion strategy, leaving message {message['MessageId']} for redelivery: {e}")
+        return False
+    try:
+        delete_message(message)
+    except Exception as e:
+        logger.error(f'Error deleting SQS message: {e}')
+    return True
+
+
+def handle_bar(
+    bar_data: dict,
+    reversion_universe: list[str],
+    order_executor: strategy.OrderExecutor,
+    *,
+    reversion_notional: float = 0.0,
+    latency_budget: Optional[monitoring.LatencyBudget] = None,
+    iv_filter: Optional[volatility.IVFilter] = None,
+    headline_guard: Optional[news.HeadlineGuard] = None,
+    sector_pairs: Optional[dict[str, str]] = None,
+    supervisor: Optional[supervision.SignalQueue] = None,
+    trade_throttle: Optional[throttle.TradeThrottle] = None,
+    signal_ttl: Optional[float] = None,
+    position_sizer: Optional[sizing.PositionSizer] = None,
+    adaptive_lookback: Optional[lookback.AdaptiveLookback] = None
+) -> Optional[dict]:
+    """
+    Runs the strategy on one bar from the data topic: generates a signal,
+    submits the resulting order, and liquidates near the close. Shared by the
+    live service loop and the backtest parity runner. The optional
+    collaborators are keyword-only, None or 0 leaves their check out.
+
+    Args:
+        bar_data (dict): The bar message.
+        reversion_universe (list[str]): Symbols the strategy trades.
+        order_executor (strategy.OrderExecutor): Executor orders are submitted through.
+        reversion_notional (float, optional): Dollar amount per long entry, short entries are floored to
+                                              whole shares and exits close the position. 0 to size by quantity.
+        latency_budget (Optional[monitoring.LatencyBudget], optional): Budget checked before
+                                                                        submission, None to skip the check.
+        iv_filter (Optional[volatility.IVFilter], optional): Filter new entries are checked
+                                                             against, None to skip the check.
+        headline_guard (Optional[news.HeadlineGuard], optional): Guard pausing new entries after
+                                                                 headlines, None to skip the check.
+        sector_pairs (Optional[dict[str, str]], optional): { stock: sector ETF } whose spreads
+                                                           are traded, None to trade single names only.
+        supervisor (Optional[supervision.SignalQueue], optional): Queue orders are held in for operator
+                                                                  review, None to execute them directly.
+        trade_throttle (Optional[throttle.TradeThrottle], optional): Round trip limits and stop-out
+                                                                     cooldowns, None for no limits.
+        signal_ttl (Optional[float], optional): Seconds after the bar's timestamp an entry signal
+                                                expires, None to never expire signals. Exits never expire.
+        position_sizer (Optional[sizing.PositionSizer], optional): Sizer share quantities of entries come
+                                                                   from, exits flatten the position. None
+                                                                   trades the signal's quantity.
+        adaptive_lookback (Optional[lookback.AdaptiveLookback], optional): Bollinger windows from each
+                                                                           symbol's half-life, None for
+                                                                           BOLLINGER_WINDOW.
+
+    Returns:
+        Optional[dict]: The order attempted as { 'symbol', 'side', 'qty', 'notional',
+        'client_order_id', 'expires_at', 'placed' }, None when no order was attempted. Orders skipped
+        for an expired signal, exceeding the latency budget, the IV filter, a headline pause, or the trade throttle are returned
+        with 'placed' False.
+        Spread entries carry the ETF leg under 'hedge' in the same format, present once the stock leg is placed,
+        and exits of a hedged stock carry the order closing its ETF leg under 'unwound_hedge'.
+        Orders queued for review are returned with 'placed' False and their idea ID under 'idea'.
+    """
+    # Sector ETF bars only feed the spreads unless the ETF is traded itself
+    if sector_pairs and bar_data['symbol'] in sector_pairs.values() and bar_data['symbol'] not in reversion_universe:
+        bar_cache.get_bar_cache().add(bar_data)
+        return None
+
+    # Backfilled bars fill in the rolling window but are too old to trade on
+    if bar_data.get('backfill'):
+        if bar_data['symbol'] in reversion_universe:
+            bar_cache.get_bar_cache().add(bar_data)
+        return None
+
+    # Value open positions at every live bar so the drawdown guard sees unrealized losses
+    if bar_data['symbol'] in reversion_universe:
+        order_executor.mark_to_market(bar_data['symbol'], bar_data['close'])
+
+    broker_impl = brokers.get_broker()
+    # Don't generate signals if market is not open
+    if not broker_impl.is_market_open() or broker_impl.minutes_till_market_close() <= 15:
+        retry_minutes = broker_impl.minutes_till_market_open() or 60
+        logger.info(
+            f'Market not open or <= 15 minutes left in trading day, skipping signal generation for {retry_minutes} minutes'
+        )
+        return None
+
+    # signal generation
+    do, side, qty, symbol = generate_signal(bar_data, reversion_universe, adaptive_lookback)
+    hedge = None
+    # Paired stocks trade their sector spread when the outright signal is quiet
+    if not do and sector_pairs and bar_data['symbol'] in sector_pairs:
+        do, side, qty, symbol, hedge = generate_spread_signal(bar_data, sector_pairs[bar_data['symbol']])
+
+    order = None
+    # make sure signal said to move and that market is not about to close
+    if do:
+        metrics.record_symbol_event(symbol, 'signals')
+    if do and broker_impl.minutes_till_market_close() > 15:
+        # Deterministic ID so a redelivered signal is never submitted twice
+        client_order_id = broker.generate_client_order_id(
+            'reversion', symbol, bar_data['timestamp']
+        )
+        direction = 1 if side == Side.BUY else -1
+        current_qty = order_executor.state.positions.get(symbol, {}).get('qty', 0)
+        if position_sizer and not reversion_notional:
+            qty = abs(current_qty) if current_qty * direction < 0 else position_sizer.qty(
+                position_sizer.account_equity(broker_impl.get_account_equity), bar_data['close']
+            )
+        notional = None
+        if reversion_notional:
+            if current_qty * direction < 0:
+                # A dollar amount would leave a fractional remainder, exits close the position
+                qty = abs(current_qty)
+            elif direction < 0:
+                # Alpaca does not short fractional shares
+                qty = math.floor(reversion_notional / bar_data['close'])
+            else:
+                notional = reversion_notional
+        order = {
+            'symbol': symbol,
+            'side': side,
+            'qty': None if notional else direction * abs(qty),
+            'notional': direction * notional if notional else None,
+            'client_order_id': client_order_id,
+        }
+        signal = Signal(
+            strategy='reversion',
+            symbol=symbol,
+            side=side,
+            timestamp=datetime.fromisoformat(bar_data['timestamp']),
+            # A late exit still reduces risk, only entries go stale
+            ttl=timedelta(seconds=signal_ttl) if signal_ttl and current_qty * direction >= 0 else None
+        )
+        order['expires_at'] = signal.expires_at.isoformat() if signal.expires_at else None
+        # Signals from a backlog that just cleared are dropped rather than traded late
+        if not signal.is_valid(datetime.now(timezone.utc)):
+            logger.warning(f"{symbol} signal from {bar_data['timestamp']} expired at {order['expires_at']}, skipping")
+            metrics.increment('expired_signals', symbol=symbol)
+            order['placed'] = False
+        # Late entries are dropped when the latency budget is enforced
+        elif latency_budget and not latency_budget.check(symbol, bar_data['timestamp']):
+            order['placed'] = False
+        # The IV filter only blocks opening or adding to a position, exits always go through
+        elif iv_filter and current_qty * direction >= 0 and not iv_filter.allows(symbol):
+            order['placed'] = False
+        # Headlines pause entries the same way, measured at bar time so backtests agree
+        elif headline_guard and current_qty * direction >= 0 and headline_guard.is_paused(
+            symbol, datetime.fromisoformat(bar_data['timestamp'])
+        ):
+            logger.info(f'{symbol} paused after a headline, skipping entry')
+            order['placed'] = False
+        # Churning symbols and pairs sit out after a stop-out or too many round trips
+        elif trade_throttle and current_qty * direction >= 0 and not trade_throttle.allows(
+            symbol, datetime.fromisoformat(bar_data['timestamp'])
+        ):
+            order['placed'] = False
+        # Sizing an entry below one share skips it
+        elif not order['qty'] and not order['notional']:
+            order['placed'] = False
+        # Supervised deployments wait for an operator, the order is executed once released
+        elif supervisor:
+            order['placed'] = False
+            order['idea'] = supervisor.submit(
+                {key: value for key, value in order.items() if key != 'placed'},
+                context={'hedge': hedge, 'timestamp': bar_data['timestamp']}
+            )
+        else:
+            execute_order(order, bar_data['timestamp'], order_executor, hedge, trade_throttle, position_sizer)
+
+    # Make sure to liquidate all positions 15 minutes prior to market close
+    if broker_impl.minutes_till_market_close() <= 15:
+        order_executor.liquidate_all_positions()
+    return order
+
+
+def execute_order(
+    order: dict,
+    timestamp: str,
+    order_executor: strategy.OrderExecutor,
+    hedge: Optional[dict] = None,
+    trade_throttle: Optional[throttle.TradeThrottle] = None,
+    position_sizer: Optional[sizing.PositionSizer] = None
+) -> dict:
+    """
+    Submit an order built by handle_bar, then its ETF hedge when it opens a
+    hedged position, or the unwind of the ETF leg when it closes one.
+
+    Args:
+        order (dict): The order, see handle_bar. 'placed' is set on it.
+        timestamp (str): Timestamp of the bar the signal came from.
+        order_executor (strategy.OrderExecutor): Executor orders are submitted through.
+        hedge (Optional[dict], optional): The spread hedge from generate_spread_signal. Defaults to None.
+        trade_throttle (Optional[throttle.TradeThrottle], optional): Throttle round trips are
+                                                                     recorded with. Defaults to None.
+        position_sizer (Optional[sizing.PositionSizer], optional): Sizer closed trades are
+                                                                   recorded with. Defaults to None.
+
+    Returns:
+        dict: The order.
+    """
+    position = order_executor.state.positions.get(order['symbol'])
+    realized_pnl = order_executor.state.realized_pnl
+    if order['notional']:
+        # Size by dollar amount with fractional shares when configured
+        order['placed'] = order_executor.execute_notional_order(
+            symbol=order['symbol'],
+            notional=order['notional'],
+            client_order_id=order['client_order_id']
+        )
+    else:
+        order['placed'] = order_executor.execute_market_order(
+            symbol=order['symbol'],
+            qty=order['qty'],
+            client_order_id=order['client_order_id']
+        )
+    if order['placed']:
+        metrics.record_symbol_event(order['symbol'], 'orders')
+    # An order that flattens or flips the position completes a round trip
+    remaining = order_executor.state.positions.get(order['symbol'], {}).get('qty', 0)
+    if (trade_throttle or position_sizer) and order['placed'] and position and remaining * position['qty'] <= 0:
+        pnl = order_executor.state.realized_pnl - realized_pnl
+        entry_notional = abs(position['qty'] * position['entry_price'])
+        try:
+            entry_notional = fx.to_base(entry_notional, order['symbol'])
+        except ValueError:
+            # Realized P&L is kept unconverted without a rate, compare like with like
+            pass
+        if trade_throttle:
+            trade_throttle.record_round_trip(order['symbol'], pnl, entry_notional, datetime.fromisoformat(timestamp))
+        if position_sizer:
+            position_sizer.record_trade(pnl, entry_notional)
+    # Closing or flipping the stock leg unwinds the ETF leg opened with it, so no ETF position is left naked
+    if order['placed'] and position and remaining * position['qty'] <= 0:
+        unwound = unwind_hedge(order['symbol'], timestamp, order_executor)
+        if unwound:
+            order['unwound_hedge'] = unwound
+    # The ETF leg follows a placed entry from flat only, so a skipped entry is never left half hedged
+    if hedge and order['placed'] and not position:
+        order['hedge'] = execute_hedge(hedge, order, timestamp, order_executor)
+    return order
+
+
+def release_ideas(
+    supervisor: supervision.SignalQueue,
+    order_executor: strategy.OrderExecutor,
+    trade_throttle: Optional[throttle.TradeThrottle] = None,
+    position_sizer: Optional[sizing.PositionSizer] = None
+) -> list[dict]:
+    """
+    Execute the ideas operators approved or that timed out into approval,
+    skipping them when the market is closed or about to close.
+
+    Returns:
+        list[dict]: The orders attempted, see execute_order.
+    """
+    orders = []
+    for idea in supervisor.release():
+        order = dict(idea.order)
+        broker_impl = brokers.get_broker()
+        if not broker_impl.is_market_open() or broker_impl.minutes_till_market_close() <= 15:
+            logger.info(f'Market closing, dropping approved idea {idea.id}')
+            order['placed'] = False
+        # Approval does not extend a signal's validity window
+        elif order.get('expires_at') and datetime.now(timezone.utc) >= datetime.fromisoformat(order['expires_at']):
+            logger.warning(f"Idea {idea.id} expired at {order['expires_at']} before it was released, dropping it")
+            metrics.increment('expired_signals', symbol=order['symbol'])
+            order['placed'] = False
+        else:
+            execute_order(
+                order, idea.context['timestamp'], order_executor, idea.context.get('hedge'), trade_throttle, position_sizer
+            )
+        supervisor.record_execution(idea, order['placed'])
+        orders.append(order)
+    return orders
+
+
+def generate_spread_signal(message: dict, etf: str) -> tuple:
+    """
+    Bollinger band signal on the spread of a stock over its beta-hedged sector ETF.
+
+    Args:
+        message (dict): The stock's bar message, already added to the bar cache.
+        etf (str): The sector ETF the stock is paired with.
+
+    Returns:
+        tuple: (do, side, qty, symbol, hedge) where the first four are as in generate_signal()
+        for the stock leg, and hedge is { 'symbol', 'beta', 'price', 'stock_price' } for the
+        ETF leg, None without a signal.
+    """
+    series = market_data.get_market_data()
+    stock_bars = series.get_series(message['symbol'], '1Min', lookback=120)
+    etf_bars = series.get_series(etf, '1Min', lookback=120)
+    if not etf_bars:
+        return False, Side.BUY, 0, None, None
+    stock_closes, etf_closes = spreads.align(stock_bars[:-1], etf_bars)
+    # The ETF's bar for this minute may not have arrived yet, its latest close stands in
+    stock_closes.append(message['close'])
+    etf_closes.append(etf_bars[-1]['close'])
+    signal = spreads.spread_signal(stock_closes, etf_closes, SPREAD_BETA_WINDOW, BOLLINGER_WINDOW)
+    if not signal or not signal['direction']:
+        return False, Side.BUY, 0, None, None
+    metrics.set_gauge('spread_beta', signal['beta'], symbol=message['symbol'])
+    side = Side.BUY if signal['direction'] > 0 else Side.SELL
+    hedge = {'symbol': etf, 'beta': signal['beta'], 'price': etf_closes[-1], 'stock_price': message['close']}
+    return True, side, signal['direction'], message['symbol'], hedge
+
+
+def execute_hedge(hedge: dict, order: dict, timestamp: str, order_executor: strategy.OrderExecutor) -> dict:
+    """
+    Submits the ETF leg offsetting the beta-weighted exposure of a placed stock leg.
+    The leg is whole shares rounded toward zero, since the ETF is usually sold
+    short and short sales cannot be fractional, and is skipped below one share.
+
+    Args:
+        hedge (dict): The ETF leg from generate_spread_signal().
+        order (dict): The stock leg as returned by handle_bar().
+        timestamp (str): Timestamp of the bar that produced the signal.
+        order_executor (strategy.OrderExecutor): Executor orders are submitted through.
+
+    Returns:
+        dict: The ETF order as { 'symbol', 'side', 'qty', 'notional', 'client_order_id', 'placed' }.
+    """
+    # Keyed on the stock too, since stocks paired with the same ETF can signal on the same bar
+    client_order_id = broker.generate_client_order_id('reversion', f"{hedge['symbol']}-{order['symbol']}", timestamp)
+    # Dollar-sized stock legs are hedged on the shares their notional bought or sold
+    stock_qty = order['notional'] / hedge['stock_price'] if order['notional'] else order['qty']
+    qty = int(spreads.hedge_qty(stock_qty, hedge['stock_price'], hedge['price'], hedge['beta']))
+    hedge_order = {
+        'symbol': hedge['symbol'],
+        'side': Side.BUY if qty > 0 else Side.SELL,
+        'qty': qty,
+        'notional': None,
+        'client_order_id': client_order_id,
+        'placed': False,
+    }
+    if not qty:
+        logger.info(f"{order['symbol']} hedge is under one share of {hedge['symbol']}, skipping it")
+        return hedge_order
+    hedge_order['placed'] = order_executor.execute_market_order(
+        symbol=hedge['symbol'],
+        qty=qty,
+        client_order_id=client_order_id
+    )
+    if hedge_order['placed']:
+        metrics.record_symbol_event(hedge['symbol'], 'orders')
+        with order_executor.state.lock:
+            order_executor.state.hedges[order['symbol']] = {'symbol': hedge['symbol'], 'qty': qty}
+    return hedge_order
+
+
+def unwind_hedge(stock: str, timestamp: str, order_executor: strategy.OrderExecutor) -> Optional[dict]:
+    """
+    Closes the ETF leg opened against a stock position once the stock leg exits.
+    A leg that fails to close stays recorded, the liquidation before the close flattens it.
+
+    Args:
+        stock (str): The stock whose position closed.
+        timestamp (str): Timestamp of the bar the exit came from.
+        order_executor (strategy.OrderExecutor): Executor orders are submitted through.
+
+    Returns:
+        Optional[dict]: The ETF order as in execute_hedge(), None when the stock was not hedged.
+    """
+    with order_executor.state.lock:
+        leg = order_executor.state.hedges.pop(stock, None)
+    if leg is None:
+        return None
+    qty = -leg['qty']
+    client_order_id = broker.generate_client_order_id('reversion', f"{leg['symbol']}-{stock}-unwind", timestamp)
+    unwind_order = {
+        'symbol': leg['symbol'],
+        'side': Side.BUY if qty > 0 else Side.SELL,
+        'qty': qty,
+        'notional': None,
+        'client_order_id': client_order_id,
+        'placed': order_executor.execute_market_order(symbol=leg['symbol'], qty=qty, client_order_id=client_order_id),
+    }
+    if unwind_order['placed']:
+        metrics.record_symbol_event(leg['symbol'], 'orders')
+    else:
+        logger.error(f"Failed to unwind the {leg['qty']} {leg['symbol']} hedge of {stock}, it is left open")
+        metrics.increment('hedge_unwind_failures', symbol=leg['symbol'])
+        with order_executor.state.lock:
+            order_executor.state.hedges[stock] = leg
+    return unwind_order
+
+
+def generate_signal(
+    message: dict,
+    reversion_universe: list[str],
+    adaptive_lookback: Optional[lookback.AdaptiveLookback] = None
+):
     """
     Calculates a trading signal based on the provided market data message.
 
-    This function analyzes the high, open, close, low, symbol, and timestamp from the input message
-    to determine whether a trade should be executed. It currently prints the relevant market data
-    and returns default values for the trade signal.
+    The bar is added to the bar cache and its close compared with Bollinger Bands over the
+    symbol's recent closes. A close at or above the upper band signals a sell, at or below the
+    lower band a buy. Symbols outside the universe, or without a full window of closes yet,
+    signal nothing.
 
     Parameters:
     -----------
@@ -146,14 +845,16 @@ def generate_signal(message: dict, reversion_universe: list[str]):
         - 'timestamp' (str): The timestamp of the market data in ISO format.
     reversion_universe : str
         A universe related to this service
+    adaptive_lookback : Optional[lookback.AdaptiveLookback]
+        Bollinger window per symbol from its half-life, None for BOLLINGER_WINDOW
     Returns:
     --------
     tuple
         A tuple containing the following elements:
         - do (bool): A flag indicating whether to execute the trade. Default is False.
-        - side (OrderSide): The side of the trade (BUY or SELL). Default is OrderSide.BUY.
-        - qty (int): The quantity of the trade. Default is 1.
-        - symbol (int): The trading symbol. Default is None
+        - side (Side): The side of the trade (BUY or SELL). Default is Side.BUY.
+        - qty (int): 1 to buy, -1 to sell, 0 without a signal.
+        - symbol (str): The trading symbol, None without a signal.
 
     Example:
     --------
@@ -161,41 +862,39 @@ def generate_signal(message: dict, reversion_universe: list[str]):
         'high': 383.89,
         'open': 383.495,
         'low': 383.49,
+        'close': 383.5,
         'symbol': 'TSLA',
         'timestamp': '2025-02-03T19:36:00+00:00'
-        'tradecount': 25
     }
-    generate_signal(message)
-    (False, OrderSide.BUY, 5, 'AAPL)
+    generate_signal(message, ['TSLA'])
+    (False, Side.BUY, 0, None)
     """
-    side = OrderSide.BUY
+    side = Side.BUY
     qty = 0
     symbol = None
     do = False
     # ensure the symbol is in the strategy universe, will add SQS filter policy at a later date
     if message['symbol'] in reversion_universe:
-        end_time = datetime.now().replace(minute=0, second=0, microsecond=0)
-        start_time = end_time - timedelta(hours=2)  # Ensure enough bars
-        print(start_time, end_time)
-        data = broker.get_historical_bar_data(
-            symbols=message['symbol'],
-            start_date=start_time,
-            end_date=end_time,
-            timeframe=TimeFrame.Minute,
-            limit=None
-        )[message['symbol']]  # extract the symbol of concern
-
-        close_prices = broker.extract_close_data(data)
-        bands = statistics.bollinger_bands(close_prices, 20)
+        # Live bars are shared with other strategies in this process through the bar cache
+        bar_cache.get_bar_cache().add(message)
+        # History is fetched on first use, so the series is continuous from the first signal
+        close_prices = market_data.get_market_data().closes(message['symbol'], '1Min', lookback=120)
+        window = BOLLINGER_WINDOW
+        if adaptive_lookback:
+            window = adaptive_lookback.window(message['symbol'], close_prices, datetime.fromisoformat(message['timestamp']))
+        # No signal until the window is full, matching the backtest warm-up
+        if len(close_prices) < window:
+            return do, side, qty, symbol
+        bands = statistics.bollinger_bands(close_prices, window)
 
         if message['close'] >= bands['upper_band'][-1]:
             do = True
             symbol = message['symbol']
             qty = -1
-            side = OrderSide.SELL
+            side = Side.SELL
         elif message['close'] <= bands['lower_band'][-1]:
             do = True
             symbol = message['symbol']
             qty = 1
-            side = OrderSide.BUY
+            side = Side.BUY
     return do, side, qty, symbol
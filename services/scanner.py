@@ -0,0 +1,57 @@
+import os
+from helpers import logger, scanner
+
+logger = logger.Logger('scanner.py')
+
+
+def _optional_float(name: str):
+    value = os.getenv(name)
+    return float(value) if value else None
+
+
+def run() -> None:
+    """
+    Scans a universe for cointegrated pairs, and optionally triples, once and
+    writes the ranked results to a file and/or an SNS topic.
+
+    Environment Variables:
+        SCANNER_UNIVERSE: Comma separated symbols to scan.
+        SCANNER_LOOKBACK_DAYS: Days of history to test on. Defaults to 30.
+        SCANNER_TIMEFRAME: Bar timeframe. Defaults to 1Hour.
+        SCANNER_OUTPUT_FILE: JSON file the results are written to.
+        SCANNER_SNS: SNS topic the results are published to.
+        SCANNER_TRIPLES: 'True' to also Johansen test every triple.
+        SCANNER_BAD_TICK_SIGMA: Remove reverted jumps of this many sigma before testing, unset to keep every print.
+        SCANNER_MAX_P_VALUE: Highest Engle-Granger p-value after correction. Defaults to 0.05.
+        SCANNER_P_VALUE_CORRECTION: bonferroni or fdr_bh to correct p-values for the pairs tested, none to
+            compare raw p-values. Defaults to fdr_bh.
+        SCANNER_MAX_HALF_LIFE: Longest spread half-life in bars, unset for no limit.
+        SCANNER_MAX_HURST: Highest spread Hurst exponent. Defaults to 0.5.
+        SCANNER_MIN_CORRELATION: Only test pairs with correlated returns, unset to test every pair.
+        SCANNER_REGRESSION: Hedge ratio fit, ols, theil_sen, or huber. Defaults to ols.
+        SCANNER_CONTROL_SQS_URL: Data service control queue the symbols found are subscribed through.
+        SCANNER_COMPRESSION: gzip or zstd to compress results published to SCANNER_SNS, unset to publish them as is.
+    """
+    universe = os.getenv('SCANNER_UNIVERSE').split(',')
+    logger.info(f'Starting scanner over {len(universe)} symbols.')
+    results = scanner.scan(
+        universe,
+        lookback_days=int(os.getenv('SCANNER_LOOKBACK_DAYS', 30)),
+        timeframe=os.getenv('SCANNER_TIMEFRAME', '1Hour'),
+        triples=os.getenv('SCANNER_TRIPLES') == 'True',
+        bad_tick_sigma=_optional_float('SCANNER_BAD_TICK_SIGMA'),
+        max_p_value=float(os.getenv('SCANNER_MAX_P_VALUE', 0.05)),
+        max_half_life=_optional_float('SCANNER_MAX_HALF_LIFE'),
+        max_hurst=float(os.getenv('SCANNER_MAX_HURST', 0.5)),
+        min_correlation=_optional_float('SCANNER_MIN_CORRELATION'),
+        regression=os.getenv('SCANNER_REGRESSION', 'ols'),
+        correction=None if os.getenv('SCANNER_P_VALUE_CORRECTION') == 'none' else os.getenv('SCANNER_P_VALUE_CORRECTION', 'fdr_bh'),
+    )
+    logger.info(f"Found {len(results['pairs'])} pairs and {len(results['triples'])} triples.")
+    scanner.publish(
+        results,
+        path=os.getenv('SCANNER_OUTPUT_FILE'),
+        topic=os.getenv('SCANNER_SNS'),
+        control_queue=os.getenv('SCANNER_CONTROL_SQS_URL'),
+        compression=os.getenv('SCANNER_COMPRESSION') or None,
+    )
@@ -4,5 +4,6 @@ from setuptools import setup, find_packages
 setup(
     name="Nexus",
     version="0.1",
-    packages=find_packages(),
+    # api, helpers, and services install as top-level packages, the api imports helpers by that name
+    packages=find_packages(exclude=['test', 'test.*']),
 )
@@ -0,0 +1,231 @@
+"""
+End-to-end integration harness for Data -> Reversion -> execution.
+
+A recorded session is streamed by a MockBroker through the Data service's
+bar handler, which publishes every bar to the data topic. The Reversion
+consumer drains its queue exactly as the service loop does, runs each bar
+through handle_bar, and executes against the same MockBroker. The orders
+the broker received are compared with the expected orders of the session.
+Not collected by pytest, run it from the repo root:
+
+    python -m test.integration --bars test/data/reversion_session.jsonl \\
+        --expected test/data/reversion_session_orders.json
+
+The topic and queue are an in-memory bus by default. With --localstack
+they are real SNS and SQS resources in LocalStack instead:
+
+    docker compose -f docker-compose.integration.yml up -d
+    python -m test.integration --bars test/data/reversion_session.jsonl \\
+        --expected test/data/reversion_session_orders.json --localstack http://localhost:4566
+
+Each line of the recording is a bar message as published by the data service
+(symbol, timestamp, open, high, low, close, volume, trade_count). The expected
+orders are a JSON list of { 'symbol', 'side', 'qty', 'client_order_id' }.
+"""
+import os
+import sys
+import json
+import asyncio
+import argparse
+from itertools import count
+from helpers import bar_cache, brokers, cloud, envelope, gaps, market_data, strategy
+from services import data, reversion
+
+# Names the harness runs the services under
+TOPIC = 'integration-data'
+QUEUE = 'integration-reversion'
+
+
+class InMemoryBus:
+    """Stands in for SNS and SQS, fanning topic messages out to subscribed
+    queues wrapped in the SNS notification envelope the consumers unwrap."""
+
+    def __init__(self):
+        self.subscriptions = {}
+        self.queues = {}
+        self._ids = count(1)
+
+    def subscribe(self, queue_url: str, topic: str) -> None:
+        self.subscriptions.setdefault(topic, []).append(queue_url)
+        self.queues.setdefault(queue_url, [])
+
+    def publish(self, message: str, topic: str) -> dict:
+        message_id = str(next(self._ids))
+        for queue_url in self.subscriptions.get(topic, []):
+            self.queues[queue_url].append({
+                'MessageId': message_id,
+                'ReceiptHandle': f'{queue_url}-{message_id}',
+                'Body': json.dumps({'Type': 'Notification', 'TopicArn': topic, 'Message': message}),
+                'Attributes': {},
+            })
+        return {'MessageId': message_id}
+
+    def receive(self, queue_url: str, max_messages: int = 1, wait_time_seconds: int = 10) -> list:
+        return list(self.queues.get(queue_url, [])[:max_messages])
+
+    def delete(self, queue_url: str, receipt_handle: str) -> None:
+        self.queues[queue_url] = [
+            message for message in self.queues[queue_url] if message['ReceiptHandle'] != receipt_handle
+        ]
+
+
+def install_bus(bus: InMemoryBus) -> dict:
+    """
+    Route the cloud helpers through the in-memory bus.
+
+    Returns:
+        dict: The replaced functions, for restore_cloud().
+    """
+    replaced = {
+        name: getattr(cloud, name)
+        for name in ('publish_sns_message', 'poll_sqs_message', 'delete_sqs_message')
+    }
+    cloud.publish_sns_message = bus.publish
+    cloud.poll_sqs_message = bus.receive
+    cloud.delete_sqs_message = bus.delete
+    return replaced
+
+
+def restore_cloud(replaced: dict) -> None:
+    for name, function in replaced.items():
+        setattr(cloud, name, function)
+
+
+def create_localstack_resources(endpoint: str) -> tuple[str, str]:
+    """
+    Create the data topic and reversion queue in LocalStack and subscribe them.
+
+    Returns:
+        tuple[str, str]: The topic ARN and queue URL.
+    """
+    os.environ.setdefault('AWS_ACCESS_KEY_ID', 'test')
+    os.environ.setdefault('AWS_SECRET_ACCESS_KEY', 'test')
+    os.environ.setdefault('REGION', 'us-east-1')
+    os.environ['AWS_ENDPOINT_URL'] = endpoint
+    cloud.aws_clients = None
+    topic_arn = cloud.get_client('sns').create_topic(Name=TOPIC)['TopicArn']
+    sqs = cloud.get_client('sqs')
+    queue_url = sqs.create_queue(QueueName=QUEUE)['QueueUrl']
+    queue_arn = sqs.get_queue_attributes(QueueUrl=queue_url, AttributeNames=['QueueArn'])['Attributes']['QueueArn']
+    cloud.subscribe_sqs_to_sns(queue_arn=queue_arn, topic_arn=topic_arn)
+    return topic_arn, queue_url
+
+
+def load_bars(path: str) -> list:
+    """
+    Load a recording of bar messages sorted by timestamp.
+    """
+    with open(path) as file:
+        bars = [json.loads(line) for line in file if line.strip()]
+    return sorted(bars, key=lambda bar: bar['timestamp'])
+
+
+def consume(queue_url: str, universe: list, executor: strategy.OrderExecutor) -> int:
+    """
+    Drain the reversion queue the way the service loop does, returning the messages handled.
+    """
+    handled = 0
+    while True:
+        messages = cloud.poll_sqs_message(queue_url=queue_url, max_messages=10, wait_time_seconds=1)
+        if not messages:
+            return handled
+        for message in messages:
+            bar = envelope.decode_sqs(message)['payload']
+            cloud.delete_sqs_message(queue_url=queue_url, receipt_handle=message['ReceiptHandle'])
+            reversion.handle_bar(bar, universe, executor)
+            handled += 1
+
+
+def run_session(bars: list, localstack: str = None) -> list:
+    """
+    Stream a recorded session through Data, the bus, and Reversion.
+
+    Args:
+        bars (list): The recorded bar messages.
+        localstack (str, optional): LocalStack endpoint, None for the in-memory bus.
+
+    Returns:
+        list: The orders the broker received as { 'symbol', 'side', 'qty', 'client_order_id' }.
+    """
+    # No stored history, the strategy only sees bars as they arrive on the queue
+    mock = brokers.MockBroker(cash=1_000_000)
+    universe = sorted({bar['symbol'] for bar in bars})
+    replaced = None
+    previous_env = {'DATA_SNS': os.environ.get('DATA_SNS')}
+    brokers.set_broker(mock)
+    bar_cache.shared_cache = bar_cache.BarCache()
+    market_data.shared_market_data = market_data.MarketData(bar_cache.shared_cache)
+    data.gap_detector = gaps.GapDetector()
+    try:
+        if localstack:
+            topic, queue_url = create_localstack_resources(localstack)
+        else:
+            bus = InMemoryBus()
+            topic, queue_url = TOPIC, QUEUE
+            bus.subscribe(queue_url, topic)
+            replaced = install_bus(bus)
+        os.environ['DATA_SNS'] = topic
+        state = strategy.TradingStateManager(logger=reversion.logger)
+        executor = strategy.OrderExecutor(state, strategy.RiskManager(state))
+
+        # Bars are consumed minute by minute, as the live services interleave
+        for timestamp in sorted({bar['timestamp'] for bar in bars}):
+            for bar in [bar for bar in bars if bar['timestamp'] == timestamp]:
+                mock.set_price(bar['symbol'], bar['close'])
+                asyncio.run(data.bar_handler(dict(bar)))
+            consume(queue_url, universe, executor)
+        return [
+            {
+                'symbol': order['symbol'],
+                'side': order['side'].value,
+                'qty': order['qty'],
+                'client_order_id': order.get('client_order_id'),
+            }
+            for order in mock.orders
+        ]
+    finally:
+        if replaced:
+            restore_cloud(replaced)
+        for name, value in previous_env.items():
+            if value is None:
+                os.environ.pop(name, None)
+            else:
+                os.environ[name] = value
+        brokers.set_broker(None)
+        bar_cache.shared_cache = None
+        market_data.shared_market_data = None
+
+
+def diff_orders(expected: list, actual: list) -> list:
+    """
+    Compare two order lists position by position.
+
+    Returns:
+        list: (index, expected, actual) for every position where they differ.
+    """
+    return [
+        (index, expected[index] if index < len(expected) else None, actual[index] if index < len(actual) else None)
+        for index in range(max(len(expected), len(actual)))
+        if index >= len(expected) or index >= len(actual) or expected[index] != actual[index]
+    ]
+
+
+def main() -> int:
+    parser = argparse.ArgumentParser(description='Run Data -> Reversion -> execution on a recorded session.')
+    parser.add_argument('--bars', required=True, help='JSONL recording of bar messages')
+    parser.add_argument('--expected', required=True, help='JSON list of the expected orders')
+    parser.add_argument('--localstack', help='LocalStack endpoint, e.g. http://localhost:4566')
+    args = parser.parse_args()
+
+    with open(args.expected) as file:
+        expected = json.load(file)
+    actual = run_session(load_bars(args.bars), args.localstack)
+    differences = diff_orders(expected, actual)
+    for index, expected_order, actual_order in differences:
+        print(f'ORDER {index}: expected={expected_order} actual={actual_order}')
+    print(f'{len(expected)} expected orders, {len(actual)} orders, {len(differences)} differences')
+    return 1 if differences else 0
+
+
+if __name__ == '__main__':
+    sys.exit(main())
@@ -0,0 +1,111 @@
+"""
+Backtest-to-live parity harness for the Reversion strategy.
+
+Feeds the same recorded day of bars through the backtester and through the
+live strategy code path (reversion.handle_bar against a MockBroker, so no
+orders leave the process) and diffs the trades each one makes. Any
+difference means the simulated and production code paths have diverged.
+Not collected by pytest, run it from the repo root:
+
+    python -m test.parity --bars recorded_day.jsonl
+
+Each line of the recording is a bar message as published by the data service
+(symbol, timestamp, open, high, low, close, volume, trade_count).
+"""
+import sys
+import json
+import argparse
+from helpers import backtest, bar_cache, brokers, market_data, strategy
+from services import reversion
+
+
+def load_bars(path: str) -> list:
+    """
+    Load a recording of bar messages sorted by timestamp.
+    """
+    with open(path) as file:
+        bars = [json.loads(line) for line in file if line.strip()]
+    return sorted(bars, key=lambda bar: bar['timestamp'])
+
+
+def backtest_trades(bars: list, qty: float) -> list:
+    """
+    Run the backtester per symbol, returning trades as { 'symbol', 'timestamp', 'qty' }.
+    """
+    trades = []
+    for symbol in sorted({bar['symbol'] for bar in bars}):
+        symbol_bars = [bar for bar in bars if bar['symbol'] == symbol]
+        result = backtest.backtest_bollinger_reversion(
+            [bar['close'] for bar in symbol_bars],
+            window=reversion.BOLLINGER_WINDOW,
+            qty=qty
+        )
+        trades.extend(
+            {'symbol': symbol, 'timestamp': symbol_bars[trade['index']]['timestamp'], 'qty': trade['qty']}
+            for trade in result['trades']
+        )
+    return trades
+
+
+def live_trades(bars: list) -> list:
+    """
+    Run the live strategy code path in dry-run against a MockBroker filling at each bar's close.
+    """
+    mock = brokers.MockBroker(cash=1_000_000)
+    # Fresh caches and no stored history, so both paths start from the same bars
+    brokers.set_broker(mock)
+    bar_cache.shared_cache = bar_cache.BarCache()
+    market_data.shared_market_data = market_data.MarketData(bar_cache.shared_cache)
+    state = strategy.TradingStateManager(logger=reversion.logger)
+    executor = strategy.OrderExecutor(state, strategy.RiskManager(state))
+    universe = sorted({bar['symbol'] for bar in bars})
+    trades = []
+    try:
+        for bar in bars:
+            mock.set_price(bar['symbol'], bar['close'])
+            order = reversion.handle_bar(bar, universe, executor)
+            if order and order['placed']:
+                trades.append({'symbol': order['symbol'], 'timestamp': bar['timestamp'], 'qty': order['qty']})
+    finally:
+        brokers.set_broker(None)
+        bar_cache.shared_cache = None
+        market_data.shared_market_data = None
+    return trades
+
+
+def diff_trades(expected: list, actual: list) -> list:
+    """
+    Compare two trade lists keyed by symbol and timestamp.
+
+    Returns:
+        list: { 'symbol', 'timestamp', 'backtest', 'live' } for every bar where
+        the quantities traded differ, None where a path did not trade.
+    """
+    backtested = {(trade['symbol'], trade['timestamp']): trade['qty'] for trade in expected}
+    live = {(trade['symbol'], trade['timestamp']): trade['qty'] for trade in actual}
+    return [
+        {'symbol': key[0], 'timestamp': key[1], 'backtest': backtested.get(key), 'live': live.get(key)}
+        for key in sorted(set(backtested) | set(live))
+        if backtested.get(key) != live.get(key)
+    ]
+
+
+def main() -> int:
+    parser = argparse.ArgumentParser(description='Diff Reversion backtest and live trades on a recorded day.')
+    parser.add_argument('--bars', required=True, help='JSONL recording of bar messages')
+    args = parser.parse_args()
+
+    bars = load_bars(args.bars)
+    # The live path trades one share per signal
+    expected = backtest_trades(bars, qty=1)
+    actual = live_trades(bars)
+    mismatches = diff_trades(expected, actual)
+    for mismatch in mismatches:
+        print(f"MISMATCH {mismatch['symbol']} {mismatch['timestamp']}: "
+              f"backtest={mismatch['backtest']} live={mismatch['live']}")
+    print(f'{len(expected)} backtest trades, {len(actual)} live trades, {len(mismatches)} mismatches')
+    return 1 if mismatches else 0
+
+
+if __name__ == '__main__':
+    sys.exit(main())
@@ -0,0 +1,147 @@
+"""
+Soak-test harness for the Data service.
+
+Replays recorded bars through the live bar handler at an accelerated speed,
+with SNS publishing swapped for an in-memory sink, and checks that over a
+long run no message is lost, memory stays bounded, and handler-to-publish
+latency does not drift. Not collected by pytest, run it from the repo root:
+
+    python -m test.soak --ticks recorded_bars.jsonl --speed 50 --hours 3
+
+Each line of the recording is a bar message as published by the data service
+(symbol, timestamp, open, high, low, close, volume, trade_count).
+"""
+import os
+import sys
+import json
+import time
+import asyncio
+import argparse
+import tracemalloc
+from datetime import datetime
+from helpers import envelope
+from services import data
+
+
+class Sink:
+    """Stands in for SNS, recording what was published and how long it took."""
+
+    def __init__(self):
+        self.received = 0
+        self.latencies = []
+        self.sent_at = {}
+
+    def publish(self, message: str, topic: str) -> dict:
+        bar = envelope.decode(message)['payload']
+        key = (bar['symbol'], bar['timestamp'])
+        self.latencies.append(time.perf_counter() - self.sent_at.pop(key, time.perf_counter()))
+        self.received += 1
+        return {'MessageId': str(self.received)}
+
+
+def load_ticks(path: str) -> list:
+    """
+    Load a recording of bar messages sorted by timestamp.
+    """
+    with open(path) as file:
+        ticks = [json.loads(line) for line in file if line.strip()]
+    return sorted(ticks, key=lambda tick: tick['timestamp'])
+
+
+def percentile(values: list, pct: float) -> float:
+    """
+    Nearest-rank percentile, 0 for an empty list.
+    """
+    if not values:
+        return 0.0
+    ordered = sorted(values)
+    return ordered[min(len(ordered) - 1, int(len(ordered) * pct / 100))]
+
+
+async def replay(ticks: list, sink: Sink, speed: float, hours: float, report_every: float) -> dict:
+    """
+    Replay the recording in a loop until the run duration elapses.
+
+    Returns:
+        dict: The sent/received counts, memory samples, and p99 latency per report window.
+    """
+    sent = 0
+    memory_samples = []
+    latency_windows = []
+    deadline = time.monotonic() + hours * 3600
+    next_report = time.monotonic() + report_every
+    loop_number = 0
+    while time.monotonic() < deadline:
+        previous = None
+        for tick in ticks:
+            timestamp = datetime.fromisoformat(tick['timestamp'])
+            if previous is not None and speed > 0:
+                await asyncio.sleep(max((timestamp - previous).total_seconds(), 0) / speed)
+            previous = timestamp
+            # Unique timestamps per loop so every published bar can be matched to its send
+            bar = {**tick, 'timestamp': timestamp.replace(microsecond=loop_number % 1_000_000).isoformat()}
+            sink.sent_at[(bar['symbol'], bar['timestamp'])] = time.perf_counter()
+            await data.bar_handler(bar)
+            sent += 1
+            if time.monotonic() >= next_report:
+                next_report += report_every
+                current, _ = tracemalloc.get_traced_memory()
+                memory_samples.append(current)
+                latency_windows.append(percentile(sink.latencies, 99))
+                sink.latencies.clear()
+                print(f'sent={sent} received={sink.received} memory={current / 1e6:.1f}MB '
+                      f'p99={latency_windows[-1] * 1000:.2f}ms', flush=True)
+            if time.monotonic() >= deadline:
+                break
+        loop_number += 1
+    if sink.latencies:
+        latency_windows.append(percentile(sink.latencies, 99))
+    return {'sent': sent, 'memory_samples': memory_samples, 'latency_windows': latency_windows}
+
+
+def check(result: dict, sink: Sink, max_memory_growth: float, max_latency_growth: float) -> list:
+    """
+    Compare a finished run against the soak criteria.
+
+    Returns:
+        list: A description of every failed criterion, empty if the run passed.
+    """
+    failures = []
+    if sink.received != result['sent']:
+        failures.append(f"message loss: sent {result['sent']} received {sink.received}")
+    samples = result['memory_samples']
+    # The first sample includes warm-up allocations, growth is measured from it
+    if len(samples) >= 2 and samples[-1] > samples[0] * max_memory_growth:
+        failures.append(f'memory grew from {samples[0] / 1e6:.1f}MB to {samples[-1] / 1e6:.1f}MB')
+    windows = result['latency_windows']
+    if len(windows) >= 2 and windows[0] > 0 and windows[-1] > windows[0] * max_latency_growth:
+        failures.append(f'p99 latency drifted from {windows[0] * 1000:.2f}ms to {windows[-1] * 1000:.2f}ms')
+    return failures
+
+
+def main() -> int:
+    parser = argparse.ArgumentParser(description='Soak test the Data service bar handler.')
+    parser.add_argument('--ticks', required=True, help='JSONL recording of bar messages')
+    parser.add_argument('--speed', type=float, default=10, help='Replay speed multiple, 0 for as fast as possible')
+    parser.add_argument('--hours', type=float, default=1, help='Run duration in hours')
+    parser.add_argument('--report-every', type=float, default=60, help='Seconds between progress reports')
+    parser.add_argument('--max-memory-growth', type=float, default=1.5, help='Allowed final/first memory ratio')
+    parser.add_argument('--max-latency-growth', type=float, default=3, help='Allowed final/first p99 latency ratio')
+    args = parser.parse_args()
+
+    os.environ.setdefault('DATA_SNS', 'soak-test')
+    sink = Sink()
+    data.cloud.publish_sns_message = sink.publish
+    # Gaps in the recording must not trigger backfills against a real broker
+    data.brokers.set_broker(data.brokers.MockBroker())
+    tracemalloc.start()
+    result = asyncio.run(replay(load_ticks(args.ticks), sink, args.speed, args.hours, args.report_every))
+    failures = check(result, sink, args.max_memory_growth, args.max_latency_growth)
+    for failure in failures:
+        print(f'FAIL: {failure}')
+    print('Soak test passed' if not failures else 'Soak test failed')
+    return 1 if failures else 0
+
+
+if __name__ == '__main__':
+    sys.exit(main())
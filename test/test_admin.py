@@ -0,0 +1,42 @@
+import json
+import pytest
+import urllib.request
+from urllib.error import HTTPError
+from nexus.helpers import admin
+
+
+@pytest.fixture
+def server(monkeypatch):
+    monkeypatch.setenv('ADMIN_TOKENS', 'dana:secret-1,ops:secret-2')
+    monkeypatch.delenv('ADMIN_HOST', raising=False)
+    admin.register_route('/test/echo', lambda query, body, identity: {'identity': identity, 'body': body}, method='POST')
+    server = admin.start_admin_server(port=0)
+    yield server
+    server.shutdown()
+    del admin.routes[('POST', '/test/echo')]
+
+
+def _post(server, token=None):
+    headers = {'Content-Type': 'application/json'}
+    if token:
+        headers['Authorization'] = f'Bearer {token}'
+    data = json.dumps({'operator': 'mallory'}).encode()
+    request = urllib.request.Request(f'http://127.0.0.1:{server.server_port}/test/echo', data=data, headers=headers)
+    with urllib.request.urlopen(request) as response:
+        return json.load(response)
+
+
+def test_post_routes_require_a_token_and_get_its_name(server):
+    for token in (None, 'wrong'):
+        with pytest.raises(HTTPError) as error:
+            _post(server, token)
+        assert error.value.code == 401
+    assert _post(server, 'secret-2') == {'identity': 'ops', 'body': {'operator': 'mallory'}}
+    with urllib.request.urlopen(f'http://127.0.0.1:{server.server_port}/health') as response:
+        assert json.load(response) == {'status': 'ok'}
+
+
+def test_server_listens_on_every_interface_by_default(server):
+    assert server.server_address[0] == '0.0.0.0'
+    assert admin.authenticate('Basic secret-1') is None
+    assert admin.authenticate('Bearer secret-1') == 'dana'
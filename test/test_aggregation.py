@@ -0,0 +1,43 @@
+import pytest
+from datetime import datetime, timezone
+from nexus.helpers import aggregation
+
+
+def trade(second: float, price: float, size: float, symbol: str = 'AAPL') -> dict:
+    timestamp = datetime(2024, 1, 2, 15, 30, tzinfo=timezone.utc).timestamp() + second
+    return {'symbol': symbol, 'timestamp': datetime.fromtimestamp(timestamp, timezone.utc).isoformat(), 'price': price, 'size': size}
+
+
+def test_parse_spec():
+    assert aggregation.parse_spec('5s') == {'kind': 'time', 'size': 5, 'timeframe': '5Sec'}
+    assert aggregation.parse_spec('2m')['size'] == 120
+    assert aggregation.parse_spec('dollar:1000000') == {'kind': 'dollar', 'size': 1_000_000, 'timeframe': 'Dollar1000000'}
+    assert [spec['timeframe'] for spec in aggregation.parse_specs('15s, volume:500')] == ['15Sec', 'Volume500']
+    for spec in ('7s', '0s', 'ticks:10', 'volume:-1'):
+        with pytest.raises(ValueError):
+            aggregation.parse_spec(spec)
+
+
+def test_time_bars_close_on_the_next_interval_or_the_clock():
+    aggregator = aggregation.TradeBarAggregator(aggregation.parse_specs('5s'), grace_seconds=1)
+    assert aggregator.on_trade(trade(0.5, 100.0, 10)) == []
+    assert aggregator.on_trade(trade(3, 101.0, 30)) == []
+    [bar] = aggregator.on_trade(trade(6, 99.0, 5))
+    assert bar['timestamp'] == '2024-01-02T15:30:00+00:00' and bar['timeframe'] == '5Sec' and bar['synthetic']
+    assert (bar['open'], bar['high'], bar['low'], bar['close']) == (100.0, 101.0, 100.0, 101.0)
+    assert bar['volume'] == 40 and bar['trade_count'] == 2 and bar['vwap'] == pytest.approx(100.75)
+    assert aggregator.on_trade(trade(4, 98.0, 1)) == []
+    assert aggregator.close_due(datetime(2024, 1, 2, 15, 30, 10, 500_000, tzinfo=timezone.utc)) == []
+    [bar] = aggregator.close_due(datetime(2024, 1, 2, 15, 30, 11, tzinfo=timezone.utc))
+    assert bar['timestamp'] == '2024-01-02T15:30:05+00:00' and bar['volume'] == 5
+
+
+def test_threshold_bars_close_on_the_trade_that_fills_them():
+    aggregator = aggregation.TradeBarAggregator(aggregation.parse_specs('volume:100,dollar:5000'))
+    assert aggregator.on_trade(trade(0, 10.0, 60)) == []
+    [volume_bar] = aggregator.on_trade(trade(1, 11.0, 50))
+    assert volume_bar['timeframe'] == 'Volume100' and volume_bar['volume'] == 110 and volume_bar['close'] == 11.0
+    assert aggregator.on_trade(trade(2, 12.0, 10, symbol='MSFT')) == []
+    [dollar_bar] = aggregator.on_trade(trade(3, 50.0, 80))
+    assert dollar_bar['timeframe'] == 'Dollar5000' and dollar_bar['trade_count'] == 3
+    assert aggregator.close_due(datetime(2025, 1, 1, tzinfo=timezone.utc)) == []
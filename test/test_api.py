@@ -0,0 +1,42 @@
+import json
+import inspect
+from nexus import api
+
+
+def test_every_exported_name_resolves():
+    assert api.API_VERSION.split('.')[0] == '1'
+    for module in (api.backtest, api.broker, api.bus, api.indicators, api.statistics):
+        for name in module.__all__:
+            assert getattr(module, name) is not None, f'{module.__name__}.{name}'
+
+
+def test_signatures_are_stable():
+    # Changing these breaks library users and requires a new major API_VERSION
+    expected = {
+        api.statistics.garch: ['returns'],
+        api.statistics.least_squares: ['X', 'Y'],
+        api.statistics.johansen_test: ['data', 'det_order', 'k_ar_diff', 'significance'],
+        api.indicators.bollinger_bands: ['data', 'window', 'num_std'],
+        api.backtest.run_parameter_grid: ['backtest_fn', 'grid', 'max_workers', 'fixed_kwargs'],
+        api.bus.publish: ['data', 'topic'],
+        api.bus.receive: ['queue_url', 'max_messages', 'wait_time_seconds'],
+        api.broker.set_broker: ['new_broker'],
+    }
+    for function, parameters in expected.items():
+        assert list(inspect.signature(function).parameters) == parameters, function.__name__
+
+
+def test_bus_unwraps_the_sns_envelope():
+    message = {'Body': json.dumps({'Type': 'Notification', 'Message': json.dumps({'symbol': 'AAPL'})})}
+    assert api.bus.unwrap(message) == {'symbol': 'AAPL'}
+
+
+def test_broker_example_trades_against_a_mock():
+    mock = api.broker.MockBroker(cash=100_000)
+    mock.set_price('AAPL', 185.0)
+    api.broker.set_broker(mock)
+    try:
+        assert api.broker.get_broker().submit_market_order('AAPL', 10, api.broker.OrderSide.BUY) == 185.0
+        assert mock.positions == {'AAPL': 10.0}
+    finally:
+        api.broker.set_broker(None)
@@ -0,0 +1,35 @@
+import gzip
+import json
+import pytest
+from nexus.helpers import archive
+
+
+def _bar(symbol, minute, day='2024-01-02'):
+    return {'symbol': symbol, 'timestamp': f'{day}T15:{minute:02d}:00+00:00', 'close': 100.0 + minute}
+
+
+def test_partition_key_layout():
+    key = archive.partition_key('s3://bucket/market/', 'bars', 'BTC/USD', '2024-01-02')
+    assert key == 's3://bucket/market/type=bars/date=2024-01-02/symbol=BTC-USD'
+
+
+def test_sink_writes_full_partitions_and_flushes_the_rest():
+    written = []
+    sink = archive.ArchiveSink('s3://bucket/market', max_records=2, write=lambda uri, body, _: written.append((uri, body)))
+    sink.record('bars', _bar('AAPL', 30))
+    sink.record('bars', _bar('MSFT', 30))
+    assert written == []
+    sink.record('bars', _bar('AAPL', 31))
+    assert len(written) == 1
+    uri, body = written[0]
+    assert uri.startswith('s3://bucket/market/type=bars/date=2024-01-02/symbol=AAPL/') and uri.endswith('.jsonl.gz')
+    assert [json.loads(line) for line in gzip.decompress(body).decode().splitlines()] == [_bar('AAPL', 30), _bar('AAPL', 31)]
+    sink.flush()
+    assert '/symbol=MSFT/' in written[1][0] and sink.buffers == {}
+
+
+def test_sink_rejects_unknown_types_and_formats():
+    with pytest.raises(ValueError):
+        archive.ArchiveSink('s3://bucket/market', fmt='csv')
+    with pytest.raises(ValueError):
+        archive.ArchiveSink('s3://bucket/market', write=lambda *_: {}).record('news', _bar('AAPL', 30))
@@ -0,0 +1,41 @@
+import asyncio
+from datetime import datetime, timezone
+from types import SimpleNamespace
+from nexus.helpers import asset_streams
+
+
+class FakeStream:
+    def __init__(self, *args, **kwargs):
+        self.handlers = {}
+
+    def subscribe_quotes(self, handler, *symbols):
+        self.handlers['quotes'] = (handler, symbols)
+
+    def subscribe_trades(self, handler, *symbols):
+        self.handlers['trades'] = (handler, symbols)
+
+    def run(self):
+        now = datetime(2024, 1, 2, 15, 30, tzinfo=timezone.utc)
+        quote = SimpleNamespace(symbol='AAPL240119C00190000', timestamp=now, bid_price=1.1, bid_size=5, ask_price=1.2, ask_size=7)
+        trade = SimpleNamespace(symbol='AAPL240119C00190000', timestamp=now, price=1.15, size=2, exchange=None)
+        asyncio.run(self.handlers['quotes'][0](quote))
+        asyncio.run(self.handlers['trades'][0](trade))
+
+    def stop(self):
+        pass
+
+
+def test_options_stream_publishes_quotes_and_trades(monkeypatch):
+    monkeypatch.setenv('BROKER_API_KEY', 'key')
+    monkeypatch.setenv('BROKER_SECRET_KEY', 'secret')
+    monkeypatch.setattr(asset_streams, 'OptionDataStream', FakeStream)
+    received = []
+
+    async def handler(message):
+        received.append(message)
+
+    asset_streams.stream_options(handler, ['AAPL240119C00190000'])
+    assert asset_streams.streams['options'].handlers['quotes'][1] == ('AAPL240119C00190000',)
+    assert [message['type'] for message in received] == ['quote', 'trade']
+    assert received[0]['ask_price'] == 1.2 and received[0]['timestamp'] == '2024-01-02T15:30:00+00:00'
+    assert received[1]['price'] == 1.15
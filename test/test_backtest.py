@@ -0,0 +1,69 @@
+import numpy as np
+from nexus.helpers import backtest
+
+
+def square(x, offset=0):
+    return {'value': x * x + offset}
+
+
+def fail_on_three(x):
+    if x == 3:
+        raise ValueError('bad parameter')
+    return {'value': x}
+
+
+def test_parameter_grid():
+    grid = backtest.parameter_grid(window=[10, 20], num_std=[1.5, 2])
+    assert grid == [
+        {'window': 10, 'num_std': 1.5},
+        {'window': 10, 'num_std': 2},
+        {'window': 20, 'num_std': 1.5},
+        {'window': 20, 'num_std': 2},
+    ]
+
+
+def test_run_parameter_grid_preserves_order():
+    grid = backtest.parameter_grid(x=[3, 1, 2])
+    results = backtest.run_parameter_grid(square, grid, max_workers=2, offset=1)
+    assert [result['result']['value'] for result in results] == [10, 2, 5]
+    assert [result['params'] for result in results] == grid
+
+
+def test_run_parameter_grid_captures_errors():
+    results = backtest.run_parameter_grid(fail_on_three, backtest.parameter_grid(x=[1, 3]), max_workers=2)
+    assert results[0]['result'] == {'value': 1}
+    assert 'bad parameter' in results[1]['error']
+
+
+def test_process_work_item():
+    body = '{"job_id": "job", "index": 4, "backtest": "bollinger_reversion", ' \
+           '"params": {"window": 5}, "fixed": {"closes": [1, 2, 3, 4, 5, 6]}}'
+    result = backtest.process_work_item(body)
+    assert result['job_id'] == 'job'
+    assert result['index'] == 4
+    assert 'pnl' in result['result']
+
+
+def test_bollinger_reversion_trades_both_sides():
+    np.random.seed(42)
+    closes = (100 + np.sin(np.linspace(0, 20 * np.pi, 1000)) * 5 + np.random.normal(0, 0.1, 1000)).tolist()
+    result = backtest.backtest_bollinger_reversion(closes, window=20, num_std=1.5)
+    quantities = {trade['qty'] for trade in result['trades']}
+    assert quantities == {1, -1}
+    assert len(result['equity_curve']) == len(closes)
+    # Never holds more than one unit in either direction
+    position = 0
+    for trade in result['trades']:
+        position += trade['qty']
+        assert abs(position) <= 1
+
+
+def test_bollinger_reversion_performance_report():
+    closes = [100 + (i % 10) for i in range(200)]
+    assert 'performance' not in backtest.backtest_bollinger_reversion(closes, window=5, num_std=1)
+    result = backtest.backtest_bollinger_reversion(closes, window=5, num_std=1, capital=1000)
+    report = result['performance']
+    assert np.isclose(report['total_return'], result['pnl'] / 1000)
+    # Round trips close every other trade
+    assert report['trades'] == len(result['trades']) // 2
+    assert 0 < report['exposure'] <= 1
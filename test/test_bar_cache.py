@@ -0,0 +1,47 @@
+from nexus.helpers.bar_cache import BarCache
+
+
+def bar(symbol, minute, close):
+    return {'symbol': symbol, 'timestamp': f'2025-02-03T15:{minute:02d}:00+00:00', 'close': close}
+
+
+def test_add_and_get_in_order():
+    cache = BarCache()
+    cache.extend([bar('AAPL', 2, 102), bar('AAPL', 0, 100), bar('AAPL', 1, 101)])
+    assert cache.closes('AAPL') == [100, 101, 102]
+    assert cache.closes('AAPL', limit=2) == [101, 102]
+    assert cache.count('AAPL') == 3
+
+
+def test_same_timestamp_replaces_and_older_is_ignored():
+    cache = BarCache()
+    cache.add(bar('AAPL', 1, 101))
+    cache.add(bar('AAPL', 1, 105))
+    cache.add(bar('AAPL', 0, 99))
+    assert cache.closes('AAPL') == [105]
+
+
+def test_series_are_separated_by_timeframe():
+    cache = BarCache()
+    cache.add(bar('AAPL', 0, 100))
+    cache.add(bar('AAPL', 0, 200), timeframe='5Min')
+    assert cache.closes('AAPL') == [100]
+    assert cache.closes('AAPL', '5Min') == [200]
+    assert cache.get('MSFT') == []
+
+
+def test_bounded_bars_and_series():
+    cache = BarCache(max_bars=2, max_series=1)
+    for minute in range(5):
+        cache.add(bar('AAPL', minute, minute))
+    assert cache.closes('AAPL') == [3, 4]
+    cache.add(bar('MSFT', 0, 1))
+    assert cache.count('AAPL') == 0
+    assert cache.count('MSFT') == 1
+
+
+def test_merge_inserts_older_bars_behind_live_ones():
+    cache = BarCache(max_bars=3)
+    cache.add(bar('AAPL', 5, 105))
+    cache.merge([bar('AAPL', 3, 103), bar('AAPL', 4, 104), bar('AAPL', 5, 999), bar('AAPL', 2, 102)])
+    assert cache.closes('AAPL') == [103, 104, 999]
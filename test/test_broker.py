@@ -0,0 +1,199 @@
+import pytest
+import requests
+from types import SimpleNamespace
+from datetime import datetime, timedelta, timezone
+from alpaca.trading.enums import OrderSide
+from nexus.helpers import broker
+
+
+# Trading client that keeps orders in memory instead of sending them to Alpaca
+class FakeTradingClient:
+    def __init__(self):
+        self.orders = {}
+        self.lost_responses = 0
+        self.hard_to_borrow = set()
+        self.asset_lookups = []
+
+    def submit_order(self, order_data):
+        order = SimpleNamespace(id=f'order-{len(self.orders) + 1}', request=order_data, status='new', filled_avg_price=None)
+        self.orders[order.id] = order
+        if self.lost_responses:
+            # The order reached the broker but the response never made it back
+            self.lost_responses -= 1
+            raise requests.ConnectionError('Connection reset by peer')
+        return order
+
+    def get_order_by_client_id(self, client_order_id):
+        return next((order for order in self.orders.values() if order.request.client_order_id == client_order_id), None)
+
+    def get_asset(self, symbol_or_asset_id):
+        self.asset_lookups.append(symbol_or_asset_id)
+        return SimpleNamespace(
+            tradable=True, shortable=True, easy_to_borrow=symbol_or_asset_id not in self.hard_to_borrow,
+            marginable=True, fractionable=True
+        )
+
+    def cancel_order_by_id(self, order_id):
+        self.orders[order_id].status = 'canceled'
+
+    def cancel_orders(self):
+        open_orders = [order for order in self.orders.values() if order.status == 'new']
+        for order in open_orders:
+            order.status = 'canceled'
+        return [SimpleNamespace(id=order.id, status=200) for order in open_orders]
+
+    def replace_order_by_id(self, order_id, order_data):
+        self.orders[order_id].status = 'replaced'
+        return self.submit_order(order_data)
+
+
+@pytest.fixture
+def trading_client(monkeypatch):
+    client = FakeTradingClient()
+    monkeypatch.setattr(broker, 'get_broker_client', lambda service: client)
+    return client
+
+
+def test_notional_orders_are_rounded_to_cents(trading_client):
+    assert broker.place_notional_order('AAPL', 1000.004, OrderSide.BUY) is None
+    order = trading_client.orders['order-1'].request
+    assert (order.notional, order.qty) == (1000.0, None)
+    with pytest.raises(ValueError):
+        broker.place_notional_order('AAPL', 0, OrderSide.SELL)
+    assert len(trading_client.orders) == 1
+
+
+def test_client_order_ids_are_deterministic_per_signal():
+    first = broker.generate_client_order_id('reversion', 'AAPL', '2025-03-03T15:00:00+00:00')
+    assert first == broker.generate_client_order_id('reversion', 'AAPL', '2025-03-03T15:00:00+00:00')
+    assert first.startswith('reversion-AAPL-')
+    assert first != broker.generate_client_order_id('reversion', 'AAPL', '2025-03-03T15:01:00+00:00')
+    assert len(broker.generate_client_order_id('reversion', 'X' * 200, 'now')) == 128
+
+
+def test_lost_responses_are_retried_without_a_duplicate_order(trading_client, monkeypatch):
+    monkeypatch.setattr(broker.time, 'sleep', lambda seconds: None)
+    trading_client.lost_responses = 1
+    broker.place_market_order('AAPL', 10, OrderSide.BUY, client_order_id='reversion-AAPL-1')
+    assert [order.request.client_order_id for order in trading_client.orders.values()] == ['reversion-AAPL-1']
+    with pytest.raises(ValueError):
+        broker.submit_order_idempotent(SimpleNamespace(client_order_id=None))
+
+
+def test_asset_flags_are_cached_and_shorts_need_easy_to_borrow(trading_client, monkeypatch):
+    monkeypatch.setattr(broker, 'asset_cache', {})
+    trading_client.hard_to_borrow.add('GME')
+    assert broker.is_shortable('AAPL') and broker.is_shortable('AAPL')
+    assert not broker.is_shortable('GME')
+    assert trading_client.asset_lookups == ['AAPL', 'GME']
+    broker.get_asset_flags('AAPL', max_age_seconds=0)
+    assert trading_client.asset_lookups == ['AAPL', 'GME', 'AAPL']
+
+
+def test_oco_exits_submit_both_legs_in_one_order(trading_client):
+    order_id = broker.place_oco_order('AAPL', 10, OrderSide.SELL, take_profit_price=110, stop_price=95)
+    oco = trading_client.orders[order_id].request
+    assert oco.order_class == broker.OrderClass.OCO
+    assert (oco.take_profit.limit_price, oco.stop_loss.stop_price) == (110, 95)
+    # A sell exit's target must sit above its stop, a buy-to-cover's below it
+    with pytest.raises(ValueError):
+        broker.place_oco_order('AAPL', 10, OrderSide.SELL, take_profit_price=90, stop_price=95)
+    with pytest.raises(ValueError):
+        broker.place_oco_order('AAPL', 10, OrderSide.BUY, take_profit_price=110, stop_price=95)
+    assert len(trading_client.orders) == 1
+
+
+def test_resting_orders_are_replaced_and_cancelled(trading_client):
+    order_id = broker.place_limit_order('AAPL', 10, OrderSide.BUY, limit_price=189.5)
+    replacement_id = broker.replace_order(order_id, limit_price=189.75)
+    assert trading_client.orders[order_id].status == 'replaced'
+    assert trading_client.orders[replacement_id].request.limit_price == 189.75
+    broker.place_limit_order('MSFT', 5, OrderSide.SELL, limit_price=410.0)
+    assert broker.cancel_all_orders() == 2
+    with pytest.raises(ValueError):
+        broker.replace_order(replacement_id)
+
+
+def test_split_date_range_covers_range_in_order():
+    start = datetime(2024, 1, 1)
+    windows = broker.split_date_range(start, start + timedelta(days=10), timedelta(days=4))
+    assert windows == [
+        (start, start + timedelta(days=4)),
+        (start + timedelta(days=4), start + timedelta(days=8)),
+        (start + timedelta(days=8), start + timedelta(days=10)),
+    ]
+    assert broker.split_date_range(start, start, timedelta(days=1)) == []
+
+
+def test_historical_bars_reject_unknown_timeframe_and_adjustment():
+    start = datetime(2024, 1, 1)
+    with pytest.raises(ValueError):
+        broker.get_historical_bar_data(['AAPL'], start, start + timedelta(days=1), timeframe='2Min')
+    with pytest.raises(ValueError):
+        broker.get_historical_bar_data(['AAPL'], start, start + timedelta(days=1), timeframe='1Day', adjustment='splits')
+
+
+def alpaca_bar(symbol, day):
+    return SimpleNamespace(
+        symbol=symbol, timestamp=datetime(2024, 1, day, tzinfo=timezone.utc),
+        open=1, high=2, low=0.5, close=1.5, volume=100, trade_count=5, vwap=None
+    )
+
+
+def test_bar_limit_applies_to_each_symbol(monkeypatch):
+    bar_requests = []
+
+    def get_stock_bars(request):
+        bar_requests.append((request.symbol_or_symbols, request.limit))
+        # Alpaca fills a limit from the first symbol's bars before the next one's
+        page = [(symbol, alpaca_bar(symbol, day)) for symbol in request.symbol_or_symbols for day in (2, 3, 4)]
+        page = page[:request.limit] if request.limit else page
+        return SimpleNamespace(data={symbol: [bar for name, bar in page if name == symbol] for symbol, _ in page})
+
+    monkeypatch.setattr(broker, 'StockBarsRequest', SimpleNamespace)
+    monkeypatch.setattr(broker, 'get_broker_client', lambda kind: SimpleNamespace(get_stock_bars=get_stock_bars))
+    start = datetime(2024, 1, 1, tzinfo=timezone.utc)
+    bars = broker.get_historical_bar_data(['AAPL', 'MSFT'], start, start + timedelta(days=5), timeframe='1Day', limit=2)
+    assert {symbol: len(symbol_bars) for symbol, symbol_bars in bars.items()} == {'AAPL': 2, 'MSFT': 2}
+    assert bar_requests == [(['AAPL', 'MSFT'], None)]
+    broker.get_historical_bar_data(['AAPL'], start, start + timedelta(days=5), timeframe='1Day', limit=2)
+    assert bar_requests[-1] == (['AAPL'], 2)
+
+
+def test_multi_symbol_bars_batch_the_universe(monkeypatch):
+    requests = []
+
+    def fake_bars(symbols, **kwargs):
+        requests.append(symbols)
+        return {symbol: [alpaca_bar(symbol, 2), alpaca_bar(symbol, 3)] for symbol in symbols if symbol != 'DELISTED'}
+
+    monkeypatch.setattr(broker, 'get_historical_bar_data', fake_bars)
+    start = datetime(2024, 1, 1)
+    bars = broker.get_historical_bars_multi(
+        ['AAPL', 'MSFT', 'AAPL', 'DELISTED', 'NVDA'], start, start + timedelta(days=5), batch_size=2
+    )
+    assert requests == [['AAPL', 'MSFT'], ['DELISTED', 'NVDA']]
+    assert list(bars) == ['AAPL', 'MSFT', 'DELISTED', 'NVDA']
+    assert bars['DELISTED'] == []
+    assert [bar.close for bar in bars['NVDA']] == [1.5, 1.5]
+    assert bars['AAPL'][0].timestamp == datetime(2024, 1, 2, tzinfo=timezone.utc)
+
+
+def test_data_feed_and_sandbox_from_env(monkeypatch):
+    monkeypatch.delenv('DATA_FEED', raising=False)
+    monkeypatch.delenv('DATA_SANDBOX', raising=False)
+    assert broker.data_feed() is None
+    assert broker.stream_url('v2/iex') is None
+    monkeypatch.setenv('DATA_FEED', 'SIP')
+    assert broker.data_feed() is broker.DATA_FEEDS['sip']
+    monkeypatch.setenv('DATA_SANDBOX', 'True')
+    assert broker.stream_url('v2/sip') == 'wss://stream.data.sandbox.alpaca.markets/v2/sip'
+    monkeypatch.setenv('DATA_FEED', 'opra')
+    with pytest.raises(ValueError):
+        broker.data_feed()
+
+
+def test_replace_order_keeps_fractional_quantities(trading_client):
+    order_id = broker.place_limit_order('AAPL', 2.5, OrderSide.BUY, limit_price=189.5)
+    replacement_id = broker.replace_order(order_id, qty=1.5)
+    assert trading_client.orders[replacement_id].request.qty == 1.5
@@ -0,0 +1,97 @@
+import pytest
+from datetime import datetime, timedelta
+from nexus.helpers import brokers, sessions, trading_calendar
+from alpaca.trading.enums import OrderSide
+
+
+@pytest.fixture(autouse=True)
+def reset_broker():
+    yield
+    brokers.set_broker(None)
+
+
+def test_market_orders_fill_at_set_price_and_update_positions():
+    mock = brokers.MockBroker(cash=1_000)
+    mock.set_price('AAPL', 100)
+    assert mock.submit_market_order('AAPL', 3, OrderSide.BUY) == 100
+    mock.set_price('AAPL', 110)
+    mock.submit_market_order('AAPL', 1, OrderSide.SELL)
+    assert mock.get_positions()['AAPL']['qty'] == 2
+    assert mock.get_account_equity() == pytest.approx(1_000 - 300 + 110 + 220)
+
+
+def test_client_order_id_is_submitted_once():
+    mock = brokers.MockBroker()
+    mock.set_price('AAPL', 100)
+    mock.submit_market_order('AAPL', 1, OrderSide.BUY, client_order_id='abc')
+    mock.submit_market_order('AAPL', 1, OrderSide.BUY, client_order_id='abc')
+    assert len(mock.orders) == 1
+    assert mock.positions == {'AAPL': 1}
+
+
+def test_limit_orders_rest_until_cancelled():
+    mock = brokers.MockBroker()
+    order_id = mock.submit_limit_order('AAPL', 1, OrderSide.BUY, 95)
+    mock.cancel_order(order_id)
+    assert mock.orders[0]['status'] == 'canceled'
+    with pytest.raises(Exception):
+        mock.cancel_order(order_id)
+
+
+def test_clock_helpers():
+    mock = brokers.MockBroker()
+    now = datetime(2025, 2, 3, 15, 0)
+    mock.clock = {'is_open': True, 'timestamp': now, 'next_open': now, 'next_close': now + timedelta(minutes=60)}
+    assert mock.is_market_open()
+    assert mock.minutes_till_market_close() == 60
+    assert mock.minutes_till_market_open() == 0
+    assert mock.is_within_last_minutes_of_session(60)
+    assert not mock.is_within_last_minutes_of_session(59)
+    assert mock.sleep_until_open()
+
+
+def test_stream_replays_bars_in_order():
+    mock = brokers.MockBroker()
+    mock.bars = {
+        'AAPL': [{'symbol': 'AAPL', 'timestamp': '2025-02-03T15:01:00', 'close': 101}],
+        'MSFT': [{'symbol': 'MSFT', 'timestamp': '2025-02-03T15:00:00', 'close': 400}],
+    }
+    received = []
+
+    async def handler(bar):
+        received.append(bar['symbol'])
+
+    mock.stream_bars(handler, ['AAPL', 'MSFT'])
+    assert received == ['MSFT', 'AAPL']
+    assert mock.get_latest_price('AAPL') == 101
+
+
+def test_set_broker_injects_implementation():
+    mock = brokers.MockBroker()
+    brokers.set_broker(mock)
+    assert brokers.get_broker() is mock
+
+
+def test_parse_trading_hours_skips_closed_days():
+    sessions = brokers.parse_trading_hours(
+        '20250203:0930-20250203:1600;20250208:CLOSED;20250204:0930-20250204:1600', 'US/Eastern'
+    )
+    assert [(open_at.day, open_at.hour, close_at.hour) for open_at, close_at in sessions] == [(3, 9, 16), (4, 9, 16)]
+
+
+def test_clock_from_sessions():
+    sessions = brokers.parse_trading_hours('20250203:0930-20250203:1600;20250204:0930-20250204:1600', 'US/Eastern')
+    open_at, close_at = sessions[0]
+    during = brokers.clock_from_sessions(sessions, open_at + timedelta(hours=1))
+    assert during['is_open'] and during['next_close'] == close_at and during['next_open'] == sessions[1][0]
+    after = brokers.clock_from_sessions(sessions, close_at + timedelta(minutes=1))
+    assert not after['is_open'] and after['next_open'] == sessions[1][0]
+    assert brokers.clock_from_sessions(sessions, sessions[1][1])['next_open'] is None
+
+
+def test_alpaca_session_is_closed_on_holidays(monkeypatch):
+    thanksgiving = datetime(2025, 11, 27, 17, tzinfo=sessions.EASTERN)
+    alpaca = brokers.AlpacaBroker()
+    monkeypatch.setattr(alpaca, 'get_clock', lambda: {'is_open': False, 'timestamp': thanksgiving})
+    monkeypatch.setattr(brokers.trading_calendar, 'get_calendar', lambda: trading_calendar.TradingCalendar(fetch=lambda start, end: {}))
+    assert alpaca.get_session() == sessions.MarketSession.CLOSED
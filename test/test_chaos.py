@@ -0,0 +1,57 @@
+import pytest
+from nexus.helpers import chaos
+
+
+class FakeClient:
+    def __init__(self):
+        self.calls = []
+        self.region = 'us-east-2'
+
+    def publish(self, **kwargs):
+        self.calls.append(('publish', kwargs))
+        return {'MessageId': 'real'}
+
+    def submit_order(self, order):
+        self.calls.append(('submit_order', order))
+        return order
+
+
+@pytest.fixture
+def chaos_env(monkeypatch):
+    monkeypatch.setenv('CHAOS_ENABLED', 'True')
+    monkeypatch.setenv('ENV', 'staging')
+    return monkeypatch
+
+
+def test_disabled_by_default(monkeypatch):
+    monkeypatch.delenv('CHAOS_ENABLED', raising=False)
+    client = FakeClient()
+    assert chaos.wrap(client, {'*': chaos.error_hook}) is client
+
+
+def test_never_enabled_in_production(chaos_env):
+    chaos_env.setenv('ENV', 'production')
+    assert not chaos.enabled()
+    client = FakeClient()
+    assert chaos.wrap(client, {'*': chaos.error_hook}) is client
+
+
+def test_drop_hook_drops_publishes(chaos_env):
+    chaos_env.setenv('CHAOS_SNS_DROP_PCT', '100')
+    client = chaos.wrap(FakeClient(), {'publish': chaos.drop_hook})
+    assert client.publish(Message='bar') == {'MessageId': 'chaos-dropped'}
+    assert client.calls == []
+
+
+def test_error_hook_raises_injected_fault(chaos_env):
+    chaos_env.setenv('CHAOS_BROKER_ERROR_PCT', '100')
+    client = chaos.wrap(FakeClient(), {'*': chaos.error_hook})
+    with pytest.raises(chaos.InjectedFault):
+        client.submit_order('order')
+
+
+def test_hooks_pass_through_at_zero_rate(chaos_env):
+    chaos_env.setenv('CHAOS_BROKER_ERROR_PCT', '0')
+    client = chaos.wrap(FakeClient(), {'*': chaos.error_hook})
+    assert client.submit_order('order') == 'order'
+    assert client.region == 'us-east-2'
@@ -0,0 +1,66 @@
+import json
+import pytest
+from nexus.helpers import cloud, envelope
+
+
+class FakeSNS:
+    def __init__(self):
+        self.calls = []
+
+    def publish_batch(self, **kwargs):
+        self.calls.append(kwargs)
+        return {'Successful': [], 'Failed': []}
+
+    def subscribe(self, **kwargs):
+        self.calls.append(kwargs)
+        return {'SubscriptionArn': 'arn:aws:sns:us-east-2:123:data:1'}
+
+    def set_subscription_attributes(self, **kwargs):
+        self.calls.append(kwargs)
+
+
+def test_messages_are_published_with_filterable_attributes(monkeypatch):
+    sns = FakeSNS()
+    monkeypatch.setattr(cloud, 'get_client', lambda service: sns)
+    bar = envelope.encode('bar', {'symbol': 'AAPL', 'close': 1.0}, source='data')
+    cloud.publish_sns_batch([bar], 'arn:aws:sns:us-east-2:123:data')
+    attributes = sns.calls[0]['PublishBatchRequestEntries'][0]['MessageAttributes']
+    assert attributes == {
+        'kind': {'DataType': 'String', 'StringValue': 'bar'},
+        'symbol': {'DataType': 'String', 'StringValue': 'AAPL'},
+        'asset_class': {'DataType': 'String', 'StringValue': 'us_equity'},
+    }
+
+
+def test_filter_policy_is_applied_to_the_subscription(monkeypatch):
+    sns = FakeSNS()
+    monkeypatch.setattr(cloud, 'get_client', lambda service: sns)
+    policy = cloud.sns_filter_policy(kinds=['bar', 'heartbeat'], symbols=['MSFT', 'AAPL', 'AAPL'])
+    assert policy == {'kind': ['bar', 'heartbeat'], 'symbol': ['AAPL', 'MSFT', {'exists': False}]}
+    cloud.subscribe_sqs_to_sns('arn:aws:sqs:us-east-2:123:reversion', 'arn:aws:sns:us-east-2:123:data', filter_policy=policy)
+    assert sns.calls[1]['SubscriptionArn'] == 'arn:aws:sns:us-east-2:123:data:1'
+    assert json.loads(sns.calls[1]['AttributeValue']) == policy
+
+
+def test_filter_policy_respects_the_sns_combination_limit():
+    with pytest.raises(ValueError):
+        cloud.sns_filter_policy(kinds=['bar', 'heartbeat'], symbols=[f'S{i}' for i in range(80)])
+
+
+def test_unprocessed_keys_are_requested_again(monkeypatch):
+    requests = []
+
+    class FakeDynamoDB:
+        def batch_get_item(self, RequestItems):
+            requests.append(len(RequestItems['latest']['Keys']))
+            if len(requests) == 1:
+                return {
+                    'Responses': {'latest': [{'symbol': {'S': 'AAPL'}}]},
+                    'UnprocessedKeys': {'latest': {'Keys': [{'symbol': {'S': 'MSFT'}}]}},
+                }
+            return {'Responses': {'latest': [{'symbol': {'S': 'MSFT'}}]}, 'UnprocessedKeys': {}}
+
+    monkeypatch.setattr(cloud, 'get_client', lambda service: FakeDynamoDB())
+    monkeypatch.setattr(cloud.time, 'sleep', lambda seconds: None)
+    items = cloud.get_dynamodb_items('latest', [{'symbol': 'AAPL'}, {'symbol': 'MSFT'}])
+    assert requests == [2, 1] and len(items) == 2
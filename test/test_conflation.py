@@ -0,0 +1,34 @@
+import pytest
+from nexus.helpers import conflation
+
+
+def test_first_quote_is_released_and_later_ones_conflated():
+    conflator = conflation.Conflator(0.25)
+    assert conflator.offer('AAPL', 'quote 1', now=10.0)
+    assert not conflator.offer('AAPL', 'quote 2', now=10.1)
+    assert not conflator.offer('AAPL', 'quote 3', now=10.2)
+    assert conflator.offer('MSFT', 'quote 1', now=10.2)
+    assert conflator.due(now=10.2) == []
+    assert conflator.due(now=10.25) == ['quote 3']
+    assert conflator.due(now=10.3) == []
+
+
+def test_quote_after_a_quiet_interval_is_released_immediately():
+    conflator = conflation.Conflator(0.25)
+    assert conflator.offer('AAPL', 'quote 1', now=10.0)
+    assert conflator.offer('AAPL', 'quote 2', now=10.5)
+    assert not conflator.offer('AAPL', 'quote 3', now=10.6)
+    assert not conflator.offer('AAPL', 'quote 4', now=11.0)
+    assert conflator.due(now=11.0) == ['quote 4']
+
+
+def test_flush_releases_everything_held():
+    conflator = conflation.Conflator(1)
+    conflator.offer('AAPL', 'quote 1', now=0)
+    conflator.offer('AAPL', 'quote 2', now=0.1)
+    conflator.offer('MSFT', 'quote 1', now=0)
+    conflator.offer('MSFT', 'quote 2', now=0.1)
+    assert sorted(conflator.flush()) == ['quote 2', 'quote 2']
+    assert conflator.held == {}
+    with pytest.raises(ValueError):
+        conflation.Conflator(0)
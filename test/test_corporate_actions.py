@@ -0,0 +1,37 @@
+import pytest
+from datetime import date, datetime, timedelta
+from types import SimpleNamespace
+from nexus.helpers import corporate_actions
+
+DAYS = [date(2024, 6, 3) + timedelta(days=i) for i in range(6)]
+
+
+def action(ex_date, ratio=None, cash=None, ca_type='split'):
+    return {'symbol': 'NVDA', 'type': ca_type, 'sub_type': None, 'ex_date': ex_date, 'ratio': ratio, 'cash': cash}
+
+
+def test_split_is_back_adjusted():
+    raw = [1200.0, 1210.0, 1220.0, 122.0, 123.0, 124.0]
+    adjusted = corporate_actions.adjust_prices(DAYS, raw, [action(DAYS[3], ratio=10)])
+    assert adjusted == pytest.approx([120.0, 121.0, 122.0, 122.0, 123.0, 124.0])
+
+
+def test_cash_dividend_scales_by_prior_close():
+    raw = [100.0, 100.0, 99.0, 99.0]
+    adjusted = corporate_actions.adjust_prices(DAYS[:4], raw, [action(DAYS[2], cash=1.0, ca_type='dividend')])
+    assert adjusted == pytest.approx([99.0, 99.0, 99.0, 99.0])
+
+
+def test_only_actions_inside_the_series_are_flagged():
+    actions = [action(DAYS[0], ratio=2), action(DAYS[4], ratio=2), action(DAYS[5] + timedelta(days=1), ratio=2)]
+    intraday = [datetime(2024, 6, 3, 14, 30), datetime(2024, 6, 8, 19, 59)]
+    assert corporate_actions.actions_in_series(intraday, actions) == [actions[1]]
+    assert corporate_actions.actions_in_series([], actions) == []
+
+
+def test_announcements_are_normalized():
+    announcement = SimpleNamespace(
+        ca_type=SimpleNamespace(value='split'), ca_sub_type=SimpleNamespace(value='stock_split'),
+        initiating_symbol='NVDA', target_symbol=None, ex_date=DAYS[3], old_rate=1, new_rate=10, cash=None
+    )
+    assert corporate_actions._announcement_to_dict(announcement) == action(DAYS[3], ratio=10) | {'sub_type': 'stock_split'}
@@ -0,0 +1,31 @@
+import pytest
+from nexus.helpers import cross_section
+
+
+def test_ranks_average_ties():
+    values = {'AAA': 0.02, 'BBB': -0.01, 'CCC': 0.02, 'DDD': 0.05}
+    assert cross_section.ranks(values) == {'BBB': 1, 'AAA': 2.5, 'CCC': 2.5, 'DDD': 4}
+    assert cross_section.ranks(values, ascending=False)['DDD'] == 1
+
+
+def test_percentiles():
+    values = {'AAA': 1.0, 'BBB': 2.0, 'CCC': 3.0}
+    assert cross_section.percentiles(values) == {'AAA': 0.0, 'BBB': 0.5, 'CCC': 1.0}
+    assert cross_section.percentiles({'AAA': 1.0}) == {'AAA': 0.5}
+
+
+def test_zscores():
+    scores = cross_section.zscores({'AAA': 1.0, 'BBB': 2.0, 'CCC': 3.0})
+    assert scores == {'AAA': pytest.approx(-1.0), 'BBB': pytest.approx(0.0), 'CCC': pytest.approx(1.0)}
+    assert cross_section.zscores({'AAA': 1.0, 'BBB': 2.0, 'CCC': 30.0}, clip=1.0)['CCC'] == 1.0
+    assert cross_section.zscores({'AAA': 2.0, 'BBB': 2.0}) == {'AAA': 0.0, 'BBB': 0.0}
+
+
+def test_demean_and_buckets():
+    values = {symbol: float(i) for i, symbol in enumerate('ABCDEFGHIJ')}
+    assert sum(cross_section.demean(values).values()) == pytest.approx(0.0)
+    buckets = cross_section.quantile_buckets(values, buckets=5)
+    assert buckets['A'] == 0 and buckets['J'] == 4
+    assert sorted(buckets.values()) == [0, 0, 1, 1, 2, 2, 3, 3, 4, 4]
+    with pytest.raises(ValueError):
+        cross_section.quantile_buckets(values, buckets=0)
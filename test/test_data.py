@@ -1,5 +1,111 @@
+import json
+import asyncio
+import pytest
+from nexus.helpers import subscription
 from nexus.services import data
 
 
 def test_run():
     assert data.dummy_test() == 1
+
+
+def test_handle_command_changes_stream(monkeypatch):
+    broker = data.brokers.MockBroker()
+    data.brokers.set_broker(broker)
+    monkeypatch.setattr(data, 'watchlist', data.subscription.Watchlist(['AAPL']))
+    monkeypatch.delenv('NEWS_SNS', raising=False)
+    try:
+        assert data.handle_command(subscription.control_command('subscribe', ['MSFT', 'AAPL'])) == {'bars': ['MSFT']}
+        assert broker.streamed_symbols == {'MSFT'}
+        assert data.handle_command(subscription.control_command('unsubscribe', ['AAPL'])) == {'bars': ['AAPL']}
+        assert data.watchlist.symbols() == ['MSFT']
+        with pytest.raises(ValueError):
+            data.handle_command(subscription.control_command('subscribe', ['NVDA'], types=('news',)))
+    finally:
+        data.brokers.set_broker(None)
+
+
+def test_trades_follow_watchlist_changes(monkeypatch):
+    broker = data.brokers.MockBroker()
+    data.brokers.set_broker(broker)
+    monkeypatch.setattr(data, 'watchlist', data.subscription.Watchlist(['AAPL']))
+    monkeypatch.delenv('NEWS_SNS', raising=False)
+    monkeypatch.setenv('TRADE_BARS', '5s')
+    try:
+        data.run_trade_bars(data.aggregation.TradeBarAggregator(data.aggregation.parse_specs('5s')), None, broker)
+        assert broker.trade_handler is not None
+        assert broker.traded_symbols == {'AAPL'}
+        data.handle_command(subscription.control_command('subscribe', ['MSFT']))
+        assert broker.traded_symbols == {'AAPL', 'MSFT'}
+        data.handle_command(subscription.control_command('unsubscribe', ['AAPL']))
+        assert broker.traded_symbols == {'MSFT'}
+    finally:
+        data.brokers.set_broker(None)
+
+
+def test_snapshots_get_stock_quotes_and_trades(monkeypatch):
+    broker = data.brokers.MockBroker()
+    data.brokers.set_broker(broker)
+    monkeypatch.setattr(data, 'watchlist', data.subscription.Watchlist(['AAPL']))
+    monkeypatch.setattr(data.snapshots, 'cache', data.snapshots.SnapshotCache())
+    monkeypatch.delenv('NEWS_SNS', raising=False)
+    monkeypatch.delenv('TRADE_BARS', raising=False)
+    try:
+        data.run_snapshot_feeds(broker)
+        quote = {'symbol': 'AAPL', 'timestamp': '2025-03-03T15:00:00+00:00', 'bid_price': 189.9, 'ask_price': 190.1}
+        asyncio.run(broker.quote_handler(quote))
+        asyncio.run(broker.trade_handler({'symbol': 'AAPL', 'timestamp': '2025-03-03T15:00:01+00:00', 'price': 190.0, 'size': 5}))
+        snapshot = data.snapshots.cache.query(['AAPL'])['AAPL']
+        assert snapshot['quote'] == quote and snapshot['trade']['price'] == 190.0
+        data.handle_command(subscription.control_command('subscribe', ['MSFT']))
+        assert broker.quoted_symbols == broker.traded_symbols == {'AAPL', 'MSFT'}
+        data.handle_command(subscription.control_command('unsubscribe', ['AAPL']))
+        assert broker.quoted_symbols == broker.traded_symbols == {'MSFT'}
+    finally:
+        data.brokers.set_broker(None)
+
+
+def test_control_commands_that_fail_are_deleted(monkeypatch):
+    messages = [{'Body': 'not json', 'ReceiptHandle': 'bad'}, {'Body': 'boom', 'ReceiptHandle': 'failing'}]
+    deleted = []
+    polls = iter([messages])
+
+    def handle_command(body):
+        if body == 'boom':
+            raise RuntimeError('stream restart failed')
+        raise ValueError('not a command')
+
+    monkeypatch.setattr(data, 'handle_command', handle_command)
+    monkeypatch.setattr(data.cloud, 'poll_sqs_message', lambda queue_url, **kwargs: next(polls))
+    monkeypatch.setattr(data.cloud, 'delete_sqs_message', lambda queue_url, receipt_handle: deleted.append(receipt_handle))
+    data.run_control('control', lambda: len(deleted) == 2)
+    assert deleted == ['bad', 'failing']
+
+
+def test_payloads_are_compressed_by_size_whatever_their_kind(monkeypatch):
+    monkeypatch.setenv('MESSAGE_COMPRESSION', 'gzip')
+    monkeypatch.delenv('MESSAGE_SERIALIZER', raising=False)
+    monkeypatch.delenv('MESSAGE_COMPRESSION_MIN_BYTES', raising=False)
+    article = {'symbols': ['AAPL'], 'headline': 'Apple beats estimates', 'content': 'Revenue rose. ' * 200}
+    news = json.loads(data._encode('news', article))
+    assert news['contentEncoding'] == 'gzip'
+    assert data.envelope.decode(news)['payload'] == article
+    bar = {'symbol': 'AAPL', 'timestamp': '2025-03-03T15:00:00+00:00', 'close': 190.0, 'volume': 1200}
+    assert 'contentEncoding' not in json.loads(data._encode('bar', bar))
+    monkeypatch.setenv('MESSAGE_COMPRESSION_MIN_BYTES', '64')
+    assert json.loads(data._encode('bar', bar))['contentEncoding'] == 'gzip'
+
+
+def test_tenant_settings_take_precedence(monkeypatch):
+    monkeypatch.setenv('TENANT', 'pool-b')
+    monkeypatch.setenv('UNIVERSE', 'AAPL')
+    monkeypatch.setenv('POOL_B__UNIVERSE', 'MSFT,NVDA')
+    monkeypatch.delenv('UNIVERSE_S3', raising=False)
+    monkeypatch.delenv('UNIVERSE_FILE', raising=False)
+    monkeypatch.delenv('NEWS_SNS', raising=False)
+    monkeypatch.setenv('POOL_B__NEWS_SNS', 'arn:aws:sns:us-east-1:123456789012:news-pool-b')
+    assert data.subscription.load_universe() == ['MSFT', 'NVDA']
+    assert data._channels() == ('bars', 'news')
+    monkeypatch.setenv('TENANT', 'default')
+    assert data.subscription.load_universe() == ['AAPL']
+    assert data._channels() == ('bars',)
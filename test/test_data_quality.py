@@ -0,0 +1,40 @@
+from datetime import datetime, timezone
+from nexus.helpers import data_quality, envelope
+
+QUOTE = {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:00+00:00', 'bid_price': 100.2, 'ask_price': 100.1}
+
+
+def test_crossed_quotes_and_zero_volume_bars_alert_once_per_cooldown():
+    monitor = data_quality.DataQualityMonitor('monitoring', cooldown_seconds=60, send=lambda body, topic: None)
+    assert [alert['alert'] for alert in monitor.observe('quotes', QUOTE, now=1_000)] == ['crossed_quote']
+    assert monitor.observe('quotes', QUOTE, now=1_030) == []
+    assert len(monitor.observe('quotes', QUOTE, now=1_061)) == 1
+    assert monitor.observe('quotes', {**QUOTE, 'bid_price': 100.1}, now=2_000) == []
+    bar = {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:00+00:00', 'close': 100.0, 'volume': 0}
+    assert monitor.observe('bars', bar, now=1_000)[0]['alert'] == 'zero_volume'
+    assert monitor.observe('bars', {**bar, 'volume': 10}, now=2_000) == []
+
+
+def test_stale_symbols_alert_once_and_recover():
+    monitor = data_quality.DataQualityMonitor('monitoring', stale_seconds=300, send=lambda body, topic: None)
+    monitor.reset(now=0)
+    monitor.observe('bars', {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:00+00:00', 'volume': 10}, now=100)
+    stale = monitor.check(['AAPL', 'MSFT'], now=350)
+    assert [alert['symbol'] for alert in stale] == ['MSFT'] and stale[0]['silent_seconds'] == 350
+    assert [alert['symbol'] for alert in monitor.check(['AAPL', 'MSFT'], now=400)] == ['AAPL']
+    recovered = monitor.observe('bars', {'symbol': 'MSFT', 'timestamp': '2024-01-02T15:36:00+00:00', 'volume': 10}, now=410)
+    assert [alert['alert'] for alert in recovered] == ['recovered'] and monitor.stale == {'AAPL'}
+
+
+def test_gaps_are_published_in_data_quality_envelopes():
+    sent = []
+    monitor = data_quality.DataQualityMonitor('monitoring', send=lambda body, topic: sent.append((body, topic)))
+    issue = {
+        'kind': 'gap', 'symbol': 'AAPL', 'missing': 3,
+        'start': datetime(2024, 1, 2, 15, 30, tzinfo=timezone.utc), 'end': datetime(2024, 1, 2, 15, 34, tzinfo=timezone.utc),
+    }
+    monitor.publish(monitor.report_gap(issue, now=1_000))
+    body, topic = sent[0]
+    decoded = envelope.decode(body)
+    assert topic == 'monitoring' and decoded['kind'] == 'data_quality'
+    assert decoded['payload']['alert'] == 'gap' and decoded['payload']['missing'] == 3
@@ -0,0 +1,63 @@
+from datetime import datetime, timedelta, timezone
+from types import SimpleNamespace
+from nexus.helpers.domain import Bar, Order, Quote, Side, Signal
+from nexus.helpers.domain import converters
+
+
+def test_bar_round_trips_through_topic_format():
+    bar = Bar('AAPL', datetime(2025, 2, 3, 15, tzinfo=timezone.utc), 1, 2, 0.5, 1.5, 100, 7)
+    data = bar.to_dict()
+    assert data['timestamp'] == '2025-02-03T15:00:00+00:00'
+    assert Bar.from_dict({**data, 'build': 'abc'}) == bar
+
+
+def test_quote_mid_and_spread():
+    quote = Quote('AAPL', datetime(2025, 2, 3, tzinfo=timezone.utc), 99.5, 100, 100.5, 200)
+    assert quote.mid == 100 and quote.spread == 1
+
+
+def test_order_signed_qty():
+    assert Order('AAPL', 5, Side.SELL).signed_qty == -5
+
+
+def test_signal_validity_window():
+    bar_time = datetime(2025, 2, 3, 15, tzinfo=timezone.utc)
+    signal = Signal('reversion', 'AAPL', Side.BUY, bar_time, qty=1, ttl=timedelta(minutes=2))
+    assert signal.expires_at == bar_time + timedelta(minutes=2)
+    assert signal.is_valid(bar_time + timedelta(seconds=119))
+    assert not signal.is_valid(bar_time + timedelta(minutes=2))
+    assert Signal('reversion', 'AAPL', Side.BUY, bar_time).is_valid(bar_time + timedelta(days=1))
+    delayed = Signal('reversion', 'AAPL', Side.BUY, bar_time, valid_from=bar_time + timedelta(minutes=1))
+    assert not delayed.is_valid(bar_time)
+
+
+def test_bar_from_ib_normalizes_dates_and_types():
+    ib_bar = SimpleNamespace(date=datetime(2025, 2, 3).date(), open='1', high=2, low=0.5, close=1.5, volume=10, barCount=3, average=1.2)
+    bar = converters.bar_from_ib('AAPL', ib_bar)
+    assert bar.timestamp == datetime(2025, 2, 3, tzinfo=timezone.utc)
+    assert bar.open == 1.0 and bar.trade_count == 3 and bar.vwap == 1.2
+
+
+def test_snapshot_from_alpaca_keeps_missing_parts_empty():
+    now = datetime(2025, 2, 3, 15, 30)
+    snapshot = converters.snapshot_from_alpaca(SimpleNamespace(
+        symbol='AAPL',
+        latest_trade=SimpleNamespace(symbol='AAPL', timestamp=now, price='101.5', size=10, exchange='V'),
+        latest_quote=SimpleNamespace(symbol='AAPL', timestamp=now, bid_price=101.4, bid_size=3, ask_price=101.6, ask_size=5),
+        minute_bar=None,
+        daily_bar=None,
+        previous_daily_bar=None
+    ))
+    assert snapshot.latest_trade.price == 101.5
+    assert snapshot.latest_trade.timestamp.tzinfo == timezone.utc
+    assert round(snapshot.latest_quote.spread, 2) == 0.2
+    assert snapshot.minute_bar is None
+
+
+def test_futures_contract_from_ib_uses_listed_expiration():
+    details = SimpleNamespace(contract=SimpleNamespace(
+        localSymbol='ESH5', symbol='ES', lastTradeDateOrContractMonth='20250321', multiplier='50', exchange='CME', currency='USD'
+    ))
+    contract = converters.futures_contract_from_ib(details)
+    assert contract.expiration == datetime(2025, 3, 21).date() and contract.multiplier == 50.0
+    assert contract.instrument.asset_class == 'future'
@@ -0,0 +1,33 @@
+import gzip
+import json
+import asyncio
+from nexus.helpers import draining
+
+
+def test_in_flight_messages_finish_and_new_ones_are_refused():
+    in_flight = draining.InFlight()
+    handled = []
+
+    async def handler(message):
+        assert in_flight.count == 1
+        handled.append(message['symbol'])
+
+    tracked = in_flight.track(handler)
+    asyncio.run(tracked({'symbol': 'AAPL'}))
+    assert in_flight.count == 0 and in_flight.wait(0)
+    in_flight.close()
+    asyncio.run(tracked({'symbol': 'MSFT'}))
+    assert handled == ['AAPL']
+
+
+def test_wait_times_out_while_a_message_is_in_flight():
+    in_flight = draining.InFlight()
+    in_flight.count = 1
+    assert not in_flight.wait(0.01)
+
+
+def test_spill_writes_messages_with_their_topics():
+    written = {}
+    uri = draining.spill('s3://bucket/spill/', [('{"close": 1}', 'bars')], write=lambda uri, body, content_type: written.update({uri: body}))
+    assert uri.startswith('s3://bucket/spill/') and uri.endswith('.jsonl.gz')
+    assert json.loads(gzip.decompress(written[uri])) == {'topic': 'bars', 'message': '{"close": 1}'}
@@ -0,0 +1,92 @@
+import json
+import pytest
+import urllib.request
+from urllib.error import HTTPError
+from nexus.helpers import drawdown, strategy
+
+
+@pytest.fixture(autouse=True)
+def no_alerts(monkeypatch):
+    monkeypatch.setattr(drawdown.cloud, 'publish_alert', lambda subject, details: None)
+
+
+def test_reduces_then_halts_from_high_water_mark():
+    guard = drawdown.DrawdownGuard('reversion', capital=10_000, reduce_at_pct=5, halt_at_pct=10)
+    assert guard.update(1_000) == drawdown.DrawdownState.ACTIVE
+    assert guard.high_water_mark == 11_000
+    assert guard.update(400) == drawdown.DrawdownState.REDUCED  # 5.45% off the high
+    assert guard.size_multiplier() == 0.5
+    assert guard.update(1_000) == drawdown.DrawdownState.ACTIVE  # new high lifts the reduction
+    assert guard.update(-100) == drawdown.DrawdownState.HALTED
+    assert guard.size_multiplier() == 0.0
+
+
+def test_halt_only_lifts_through_resume():
+    guard = drawdown.DrawdownGuard('reversion', capital=10_000, reduce_at_pct=5, halt_at_pct=10)
+    guard.update(-1_500)
+    assert guard.update(5_000) == drawdown.DrawdownState.HALTED
+    status = guard.resume()
+    assert status['state'] == 'active'
+    assert status['high_water_mark'] == 15_000
+    assert guard.update(5_000) == drawdown.DrawdownState.ACTIVE
+
+
+def test_halt_is_persisted_across_restarts(tmp_path):
+    state_file = str(tmp_path / 'reversion.json')
+    guard = drawdown.DrawdownGuard('reversion', capital=10_000, state_file=state_file)
+    guard.update(-2_000)
+    with open(state_file) as file:
+        assert json.load(file) == {'state': 'halted'}
+    restarted = drawdown.DrawdownGuard('reversion', capital=10_000, state_file=state_file)
+    assert restarted.state == drawdown.DrawdownState.HALTED
+
+
+def test_admin_resume_requires_a_registered_strategy():
+    guard = drawdown.register_guard(drawdown.DrawdownGuard('reversion', capital=10_000))
+    guard.update(-2_000)
+    resume = drawdown.admin.routes[('POST', '/drawdown/resume')]
+    with pytest.raises(ValueError):
+        resume(query={}, body={'strategy': 'momentum'}, identity='dana')
+    assert resume(query={}, body={'strategy': 'reversion'}, identity='dana')['state'] == 'active'
+    drawdown.guards.clear()
+
+
+def test_resume_over_http_requires_a_token(monkeypatch):
+    monkeypatch.setenv('ADMIN_TOKENS', 'dana:secret-1')
+    guard = drawdown.register_guard(drawdown.DrawdownGuard('reversion', capital=10_000))
+    guard.update(-2_000)
+    server = drawdown.admin.start_admin_server(port=0)
+    url = f'http://127.0.0.1:{server.server_port}/drawdown/resume'
+    data = json.dumps({'strategy': 'reversion'}).encode()
+    try:
+        with pytest.raises(HTTPError) as error:
+            urllib.request.urlopen(urllib.request.Request(url, data=data))
+        assert error.value.code == 401
+        assert guard.state == drawdown.DrawdownState.HALTED
+        request = urllib.request.Request(url, data=data, headers={'Authorization': 'Bearer secret-1'})
+        with urllib.request.urlopen(request) as response:
+            assert json.load(response)['resumed_by'] == 'dana'
+        assert guard.state == drawdown.DrawdownState.ACTIVE
+    finally:
+        server.shutdown()
+        drawdown.guards.clear()
+
+
+def test_executor_sizes_new_risk_but_not_exits():
+    guard = drawdown.DrawdownGuard('reversion', capital=10_000, reduce_at_pct=5, halt_at_pct=10)
+    state = strategy.TradingStateManager(logger=drawdown.logger)
+    executor = strategy.OrderExecutor(state, strategy.RiskManager(state), drawdown_guard=guard)
+    state.positions['AAPL'] = {'qty': 10, 'entry_price': 100.0}
+    executor.mark_to_market('AAPL', 40.0)
+    assert guard.state == drawdown.DrawdownState.REDUCED
+    assert executor._size_for_drawdown('MSFT', 4) == 2
+    assert executor._size_for_drawdown('AAPL', -10) == -10
+    assert executor._size_for_drawdown('MSFT', 3) == 1
+    assert executor._size_for_drawdown('MSFT', -3) == -1
+    assert executor._size_for_drawdown('MSFT', 1) == 0
+    assert executor._size_for_drawdown('MSFT', 3, fractional=True) == 1.5
+    assert executor.execute_market_order('MSFT', 1) is False
+    executor.mark_to_market('AAPL', 0.0)
+    assert guard.state == drawdown.DrawdownState.HALTED
+    assert executor._size_for_drawdown('MSFT', 4) == 0
+    assert executor._size_for_drawdown('AAPL', -5) == -5
@@ -0,0 +1,70 @@
+import json
+import pytest
+from nexus.helpers import envelope
+
+
+def test_round_trip_through_sns_notification():
+    bar = {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:00+00:00', 'close': 100.0}
+    body = envelope.encode('bar', bar, source='data', build='abc123')
+    decoded = envelope.decode_sqs({'Body': json.dumps({'Type': 'Notification', 'Message': body})})
+    assert decoded['schema'] == 'bar.v1' and decoded['kind'] == 'bar' and decoded['version'] == 1
+    assert decoded['source'] == 'data' and decoded['build'] == 'abc123'
+    assert decoded['payload'] == bar and decoded['produced_at'].tzinfo is not None
+
+
+def test_legacy_payloads_are_classified():
+    assert envelope.decode({'symbol': 'AAPL', 'close': 100.0, 'build': 'abc'})['kind'] == 'bar'
+    assert envelope.decode({'type': 'news', 'symbols': ['AAPL']})['kind'] == 'news'
+    assert envelope.decode({'symbol': 'AAPL', 'bid_price': 1.0, 'ask_price': 1.1})['kind'] == 'quote'
+    assert envelope.decode('{"symbol": "AAPL", "price": 100.0}')['kind'] == 'trade'
+
+
+def test_rejects_unknown_kinds_and_malformed_schemas():
+    with pytest.raises(ValueError):
+        envelope.wrap('order', {}, source='data')
+    with pytest.raises(ValueError):
+        envelope.decode({'schema': 'bar', 'payload': {}})
+    with pytest.raises(ValueError):
+        envelope.decode('[1, 2]')
+
+
+def test_large_payloads_are_compressed_and_decoded_transparently():
+    scan = {'pairs': [{'symbols': ['AAPL', 'MSFT'], 'p_value': 0.01}] * 200}
+    body = envelope.encode('scan', scan, source='scanner', compression='gzip')
+    message = json.loads(body)
+    assert message['schema'] == 'scan.v1' and message['contentEncoding'] == 'gzip'
+    assert len(body) < len(json.dumps(scan))
+    assert envelope.decode_sqs({'Body': json.dumps({'Message': body})})['payload'] == scan
+
+
+def test_small_payloads_are_not_compressed():
+    bar = {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:00+00:00', 'close': 100.0}
+    message = json.loads(envelope.encode('bar', bar, source='data', compression='gzip'))
+    assert 'contentEncoding' not in message and message['payload'] == bar
+    with pytest.raises(ValueError):
+        envelope.encode('bar', bar, source='data', compression='brotli')
+
+
+def test_attributes_describe_enveloped_messages():
+    option = envelope.encode('quote', {'symbol': 'AAPL250321C00150000', 'bid_price': 1.0, 'ask_price': 1.1}, source='data')
+    assert envelope.attributes(option) == {'kind': 'quote', 'symbol': 'AAPL250321C00150000', 'asset_class': 'us_option'}
+    crypto = envelope.encode('bar', {'symbol': 'BTC/USD', 'close': 1.0}, source='data')
+    assert envelope.attributes(crypto)['asset_class'] == 'crypto'
+    assert envelope.asset_class('AAPL') == 'us_equity'
+    assert envelope.attributes(envelope.encode('heartbeat', {'status': 'waiting'}, source='data')) == {'kind': 'heartbeat'}
+    assert envelope.attributes(json.dumps({'subject': 'Consumer lagging'})) == {}
+
+
+def test_encoded_messages_route_without_decoding(monkeypatch):
+    bar = envelope.encode('bar', {'symbol': 'AAPL', 'close': 190.5}, source='data')
+    monkeypatch.setattr(envelope, 'decode', lambda data: pytest.fail('decoded'))
+    assert envelope.route(bar) == ('bar', 'AAPL')
+    assert isinstance(bar, str) and json.loads(bar)['payload']['symbol'] == 'AAPL'
+
+
+def test_encoded_messages_carry_their_attributes(monkeypatch):
+    option = envelope.encode('quote', {'symbol': 'AAPL250321C00150000', 'bid_price': 1.0}, source='data')
+    heartbeat = envelope.encode('heartbeat', {'status': 'waiting'}, source='data')
+    monkeypatch.setattr(envelope, 'decode', lambda data: pytest.fail('decoded'))
+    assert envelope.attributes(option) == {'kind': 'quote', 'symbol': 'AAPL250321C00150000', 'asset_class': 'us_option'}
+    assert envelope.attributes(heartbeat) == {'kind': 'heartbeat'}
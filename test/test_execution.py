@@ -0,0 +1,52 @@
+import pytest
+from datetime import datetime
+from types import SimpleNamespace
+from nexus.helpers import execution
+
+
+def test_allocate_sums_to_total():
+    quantities = execution.allocate(10, [1, 1, 1])
+    assert quantities == [4.0, 3.0, 3.0]
+    assert sum(quantities) == 10
+
+
+def test_allocate_fractional():
+    assert execution.allocate(1, [1, 3], allow_fractional=True) == [0.25, 0.75]
+
+
+def test_allocate_zero_weights_falls_back_to_uniform():
+    assert execution.allocate(4, [0, 0]) == [2.0, 2.0]
+
+
+def test_allocate_invalid_inputs():
+    with pytest.raises(ValueError):
+        execution.allocate(0, [1])
+    with pytest.raises(ValueError):
+        execution.allocate(10, [])
+
+
+def test_twap_schedule_spacing():
+    start = datetime(2025, 2, 3, 15, 0)
+    schedule = execution.twap_schedule(100, horizon_minutes=30, slices=3, start=start)
+    assert [child['qty'] for child in schedule] == [34.0, 33.0, 33.0]
+    assert [child['at'].minute for child in schedule] == [0, 10, 20]
+
+
+def test_volume_profile_weights_by_time_of_day():
+    bars = [
+        SimpleNamespace(timestamp=datetime(2025, 1, day, 15, minute), volume=volume)
+        for day in (1, 2)
+        for minute, volume in ((0, 300), (10, 100), (45, 1000))
+    ]
+    weights = execution.volume_profile(bars, slices=2, horizon_minutes=20, start=datetime(2025, 2, 3, 15, 0))
+    assert weights == [0.75, 0.25]
+
+
+def test_volume_profile_without_data_is_uniform():
+    weights = execution.volume_profile([], slices=4, horizon_minutes=20, start=datetime(2025, 2, 3, 15, 0))
+    assert weights == [0.25] * 4
+
+
+def test_vwap_schedule_follows_weights():
+    schedule = execution.vwap_schedule(100, 20, [0.75, 0.25], start=datetime(2025, 2, 3, 15, 0))
+    assert [child['qty'] for child in schedule] == [75.0, 25.0]
@@ -0,0 +1,51 @@
+import pytest
+from datetime import date
+from nexus.helpers import futures
+from nexus.helpers.domain import FuturesContract, RollSchedule
+
+SCHEDULE = RollSchedule('ES', months=(3, 6), roll_days_before_expiry=8)
+MARCH = FuturesContract('ESH25', 'ES', date(2025, 3, 21), 50.0, 'CME')
+JUNE = FuturesContract('ESM25', 'ES', date(2025, 6, 20), 50.0, 'CME')
+
+
+def bar(day, close):
+    return {'timestamp': f'{day}T00:00:00+00:00', 'open': close, 'high': close, 'low': close, 'close': close}
+
+
+def test_contract_symbols():
+    assert futures.contract_symbol('ES', 2025, 3) == 'ESH25'
+    assert futures.parse_contract_symbol('RTYZ25') == ('RTY', 2025, 12)
+    assert futures.is_contract_symbol('ESH25')
+    assert not futures.is_contract_symbol('AAPL')
+    with pytest.raises(ValueError):
+        futures.parse_contract_symbol('AAPL')
+
+
+def test_chain_and_active_contract_follow_roll_schedule():
+    chain = futures.contract_chain(SCHEDULE, date(2025, 1, 1), date(2025, 4, 1))
+    assert [contract.symbol for contract in chain] == ['ESH25', 'ESM25']
+    assert chain[0].expiration == date(2025, 3, 21)
+    assert futures.active_contract(chain, date(2025, 3, 12), SCHEDULE).symbol == 'ESH25'
+    assert futures.active_contract(chain, date(2025, 3, 13), SCHEDULE).symbol == 'ESM25'
+
+
+def test_continuous_series_back_adjusts_at_roll():
+    bars = {
+        'ESH25': [bar('2025-03-11', 100.0), bar('2025-03-12', 102.0), bar('2025-03-13', 103.0)],
+        'ESM25': [bar('2025-03-12', 104.0), bar('2025-03-13', 105.0)],
+    }
+    ratio = futures.continuous_series(bars, [MARCH, JUNE], SCHEDULE)
+    assert [b['contract'] for b in ratio] == ['ESH25', 'ESH25', 'ESM25']
+    assert [b['close'] for b in ratio] == pytest.approx([100.0 * 104 / 102, 104.0, 105.0])
+    difference = futures.continuous_series(bars, [MARCH, JUNE], SCHEDULE, adjustment='difference')
+    assert [b['close'] for b in difference] == [102.0, 104.0, 105.0]
+    assert ratio[0]['symbol'] == 'ES'
+    with pytest.raises(ValueError):
+        futures.continuous_series(bars, [MARCH, JUNE], SCHEDULE, adjustment='linear')
+
+
+def test_calendar_spread():
+    spread = futures.calendar_spread(
+        [bar('2025-03-11', 100.0), bar('2025-03-12', 102.0)], [bar('2025-03-12', 104.0)]
+    )
+    assert spread == [{'timestamp': '2025-03-12T00:00:00+00:00', 'near': 102.0, 'far': 104.0, 'spread': 2.0}]
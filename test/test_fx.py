@@ -0,0 +1,61 @@
+import pytest
+from nexus.helpers import fx
+
+
+@pytest.fixture(autouse=True)
+def reset_rates(monkeypatch):
+    for name in ('BASE_CURRENCY', 'FX_RATES', 'INSTRUMENT_CURRENCIES', 'TENANT'):
+        monkeypatch.delenv(name, raising=False)
+    yield
+    fx.set_rates(None)
+
+
+def test_currency_of_instruments(monkeypatch):
+    monkeypatch.setenv('INSTRUMENT_CURRENCIES', 'SHOP.TO:CAD')
+    assert fx.currency_of('SHOP.TO') == 'CAD'
+    assert fx.currency_of('BTC/EUR') == 'EUR'
+    assert fx.currency_of('AAPL') == 'USD'
+
+
+def test_rates_invert_and_cross_through_pivot(monkeypatch):
+    monkeypatch.setenv('FX_RATES', 'EURUSD=1.10,USDCAD=1.25')
+    rates = fx.FXRates()
+    assert rates.rate('USD', 'USD') == 1.0
+    assert rates.rate('EUR', 'USD') == 1.10
+    assert rates.rate('USD', 'EUR') == pytest.approx(1 / 1.10)
+    assert rates.rate('EUR', 'CAD') == pytest.approx(1.10 * 1.25)
+    with pytest.raises(ValueError):
+        rates.rate('JPY', 'USD')
+
+
+def test_rates_are_cached_for_ttl():
+    calls = []
+    now = [0.0]
+    rates = fx.FXRates(source=lambda base, quote: calls.append((base, quote)) or 2.0, ttl_seconds=60, monotonic=lambda: now[0])
+    rates.rate('GBP', 'USD')
+    now[0] = 30
+    rates.rate('GBP', 'USD')
+    assert len(calls) == 1
+    now[0] = 61
+    rates.rate('GBP', 'USD')
+    assert len(calls) == 2
+
+
+def test_to_base_uses_instrument_currency(monkeypatch):
+    monkeypatch.setenv('BASE_CURRENCY', 'eur')
+    monkeypatch.setenv('FX_RATES', 'EURUSD=1.25')
+    assert fx.get_base_currency() == 'EUR'
+    assert fx.to_base(125.0, 'AAPL') == pytest.approx(100.0)
+    assert fx.to_base(100.0, 'BTC/EUR') == 100.0
+
+
+def test_portfolio_pnl_is_in_base_currency(monkeypatch):
+    from nexus.helpers import logger, strategy
+    monkeypatch.setenv('FX_RATES', 'EURUSD=1.10')
+    state = strategy.TradingStateManager(logger=logger.Logger('test_fx.py'))
+    state.update_position('BTC/EUR', 1, 100.0)
+    assert state.positions['BTC/EUR']['currency'] == 'EUR'
+    state.mark_price('BTC/EUR', 110.0)
+    assert state.total_pnl() == pytest.approx(11.0)
+    state.update_position('BTC/EUR', -1, 120.0)
+    assert state.realized_pnl == pytest.approx(22.0)
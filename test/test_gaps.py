@@ -0,0 +1,46 @@
+from datetime import datetime, timedelta
+from nexus.helpers import gaps
+
+START = datetime(2025, 2, 3, 15, 0)
+
+
+def minute(n):
+    return START + timedelta(minutes=n)
+
+
+def test_in_sequence_bars_are_not_flagged():
+    detector = gaps.GapDetector()
+    assert [detector.observe('AAPL', minute(n)) for n in range(3)] == [None, None, None]
+    assert detector.count('AAPL') == 3
+    assert detector.last_seen('AAPL') == minute(2)
+
+
+def test_gap_reports_missing_interval():
+    detector = gaps.GapDetector(min_missing=2)
+    detector.observe('AAPL', minute(0))
+    assert detector.observe('AAPL', minute(2)) is None  # one skipped bar is tolerated
+    gap = detector.observe('AAPL', minute(6))
+    assert gap == {'kind': 'gap', 'symbol': 'AAPL', 'start': minute(2), 'end': minute(6), 'missing': 3}
+
+
+def test_out_of_order_does_not_move_last_seen():
+    detector = gaps.GapDetector()
+    detector.observe('AAPL', minute(5))
+    assert detector.observe('AAPL', minute(4))['kind'] == 'out_of_order'
+    assert detector.observe('AAPL', minute(5))['kind'] == 'out_of_order'
+    assert detector.last_seen('AAPL') == minute(5)
+
+
+def test_overnight_step_is_not_a_gap():
+    detector = gaps.GapDetector()
+    detector.observe('AAPL', minute(0))
+    assert detector.observe('AAPL', minute(0) + timedelta(hours=17)) is None
+
+
+def test_advance_only_moves_forward():
+    detector = gaps.GapDetector()
+    detector.observe('AAPL', minute(5))
+    detector.advance('AAPL', minute(3))
+    assert detector.last_seen('AAPL') == minute(5)
+    detector.advance('AAPL', minute(9))
+    assert detector.observe('AAPL', minute(10)) is None
@@ -0,0 +1,49 @@
+from datetime import datetime, timezone
+import pytest
+from nexus.helpers import indicators
+
+
+def bar(high, low, close):
+    return {'symbol': 'AAPL', 'open': close, 'high': high, 'low': low, 'close': close, 'volume': 100}
+
+
+def test_moving_averages():
+    assert indicators.sma([1, 2, 3, 4, 5], 3) == [None, None, 2, 3, 4]
+    # Seeded with the SMA of the first three, then alpha 0.5
+    assert indicators.ema([1, 2, 3, 4, 5], 3) == [None, None, 2, 3, 4]
+    assert indicators.ema([2, 2, 2, 10], 3)[-1] == 6
+    Bar = indicators.inputs.Bar
+    bars = [Bar('AAPL', datetime(2025, 2, 3, tzinfo=timezone.utc), 1, 1, 1, close, 1) for close in (1, 2, 3)]
+    assert indicators.sma(bars, 3)[-1] == 2
+
+
+def test_macd_converges_to_zero_on_a_flat_series():
+    result = indicators.macd([100.0] * 40, fast=3, slow=6, signal=3)
+    assert result['macd'][:5] == [None] * 5
+    assert result['macd'][5] == 0
+    assert result['signal'][7] == 0 and result['signal'][6] is None
+    rising = indicators.macd(list(range(1, 41)), fast=3, slow=6, signal=3)
+    assert rising['macd'][-1] > 0
+    assert rising['histogram'][-1] == pytest.approx(0, abs=1e-9)
+
+
+def test_rsi():
+    assert indicators.rsi([1, 2, 3, 4, 5], period=3)[3:] == [100, 100]
+    assert indicators.rsi([5, 4, 3, 2], period=3)[-1] == 0
+    assert indicators.rsi([3, 3, 3, 3], period=3)[-1] == 50
+    # Gains of 2 and a loss of 1 average to RS 4
+    values = indicators.rsi([10, 12, 11, 13], period=3)
+    assert values[:3] == [None] * 3
+    assert values[3] == pytest.approx(80)
+    assert indicators.rsi([1, 2], period=3) == [None, None]
+
+
+def test_atr_and_stochastic():
+    bars = [bar(11, 9, 10), bar(12, 10, 11), bar(15, 12, 14), bar(14, 8, 9)]
+    assert indicators.true_range(bars) == [2, 2, 4, 6]
+    assert indicators.atr(bars, period=2) == [None, 2, 3, 4.5]
+
+    stochastic = indicators.stochastic(bars, k_period=2, d_period=2)
+    assert stochastic['k'] == [None, pytest.approx(200 / 3), pytest.approx(80), pytest.approx(100 / 7)]
+    assert stochastic['d'][:2] == [None, None]
+    assert stochastic['d'][2] == pytest.approx((200 / 3 + 80) / 2)
@@ -0,0 +1,123 @@
+from types import SimpleNamespace
+from nexus.helpers import envelope, kafka
+
+
+class FakeError:
+    def code(self):
+        return 'MSG_SIZE_TOO_LARGE'
+
+    def str(self):
+        return 'Message size too large'
+
+
+class FakeProducer:
+    def __init__(self):
+        self.produced = []
+        self.callbacks = []
+
+    def produce(self, topic, value, key, on_delivery):
+        self.produced.append((topic, key, value.decode()))
+        self.callbacks.append(on_delivery)
+
+    def flush(self, timeout):
+        for i, callback in enumerate(self.callbacks):
+            callback(FakeError() if i == 1 else None, None)
+        self.callbacks = []
+        return 0
+
+
+class FakeMessage:
+    def __init__(self, topic, offset, value):
+        self._topic, self._offset, self._value = topic, offset, value
+
+    def error(self):
+        return None
+
+    def topic(self):
+        return self._topic
+
+    def partition(self):
+        return 0
+
+    def offset(self):
+        return self._offset
+
+    def value(self):
+        return self._value.encode()
+
+    def timestamp(self):
+        return 1, 1704205800000
+
+
+class FakeConsumer:
+    def __init__(self, messages):
+        self.messages = messages
+        self.subscribed = []
+        self.committed = []
+        self.seeks = []
+
+    def subscribe(self, topics, on_revoke=None):
+        self.subscribed = topics
+        self.on_revoke = on_revoke
+
+    def seek(self, partition):
+        self.seeks.append((partition.topic, partition.partition, partition.offset))
+
+    def consume(self, num_messages, timeout):
+        taken, self.messages = self.messages[:num_messages], self.messages[num_messages:]
+        return taken
+
+    def commit(self, message, asynchronous):
+        self.committed.append(message.offset())
+
+
+def test_messages_go_to_a_topic_per_kind_keyed_by_symbol(monkeypatch):
+    producer = FakeProducer()
+    monkeypatch.setattr(kafka, 'producer', producer)
+    bar = envelope.encode('bar', {'symbol': 'AAPL', 'close': 190.5}, 'data')
+    heartbeat = envelope.encode('heartbeat', {'status': 'streaming', 'subscribed_symbols': 1}, 'data')
+    response = kafka.publish_batch([bar, heartbeat], 'nexus.data')
+    assert producer.produced == [('nexus.data.bar', b'AAPL', bar), ('nexus.data.heartbeat', None, heartbeat)]
+    assert response['Successful'] == [{'Id': '0'}]
+    assert response['Failed'] == [{'Id': '1', 'Code': 'MSG_SIZE_TOO_LARGE', 'Message': 'Message size too large'}]
+
+
+def test_poller_returns_sqs_shaped_messages_and_commits_on_delete():
+    bar = envelope.encode('bar', {'symbol': 'AAPL', 'close': 190.5}, 'data')
+    consumer = FakeConsumer([FakeMessage('nexus.data.bar', 41, bar), FakeMessage('nexus.data.bar', 42, bar)])
+    poller = kafka.KafkaPoller(['nexus.data.bar'], 'reversion', max_batch=1, consumer=consumer)
+    assert consumer.subscribed == ['nexus.data.bar']
+    messages = poller.poll()
+    assert len(messages) == 1
+    assert envelope.decode_sqs(messages[0])['payload']['symbol'] == 'AAPL'
+    assert messages[0]['Attributes']['SentTimestamp'] == '1704205800000'
+    poller.delete(messages[0]['ReceiptHandle'])
+    poller.delete(messages[0]['ReceiptHandle'])
+    assert consumer.committed == [41]
+
+
+def test_offsets_are_only_committed_past_handled_messages():
+    bar = envelope.encode('bar', {'symbol': 'AAPL', 'close': 190.5}, 'data')
+    consumer = FakeConsumer([FakeMessage('nexus.data.bar', offset, bar) for offset in (41, 42, 43)])
+    poller = kafka.KafkaPoller(['nexus.data.bar'], 'reversion', visibility_seconds=60, consumer=consumer)
+    first, second, third = poller.poll()
+    poller.delete(third['ReceiptHandle'])
+    poller.delete(second['ReceiptHandle'])
+    assert consumer.committed == []
+    poller.delete(first['ReceiptHandle'])
+    assert consumer.committed == [43]
+
+
+def test_unhandled_messages_are_delivered_again():
+    bar = envelope.encode('bar', {'symbol': 'AAPL', 'close': 190.5}, 'data')
+    consumer = FakeConsumer([FakeMessage('nexus.data.bar', offset, bar) for offset in (41, 42)])
+    poller = kafka.KafkaPoller(['nexus.data.bar'], 'reversion', visibility_seconds=0, consumer=consumer)
+    first, second = poller.poll()
+    poller.delete(first['ReceiptHandle'])
+    assert consumer.committed == [41]
+    poller.poll()
+    assert consumer.seeks == [('nexus.data.bar', 0, 42)]
+    poller.delete(second['ReceiptHandle'])
+    assert consumer.committed == [41]
+    consumer.on_revoke(consumer, [SimpleNamespace(topic='nexus.data.bar', partition=0)])
+    assert poller._pending == {}
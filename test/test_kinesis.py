@@ -0,0 +1,30 @@
+import pytest
+from nexus.helpers import envelope, kinesis
+
+
+def test_messages_are_partitioned_by_symbol():
+    bar = envelope.encode('bar', {'symbol': 'AAPL', 'close': 190.5, 'timestamp': '2024-01-02T14:30:00+00:00'}, 'data')
+    heartbeat = envelope.encode('heartbeat', {'status': 'streaming', 'subscribed_symbols': 2}, 'data')
+    assert kinesis.partition_key(bar) == 'AAPL'
+    assert kinesis.partition_key(heartbeat) == 'heartbeat'
+    assert len(kinesis.partition_key('not a message')) == 32
+
+
+def test_batch_response_matches_sns(monkeypatch):
+    put = []
+
+    def publish_kinesis_records(records, stream):
+        put.append((records, stream))
+        return {'FailedRecordCount': 1, 'Records': [
+            {'SequenceNumber': '1', 'ShardId': 'shardId-000000000000'},
+            {'ErrorCode': 'ProvisionedThroughputExceededException', 'ErrorMessage': 'Rate exceeded for shard'},
+        ]}
+
+    monkeypatch.setattr(kinesis.cloud, 'publish_kinesis_records', publish_kinesis_records)
+    messages = [envelope.encode('bar', {'symbol': symbol, 'close': 1.0}, 'data') for symbol in ('AAPL', 'MSFT')]
+    response = kinesis.publish_batch(messages, 'quotes')
+    assert put == [([(messages[0], 'AAPL'), (messages[1], 'MSFT')], 'quotes')]
+    assert response['Successful'] == [{'Id': '0', 'MessageId': '1', 'ShardId': 'shardId-000000000000'}]
+    assert [entry['Id'] for entry in response['Failed']] == ['1']
+    with pytest.raises(Exception, match='Rate exceeded'):
+        kinesis.publish(messages[1], 'quotes')
@@ -0,0 +1,43 @@
+import threading
+import pytest
+from nexus.helpers import latest_prices
+
+
+def test_sink_coalesces_to_the_newest_record_per_symbol():
+    updates = []
+    sink = latest_prices.LatestPriceSink('latest', flush_seconds=60, update=lambda *args, **kwargs: updates.append((args, kwargs)))
+    sink.record('quotes', {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:01+00:00', 'bid_price': 99.9, 'ask_price': 100.1})
+    sink.record('quotes', {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:00+00:00', 'bid_price': 99.0, 'ask_price': 99.2})
+    sink.record('trades', {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:00+00:00', 'price': 100.0})
+    assert updates == []
+    sink.flush()
+    assert len(updates) == 2
+    (table, key, _, values), kwargs = updates[0]
+    assert table == 'latest' and key == {'symbol': 'AAPL'}
+    assert values[':record']['bid_price'] == 99.9 and kwargs['names']['#record'] == 'quote'
+    assert 'attribute_not_exists(#record)' in kwargs['condition']
+    assert sink.pending == {}
+
+
+def test_mark_uses_the_newest_price():
+    item = {
+        'trade': {'timestamp': '2024-01-02T15:30:00+00:00', 'price': 100.0},
+        'quote': {'timestamp': '2024-01-02T15:30:01+00:00', 'bid_price': 100.1, 'ask_price': 100.3},
+        'bar': {'timestamp': '2024-01-02T15:29:00+00:00', 'close': 99.5},
+    }
+    assert latest_prices.mark(item) == pytest.approx(100.2)
+    assert latest_prices.mark({'bar': item['bar']}) == 99.5
+    assert latest_prices.mark({}) is None
+
+
+def test_records_are_written_by_the_timer_not_the_caller():
+    writers, written = [], threading.Event()
+
+    def update(*args, **kwargs):
+        writers.append(threading.current_thread())
+        written.set()
+
+    sink = latest_prices.LatestPriceSink('latest', flush_seconds=0.01, update=update)
+    sink.record('trades', {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:00+00:00', 'price': 100.0})
+    assert written.wait(1)
+    assert writers[0] is not threading.current_thread()
@@ -0,0 +1,48 @@
+import pytest
+from nexus.helpers import liveness
+
+
+class FakeLogger:
+    def __init__(self):
+        self.warnings = []
+
+    def warning(self, message):
+        self.warnings.append(message)
+
+    def info(self, message):
+        pass
+
+    def error(self, message):
+        pass
+
+
+def test_heartbeat_reports_rate_and_last_message():
+    heartbeat = liveness.StreamHeartbeat()
+    heartbeat._last_beat = 1_000
+    heartbeat.set_status('streaming')
+    for second in range(10):
+        heartbeat.record_message(now=1_000 + second)
+    beat = heartbeat.beat(5, now=1_010)
+    assert beat['connected'] and beat['subscribed_symbols'] == 5
+    assert beat['messages'] == 10 and beat['messages_per_second'] == pytest.approx(1.0)
+    assert beat['last_message_age_seconds'] == 1.0
+    assert heartbeat.beat(5, now=1_020)['messages'] == 0
+    with pytest.raises(ValueError):
+        heartbeat.set_status('dead')
+
+
+def test_feed_is_dead_when_silent_reconnecting_or_starved(monkeypatch):
+    alerts = []
+    monkeypatch.setattr(liveness.cloud, 'publish_alert', lambda subject, details: alerts.append(subject))
+    feed = liveness.FeedLiveness(FakeLogger(), max_silence_seconds=60, max_message_age_seconds=180)
+    healthy = {'status': 'streaming', 'subscribed_symbols': 5, 'last_message_age_seconds': 30.0}
+    feed.observe(healthy, now=0)
+    assert feed.check(now=10)
+    assert not feed.check(now=61) and not feed.check(now=62)
+    feed.observe({'status': 'waiting', 'subscribed_symbols': 5, 'last_message_age_seconds': None}, now=70)
+    assert feed.check(now=71)
+    feed.observe({**healthy, 'status': 'reconnecting'}, now=80)
+    assert feed.problem(now=81) == 'stream reconnecting'
+    feed.observe({**healthy, 'last_message_age_seconds': 600.0}, now=90)
+    assert feed.problem(now=91) == 'no messages for 600 seconds'
+    assert alerts == ['Market data feed dead', 'Market data feed recovered']
@@ -0,0 +1,38 @@
+from datetime import datetime, timedelta, timezone
+from nexus.helpers import lookback
+
+
+def test_window_from_half_life():
+    assert lookback.window_from_half_life(15, multiplier=2) == 30
+    assert lookback.window_from_half_life(1, multiplier=2, min_window=10) == 10
+    assert lookback.window_from_half_life(500, max_window=120) == 120
+    assert lookback.window_from_half_life(None, default=20) == 20
+    assert lookback.window_from_half_life(float('nan'), default=20) == 20
+
+
+def test_adaptive_lookback_reestimates_on_schedule(monkeypatch):
+    half_lives = iter([12.0, 30.0])
+    calls = []
+
+    def half_life(closes):
+        calls.append(len(closes))
+        return next(half_lives)
+
+    monkeypatch.setattr(lookback.statistics, 'half_life', half_life)
+    adaptive = lookback.AdaptiveLookback(multiplier=2, reestimate_seconds=600)
+    start = datetime(2025, 2, 3, 15, 0, tzinfo=timezone.utc)
+    closes = [100.0] * 60
+    assert adaptive.window('AAPL', closes, start) == 24
+    assert adaptive.window('AAPL', closes, start + timedelta(minutes=5)) == 24
+    assert len(calls) == 1
+    assert adaptive.window('AAPL', closes, start + timedelta(minutes=10)) == 60
+    assert len(calls) == 2
+
+
+def test_adaptive_lookback_falls_back_to_default(monkeypatch):
+    def failing(closes):
+        raise ValueError('singular')
+
+    monkeypatch.setattr(lookback.statistics, 'half_life', failing)
+    adaptive = lookback.AdaptiveLookback(default=20)
+    assert adaptive.window('AAPL', [100.0] * 60, datetime(2025, 2, 3, tzinfo=timezone.utc)) == 20
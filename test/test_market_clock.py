@@ -0,0 +1,73 @@
+from datetime import datetime, timedelta, timezone
+from threading import Event
+from nexus.helpers import market_clock
+
+NOW = datetime(2025, 2, 3, 15, 0, tzinfo=timezone.utc)
+
+
+class FakeTime:
+    def __init__(self):
+        self.now = 0.0
+
+    def __call__(self):
+        return self.now
+
+
+def closed_clock(timestamp=NOW, minutes_to_open=10):
+    return {
+        'is_open': False,
+        'timestamp': timestamp,
+        'next_open': NOW + timedelta(minutes=minutes_to_open),
+        'next_close': NOW + timedelta(hours=7),
+    }
+
+
+def test_clock_is_reused_within_ttl_and_advanced():
+    fetches = []
+    fake_time = FakeTime()
+    cache = market_clock.CachedClock(lambda: fetches.append(1) or closed_clock(), ttl_seconds=30, monotonic=fake_time)
+    cache.get()
+    fake_time.now = 20
+    clock = cache.get()
+    assert len(fetches) == 1
+    assert clock['timestamp'] == NOW + timedelta(seconds=20)
+    fake_time.now = 31
+    cache.get()
+    assert len(fetches) == 2
+
+
+def test_clock_is_refetched_at_session_boundary():
+    fetches = []
+    fake_time = FakeTime()
+    cache = market_clock.CachedClock(
+        lambda: fetches.append(1) or closed_clock(minutes_to_open=0.25), ttl_seconds=60, monotonic=fake_time
+    )
+    cache.get()
+    fake_time.now = 10
+    cache.get()
+    assert len(fetches) == 1
+    fake_time.now = 16
+    cache.get()
+    assert len(fetches) == 2
+
+
+def test_session_utilities():
+    clock = closed_clock()
+    assert market_clock.next_open(clock) == NOW + timedelta(minutes=10)
+    assert market_clock.seconds_till_open(clock) == 600
+    assert not market_clock.is_within_last_minutes_of_session(clock, 15)
+    open_clock = {**clock, 'is_open': True, 'next_close': NOW + timedelta(minutes=10)}
+    assert market_clock.seconds_till_open(open_clock) == 0
+    assert market_clock.is_within_last_minutes_of_session(open_clock, 15)
+    assert not market_clock.is_within_last_minutes_of_session(open_clock, 5)
+
+
+def test_sleep_until_open_waits_for_open_and_honors_stop():
+    clocks = [closed_clock(), {**closed_clock(), 'is_open': True}]
+    stop = Event()
+    waits = []
+    stop.wait = lambda seconds: waits.append(seconds)
+    assert market_clock.sleep_until_open(lambda: clocks.pop(0), stop)
+    assert waits == [600]
+    stop.set()
+    assert not market_clock.sleep_until_open(lambda: closed_clock(), stop)
@@ -0,0 +1,39 @@
+from datetime import datetime, timedelta, timezone
+from nexus.helpers import market_data
+from nexus.helpers.bar_cache import BarCache
+
+
+def bar(minute, close, volume=10):
+    timestamp = datetime(2025, 2, 3, 15, 0, tzinfo=timezone.utc) + timedelta(minutes=minute)
+    return {
+        'symbol': 'AAPL', 'timestamp': timestamp.isoformat(), 'open': close, 'high': close,
+        'low': close, 'close': close, 'volume': volume, 'trade_count': 1
+    }
+
+
+def test_aggregate_bars_into_buckets():
+    five = market_data.aggregate_bars([bar(n, 100 + n) for n in range(7)], 5)
+    assert [b['timestamp'][11:16] for b in five] == ['15:00', '15:05']
+    assert five[0]['open'] == 100 and five[0]['close'] == 104 and five[0]['high'] == 104
+    assert five[0]['volume'] == 50 and five[1]['volume'] == 20
+
+
+def test_get_series_combines_history_and_live_bars():
+    mock = market_data.brokers.MockBroker()
+    now = datetime.now(timezone.utc).replace(second=0, microsecond=0)
+    history = [
+        {**bar(0, 100 + n), 'timestamp': (now - timedelta(minutes=10 - n)).isoformat()}
+        for n in range(5)
+    ]
+    mock.bars = {'AAPL': history}
+    market_data.brokers.set_broker(mock)
+    try:
+        cache = BarCache()
+        cache.add({**bar(0, 200), 'timestamp': now.isoformat()})
+        data = market_data.MarketData(cache)
+        assert data.closes('AAPL', '1Min', lookback=4) == [102, 103, 104, 200]
+        # History is fetched once, later calls read the cache
+        mock.bars = {}
+        assert len(data.get_series('AAPL', '1Min', lookback=10)) == 6
+    finally:
+        market_data.brokers.set_broker(None)
@@ -0,0 +1,62 @@
+import pytest
+from nexus.helpers import metrics
+
+
+@pytest.fixture(autouse=True)
+def clean_registry():
+    metrics.reset()
+    yield
+    metrics.reset()
+
+
+def test_increment_counter():
+    metrics.increment('orders_placed')
+    metrics.increment('orders_placed', 2)
+    assert metrics.get_counter('orders_placed') == 3
+    assert metrics.get_counter('never_incremented') == 0
+
+
+def test_counters_are_separated_by_labels():
+    metrics.increment('publish_failures', symbol='AAPL')
+    metrics.increment('publish_failures', symbol='MSFT')
+    metrics.increment('publish_failures', symbol='MSFT')
+    assert metrics.get_counter('publish_failures', symbol='AAPL') == 1
+    assert metrics.get_counter('publish_failures', symbol='MSFT') == 2
+
+
+def test_record_symbol_event():
+    metrics.record_symbol_event('AAPL', 'bars')
+    metrics.record_symbol_event('AAPL', 'bars')
+    metrics.record_symbol_event('AAPL', 'signals')
+    metrics.record_symbol_event('TSLA', 'bars')
+
+    stats = metrics.symbol_stats()
+    assert stats['AAPL']['bars'] == 2
+    assert stats['AAPL']['signals'] == 1
+    assert stats['TSLA']['bars'] == 1
+    assert 'last_seen' in stats['AAPL']
+    assert metrics.get_counter('symbol_bars', symbol='AAPL') == 2
+    assert metrics.symbol_stats('TSLA') == {'TSLA': stats['TSLA']}
+
+
+def test_snapshot():
+    metrics.increment('orders_placed', symbol='AAPL')
+    metrics.set_gauge('window_memory_bytes', 1024)
+    snapshot = metrics.snapshot()
+    assert snapshot['counters'] == [{'name': 'orders_placed', 'labels': {'symbol': 'AAPL'}, 'value': 1}]
+    assert snapshot['gauges'] == [{'name': 'window_memory_bytes', 'labels': {}, 'value': 1024}]
+
+
+def test_histogram_counts_observations_at_or_below_each_bound():
+    for value in (0.0005, 0.001, 0.2, 30):
+        metrics.observe('handler_publish_seconds', value, type='bars')
+    histogram = metrics.get_histogram('handler_publish_seconds', type='bars')
+    assert histogram['count'] == 4
+    assert histogram['sum'] == pytest.approx(30.2015)
+    assert histogram['buckets']['0.001'] == 2
+    assert histogram['buckets']['0.25'] == 3
+    assert histogram['buckets']['10'] == 3 and histogram['buckets']['+Inf'] == 4
+    assert metrics.get_histogram('handler_publish_seconds', type='quotes')['count'] == 0
+    assert metrics.snapshot()['histograms'] == [
+        {'name': 'handler_publish_seconds', 'labels': {'type': 'bars'}, 'value': histogram}
+    ]
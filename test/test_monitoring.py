@@ -0,0 +1,74 @@
+from datetime import datetime, timezone
+from nexus.helpers import monitoring
+
+BAR = '2025-02-03T15:00:00+00:00'
+BAR_CLOSE = datetime(2025, 2, 3, 15, 1, tzinfo=timezone.utc).timestamp()
+
+
+class FakeLogger:
+    def __init__(self):
+        self.warnings = []
+
+    def warning(self, message):
+        self.warnings.append(message)
+
+    def error(self, message):
+        pass
+
+    def info(self, message):
+        pass
+
+
+def test_lag_alerts_once_and_again_on_recovery(monkeypatch):
+    queue = {'visible': 250}
+    alerts = []
+    monkeypatch.setattr(monitoring.cloud, 'get_queue_attributes', lambda queue_url: queue)
+    monkeypatch.setattr(monitoring.cloud, 'publish_alert', lambda subject, stats: alerts.append(subject))
+    logger = FakeLogger()
+    monitor = monitoring.QueueLagMonitor('queue', logger, max_backlog=100, check_interval_seconds=0)
+    assert monitor.check()['backlog'] == 250 and monitor.alerting
+    monitor.check()
+    queue['visible'] = 0
+    assert monitor.check()['backlog'] == 0 and not monitor.alerting
+    assert alerts == ['SQS consumer lagging', 'SQS consumer recovered']
+    assert len(logger.warnings) == 1
+
+
+def test_latency_is_measured_from_bar_close():
+    budget = monitoring.LatencyBudget(FakeLogger())
+    assert budget.elapsed_ms(BAR, now=BAR_CLOSE + 0.25) == 250
+
+
+def test_within_budget_does_not_alert(monkeypatch):
+    alerts = []
+    monkeypatch.setattr(monitoring.cloud, 'publish_alert', lambda subject, stats: alerts.append(stats))
+    budget = monitoring.LatencyBudget(FakeLogger(), budget_ms=500, skip_late=True)
+    assert budget.check('AAPL', BAR, now=BAR_CLOSE + 0.4)
+    assert budget.breaches == 0
+    assert alerts == []
+
+
+def test_late_signal_alerts_and_is_skipped_only_when_configured(monkeypatch):
+    alerts = []
+    monkeypatch.setattr(monitoring.cloud, 'publish_alert', lambda subject, stats: alerts.append(stats))
+    logger = FakeLogger()
+    assert monitoring.LatencyBudget(logger, budget_ms=500).check('AAPL', BAR, now=BAR_CLOSE + 2)
+    skipping = monitoring.LatencyBudget(logger, budget_ms=500, skip_late=True)
+    assert not skipping.check('AAPL', BAR, now=BAR_CLOSE + 2)
+    assert skipping.breaches == 1
+    assert [alert['skipped'] for alert in alerts] == [False, True]
+    assert alerts[0]['latency_ms'] == 2000
+    assert len(logger.warnings) == 2
+
+
+def test_message_age_survives_empty_receives_until_the_queue_drains(monkeypatch):
+    monkeypatch.setattr(monitoring.cloud, 'publish_alert', lambda subject, stats: None)
+    backlog = [5]
+    monitor = monitoring.QueueLagMonitor('queue', FakeLogger(), check_interval_seconds=0, read_backlog=lambda: backlog[0])
+    sent = (monitoring.time.time() - 300) * 1000
+    monitor.record_messages([{'Attributes': {'SentTimestamp': str(int(sent))}}])
+    monitor.record_messages([])
+    assert monitor.oldest_message_age >= 300
+    assert monitor.check()['oldest_message_age'] >= 300 and monitor.alerting
+    backlog[0] = 0
+    assert monitor.check()['oldest_message_age'] == 0 and not monitor.alerting
@@ -0,0 +1,122 @@
+import asyncio
+from datetime import datetime, timezone
+from types import SimpleNamespace
+from nexus.helpers import envelope, nats_transport
+
+
+class FakeMessage:
+    def __init__(self, subject, data):
+        self.subject = subject
+        self.data = data
+        self.metadata = SimpleNamespace(timestamp=datetime(2024, 1, 2, 14, 30, tzinfo=timezone.utc))
+        self.acked = False
+
+    async def ack(self):
+        self.acked = True
+
+
+class FakeClient:
+    def __init__(self):
+        self.published = []
+        self.subscribers = []
+
+    async def publish(self, subject, data):
+        if subject.startswith('broken.'):
+            raise ConnectionError('nats: connection closed')
+        self.published.append(subject)
+        for prefix, callback in self.subscribers:
+            if subject.startswith(prefix):
+                await callback(FakeMessage(subject, data))
+
+    async def flush(self, timeout):
+        await asyncio.sleep(0)
+
+    async def subscribe(self, subject, queue, cb):
+        self.subscribers.append((subject.rstrip('>'), cb))
+
+
+class FakeSubscription:
+    def __init__(self, messages):
+        self.messages = messages
+
+    async def fetch(self, batch, timeout):
+        if not self.messages:
+            raise TimeoutError()
+        taken, self.messages = self.messages[:batch], self.messages[batch:]
+        return taken
+
+    async def consumer_info(self):
+        return SimpleNamespace(num_pending=len(self.messages))
+
+
+class FakeJetStream:
+    def __init__(self, messages):
+        self.subscription = FakeSubscription(messages)
+        self.durables = []
+        self.streams = {}
+
+    async def add_stream(self, name, subjects):
+        if name in self.streams and self.streams[name] != subjects:
+            raise ValueError('nats: stream name already in use with a different configuration')
+        self.streams[name] = subjects
+
+    async def stream_info(self, name):
+        if name not in self.streams:
+            raise ValueError('nats: stream not found')
+        return SimpleNamespace(config=SimpleNamespace(name=name, subjects=self.streams[name]))
+
+    async def publish(self, subject, data):
+        return SimpleNamespace(stream='NEXUS', seq=7)
+
+    async def pull_subscribe(self, subject, durable):
+        self.durables.append(durable)
+        return self.subscription
+
+
+def test_core_nats_publishes_per_symbol_subjects_and_delivers_to_the_group(monkeypatch):
+    client = FakeClient()
+    connection = nats_transport.Connection(jetstream=False, client=client)
+    monkeypatch.setattr(nats_transport, 'connection', connection)
+    poller = nats_transport.NatsPoller([nats_transport.wildcard('nexus.data', 'bar')], 'reversion', wait_seconds=0.1)
+    bar = envelope.encode('bar', {'symbol': 'AAPL', 'close': 190.5}, 'data')
+    heartbeat = envelope.encode('heartbeat', {'status': 'streaming', 'subscribed_symbols': 1}, 'data')
+    response = nats_transport.publish_batch([bar, heartbeat], 'nexus.data')
+    assert client.published == ['nexus.data.bar.AAPL', 'nexus.data.heartbeat._']
+    assert [entry['Id'] for entry in response['Successful']] == ['0', '1'] and response['Failed'] == []
+    assert nats_transport.publish_batch([bar], 'broken')['Failed'][0]['Code'] == 'ConnectionError'
+    assert poller.lag() == 1
+    messages = poller.poll()
+    assert [envelope.decode_sqs(message)['payload']['symbol'] for message in messages] == ['AAPL']
+    poller.delete(messages[0]['ReceiptHandle'])
+    assert poller.poll() == []
+
+
+def test_jetstream_messages_are_acknowledged_on_delete():
+    bar = envelope.encode('bar', {'symbol': 'AAPL', 'close': 190.5}, 'data')
+    message = FakeMessage('nexus.data.bar.AAPL', bar.encode())
+    client = FakeClient()
+    client.jetstream = lambda: FakeJetStream([message])
+    connection = nats_transport.Connection(jetstream=True, client=client)
+    poller = nats_transport.NatsPoller([nats_transport.wildcard('nexus.data', 'bar')], 'reversion', connection=connection)
+    assert connection.js.durables == ['reversion-nexus_data_bar__']
+    assert connection.js.streams == {'nexus_data': ['nexus.data.>']}
+    assert poller.lag() == 1
+    messages = poller.poll()
+    assert messages[0]['Attributes']['SentTimestamp'] == '1704205800000'
+    assert poller.poll() == []
+    poller.delete(messages[0]['ReceiptHandle'])
+    assert message.acked
+
+
+def test_jetstream_stream_is_created_once_per_destination(monkeypatch):
+    client = FakeClient()
+    client.jetstream = lambda: FakeJetStream([])
+    connection = nats_transport.Connection(jetstream=True, client=client)
+    monkeypatch.setattr(nats_transport, 'connection', connection)
+    connection.js.streams['nexus_quotes'] = ['nexus.quotes.>', 'legacy.>']
+    bar = envelope.encode('bar', {'symbol': 'AAPL', 'close': 190.5}, 'data')
+    nats_transport.publish_batch([bar], 'nexus.data')
+    nats_transport.publish_batch([bar], 'nexus.data')
+    nats_transport.publish_batch([bar], 'nexus.quotes')
+    assert connection.js.streams == {'nexus_data': ['nexus.data.>'], 'nexus_quotes': ['nexus.quotes.>', 'legacy.>']}
+    assert connection.streams == {'nexus.data', 'nexus.quotes'}
@@ -0,0 +1,24 @@
+from datetime import datetime, timedelta, timezone
+from nexus.helpers import news
+
+NOW = datetime(2025, 3, 3, 15, 0, tzinfo=timezone.utc)
+
+
+def article(symbols, published):
+    return {'type': 'news', 'headline': 'Headline', 'symbols': symbols, 'created_at': published.isoformat()}
+
+
+def test_headline_pauses_its_symbols_for_the_window():
+    guard = news.HeadlineGuard(pause_seconds=300)
+    guard.record(article(['AAPL', 'MSFT'], NOW))
+    assert guard.is_paused('AAPL', NOW + timedelta(seconds=60))
+    assert guard.is_paused('MSFT', NOW + timedelta(seconds=299))
+    assert not guard.is_paused('AAPL', NOW + timedelta(seconds=300))
+    assert not guard.is_paused('TSLA', NOW)
+
+
+def test_older_headline_does_not_shorten_pause():
+    guard = news.HeadlineGuard(pause_seconds=300)
+    guard.record(article(['AAPL'], NOW))
+    guard.record(article(['AAPL'], NOW - timedelta(minutes=10)))
+    assert guard.is_paused('AAPL', NOW + timedelta(seconds=120))
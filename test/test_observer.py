@@ -0,0 +1,58 @@
+import pytest
+from nexus.helpers import observer, brokers, strategy
+from alpaca.trading.enums import OrderSide
+
+
+class TradingClient:
+    def __init__(self):
+        self.submitted = []
+
+    def submit_order(self, order):
+        self.submitted.append(order)
+
+    def get_clock(self):
+        return 'clock'
+
+
+def test_trading_client_is_untouched_outside_observer_mode(monkeypatch):
+    monkeypatch.delenv('OBSERVER_MODE', raising=False)
+    client = TradingClient()
+    assert observer.wrap_trading_client(client) is client
+
+
+def test_trading_client_order_methods_are_disabled(monkeypatch):
+    monkeypatch.setenv('OBSERVER_MODE', 'True')
+    client = TradingClient()
+    wrapped = observer.wrap_trading_client(client)
+    assert wrapped.get_clock() == 'clock'
+    with pytest.raises(observer.ExecutionDisabled):
+        wrapped.submit_order('order')
+    assert client.submitted == []
+
+
+def test_observer_broker_reads_but_never_trades():
+    mock = brokers.MockBroker()
+    mock.set_price('AAPL', 100.0)
+    broker = brokers.ObserverBroker(mock)
+    assert broker.get_latest_price('AAPL') == 100.0
+    assert broker.is_market_open()
+    with pytest.raises(brokers.observer.ExecutionDisabled):
+        broker.submit_market_order('AAPL', 1, OrderSide.BUY)
+    with pytest.raises(brokers.observer.ExecutionDisabled):
+        broker.submit_limit_order('AAPL', 1, OrderSide.BUY, 99.0)
+    assert mock.orders == []
+
+
+def test_executor_validates_but_does_not_submit(monkeypatch):
+    monkeypatch.setenv('OBSERVER_MODE', 'True')
+    mock = brokers.MockBroker()
+    mock.set_price('AAPL', 100.0)
+    strategy.brokers.set_broker(mock)
+    try:
+        state = strategy.TradingStateManager(logger=observer.logger)
+        executor = strategy.OrderExecutor(state, strategy.RiskManager(state))
+        assert not executor.execute_market_order('AAPL', 1)
+        assert executor.execute_limit_order('AAPL', 1, 99.0) is None
+        assert mock.orders == [] and state.positions == {}
+    finally:
+        strategy.brokers.set_broker(None)
@@ -0,0 +1,23 @@
+import pytest
+from datetime import date
+from nexus.helpers import options
+
+
+def test_parse_option_symbol():
+    assert options.parse_option_symbol('AAPL250321C00150000') == {
+        'underlying': 'AAPL', 'expiration': date(2025, 3, 21), 'type': 'call', 'strike': 150.0
+    }
+    assert options.parse_option_symbol('SPY250117P00412500')['strike'] == 412.5
+
+
+def test_parse_option_symbol_rejects_stock_symbols():
+    with pytest.raises(ValueError):
+        options.parse_option_symbol('AAPL')
+
+
+def test_multileg_order_validates_legs():
+    leg = {'symbol': 'AAPL250321C00150000', 'side': None}
+    with pytest.raises(ValueError):
+        options.place_multileg_order([leg], qty=1)
+    with pytest.raises(ValueError):
+        options.place_multileg_order([leg, leg], qty=1)
@@ -0,0 +1,50 @@
+import pytest
+from nexus.helpers import broker, order_errors
+from alpaca.trading.enums import OrderSide
+
+
+class FakeAPIError(Exception):
+    def __init__(self, status_code, message, code=None):
+        super().__init__(message)
+        self.status_code = status_code
+        self.code = code
+
+
+def test_classify_maps_broker_responses_to_causes():
+    assert order_errors.classify(FakeAPIError(403, 'insufficient buying power')) is order_errors.InsufficientBuyingPower
+    assert order_errors.classify(FakeAPIError(422, 'asset "XYZ" is not tradable')) is order_errors.AssetNotTradable
+    assert order_errors.classify(
+        FakeAPIError(422, 'options market orders are only allowed during market hours')
+    ) is order_errors.MarketClosed
+    assert order_errors.classify(FakeAPIError(429, 'too many requests')) is order_errors.RateLimited
+    assert order_errors.classify(FakeAPIError(422, 'qty must be > 0')) is order_errors.OrderRejected
+    assert order_errors.classify(FakeAPIError(500, 'internal server error')) is None
+
+
+def test_wrap_finds_the_cause_through_chained_errors():
+    try:
+        try:
+            raise FakeAPIError(403, 'insufficient buying power', code=40310000)
+        except FakeAPIError as e:
+            raise Exception(f'Failed to submit order abc: {e}') from e
+    except Exception as e:
+        wrapped = order_errors.wrap(e, 'Failed to place market order')
+    assert isinstance(wrapped, order_errors.InsufficientBuyingPower)
+    assert wrapped.status_code == 403
+    assert wrapped.code == 40310000
+    assert str(wrapped) == 'Failed to place market order: Failed to submit order abc: insufficient buying power'
+
+    unknown = order_errors.wrap(ConnectionError('reset by peer'), 'Failed to place limit order')
+    assert type(unknown) is Exception
+    assert str(unknown) == 'Failed to place limit order: reset by peer'
+
+
+def test_place_market_order_raises_typed_error(monkeypatch):
+    class RejectingClient:
+        def submit_order(self, order):
+            raise FakeAPIError(422, 'asset "XYZ" is not active')
+
+    monkeypatch.setattr(broker, 'get_broker_client', lambda name: RejectingClient())
+    monkeypatch.setattr(broker, '_pre_trade_check', lambda *args, **kwargs: None)
+    with pytest.raises(broker.order_errors.AssetNotTradable):
+        broker.place_market_order('XYZ', 1, OrderSide.BUY)
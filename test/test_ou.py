@@ -0,0 +1,36 @@
+import math
+import pytest
+from nexus.helpers import ou
+
+
+def test_fit_ou_recovers_parameters():
+    path = ou.simulate_paths(theta=0.1, mu=5.0, sigma=0.5, x0=5.0, steps=20_000, paths=1, seed=1)[0]
+    params = ou.fit_ou(path)
+    assert params['theta'] == pytest.approx(0.1, rel=0.2)
+    assert params['mu'] == pytest.approx(5.0, abs=0.2)
+    assert params['sigma'] == pytest.approx(0.5, rel=0.1)
+    assert params['half_life'] == pytest.approx(math.log(2) / params['theta'])
+
+
+def test_fit_ou_rejects_random_walk():
+    with pytest.raises(ValueError):
+        ou.fit_ou([float(i) for i in range(100)])
+
+
+def test_simulate_trades():
+    params = {'theta': 0.1, 'mu': 0.0, 'sigma': 1.0}
+    result = ou.simulate_trades(params, entry_z=2.0, exit_z=0.0, stop_z=4.0, paths=500, seed=3)
+    assert result['win_rate'] > 0.9
+    assert result['expected_pnl'] > 0
+    assert 0 <= result['stop_out_probability'] < 0.1
+    assert result['holding_percentiles'][0.5] <= result['holding_percentiles'][0.99]
+    # Without a stop every trade reverts or times out
+    assert ou.simulate_trades(params, stop_z=None, paths=100, seed=3)['stop_out_probability'] == 0
+    with pytest.raises(ValueError):
+        ou.simulate_trades(params, entry_z=2.0, stop_z=1.0)
+
+
+def test_simulate_paths_is_reproducible():
+    first = ou.simulate_paths(0.2, 1.0, 0.3, 1.0, steps=10, paths=2, seed=9)
+    assert first == ou.simulate_paths(0.2, 1.0, 0.3, 1.0, steps=10, paths=2, seed=9)
+    assert len(first) == 2 and len(first[0]) == 11
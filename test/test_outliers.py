@@ -0,0 +1,36 @@
+import pytest
+from nexus.helpers import outliers
+
+
+def test_clip():
+    assert outliers.clip([1, 5, 10], lower=2, upper=8) == [2, 5, 8]
+    assert outliers.clip([1, 5, 10], upper=8) == [1, 5, 8]
+
+
+def test_winsorize_pulls_in_tails():
+    values = list(range(101))
+    result = outliers.winsorize(values, 0.05, 0.95)
+    assert min(result) == pytest.approx(5)
+    assert max(result) == pytest.approx(95)
+    assert result[50] == 50
+    with pytest.raises(ValueError):
+        outliers.winsorize(values, 0.9, 0.1)
+
+
+def test_median_filter_removes_spike():
+    assert outliers.median_filter([1, 1, 50, 1, 1], window=3) == [1, 1, 1, 1, 1]
+    # A level shift survives
+    assert outliers.median_filter([1, 1, 1, 5, 5, 5], window=3) == [1, 1, 1, 5, 5, 5]
+    with pytest.raises(ValueError):
+        outliers.median_filter([1, 2], window=4)
+
+
+def test_bad_ticks_only_flags_reverted_jumps():
+    prices = [100 + 0.1 * (i % 3) for i in range(30)]
+    prices[10] = 90.0
+    # A real move that holds
+    prices[20:] = [price + 10 for price in prices[20:]]
+    assert outliers.bad_ticks(prices) == [10]
+    cleaned = outliers.remove_bad_ticks(prices)
+    assert cleaned[10] == prices[9]
+    assert cleaned[20:] == prices[20:]
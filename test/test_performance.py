@@ -0,0 +1,58 @@
+import math
+import pytest
+from nexus.helpers import performance, statistics
+
+
+def test_ratios_of_a_return_series():
+    returns = [0.01, -0.005, 0.02, -0.01, 0.015]
+    mean = sum(returns) / 5
+    deviation = math.sqrt(sum((r - mean) ** 2 for r in returns) / 4)
+    assert performance.sharpe_ratio(returns) == pytest.approx(mean / deviation * math.sqrt(252))
+    downside = math.sqrt((0.005 ** 2 + 0.01 ** 2) / 5)
+    assert performance.sortino_ratio(returns) == pytest.approx(mean / downside * math.sqrt(252))
+    assert performance.sortino_ratio([0.01, 0.02]) == 0
+    assert performance.sharpe_ratio([0.01]) == 0
+
+
+def test_drawdown_and_calmar():
+    equity = [100, 120, 90, 110, 130, 117]
+    assert performance.max_drawdown(equity) == pytest.approx(0.25)
+    assert performance.returns_from_equity([100, 110, 99]) == pytest.approx([0.1, -0.1])
+    # One yearly period doubling equity
+    growth = performance.annualized_return([100, 200], periods_per_year=1)
+    assert growth == pytest.approx(1.0)
+    assert performance.calmar_ratio(equity, periods_per_year=5) == pytest.approx(0.17 / 0.25)
+    assert performance.calmar_ratio([100, 101, 102]) == 0
+
+
+def test_report_with_trades_and_exposure():
+    report = performance.report([100, 101, 100, 103], trade_pnls=[3, -1, 2, 0], positions=[0, 1, 1, 0])
+    assert report['hit_rate'] == 0.5
+    assert report['average_win'] == 2.5 and report['average_loss'] == -1
+    assert report['profit_factor'] == 5
+    assert report['pnl_skewness'] == pytest.approx(statistics.skewness([3, -1, 2, 0]))
+    assert report['exposure'] == 0.5
+    assert report['total_return'] == pytest.approx(0.03)
+    assert 'exposure' not in performance.report([100, 101])
+
+
+def test_underwater_curve():
+    curve = performance.underwater_curve([100, 120, 90, 130, 117])
+    assert curve == pytest.approx([0, 0, -0.25, 0, -0.1])
+    assert performance.max_drawdown([100, 110]) == 0
+
+
+def test_live_performance_samples_once_per_period_and_reports():
+    now = [0.0]
+    tracker = performance.register_tracker(
+        performance.LivePerformance('reversion', capital=100, period_seconds=60, monotonic=lambda: now[0])
+    )
+    for seconds, pnl in ((30, 5), (60, 1), (90, 4), (120, 3)):
+        now[0] = seconds
+        tracker.record_equity(pnl)
+    tracker.record_trade(3)
+    assert list(tracker.equity_curve) == [100, 101, 103]
+    report = performance.admin.routes[('GET', '/performance')](query={}, body={})['reversion']
+    assert report['samples'] == 3 and report['trades'] == 1
+    assert report['total_return'] == pytest.approx(0.03)
+    performance.trackers.clear()
@@ -0,0 +1,39 @@
+from nexus.helpers import polling
+
+
+class FakeQueue:
+    def __init__(self, visible):
+        self.visible = visible
+        self.calls = []
+
+    def receive(self, queue_url, max_messages=1, wait_time_seconds=10):
+        self.calls.append((max_messages, wait_time_seconds))
+        count = min(max_messages, self.visible)
+        self.visible -= count
+        return [{'MessageId': str(i)} for i in range(count)]
+
+
+def test_scales_up_under_backlog_and_back_to_idle():
+    queue = FakeQueue(visible=100)
+    poller = polling.AdaptivePoller('url', max_batch=10, max_workers=3, receive=queue.receive)
+    assert poller.idle
+    received = []
+    while queue.visible:
+        received.append(len(poller.poll()))
+    assert received[:6] == [1, 2, 4, 8, 10, 20]
+    assert poller.workers == 3 and poller.batch_size == 10
+    assert sum(received) == 100
+
+    poller.poll()
+    assert poller.idle
+    assert queue.calls[0] == (1, polling.MAX_SQS_WAIT_SECONDS)
+    assert queue.calls[1] == (2, 1)
+
+
+def test_known_backlog_skips_the_idle_long_poll():
+    queue = FakeQueue(visible=0)
+    poller = polling.AdaptivePoller('url', receive=queue.receive)
+    poller.poll(backlog=50)
+    assert poller.batch_size == 2
+    poller.poll(backlog=0)
+    assert poller.idle
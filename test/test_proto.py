@@ -0,0 +1,28 @@
+import os
+import re
+from nexus.helpers import envelope, proto
+
+
+def test_schema_file_matches_runtime_descriptors():
+    path = os.path.join(os.path.dirname(__file__), '..', 'proto', 'market_data.proto')
+    with open(path) as file:
+        text = file.read()
+    declared = {}
+    for name, body in re.findall(r'message (\w+) \{(.*?)\n\}', text, re.S):
+        declared[name] = [(field, int(number), kind) for kind, field, number in re.findall(r'(\w+) (\w+) = (\d+);', body)]
+    assert declared == proto.MESSAGES
+
+
+def test_timestamps_round_trip_as_micros():
+    assert proto.to_micros('1970-01-01T00:00:01.000002+00:00') == 1_000_002
+    assert proto.from_micros(proto.to_micros('2024-01-02T15:30:00+00:00')) == '2024-01-02T15:30:00+00:00'
+
+
+def test_bars_round_trip_through_proto_envelope():
+    bar = {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:00+00:00', 'open': 99.0, 'high': 101.0, 'low': 98.0,
+           'close': 100.0, 'volume': 1_000.0, 'trade_count': None, 'backfill': True}
+    data = envelope.encode('bar', bar, source='data', build='abc123', serializer='proto')
+    assert not data.startswith('{') and len(data) < len(envelope.encode('bar', bar, source='data', build='abc123'))
+    decoded = envelope.decode(data)
+    assert decoded['schema'] == 'bar.v1' and decoded['build'] == 'abc123' and decoded['payload'] == bar
+    assert envelope.encode('news', {'type': 'news', 'symbols': []}, source='data', serializer='proto').startswith('{')
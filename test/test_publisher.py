@@ -0,0 +1,106 @@
+import time
+import threading
+import pytest
+from nexus.helpers import envelope, publisher
+
+
+def test_batches_respect_count_and_size():
+    assert publisher.batches([str(i) for i in range(23)], 10) == [
+        [str(i) for i in range(10)], [str(i) for i in range(10, 20)], ['20', '21', '22']
+    ]
+    large = 'x' * (publisher.MAX_SNS_BATCH_BYTES // 2 + 1)
+    assert [len(batch) for batch in publisher.batches([large, large, large])] == [1, 1, 1]
+    assert publisher.batches([]) == []
+
+
+def test_publisher_batches_by_topic_and_flushes():
+    sent = []
+
+    def publish_batch(messages, topic):
+        sent.append((topic, list(messages)))
+        return {'Failed': [{'Id': '0'}]} if topic == 'flaky' else {}
+
+    batch_publisher = publisher.BatchPublisher(batch_size=3, flush_seconds=0.05, workers=1, publish_batch=publish_batch)
+    for i in range(4):
+        assert batch_publisher.publish(f'bar {i}', 'bars')
+    batch_publisher.publish('quote', 'flaky')
+    batch_publisher.start()
+    batch_publisher.stop()
+    published = [message for topic, messages in sent if topic == 'bars' for message in messages]
+    assert published == ['bar 0', 'bar 1', 'bar 2', 'bar 3']
+    assert all(len(messages) <= 3 for _, messages in sent)
+    assert ('flaky', ['quote']) in sent
+
+
+def test_full_queue_drops_messages():
+    batch_publisher = publisher.BatchPublisher(max_queue=1, publish_batch=lambda messages, topic: {})
+    assert batch_publisher.publish('first', 'bars')
+    assert not batch_publisher.publish('second', 'bars')
+
+
+def test_stop_returns_what_was_not_published_by_the_deadline():
+    release = threading.Event()
+
+    def publish_batch(messages, topic):
+        release.wait(1)
+        return {}
+
+    batch_publisher = publisher.BatchPublisher(batch_size=1, flush_seconds=0.01, workers=1, publish_batch=publish_batch).start()
+    for i in range(3):
+        batch_publisher.publish(f'bar {i}', 'bars')
+    time.sleep(0.05)
+    remaining = batch_publisher.stop(timeout=0.05)
+    release.set()
+    assert remaining == [('bar 1', 'bars'), ('bar 2', 'bars')]
+
+
+def test_queue_time_is_observed_when_the_batch_is_sent(monkeypatch):
+    observed = []
+
+    def observe(name, value, **labels):
+        if name == 'publish_queue_seconds':
+            observed.append(value)
+
+    monkeypatch.setattr(publisher.metrics, 'observe', observe)
+
+    def publish_batch(messages, topic):
+        time.sleep(0.05)
+        return {}
+
+    batch_publisher = publisher.BatchPublisher(flush_seconds=0.01, workers=1, publish_batch=publish_batch)
+    batch_publisher.publish('bar 0', 'bars')
+    batch_publisher.publish('bar 1', 'bars')
+    batch_publisher.start()
+    batch_publisher.stop()
+    assert len(observed) == 2
+    assert all(value >= 0.05 for value in observed)
+
+
+def test_publishers_are_selected_by_transport():
+    assert publisher.publishers('sns') == (publisher.cloud.publish_sns_message, publisher.cloud.publish_sns_batch)
+    assert publisher.publishers('kinesis') == (publisher.kinesis.publish, publisher.kinesis.publish_batch)
+    assert publisher.publishers('kafka') == (publisher.kafka.publish, publisher.kafka.publish_batch)
+    assert publisher.publishers('nats') == (publisher.nats_transport.publish, publisher.nats_transport.publish_batch)
+    with pytest.raises(ValueError):
+        publisher.publishers('sqs')
+
+
+def test_ordered_publisher_keeps_each_symbols_messages_on_one_worker():
+    sent = []
+
+    def publish_batch(messages, topic):
+        sent.append((threading.current_thread().name, [envelope.route(data)[1] for data in messages]))
+        return {}
+
+    batch_publisher = publisher.BatchPublisher(
+        batch_size=1, flush_seconds=0.01, workers=4, publish_batch=publish_batch, key=publisher.ordering_key
+    )
+    for i in range(12):
+        batch_publisher.publish(envelope.encode('bar', {'symbol': ['AAPL', 'MSFT', 'NVDA'][i % 3], 'close': i}, 'data'), 'bars')
+    batch_publisher.start()
+    batch_publisher.stop()
+    workers = {}
+    for worker, symbols in sent:
+        workers.setdefault(symbols[0], set()).add(worker)
+    assert sorted(workers) == ['AAPL', 'MSFT', 'NVDA'] and all(len(names) == 1 for names in workers.values())
+    assert publisher.ordering_key(envelope.encode('heartbeat', {'status': 'waiting'}, 'data')) == 'heartbeat'
@@ -0,0 +1,64 @@
+import pytest
+from nexus.helpers import rebalance
+
+
+def test_buys_into_new_targets():
+    orders = rebalance.compute_rebalance_orders(
+        target_weights={'AAPL': 0.5, 'MSFT': 0.5},
+        positions={},
+        prices={'AAPL': 100, 'MSFT': 50},
+        equity=10_000
+    )
+    assert [(order['symbol'], order['qty']) for order in orders] == [('AAPL', 50), ('MSFT', 100)]
+
+
+def test_skips_positions_within_drift_threshold():
+    orders = rebalance.compute_rebalance_orders(
+        target_weights={'AAPL': 0.5},
+        positions={'AAPL': 49},
+        prices={'AAPL': 100},
+        equity=10_000,
+        drift_threshold=0.02
+    )
+    assert orders == []
+
+
+def test_closes_dropped_symbols_and_sells_first():
+    orders = rebalance.compute_rebalance_orders(
+        target_weights={'AAPL': 0.5},
+        positions={'MSFT': 10},
+        prices={'AAPL': 100, 'MSFT': 50},
+        equity=10_000
+    )
+    assert [(order['symbol'], order['qty']) for order in orders] == [('MSFT', -10), ('AAPL', 50)]
+
+
+def test_fractional_quantities():
+    orders = rebalance.compute_rebalance_orders(
+        target_weights={'AAPL': 0.1},
+        positions={},
+        prices={'AAPL': 300},
+        equity=1_000,
+        allow_fractional=True
+    )
+    assert orders[0]['qty'] == pytest.approx(1 / 3)
+
+
+def test_whole_shares_round_toward_zero():
+    orders = rebalance.compute_rebalance_orders(
+        target_weights={'AAPL': 0.1},
+        positions={},
+        prices={'AAPL': 300},
+        equity=1_000
+    )
+    assert orders == []
+
+
+def test_rejects_leveraged_targets():
+    with pytest.raises(ValueError):
+        rebalance.compute_rebalance_orders({'AAPL': 0.8, 'MSFT': -0.4}, {}, {'AAPL': 1, 'MSFT': 1}, 1_000)
+
+
+def test_rejects_missing_price():
+    with pytest.raises(ValueError):
+        rebalance.compute_rebalance_orders({'AAPL': 0.5}, {}, {}, 1_000)
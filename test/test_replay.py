@@ -0,0 +1,65 @@
+import asyncio
+import pytest
+from datetime import date
+from nexus.helpers import archive, replay
+
+BARS = [
+    {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:00+00:00', 'close': 100.0},
+    {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:31:00+00:00', 'close': 101.0},
+]
+TRADES = [
+    {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:30+00:00', 'price': 100.5, 'size': 10},
+    {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:31:30+00:00', 'price': 101.5, 'size': 10},
+]
+
+
+def test_parse_speed():
+    assert replay.parse_speed('realtime') == 1.0
+    assert replay.parse_speed('10x') == 10.0
+    assert replay.parse_speed('0.5x') == 0.5
+    assert replay.parse_speed('max') is None
+    for text in ('fast', '0x', '-2x'):
+        with pytest.raises(ValueError):
+            replay.parse_speed(text)
+
+
+def test_bars_are_released_at_their_close():
+    order = [(record_type, record['timestamp'][11:19]) for record_type, record in replay.merge({'bars': BARS, 'trades': TRADES})]
+    assert order == [('trades', '15:30:30'), ('bars', '15:30:00'), ('trades', '15:31:30'), ('bars', '15:31:00')]
+
+
+def test_replay_paces_records_at_the_speed():
+    now, waits, published = [0.0], [], []
+
+    async def sleep(seconds):
+        waits.append(seconds)
+        now[0] += seconds
+
+    async def publish(record_type, record):
+        published.append(record['timestamp'])
+
+    count = asyncio.run(replay.replay(replay.merge({'bars': BARS, 'trades': TRADES}), publish, 10.0, sleep=sleep, clock=lambda: now[0]))
+    assert count == 4 and len(published) == 4
+    assert waits == pytest.approx([3.0, 3.0, 3.0])
+    waits.clear()
+    asyncio.run(replay.replay(replay.merge({'bars': BARS}), publish, None, sleep=sleep, clock=lambda: now[0]))
+    assert waits == []
+
+
+def test_load_archive_reads_each_day_and_symbol():
+    objects = {
+        's3://bucket/archive/type=bars/date=2024-01-02/symbol=AAPL/b.jsonl.gz': archive.encode(BARS[1:], 'jsonl'),
+        's3://bucket/archive/type=bars/date=2024-01-02/symbol=AAPL/a.jsonl.gz': archive.encode(BARS[:1], 'jsonl'),
+    }
+    prefixes = []
+
+    def list_objects(prefix):
+        prefixes.append(prefix)
+        return [uri for uri in objects if uri.startswith(prefix)]
+
+    records = replay.load_archive('s3://bucket/archive', 'bars', ['AAPL'], date(2024, 1, 1), date(2024, 1, 2), list_objects, objects.get)
+    assert records == BARS
+    assert prefixes == [
+        's3://bucket/archive/type=bars/date=2024-01-01/symbol=AAPL/',
+        's3://bucket/archive/type=bars/date=2024-01-02/symbol=AAPL/',
+    ]
@@ -0,0 +1,52 @@
+import math
+import pytest
+from nexus.helpers import returns
+
+
+def _bars(points):
+    return [{'timestamp': timestamp, 'close': close} for timestamp, close in points]
+
+
+def test_to_returns():
+    assert returns.simple_returns([100, 110, 99]) == pytest.approx([0.1, -0.1])
+    assert returns.to_returns([100, 110], 'log') == pytest.approx([math.log(1.1)])
+    assert returns.to_returns([100, 110], 'simple') == pytest.approx([0.1])
+    with pytest.raises(ValueError):
+        returns.to_returns([100, 110], 'arithmetic')
+
+
+def test_resample_rejects_unknown_timeframe():
+    with pytest.raises(ValueError):
+        returns.resample([], '2Min')
+
+
+def test_align_bars_forward_fills():
+    bars = {
+        'AAA': _bars([('t1', 10), ('t2', 11), ('t3', 12), ('t4', 13)]),
+        'BBB': _bars([('t2', 100), ('t4', 102)]),
+    }
+    timestamps, closes = returns.align_bars(bars)
+    assert timestamps == ['t2', 't3', 't4']
+    assert closes == {'AAA': [11, 12, 13], 'BBB': [100, 100, 102]}
+
+    timestamps, closes = returns.align_bars(bars, forward_fill=False)
+    assert timestamps == ['t2', 't4']
+    assert closes == {'AAA': [11, 13], 'BBB': [100, 102]}
+
+
+def test_align_bars_limits_fill():
+    bars = {
+        'AAA': _bars([('t1', 10), ('t2', 11), ('t3', 12), ('t4', 13)]),
+        'BBB': _bars([('t1', 100), ('t4', 103)]),
+    }
+    timestamps, closes = returns.align_bars(bars, max_fill=1)
+    assert timestamps == ['t1', 't2', 't4']
+    assert closes['BBB'] == [100, 100, 103]
+
+
+def test_aligned_returns():
+    bars = {
+        'AAA': _bars([('t1', 100), ('t2', 110)]),
+        'BBB': _bars([('t1', 50), ('t2', 50)]),
+    }
+    assert returns.aligned_returns(bars, kind='simple') == {'AAA': pytest.approx([0.1]), 'BBB': [0.0]}
@@ -0,0 +1,119 @@
+import pytest
+from unittest.mock import MagicMock
+from nexus.helpers import brokers, envelope, kafka, strategy
+from nexus.helpers.domain import Side
+from nexus.services import reversion
+
+TIMESTAMP = '2025-03-03T15:00:00+00:00'
+
+HEDGE = {'symbol': 'XLK', 'beta': 1.3, 'price': 200.0, 'stock_price': 190.0}
+
+
+@pytest.fixture
+def executor():
+    mock = brokers.MockBroker(cash=1_000_000)
+    mock.set_price('AAPL', 190.0)
+    mock.set_price('XLK', 200.0)
+    brokers.set_broker(mock)
+    reversion.brokers.set_broker(mock)
+    state = strategy.TradingStateManager(logger=reversion.logger)
+    yield strategy.OrderExecutor(state, strategy.RiskManager(state))
+    brokers.set_broker(None)
+    reversion.brokers.set_broker(None)
+
+
+def test_hedge_is_whole_shares_and_skipped_under_one(executor):
+    leg = reversion.execute_hedge(HEDGE, {'symbol': 'AAPL', 'qty': 10, 'notional': None}, TIMESTAMP, executor)
+    assert (leg['qty'], leg['side'], leg['placed']) == (-12, Side.SELL, True)
+    assert brokers.get_broker().positions['XLK'] == -12
+    small = reversion.execute_hedge(HEDGE, {'symbol': 'MSFT', 'qty': None, 'notional': 100.0}, TIMESTAMP, executor)
+    assert (small['qty'], small['placed']) == (0, False)
+    assert len(brokers.get_broker().orders) == 1
+
+
+def test_hedges_of_stocks_sharing_an_etf_on_one_bar_are_distinct_orders(executor):
+    brokers.get_broker().set_price('MSFT', 190.0)
+    first = reversion.execute_hedge(HEDGE, {'symbol': 'AAPL', 'qty': 10, 'notional': None}, TIMESTAMP, executor)
+    # The risk manager refuses to add to a position, so the second leg starts from a flat book
+    executor.state.positions.clear()
+    second = reversion.execute_hedge(HEDGE, {'symbol': 'MSFT', 'qty': 10, 'notional': None}, TIMESTAMP, executor)
+    assert first['client_order_id'] != second['client_order_id']
+    assert first['placed'] and second['placed']
+    assert brokers.get_broker().positions['XLK'] == -24
+
+
+def test_exiting_the_stock_leg_unwinds_its_hedge(executor):
+    entry = {'symbol': 'AAPL', 'side': Side.BUY, 'qty': 10, 'notional': None, 'client_order_id': 'entry'}
+    reversion.execute_order(entry, TIMESTAMP, executor, HEDGE)
+    assert entry['hedge']['placed']
+    assert brokers.get_broker().positions == {'AAPL': 10, 'XLK': -12}
+    assert executor.state.hedges == {'AAPL': {'symbol': 'XLK', 'qty': -12}}
+    # An outright exit signal carries no hedge of its own
+    exit_order = {'symbol': 'AAPL', 'side': Side.SELL, 'qty': -10, 'notional': None, 'client_order_id': 'exit'}
+    reversion.execute_order(exit_order, '2025-03-03T15:05:00+00:00', executor)
+    assert (exit_order['unwound_hedge']['qty'], exit_order['unwound_hedge']['placed']) == (12, True)
+    assert 'hedge' not in exit_order
+    assert brokers.get_broker().positions == {}
+    assert executor.state.hedges == {} and executor.state.positions == {}
+
+
+def test_filter_falls_back_to_asset_class_for_large_universes():
+    assert reversion.data_filter_policy(['MSFT', 'AAPL'])['symbol'] == ['AAPL', 'MSFT', {'exists': False}]
+    policy = reversion.data_filter_policy([f'S{i}' for i in range(80)])
+    assert policy == {'kind': ['bar', 'heartbeat'], 'asset_class': ['us_equity', {'exists': False}]}
+
+
+def test_stale_entries_expire_but_exits_do_not(executor, monkeypatch):
+    stale = {'symbol': 'AAPL', 'close': 190.0, 'timestamp': TIMESTAMP}
+    monkeypatch.setattr(reversion, 'generate_signal', lambda *args: (True, Side.BUY, 10, 'AAPL'))
+    entry = reversion.handle_bar(stale, ['AAPL'], executor, signal_ttl=120)
+    assert not entry['placed'] and entry['expires_at']
+    reversion.execute_order(
+        {'symbol': 'AAPL', 'side': Side.BUY, 'qty': 10, 'notional': None, 'client_order_id': 'entry'}, TIMESTAMP, executor
+    )
+    monkeypatch.setattr(reversion, 'generate_signal', lambda *args: (True, Side.SELL, 10, 'AAPL'))
+    exit_order = reversion.handle_bar(stale, ['AAPL'], executor, signal_ttl=120)
+    assert exit_order['placed'] and exit_order['expires_at'] is None
+    assert brokers.get_broker().positions == {}
+
+
+def test_notional_sizing_floors_shorts_and_closes_exits(executor, monkeypatch):
+    def bar(minute):
+        return {'symbol': 'AAPL', 'close': 190.0, 'timestamp': f'2025-03-03T15:0{minute}:00+00:00'}
+
+    monkeypatch.setattr(reversion, 'generate_signal', lambda *args: (True, Side.BUY, 10, 'AAPL'))
+    entry = reversion.handle_bar(bar(1), ['AAPL'], executor, reversion_notional=1_000)
+    assert (entry['qty'], entry['notional'], entry['placed']) == (None, 1_000, True)
+    assert brokers.get_broker().positions['AAPL'] == pytest.approx(1_000 / 190)
+    monkeypatch.setattr(reversion, 'generate_signal', lambda *args: (True, Side.SELL, 10, 'AAPL'))
+    exit_order = reversion.handle_bar(bar(2), ['AAPL'], executor, reversion_notional=1_000)
+    assert exit_order['qty'] == pytest.approx(-1_000 / 190) and exit_order['notional'] is None
+    assert brokers.get_broker().positions == {}
+    short = reversion.handle_bar(bar(3), ['AAPL'], executor, reversion_notional=1_000)
+    assert (short['qty'], short['notional'], short['placed']) == (-5, None, True)
+    assert brokers.get_broker().positions['AAPL'] == -5
+
+
+def test_bars_that_fail_are_not_committed(executor, monkeypatch):
+    bar = {'symbol': 'AAPL', 'close': 190.0, 'timestamp': TIMESTAMP}
+    record = MagicMock(**{
+        'error.return_value': None, 'topic.return_value': 'nexus.data.bar', 'partition.return_value': 0,
+        'offset.return_value': 41, 'value.return_value': envelope.encode('bar', bar, 'data').encode(),
+        'timestamp.return_value': (1, 1741014000000)
+    })
+    consumer = MagicMock(**{'consume.return_value': [record]})
+    poller = kafka.KafkaPoller(['nexus.data.bar'], 'reversion', consumer=consumer)
+    message, = poller.poll()
+
+    def delete(message):
+        poller.delete(message['ReceiptHandle'])
+
+    def fail(*args, **kwargs):
+        raise RuntimeError('broker unavailable')
+
+    monkeypatch.setattr(reversion, 'handle_bar', fail)
+    assert not reversion.handle_bar_message(message, bar, delete, ['AAPL'], executor, signal_ttl=120)
+    consumer.commit.assert_not_called()
+    monkeypatch.setattr(reversion, 'handle_bar', lambda *args, **kwargs: None)
+    assert reversion.handle_bar_message(message, bar, delete, ['AAPL'], executor, signal_ttl=120)
+    consumer.commit.assert_called_once_with(message=record, asynchronous=True)
@@ -0,0 +1,112 @@
+import pytest
+from nexus.helpers import risk
+
+LIMITS = risk.RiskLimits(
+    max_order_notional=10_000,
+    max_position_notional=20_000,
+    max_gross_exposure=50_000,
+    restricted_symbols={'GME'}
+)
+
+
+def position(qty, price):
+    return {'qty': qty, 'market_value': qty * price}
+
+
+def test_order_notional_limit():
+    assert risk.evaluate(LIMITS, 'AAPL', 50, 100.0, {}) is None
+    assert 'order notional' in risk.evaluate(LIMITS, 'AAPL', 101, 100.0, {})
+
+
+def test_reducing_orders_pass_position_and_restricted_limits():
+    positions = {'GME': position(300, 100.0)}
+    assert risk.evaluate(LIMITS, 'GME', -50, 100.0, positions) is None
+    assert risk.evaluate(LIMITS, 'GME', 10, 100.0, positions) == 'symbol is restricted'
+    # Flipping through flat opens a new position
+    assert risk.evaluate(LIMITS, 'GME', -90, 100.0, {'GME': position(10, 100.0)}) == 'symbol is restricted'
+
+
+def test_position_and_gross_exposure_limits():
+    positions = {'AAPL': position(150, 100.0), 'MSFT': position(-300, 100.0)}
+    assert 'position notional' in risk.evaluate(LIMITS, 'AAPL', 60, 100.0, positions)
+    assert risk.evaluate(LIMITS, 'AAPL', 40, 100.0, positions) is None
+    assert 'gross exposure' in risk.evaluate(LIMITS, 'NVDA', 100, 100.0, {**positions, 'AMD': position(200, 100.0)})
+
+
+def test_check_order_raises_and_alerts(monkeypatch):
+    alerts = []
+    monkeypatch.setattr(risk, 'limits', LIMITS)
+    monkeypatch.setattr(risk.cloud, 'publish_alert', lambda subject, details: alerts.append(details))
+    risk.check_order('AAPL', 10, 100.0, {})
+    with pytest.raises(risk.RiskRejection) as rejection:
+        risk.check_order('GME', 10, 100.0, {})
+    assert rejection.value.reason == 'symbol is restricted'
+    assert alerts == [{'symbol': 'GME', 'qty': 10, 'price': 100.0, 'reason': 'symbol is restricted'}]
+
+
+def test_load_limits_from_env(monkeypatch):
+    monkeypatch.delenv('RISK_CONFIG_FILE', raising=False)
+    monkeypatch.setenv('RISK_MAX_ORDER_NOTIONAL', '2500')
+    monkeypatch.setenv('RISK_RESTRICTED_SYMBOLS', 'gme, amc')
+    limits = risk.load_limits()
+    assert limits.max_order_notional == 2500.0
+    assert limits.max_position_notional is None
+    assert limits.restricted_symbols == {'GME', 'AMC'}
+    assert limits.enabled()
+    assert not risk.RiskLimits().enabled()
+
+
+def test_drawdown_circuit_breaker_blocks_new_risk(monkeypatch, tmp_path):
+    limits = risk.RiskLimits(max_drawdown_pct=10)
+    monkeypatch.setattr(risk, 'limits', limits)
+    monkeypatch.setattr(risk, 'account_guard', None)
+    monkeypatch.setattr(risk, 'EQUITY_TTL_SECONDS', 0)
+    monkeypatch.setattr(risk.cloud, 'publish_alert', lambda subject, details: None)
+    monkeypatch.setenv('RISK_DRAWDOWN_STATE_FILE', str(tmp_path / 'account.json'))
+    risk.check_order('AAPL', 10, 100.0, {}, get_equity=lambda: 100_000)
+    risk.check_order('AAPL', 10, 100.0, {}, get_equity=lambda: 120_000)
+    with pytest.raises(risk.RiskRejection) as rejection:
+        risk.check_order('AAPL', 10, 100.0, {}, get_equity=lambda: 107_000)
+    assert 'circuit breaker' in rejection.value.reason
+    # Closing out is still allowed while underwater
+    risk.check_order('AAPL', -10, 100.0, {'AAPL': position(10, 100.0)}, get_equity=lambda: 107_000)
+    # The high-water mark and halt survive a restart
+    monkeypatch.setattr(risk, 'account_guard', None)
+    with pytest.raises(risk.RiskRejection):
+        risk.check_order('AAPL', 10, 100.0, {}, get_equity=lambda: 115_000)
+    assert risk.account_guard.high_water_mark == 120_000
+    risk.account_guard.resume('dana')
+    risk.check_order('AAPL', 10, 100.0, {}, get_equity=lambda: 115_000)
+
+
+def test_equity_is_read_once_per_ttl(monkeypatch):
+    monkeypatch.setattr(risk, 'limits', risk.RiskLimits(max_drawdown_pct=10))
+    monkeypatch.setattr(risk, 'account_guard', None)
+    monkeypatch.setattr(risk.cloud, 'publish_alert', lambda subject, details: None)
+    monkeypatch.delenv('RISK_DRAWDOWN_STATE_FILE', raising=False)
+    reads = []
+
+    def get_equity():
+        reads.append(1)
+        return 100_000
+
+    for _ in range(3):
+        risk.check_order('AAPL', 10, 100.0, {}, get_equity=get_equity)
+    assert len(reads) == 1
+
+
+def test_pre_trade_check_rejects_orders_it_cannot_price(monkeypatch):
+    monkeypatch.setattr(risk, 'limits', LIMITS)
+    monkeypatch.setattr(risk.wash, 'config', risk.wash.WashTradeConfig(enabled=True))
+    monkeypatch.setattr(risk.cloud, 'publish_alert', lambda subject, details: None)
+    account = {
+        'get_positions': dict,
+        'get_orders': lambda symbol, since: [],
+        'get_latest_price': lambda symbol: None,
+        'get_equity': lambda: 100_000.0,
+    }
+    with pytest.raises(risk.RiskRejection, match='no price'):
+        risk.pre_trade_check('AAPL', 'buy', notional=1_000, **account)
+    risk.pre_trade_check('AAPL', 'buy', qty=10, price=100.0, **account)
+    with pytest.raises(risk.RiskRejection, match='order notional'):
+        risk.pre_trade_check('AAPL', 'buy', notional=20_000, **{**account, 'get_latest_price': lambda symbol: 100.0})
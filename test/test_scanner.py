@@ -0,0 +1,65 @@
+import json
+import random
+from nexus.helpers import scanner
+
+
+def _universe(n: int = 300) -> dict[str, list[float]]:
+    rng = random.Random(7)
+    walk, spread = [100.0], [0.0]
+    for _ in range(n - 1):
+        walk.append(walk[-1] + rng.gauss(0, 1))
+        spread.append(0.5 * spread[-1] + rng.gauss(0, 0.5))
+    noise = [100.0]
+    for _ in range(n - 1):
+        noise.append(noise[-1] + rng.gauss(0, 1))
+    return {
+        'AAA': [2 * price + s for price, s in zip(walk, spread)],
+        'BBB': walk,
+        'CCC': noise,
+    }
+
+
+def test_align_closes_keeps_shared_timestamps():
+    bars = {
+        'AAA': [{'timestamp': 't1', 'close': 10}, {'timestamp': 't2', 'close': 11}, {'timestamp': 't3', 'close': 12}],
+        'BBB': [{'timestamp': 't3', 'close': 102}, {'timestamp': 't1', 'close': 100}],
+        'CCC': [],
+    }
+    assert scanner.align_closes(bars) == {'AAA': [10, 12], 'BBB': [100, 102]}
+    assert scanner.align_closes({}) == {}
+
+
+def test_scan_pairs_finds_cointegrated_pair():
+    pairs = scanner.scan_pairs(_universe())
+    assert [sorted(pair['symbols']) for pair in pairs] == [['AAA', 'BBB']]
+    assert pairs[0]['p_value'] < 0.05
+    assert pairs[0]['half_life'] > 0
+    assert scanner.scan_pairs(_universe(), max_half_life=0.01) == []
+
+
+def test_scan_pairs_corrects_for_pairs_tested(monkeypatch):
+    # Three pairs at p = 0.02 each pass on their own but not after Bonferroni
+    monkeypatch.setattr(
+        scanner.statistics, 'engle_granger_test', lambda X, Y: {'statistic': -3.5, 'p_value': 0.02, 'critical_values': {}}
+    )
+    assert scanner.scan_pairs(_universe(), correction='bonferroni') == []
+
+
+def test_scan_pairs_needs_history():
+    assert scanner.scan_pairs({symbol: closes[:50] for symbol, closes in _universe().items()}) == []
+
+
+def test_publish_writes_file(tmp_path):
+    path = tmp_path / 'pairs.json'
+    scanner.publish({'pairs': [{'symbols': ['AAA', 'BBB'], 'hurst': None}]}, path=str(path))
+    assert json.loads(path.read_text()) == {'pairs': [{'symbols': ['AAA', 'BBB'], 'hurst': None}]}
+
+
+def test_publish_subscribes_symbols_found(monkeypatch):
+    sent = []
+    monkeypatch.setattr(scanner.cloud, 'send_sqs_message', lambda body, queue_url: sent.append((json.loads(body), queue_url)))
+    results = {'pairs': [{'symbols': ['AAA', 'BBB']}], 'triples': [{'symbols': ['AAA', 'CCC', 'DDD']}]}
+    scanner.publish(results, control_queue='control')
+    assert sent == [({'action': 'subscribe', 'symbols': ['AAA', 'BBB', 'CCC', 'DDD'], 'types': ['bars']}, 'control')]
+    scanner.publish({'pairs': [], 'triples': []}, control_queue='control')
+    assert len(sent) == 1
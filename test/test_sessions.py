@@ -0,0 +1,45 @@
+from datetime import datetime
+from nexus.helpers import sessions
+
+MONDAY = (2025, 2, 3)
+
+
+def eastern(hour, minute=0, day=MONDAY):
+    return datetime(*day, hour, minute, tzinfo=sessions.EASTERN)
+
+
+def test_sessions_by_time_of_day():
+    assert sessions.get_session(eastern(3, 59)) == sessions.MarketSession.CLOSED
+    assert sessions.get_session(eastern(4)) == sessions.MarketSession.PRE_MARKET
+    assert sessions.get_session(eastern(9, 30)) == sessions.MarketSession.REGULAR
+    assert sessions.get_session(eastern(16)) == sessions.MarketSession.POST_MARKET
+    assert sessions.get_session(eastern(20)) == sessions.MarketSession.CLOSED
+
+
+def test_weekends_are_closed():
+    assert sessions.get_session(eastern(10, day=(2025, 2, 8))) == sessions.MarketSession.CLOSED
+
+
+def test_clock_overrides_regular_hours_on_holidays():
+    clock = {'is_open': False}
+    assert sessions.get_session(eastern(11), clock) == sessions.MarketSession.CLOSED
+    assert sessions.get_session(eastern(11), {'is_open': True}) == sessions.MarketSession.REGULAR
+
+
+def test_is_extended_hours():
+    assert sessions.is_extended_hours(sessions.MarketSession.PRE_MARKET)
+    assert not sessions.is_extended_hours(sessions.MarketSession.REGULAR)
+
+
+def test_session_times_move_post_market_on_early_close():
+    half_day = (eastern(9, 30, day=(2025, 11, 28)), eastern(13, day=(2025, 11, 28)))
+    assert sessions.get_session(eastern(12, day=(2025, 11, 28)), session_times=half_day) == sessions.MarketSession.REGULAR
+    assert sessions.get_session(eastern(14, day=(2025, 11, 28)), session_times=half_day) == sessions.MarketSession.POST_MARKET
+    assert sessions.get_session(eastern(17, 30, day=(2025, 11, 28)), session_times=half_day) == sessions.MarketSession.CLOSED
+
+
+def test_holidays_are_closed_all_day():
+    thanksgiving = (2025, 11, 27)
+    for hour in (5, 11, 17):
+        session = sessions.get_session(eastern(hour, day=thanksgiving), trading_day=False)
+        assert session == sessions.MarketSession.CLOSED
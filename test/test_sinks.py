@@ -0,0 +1,57 @@
+import threading
+import pytest
+from nexus.helpers import sinks
+
+
+class ListSink(sinks.MarketDataSink):
+    def __init__(self, release=None):
+        self.records = []
+        self.flushed = False
+        self.release = release
+
+    def record(self, record_type, message):
+        if self.release is not None:
+            self.release.wait(1)
+        self.records.append((record_type, message['symbol']))
+
+    def flush(self):
+        self.flushed = True
+
+
+class FailingSink(ListSink):
+    def record(self, record_type, message):
+        raise RuntimeError('database is down')
+
+
+def test_offer_returns_before_a_slow_sink_stores_the_record():
+    release = threading.Event()
+    sink = ListSink(release)
+    sink_queue = sinks.SinkQueue([sink]).start()
+    assert sink_queue.offer('bars', {'symbol': 'AAPL'})
+    assert sink.records == []
+    release.set()
+    assert sink_queue.stop(timeout=1) == 0
+    assert sink.records == [('bars', 'AAPL')]
+    assert sink.flushed
+
+
+def test_failing_sink_does_not_stop_the_others():
+    sink = ListSink()
+    sink_queue = sinks.SinkQueue([FailingSink(), sink]).start()
+    sink_queue.offer('trades', {'symbol': 'MSFT'})
+    sink_queue.offer('quotes', {'symbol': 'MSFT'})
+    sink_queue.stop(timeout=1)
+    assert sink.records == [('trades', 'MSFT'), ('quotes', 'MSFT')]
+
+
+def test_full_queue_drops_records():
+    sink_queue = sinks.SinkQueue([ListSink()], max_queue=1)
+    assert sink_queue.offer('bars', {'symbol': 'AAPL'})
+    assert not sink_queue.offer('bars', {'symbol': 'MSFT'})
+    assert sink_queue.stop(timeout=0) == 1
+
+
+def test_check_record_type():
+    sinks.check_record_type('quotes')
+    with pytest.raises(ValueError):
+        sinks.check_record_type('news')
@@ -0,0 +1,51 @@
+import pytest
+from nexus.helpers import sizing
+
+
+def test_kelly_fractions():
+    # 60% winners paying 1:1 on the whole stake
+    assert sizing.kelly_fraction(0.6, 1.0) == pytest.approx(0.2)
+    # Winners of 2% against losers of 1% of the notional
+    assert sizing.kelly_fraction(0.5, 0.02, 0.01) == pytest.approx(25.0)
+    assert sizing.kelly_fraction(0.3, 1.0) < 0
+    assert sizing.continuous_kelly_fraction(0.001, 0.0004) == pytest.approx(2.5)
+    with pytest.raises(ValueError):
+        sizing.kelly_fraction(1.5, 1.0)
+    with pytest.raises(ValueError):
+        sizing.continuous_kelly_fraction(0.001, 0)
+
+
+def test_sizer_falls_back_to_fixed_fraction_until_enough_trades():
+    sizer = sizing.PositionSizer(method='half_kelly', fixed_fraction=0.01, max_fraction=0.5, min_trades=4)
+    assert sizer.qty(equity=100_000, price=50) == 20
+    for pnl in (30, -10, 30, -10):
+        sizer.record_trade(pnl, entry_notional=1000)
+    # Half of 0.5 / 0.01 - 0.5 / 0.03 = 33.3, capped
+    assert sizer.fraction() == 0.5
+    sizer.max_fraction = 1.0
+    sizer.record_trade(-900, entry_notional=1000)
+    # A Kelly fraction with no edge stops new entries
+    assert sizer.fraction() == 0
+    assert sizer.qty(equity=100_000, price=50) == 0
+
+
+def test_sizer_rejects_unknown_method():
+    with pytest.raises(ValueError):
+        sizing.PositionSizer(method='martingale')
+
+
+def test_sizer_reuses_account_equity_within_its_ttl():
+    now = [0.0]
+    reads = []
+
+    def get_equity():
+        reads.append(now[0])
+        return 100_000.0 + len(reads)
+
+    sizer = sizing.PositionSizer(equity_ttl_seconds=30, monotonic=lambda: now[0])
+    assert sizer.account_equity(get_equity) == 100_001.0
+    now[0] = 29
+    assert sizer.account_equity(get_equity) == 100_001.0
+    now[0] = 30
+    assert sizer.account_equity(get_equity) == 100_002.0
+    assert reads == [0.0, 30]
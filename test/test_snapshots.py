@@ -0,0 +1,36 @@
+import pytest
+from nexus.helpers import snapshots
+
+
+def test_snapshot_keeps_the_newest_records_and_builds_the_minute_bar():
+    cache = snapshots.SnapshotCache()
+    cache.record('quotes', {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:01+00:00', 'bid_price': 99.9, 'ask_price': 100.1})
+    cache.record('quotes', {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:00+00:00', 'bid_price': 99.0, 'ask_price': 99.2})
+    cache.record('trades', {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:29:59+00:00', 'price': 99.0, 'size': 100})
+    cache.record('trades', {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:02+00:00', 'price': 100.0, 'size': 100})
+    before = cache.query(['AAPL'])['AAPL']['minute_bar']
+    cache.record('trades', {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:05+00:00', 'price': 102.0, 'size': 300})
+    cache.record('trades', {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:29:58+00:00', 'price': 90.0, 'size': 100})
+    snapshot = cache.query(['AAPL', 'MSFT'])['AAPL']
+    assert snapshot['quote']['bid_price'] == 99.9
+    assert snapshot['trade']['price'] == 102.0
+    bar = snapshot['minute_bar']
+    assert bar['timestamp'] == '2024-01-02T15:30:00+00:00'
+    assert (bar['open'], bar['high'], bar['low'], bar['close']) == (100.0, 102.0, 100.0, 102.0)
+    assert bar['volume'] == 400 and bar['trade_count'] == 2
+    assert bar['vwap'] == pytest.approx(101.5)
+    assert before['close'] == 100.0
+    assert list(cache.query()) == ['AAPL']
+
+
+def test_route_filters_symbols_and_requires_the_cache(monkeypatch):
+    monkeypatch.setattr(snapshots, 'cache', None)
+    with pytest.raises(ValueError, match='SNAPSHOTS'):
+        snapshots.admin.routes[('GET', '/snapshots')](query={}, body={})
+    cache = snapshots.SnapshotCache()
+    for symbol in ('AAPL', 'MSFT', 'SPY'):
+        cache.record('bars', {'symbol': symbol, 'timestamp': '2024-01-02T15:30:00+00:00', 'close': 100.0})
+    monkeypatch.setattr(snapshots, 'cache', cache)
+    response = snapshots.admin.routes[('GET', '/snapshots')](query={'symbol': 'AAPL,SPY'}, body={})
+    assert sorted(response) == ['AAPL', 'SPY']
+    assert response['SPY']['bar']['close'] == 100.0
@@ -0,0 +1,83 @@
+import os
+from nexus.helpers import spool
+
+
+class FlakySNS:
+    def __init__(self):
+        self.up = True
+        self.published = []
+
+    def publish(self, data, topic):
+        if not self.up:
+            raise Exception('Could not connect to the endpoint URL')
+        self.published.append((data, topic))
+        return {'MessageId': '1'}
+
+    def publish_batch(self, messages, topic):
+        if not self.up:
+            raise Exception('Could not connect to the endpoint URL')
+        self.published += [(data, topic) for data in messages]
+        return {'Successful': [{'Id': str(i)} for i in range(len(messages))], 'Failed': []}
+
+
+def make_spool(directory, sns, **kwargs):
+    return spool.DiskSpool(str(directory), publish=sns.publish, publish_batch=sns.publish_batch, **kwargs)
+
+
+def test_repeated_failures_spool_until_replayed(tmp_path):
+    sns = FlakySNS()
+    disk = make_spool(tmp_path, sns, max_failures=2)
+    assert disk.publish('a', 'bars')
+    sns.up = False
+    assert not disk.publish('b', 'bars')
+    assert not disk.spooling
+    assert disk.publish_batch(['c', 'd'], 'news') == {'Failed': [], 'Spooled': 2}
+    assert disk.spooling
+    sns.up = True
+    assert not disk.publish('e', 'bars')
+    assert sns.published == [('a', 'bars')]
+    assert disk.replay() == 4
+    assert sorted(sns.published[1:]) == [('b', 'bars'), ('c', 'news'), ('d', 'news'), ('e', 'bars')]
+    assert not disk.spooling and disk.size == 0 and os.listdir(tmp_path) == []
+
+
+def test_partial_replay_keeps_what_was_not_published(tmp_path):
+    sns = FlakySNS()
+    disk = make_spool(tmp_path, sns)
+    disk.append([('a', 'bars'), ('b', 'news')])
+    calls = []
+
+    def publish_batch(messages, topic):
+        calls.append(topic)
+        if len(calls) > 1:
+            raise Exception('Throttled')
+        return sns.publish_batch(messages, topic)
+
+    disk._publish_batch = publish_batch
+    assert disk.replay() == 1
+    assert len(disk.segments) == 1
+    disk._publish_batch = sns.publish_batch
+    assert disk.replay() == 1
+    assert sorted(sns.published) == [('a', 'bars'), ('b', 'news')]
+    assert not disk.segments and not disk.spooling
+
+
+def test_oldest_segments_are_evicted_when_full(tmp_path):
+    disk = make_spool(tmp_path, FlakySNS(), max_bytes=200, segment_bytes=50)
+    for i in range(10):
+        disk.append([(f'message-{i}', 'bars')])
+    assert disk.size <= 200
+    assert len(disk.segments) < 10
+    with open(disk.segments[-1]) as file:
+        assert 'message-9' in file.read()
+
+
+def test_segments_left_by_a_previous_run_are_replayed(tmp_path):
+    sns = FlakySNS()
+    make_spool(tmp_path, sns).append([('a', 'bars')])
+    disk = make_spool(tmp_path, sns)
+    assert disk.spooling and disk.size > 0
+    assert not disk.publish('b', 'bars')
+    assert disk.replay() == 1 and disk.replay() == 1
+    assert not disk.spooling
+    assert sns.published == [('a', 'bars'), ('b', 'bars')]
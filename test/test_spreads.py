@@ -0,0 +1,70 @@
+import math
+import pytest
+from nexus.helpers import spreads
+
+
+def test_parse_pairs():
+    assert spreads.parse_pairs('AAPL:XLK, JPM:XLF') == {'AAPL': 'XLK', 'JPM': 'XLF'}
+    assert spreads.parse_pairs(None) == {}
+    with pytest.raises(ValueError):
+        spreads.parse_pairs('AAPL')
+    with pytest.raises(ValueError):
+        spreads.parse_pairs('XLK:XLK')
+
+
+def test_align_keeps_shared_timestamps():
+    stock = [{'timestamp': 't1', 'close': 10}, {'timestamp': 't2', 'close': 11}, {'timestamp': 't3', 'close': 12}]
+    etf = [{'timestamp': 't1', 'close': 100}, {'timestamp': 't3', 'close': 102}]
+    assert spreads.align(stock, etf) == ([10, 12], [100, 102])
+
+
+def test_beta_recovers_return_multiple():
+    etf = [100 * math.exp(0.01 * ((-1) ** i) * (i % 3)) for i in range(30)]
+    stock = [50 * (price / 100) ** 1.5 for price in etf]
+    assert spreads.beta(stock, etf) == pytest.approx(1.5)
+    betas = spreads.rolling_beta(stock, etf, window=10)
+    assert betas[:9] == [None] * 9
+    assert betas[-1] == pytest.approx(1.5)
+    with pytest.raises(ValueError):
+        spreads.beta([1, 2, 3], [5, 5, 5])
+
+
+def test_spread_and_basket_spread():
+    stock, etf = [10.0, 20.0], [100.0, 50.0]
+    assert spreads.spread(stock, etf, 0.5) == pytest.approx([math.log(10) - 0.5 * math.log(100), math.log(20) - 0.5 * math.log(50)])
+    assert spreads.spread(stock, etf, 0.5, log_prices=False) == [-40, -5]
+    basket = spreads.basket_spread([[1, 2], [3, 4], [5, 6]], [1, -2, 0.5])
+    assert basket == [1 - 6 + 2.5, 2 - 8 + 3]
+    assert spreads.basket_spread([stock, etf], [1, -0.5], log_prices=True) == spreads.spread(stock, etf, 0.5)
+    with pytest.raises(ValueError):
+        spreads.basket_spread([[1, 2], [3]], [1, -1])
+    with pytest.raises(ValueError):
+        spreads.basket_spread([[1, 2]], [1, -1])
+
+
+def test_spread_signal_fires_on_stock_specific_move():
+    etf = [100 * math.exp(0.01 * ((-1) ** i) * (i % 3)) for i in range(60)]
+    stock = [50 * (price / 100) * (1 + 0.001 * ((-1) ** i)) for i, price in enumerate(etf)]
+    quiet = spreads.spread_signal(stock, etf, beta_window=40, band_window=20)
+    assert quiet['direction'] == 0
+    stock[-1] *= 1.05
+    rich = spreads.spread_signal(stock, etf, beta_window=40, band_window=20)
+    assert rich['direction'] == -1
+    assert spreads.spread_signal(stock[:10], etf[:10], beta_window=40) is None
+    assert spreads.hedge_qty(-1, 200, 100, 1.5) == pytest.approx(3)
+
+
+def test_rolling_hedge_ratio_tracks_drift():
+    etf = [100 + 5 * math.sin(i / 3) for i in range(60)]
+    stock = [1.5 * price if i < 30 else 2.0 * price for i, price in enumerate(etf)]
+    ratios = spreads.rolling_hedge_ratio(stock, etf, window=10, log_prices=False)
+    assert ratios[:9] == [None] * 9
+    assert ratios[20] == pytest.approx(1.5)
+    assert ratios[-1] == pytest.approx(2.0)
+    drift = spreads.hedge_ratio_drift(ratios, reference=1.5)
+    assert drift['drift'] == pytest.approx(1 / 3)
+    assert drift['drifted']
+    assert not spreads.hedge_ratio_drift(ratios[:25], reference=1.5)['drifted']
+    assert spreads.hedge_ratio_drift([None], reference=1.5) == {'current': None, 'drift': None, 'drifted': False}
+    with pytest.raises(ValueError):
+        spreads.hedge_ratio([1, 2, 3], [5, 5, 5])
@@ -1,4 +1,5 @@
 import pytest
+from types import SimpleNamespace
 import numpy as np
 from nexus.helpers import statistics
 
@@ -43,6 +44,30 @@ def test_standard_deviation():
     assert statistics.standard_deviation([5]) == 0.0
 
 
+def test_quantile_and_percentile():
+    data = [5, 1, 4, 2, 3]
+    assert statistics.quantile(data, 0.5) == 3
+    assert statistics.quantile(data, 0.0) == 1
+    assert statistics.quantile(data, 0.1) == pytest.approx(1.4)
+    assert statistics.percentile(data, 75) == 4
+    with pytest.raises(ValueError):
+        statistics.quantile(data, 1.5)
+    with pytest.raises(ValueError):
+        statistics.quantile([], 0.5)
+
+
+def test_skewness_and_kurtosis():
+    assert statistics.skewness([1, 2, 3, 4, 5]) == 0
+    assert statistics.skewness([1, 1, 1, 1, 10]) > 1
+    assert statistics.skewness([-10, 1, 1, 1, 1]) < -1
+    # Two equally likely values have the thinnest possible tails
+    assert statistics.excess_kurtosis([-1, 1] * 50) == pytest.approx(-2)
+    assert statistics.excess_kurtosis([0] * 98 + [-10, 10]) > 10
+    assert statistics.skewness([2, 2, 2]) == 0 and statistics.excess_kurtosis([2, 2, 2]) == 0
+    summary = statistics.describe([1, 2, 3, 4, 5])
+    assert summary['count'] == 5 and summary['quantiles'][0.5] == 3
+
+
 # Test time series functions
 def test_adf_test(stationary_series, random_walk):
     # Test stationary series
@@ -54,6 +79,19 @@ def test_adf_test(stationary_series, random_walk):
     assert result[1] > 0.05
 
 
+def test_kpss_test(stationary_series, random_walk):
+    # KPSS's null is stationarity, the reverse of ADF
+    assert statistics.kpss_test(stationary_series)[1] > 0.05
+    assert statistics.kpss_test(random_walk)[1] < 0.05
+
+
+def test_stationarity_test_requires_agreement(stationary_series, random_walk):
+    assert statistics.stationarity_test(stationary_series)['is_stationary']
+    result = statistics.stationarity_test(random_walk)
+    assert not result['is_stationary']
+    assert result['adf_p_value'] > 0.05 and result['kpss_p_value'] < 0.05
+
+
 def test_half_life():
     # 1. Test mean-reverting series with proper noise
     np.random.seed(42)
@@ -80,6 +118,26 @@ def test_half_life():
     assert hl is None or hl > 200
 
 
+def test_hurst_confidence_interval(random_walk, stationary_series):
+    # Increments of a random walk are white noise, H = 0.5
+    dfa = statistics.hurst_confidence_interval(random_walk)
+    low, high = dfa['confidence_interval']
+    assert low < 0.5 < high
+    assert np.isclose(dfa['hurst'], 0.5, atol=0.1)
+    assert dfa['standard_error'] > 0 and dfa['windows'] >= 4
+
+    rs = statistics.hurst_confidence_interval(random_walk, method='rs')
+    assert np.isclose(rs['hurst'], 0.5, atol=0.15)
+
+    # A stationary series is strongly mean reverting
+    assert statistics.hurst_confidence_interval(stationary_series)['confidence_interval'][1] < 0.5
+
+    with pytest.raises(ValueError):
+        statistics.hurst_confidence_interval(random_walk, method='variogram')
+    with pytest.raises(ValueError):
+        statistics.hurst_confidence_interval(random_walk[:40])
+
+
 def test_cointegration_adf_test():
     # Create more obviously cointegrated pair
     np.random.seed(42)
@@ -88,6 +146,8 @@ def test_cointegration_adf_test():
 
     result = statistics.cointegration_adf_test(X, Y)
     assert result['is_cointegrated']
+    confirmed = statistics.cointegration_adf_test(X, Y, confirm_with_kpss=True)
+    assert confirmed['is_cointegrated'] and confirmed['kpss_p_value'] > 0.05
 
     # Create clearly non-cointegrated pair
     np.random.seed(42)
@@ -97,6 +157,28 @@ def test_cointegration_adf_test():
     assert not result['is_cointegrated']
 
 
+def test_engle_granger_test():
+    np.random.seed(42)
+    X = np.cumsum(np.random.normal(0, 1, 200))
+    Y = 2*X + np.random.normal(0, 0.5, 200)
+    result = statistics.engle_granger_test(X, Y)
+    assert result['p_value'] < 0.01
+    assert result['statistic'] < result['critical_values']['1%']
+
+    Y = np.cumsum(np.random.normal(0, 1, 200))
+    assert statistics.engle_granger_test(X, Y)['p_value'] > 0.05
+    with pytest.raises(ValueError):
+        statistics.engle_granger_test([1, 2], [3])
+
+
+def test_adjust_p_values():
+    assert statistics.adjust_p_values([0.01, 0.04], 'bonferroni') == pytest.approx([0.02, 0.08])
+    assert statistics.adjust_p_values([0.01, 0.04, 0.03]) == pytest.approx([0.03, 0.04, 0.04])
+    assert statistics.adjust_p_values([]) == []
+    with pytest.raises(ValueError):
+        statistics.adjust_p_values([0.01], 'holm')
+
+
 def test_johansen_test():
     # Create cointegrated system
     np.random.seed(42)
@@ -106,6 +188,114 @@ def test_johansen_test():
 
     result = statistics.johansen_test([X, Y, Z])
     assert result['cointegration_rank'] == 2  # Should find 2 cointegrating relationships
+    assert result['eigenvalues'] == sorted(result['eigenvalues'], reverse=True)
+    assert len(result['eigenvectors']) == 3
+    assert len(result['max_eigen_statistics']) == 3
+    assert len(result['cointegrating_vectors']) == 2
+    assert all(np.isclose(vector[0], 1) for vector in result['cointegrating_vectors'])
+    # The leading vector makes the basket stationary
+    basket = np.column_stack((X, Y, Z)) @ np.array(result['cointegrating_vectors'][0])
+    assert statistics.adf_test(basket.tolist())[1] < 0.05
+
+    strict = statistics.johansen_test([X, Y, Z], significance='99%')
+    assert strict['trace_rank'] <= result['trace_rank']
+    with pytest.raises(ValueError):
+        statistics.johansen_test([X, Y, Z], det_order=2)
+    with pytest.raises(ValueError):
+        statistics.johansen_test([X])
+
+
+# Test correlation and covariance
+def test_vecm():
+    np.random.seed(11)
+    x = np.cumsum(np.random.normal(0, 1, 500)) + 100
+    spread = np.zeros(500)
+    for i in range(1, 500):
+        spread[i] = 0.5 * spread[i - 1] + np.random.normal(0, 0.5)
+    y = 2 * x + spread
+    fit = statistics.vecm([y.tolist(), x.tolist()], coint_rank=1, steps=3)
+    assert fit['rank'] == 1
+    assert np.isclose(fit['beta'][0][0], 1.0)
+    assert np.isclose(fit['beta'][1][0], -2.0, atol=0.05)
+    # y carries the correction, x is the random walk
+    assert fit['alpha'][0][0] < 0
+    assert len(fit['forecast']) == 3 and len(fit['forecast'][0]) == 2
+    assert len(fit['spread_forecast']) == 3
+    with pytest.raises(ValueError):
+        statistics.vecm([y.tolist(), x.tolist()], coint_rank=2)
+
+
+def test_covariance_and_correlation_matrices():
+    np.random.seed(42)
+    market = np.random.normal(0, 0.01, 500)
+    returns = {
+        'AAPL': (market + np.random.normal(0, 0.002, 500)).tolist(),
+        'MSFT': (market + np.random.normal(0, 0.002, 500)).tolist(),
+        'XOM': np.random.normal(0, 0.01, 500).tolist(),
+    }
+    covariance = statistics.covariance_matrix(returns)
+    assert covariance['symbols'] == ['AAPL', 'MSFT', 'XOM']
+    assert np.isclose(covariance['matrix'][0][0], np.var(returns['AAPL'], ddof=1))
+    assert np.allclose(covariance['matrix'], np.array(covariance['matrix']).T)
+
+    correlation = statistics.correlation_matrix(returns)
+    assert np.allclose(np.diag(correlation['matrix']), 1)
+    assert correlation['matrix'][0][1] > 0.9
+    assert abs(correlation['matrix'][0][2]) < 0.2
+
+    pairs = statistics.correlated_pairs(returns, min_correlation=0.7)
+    assert [(a, b) for a, b, _ in pairs] == [('AAPL', 'MSFT')]
+
+    with pytest.raises(ValueError):
+        statistics.covariance_matrix({'AAPL': [0.01, 0.02], 'MSFT': [0.01]})
+
+
+def test_ledoit_wolf_covariance_is_well_conditioned():
+    np.random.seed(8)
+    # More symbols than observations, the sample covariance is singular
+    returns = {f'S{i}': np.random.normal(0, 0.01, 30).tolist() for i in range(50)}
+    sample = statistics.covariance_matrix(returns)
+    shrunk = statistics.covariance_matrix(returns, estimator='ledoit_wolf')
+    assert sample['shrinkage'] == 0.0
+    assert 0 < shrunk['shrinkage'] <= 1
+    assert np.linalg.cond(shrunk['matrix']) < np.linalg.cond(sample['matrix'])
+    assert np.all(np.linalg.eigvalsh(shrunk['matrix']) > 0)
+    with pytest.raises(ValueError):
+        statistics.covariance_matrix(returns, estimator='oas')
+
+
+def test_rolling_correlation():
+    X = [1, 2, 3, 4, 5, 6]
+    Y = [2, 4, 6, 8, 10, 5]
+    correlations = statistics.rolling_correlation(X, Y, window=3)
+    assert np.all(np.isnan(correlations[:2]))
+    assert np.allclose(correlations[2:5], 1)
+    assert correlations[5] < 0
+    assert np.isnan(statistics.rolling_correlation([1, 1, 1], [1, 2, 3], window=3)[-1])
+
+    rolling = statistics.rolling_correlations({'A': X, 'B': Y, 'C': X[::-1]}, window=3)
+    assert list(rolling) == [('A', 'B'), ('A', 'C'), ('B', 'C')]
+    assert np.allclose(rolling[('A', 'C')][2:], -1)
+
+
+def test_pca_finds_the_market_factor():
+    np.random.seed(42)
+    market = np.random.normal(0, 0.01, 1000)
+    returns = {symbol: (market + np.random.normal(0, 0.003, 1000)).tolist() for symbol in ('A', 'B', 'C', 'D')}
+    decomposition = statistics.pca(returns)
+    assert decomposition['eigenvalues'] == sorted(decomposition['eigenvalues'], reverse=True)
+    assert np.isclose(sum(decomposition['explained_variance_ratio']), 1)
+    assert decomposition['explained_variance_ratio'][0] > 0.8
+    # Every stock loads equally on the market
+    assert np.allclose(decomposition['loadings'][0], 0.5, atol=0.05)
+    assert np.isclose(np.linalg.norm(decomposition['loadings'][1]), 1)
+    assert len(statistics.pca(returns, n_components=2)['loadings']) == 2
+
+    # Removing the market leaves the idiosyncratic noise, uncorrelated with it
+    residuals = statistics.factor_residuals(returns, n_factors=1)
+    assert list(residuals) == ['A', 'B', 'C', 'D']
+    assert abs(np.corrcoef(residuals['A'], market)[0, 1]) < 0.1
+    assert np.std(residuals['A']) < np.std(returns['A']) / 2
 
 
 # Test Bollinger Bands
@@ -125,6 +315,65 @@ def test_bollinger_bands():
     valid_upper = bands['upper_band'][window-1:]
     assert np.allclose(valid_upper, expected_upper, atol=0.01)
 
+    # A steady climb closes above the middle band, the bands are 4 std devs wide
+    assert np.allclose(bands['percent_b'][window-1:], 0.5 + 1 / (4*std_dev))
+    assert np.allclose(bands['bandwidth'][window-1:], 4*std_dev / np.array(expected_middle))
+    assert np.isnan(bands['percent_b'][0])
+
+    # %B follows the multiplier
+    narrow = statistics.bollinger_bands(data, window, num_std=1)
+    assert np.allclose(narrow['percent_b'][window-1:], 0.5 + 1 / (2*std_dev))
+
+    flat = statistics.bollinger_bands([5, 5, 5, 5], window)
+    assert np.all(np.isnan(flat['percent_b']))
+
+
+# Test GARCH
+def test_garch():
+    # Simulate GARCH(1,1) returns with omega=0.05, alpha=0.1, beta=0.85
+    np.random.seed(42)
+    n_points = 5000
+    returns = np.zeros(n_points)
+    variance = 0.05 / (1 - 0.1 - 0.85)
+    for i in range(n_points):
+        returns[i] = np.sqrt(variance) * np.random.normal()
+        variance = 0.05 + 0.1 * returns[i] ** 2 + 0.85 * variance
+
+    fit = statistics.garch(returns.tolist())
+    assert np.isclose(fit['alpha'], 0.1, atol=0.05)
+    assert np.isclose(fit['beta'], 0.85, atol=0.07)
+    assert fit['persistence'] < 1
+    assert len(fit['conditional_volatility']) == n_points
+
+    # The forecast follows the variance recursion one step past the data
+    residual = returns[-1] - returns.mean()
+    expected = fit['omega'] + fit['alpha'] * residual ** 2 + fit['beta'] * fit['conditional_volatility'][-1] ** 2
+    assert np.isclose(fit['forecast_volatility'], np.sqrt(expected))
+
+    # Scaling returns scales the volatilities but not the dynamics
+    scaled = statistics.garch((returns / 100).tolist())
+    assert np.isclose(scaled['alpha'], fit['alpha'], atol=1e-3)
+    assert np.isclose(scaled['forecast_volatility'], fit['forecast_volatility'] / 100, rtol=1e-2)
+
+
+def test_garch_raises_when_the_fit_does_not_converge(monkeypatch):
+    np.random.seed(42)
+    failed = SimpleNamespace(success=False, message='Iteration limit reached', x=np.array([0.1, 0.05, 0.85]), fun=0.0)
+    monkeypatch.setattr(statistics, 'minimize', lambda *args, **kwargs: failed)
+    with pytest.raises(ValueError, match='did not converge'):
+        statistics.garch(np.random.normal(0, 0.01, 500).tolist())
+
+    with pytest.raises(ValueError):
+        statistics.garch([0.01] * 10)
+    with pytest.raises(ValueError):
+        statistics.garch([0.0] * 100)
+
+
+def test_volatility_scale():
+    assert statistics.volatility_scale(0.02, 0.01) == 0.5
+    assert statistics.volatility_scale(0.001, 0.01) == 2.0
+    assert statistics.volatility_scale(0, 0.01, max_scale=3.0) == 3.0
+
 
 # Test regression functions
 def test_linear_regression():
@@ -135,6 +384,52 @@ def test_linear_regression():
     assert np.isclose(intercept, 1.0, atol=0.1)
 
 
+def test_variance_ratio_test():
+    np.random.seed(3)
+    walk = np.cumsum(np.random.normal(0, 1, 2000))
+    reverting = np.zeros(2000)
+    for i in range(1, 2000):
+        reverting[i] = 0.3 * reverting[i - 1] + np.random.normal()
+    assert abs(statistics.variance_ratio_test(walk)['variance_ratio'] - 1) < 0.1
+    result = statistics.variance_ratio_test(reverting)
+    assert result['variance_ratio'] < 0.8
+    assert result['p_value'] < 0.01
+    with pytest.raises(ValueError):
+        statistics.variance_ratio_test(walk, period=1)
+
+
+def test_screen_series():
+    np.random.seed(5)
+    reverting = np.zeros(1000)
+    for i in range(1, 1000):
+        reverting[i] = 0.8 * reverting[i - 1] + np.random.normal()
+    screen = statistics.screen_series(reverting)
+    assert screen['is_mean_reverting']
+    assert all(screen['checks'].values())
+    assert 2 < screen['half_life'] < 5
+
+    assert not statistics.screen_series(reverting, max_half_life=1)['is_mean_reverting']
+    walk = np.cumsum(np.random.normal(0, 1, 1000))
+    screen = statistics.screen_series(walk)
+    assert not screen['is_mean_reverting']
+    assert not screen['checks']['adf']
+
+
+def test_robust_linear_regression_ignores_bad_prints():
+    X = list(range(1, 51))
+    Y = [2 * x + 1 for x in X]
+    # A flash crash print and a fat finger in the response
+    Y[10], Y[30] = 0.0, 1000.0
+    ols, _ = statistics.linear_regression(X, Y)
+    assert not np.isclose(ols, 2.0, atol=0.05)
+    for method in ('theil_sen', 'huber'):
+        slope, intercept = statistics.linear_regression(X, Y, method=method)
+        assert np.isclose(slope, 2.0, atol=0.05), method
+        assert np.isclose(intercept, 1.0, atol=1.0), method
+    with pytest.raises(ValueError):
+        statistics.linear_regression(X, Y, method='lad')
+
+
 def test_multiple_regression():
     np.random.seed(42)  # Fixed seed for reproducibility
 
@@ -153,6 +448,25 @@ def test_multiple_regression():
     assert np.isclose(coefficients[2], 3, atol=0.1), f"X2 coefficient: {coefficients[2]}"
 
 
+def test_least_squares_reports_fit_quality():
+    # Two-leg hedge of a spread: Y = 0.5 + 1.5*leg1 - 0.75*leg2 exactly
+    X = [[1, 2], [2, 1], [3, 5], [4, 3], [5, 8], [6, 2]]
+    Y = [0.5 + 1.5*a - 0.75*b for a, b in X]
+    fit = statistics.least_squares(X, Y)
+    assert np.isclose(fit['intercept'], 0.5)
+    assert np.allclose(fit['coefficients'], [1.5, -0.75])
+    assert np.allclose(fit['residuals'], 0, atol=1e-9)
+    assert np.isclose(fit['r_squared'], 1.0)
+
+    noisy = statistics.least_squares([[1], [2], [3], [4]], [1, 3, 2, 4])
+    assert len(noisy['residuals']) == 4
+    assert np.isclose(sum(noisy['residuals']), 0, atol=1e-9)
+    assert 0 < noisy['r_squared'] < 1
+
+    with pytest.raises(ValueError):
+        statistics.least_squares([[1, 2], [2, 3]], [1, 2])
+
+
 # Edge case tests
 def test_empty_input():
     with pytest.raises(ZeroDivisionError):
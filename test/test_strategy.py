@@ -0,0 +1,29 @@
+import pytest
+from nexus.helpers import brokers, strategy
+
+
+@pytest.fixture
+def risk_manager():
+    mock = brokers.MockBroker(shortable={'AAPL'})
+    brokers.set_broker(mock)
+    yield strategy.RiskManager(strategy.TradingStateManager(logger=strategy.logger.Logger('test_strategy.py')))
+    brokers.set_broker(None)
+
+
+def test_shorts_need_a_shortable_asset(risk_manager):
+    assert risk_manager.validate_order('AAPL', -10, 100.0)
+    assert not risk_manager.validate_order('GME', -10, 20.0)
+    # Selling out of a long is not a short
+    risk_manager.state.update_position('GME', 10, 20.0)
+    assert risk_manager.validate_order('GME', -10, 20.0)
+
+
+def test_orders_without_an_fx_rate_are_rejected_by_name(risk_manager, monkeypatch):
+    monkeypatch.setenv('INSTRUMENT_CURRENCIES', 'SHOP.TO:CAD')
+    monkeypatch.setenv('FX_RATES', 'EURUSD=1.10')
+    monkeypatch.setattr(strategy.fx, 'fx_rates', None)
+    errors = []
+    monkeypatch.setattr(risk_manager.state.logger, 'error', errors.append)
+    assert not risk_manager.validate_order('SHOP.TO', 10, 100.0)
+    assert errors[0].startswith('Missing FX rate for CAD, rejecting 10 SHOP.TO order')
+    assert risk_manager.validate_order('AAPL', 10, 100.0)
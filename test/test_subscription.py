@@ -0,0 +1,58 @@
+import pytest
+from nexus.helpers import subscription
+
+
+def test_plan_limit_refuses_or_trims():
+    universe = [f'S{i}' for i in range(35)]
+    with pytest.raises(subscription.SubscriptionLimitExceeded):
+        subscription.check_universe(universe, plan='basic')
+    assert subscription.check_universe(universe, plan='basic', trim=True) == universe[:30]
+    assert subscription.check_universe(universe, plan='plus') == universe
+    assert subscription.check_universe(universe) == universe
+    with pytest.raises(ValueError):
+        subscription.check_universe(universe, plan='gold')
+
+
+def test_message_budget_limits_universe():
+    per_symbol = subscription.projected_monthly_messages(1)
+    assert per_symbol == subscription.BARS_PER_SESSION * subscription.SESSIONS_PER_MONTH
+    assert subscription.max_symbols(None, monthly_budget=per_symbol * 10) == 10
+    assert subscription.max_symbols('basic', monthly_budget=per_symbol * 100) == 30
+    with_news = subscription.max_symbols(None, channels=('bars', 'news'), monthly_budget=per_symbol * 10)
+    assert with_news == 9
+    assert subscription.check_universe(['A', 'B', 'C'], monthly_budget=per_symbol * 2, trim=True) == ['A', 'B']
+
+
+def test_parse_and_load_universe(tmp_path, monkeypatch):
+    assert subscription.parse_universe('AAPL, MSFT,\nAAPL\n') == ['AAPL', 'MSFT']
+    assert subscription.parse_universe('["SPY", "QQQ"]') == ['SPY', 'QQQ']
+    path = tmp_path / 'universe.txt'
+    path.write_text('XLE\nXOM\n')
+    monkeypatch.delenv('UNIVERSE_S3', raising=False)
+    monkeypatch.setenv('UNIVERSE', 'AAPL')
+    assert subscription.load_universe() == ['AAPL']
+    monkeypatch.setenv('UNIVERSE_FILE', str(path))
+    assert subscription.load_universe() == ['XLE', 'XOM']
+    monkeypatch.delenv('UNIVERSE_FILE')
+    monkeypatch.setenv('UNIVERSE', '')
+    with pytest.raises(ValueError):
+        subscription.load_universe()
+
+
+def test_watchlist_add_and_remove():
+    watchlist = subscription.Watchlist(['AAPL', 'MSFT'])
+    assert watchlist.add(['MSFT', 'NVDA']) == ['NVDA']
+    assert watchlist.remove(['AAPL', 'TSLA']) == ['AAPL']
+    assert watchlist.symbols() == ['MSFT', 'NVDA']
+    assert 'NVDA' in watchlist and 'AAPL' not in watchlist
+    assert len(watchlist) == 2
+
+
+def test_control_commands():
+    command = subscription.parse_control_command(subscription.control_command('subscribe', ['MSFT', 'GOOG']))
+    assert command == {'action': 'subscribe', 'symbols': ['MSFT', 'GOOG'], 'types': ('bars',)}
+    assert subscription.parse_control_command('{"action": "unsubscribe", "symbols": ["MSFT"]}')['types'] == ('bars',)
+    for body in ('not json', '{"action": "halt", "symbols": ["MSFT"]}', '{"action": "subscribe", "symbols": []}',
+                 '{"action": "subscribe", "symbols": ["MSFT"], "types": ["quotes"]}'):
+        with pytest.raises(ValueError):
+            subscription.parse_control_command(body)
@@ -0,0 +1,65 @@
+import json
+import pytest
+from datetime import datetime, timedelta, timezone
+from nexus.helpers import supervision
+
+NOW = datetime(2025, 3, 3, 15, 0, tzinfo=timezone.utc)
+
+ORDER = {'symbol': 'AAPL', 'side': 'buy', 'qty': 1, 'notional': None, 'client_order_id': 'abc'}
+
+
+@pytest.fixture(autouse=True)
+def reset_queues():
+    yield
+    supervision.queues.clear()
+
+
+def test_operator_decisions_gate_release():
+    queue = supervision.SignalQueue('reversion', auto_approve_seconds=0)
+    approved = queue.submit(ORDER, context={'timestamp': NOW.isoformat()}, now=NOW)
+    vetoed = queue.submit(dict(ORDER, symbol='MSFT'), now=NOW)
+    queue.annotate(approved, 'dana', 'earnings tomorrow, small size ok')
+    assert [idea['id'] for idea in queue.pending()] == [approved, vetoed]
+    assert queue.release(NOW + timedelta(hours=1)) == []
+
+    queue.approve(approved, 'dana')
+    queue.veto(vetoed, 'dana', 'halted')
+    released = queue.release(NOW)
+    assert [idea.id for idea in released] == [approved]
+    assert released[0].notes == [{'operator': 'dana', 'note': 'earnings tomorrow, small size ok'}]
+    assert queue.pending() == []
+    with pytest.raises(ValueError):
+        queue.approve(vetoed, 'dana')
+
+
+def test_pending_ideas_auto_approve_after_timeout():
+    queue = supervision.SignalQueue('reversion', auto_approve_seconds=60)
+    idea_id = queue.submit(ORDER, now=NOW)
+    assert queue.release(NOW + timedelta(seconds=59)) == []
+    released = queue.release(NOW + timedelta(seconds=60))
+    assert [(idea.id, idea.status) for idea in released] == [(idea_id, 'auto_approved')]
+
+
+def test_decisions_are_journaled_with_automated_ones(tmp_path):
+    path = str(tmp_path / 'journal.jsonl')
+    queue = supervision.register_queue(
+        supervision.SignalQueue('reversion', auto_approve_seconds=60, journal=supervision.Journal(path))
+    )
+    first = queue.submit(ORDER, now=NOW)
+    second = queue.submit(ORDER, now=NOW)
+    supervision.admin.routes[('POST', '/ideas/veto')](query={}, body={'strategy': 'reversion', 'id': first, 'operator': 'mallory'}, identity='dana')
+    with pytest.raises(ValueError):
+        supervision.admin.routes[('POST', '/ideas/approve')](query={}, body={'strategy': 'momentum', 'id': second}, identity='dana')
+    idea = queue.release(NOW + timedelta(minutes=5))[0]
+    queue.record_execution(idea, placed=True)
+
+    with open(path) as file:
+        entries = [json.loads(line) for line in file]
+    assert [(entry['event'], entry['actor'], entry['idea']) for entry in entries] == [
+        ('signal', 'strategy', first),
+        ('signal', 'strategy', second),
+        ('vetoed', 'dana', first),
+        ('auto_approved', 'auto', second),
+        ('executed', 'strategy', second),
+    ]
+    assert supervision.admin.routes[('GET', '/ideas/journal')](query={'strategy': 'reversion'}, body={})['entries'][-1]['placed'] is True
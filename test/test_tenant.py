@@ -0,0 +1,42 @@
+import pytest
+from nexus.helpers import tenant, metrics, broker
+
+
+def test_default_tenant_keeps_existing_names(monkeypatch):
+    monkeypatch.delenv('TENANT', raising=False)
+    assert tenant.get_tenant() == 'default'
+    assert tenant.resource_name('reversion') == 'reversion'
+    assert tenant.scoped_path('/var/nexus/drawdown.json') == '/var/nexus/drawdown.json'
+
+
+def test_names_and_paths_are_namespaced(monkeypatch):
+    monkeypatch.setenv('TENANT', 'pool-b')
+    assert tenant.resource_name('ReversionService') == 'ReversionService-pool-b'
+    assert tenant.scoped_path('/var/nexus/drawdown.json') == '/var/nexus/drawdown-pool-b.json'
+    with pytest.raises(ValueError):
+        monkeypatch.setenv('TENANT', 'Pool B')
+        tenant.get_tenant()
+
+
+def test_scoped_config_takes_precedence(monkeypatch):
+    monkeypatch.setenv('BROKER_API_KEY', 'shared')
+    monkeypatch.delenv('TENANT', raising=False)
+    monkeypatch.setenv('POOL_B__BROKER_API_KEY', 'pool-b-key')
+    assert tenant.getenv('BROKER_API_KEY') == 'shared'
+    monkeypatch.setenv('TENANT', 'pool-b')
+    assert tenant.getenv('BROKER_API_KEY') == 'pool-b-key'
+    assert tenant.getenv('REVERSION_NOTIONAL', '0') == '0'
+
+
+def test_metrics_and_order_ids_do_not_cross_tenants(monkeypatch):
+    metrics.reset()
+    monkeypatch.delenv('TENANT', raising=False)
+    default_id = broker.generate_client_order_id('reversion', 'AAPL', '2025-02-03T15:00:00+00:00')
+    metrics.increment('orders_placed', symbol='AAPL')
+    monkeypatch.setenv('TENANT', 'pool-b')
+    pool_b_id = broker.generate_client_order_id('reversion', 'AAPL', '2025-02-03T15:00:00+00:00')
+    metrics.increment('orders_placed', symbol='AAPL')
+    assert default_id != pool_b_id and pool_b_id.startswith('reversion-pool-b-AAPL-')
+    assert metrics.get_counter('orders_placed', symbol='AAPL') == 1
+    assert {'symbol': 'AAPL', 'tenant': 'pool-b'} in [counter['labels'] for counter in metrics.snapshot()['counters']]
+    metrics.reset()
@@ -0,0 +1,25 @@
+from datetime import datetime, timedelta, timezone
+from nexus.helpers import throttle
+
+OPEN = datetime(2025, 3, 3, 14, 30, tzinfo=timezone.utc)
+
+
+def test_stop_out_starts_cooldown():
+    guard = throttle.TradeThrottle(stop_out_pct=1, cooldown_seconds=1800)
+    guard.record_round_trip('AAPL', pnl=-5, entry_notional=1000, at=OPEN)
+    assert guard.allows('AAPL', OPEN + timedelta(minutes=10))
+    guard.record_round_trip('AAPL', pnl=-15, entry_notional=1000, at=OPEN)
+    assert not guard.allows('AAPL', OPEN + timedelta(minutes=29))
+    assert guard.allows('AAPL', OPEN + timedelta(minutes=30))
+    assert guard.allows('MSFT', OPEN)
+
+
+def test_round_trip_limit_resets_next_session():
+    guard = throttle.TradeThrottle(max_round_trips=2)
+    guard.record_round_trip('XLK', pnl=10, entry_notional=1000, at=OPEN)
+    assert guard.allows('XLK', OPEN + timedelta(hours=1))
+    guard.record_round_trip('XLK', pnl=-50, entry_notional=1000, at=OPEN + timedelta(hours=1))
+    assert not guard.allows('XLK', OPEN + timedelta(hours=2))
+    # 01:00 UTC is still the same New York session day
+    assert not guard.allows('XLK', OPEN + timedelta(hours=10, minutes=30))
+    assert guard.allows('XLK', OPEN + timedelta(days=1))
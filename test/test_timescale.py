@@ -0,0 +1,65 @@
+import threading
+import pytest
+from nexus.helpers import timescale
+
+
+def _bar(minute, close=100.0, **extra):
+    return {'symbol': 'AAPL', 'timestamp': f'2024-01-02T15:{minute:02d}:00+00:00', 'open': 99.0, 'high': 101.0,
+            'low': 98.0, 'close': close, 'volume': 1_000.0, 'trade_count': 10, **extra}
+
+
+def test_statements_upsert_on_each_tables_key():
+    statements = timescale.schema_statements()
+    assert "SELECT create_hypertable('quotes', 'timestamp', if_not_exists => TRUE)" in statements
+    assert 'PRIMARY KEY (symbol, timestamp)' in statements[0]
+    bars = timescale.upsert_statement('bars')
+    assert bars.startswith('INSERT INTO bars (symbol, timestamp, open,') and 'ON CONFLICT (symbol, timestamp) DO UPDATE' in bars
+    assert 'close = EXCLUDED.close' in bars
+
+
+def test_rows_keep_column_order_and_last_duplicate():
+    first, replay = timescale.to_row('bars', _bar(30)), timescale.to_row('bars', _bar(30, close=100.5, backfill=True))
+    assert first == ('AAPL', '2024-01-02T15:30:00+00:00', 99.0, 101.0, 98.0, 100.0, 1_000.0, 10, False)
+    assert timescale.dedupe('bars', [first, timescale.to_row('bars', _bar(31)), replay])[0] == replay
+
+
+def test_sink_inserts_full_batches_and_flushes():
+    sink = timescale.TimescaleSink('postgresql://localhost/market', batch_size=2, flush_seconds=60)
+    inserted = []
+    sink._insert = lambda table, rows: inserted.append((table, rows))
+    sink.record('bars', _bar(30))
+    sink.record('quotes', {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:00+00:00', 'bid_price': 1.0})
+    assert inserted == []
+    sink.record('bars', _bar(31))
+    sink.pending.join()
+    assert [(table, len(rows)) for table, rows in inserted] == [('bars', 2)]
+    sink.flush()
+    assert [table for table, _ in inserted] == ['bars', 'quotes'] and sink.buffers == {}
+    with pytest.raises(ValueError):
+        sink.record('news', _bar(32))
+
+
+def test_trades_are_keyed_on_exchange_and_trade_id():
+    trade = {'symbol': 'AAPL', 'timestamp': '2024-01-02T15:30:00+00:00', 'price': 100.0, 'size': 100.0, 'exchange': 'V', 'trade_id': 1}
+    rows = [timescale.to_row('trades', trade), timescale.to_row('trades', {**trade, 'trade_id': 2})]
+    assert len(timescale.dedupe('trades', rows)) == 2
+    assert len(timescale.dedupe('trades', rows + [rows[0]])) == 2
+    assert timescale.to_row('trades', {**trade, 'exchange': None, 'trade_id': None})[-2:] == ('', -1)
+    assert 'ON CONFLICT (symbol, timestamp, exchange, trade_id)' in timescale.upsert_statement('trades')
+
+
+def test_slow_database_does_not_block_record():
+    sink = timescale.TimescaleSink('postgresql://localhost/market', batch_size=1, flush_seconds=60)
+    release, inserted = threading.Event(), []
+
+    def insert(table, rows):
+        release.wait(1)
+        inserted.append(table)
+
+    sink._insert = insert
+    sink.record('bars', _bar(30))
+    sink.record('bars', _bar(31))
+    assert inserted == []
+    release.set()
+    sink.flush()
+    assert inserted == ['bars', 'bars']
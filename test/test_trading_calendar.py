@@ -0,0 +1,46 @@
+from datetime import date, datetime
+from nexus.helpers import sessions, trading_calendar
+
+
+def eastern(day, hour, minute=0):
+    return datetime(day.year, day.month, day.day, hour, minute, tzinfo=sessions.EASTERN)
+
+
+FRIDAY = date(2025, 11, 28)
+THANKSGIVING = date(2025, 11, 27)
+WEDNESDAY = date(2025, 11, 26)
+MONDAY = date(2025, 12, 1)
+
+
+def fake_fetch(calls):
+    def fetch(start, end):
+        calls.append((start, end))
+        return {
+            WEDNESDAY: (eastern(WEDNESDAY, 9, 30), eastern(WEDNESDAY, 16)),
+            FRIDAY: (eastern(FRIDAY, 9, 30), eastern(FRIDAY, 13)),
+            MONDAY: (eastern(MONDAY, 9, 30), eastern(MONDAY, 16)),
+        }
+    return fetch
+
+
+def test_holidays_and_early_closes():
+    calls = []
+    calendar = trading_calendar.TradingCalendar(fetch=fake_fetch(calls))
+    assert calendar.is_trading_day(WEDNESDAY)
+    assert not calendar.is_trading_day(THANKSGIVING)
+    assert calendar.is_early_close(FRIDAY)
+    assert not calendar.is_early_close(WEDNESDAY)
+    assert calendar.next_trading_day(WEDNESDAY) == FRIDAY
+    assert calendar.next_trading_day(FRIDAY) == MONDAY
+    assert calendar.flatten_time(FRIDAY) == eastern(FRIDAY, 12, 45)
+    assert calendar.flatten_time(THANKSGIVING) is None
+    assert calls == [(date(2025, 1, 1), date(2025, 12, 31))]
+
+
+def test_sessions_persist_across_restarts(tmp_path):
+    cache_file = str(tmp_path / 'calendar.json')
+    trading_calendar.TradingCalendar(fetch=fake_fetch([]), cache_file=cache_file).is_trading_day(FRIDAY)
+    calls = []
+    calendar = trading_calendar.TradingCalendar(fetch=fake_fetch(calls), cache_file=cache_file)
+    assert calendar.session_times(FRIDAY) == (eastern(FRIDAY, 9, 30), eastern(FRIDAY, 13))
+    assert calls == []
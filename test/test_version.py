@@ -0,0 +1,21 @@
+from nexus.helpers import version
+
+
+def test_config_hash_changes_with_config_but_hides_it(monkeypatch, tmp_path):
+    monkeypatch.setenv('SERVICE', 'reversion')
+    missing = str(tmp_path / 'missing.env')
+    first = version.config_hash(missing)
+    assert len(first) == 12 and 'reversion' not in first
+    assert version.config_hash(missing) == first
+    monkeypatch.setenv('SERVICE', 'data')
+    assert version.config_hash(missing) != first
+
+
+def test_build_info_is_resolved_once(monkeypatch):
+    monkeypatch.setattr(version, 'build_info', None)
+    monkeypatch.setenv('GIT_COMMIT', 'abc123')
+    monkeypatch.setenv('BUILD_TIME', '2025-03-03T15:00:00Z')
+    info = version.get_build_info()
+    assert (info['commit'], info['build_time']) == ('abc123', '2025-03-03T15:00:00Z')
+    monkeypatch.setenv('GIT_COMMIT', 'def456')
+    assert version.get_build_info() is info
@@ -0,0 +1,48 @@
+import pytest
+from datetime import date
+from nexus.helpers import volatility
+
+TODAY = date(2025, 3, 3)
+
+
+def test_atm_iv_uses_nearest_month_and_strike():
+    chain = {
+        'AAPL250404C00150000': {'implied_volatility': 0.30},
+        'AAPL250404P00150000': {'implied_volatility': 0.34},
+        'AAPL250404C00155000': {'implied_volatility': 0.28},
+        'AAPL250321C00150000': {'implied_volatility': 0.50},
+        'AAPL250404P00145000': {'implied_volatility': None},
+    }
+    assert volatility.atm_implied_volatility(chain, spot=151.0, today=TODAY) == 0.32
+    assert volatility.atm_implied_volatility({}, spot=151.0, today=TODAY) is None
+
+
+def test_rank_and_percentile():
+    history = [0.20, 0.25, 0.30, 0.40]
+    assert volatility.iv_rank(history, 0.30) == pytest.approx(50.0)
+    assert volatility.iv_percentile(history, 0.30) == 50.0
+    assert volatility.iv_rank([0.2, 0.2], 0.2) == 50.0
+    assert volatility.iv_rank([], 0.2) is None
+
+
+def test_history_keeps_lookback_and_persists(tmp_path):
+    state_file = str(tmp_path / 'iv.json')
+    features = volatility.IVFeatures(lookback_days=3, state_file=state_file)
+    for day, iv in enumerate([0.2, 0.3, 0.4, 0.6], start=1):
+        features.record('AAPL', date(2025, 3, day), iv)
+    features.save()
+    restored = volatility.IVFeatures(lookback_days=3, state_file=state_file)
+    assert restored.features('AAPL') == {'iv': 0.6, 'iv_rank': 100.0, 'iv_percentile': pytest.approx(200 / 3), 'observations': 3}
+
+
+def test_filter_blocks_only_with_enough_history(monkeypatch):
+    features = volatility.IVFeatures()
+    monkeypatch.setattr(features, 'refresh', lambda symbol: None)
+    iv_filter = volatility.IVFilter(features, max_iv_rank=80, min_observations=3)
+    features.record('AAPL', date(2025, 3, 1), 0.2)
+    features.record('AAPL', date(2025, 3, 2), 0.9)
+    assert iv_filter.allows('AAPL')
+    features.record('AAPL', date(2025, 3, 3), 0.9)
+    assert not iv_filter.allows('AAPL')
+    features.record('AAPL', date(2025, 3, 4), 0.3)
+    assert iv_filter.allows('AAPL')
@@ -0,0 +1,56 @@
+import pytest
+from datetime import datetime, timedelta, timezone
+from nexus.helpers import brokers, wash
+from nexus.helpers.domain import Order, Side
+from alpaca.trading.enums import OrderSide
+
+NOW = datetime(2025, 2, 3, 15, 0, tzinfo=timezone.utc)
+
+
+@pytest.fixture(autouse=True)
+def reset_config():
+    yield
+    wash.config = None
+    brokers.risk.wash.config = None
+
+
+def test_open_opposing_order_conflicts():
+    resting = Order('AAPL', 1, Side.SELL, order_type='limit', id='1', status='new')
+    assert wash.find_conflict('AAPL', 'buy', [resting], now=NOW) == 'opposing sell order 1 is open'
+    assert wash.find_conflict('AAPL', 'sell', [resting], now=NOW) is None
+    assert wash.find_conflict('MSFT', 'buy', [resting], now=NOW) is None
+
+
+def test_recent_opposing_fill_conflicts_within_window():
+    filled = Order('AAPL', 1, Side.BUY, id='2', status='filled', filled_qty=1, submitted_at=NOW - timedelta(seconds=20))
+    assert wash.find_conflict('AAPL', 'sell', [filled], window_seconds=30, now=NOW) is not None
+    assert wash.find_conflict('AAPL', 'sell', [filled], window_seconds=10, now=NOW) is None
+    assert wash.find_conflict('AAPL', 'sell', [filled], now=NOW) is None
+
+
+def test_broker_blocks_self_cross_when_enabled():
+    brokers.risk.wash.config = brokers.risk.wash.WashTradeConfig(enabled=True)
+    mock = brokers.MockBroker()
+    mock.set_price('AAPL', 100)
+    mock.submit_limit_order('AAPL', 1, OrderSide.SELL, 105)
+    with pytest.raises(brokers.risk.wash.WashTradeRejection):
+        mock.submit_market_order('AAPL', 1, OrderSide.BUY)
+    mock.submit_market_order('AAPL', 1, OrderSide.SELL)
+    assert mock.positions == {'AAPL': -1}
+
+
+def test_exits_are_exempt_from_the_self_cross_check():
+    assert wash.reduces_position('sell', 1, 2)
+    assert wash.reduces_position('buy', 3, -3)
+    assert not wash.reduces_position('sell', 3, 2)
+    assert not wash.reduces_position('buy', 1, 2)
+    assert not wash.reduces_position('sell', None, 2)
+    brokers.risk.wash.config = brokers.risk.wash.WashTradeConfig(enabled=True)
+    mock = brokers.MockBroker()
+    mock.set_price('AAPL', 100)
+    mock.submit_market_order('AAPL', 2, OrderSide.BUY)
+    mock.submit_limit_order('AAPL', 1, OrderSide.BUY, 95)
+    mock.submit_market_order('AAPL', 2, OrderSide.SELL)
+    assert 'AAPL' not in mock.positions
+    with pytest.raises(brokers.risk.wash.WashTradeRejection):
+        mock.submit_market_order('AAPL', 1, OrderSide.SELL)
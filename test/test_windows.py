@@ -0,0 +1,97 @@
+import pytest
+from nexus.helpers import windows
+from nexus.helpers.windows import RollingWindow, WindowStore
+
+
+def test_rolling_window_keeps_latest_values():
+    window = RollingWindow(3)
+    for value in [1, 2, 3, 4, 5]:
+        window.append(value)
+    assert window.values() == [3.0, 4.0, 5.0]
+    assert len(window) == 3
+    assert window.is_full()
+
+
+def test_rolling_window_partial_fill():
+    window = RollingWindow(5)
+    window.append(1.5)
+    window.append(2.5)
+    assert window.values() == [1.5, 2.5]
+    assert not window.is_full()
+
+
+def test_rolling_window_memory_is_fixed():
+    window = RollingWindow(100)
+    before = window.nbytes()
+    for value in range(10_000):
+        window.append(value)
+    assert window.nbytes() == before
+
+
+def test_rolling_window_invalid_capacity():
+    with pytest.raises(ValueError):
+        RollingWindow(0)
+
+
+def test_store_evicts_least_recent_over_capacity():
+    store = WindowStore(capacity=10, max_symbols=2, name='test')
+    store.append('AAPL', 1)
+    store.append('MSFT', 1)
+    store.append('AAPL', 2)
+    store.append('TSLA', 1)
+    assert store.symbols() == ['AAPL', 'TSLA']
+    assert store.get('MSFT') is None
+
+
+def test_store_evicts_idle_symbols():
+    store = WindowStore(capacity=10, idle_seconds=60, name='test')
+    store.append('AAPL', 1)
+    store.append('MSFT', 1)
+    store.get('AAPL').last_update -= 120
+    assert store.evict_idle() == ['AAPL']
+    assert store.symbols() == ['MSFT']
+
+
+def test_store_publishes_memory_gauge():
+    # Read the registry through the module so it is the instance the store writes to
+    metrics = windows.metrics
+    metrics.reset()
+    store = WindowStore(capacity=10, name='gauge-test')
+    store.append('AAPL', 1)
+    store.append('MSFT', 1)
+    gauges = {
+        gauge['name']: gauge['value']
+        for gauge in metrics.snapshot()['gauges']
+        if gauge['labels'] == {'store': 'gauge-test'}
+    }
+    assert gauges['window_symbols'] == 2
+    assert gauges['window_memory_bytes'] == store.memory_bytes()
+
+
+def test_running_stats_match_batch_statistics():
+    values = [4.0, 7.0, 13.0, 16.0, 1e9 + 4, 1e9 + 7]
+    stats = windows.RunningStats()
+    for value in values:
+        stats.update(value)
+    mean = sum(values) / len(values)
+    assert stats.count == 6
+    assert stats.mean == pytest.approx(mean)
+    assert stats.variance() == pytest.approx(sum((v - mean) ** 2 for v in values) / 5)
+    assert (stats.min, stats.max) == (4.0, 1e9 + 7)
+
+
+def test_running_stats_empty():
+    stats = windows.RunningStats()
+    assert stats.snapshot() == {'count': 0, 'mean': 0.0, 'variance': 0.0, 'min': None, 'max': None}
+
+
+def test_running_stats_store_keys_by_symbol():
+    store = windows.RunningStatsStore()
+    for value in [1.0, 2.0, 3.0]:
+        store.update('AAPL', value)
+    store.update('MSFT', 10.0)
+    assert store.get('AAPL').mean == pytest.approx(2.0)
+    assert store.get('MSFT').count == 1
+    store.reset('MSFT')
+    assert store.get('MSFT') is None
+    assert store.symbols() == ['AAPL']